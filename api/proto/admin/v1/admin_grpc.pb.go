@@ -0,0 +1,420 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: admin/v1/admin.proto
+
+// Package gatewaycd.admin.v1 is the gRPC admin API of the gateway-cd API
+// server. It exposes the canary management operations of the REST API with
+// typed messages and a watch stream.
+
+package adminv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	CanaryService_ListCanaries_FullMethodName  = "/gatewaycd.admin.v1.CanaryService/ListCanaries"
+	CanaryService_GetCanary_FullMethodName     = "/gatewaycd.admin.v1.CanaryService/GetCanary"
+	CanaryService_PauseCanary_FullMethodName   = "/gatewaycd.admin.v1.CanaryService/PauseCanary"
+	CanaryService_ResumeCanary_FullMethodName  = "/gatewaycd.admin.v1.CanaryService/ResumeCanary"
+	CanaryService_PromoteCanary_FullMethodName = "/gatewaycd.admin.v1.CanaryService/PromoteCanary"
+	CanaryService_AbortCanary_FullMethodName   = "/gatewaycd.admin.v1.CanaryService/AbortCanary"
+	CanaryService_RetryCanary_FullMethodName   = "/gatewaycd.admin.v1.CanaryService/RetryCanary"
+	CanaryService_WatchCanaries_FullMethodName = "/gatewaycd.admin.v1.CanaryService/WatchCanaries"
+)
+
+// CanaryServiceClient is the client API for CanaryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CanaryServiceClient interface {
+	// ListCanaries returns the canary deployments of every cluster, or of the
+	// selected cluster
+	ListCanaries(ctx context.Context, in *ListCanariesRequest, opts ...grpc.CallOption) (*ListCanariesResponse, error)
+	// GetCanary returns a single canary deployment
+	GetCanary(ctx context.Context, in *GetCanaryRequest, opts ...grpc.CallOption) (*Canary, error)
+	// PauseCanary pauses a running canary deployment
+	PauseCanary(ctx context.Context, in *CanaryActionRequest, opts ...grpc.CallOption) (*Canary, error)
+	// ResumeCanary resumes a paused canary deployment
+	ResumeCanary(ctx context.Context, in *CanaryActionRequest, opts ...grpc.CallOption) (*Canary, error)
+	// PromoteCanary promotes the canary to stable
+	PromoteCanary(ctx context.Context, in *CanaryActionRequest, opts ...grpc.CallOption) (*Canary, error)
+	// AbortCanary aborts a canary deployment and rolls it back
+	AbortCanary(ctx context.Context, in *CanaryActionRequest, opts ...grpc.CallOption) (*Canary, error)
+	// RetryCanary restarts the rollout of a Failed canary deployment
+	RetryCanary(ctx context.Context, in *RetryCanaryRequest, opts ...grpc.CallOption) (*Canary, error)
+	// WatchCanaries streams the current canary deployments as ADDED events,
+	// then every change until the call is cancelled
+	WatchCanaries(ctx context.Context, in *WatchCanariesRequest, opts ...grpc.CallOption) (CanaryService_WatchCanariesClient, error)
+}
+
+type canaryServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCanaryServiceClient(cc grpc.ClientConnInterface) CanaryServiceClient {
+	return &canaryServiceClient{cc}
+}
+
+func (c *canaryServiceClient) ListCanaries(ctx context.Context, in *ListCanariesRequest, opts ...grpc.CallOption) (*ListCanariesResponse, error) {
+	out := new(ListCanariesResponse)
+	err := c.cc.Invoke(ctx, CanaryService_ListCanaries_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *canaryServiceClient) GetCanary(ctx context.Context, in *GetCanaryRequest, opts ...grpc.CallOption) (*Canary, error) {
+	out := new(Canary)
+	err := c.cc.Invoke(ctx, CanaryService_GetCanary_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *canaryServiceClient) PauseCanary(ctx context.Context, in *CanaryActionRequest, opts ...grpc.CallOption) (*Canary, error) {
+	out := new(Canary)
+	err := c.cc.Invoke(ctx, CanaryService_PauseCanary_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *canaryServiceClient) ResumeCanary(ctx context.Context, in *CanaryActionRequest, opts ...grpc.CallOption) (*Canary, error) {
+	out := new(Canary)
+	err := c.cc.Invoke(ctx, CanaryService_ResumeCanary_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *canaryServiceClient) PromoteCanary(ctx context.Context, in *CanaryActionRequest, opts ...grpc.CallOption) (*Canary, error) {
+	out := new(Canary)
+	err := c.cc.Invoke(ctx, CanaryService_PromoteCanary_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *canaryServiceClient) AbortCanary(ctx context.Context, in *CanaryActionRequest, opts ...grpc.CallOption) (*Canary, error) {
+	out := new(Canary)
+	err := c.cc.Invoke(ctx, CanaryService_AbortCanary_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *canaryServiceClient) RetryCanary(ctx context.Context, in *RetryCanaryRequest, opts ...grpc.CallOption) (*Canary, error) {
+	out := new(Canary)
+	err := c.cc.Invoke(ctx, CanaryService_RetryCanary_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *canaryServiceClient) WatchCanaries(ctx context.Context, in *WatchCanariesRequest, opts ...grpc.CallOption) (CanaryService_WatchCanariesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CanaryService_ServiceDesc.Streams[0], CanaryService_WatchCanaries_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &canaryServiceWatchCanariesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CanaryService_WatchCanariesClient interface {
+	Recv() (*CanaryEvent, error)
+	grpc.ClientStream
+}
+
+type canaryServiceWatchCanariesClient struct {
+	grpc.ClientStream
+}
+
+func (x *canaryServiceWatchCanariesClient) Recv() (*CanaryEvent, error) {
+	m := new(CanaryEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CanaryServiceServer is the server API for CanaryService service.
+// All implementations must embed UnimplementedCanaryServiceServer
+// for forward compatibility
+type CanaryServiceServer interface {
+	// ListCanaries returns the canary deployments of every cluster, or of the
+	// selected cluster
+	ListCanaries(context.Context, *ListCanariesRequest) (*ListCanariesResponse, error)
+	// GetCanary returns a single canary deployment
+	GetCanary(context.Context, *GetCanaryRequest) (*Canary, error)
+	// PauseCanary pauses a running canary deployment
+	PauseCanary(context.Context, *CanaryActionRequest) (*Canary, error)
+	// ResumeCanary resumes a paused canary deployment
+	ResumeCanary(context.Context, *CanaryActionRequest) (*Canary, error)
+	// PromoteCanary promotes the canary to stable
+	PromoteCanary(context.Context, *CanaryActionRequest) (*Canary, error)
+	// AbortCanary aborts a canary deployment and rolls it back
+	AbortCanary(context.Context, *CanaryActionRequest) (*Canary, error)
+	// RetryCanary restarts the rollout of a Failed canary deployment
+	RetryCanary(context.Context, *RetryCanaryRequest) (*Canary, error)
+	// WatchCanaries streams the current canary deployments as ADDED events,
+	// then every change until the call is cancelled
+	WatchCanaries(*WatchCanariesRequest, CanaryService_WatchCanariesServer) error
+	mustEmbedUnimplementedCanaryServiceServer()
+}
+
+// UnimplementedCanaryServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCanaryServiceServer struct {
+}
+
+func (UnimplementedCanaryServiceServer) ListCanaries(context.Context, *ListCanariesRequest) (*ListCanariesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCanaries not implemented")
+}
+func (UnimplementedCanaryServiceServer) GetCanary(context.Context, *GetCanaryRequest) (*Canary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCanary not implemented")
+}
+func (UnimplementedCanaryServiceServer) PauseCanary(context.Context, *CanaryActionRequest) (*Canary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseCanary not implemented")
+}
+func (UnimplementedCanaryServiceServer) ResumeCanary(context.Context, *CanaryActionRequest) (*Canary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumeCanary not implemented")
+}
+func (UnimplementedCanaryServiceServer) PromoteCanary(context.Context, *CanaryActionRequest) (*Canary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PromoteCanary not implemented")
+}
+func (UnimplementedCanaryServiceServer) AbortCanary(context.Context, *CanaryActionRequest) (*Canary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AbortCanary not implemented")
+}
+func (UnimplementedCanaryServiceServer) RetryCanary(context.Context, *RetryCanaryRequest) (*Canary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetryCanary not implemented")
+}
+func (UnimplementedCanaryServiceServer) WatchCanaries(*WatchCanariesRequest, CanaryService_WatchCanariesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchCanaries not implemented")
+}
+func (UnimplementedCanaryServiceServer) mustEmbedUnimplementedCanaryServiceServer() {}
+
+// UnsafeCanaryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CanaryServiceServer will
+// result in compilation errors.
+type UnsafeCanaryServiceServer interface {
+	mustEmbedUnimplementedCanaryServiceServer()
+}
+
+func RegisterCanaryServiceServer(s grpc.ServiceRegistrar, srv CanaryServiceServer) {
+	s.RegisterService(&CanaryService_ServiceDesc, srv)
+}
+
+func _CanaryService_ListCanaries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCanariesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CanaryServiceServer).ListCanaries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CanaryService_ListCanaries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CanaryServiceServer).ListCanaries(ctx, req.(*ListCanariesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CanaryService_GetCanary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCanaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CanaryServiceServer).GetCanary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CanaryService_GetCanary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CanaryServiceServer).GetCanary(ctx, req.(*GetCanaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CanaryService_PauseCanary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CanaryActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CanaryServiceServer).PauseCanary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CanaryService_PauseCanary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CanaryServiceServer).PauseCanary(ctx, req.(*CanaryActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CanaryService_ResumeCanary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CanaryActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CanaryServiceServer).ResumeCanary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CanaryService_ResumeCanary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CanaryServiceServer).ResumeCanary(ctx, req.(*CanaryActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CanaryService_PromoteCanary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CanaryActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CanaryServiceServer).PromoteCanary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CanaryService_PromoteCanary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CanaryServiceServer).PromoteCanary(ctx, req.(*CanaryActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CanaryService_AbortCanary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CanaryActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CanaryServiceServer).AbortCanary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CanaryService_AbortCanary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CanaryServiceServer).AbortCanary(ctx, req.(*CanaryActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CanaryService_RetryCanary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetryCanaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CanaryServiceServer).RetryCanary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CanaryService_RetryCanary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CanaryServiceServer).RetryCanary(ctx, req.(*RetryCanaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CanaryService_WatchCanaries_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchCanariesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CanaryServiceServer).WatchCanaries(m, &canaryServiceWatchCanariesServer{stream})
+}
+
+type CanaryService_WatchCanariesServer interface {
+	Send(*CanaryEvent) error
+	grpc.ServerStream
+}
+
+type canaryServiceWatchCanariesServer struct {
+	grpc.ServerStream
+}
+
+func (x *canaryServiceWatchCanariesServer) Send(m *CanaryEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// CanaryService_ServiceDesc is the grpc.ServiceDesc for CanaryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CanaryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gatewaycd.admin.v1.CanaryService",
+	HandlerType: (*CanaryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListCanaries",
+			Handler:    _CanaryService_ListCanaries_Handler,
+		},
+		{
+			MethodName: "GetCanary",
+			Handler:    _CanaryService_GetCanary_Handler,
+		},
+		{
+			MethodName: "PauseCanary",
+			Handler:    _CanaryService_PauseCanary_Handler,
+		},
+		{
+			MethodName: "ResumeCanary",
+			Handler:    _CanaryService_ResumeCanary_Handler,
+		},
+		{
+			MethodName: "PromoteCanary",
+			Handler:    _CanaryService_PromoteCanary_Handler,
+		},
+		{
+			MethodName: "AbortCanary",
+			Handler:    _CanaryService_AbortCanary_Handler,
+		},
+		{
+			MethodName: "RetryCanary",
+			Handler:    _CanaryService_RetryCanary_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchCanaries",
+			Handler:       _CanaryService_WatchCanaries_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "admin/v1/admin.proto",
+}
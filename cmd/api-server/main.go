@@ -1,18 +1,29 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	gatewaycdv1beta1 "gateway-cd/pkg/api/v1beta1"
 	"gateway-cd/pkg/api"
+	"gateway-cd/pkg/quota"
 )
 
 var (
@@ -22,30 +33,83 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(gatewaycdv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(gatewaycdv1beta1.AddToScheme(scheme))
 	utilruntime.Must(gatewayapi.AddToScheme(scheme))
+	utilruntime.Must(gatewayapiv1alpha2.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 }
 
 func main() {
 	var addr string
+	var grpcAddr string
+	var metricsAddr string
+	var quotaConfigMap string
+	var useCache bool
+	var serverFlags api.Flags
 
 	flag.StringVar(&addr, "addr", ":8080", "The address to bind the API server to")
+	flag.StringVar(&grpcAddr, "grpc-addr", ":9090", "The address to bind the gRPC admin API to. Empty disables it.")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8081",
+		"The address the Prometheus metrics endpoint binds to. Empty disables it.")
+	flag.StringVar(&quotaConfigMap, "quota-configmap", "",
+		"namespace/name of the ConfigMap holding the per-team quotas enforced on created canaries. Empty disables quotas.")
+	flag.BoolVar(&useCache, "cache", false,
+		"Serve reads from a shared informer cache indexed by namespace and phase instead of querying the Kubernetes API on every request")
+	serverFlags.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	// Set up Kubernetes client
-	config := ctrl.GetConfigOrDie()
-
-	client, err := client.New(config, client.Options{
-		Scheme: scheme,
-	})
+	// Set up Kubernetes client; it watches canaries for the gRPC watch stream
+	var k8sClient client.WithWatch
+	var err error
+	if useCache {
+		k8sClient, err = api.NewCachedClient(context.Background(), ctrl.GetConfigOrDie(), scheme)
+	} else {
+		k8sClient, err = client.NewWithWatch(ctrl.GetConfigOrDie(), client.Options{
+			Scheme: scheme,
+		})
+	}
 	if err != nil {
 		log.Fatal("Failed to create Kubernetes client:", err)
 	}
 
 	// Create API server
-	server := api.NewServer(client)
+	opts, err := serverFlags.Options(k8sClient, scheme)
+	if err != nil {
+		log.Fatal("Invalid API server flags:", err)
+	}
+	if quotaConfigMap != "" {
+		configMap, err := quota.ParseConfigMap(quotaConfigMap)
+		if err != nil {
+			log.Fatal("Invalid --quota-configmap:", err)
+		}
+		opts = append(opts, api.WithQuotas(quota.NewChecker(configMap)))
+	}
+	if useCache {
+		opts = append(opts, api.WithFieldIndexes())
+	}
+	server := api.NewServer(k8sClient, opts...)
+
+	// Drain in-flight requests and watch streams on SIGTERM, so rolling
+	// restarts of the api-server drop no connections
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if metricsAddr != "" {
+		log.Printf("Serving metrics on %s", metricsAddr)
+		go func() {
+			handler := promhttp.HandlerFor(ctrlmetrics.Registry, promhttp.HandlerOpts{})
+			if err := http.ListenAndServe(metricsAddr, handler); err != nil {
+				log.Print("Metrics endpoint failed:", err)
+			}
+		}()
+	}
 
 	log.Printf("Starting API server on %s", addr)
-	if err := server.Run(addr); err != nil {
-		log.Fatal("Failed to start API server:", err)
+	if grpcAddr != "" {
+		log.Printf("Starting gRPC admin API on %s", grpcAddr)
 	}
-}
\ No newline at end of file
+	if err := server.Serve(ctx, addr, grpcAddr); err != nil {
+		log.Fatal("API server failed:", err)
+	}
+	log.Print("API server stopped")
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	gatewaycdv1beta1 "gateway-cd/pkg/api/v1beta1"
+	"gateway-cd/pkg/diagnose"
+)
+
+// controlAnnotations maps control actions to the annotation the controller reacts to
+var controlAnnotations = map[string]string{
+	"resume":  "gateway-cd.io/resume",
+	"pause":   "gateway-cd.io/pause",
+	"abort":   "gateway-cd.io/abort",
+	"promote": "gateway-cd.io/promote",
+	"retry":   "gateway-cd.io/retry",
+}
+
+// The CLI records itself as the source of its control actions in the audit
+// trail of the canaries
+const (
+	requestedViaAnnotation = "gateway-cd.io/requested-via"
+	requestedViaHeader     = "X-Requested-Via"
+	requestedVia           = "CLI"
+)
+
+// backend reads and controls canary deployments
+type backend interface {
+	List(ctx context.Context, namespace string) ([]gatewaycdv1alpha1.CanaryDeployment, error)
+	Get(ctx context.Context, namespace, name string) (*gatewaycdv1alpha1.CanaryDeployment, error)
+	Control(ctx context.Context, namespace, name, action string) error
+	Diagnose(ctx context.Context, namespace, name string) (*diagnose.Report, error)
+}
+
+// kubeBackend talks to the Kubernetes API directly
+type kubeBackend struct {
+	client client.Client
+}
+
+// newKubeBackend creates a backend from the current kubeconfig
+func newKubeBackend() (*kubeBackend, error) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(gatewaycdv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(gatewaycdv1beta1.AddToScheme(scheme))
+	utilruntime.Must(gatewayapi.AddToScheme(scheme))
+	utilruntime.Must(gatewayapiv1alpha2.AddToScheme(scheme))
+
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	return &kubeBackend{client: c}, nil
+}
+
+// List returns the canary deployments in namespace, or all namespaces if empty
+func (b *kubeBackend) List(ctx context.Context, namespace string) ([]gatewaycdv1alpha1.CanaryDeployment, error) {
+	var canaries gatewaycdv1alpha1.CanaryDeploymentList
+	var listOpts []client.ListOption
+	if namespace != "" {
+		listOpts = append(listOpts, client.InNamespace(namespace))
+	}
+	if err := b.client.List(ctx, &canaries, listOpts...); err != nil {
+		return nil, err
+	}
+	return canaries.Items, nil
+}
+
+// Get returns a single canary deployment
+func (b *kubeBackend) Get(ctx context.Context, namespace, name string) (*gatewaycdv1alpha1.CanaryDeployment, error) {
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if err := b.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &canary); err != nil {
+		return nil, err
+	}
+	return &canary, nil
+}
+
+// Control sets the annotation of the action on the canary deployment
+func (b *kubeBackend) Control(ctx context.Context, namespace, name, action string) error {
+	annotation, ok := controlAnnotations[action]
+	if !ok {
+		return fmt.Errorf("unsupported action %q", action)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{annotation: "true", requestedViaAnnotation: requestedVia},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	canary := &gatewaycdv1alpha1.CanaryDeployment{}
+	canary.Namespace = namespace
+	canary.Name = name
+	return b.client.Patch(ctx, canary, client.RawPatch(types.MergePatchType, patch))
+}
+
+// Diagnose inspects the canary deployment and the objects it depends on
+func (b *kubeBackend) Diagnose(ctx context.Context, namespace, name string) (*diagnose.Report, error) {
+	canary, err := b.Get(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return diagnose.Diagnose(ctx, b.client, canary), nil
+}
+
+// restBackend talks to the gateway-cd REST API
+type restBackend struct {
+	baseURL string
+	client  *http.Client
+}
+
+// newRESTBackend creates a backend for the API server at serverURL
+func newRESTBackend(serverURL string) *restBackend {
+	return &restBackend{
+		baseURL: strings.TrimSuffix(serverURL, "/") + "/api/v1",
+		client: &http.Client{
+			Timeout: time.Second * 30,
+		},
+	}
+}
+
+// List returns the canary deployments in namespace, or all namespaces if empty
+func (b *restBackend) List(ctx context.Context, namespace string) ([]gatewaycdv1alpha1.CanaryDeployment, error) {
+	path := "/canaries"
+	if namespace != "" {
+		path += "?namespace=" + url.QueryEscape(namespace)
+	}
+	var canaries []gatewaycdv1alpha1.CanaryDeployment
+	if err := b.do(ctx, http.MethodGet, path, &canaries); err != nil {
+		return nil, err
+	}
+	return canaries, nil
+}
+
+// Get returns a single canary deployment
+func (b *restBackend) Get(ctx context.Context, namespace, name string) (*gatewaycdv1alpha1.CanaryDeployment, error) {
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if err := b.do(ctx, http.MethodGet, canaryPath(namespace, name), &canary); err != nil {
+		return nil, err
+	}
+	return &canary, nil
+}
+
+// Control calls the control endpoint of the action
+func (b *restBackend) Control(ctx context.Context, namespace, name, action string) error {
+	if _, ok := controlAnnotations[action]; !ok {
+		return fmt.Errorf("unsupported action %q", action)
+	}
+	return b.do(ctx, http.MethodPost, canaryPath(namespace, name)+"/"+action, nil)
+}
+
+// Diagnose calls the diagnose endpoint
+func (b *restBackend) Diagnose(ctx context.Context, namespace, name string) (*diagnose.Report, error) {
+	var report diagnose.Report
+	if err := b.do(ctx, http.MethodGet, canaryPath(namespace, name)+"/diagnose", &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// do performs a request and decodes the JSON response into out if set
+func (b *restBackend) do(ctx context.Context, method, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+path, bytes.NewReader(nil))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(requestedViaHeader, requestedVia)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s %s: %s", method, path, apiErr.Error)
+		}
+		return fmt.Errorf("%s %s failed with status %d", method, path, resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// canaryPath returns the REST path of a canary deployment
+func canaryPath(namespace, name string) string {
+	return fmt.Sprintf("/canaries/%s/%s", url.PathEscape(namespace), url.PathEscape(name))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	adminv1 "gateway-cd/api/proto/admin/v1"
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/diagnose"
+)
+
+// grpcBackend talks to the gateway-cd gRPC admin API
+type grpcBackend struct {
+	client adminv1.CanaryServiceClient
+}
+
+// newGRPCBackend creates a backend for the gRPC admin API at addr
+func newGRPCBackend(addr string) (*grpcBackend, error) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	return &grpcBackend{client: adminv1.NewCanaryServiceClient(conn)}, nil
+}
+
+// List returns the canary deployments in namespace, or all namespaces if empty
+func (b *grpcBackend) List(ctx context.Context, namespace string) ([]gatewaycdv1alpha1.CanaryDeployment, error) {
+	resp, err := b.client.ListCanaries(ctx, &adminv1.ListCanariesRequest{Namespace: namespace})
+	if err != nil {
+		return nil, err
+	}
+	canaries := make([]gatewaycdv1alpha1.CanaryDeployment, 0, len(resp.Canaries))
+	for _, message := range resp.Canaries {
+		canary, err := decodeCanary(message)
+		if err != nil {
+			return nil, err
+		}
+		canaries = append(canaries, *canary)
+	}
+	return canaries, nil
+}
+
+// Get returns a single canary deployment
+func (b *grpcBackend) Get(ctx context.Context, namespace, name string) (*gatewaycdv1alpha1.CanaryDeployment, error) {
+	message, err := b.client.GetCanary(ctx, &adminv1.GetCanaryRequest{Namespace: namespace, Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return decodeCanary(message)
+}
+
+// Control calls the RPC of the action
+func (b *grpcBackend) Control(ctx context.Context, namespace, name, action string) error {
+	req := &adminv1.CanaryActionRequest{Namespace: namespace, Name: name}
+	ctx = metadata.AppendToOutgoingContext(ctx, requestedViaHeader, requestedVia)
+	var err error
+	switch action {
+	case "resume":
+		_, err = b.client.ResumeCanary(ctx, req)
+	case "pause":
+		_, err = b.client.PauseCanary(ctx, req)
+	case "abort":
+		_, err = b.client.AbortCanary(ctx, req)
+	case "promote":
+		_, err = b.client.PromoteCanary(ctx, req)
+	case "retry":
+		_, err = b.client.RetryCanary(ctx, &adminv1.RetryCanaryRequest{Namespace: namespace, Name: name})
+	default:
+		return fmt.Errorf("unsupported action %q", action)
+	}
+	return err
+}
+
+// Diagnose is not part of the gRPC admin API
+func (b *grpcBackend) Diagnose(ctx context.Context, namespace, name string) (*diagnose.Report, error) {
+	return nil, fmt.Errorf("diagnose is not available over gRPC, use --server or the kubeconfig")
+}
+
+// decodeCanary decodes the full canary deployment carried by a message
+func decodeCanary(message *adminv1.Canary) (*gatewaycdv1alpha1.CanaryDeployment, error) {
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if err := json.Unmarshal(message.Object, &canary); err != nil {
+		return nil, fmt.Errorf("failed to decode canary deployment %s/%s: %w", message.Namespace, message.Name, err)
+	}
+	return &canary, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const usage = `gateway-cd controls canary deployments.
+
+Usage:
+  gateway-cd <command> [name] [flags]
+
+Commands:
+  get       List canary deployments, or show one by name
+  status    Show the detailed status of a canary deployment
+  promote   Promote a canary deployment
+  pause     Pause a canary deployment
+  resume    Resume a paused canary deployment
+  abort     Abort a canary deployment and roll back
+  retry     Restart the rollout of a failed canary deployment
+  watch     Follow a canary deployment until it finishes
+  diagnose  Explain why a rollout is stuck; the name may be namespace/name
+
+Flags:
+  -n, --namespace       Namespace of the canary deployment (default "default")
+  -A, --all-namespaces  List canary deployments in all namespaces (get only)
+  -o, --output          Output format: table or json (default "table")
+  --server              URL of the gateway-cd API server; the Kubernetes API
+                        from the current kubeconfig is used when unset
+  --grpc-server         host:port of the gateway-cd gRPC admin API, used
+                        instead of --server when set
+  --interval            Poll interval of watch (default 2s)
+`
+
+// options are the flags shared by every command
+type options struct {
+	namespace     string
+	allNamespaces bool
+	output        string
+	server        string
+	grpcServer    string
+	interval      time.Duration
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	command := os.Args[1]
+	opts, args, err := parseFlags(command, os.Args[2:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if err := run(context.Background(), command, opts, args); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// parseFlags parses the shared flags, which may appear before or after the name
+func parseFlags(command string, arguments []string) (*options, []string, error) {
+	opts := &options{}
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	for _, name := range []string{"namespace", "n"} {
+		fs.StringVar(&opts.namespace, name, "default", "Namespace of the canary deployment")
+	}
+	for _, name := range []string{"all-namespaces", "A"} {
+		fs.BoolVar(&opts.allNamespaces, name, false, "List canary deployments in all namespaces")
+	}
+	for _, name := range []string{"output", "o"} {
+		fs.StringVar(&opts.output, name, "table", "Output format: table or json")
+	}
+	fs.StringVar(&opts.server, "server", os.Getenv("GATEWAY_CD_SERVER"), "URL of the gateway-cd API server")
+	fs.StringVar(&opts.grpcServer, "grpc-server", os.Getenv("GATEWAY_CD_GRPC_SERVER"), "host:port of the gateway-cd gRPC admin API")
+	fs.DurationVar(&opts.interval, "interval", time.Second*2, "Poll interval of watch")
+
+	var args []string
+	for {
+		if err := fs.Parse(arguments); err != nil {
+			return nil, nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		args = append(args, fs.Arg(0))
+		arguments = fs.Args()[1:]
+	}
+
+	if opts.output != "table" && opts.output != "json" {
+		return nil, nil, fmt.Errorf("unsupported output format %q", opts.output)
+	}
+	return opts, args, nil
+}
+
+// run executes a command
+func run(ctx context.Context, command string, opts *options, args []string) error {
+	var b backend
+	if opts.grpcServer != "" {
+		grpcBackend, err := newGRPCBackend(opts.grpcServer)
+		if err != nil {
+			return err
+		}
+		b = grpcBackend
+	} else if opts.server != "" {
+		b = newRESTBackend(opts.server)
+	} else {
+		kube, err := newKubeBackend()
+		if err != nil {
+			return err
+		}
+		b = kube
+	}
+
+	switch command {
+	case "get":
+		return get(ctx, b, opts, args)
+	case "status":
+		canary, err := getOne(ctx, b, opts, args)
+		if err != nil {
+			return err
+		}
+		if opts.output == "json" {
+			return printJSON(os.Stdout, canary.Status)
+		}
+		return printStatus(os.Stdout, canary)
+	case "promote", "pause", "resume", "abort", "retry":
+		name, err := requireName(args)
+		if err != nil {
+			return err
+		}
+		if err := b.Control(ctx, opts.namespace, name, command); err != nil {
+			return err
+		}
+		fmt.Printf("canarydeployment %s/%s: %s requested\n", opts.namespace, name, command)
+		return nil
+	case "watch":
+		return watch(ctx, b, opts, args)
+	case "diagnose":
+		name, err := requireName(args)
+		if err != nil {
+			return err
+		}
+		namespace := opts.namespace
+		if ns, n, ok := strings.Cut(name, "/"); ok {
+			namespace, name = ns, n
+		}
+		report, err := b.Diagnose(ctx, namespace, name)
+		if err != nil {
+			return err
+		}
+		if opts.output == "json" {
+			return printJSON(os.Stdout, report)
+		}
+		return printDiagnosis(os.Stdout, report)
+	default:
+		return fmt.Errorf("unknown command %q, run gateway-cd help for usage", command)
+	}
+}
+
+// get lists canary deployments or shows a single one
+func get(ctx context.Context, b backend, opts *options, args []string) error {
+	var canaries []gatewaycdv1alpha1.CanaryDeployment
+	if len(args) > 0 {
+		canary, err := getOne(ctx, b, opts, args)
+		if err != nil {
+			return err
+		}
+		if opts.output == "json" {
+			return printJSON(os.Stdout, canary)
+		}
+		canaries = append(canaries, *canary)
+	} else {
+		namespace := opts.namespace
+		if opts.allNamespaces {
+			namespace = ""
+		}
+		list, err := b.List(ctx, namespace)
+		if err != nil {
+			return err
+		}
+		if opts.output == "json" {
+			return printJSON(os.Stdout, list)
+		}
+		canaries = list
+	}
+	return printTable(os.Stdout, canaries)
+}
+
+// watch polls a canary deployment and prints every change until it finishes
+func watch(ctx context.Context, b backend, opts *options, args []string) error {
+	var last string
+	for {
+		canary, err := getOne(ctx, b, opts, args)
+		if err != nil {
+			return err
+		}
+
+		current := fmt.Sprintf("%s/%s/%d/%s", canary.Status.Phase, stepProgress(canary), canary.Status.CanaryWeight, canary.Status.Message)
+		if current != last {
+			last = current
+			if opts.output == "json" {
+				if err := printJSON(os.Stdout, canary.Status); err != nil {
+					return err
+				}
+			} else {
+				fmt.Printf("%s  %-12s step %-5s %3d%% canary  %s\n", time.Now().Format("15:04:05"),
+					canary.Status.Phase, stepProgress(canary), canary.Status.CanaryWeight, canary.Status.Message)
+			}
+		}
+
+		if canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhaseSucceeded ||
+			canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhaseFailed {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opts.interval):
+		}
+	}
+}
+
+// getOne fetches the canary deployment named by the first argument
+func getOne(ctx context.Context, b backend, opts *options, args []string) (*gatewaycdv1alpha1.CanaryDeployment, error) {
+	name, err := requireName(args)
+	if err != nil {
+		return nil, err
+	}
+	return b.Get(ctx, opts.namespace, name)
+}
+
+// requireName returns the single name argument of a command
+func requireName(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("exactly one canary deployment name is required")
+	}
+	return args[0], nil
+}
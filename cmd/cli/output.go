@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/diagnose"
+)
+
+// printJSON writes v as indented JSON
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printTable writes one row per canary deployment
+func printTable(w io.Writer, canaries []gatewaycdv1alpha1.CanaryDeployment) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tNAME\tPHASE\tSTEP\tCANARY WEIGHT\tREADY\tAGE")
+	for _, canary := range canaries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d%%\t%s\t%s\n",
+			canary.Namespace,
+			canary.Name,
+			canary.Status.Phase,
+			stepProgress(&canary),
+			canary.Status.CanaryWeight,
+			conditionStatus(&canary, gatewaycdv1alpha1.ConditionTypeReady),
+			age(canary.CreationTimestamp.Time),
+		)
+	}
+	return tw.Flush()
+}
+
+// printStatus writes a detailed status of a single canary deployment
+func printStatus(w io.Writer, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s/%s\n", canary.Namespace, canary.Name)
+	fmt.Fprintf(tw, "Phase:\t%s\n", canary.Status.Phase)
+	fmt.Fprintf(tw, "Message:\t%s\n", canary.Status.Message)
+	fmt.Fprintf(tw, "Step:\t%s\n", stepProgress(canary))
+	fmt.Fprintf(tw, "Traffic:\t%d%% canary, %d%% stable\n", canary.Status.CanaryWeight, canary.Status.StableWeight)
+	if canary.Status.ManagedRoute != "" {
+		fmt.Fprintf(tw, "Route:\t%s (weight %d, generation %d)\n",
+			canary.Status.ManagedRoute, canary.Status.LastAppliedWeight, canary.Status.RouteGeneration)
+	}
+	if len(canary.Status.Routes) > 1 {
+		fmt.Fprintln(tw, "Routes:")
+		for _, route := range canary.Status.Routes {
+			fmt.Fprintf(tw, "  %s %s/%s	weight %d, generation %d	%s\n",
+				route.Kind, route.Namespace, route.Name, route.Weight, route.Generation, route.Error)
+		}
+	}
+	if canary.Status.RollbackReason != "" {
+		fmt.Fprintf(tw, "Rollback Reason:\t%s\n", canary.Status.RollbackReason)
+	}
+	if run := canary.Status.AnalysisRun; run != nil {
+		fmt.Fprintf(tw, "Analysis:\t%s (success rate %.4f, latency %dms)\n", run.Phase, run.SuccessRate, run.AverageLatency)
+		if canary.Spec.Baseline != nil {
+			fmt.Fprintf(tw, "Baseline:\tsuccess rate %.4f, latency %dms\n", run.BaselineSuccessRate, run.BaselineLatency)
+		}
+		for _, result := range run.MetricResults {
+			fmt.Fprintf(tw, "  %s:\t%g (threshold %g, passed %t)\n", result.Name, result.Value, result.Threshold, result.Passed)
+		}
+	}
+	if md := canary.Status.ChangeMetadata; md != nil {
+		fmt.Fprintf(tw, "Change:\t%s\n", strings.Join(nonEmpty(md.GitSHA, md.Author, md.Ticket, md.PullRequestURL), ", "))
+	}
+	if len(canary.Status.Conditions) > 0 {
+		fmt.Fprintln(tw, "Conditions:")
+		for _, condition := range canary.Status.Conditions {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason)
+		}
+	}
+	return tw.Flush()
+}
+
+// printDiagnosis writes the findings of a diagnosis, most severe first
+func printDiagnosis(w io.Writer, report *diagnose.Report) error {
+	fmt.Fprintf(w, "%s/%s is %s: %s\n", report.Namespace, report.Name, report.Phase, report.Message)
+	if len(report.Findings) == 0 {
+		fmt.Fprintln(w, "No blockers found.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nSEVERITY\tCHECK\tFINDING")
+	for _, finding := range report.Findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", finding.Severity, finding.Check, finding.Message)
+		if finding.Hint != "" {
+			fmt.Fprintf(tw, "\t\t  -> %s\n", finding.Hint)
+		}
+	}
+	return tw.Flush()
+}
+
+// stepProgress renders the current step as "current/total"
+func stepProgress(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	total := len(canary.Spec.TrafficSplit)
+	current := int(canary.Status.CurrentStep) + 1
+	if current > total {
+		current = total
+	}
+	return fmt.Sprintf("%d/%d", current, total)
+}
+
+// conditionStatus returns the status of a condition or "Unknown" if unset
+func conditionStatus(canary *gatewaycdv1alpha1.CanaryDeployment, conditionType string) string {
+	condition := meta.FindStatusCondition(canary.Status.Conditions, conditionType)
+	if condition == nil {
+		return "Unknown"
+	}
+	return string(condition.Status)
+}
+
+// age renders the time since t like kubectl does
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(t))
+}
+
+// nonEmpty returns the non-empty values
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
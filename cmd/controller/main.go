@@ -3,19 +3,28 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
-	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	crwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	"gateway-cd/pkg/api"
 	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
 	"gateway-cd/pkg/controller"
-	"gateway-cd/pkg/gateway"
+	"gateway-cd/pkg/gatewaycd"
+	"gateway-cd/pkg/grafana"
+	"gateway-cd/pkg/integrations/scm"
 	"gateway-cd/pkg/metrics"
+	"gateway-cd/pkg/notifications"
+	"gateway-cd/pkg/otlp"
+	"gateway-cd/pkg/quota"
+	"gateway-cd/pkg/retry"
 )
 
 var (
@@ -24,23 +33,127 @@ var (
 )
 
 func init() {
-	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
-	utilruntime.Must(gatewaycdv1alpha1.AddToScheme(scheme))
-	utilruntime.Must(gatewayapi.AddToScheme(scheme))
+	utilruntime.Must(gatewaycd.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 }
 
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var leaderElectionID string
+	var watchNamespaces string
+	var labelSelector string
 	var probeAddr string
 	var prometheusURL string
+	var prometheusBackend string
+	var prometheusTenantID string
+	var prometheusLookbackDelta time.Duration
+	var tempoURL string
+	var jaegerURL string
+	var cloudMonitoringProject string
+	var enableWebhooks bool
+	var trustedRequesters string
+	var webhookPort int
+	var webhookCertDir string
+	var providerFailureThreshold int
+	var providerOpenDuration time.Duration
+	var providerQueryCacheTTL time.Duration
+	var grafanaURL string
+	var grafanaToken string
+	var otlpEndpoint string
+	var otlpHeaders string
+	var otlpTracing bool
+	var slackWebhookURL string
+	var teamsWebhookURL string
+	var notificationWebhookURL string
+	var scmProvider string
+	var scmURL string
+	var scmToken string
+	var quotaConfigMap string
+	var retryPolicy retry.Policy
+	var maxRetries int
+	var driftCheckInterval time.Duration
+	var requeue controller.RequeueIntervals
+	var autoCanary bool
+	var apiAddr string
+	var apiGRPCAddr string
+	var apiLeaderElection bool
+	var apiFlags api.Flags
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "gateway-cd-controller",
+		"The name of the leader election lease. Controllers sharding the canaries in the same namespace need distinct IDs.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", os.Getenv("WATCH_NAMESPACE"),
+		"Comma-separated namespaces whose canaries and related resources the controller watches. Empty watches all namespaces.")
+	flag.StringVar(&labelSelector, "label-selector", "",
+		"Only manage the CanaryDeployments matching this label selector, e.g. team=payments, so several controllers can shard the canaries.")
 	flag.StringVar(&prometheusURL, "prometheus-url", "", "The URL of the Prometheus server for metrics analysis.")
+	flag.StringVar(&prometheusBackend, "prometheus-backend", string(gatewaycdv1alpha1.PrometheusBackendPrometheus),
+		"The Prometheus-compatible server behind --prometheus-url: Prometheus, VictoriaMetrics, Thanos or Mimir.")
+	flag.StringVar(&prometheusTenantID, "prometheus-tenant-id", "", "The tenant queried on a multi-tenant Thanos or Mimir behind --prometheus-url.")
+	flag.DurationVar(&prometheusLookbackDelta, "prometheus-lookback-delta", 0,
+		"How far back queries look for the latest sample of a series. 0 keeps the backend's default.")
+	flag.StringVar(&tempoURL, "tempo-url", "", "The URL of a Grafana Tempo server for trace analysis.")
+	flag.StringVar(&jaegerURL, "jaeger-url", "", "The URL of a Jaeger query service for trace analysis, used when --tempo-url is not set.")
+	flag.StringVar(&cloudMonitoringProject, "cloud-monitoring-project", "", "The Google Cloud project whose Cloud Monitoring metrics are analyzed through workload identity, used when --prometheus-url is not set.")
+	flag.IntVar(&providerFailureThreshold, "provider-failure-threshold", 5,
+		"Consecutive metrics provider failures before the provider is marked unavailable.")
+	flag.DurationVar(&providerOpenDuration, "provider-open-duration", time.Minute,
+		"How long a failing metrics provider stays unavailable before it is queried again.")
+	flag.DurationVar(&providerQueryCacheTTL, "provider-query-cache-ttl", time.Second*10,
+		"How long metrics provider query results are reused by canaries running identical queries. 0 only shares queries in flight.")
+	flag.StringVar(&grafanaURL, "grafana-url", "", "The URL of a Grafana instance to write rollout annotations to.")
+	flag.StringVar(&grafanaToken, "grafana-token", os.Getenv("GRAFANA_TOKEN"), "The Grafana API token used for annotations.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		"The OTLP/HTTP endpoint rollout events are exported to as OpenTelemetry logs, e.g. http://otel-collector:4318.")
+	flag.StringVar(&otlpHeaders, "otlp-headers", os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"),
+		"Headers sent with OTLP exports, as key1=value1,key2=value2.")
+	flag.BoolVar(&otlpTracing, "otlp-tracing", false,
+		"Also export reconcile, analysis and route update spans to the OTLP endpoint.")
+	flag.StringVar(&slackWebhookURL, "slack-webhook-url", os.Getenv("SLACK_WEBHOOK_URL"),
+		"A Slack incoming webhook URL notified of every rollout.")
+	flag.StringVar(&teamsWebhookURL, "teams-webhook-url", os.Getenv("TEAMS_WEBHOOK_URL"),
+		"A Microsoft Teams incoming webhook URL notified of every rollout.")
+	flag.StringVar(&notificationWebhookURL, "notification-webhook-url", os.Getenv("NOTIFICATION_WEBHOOK_URL"),
+		"An HTTP endpoint that receives every rollout notification as JSON.")
+	flag.StringVar(&scmProvider, "scm-provider", "",
+		"Report the rollouts of canaries annotated with gateway-cd.io/scm-repository to github (Deployments) or gitlab (environments).")
+	flag.StringVar(&scmURL, "scm-url", "", "The API URL of the SCM, for GitHub Enterprise or self-managed GitLab. Defaults to the public API.")
+	flag.StringVar(&scmToken, "scm-token", os.Getenv("SCM_TOKEN"), "The token used to post deployment statuses to the SCM.")
+	flag.StringVar(&quotaConfigMap, "quota-configmap", "",
+		"namespace/name of the ConfigMap holding the per-team quotas. Rollouts of teams at their concurrent rollout quota are queued.")
+	flag.DurationVar(&retryPolicy.BaseDelay, "retry-base-delay", retry.DefaultPolicy.BaseDelay,
+		"The delay before the first retry of a failed route update, doubled on every further retry.")
+	flag.DurationVar(&retryPolicy.MaxDelay, "retry-max-delay", retry.DefaultPolicy.MaxDelay,
+		"The longest delay between retries of a failed route update.")
+	flag.IntVar(&maxRetries, "max-retries", int(retry.DefaultPolicy.MaxRetries),
+		"Retries of a failed route update before the rollout is rolled back. 0 retries forever.")
+	flag.DurationVar(&driftCheckInterval, "drift-check-interval", time.Minute,
+		"How often the routes of active rollouts are checked for weights changed outside the rollout. 0 only checks on route events.")
+	flag.BoolVar(&autoCanary, "auto-canary", false,
+		"Generate a CanaryDeployment for every Deployment annotated with gateway-cd.io/enabled: \"true\", from its gateway-cd.io/route and gateway-cd.io/steps annotations.")
+	flag.DurationVar(&requeue.Active, "requeue-interval", controller.DefaultRequeueIntervals.Active,
+		"How soon a rollout that just changed state is reconciled again. Canaries override it with spec.interval.")
+	flag.DurationVar(&requeue.Poll, "poll-interval", controller.DefaultRequeueIntervals.Poll,
+		"How often the hooks, jobs and analysis of a rollout are checked. Canaries override it with spec.interval.")
+	flag.DurationVar(&requeue.Idle, "idle-requeue-interval", controller.DefaultRequeueIntervals.Idle,
+		"How soon a rollout that is blocked, queued or failed to reconcile is retried.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the admission webhooks for CanaryDeployments and Approvals and the CanaryDeployment conversion webhook.")
+	flag.StringVar(&trustedRequesters, "trusted-requesters", "",
+		"Comma-separated users, e.g. the service account of an API server requiring --auth, whose control actions the admission webhook admits as the API caller they record in gateway-cd.io/requested-by.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the admission webhook server binds to.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "", "The directory containing the webhook TLS certificate (tls.crt/tls.key).")
+	flag.StringVar(&apiAddr, "api-bind-address", "",
+		"The address the REST API binds to when the manager serves it, replacing the api-server deployment. Empty disables it.")
+	flag.StringVar(&apiGRPCAddr, "api-grpc-bind-address", "",
+		"The address the gRPC admin API binds to when the manager serves the API. Empty disables it.")
+	flag.BoolVar(&apiLeaderElection, "api-leader-elect", false,
+		"Serve the API only on the elected leader; other replicas report not ready.")
+	apiFlags.BindFlags(flag.CommandLine)
 
 	opts := zap.Options{
 		Development: true,
@@ -50,37 +163,172 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	scope, err := gatewaycd.ParseScope(watchNamespaces, labelSelector)
+	if err != nil {
+		setupLog.Error(err, "invalid --watch-namespaces or --label-selector")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "gateway-cd-controller",
+		LeaderElectionID:       leaderElectionID,
+		Cache:                  scope.CacheOptions(),
+		WebhookServer: crwebhook.NewServer(crwebhook.Options{
+			Port:    webhookPort,
+			CertDir: webhookCertDir,
+		}),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	// Initialize Gateway Manager
-	gatewayManager := gateway.NewManager(mgr.GetClient())
-
 	// Initialize Metrics Provider
-	var metricsProvider metrics.Provider
+	breakerOpts := metrics.CircuitBreakerOptions{
+		FailureThreshold: providerFailureThreshold,
+		OpenDuration:     providerOpenDuration,
+	}
+	var providers []metrics.Provider
 	if prometheusURL != "" {
-		metricsProvider = metrics.NewPrometheusProvider(prometheusURL)
+		backend, err := metrics.ParseBackend(prometheusBackend)
+		if err != nil {
+			setupLog.Error(err, "invalid --prometheus-backend")
+			os.Exit(1)
+		}
+		compat := metrics.Compatibility{
+			Backend:       backend,
+			TenantID:      prometheusTenantID,
+			LookbackDelta: prometheusLookbackDelta,
+		}
+		provider := metrics.CacheQueries(metrics.NewPrometheusCompatibleProvider(prometheusURL, compat),
+			metrics.NewQueryCache("prometheus", providerQueryCacheTTL))
+		providers = append(providers, metrics.NewInstrumentedProvider("prometheus", provider, breakerOpts))
+	} else if cloudMonitoringProject != "" {
+		provider := metrics.CacheQueries(metrics.NewCloudMonitoringProvider(metrics.DefaultCloudMonitoringAddress, cloudMonitoringProject),
+			metrics.NewQueryCache("cloudmonitoring", providerQueryCacheTTL))
+		providers = append(providers, metrics.NewInstrumentedProvider("cloudmonitoring", provider, breakerOpts))
+	}
+	if tempoURL != "" {
+		providers = append(providers, metrics.NewInstrumentedProvider("tempo", metrics.NewTempoProvider(tempoURL), breakerOpts))
+	} else if jaegerURL != "" {
+		providers = append(providers, metrics.NewInstrumentedProvider("jaeger", metrics.NewJaegerProvider(jaegerURL), breakerOpts))
+	}
+
+	var metricsProvider metrics.Provider
+	switch len(providers) {
+	case 0:
+	case 1:
+		metricsProvider = providers[0]
+	default:
+		metricsProvider = metrics.NewCompositeProvider(providers...)
+	}
+
+	// Initialize Grafana annotations
+	var annotator *grafana.Annotator
+	if grafanaURL != "" {
+		annotator = grafana.NewAnnotator(grafanaURL, grafanaToken)
 	}
 
-	// Setup CanaryDeployment controller
-	if err = (&controller.CanaryDeploymentReconciler{
-		Client:          mgr.GetClient(),
-		Scheme:          mgr.GetScheme(),
-		GatewayManager:  gatewayManager,
-		MetricsProvider: metricsProvider,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "CanaryDeployment")
+	// Initialize the OpenTelemetry rollout event timeline
+	var timeline *otlp.Exporter
+	if otlpEndpoint != "" {
+		timeline = otlp.NewExporter(otlpEndpoint, otlp.ParseHeaders(otlpHeaders))
+	}
+	var tracer *otlp.Tracer
+	if otlpEndpoint != "" && otlpTracing {
+		tracer = otlp.NewTracer(otlpEndpoint, otlp.ParseHeaders(otlpHeaders))
+	}
+
+	// Initialize controller-wide notification channels
+	var notificationChannels []notifications.Channel
+	for channelType, url := range map[gatewaycdv1alpha1.NotificationChannelType]string{
+		gatewaycdv1alpha1.NotificationChannelSlack:   slackWebhookURL,
+		gatewaycdv1alpha1.NotificationChannelTeams:   teamsWebhookURL,
+		gatewaycdv1alpha1.NotificationChannelWebhook: notificationWebhookURL,
+	} {
+		if url == "" {
+			continue
+		}
+		channel, err := notifications.NewChannel(channelType, url)
+		if err != nil {
+			setupLog.Error(err, "unable to set up notification channel")
+			os.Exit(1)
+		}
+		notificationChannels = append(notificationChannels, channel)
+	}
+
+	// Initialize deployment status reporting to GitHub or GitLab
+	var scmReporter *scm.Reporter
+	if scmProvider != "" {
+		provider, err := scm.NewProvider(scmProvider, scmURL, scmToken)
+		if err != nil {
+			setupLog.Error(err, "unable to set up SCM integration")
+			os.Exit(1)
+		}
+		scmReporter = scm.NewReporter(provider, ctrl.Log.WithName("scm"))
+	}
+
+	retryPolicy.Jitter = retry.DefaultPolicy.Jitter
+	retryPolicy.MaxRetries = int32(maxRetries)
+
+	// Initialize per-team rollout quotas
+	var quotas *quota.Checker
+	if quotaConfigMap != "" {
+		configMap, err := quota.ParseConfigMap(quotaConfigMap)
+		if err != nil {
+			setupLog.Error(err, "invalid --quota-configmap")
+			os.Exit(1)
+		}
+		quotas = quota.NewChecker(configMap)
+	}
+
+	// Setup the rollout engine: CanaryDeployment controller, per-namespace
+	// rollout statistics and, if enabled, admission webhooks
+	if _, err = gatewaycd.AddToManager(mgr, gatewaycd.Options{
+		MetricsProvider:        metricsProvider,
+		ProviderCircuitBreaker: breakerOpts,
+		ProviderQueryCacheTTL:  providerQueryCacheTTL,
+		Annotator:              annotator,
+		Timeline:               timeline,
+		Tracer:                 tracer,
+		NotificationChannels:   notificationChannels,
+		SCM:                    scmReporter,
+		EnableWebhooks:         enableWebhooks,
+		TrustedRequesters:      strings.FieldsFunc(trustedRequesters, func(r rune) bool { return r == ',' }),
+		Quotas:                 quotas,
+		Retry:                  retryPolicy,
+		Requeue:                requeue,
+		DriftCheckInterval:     driftCheckInterval,
+		AutoCanary:             autoCanary,
+		AutoCanarySelector:     scope.CanarySelector,
+	}); err != nil {
+		setupLog.Error(err, "unable to set up rollout engine")
 		os.Exit(1)
 	}
 
+	// Serve the REST and gRPC APIs from the manager, sharing its cache
+	if apiAddr != "" {
+		serverOpts, err := apiFlags.Options(mgr.GetClient(), scheme)
+		if err != nil {
+			setupLog.Error(err, "invalid API server flags")
+			os.Exit(1)
+		}
+		if quotas != nil {
+			serverOpts = append(serverOpts, api.WithQuotas(quotas))
+		}
+		if _, err := api.AddToManager(mgr, api.ManagerOptions{
+			Addr:           apiAddr,
+			GRPCAddr:       apiGRPCAddr,
+			LeaderElection: apiLeaderElection,
+			ServerOptions:  serverOpts,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up API server")
+			os.Exit(1)
+		}
+	}
+
 	// Add health checks
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -0,0 +1,44 @@
+-- Argo CD health check of gateway-cd.io/CanaryDeployment. The controller
+-- maps the rollout to status.health; canaries written by an older controller
+-- fall back to status.phase.
+local hs = {}
+hs.status = "Progressing"
+hs.message = "Waiting for the rollout to start"
+
+if obj.status == nil then
+  return hs
+end
+
+if obj.metadata.generation ~= nil and obj.status.observedGeneration ~= nil and
+    obj.status.observedGeneration < obj.metadata.generation then
+  hs.message = "Waiting for the controller to observe the latest spec"
+  return hs
+end
+
+if obj.status.message ~= nil then
+  hs.message = obj.status.message
+end
+
+local health = obj.status.health
+if health == nil then
+  local phases = {
+    Succeeded = "Healthy",
+    Failed = "Degraded",
+    Paused = "Suspended",
+  }
+  health = phases[obj.status.phase] or "Progressing"
+end
+
+if obj.status.conditions ~= nil then
+  for _, condition in ipairs(obj.status.conditions) do
+    if (health == "Degraded" and condition.type == "Stalled" and condition.status == "True") or
+        (health == "Progressing" and condition.type == "Reconciling" and condition.status == "True") then
+      if condition.message ~= nil and condition.message ~= "" then
+        hs.message = condition.message
+      end
+    end
+  end
+end
+
+hs.status = health
+return hs
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// userKey is the gin context key of the authenticated caller
+const userKey = "gateway-cd.io/user"
+
+var errInvalidToken = errors.New("invalid bearer token")
+
+// Authenticator resolves the identity behind a bearer token
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error)
+}
+
+// TokenReviewAuthenticator authenticates tokens with a Kubernetes TokenReview,
+// which accepts service account tokens and any token the cluster trusts, e.g.
+// OIDC tokens of the cluster's identity provider
+type TokenReviewAuthenticator struct {
+	client    client.Client
+	audiences []string
+}
+
+// NewTokenReviewAuthenticator creates an authenticator reviewing tokens in the
+// cluster of c, optionally requiring one of audiences
+func NewTokenReviewAuthenticator(c client.Client, audiences ...string) *TokenReviewAuthenticator {
+	return &TokenReviewAuthenticator{client: c, audiences: audiences}
+}
+
+// Authenticate returns the user the cluster authenticates token as
+func (a *TokenReviewAuthenticator) Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: a.audiences},
+	}
+	if err := a.client.Create(ctx, review); err != nil {
+		return nil, fmt.Errorf("failed to review token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return nil, errInvalidToken
+	}
+	return &review.Status.User, nil
+}
+
+// WithAuthenticator requires a bearer token on API routes and authorizes the
+// caller with a SubjectAccessReview on canarydeployments in each cluster the
+// request addresses
+func WithAuthenticator(authenticator Authenticator) Option {
+	return func(s *Server) {
+		s.authenticator = authenticator
+	}
+}
+
+// WithCORSOrigins sets the origins allowed to call the API from a browser.
+// "*" allows any origin.
+func WithCORSOrigins(origins ...string) Option {
+	return func(s *Server) {
+		s.corsOrigins = origins
+	}
+}
+
+// cors answers preflight requests and allows the configured origins
+func (s *Server) cors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if origin := c.GetHeader("Origin"); origin != "" {
+			c.Header("Vary", "Origin")
+			for _, allowed := range s.corsOrigins {
+				if allowed == "*" || allowed == origin {
+					c.Header("Access-Control-Allow-Origin", allowed)
+					c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+					c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, If-None-Match, "+RequestIDHeader)
+					c.Header("Access-Control-Expose-Headers", "ETag, "+RequestIDHeader+", "+UnreachableClustersHeader)
+					break
+				}
+			}
+		}
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// authorize authenticates the caller and checks that it may perform verb on
+// the canary deployments addressed by the request. Without an authenticator
+// every request is allowed.
+func (s *Server) authorize(verb string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.authenticator == nil {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
+			return
+		}
+		user, err := s.authenticator.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid bearer token"})
+			return
+		}
+
+		namespace, err := requestNamespace(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		attributes := &authorizationv1.ResourceAttributes{
+			Namespace: namespace,
+			Verb:      verb,
+			Group:     gatewaycdv1alpha1.GroupVersion.Group,
+			Resource:  "canarydeployments",
+			Name:      c.Param("name"),
+		}
+		names, ok := s.reviewedClusters(verb, c.Query("cluster"))
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Unknown cluster %q", c.Query("cluster"))})
+			return
+		}
+		ctx, allowed, err := s.authorizeClusters(c.Request.Context(), user, attributes, names)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("User %q cannot %s canarydeployments in namespace %q",
+				user.Username, verb, namespace)})
+			return
+		}
+
+		c.Set(userKey, user)
+		c.Request = c.Request.WithContext(withCaller(ctx, user))
+		c.Next()
+	}
+}
+
+// clusterAccessKey is the context key of the clusterAccess of an authorized caller
+type clusterAccessKey struct{}
+
+// clusterAccess is the outcome of the access reviews of a caller in the
+// clusters a request addresses
+type clusterAccess struct {
+	allowed map[string]bool
+	// failed holds the clusters whose review failed
+	failed map[string]bool
+}
+
+// clusterAllowed reports whether the caller of ctx was authorized in cluster
+// name. Requests that were not authorized, e.g. without an authenticator or
+// from inbound webhooks, may access every cluster.
+func clusterAllowed(ctx context.Context, name string) bool {
+	access, ok := ctx.Value(clusterAccessKey{}).(*clusterAccess)
+	return !ok || access.allowed[name]
+}
+
+// clusterReviewFailed reports whether the access review of the caller of ctx
+// failed in cluster name, e.g. because the cluster is unreachable
+func clusterReviewFailed(ctx context.Context, name string) bool {
+	access, ok := ctx.Value(clusterAccessKey{}).(*clusterAccess)
+	return ok && access.failed[name]
+}
+
+// reviewedClusters returns the clusters whose access is reviewed for verb:
+// the selected cluster, defaulting to the server's own, or every cluster for
+// the list and watch requests that fan out. It reports false if the selected
+// cluster is unknown.
+func (s *Server) reviewedClusters(verb, selected string) ([]string, bool) {
+	if selected == "" {
+		if verb == "list" || verb == "watch" {
+			return s.clusterNames(), true
+		}
+		selected = s.clusterName
+	}
+	if _, ok := s.clusters[selected]; !ok {
+		return nil, false
+	}
+	return []string{selected}, true
+}
+
+// authorizeClusters reviews the access of user in each named cluster, since
+// the RBAC of one cluster doesn't grant access to the others, and returns
+// ctx carrying the clusters that allow it. It reports whether any cluster
+// does; an error is returned only if none does and a review failed.
+func (s *Server) authorizeClusters(ctx context.Context, user *authenticationv1.UserInfo, attributes *authorizationv1.ResourceAttributes, names []string) (context.Context, bool, error) {
+	access := &clusterAccess{allowed: map[string]bool{}, failed: map[string]bool{}}
+	var mu sync.Mutex
+	var lastErr error
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			reviewCtx, cancel := s.clusterContext(ctx)
+			defer cancel()
+			allowed, err := s.subjectAccessReview(reviewCtx, s.clusters[name], user, attributes)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				access.failed[name] = true
+				lastErr = err
+			}
+			if allowed {
+				access.allowed[name] = true
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	if len(access.allowed) == 0 {
+		return ctx, false, lastErr
+	}
+	return context.WithValue(ctx, clusterAccessKey{}, access), true, nil
+}
+
+// subjectAccessReview asks the cluster of cl whether user may access the resource
+func (s *Server) subjectAccessReview(ctx context.Context, cl client.Client, user *authenticationv1.UserInfo, attributes *authorizationv1.ResourceAttributes) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attributes,
+			User:               user.Username,
+			Groups:             user.Groups,
+			UID:                user.UID,
+			Extra:              extra,
+		},
+	}
+	if err := cl.Create(ctx, review); err != nil {
+		return false, fmt.Errorf("failed to review access: %w", err)
+	}
+	return review.Status.Allowed, nil
+}
+
+// requestNamespace is the namespace a request addresses: the path or query
+// namespace, or the namespace of a created or generated canary
+func requestNamespace(c *gin.Context) (string, error) {
+	if namespace := c.Param("namespace"); namespace != "" {
+		return namespace, nil
+	}
+	if c.Request.Method != http.MethodPost {
+		return c.Query("namespace"), nil
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read request body")
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var object struct {
+		Namespace string `json:"namespace"`
+		Metadata  struct {
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(body, &object); err != nil {
+		return "", err
+	}
+	if object.Metadata.Namespace != "" {
+		return object.Metadata.Namespace, nil
+	}
+	return object.Namespace, nil
+}
+
+// requestedBy is the authenticated caller of a request, or "" if authentication is disabled
+func requestedBy(c *gin.Context) string {
+	if user, ok := c.Get(userKey); ok {
+		return user.(*authenticationv1.UserInfo).Username
+	}
+	return ""
+}
@@ -0,0 +1,482 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// tokenAuthenticator authenticates the tokens it maps to users
+type tokenAuthenticator map[string]*authenticationv1.UserInfo
+
+func (a tokenAuthenticator) Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	if user, ok := a[token]; ok {
+		return user, nil
+	}
+	return nil, errInvalidToken
+}
+
+// accessReviews answers the SubjectAccessReviews and TokenReviews created on
+// the fake client and records the reviewed access
+type accessReviews struct {
+	// allowed lists the "user verb namespace" accesses to allow
+	allowed map[string]bool
+	// tokens maps the tokens a TokenReview authenticates to users
+	tokens map[string]authenticationv1.UserInfo
+	// err fails every SubjectAccessReview when set
+	err error
+
+	mu       sync.Mutex
+	reviewed []authorizationv1.SubjectAccessReviewSpec
+}
+
+func (a *accessReviews) create(ctx context.Context, cl client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+	switch review := obj.(type) {
+	case *authorizationv1.SubjectAccessReview:
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.err != nil {
+			return a.err
+		}
+		a.reviewed = append(a.reviewed, review.Spec)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = a.allowed[review.Spec.User+" "+attributes.Verb+" "+attributes.Namespace]
+		return nil
+	case *authenticationv1.TokenReview:
+		user, ok := a.tokens[review.Spec.Token]
+		review.Status.Authenticated = ok
+		review.Status.User = user
+		return nil
+	}
+	return cl.Create(ctx, obj, opts...)
+}
+
+// newTestClient returns a fake client builder holding objs
+func newTestClient(t *testing.T, objs ...client.Object) *fake.ClientBuilder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := gatewaycdv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...)
+}
+
+// newAuthTestServer returns a server on a fake client holding a canary, whose
+// access reviews are answered by reviews
+func newAuthTestServer(t *testing.T, reviews *accessReviews, authenticator func(client.Client) Authenticator) *Server {
+	t.Helper()
+	canary := &gatewaycdv1alpha1.CanaryDeployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout"},
+	}
+	cl := newTestClient(t, canary).WithInterceptorFuncs(interceptor.Funcs{Create: reviews.create}).Build()
+	return NewServer(cl, WithAuthenticator(authenticator(cl)))
+}
+
+func TestAuthorize(t *testing.T) {
+	alice := &authenticationv1.UserInfo{Username: "alice", UID: "1", Groups: []string{"devs"},
+		Extra: map[string]authenticationv1.ExtraValue{"scopes": {"deploy"}}}
+	bob := &authenticationv1.UserInfo{Username: "bob"}
+	authenticator := func(client.Client) Authenticator {
+		return tokenAuthenticator{"alice-token": alice, "bob-token": bob}
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		reviewErr     error
+		want          int
+		wantError     string
+		wantReview    string
+	}{
+		{
+			name:      "no token",
+			method:    http.MethodGet,
+			path:      "/api/v1/canaries/shop/checkout",
+			want:      http.StatusUnauthorized,
+			wantError: "Bearer token required",
+		},
+		{
+			name:          "not a bearer token",
+			method:        http.MethodGet,
+			path:          "/api/v1/canaries/shop/checkout",
+			authorization: "Basic YWxpY2U6cHc=",
+			want:          http.StatusUnauthorized,
+			wantError:     "Bearer token required",
+		},
+		{
+			name:          "empty bearer token",
+			method:        http.MethodGet,
+			path:          "/api/v1/canaries/shop/checkout",
+			authorization: "Bearer ",
+			want:          http.StatusUnauthorized,
+			wantError:     "Bearer token required",
+		},
+		{
+			name:          "invalid token",
+			method:        http.MethodGet,
+			path:          "/api/v1/canaries/shop/checkout",
+			authorization: "Bearer mallory-token",
+			want:          http.StatusUnauthorized,
+			wantError:     "Invalid bearer token",
+		},
+		{
+			name:          "allowed",
+			method:        http.MethodGet,
+			path:          "/api/v1/canaries/shop/checkout",
+			authorization: "Bearer alice-token",
+			want:          http.StatusOK,
+			wantReview:    "alice get shop",
+		},
+		{
+			name:          "denied",
+			method:        http.MethodGet,
+			path:          "/api/v1/canaries/shop/checkout",
+			authorization: "Bearer bob-token",
+			want:          http.StatusForbidden,
+			wantError:     `User \"bob\" cannot get canarydeployments in namespace \"shop\"`,
+			wantReview:    "bob get shop",
+		},
+		{
+			name:          "denied for the verb of the route",
+			method:        http.MethodPost,
+			path:          "/api/v1/canaries/shop/checkout/abort",
+			authorization: "Bearer alice-token",
+			want:          http.StatusForbidden,
+			wantError:     `User \"alice\" cannot patch canarydeployments in namespace \"shop\"`,
+			wantReview:    "alice patch shop",
+		},
+		{
+			name:          "denied in the namespace of the query",
+			method:        http.MethodGet,
+			path:          "/api/v1/canaries?namespace=payments",
+			authorization: "Bearer alice-token",
+			want:          http.StatusForbidden,
+			wantReview:    "alice list payments",
+		},
+		{
+			name:          "review failed",
+			method:        http.MethodGet,
+			path:          "/api/v1/canaries/shop/checkout",
+			authorization: "Bearer alice-token",
+			reviewErr:     errors.New("apiserver unavailable"),
+			want:          http.StatusInternalServerError,
+			wantError:     "failed to review access: apiserver unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reviews := &accessReviews{
+				allowed: map[string]bool{"alice get shop": true, "alice list shop": true},
+				err:     tt.reviewErr,
+			}
+			s := newAuthTestServer(t, reviews, authenticator)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantError) {
+				t.Errorf("body = %s, want error %q", rec.Body.String(), tt.wantError)
+			}
+			if tt.wantReview == "" {
+				if tt.reviewErr == nil && len(reviews.reviewed) > 0 {
+					t.Errorf("reviewed access %+v, want no review", reviews.reviewed)
+				}
+				return
+			}
+			if len(reviews.reviewed) != 1 {
+				t.Fatalf("got %d access reviews, want 1", len(reviews.reviewed))
+			}
+			spec := reviews.reviewed[0]
+			attributes := spec.ResourceAttributes
+			if got := spec.User + " " + attributes.Verb + " " + attributes.Namespace; got != tt.wantReview {
+				t.Errorf("reviewed %q, want %q", got, tt.wantReview)
+			}
+			if attributes.Group != gatewaycdv1alpha1.GroupVersion.Group || attributes.Resource != "canarydeployments" {
+				t.Errorf("reviewed resource %s/%s, want %s/canarydeployments", attributes.Group, attributes.Resource,
+					gatewaycdv1alpha1.GroupVersion.Group)
+			}
+		})
+	}
+}
+
+func TestAuthorizeReviewsUser(t *testing.T) {
+	alice := &authenticationv1.UserInfo{Username: "alice", UID: "1", Groups: []string{"devs"},
+		Extra: map[string]authenticationv1.ExtraValue{"scopes": {"deploy"}}}
+	reviews := &accessReviews{allowed: map[string]bool{"alice get shop": true}}
+	s := newAuthTestServer(t, reviews, func(client.Client) Authenticator {
+		return tokenAuthenticator{"alice-token": alice}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/canaries/shop/checkout", nil)
+	req.Header.Set("Authorization", "Bearer alice-token")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	spec := reviews.reviewed[0]
+	if spec.UID != "1" || len(spec.Groups) != 1 || spec.Groups[0] != "devs" {
+		t.Errorf("reviewed uid %q and groups %v, want 1 and [devs]", spec.UID, spec.Groups)
+	}
+	if scopes := spec.Extra["scopes"]; len(scopes) != 1 || scopes[0] != "deploy" {
+		t.Errorf("reviewed extra %v, want scopes [deploy]", spec.Extra)
+	}
+	if spec.ResourceAttributes.Name != "checkout" {
+		t.Errorf("reviewed name %q, want checkout", spec.ResourceAttributes.Name)
+	}
+}
+
+func TestAuthorizeClusters(t *testing.T) {
+	alice := &authenticationv1.UserInfo{Username: "alice"}
+	newCluster := func(reviews *accessReviews) client.Client {
+		canary := &gatewaycdv1alpha1.CanaryDeployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout"},
+		}
+		return newTestClient(t, canary).WithInterceptorFuncs(interceptor.Funcs{Create: reviews.create}).Build()
+	}
+	// alice may access the canaries of the server's own cluster only
+	east := &accessReviews{allowed: map[string]bool{"alice get shop": true, "alice list shop": true, "alice patch shop": true}}
+	west := &accessReviews{}
+	down := &accessReviews{err: errors.New("apiserver unavailable")}
+	s := NewServer(newCluster(east), WithClusterName("east"),
+		WithClusters(map[string]client.Client{"west": newCluster(west), "north": newCluster(down)}),
+		WithAuthenticator(tokenAuthenticator{"alice-token": alice}))
+
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		want            int
+		wantClusters    []string
+		wantUnreachable string
+	}{
+		{
+			name:   "own cluster",
+			method: http.MethodGet,
+			path:   "/api/v1/canaries/shop/checkout",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "control action in another cluster",
+			method: http.MethodPost,
+			path:   "/api/v1/canaries/shop/checkout/abort?cluster=west",
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "get in another cluster",
+			method: http.MethodGet,
+			path:   "/api/v1/canaries/shop/checkout?cluster=west",
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "unreachable cluster",
+			method: http.MethodGet,
+			path:   "/api/v1/canaries/shop/checkout?cluster=north",
+			want:   http.StatusInternalServerError,
+		},
+		{
+			name:   "unknown cluster",
+			method: http.MethodGet,
+			path:   "/api/v1/canaries/shop/checkout?cluster=south",
+			want:   http.StatusNotFound,
+		},
+		{
+			name:            "list of the allowed clusters",
+			method:          http.MethodGet,
+			path:            "/api/v1/canaries?namespace=shop",
+			want:            http.StatusOK,
+			wantClusters:    []string{"east"},
+			wantUnreachable: "north",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer alice-token")
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if got := rec.Header().Get(UnreachableClustersHeader); got != tt.wantUnreachable {
+				t.Errorf("unreachable clusters = %q, want %q", got, tt.wantUnreachable)
+			}
+			if tt.wantClusters == nil {
+				return
+			}
+			var items []ClusterCanary
+			if err := json.Unmarshal(rec.Body.Bytes(), &items); err != nil {
+				t.Fatal(err)
+			}
+			var clusters []string
+			for _, item := range items {
+				clusters = append(clusters, item.Cluster)
+			}
+			if strings.Join(clusters, ",") != strings.Join(tt.wantClusters, ",") {
+				t.Errorf("listed canaries of clusters %v, want %v", clusters, tt.wantClusters)
+			}
+		})
+	}
+}
+
+func TestTokenReviewAuthenticator(t *testing.T) {
+	reviews := &accessReviews{
+		allowed: map[string]bool{"system:serviceaccount:ci:deployer get shop": true},
+		tokens:  map[string]authenticationv1.UserInfo{"sa-token": {Username: "system:serviceaccount:ci:deployer"}},
+	}
+	s := newAuthTestServer(t, reviews, func(cl client.Client) Authenticator {
+		return NewTokenReviewAuthenticator(cl)
+	})
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"authenticated", "sa-token", http.StatusOK},
+		{"not authenticated", "forged-token", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/canaries/shop/checkout", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestNamespace(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		params  gin.Params
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "path",
+			method: http.MethodGet,
+			target: "/api/v1/canaries/shop/checkout?namespace=other",
+			params: gin.Params{{Key: "namespace", Value: "shop"}, {Key: "name", Value: "checkout"}},
+			want:   "shop",
+		},
+		{
+			name:   "path of a POST",
+			method: http.MethodPost,
+			target: "/api/v1/canaries/shop/checkout/abort",
+			params: gin.Params{{Key: "namespace", Value: "shop"}, {Key: "name", Value: "checkout"}},
+			body:   `{"metadata": {"namespace": "other"}}`,
+			want:   "shop",
+		},
+		{
+			name:   "query",
+			method: http.MethodGet,
+			target: "/api/v1/canaries?namespace=payments",
+			want:   "payments",
+		},
+		{
+			name:   "all namespaces",
+			method: http.MethodGet,
+			target: "/api/v1/canaries",
+			want:   "",
+		},
+		{
+			name:   "created canary",
+			method: http.MethodPost,
+			target: "/api/v1/canaries",
+			body:   `{"metadata": {"name": "checkout", "namespace": "shop"}, "spec": {}}`,
+			want:   "shop",
+		},
+		{
+			name:   "generated canary",
+			method: http.MethodPost,
+			target: "/api/v1/canaries/generate",
+			body:   `{"preset": "web", "namespace": "shop"}`,
+			want:   "shop",
+		},
+		{
+			name:   "metadata before top-level namespace",
+			method: http.MethodPost,
+			target: "/api/v1/canaries",
+			body:   `{"namespace": "other", "metadata": {"namespace": "shop"}}`,
+			want:   "shop",
+		},
+		{
+			name:   "POST without a namespace",
+			method: http.MethodPost,
+			target: "/api/v1/canaries",
+			body:   `{"metadata": {"name": "checkout"}}`,
+			want:   "",
+		},
+		{
+			name:    "invalid body",
+			method:  http.MethodPost,
+			target:  "/api/v1/canaries",
+			body:    `{"metadata": `,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			c.Params = tt.params
+
+			got, err := requestNamespace(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("requestNamespace() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("requestNamespace() = %q, want %q", got, tt.want)
+			}
+
+			// The handler still reads the whole body
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body left for the handler = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// PhaseIndex is the field index of canary deployments by status.phase. Lists
+// by namespace use the namespace index every cache maintains.
+const PhaseIndex = "status.phase"
+
+// IndexFields registers the field indexes the API lists canary deployments by
+func IndexFields(ctx context.Context, indexer client.FieldIndexer) error {
+	return indexer.IndexField(ctx, &gatewaycdv1alpha1.CanaryDeployment{}, PhaseIndex, func(obj client.Object) []string {
+		return []string{string(obj.(*gatewaycdv1alpha1.CanaryDeployment).Status.Phase)}
+	})
+}
+
+// WithFieldIndexes tells the server its own cluster's client reads from a
+// cache with the fields of IndexFields indexed, so phase filters are served
+// by the index instead of filtering every canary
+func WithFieldIndexes() Option {
+	return func(s *Server) {
+		s.fieldIndexes = true
+	}
+}
+
+// NewCachedClient creates a client reading canary deployments and the objects
+// they reference from a shared informer cache with the API's field indexes,
+// so list-heavy dashboard traffic is served from memory. The cache is synced
+// before it returns and runs until ctx is done. Writes and watches go to the
+// Kubernetes API.
+func NewCachedClient(ctx context.Context, config *rest.Config, scheme *runtime.Scheme) (client.WithWatch, error) {
+	informers, err := cache.New(config, cache.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create informer cache: %w", err)
+	}
+	if err := IndexFields(ctx, informers); err != nil {
+		return nil, fmt.Errorf("failed to index canary deployments: %w", err)
+	}
+	if _, err := informers.GetInformer(ctx, &gatewaycdv1alpha1.CanaryDeployment{}); err != nil {
+		return nil, fmt.Errorf("failed to start canary deployment informer: %w", err)
+	}
+
+	go func() {
+		if err := informers.Start(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "Informer cache stopped")
+		}
+	}()
+	if !informers.WaitForCacheSync(ctx) {
+		return nil, fmt.Errorf("failed to sync informer cache")
+	}
+	return cachedClient(config, scheme, nil, informers)
+}
+
+// cachedClient creates a client reading from reader, except for Secrets,
+// ConfigMaps and CRDs, which are read live so serving the API starts no
+// cluster-wide informers for them
+func cachedClient(config *rest.Config, scheme *runtime.Scheme, mapper meta.RESTMapper, reader client.Reader) (client.WithWatch, error) {
+	return client.NewWithWatch(config, client.Options{
+		Scheme: scheme,
+		Mapper: mapper,
+		Cache: &client.CacheOptions{
+			Reader: reader,
+			DisableFor: []client.Object{
+				&corev1.Secret{},
+				&corev1.ConfigMap{},
+				&apiextensionsv1.CustomResourceDefinition{},
+			},
+		},
+	})
+}
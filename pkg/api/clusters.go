@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/stats"
+)
+
+const (
+	// DefaultClusterName names the cluster of the client passed to NewServer
+	DefaultClusterName = "default"
+
+	// UnreachableClustersHeader lists the clusters a fan-out request could not reach
+	UnreachableClustersHeader = "X-Gateway-CD-Unreachable-Clusters"
+)
+
+// ClusterCanary is a canary deployment together with the cluster it runs in
+type ClusterCanary struct {
+	Cluster string `json:"cluster"`
+	gatewaycdv1alpha1.CanaryDeployment
+}
+
+// ClusterNamespaceSummary is a namespace rollout summary together with its cluster
+type ClusterNamespaceSummary struct {
+	Cluster string `json:"cluster"`
+	stats.NamespaceSummary
+}
+
+// WithClusterName names the cluster of the client passed to NewServer
+func WithClusterName(name string) Option {
+	return func(s *Server) {
+		s.clusterName = name
+	}
+}
+
+// WithClusters adds clusters the server fans out to, keyed by cluster name.
+// Lists aggregate all clusters and single-canary requests select one with
+// the cluster query parameter.
+func WithClusters(clients map[string]client.Client) Option {
+	return func(s *Server) {
+		for name, c := range clients {
+			s.clusters[name] = c
+		}
+	}
+}
+
+// clusterNames returns the configured cluster names in order
+func (s *Server) clusterNames() []string {
+	names := make([]string, 0, len(s.clusters))
+	for name := range s.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// multiCluster reports whether the server fans out to more than one cluster
+func (s *Server) multiCluster() bool {
+	return len(s.clusters) > 1
+}
+
+// cluster resolves a cluster name, defaulting to the server's own cluster,
+// and writes a 404 response if it is unknown
+func (s *Server) cluster(c *gin.Context, name string) (string, client.Client, bool) {
+	if name == "" {
+		name = s.clusterName
+	}
+	if _, ok := s.clusters[name]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Unknown cluster %q", name)})
+		return "", nil, false
+	}
+	if !clusterAllowed(c.Request.Context(), name) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Access to cluster %q not allowed", name)})
+		return "", nil, false
+	}
+	return name, s.clusterClient(c.Request.Context(), name), true
+}
+
+// requestCluster resolves the cluster selected by the cluster query parameter
+func (s *Server) requestCluster(c *gin.Context) (string, client.Client, bool) {
+	return s.cluster(c, c.Query("cluster"))
+}
+
+// fanOut runs fn against the cluster named by the cluster query parameter, or
+// every cluster if it is empty, skipping those the caller may not access,
+// and returns their results in cluster order. The clusters are queried
+// concurrently, each within the ClusterTimeout of the server's limits.
+// Clusters that fail or time out are listed in the UnreachableClustersHeader;
+// an error is returned only if every queried cluster failed.
+func fanOut[T any](s *Server, c *gin.Context, fn func(ctx context.Context, name string, cl client.Client) ([]T, error)) ([]T, error) {
+	ctx := c.Request.Context()
+	names := s.clusterNames()
+	if selected := c.Query("cluster"); selected != "" {
+		if _, ok := s.clusters[selected]; !ok {
+			return nil, fmt.Errorf("unknown cluster %q", selected)
+		}
+		names = []string{selected}
+	}
+
+	results := make([][]T, len(names))
+	errs := make([]error, len(names))
+	queried := make([]bool, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		if !clusterAllowed(ctx, name) {
+			continue
+		}
+		queried[i] = true
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			clusterCtx, cancel := s.clusterContext(ctx)
+			defer cancel()
+			results[i], errs[i] = fn(clusterCtx, name, s.clusterClient(ctx, name))
+		}(i, name)
+	}
+	wg.Wait()
+
+	var items []T
+	var unreachable []string
+	var lastErr error
+	allFailed := true
+	for i, name := range names {
+		switch {
+		case !queried[i]:
+			// Clusters whose access review failed count as unreachable
+			if clusterReviewFailed(ctx, name) {
+				unreachable = append(unreachable, name)
+			}
+		case errs[i] != nil:
+			unreachable = append(unreachable, name)
+			lastErr = errs[i]
+		default:
+			allFailed = false
+			items = append(items, results[i]...)
+		}
+	}
+	if allFailed && lastErr != nil {
+		return nil, lastErr
+	}
+	if len(unreachable) > 0 {
+		c.Header(UnreachableClustersHeader, strings.Join(unreachable, ","))
+	}
+	return items, nil
+}
+
+// clusterContext bounds a call to one cluster of a fan-out by the
+// ClusterTimeout of the server's limits
+func (s *Server) clusterContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.limits.ClusterTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.limits.ClusterTimeout)
+}
+
+// canaryKey identifies a canary in bulk responses, prefixed with its cluster
+// when the server fans out to several clusters
+func (s *Server) canaryKey(cluster string, canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	if s.multiCluster() {
+		return fmt.Sprintf("%s/%s/%s", cluster, canary.Namespace, canary.Name)
+	}
+	return fmt.Sprintf("%s/%s", canary.Namespace, canary.Name)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// newClusterClient returns a client of a cluster holding one canary, whose
+// lists are answered by list when set
+func newClusterClient(t *testing.T, list func(ctx context.Context) error) client.Client {
+	t.Helper()
+	canary := &gatewaycdv1alpha1.CanaryDeployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout"},
+	}
+	builder := newTestClient(t, canary)
+	if list != nil {
+		builder = builder.WithInterceptorFuncs(interceptor.Funcs{
+			List: func(ctx context.Context, cl client.WithWatch, obj client.ObjectList, opts ...client.ListOption) error {
+				if err := list(ctx); err != nil {
+					return err
+				}
+				return cl.List(ctx, obj, opts...)
+			},
+		})
+	}
+	return builder.Build()
+}
+
+func TestFanOut(t *testing.T) {
+	// hang blocks a list until the cluster times out
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	fail := func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}
+	// together blocks a list until both clusters calling it list at once,
+	// which only happens when they are queried concurrently
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	together := func(ctx context.Context) error {
+		arrived.Done()
+		done := make(chan struct{})
+		go func() {
+			arrived.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	tests := []struct {
+		name            string
+		clusters        map[string]func(ctx context.Context) error
+		path            string
+		want            int
+		wantClusters    []string
+		wantUnreachable string
+	}{
+		{
+			name:         "every cluster in cluster order",
+			clusters:     map[string]func(ctx context.Context) error{"east": nil, "west": nil, "north": nil},
+			path:         "/api/v1/canaries",
+			want:         http.StatusOK,
+			wantClusters: []string{"east", "north", "west"},
+		},
+		{
+			name:         "selected cluster",
+			clusters:     map[string]func(ctx context.Context) error{"east": nil, "west": nil},
+			path:         "/api/v1/canaries?cluster=west",
+			want:         http.StatusOK,
+			wantClusters: []string{"west"},
+		},
+		{
+			name:            "timed out and failed clusters",
+			clusters:        map[string]func(ctx context.Context) error{"east": nil, "west": hang, "north": fail},
+			path:            "/api/v1/canaries",
+			want:            http.StatusOK,
+			wantClusters:    []string{"east"},
+			wantUnreachable: "north,west",
+		},
+		{
+			name:     "every cluster failed",
+			clusters: map[string]func(ctx context.Context) error{"east": fail, "west": hang},
+			path:     "/api/v1/canaries",
+			want:     http.StatusInternalServerError,
+		},
+		{
+			name:         "clusters queried concurrently",
+			clusters:     map[string]func(ctx context.Context) error{"east": together, "west": together},
+			path:         "/api/v1/canaries",
+			want:         http.StatusOK,
+			wantClusters: []string{"east", "west"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusters := map[string]client.Client{}
+			for name, list := range tt.clusters {
+				clusters[name] = newClusterClient(t, list)
+			}
+			s := NewServer(clusters["east"], WithClusterName("east"), WithClusters(clusters),
+				WithLimits(Limits{RequestTimeout: 5 * time.Second, ClusterTimeout: 100 * time.Millisecond}))
+
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if got := rec.Header().Get(UnreachableClustersHeader); got != tt.wantUnreachable {
+				t.Errorf("unreachable clusters = %q, want %q", got, tt.wantUnreachable)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var items []ClusterCanary
+			if err := json.Unmarshal(rec.Body.Bytes(), &items); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, item := range items {
+				got = append(got, item.Cluster)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantClusters, ",") {
+				t.Errorf("listed canaries of clusters %v, want %v", got, tt.wantClusters)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DashboardPath is where the embedded dashboard is served
+const DashboardPath = "/ui"
+
+// dashboardFiles are the static assets of the embedded dashboard. They call
+// the REST API from the browser, with the bearer token the user enters.
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// WithDashboard serves the embedded dashboard under DashboardPath and
+// redirects / to it
+func WithDashboard() Option {
+	return func(s *Server) {
+		s.dashboard = true
+	}
+}
+
+// serveDashboard registers the routes of the embedded dashboard
+func (s *Server) serveDashboard() {
+	assets, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		// The directory is embedded at build time
+		panic(err)
+	}
+	s.router.StaticFS(DashboardPath, http.FS(assets))
+	s.router.GET("/", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, DashboardPath+"/")
+	})
+}
@@ -0,0 +1,299 @@
+// The embedded gateway-cd dashboard. It polls the REST API of the server
+// serving it and keeps the samples of the selected canary in memory to plot
+// its weight and analysis over time.
+(function () {
+  "use strict";
+
+  var API = "../api/v1";
+  var LIST_INTERVAL = 5000;
+  var DETAIL_INTERVAL = 2000;
+  var MAX_SAMPLES = 300;
+
+  var state = {
+    token: localStorage.getItem("gateway-cd.token") || "",
+    namespace: localStorage.getItem("gateway-cd.namespace") || "",
+    canaries: [],
+    selected: null,
+    status: null,
+    weights: [],
+    runs: {},
+  };
+
+  var $ = function (id) { return document.getElementById(id); };
+
+  function request(method, path, body) {
+    var headers = { "Accept": "application/json" };
+    if (state.token) {
+      headers["Authorization"] = "Bearer " + state.token;
+    }
+    if (body !== undefined) {
+      headers["Content-Type"] = "application/json";
+    }
+    return fetch(API + path, {
+      method: method,
+      headers: headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    }).then(function (response) {
+      return response.json().catch(function () { return {}; }).then(function (data) {
+        if (!response.ok) {
+          throw new Error(data.error || response.status + " " + response.statusText);
+        }
+        return data;
+      });
+    });
+  }
+
+  function key(canary) {
+    return canary.cluster + "/" + canary.metadata.namespace + "/" + canary.metadata.name;
+  }
+
+  function canaryPath(canary) {
+    return "/canaries/" + encodeURIComponent(canary.metadata.namespace) + "/" +
+      encodeURIComponent(canary.metadata.name);
+  }
+
+  function clusterQuery(canary) {
+    return "?cluster=" + encodeURIComponent(canary.cluster);
+  }
+
+  function showError(id, err) {
+    $(id).textContent = err ? err.message : "";
+    $(id).hidden = !err;
+  }
+
+  function cell(row, text, className) {
+    var td = document.createElement("td");
+    td.textContent = text === undefined || text === null ? "" : text;
+    if (className) {
+      td.className = className;
+    }
+    row.appendChild(td);
+    return td;
+  }
+
+  // The list
+
+  function loadCanaries() {
+    var query = state.namespace ? "?namespace=" + encodeURIComponent(state.namespace) : "";
+    return request("GET", "/canaries" + query).then(function (canaries) {
+      state.canaries = canaries;
+      showError("list-error", null);
+      renderList();
+    }).catch(function (err) {
+      showError("list-error", err);
+    });
+  }
+
+  function renderList() {
+    var body = $("canaries");
+    body.textContent = "";
+    state.canaries.forEach(function (canary) {
+      var status = canary.status || {};
+      var steps = (canary.spec.trafficSplit || []).length;
+      var row = document.createElement("tr");
+      if (state.selected && key(canary) === key(state.selected)) {
+        row.className = "selected";
+      }
+      cell(row, canary.metadata.namespace + "/" + canary.metadata.name);
+      cell(row, canary.cluster);
+      cell(row, status.phase || "Pending", "phase phase-" + (status.phase || "Pending"));
+      var weight = cell(row, "");
+      var bar = document.createElement("span");
+      bar.className = "weight";
+      var fill = document.createElement("span");
+      fill.style.width = (status.canaryWeight || 0) + "%";
+      bar.appendChild(fill);
+      weight.appendChild(bar);
+      weight.appendChild(document.createTextNode((status.canaryWeight || 0) + "%"));
+      cell(row, steps ? Math.min((status.currentStep || 0) + 1, steps) + "/" + steps : "");
+      cell(row, status.message);
+      row.addEventListener("click", function () { select(canary); });
+      body.appendChild(row);
+    });
+    $("empty").hidden = state.canaries.length > 0;
+  }
+
+  // The detail of the selected canary
+
+  function select(canary) {
+    state.selected = canary;
+    state.status = null;
+    state.weights = [];
+    state.runs = {};
+    $("detail").hidden = false;
+    $("detail-name").textContent = canary.metadata.namespace + "/" + canary.metadata.name;
+    renderList();
+    loadStatus();
+  }
+
+  function loadStatus() {
+    var canary = state.selected;
+    if (!canary) {
+      return Promise.resolve();
+    }
+    return request("GET", canaryPath(canary) + "/status" + clusterQuery(canary)).then(function (status) {
+      if (canary !== state.selected) {
+        return;
+      }
+      state.status = status;
+      record(status);
+      showError("detail-error", null);
+      renderDetail();
+    }).catch(function (err) {
+      showError("detail-error", err);
+    });
+  }
+
+  // record adds a weight sample and the analysis runs of the status, keyed by
+  // completion time so runs seen in several polls are kept once
+  function record(status) {
+    state.weights.push({ t: Date.now(), v: status.canaryWeight || 0 });
+    if (state.weights.length > MAX_SAMPLES) {
+      state.weights.shift();
+    }
+    (status.analysisHistory || []).concat(status.analysisRun ? [status.analysisRun] : []).forEach(function (run) {
+      if (run.completedAt) {
+        state.runs[run.completedAt] = run;
+      }
+    });
+  }
+
+  function renderDetail() {
+    var status = state.status;
+    var phase = status.phase || "Pending";
+    $("detail-phase").textContent = phase;
+    $("detail-phase").className = "phase phase-" + phase;
+    $("detail-message").textContent = status.message || "";
+
+    var allowed = {
+      pause: status.canPause,
+      resume: status.canResume,
+      promote: status.canPromote,
+      abort: status.canAbort,
+    };
+    Array.prototype.forEach.call(document.querySelectorAll("[data-action]"), function (button) {
+      button.disabled = !allowed[button.dataset.action];
+    });
+
+    var runs = Object.keys(state.runs).sort().map(function (t) { return state.runs[t]; });
+    var analysis = (state.selected.spec && state.selected.spec.analysis) || {};
+    chart($("weight-chart"), state.weights, 0, 100);
+    chart($("success-chart"), runs.map(function (run) {
+      return { t: Date.parse(run.completedAt), v: (run.successRate || 0) * 100 };
+    }), 0, 100, analysis.successRate ? analysis.successRate * 100 : undefined);
+    chart($("latency-chart"), runs.map(function (run) {
+      return { t: Date.parse(run.completedAt), v: run.averageLatency || 0 };
+    }), 0, undefined, analysis.maxLatency || undefined);
+
+    var metrics = $("metrics");
+    metrics.textContent = "";
+    ((status.analysisRun && status.analysisRun.metricResults) || []).forEach(function (result) {
+      var row = document.createElement("tr");
+      cell(row, result.name);
+      cell(row, result.value);
+      cell(row, result.threshold);
+      cell(row, result.passed ? "Passed" : "Failed", result.passed ? "passed" : "failed");
+      metrics.appendChild(row);
+    });
+
+    var conditions = $("conditions");
+    conditions.textContent = "";
+    (status.conditions || []).forEach(function (condition) {
+      var row = document.createElement("tr");
+      cell(row, condition.type);
+      cell(row, condition.status);
+      cell(row, condition.reason);
+      cell(row, condition.message);
+      conditions.appendChild(row);
+    });
+  }
+
+  // chart draws points as a line scaled to the svg, with a dashed line at
+  // threshold. The vertical range is [min, max], max defaulting to the
+  // largest value.
+  function chart(svg, points, min, max, threshold) {
+    var width = 600;
+    var height = 200;
+    svg.textContent = "";
+    var values = points.map(function (p) { return p.v; });
+    if (threshold !== undefined) {
+      values.push(threshold);
+    }
+    if (max === undefined) {
+      max = Math.max.apply(null, values.concat([1])) * 1.1;
+    }
+    var y = function (v) { return height - (v - min) / (max - min) * height; };
+
+    [0.25, 0.5, 0.75].forEach(function (f) {
+      line(svg, 0, height * f, width, height * f, "grid");
+    });
+    if (threshold !== undefined) {
+      line(svg, 0, y(threshold), width, y(threshold), "threshold");
+    }
+    if (points.length === 0) {
+      return;
+    }
+
+    var first = points[0].t;
+    var span = Math.max(points[points.length - 1].t - first, 1);
+    var path = points.map(function (p, i) {
+      var x = points.length === 1 ? width : (p.t - first) / span * width;
+      return (i === 0 ? "M" : "L") + x.toFixed(1) + " " + y(p.v).toFixed(1);
+    }).join(" ");
+    var el = document.createElementNS("http://www.w3.org/2000/svg", "path");
+    el.setAttribute("d", path);
+    el.setAttribute("class", "line");
+    svg.appendChild(el);
+  }
+
+  function line(svg, x1, y1, x2, y2, className) {
+    var el = document.createElementNS("http://www.w3.org/2000/svg", "line");
+    el.setAttribute("x1", x1);
+    el.setAttribute("y1", y1);
+    el.setAttribute("x2", x2);
+    el.setAttribute("y2", y2);
+    el.setAttribute("class", className);
+    svg.appendChild(el);
+  }
+
+  function act(action) {
+    var canary = state.selected;
+    if (!canary) {
+      return;
+    }
+    if (action === "abort" && !window.confirm("Abort " + canary.metadata.name + " and roll it back?")) {
+      return;
+    }
+    request("POST", canaryPath(canary) + "/" + action + clusterQuery(canary)).then(function () {
+      showError("detail-error", null);
+      return Promise.all([loadStatus(), loadCanaries()]);
+    }).catch(function (err) {
+      showError("detail-error", err);
+    });
+  }
+
+  // Wiring
+
+  $("namespace").value = state.namespace;
+  $("token").value = state.token;
+  $("settings").addEventListener("submit", function (event) {
+    event.preventDefault();
+    state.namespace = $("namespace").value.trim();
+    state.token = $("token").value.trim();
+    localStorage.setItem("gateway-cd.namespace", state.namespace);
+    localStorage.setItem("gateway-cd.token", state.token);
+    loadCanaries();
+  });
+  $("close").addEventListener("click", function () {
+    state.selected = null;
+    $("detail").hidden = true;
+    renderList();
+  });
+  Array.prototype.forEach.call(document.querySelectorAll("[data-action]"), function (button) {
+    button.addEventListener("click", function () { act(button.dataset.action); });
+  });
+
+  loadCanaries();
+  setInterval(loadCanaries, LIST_INTERVAL);
+  setInterval(loadStatus, DETAIL_INTERVAL);
+})();
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/types"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/diagnose"
+)
+
+// diagnoseCanaryDeployment ranks the likely blockers of a canary's rollout
+func (s *Server) diagnoseCanaryDeployment(c *gin.Context) {
+	_, cl, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if err := cl.Get(c.Request.Context(), types.NamespacedName{
+		Namespace: c.Param("namespace"),
+		Name:      c.Param("name"),
+	}, &canary); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Canary deployment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, diagnose.Diagnose(c.Request.Context(), cl, &canary))
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// canaryETag derives the ETag of a response built from a single canary from
+// its cluster and resourceVersion
+func canaryETag(canary ClusterCanary) string {
+	return `W/"` + canary.Cluster + "-" + canary.ResourceVersion + `"`
+}
+
+// listETag derives the ETag of a response built from canaries from their
+// clusters, names and resourceVersions, independently of their order
+func listETag(canaries []ClusterCanary) string {
+	keys := make([]string, 0, len(canaries))
+	for _, canary := range canaries {
+		keys = append(keys, canary.Cluster+"/"+canary.Namespace+"/"+canary.Name+"/"+canary.ResourceVersion)
+	}
+	sort.Strings(keys)
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified sets the ETag of the response and, if the request's
+// If-None-Match already names it, answers 304 Not Modified. Handlers return
+// without writing a body when it reports true.
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	// Clients may cache the response but must revalidate it on every poll
+	c.Header("Cache-Control", "no-cache")
+
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
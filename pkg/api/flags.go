@@ -0,0 +1,233 @@
+package api
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// Flags are the command line flags configuring a Server, shared by the
+// api-server binary and the controller when it runs the API in its manager
+type Flags struct {
+	WebhookSecretName      string
+	WebhookSecretNamespace string
+	SlackSecretName        string
+	ClusterName            string
+	Contexts               string
+	Auth                   string
+	OIDCIssuerURL          string
+	OIDCClientID           string
+	OIDCUsernameClaim      string
+	OIDCUsernamePrefix     string
+	OIDCGroupsClaim        string
+	Impersonate            bool
+	CORSOrigins            string
+	PresetNamespace        string
+	RateLimit              float64
+	RateLimitBurst         int
+	TrustedProxies         string
+	MaxBodyBytes           int64
+	RequestTimeout         time.Duration
+	ClusterTimeout         time.Duration
+	Dashboard              bool
+	ShutdownDelay          time.Duration
+	ShutdownTimeout        time.Duration
+	RequestLog             bool
+	RequestIDs             bool
+	Gzip                   bool
+	HTTPMetrics            bool
+}
+
+// BindFlags registers the flags on fs
+func (f *Flags) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&f.WebhookSecretName, "webhook-secret-name", "",
+		"Name of the Secret whose \"secret\" key is the HMAC-SHA256 key for inbound webhooks")
+	fs.StringVar(&f.WebhookSecretNamespace, "webhook-secret-namespace", "gateway-cd",
+		"Namespace of the inbound webhook Secret")
+	fs.StringVar(&f.SlackSecretName, "slack-secret-name", "",
+		"Name of the Secret whose \"signing-secret\" key verifies Slack approval button requests, in the webhook Secret namespace")
+	fs.StringVar(&f.ClusterName, "cluster-name", DefaultClusterName,
+		"Name reported for the cluster the API server runs in")
+	fs.StringVar(&f.Contexts, "contexts", "",
+		"Comma-separated kubeconfig contexts to aggregate canaries from. Each context becomes a cluster named after it.")
+	fs.StringVar(&f.Auth, "auth", "none",
+		"How API callers are authenticated: none, tokenreview (Kubernetes TokenReview) or oidc. Authenticated callers are authorized with a SubjectAccessReview.")
+	fs.StringVar(&f.OIDCIssuerURL, "oidc-issuer-url", "", "Issuer URL of the OIDC provider, with --auth=oidc")
+	fs.StringVar(&f.OIDCClientID, "oidc-client-id", "", "Client ID ID tokens must be issued for, with --auth=oidc")
+	fs.StringVar(&f.OIDCUsernameClaim, "oidc-username-claim", "sub", "ID token claim used as the username")
+	fs.StringVar(&f.OIDCUsernamePrefix, "oidc-username-prefix", "", "Prefix added to OIDC usernames, matching the cluster's OIDC configuration")
+	fs.StringVar(&f.OIDCGroupsClaim, "oidc-groups-claim", "groups", "ID token claim listing the user's groups")
+	fs.BoolVar(&f.Impersonate, "impersonate", false,
+		"Read and write canaries as the authenticated caller through Kubernetes impersonation, so each cluster's RBAC decides what callers can do. Requires --auth.")
+	fs.StringVar(&f.CORSOrigins, "cors-origins", "*", "Comma-separated origins browsers may call the API from, or * for any")
+	fs.StringVar(&f.PresetNamespace, "preset-namespace", DefaultPresetNamespace,
+		"Namespace of the ConfigMaps holding the CanaryDeployment presets of the generate endpoint")
+	fs.Float64Var(&f.RateLimit, "rate-limit", DefaultLimits.RequestsPerSecond,
+		"Requests per second allowed per client IP. 0 disables rate limiting.")
+	fs.IntVar(&f.RateLimitBurst, "rate-limit-burst", DefaultLimits.Burst,
+		"Requests a client IP may make at once. Defaults to twice --rate-limit.")
+	fs.StringVar(&f.TrustedProxies, "trusted-proxies", "",
+		"Comma-separated IPs and CIDRs of the proxies whose X-Forwarded-For header names the client IP. By default the client IP is the remote address.")
+	fs.Int64Var(&f.MaxBodyBytes, "max-body-bytes", DefaultLimits.MaxBodyBytes, "Largest request body accepted. 0 leaves bodies unbounded.")
+	fs.DurationVar(&f.RequestTimeout, "request-timeout", DefaultLimits.RequestTimeout,
+		"How long a request may take, including its Kubernetes API calls. 0 leaves requests unbounded.")
+	fs.DurationVar(&f.ClusterTimeout, "cluster-timeout", DefaultLimits.ClusterTimeout,
+		"How long each cluster of a request across --contexts may take before it is reported unreachable. 0 leaves it to --request-timeout.")
+	fs.BoolVar(&f.Dashboard, "dashboard", true, "Serve the embedded web dashboard under "+DashboardPath)
+	fs.DurationVar(&f.ShutdownDelay, "shutdown-delay", DefaultShutdown.Delay,
+		"How long the server keeps serving after it starts failing readiness on shutdown, so load balancers stop routing to it.")
+	fs.DurationVar(&f.ShutdownTimeout, "shutdown-timeout", DefaultShutdown.Timeout,
+		"How long in-flight requests may take to complete on shutdown before their connections are closed.")
+	fs.BoolVar(&f.RequestLog, "request-log", true, "Log every API request with its status and latency")
+	fs.BoolVar(&f.RequestIDs, "request-ids", true,
+		"Tag every API request with its "+RequestIDHeader+" header, or a generated ID, and return it in the response")
+	fs.BoolVar(&f.Gzip, "gzip", false, "Compress the responses of callers accepting gzip encoding")
+	fs.BoolVar(&f.HTTPMetrics, "http-metrics", true,
+		"Record the count and latency of API requests as gatewaycd_api_* Prometheus metrics")
+}
+
+// Options returns the server options the flags select. c authenticates
+// tokens with --auth=tokenreview; the clusters of --contexts get clients
+// for scheme.
+func (f *Flags) Options(c client.Client, scheme *runtime.Scheme) ([]Option, error) {
+	opts := []Option{WithClusterName(f.ClusterName), WithMiddleware(f.middleware()...)}
+	if f.WebhookSecretName != "" {
+		opts = append(opts, WithWebhookSecret(f.WebhookSecretNamespace, f.WebhookSecretName))
+	}
+	if f.SlackSecretName != "" {
+		opts = append(opts, WithSlackSigningSecret(f.WebhookSecretNamespace, f.SlackSecretName))
+	}
+	switch f.Auth {
+	case "none":
+	case "tokenreview":
+		opts = append(opts, WithAuthenticator(NewTokenReviewAuthenticator(c)))
+	case "oidc":
+		if f.OIDCIssuerURL == "" || f.OIDCClientID == "" {
+			return nil, fmt.Errorf("--auth=oidc requires --oidc-issuer-url and --oidc-client-id")
+		}
+		opts = append(opts, WithAuthenticator(NewOIDCAuthenticator(f.OIDCIssuerURL, f.OIDCClientID,
+			f.OIDCUsernameClaim, f.OIDCUsernamePrefix, f.OIDCGroupsClaim)))
+	default:
+		return nil, fmt.Errorf("unknown --auth mode %q", f.Auth)
+	}
+	if f.Impersonate {
+		if f.Auth == "none" {
+			return nil, fmt.Errorf("--impersonate requires --auth=tokenreview or --auth=oidc")
+		}
+		impersonators, err := impersonatingClients(f.ClusterName, f.Contexts, scheme)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up impersonating clients: %w", err)
+		}
+		opts = append(opts, WithImpersonation(impersonators))
+	}
+	opts = append(opts, WithCORSOrigins(strings.Split(f.CORSOrigins, ",")...))
+	opts = append(opts, WithPresetNamespace(f.PresetNamespace))
+	var trustedProxies []string
+	for _, proxy := range strings.Split(f.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return nil, fmt.Errorf("invalid --trusted-proxies entry %q", proxy)
+		}
+		trustedProxies = append(trustedProxies, proxy)
+	}
+	opts = append(opts, WithLimits(Limits{
+		RequestsPerSecond: f.RateLimit,
+		TrustedProxies:    trustedProxies,
+		Burst:             f.RateLimitBurst,
+		MaxBodyBytes:      f.MaxBodyBytes,
+		RequestTimeout:    f.RequestTimeout,
+		ClusterTimeout:    f.ClusterTimeout,
+	}))
+	if f.Dashboard {
+		opts = append(opts, WithDashboard())
+	}
+	opts = append(opts, WithShutdown(Shutdown{Delay: f.ShutdownDelay, Timeout: f.ShutdownTimeout}))
+	if f.Contexts != "" {
+		clusters, err := clusterClients(strings.Split(f.Contexts, ","), scheme)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up cluster clients: %w", err)
+		}
+		opts = append(opts, WithClusters(clusters))
+	}
+	return opts, nil
+}
+
+// middleware returns the built-in middleware the flags enable, the request ID
+// first so the logger sees it
+func (f *Flags) middleware() []gin.HandlerFunc {
+	var handlers []gin.HandlerFunc
+	if f.RequestIDs {
+		handlers = append(handlers, RequestID())
+	}
+	if f.HTTPMetrics {
+		handlers = append(handlers, HTTPMetrics())
+	}
+	if f.RequestLog {
+		handlers = append(handlers, RequestLogger())
+	}
+	if f.Gzip {
+		handlers = append(handlers, Gzip())
+	}
+	return handlers
+}
+
+// clusterClients creates a client per kubeconfig context, keyed by context
+// name. The clients can watch, for the gRPC watch stream.
+func clusterClients(contexts []string, scheme *runtime.Scheme) (map[string]client.Client, error) {
+	clients := map[string]client.Client{}
+	for _, context := range contexts {
+		context = strings.TrimSpace(context)
+		if context == "" {
+			continue
+		}
+		cfg, err := config.GetConfigWithContext(context)
+		if err != nil {
+			return nil, err
+		}
+		c, err := client.NewWithWatch(cfg, client.Options{Scheme: scheme})
+		if err != nil {
+			return nil, err
+		}
+		clients[context] = c
+	}
+	return clients, nil
+}
+
+// impersonatingClients creates an impersonating client for the server's own
+// cluster, named clusterName, and for each of the comma-separated kubeconfig
+// contexts
+func impersonatingClients(clusterName, contexts string, scheme *runtime.Scheme) (map[string]client.Client, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	own, err := ImpersonatingClient(cfg, scheme)
+	if err != nil {
+		return nil, err
+	}
+	clients := map[string]client.Client{clusterName: own}
+	for _, context := range strings.Split(contexts, ",") {
+		context = strings.TrimSpace(context)
+		if context == "" {
+			continue
+		}
+		cfg, err := config.GetConfigWithContext(context)
+		if err != nil {
+			return nil, err
+		}
+		clients[context], err = ImpersonatingClient(cfg, scheme)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return clients, nil
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// PresetLabel names the preset a ConfigMap in the preset namespace holds
+	// the CanaryDeployment template of
+	PresetLabel = "gateway-cd.io/preset"
+	// PresetTemplateKey is the ConfigMap key holding the template
+	PresetTemplateKey = "canary.yaml"
+	// DefaultPresetNamespace is the namespace of the preset ConfigMaps unless
+	// WithPresetNamespace sets another
+	DefaultPresetNamespace = "gateway-cd"
+)
+
+// GenerateRequest holds the inputs rendered into a preset's template
+type GenerateRequest struct {
+	// Preset names the template to render
+	Preset string `json:"preset" binding:"required"`
+	// Namespace of the generated canary
+	Namespace string `json:"namespace" binding:"required"`
+	// Name of the generated canary, defaulting to the service name
+	Name string `json:"name"`
+	// Service is the stable Service of the application
+	Service string `json:"service" binding:"required"`
+	// Route is the HTTPRoute shifting traffic to the application
+	Route string `json:"route" binding:"required"`
+	// Image is the container image being rolled out
+	Image string `json:"image"`
+}
+
+// WithPresetNamespace sets the namespace holding the preset ConfigMaps that
+// platform admins maintain for the generate endpoint
+func WithPresetNamespace(namespace string) Option {
+	return func(s *Server) {
+		s.presetNamespace = namespace
+	}
+}
+
+// generateCanaryDeployment renders a preset's template with the request's
+// inputs and returns the CanaryDeployment, validated against the CRD schema
+// but not created
+func (s *Server) generateCanaryDeployment(c *gin.Context) {
+	cluster, cl, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
+	var req GenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name == "" {
+		req.Name = req.Service
+	}
+
+	tmpl, err := s.presetTemplate(c.Request.Context(), req.Preset)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	body, err := renderPreset(tmpl, req)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if !s.decodeCanary(c, cluster, cl, body, &canary) {
+		return
+	}
+	canary.APIVersion = gatewaycdv1alpha1.GroupVersion.String()
+	canary.Kind = "CanaryDeployment"
+	canary.Name = req.Name
+	canary.Namespace = req.Namespace
+
+	c.JSON(http.StatusOK, canary)
+}
+
+// presetTemplate returns the template of the preset ConfigMap labelled with preset
+func (s *Server) presetTemplate(ctx context.Context, preset string) (string, error) {
+	var configMaps corev1.ConfigMapList
+	if err := s.client.List(ctx, &configMaps, client.InNamespace(s.presetNamespace),
+		client.MatchingLabels{PresetLabel: preset}); err != nil {
+		return "", fmt.Errorf("failed to list presets: %w", err)
+	}
+	if len(configMaps.Items) == 0 {
+		return "", fmt.Errorf("preset %q not found", preset)
+	}
+	if len(configMaps.Items) > 1 {
+		return "", fmt.Errorf("preset %q is defined by %d ConfigMaps", preset, len(configMaps.Items))
+	}
+	tmpl, ok := configMaps.Items[0].Data[PresetTemplateKey]
+	if !ok {
+		return "", fmt.Errorf("preset %q has no %s key", preset, PresetTemplateKey)
+	}
+	return tmpl, nil
+}
+
+// renderPreset executes a Helm-style template with the request as values and
+// converts the YAML or JSON output to JSON
+func renderPreset(text string, req GenerateRequest) ([]byte, error) {
+	tmpl, err := template.New(req.Preset).Option("missingkey=error").Funcs(presetFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse preset %q: %w", req.Preset, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, req); err != nil {
+		return nil, fmt.Errorf("failed to render preset %q: %w", req.Preset, err)
+	}
+
+	var obj map[string]interface{}
+	if err := yaml.NewYAMLOrJSONDecoder(&out, out.Len()+1).Decode(&obj); err != nil {
+		return nil, fmt.Errorf("preset %q rendered invalid YAML: %w", req.Preset, err)
+	}
+	return json.Marshal(obj)
+}
+
+// presetFuncs are the Helm template functions presets commonly use
+var presetFuncs = template.FuncMap{
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+	"quote": func(value interface{}) string {
+		return fmt.Sprintf("%q", fmt.Sprint(value))
+	},
+	"required": func(msg string, value interface{}) (interface{}, error) {
+		if value == nil || value == "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return value, nil
+	},
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"imageTag": func(image string) string {
+		if i := strings.LastIndex(image, "@"); i >= 0 {
+			return image[i+1:]
+		}
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			return image[i+1:]
+		}
+		return "latest"
+	},
+}
@@ -0,0 +1,417 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	adminv1 "gateway-cd/api/proto/admin/v1"
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// canaryService implements the gRPC CanaryService on top of the server's
+// clusters and authenticator, so both APIs share one set of semantics
+type canaryService struct {
+	adminv1.UnimplementedCanaryServiceServer
+	server *Server
+}
+
+// GRPCServer creates a gRPC server serving the CanaryService admin API,
+// with server reflection so tools like grpcurl need no local proto files
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	grpcServer := grpc.NewServer(opts...)
+	adminv1.RegisterCanaryServiceServer(grpcServer, &canaryService{server: s})
+	reflection.Register(grpcServer)
+	return grpcServer
+}
+
+// RunGRPC starts the gRPC admin API on addr
+func (s *Server) RunGRPC(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.GRPCServer().Serve(listener)
+}
+
+// ListCanaries returns the canary deployments of every cluster, or of the selected cluster
+func (g *canaryService) ListCanaries(ctx context.Context, req *adminv1.ListCanariesRequest) (*adminv1.ListCanariesResponse, error) {
+	ctx, _, err := g.server.authorizeRPC(ctx, "list", req.GetCluster(), req.GetNamespace(), "")
+	if err != nil {
+		return nil, err
+	}
+	names, err := g.server.rpcClusterNames(ctx, req.GetCluster())
+	if err != nil {
+		return nil, err
+	}
+	var listOpts []client.ListOption
+	if req.GetNamespace() != "" {
+		listOpts = append(listOpts, client.InNamespace(req.GetNamespace()))
+	}
+
+	resp := &adminv1.ListCanariesResponse{}
+	var lastErr error
+	for _, name := range names {
+		var canaries gatewaycdv1alpha1.CanaryDeploymentList
+		if err := g.server.clusterClient(ctx, name).List(ctx, &canaries, listOpts...); err != nil {
+			resp.UnreachableClusters = append(resp.UnreachableClusters, name)
+			lastErr = err
+			continue
+		}
+		for i := range canaries.Items {
+			canary, err := canaryMessage(name, &canaries.Items[i])
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			resp.Canaries = append(resp.Canaries, canary)
+		}
+	}
+	if len(resp.UnreachableClusters) == len(names) {
+		return nil, rpcError(lastErr)
+	}
+	return resp, nil
+}
+
+// GetCanary returns a single canary deployment
+func (g *canaryService) GetCanary(ctx context.Context, req *adminv1.GetCanaryRequest) (*adminv1.Canary, error) {
+	ctx, _, err := g.server.authorizeRPC(ctx, "get", req.GetCluster(), req.GetNamespace(), req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	cluster, cl, err := g.server.rpcCluster(ctx, req.GetCluster())
+	if err != nil {
+		return nil, err
+	}
+
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if err := cl.Get(ctx, types.NamespacedName{Namespace: req.GetNamespace(), Name: req.GetName()}, &canary); err != nil {
+		return nil, rpcError(err)
+	}
+	message, err := canaryMessage(cluster, &canary)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return message, nil
+}
+
+// PauseCanary pauses a running canary deployment
+func (g *canaryService) PauseCanary(ctx context.Context, req *adminv1.CanaryActionRequest) (*adminv1.Canary, error) {
+	return g.control(ctx, req, "gateway-cd.io/pause", "true")
+}
+
+// ResumeCanary resumes a paused canary deployment
+func (g *canaryService) ResumeCanary(ctx context.Context, req *adminv1.CanaryActionRequest) (*adminv1.Canary, error) {
+	return g.control(ctx, req, "gateway-cd.io/resume", "true")
+}
+
+// PromoteCanary promotes the canary to stable
+func (g *canaryService) PromoteCanary(ctx context.Context, req *adminv1.CanaryActionRequest) (*adminv1.Canary, error) {
+	return g.control(ctx, req, "gateway-cd.io/promote", "true")
+}
+
+// AbortCanary aborts a canary deployment
+func (g *canaryService) AbortCanary(ctx context.Context, req *adminv1.CanaryActionRequest) (*adminv1.Canary, error) {
+	return g.control(ctx, req, "gateway-cd.io/abort", "true")
+}
+
+// RetryCanary restarts the rollout of a Failed canary deployment from the
+// first step, or with from "last-good" from the last step that passed
+func (g *canaryService) RetryCanary(ctx context.Context, req *adminv1.RetryCanaryRequest) (*adminv1.Canary, error) {
+	value := "true"
+	switch req.GetFrom() {
+	case "":
+	case "last-good":
+		value = req.GetFrom()
+	default:
+		return nil, status.Error(codes.InvalidArgument, "from must be last-good")
+	}
+	action := &adminv1.CanaryActionRequest{Cluster: req.GetCluster(), Namespace: req.GetNamespace(), Name: req.GetName()}
+	return g.control(ctx, action, "gateway-cd.io/retry", value)
+}
+
+// control sets the annotation of a control action to value, like the REST
+// control endpoints, and returns the updated canary deployment
+func (g *canaryService) control(ctx context.Context, req *adminv1.CanaryActionRequest, annotation, value string) (*adminv1.Canary, error) {
+	ctx, user, err := g.server.authorizeRPC(ctx, "patch", req.GetCluster(), req.GetNamespace(), req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	cluster, cl, err := g.server.rpcCluster(ctx, req.GetCluster())
+	if err != nil {
+		return nil, err
+	}
+
+	source := gatewaycdv1alpha1.ControlSourceAPI
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestedViaHeader); len(values) > 0 &&
+			strings.EqualFold(values[0], string(gatewaycdv1alpha1.ControlSourceCLI)) {
+			source = gatewaycdv1alpha1.ControlSourceCLI
+		}
+	}
+	annotations := map[string]string{annotation: value, AnnotationRequestedVia: string(source)}
+	if user != nil {
+		annotations[AnnotationRequestedBy] = user.Username
+	}
+	if err := setCanaryAnnotations(ctx, cl, req.GetNamespace(), req.GetName(), annotations); err != nil {
+		return nil, rpcError(err)
+	}
+
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if err := cl.Get(ctx, types.NamespacedName{Namespace: req.GetNamespace(), Name: req.GetName()}, &canary); err != nil {
+		return nil, rpcError(err)
+	}
+	message, err := canaryMessage(cluster, &canary)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return message, nil
+}
+
+// WatchCanaries streams the canary deployments of every cluster, or of the
+// selected cluster, as they change. A cluster whose client cannot watch
+// fails the call with Unimplemented.
+func (g *canaryService) WatchCanaries(req *adminv1.WatchCanariesRequest, stream adminv1.CanaryService_WatchCanariesServer) error {
+	ctx, _, err := g.server.authorizeRPC(stream.Context(), "watch", req.GetCluster(), req.GetNamespace(), "")
+	if err != nil {
+		return err
+	}
+	names, err := g.server.rpcClusterNames(ctx, req.GetCluster())
+	if err != nil {
+		return err
+	}
+	var listOpts []client.ListOption
+	if req.GetNamespace() != "" {
+		listOpts = append(listOpts, client.InNamespace(req.GetNamespace()))
+	}
+
+	watchers := make([]watch.Interface, 0, len(names))
+	defer func() {
+		for _, watcher := range watchers {
+			watcher.Stop()
+		}
+	}()
+	for _, name := range names {
+		cl, ok := g.server.clusterClient(ctx, name).(client.WithWatch)
+		if !ok {
+			return status.Errorf(codes.Unimplemented, "cluster %q does not support watching", name)
+		}
+		watcher, err := cl.Watch(ctx, &gatewaycdv1alpha1.CanaryDeploymentList{}, listOpts...)
+		if err != nil {
+			return rpcError(err)
+		}
+		watchers = append(watchers, watcher)
+	}
+
+	// Fan the watches of every cluster in to the single stream
+	events := make(chan *adminv1.CanaryEvent)
+	errs := make(chan error, len(watchers))
+	var wg sync.WaitGroup
+	for i, watcher := range watchers {
+		wg.Add(1)
+		go func(cluster string, watcher watch.Interface) {
+			defer wg.Done()
+			for event := range watcher.ResultChan() {
+				message, err := canaryEvent(cluster, event)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if message == nil {
+					continue
+				}
+				select {
+				case events <- message:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(names[i], watcher)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for {
+		select {
+		case event := <-events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case err := <-errs:
+			return err
+		case <-done:
+			return status.Error(codes.Unavailable, "watch closed by the cluster")
+		case <-g.server.stopping:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// authorizeRPC authenticates the caller from the authorization metadata and
+// checks that it may perform verb on the canary deployments of namespace in
+// cluster, as authorize does for REST routes. It returns ctx carrying the caller, and
+// the caller, which is nil without an authenticator.
+func (s *Server) authorizeRPC(ctx context.Context, verb, cluster, namespace, name string) (context.Context, *authenticationv1.UserInfo, error) {
+	if s.authenticator == nil {
+		return ctx, nil, nil
+	}
+
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token, _ = strings.CutPrefix(values[0], "Bearer ")
+		}
+	}
+	if token == "" {
+		return nil, nil, status.Error(codes.Unauthenticated, "Bearer token required")
+	}
+	user, err := s.authenticator.Authenticate(ctx, token)
+	if err != nil {
+		return nil, nil, status.Error(codes.Unauthenticated, "Invalid bearer token")
+	}
+
+	attributes := &authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      verb,
+		Group:     gatewaycdv1alpha1.GroupVersion.Group,
+		Resource:  "canarydeployments",
+		Name:      name,
+	}
+	names, ok := s.reviewedClusters(verb, cluster)
+	if !ok {
+		return nil, nil, status.Errorf(codes.NotFound, "Unknown cluster %q", cluster)
+	}
+	ctx, allowed, err := s.authorizeClusters(ctx, user, attributes, names)
+	if err != nil {
+		return nil, nil, status.Error(codes.Internal, err.Error())
+	}
+	if !allowed {
+		return nil, nil, status.Errorf(codes.PermissionDenied, "User %q cannot %s canarydeployments in namespace %q",
+			user.Username, verb, namespace)
+	}
+	return withCaller(ctx, user), user, nil
+}
+
+// rpcCluster resolves a cluster name, defaulting to the server's own
+// cluster, to the client performing the calls of ctx
+func (s *Server) rpcCluster(ctx context.Context, name string) (string, client.Client, error) {
+	if name == "" {
+		name = s.clusterName
+	}
+	if _, ok := s.clusters[name]; !ok {
+		return "", nil, status.Errorf(codes.NotFound, "Unknown cluster %q", name)
+	}
+	if !clusterAllowed(ctx, name) {
+		return "", nil, status.Errorf(codes.PermissionDenied, "Access to cluster %q not allowed", name)
+	}
+	return name, s.clusterClient(ctx, name), nil
+}
+
+// rpcClusterNames returns the selected cluster, or every cluster if name is empty
+func (s *Server) rpcClusterNames(ctx context.Context, name string) ([]string, error) {
+	if name == "" {
+		var names []string
+		for _, name := range s.clusterNames() {
+			if clusterAllowed(ctx, name) {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+	if _, ok := s.clusters[name]; !ok {
+		return nil, status.Errorf(codes.NotFound, "Unknown cluster %q", name)
+	}
+	return []string{name}, nil
+}
+
+// rpcError converts a Kubernetes API error to a gRPC status
+func rpcError(err error) error {
+	var apiErr apierrors.APIStatus
+	switch {
+	case apierrors.IsNotFound(err):
+		return status.Error(codes.NotFound, "Canary deployment not found")
+	case apierrors.IsConflict(err):
+		return status.Error(codes.Aborted, err.Error())
+	case apierrors.IsForbidden(err):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &apiErr):
+		return status.Error(codes.Unknown, err.Error())
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// canaryEvent converts a watch event to a CanaryEvent, or nil for events
+// that carry no canary deployment, e.g. bookmarks
+func canaryEvent(cluster string, event watch.Event) (*adminv1.CanaryEvent, error) {
+	var eventType adminv1.CanaryEvent_Type
+	switch event.Type {
+	case watch.Added:
+		eventType = adminv1.CanaryEvent_ADDED
+	case watch.Modified:
+		eventType = adminv1.CanaryEvent_MODIFIED
+	case watch.Deleted:
+		eventType = adminv1.CanaryEvent_DELETED
+	case watch.Error:
+		return nil, rpcError(apierrors.FromObject(event.Object))
+	default:
+		return nil, nil
+	}
+	canary, ok := event.Object.(*gatewaycdv1alpha1.CanaryDeployment)
+	if !ok {
+		return nil, nil
+	}
+	message, err := canaryMessage(cluster, canary)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &adminv1.CanaryEvent{Type: eventType, Canary: message}, nil
+}
+
+// canaryMessage converts a canary deployment to its protobuf message
+func canaryMessage(cluster string, canary *gatewaycdv1alpha1.CanaryDeployment) (*adminv1.Canary, error) {
+	object, err := json.Marshal(canary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode canary deployment: %w", err)
+	}
+	message := &adminv1.Canary{
+		Cluster:         cluster,
+		Namespace:       canary.Namespace,
+		Name:            canary.Name,
+		ResourceVersion: canary.ResourceVersion,
+		Phase:           string(canary.Status.Phase),
+		Message:         canary.Status.Message,
+		CurrentStep:     canary.Status.CurrentStep,
+		TotalSteps:      int32(len(canary.Spec.TrafficSplit)),
+		CanaryWeight:    canary.Status.CanaryWeight,
+		StableWeight:    canary.Status.StableWeight,
+		RollbackReason:  canary.Status.RollbackReason,
+		Object:          object,
+	}
+	if canary.Status.LastTransitionTime != nil {
+		message.LastTransitionTime = timestamppb.New(canary.Status.LastTransitionTime.Time)
+	}
+	return message, nil
+}
@@ -2,28 +2,120 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/controller"
+	"gateway-cd/pkg/quota"
+	"gateway-cd/pkg/stats"
 )
 
 // Server represents the API server
 type Server struct {
 	client client.Client
 	router *gin.Engine
+
+	// clusterName names the cluster of client
+	clusterName string
+	// clusters holds a client per cluster, including client under clusterName
+	clusters map[string]client.Client
+
+	// webhookSecret locates the Secret holding the HMAC key for inbound webhooks
+	webhookSecret types.NamespacedName
+	// deliveries rejects inbound webhook requests delivered twice
+	deliveries *deliveryLog
+	// slackSecret locates the Secret holding the Slack app signing secret
+	slackSecret types.NamespacedName
+
+	// authenticator authenticates API callers; nil leaves the API open
+	authenticator Authenticator
+	// impersonators hold a client per cluster performing the calls of
+	// authenticated callers as the callers; nil uses clusters
+	impersonators map[string]client.Client
+	// corsOrigins are the origins browsers may call the API from
+	corsOrigins []string
+	// limits bounds the rate, body size and duration of requests
+	limits Limits
+	// middleware runs on every request before CORS and the limits
+	middleware []gin.HandlerFunc
+
+	// presetNamespace holds the preset ConfigMaps of the generate endpoint
+	presetNamespace string
+
+	// quotas enforces the per-team quotas on created canaries; nil enforces none
+	quotas *quota.Checker
+
+	// fieldIndexes reports whether client reads from a cache with the fields
+	// of IndexFields indexed
+	fieldIndexes bool
+
+	// dashboard serves the embedded dashboard
+	dashboard bool
+
+	// shutdown configures how Serve drains the server
+	shutdown Shutdown
+	// draining is set once the server is shutting down and fails readiness
+	draining atomic.Bool
+	// stopping is closed when the server closes its watch streams
+	stopping chan struct{}
+	stopOnce sync.Once
+
+	// schemas caches the CanaryDeployment CRD schema per cluster
+	schemas   map[string]*apiextensionsv1.JSONSchemaProps
+	schemasMu sync.Mutex
+}
+
+// Option configures optional Server behaviour
+type Option func(*Server)
+
+// WithWebhookSecret sets the Secret whose "secret" key signs inbound webhook requests
+func WithWebhookSecret(namespace, name string) Option {
+	return func(s *Server) {
+		s.webhookSecret = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+}
+
+// WithQuotas enforces the per-team quotas of the checker on created canaries
+func WithQuotas(quotas *quota.Checker) Option {
+	return func(s *Server) {
+		s.quotas = quotas
+	}
 }
 
 // NewServer creates a new API server
-func NewServer(client client.Client) *Server {
+func NewServer(k8sClient client.Client, opts ...Option) *Server {
 	s := &Server{
-		client: client,
-		router: gin.Default(),
+		client:          k8sClient,
+		router:          gin.New(),
+		clusterName:     DefaultClusterName,
+		clusters:        map[string]client.Client{},
+		schemas:         map[string]*apiextensionsv1.JSONSchemaProps{},
+		corsOrigins:     []string{"*"},
+		limits:          DefaultLimits,
+		presetNamespace: DefaultPresetNamespace,
+		shutdown:        DefaultShutdown,
+		stopping:        make(chan struct{}),
+		deliveries:      newDeliveryLog(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if _, ok := s.clusters[s.clusterName]; !ok {
+		s.clusters[s.clusterName] = k8sClient
 	}
 
 	s.setupRoutes()
@@ -32,66 +124,113 @@ func NewServer(client client.Client) *Server {
 
 // setupRoutes configures the API routes
 func (s *Server) setupRoutes() {
-	// CORS middleware
-	s.router.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+	s.trustProxies()
 
-		c.Next()
-	})
+	// Recovery, the configured middleware, then CORS and request limits
+	s.router.Use(gin.Recovery())
+	s.router.Use(s.middleware...)
+	s.router.Use(s.cors(), s.limit())
 
 	api := s.router.Group("/api/v1")
 	{
 		// Canary deployment routes
-		api.GET("/canaries", s.listCanaryDeployments)
-		api.GET("/canaries/:namespace/:name", s.getCanaryDeployment)
-		api.POST("/canaries", s.createCanaryDeployment)
-		api.PUT("/canaries/:namespace/:name", s.updateCanaryDeployment)
-		api.DELETE("/canaries/:namespace/:name", s.deleteCanaryDeployment)
+		api.GET("/canaries", s.authorize("list"), s.listCanaryDeployments)
+		api.GET("/canaries/:namespace/:name", s.authorize("get"), s.getCanaryDeployment)
+		api.POST("/canaries", s.authorize("create"), s.createCanaryDeployment)
+		api.POST("/canaries/generate", s.authorize("create"), s.generateCanaryDeployment)
+		api.POST("/canaries/validate", s.authorize("create"), s.validateCanaryDeployment)
+		api.PUT("/canaries/:namespace/:name", s.authorize("update"), s.updateCanaryDeployment)
+		api.DELETE("/canaries/:namespace/:name", s.authorize("delete"), s.deleteCanaryDeployment)
 
 		// Canary control routes
-		api.POST("/canaries/:namespace/:name/resume", s.resumeCanaryDeployment)
-		api.POST("/canaries/:namespace/:name/pause", s.pauseCanaryDeployment)
-		api.POST("/canaries/:namespace/:name/abort", s.abortCanaryDeployment)
-		api.POST("/canaries/:namespace/:name/promote", s.promoteCanaryDeployment)
+		api.POST("/canaries/:namespace/:name/resume", s.authorize("patch"), s.resumeCanaryDeployment)
+		api.POST("/canaries/:namespace/:name/pause", s.authorize("patch"), s.pauseCanaryDeployment)
+		api.POST("/canaries/:namespace/:name/abort", s.authorize("patch"), s.abortCanaryDeployment)
+		api.POST("/canaries/:namespace/:name/promote", s.authorize("patch"), s.promoteCanaryDeployment)
+		api.POST("/canaries/:namespace/:name/retry", s.authorize("patch"), s.retryCanaryDeployment)
+		api.POST("/canaries/:namespace/:name/weight", s.authorize("patch"), s.overrideCanaryWeight)
+		api.POST("/canaries/:namespace/:name/plan", s.authorize("get"), s.planCanaryDeployment)
 
 		// Status and metrics routes
-		api.GET("/canaries/:namespace/:name/status", s.getCanaryStatus)
-		api.GET("/canaries/:namespace/:name/metrics", s.getCanaryMetrics)
-		api.GET("/canaries/:namespace/:name/history", s.getCanaryHistory)
+		api.GET("/canaries/:namespace/:name/status", s.authorize("get"), s.getCanaryStatus)
+		api.GET("/canaries/:namespace/:name/metrics", s.authorize("get"), s.getCanaryMetrics)
+		api.GET("/canaries/:namespace/:name/history", s.authorize("get"), s.getCanaryHistory)
+		api.GET("/canaries/:namespace/:name/audit", s.authorize("get"), s.getCanaryAudit)
+		api.GET("/canaries/:namespace/:name/diagnose", s.authorize("get"), s.diagnoseCanaryDeployment)
 
-		// Health check
+		// Rollout statistics
+		api.GET("/stats/namespaces", s.authorize("list"), s.getNamespaceStats)
+
+		// Health and readiness checks
 		api.GET("/health", s.healthCheck)
+		api.GET("/ready", s.readyCheck)
+	}
+
+	// Inbound webhooks, authenticated with an HMAC-SHA256 signature
+	hooks := s.router.Group("/api/v1/hooks", s.verifySignature())
+	{
+		hooks.POST("/trigger", s.triggerHook)
+		hooks.POST("/analysis-verdict", s.analysisVerdictHook)
+		hooks.POST("/bulk", s.bulkControlHook)
+	}
+
+	// Slack approval buttons, authenticated with the Slack app signing secret
+	s.router.POST("/api/v1/slack/interactions", s.verifySlackSignature(), s.slackInteractionHook)
+
+	if s.dashboard {
+		s.serveDashboard()
 	}
 }
 
-// Run starts the API server
+// Run starts the API server. It serves until the process exits; Serve
+// shuts down gracefully when its context is done.
 func (s *Server) Run(addr string) error {
-	return s.router.Run(addr)
+	return s.Serve(context.Background(), addr, "")
 }
 
-// listCanaryDeployments returns all canary deployments
+// listCanaryDeployments returns the canary deployments of every cluster, or
+// of the cluster selected by the cluster query parameter, optionally only
+// those in the phase query parameter
 func (s *Server) listCanaryDeployments(c *gin.Context) {
-	var canaries gatewaycdv1alpha1.CanaryDeploymentList
-
 	namespace := c.Query("namespace")
+	phase := gatewaycdv1alpha1.CanaryDeploymentPhase(c.Query("phase"))
 	var listOpts []client.ListOption
 	if namespace != "" {
 		listOpts = append(listOpts, client.InNamespace(namespace))
 	}
 
-	if err := s.client.List(context.Background(), &canaries, listOpts...); err != nil {
+	items, err := fanOut(s, c, func(ctx context.Context, cluster string, cl client.Client) ([]ClusterCanary, error) {
+		opts := append([]client.ListOption{}, listOpts...)
+		// Only the server's own cluster is read through the indexed cache
+		indexed := phase != "" && s.fieldIndexes && cluster == s.clusterName
+		if indexed {
+			opts = append(opts, client.MatchingFields{PhaseIndex: string(phase)})
+		}
+		var canaries gatewaycdv1alpha1.CanaryDeploymentList
+		if err := cl.List(ctx, &canaries, opts...); err != nil {
+			return nil, err
+		}
+		var items []ClusterCanary
+		for _, canary := range canaries.Items {
+			if phase != "" && !indexed && canary.Status.Phase != phase {
+				continue
+			}
+			items = append(items, ClusterCanary{Cluster: cluster, CanaryDeployment: canary})
+		}
+		return items, nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if items == nil {
+		items = []ClusterCanary{}
+	}
 
-	c.JSON(http.StatusOK, canaries.Items)
+	if notModified(c, listETag(items)) {
+		return
+	}
+	c.JSON(http.StatusOK, items)
 }
 
 // getCanaryDeployment returns a specific canary deployment
@@ -99,8 +238,13 @@ func (s *Server) getCanaryDeployment(c *gin.Context) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
 
+	cluster, cl, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
 	var canary gatewaycdv1alpha1.CanaryDeployment
-	if err := s.client.Get(context.Background(), types.NamespacedName{
+	if err := cl.Get(c.Request.Context(), types.NamespacedName{
 		Namespace: namespace,
 		Name:      name,
 	}, &canary); err != nil {
@@ -108,23 +252,40 @@ func (s *Server) getCanaryDeployment(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, canary)
+	result := ClusterCanary{Cluster: cluster, CanaryDeployment: canary}
+	if notModified(c, canaryETag(result)) {
+		return
+	}
+	c.JSON(http.StatusOK, result)
 }
 
 // createCanaryDeployment creates a new canary deployment
 func (s *Server) createCanaryDeployment(c *gin.Context) {
+	cluster, cl, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
 	var canary gatewaycdv1alpha1.CanaryDeployment
-	if err := c.ShouldBindJSON(&canary); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !s.bindCanary(c, cluster, cl, &canary) {
 		return
 	}
 
-	if err := s.client.Create(context.Background(), &canary); err != nil {
+	if err := s.quotas.CheckCreate(c.Request.Context(), cl, canary.Namespace); err != nil {
+		if quota.IsExceeded(err) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, canary)
+	if err := cl.Create(c.Request.Context(), &canary); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ClusterCanary{Cluster: cluster, CanaryDeployment: canary})
 }
 
 // updateCanaryDeployment updates an existing canary deployment
@@ -132,8 +293,13 @@ func (s *Server) updateCanaryDeployment(c *gin.Context) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
 
+	cluster, cl, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
 	var existing gatewaycdv1alpha1.CanaryDeployment
-	if err := s.client.Get(context.Background(), types.NamespacedName{
+	if err := cl.Get(c.Request.Context(), types.NamespacedName{
 		Namespace: namespace,
 		Name:      name,
 	}, &existing); err != nil {
@@ -142,8 +308,7 @@ func (s *Server) updateCanaryDeployment(c *gin.Context) {
 	}
 
 	var updated gatewaycdv1alpha1.CanaryDeployment
-	if err := c.ShouldBindJSON(&updated); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !s.bindCanary(c, cluster, cl, &updated) {
 		return
 	}
 
@@ -151,12 +316,12 @@ func (s *Server) updateCanaryDeployment(c *gin.Context) {
 	updated.ObjectMeta = existing.ObjectMeta
 	updated.Status = existing.Status
 
-	if err := s.client.Update(context.Background(), &updated); err != nil {
+	if err := cl.Update(c.Request.Context(), &updated); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, updated)
+	c.JSON(http.StatusOK, ClusterCanary{Cluster: cluster, CanaryDeployment: updated})
 }
 
 // deleteCanaryDeployment deletes a canary deployment
@@ -164,8 +329,13 @@ func (s *Server) deleteCanaryDeployment(c *gin.Context) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
 
+	cluster, cl, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
 	var canary gatewaycdv1alpha1.CanaryDeployment
-	if err := s.client.Get(context.Background(), types.NamespacedName{
+	if err := cl.Get(c.Request.Context(), types.NamespacedName{
 		Namespace: namespace,
 		Name:      name,
 	}, &canary); err != nil {
@@ -173,12 +343,12 @@ func (s *Server) deleteCanaryDeployment(c *gin.Context) {
 		return
 	}
 
-	if err := s.client.Delete(context.Background(), &canary); err != nil {
+	if err := cl.Delete(c.Request.Context(), &canary); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Canary deployment deleted"})
+	c.JSON(http.StatusOK, gin.H{"message": "Canary deployment deleted", "cluster": cluster})
 }
 
 // resumeCanaryDeployment resumes a paused canary deployment
@@ -201,31 +371,89 @@ func (s *Server) promoteCanaryDeployment(c *gin.Context) {
 	s.updateCanaryAnnotation(c, "gateway-cd.io/promote", "true")
 }
 
+// retryCanaryDeployment restarts the rollout of a Failed canary deployment
+// from the first step, or with ?from=last-good from the last step that passed
+func (s *Server) retryCanaryDeployment(c *gin.Context) {
+	switch from := c.Query("from"); from {
+	case "":
+		s.updateCanaryAnnotation(c, "gateway-cd.io/retry", "true")
+	case "last-good":
+		s.updateCanaryAnnotation(c, "gateway-cd.io/retry", from)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be last-good"})
+	}
+}
+
+// WeightRequest sets the canary weight outside the traffic split steps
+type WeightRequest struct {
+	Weight *int32 `json:"weight" binding:"required,min=0,max=100"`
+}
+
+// overrideCanaryWeight sends an explicit share of traffic to the canary and
+// holds the rollout there until it is resumed
+func (s *Server) overrideCanaryWeight(c *gin.Context) {
+	var req WeightRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s.updateCanaryAnnotation(c, "gateway-cd.io/weight", strconv.Itoa(int(*req.Weight)))
+}
+
 // updateCanaryAnnotation is a helper to update canary annotations
 func (s *Server) updateCanaryAnnotation(c *gin.Context, key, value string) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
 
-	var canary gatewaycdv1alpha1.CanaryDeployment
-	if err := s.client.Get(context.Background(), types.NamespacedName{
-		Namespace: namespace,
-		Name:      name,
-	}, &canary); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Canary deployment not found"})
+	cluster, cl, ok := s.requestCluster(c)
+	if !ok {
 		return
 	}
 
-	if canary.Annotations == nil {
-		canary.Annotations = make(map[string]string)
-	}
-	canary.Annotations[key] = value
-
-	if err := s.client.Update(context.Background(), &canary); err != nil {
+	if err := setCanaryAnnotations(c.Request.Context(), cl, namespace, name, controlRequest(c, key, value)); err != nil {
+		if apierrors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Canary deployment not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Annotation updated"})
+	c.JSON(http.StatusOK, gin.H{"message": "Annotation updated", "cluster": cluster})
+}
+
+// RequestedViaHeader names the client requesting a control action, e.g.
+// "CLI", recorded as the source in the audit trail. It defaults to API.
+const RequestedViaHeader = "X-Requested-Via"
+
+// controlRequest returns the annotations requesting a control action for the
+// caller of c, recording who requested it and from where
+func controlRequest(c *gin.Context, key, value string) map[string]string {
+	source := gatewaycdv1alpha1.ControlSourceAPI
+	if strings.EqualFold(c.GetHeader(RequestedViaHeader), string(gatewaycdv1alpha1.ControlSourceCLI)) {
+		source = gatewaycdv1alpha1.ControlSourceCLI
+	}
+	annotations := map[string]string{key: value, AnnotationRequestedVia: string(source)}
+	if user := requestedBy(c); user != "" {
+		annotations[AnnotationRequestedBy] = user
+	}
+	return annotations
+}
+
+// setCanaryAnnotations sets the given annotations on a canary deployment in
+// the cluster of cl. The merge patch leaves the rest of the canary alone, so
+// it neither conflicts with nor reverts concurrent writes, e.g. when cl reads
+// from a stale cache.
+func setCanaryAnnotations(ctx context.Context, cl client.Client, namespace, name string, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+
+	canary := &gatewaycdv1alpha1.CanaryDeployment{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	return cl.Patch(ctx, canary, client.RawPatch(types.MergePatchType, patch))
 }
 
 // getCanaryStatus returns the current status of a canary deployment
@@ -233,17 +461,26 @@ func (s *Server) getCanaryStatus(c *gin.Context) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
 
+	cluster, cl, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
 	var canary gatewaycdv1alpha1.CanaryDeployment
-	if err := s.client.Get(context.Background(), types.NamespacedName{
+	if err := cl.Get(c.Request.Context(), types.NamespacedName{
 		Namespace: namespace,
 		Name:      name,
 	}, &canary); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Canary deployment not found"})
 		return
 	}
+	if notModified(c, canaryETag(ClusterCanary{Cluster: cluster, CanaryDeployment: canary})) {
+		return
+	}
 
 	// Enhanced status response
 	status := map[string]interface{}{
+		"cluster":           cluster,
 		"phase":             canary.Status.Phase,
 		"message":           canary.Status.Message,
 		"currentStep":       canary.Status.CurrentStep,
@@ -251,8 +488,17 @@ func (s *Server) getCanaryStatus(c *gin.Context) {
 		"canaryWeight":      canary.Status.CanaryWeight,
 		"stableWeight":      canary.Status.StableWeight,
 		"lastTransition":    canary.Status.LastTransitionTime,
+		"managedRoute":      canary.Status.ManagedRoute,
+		"lastAppliedWeight": canary.Status.LastAppliedWeight,
+		"routeGeneration":   canary.Status.RouteGeneration,
+		"routeUpdatedTime":  canary.Status.RouteUpdatedTime,
+		"routes":            canary.Status.Routes,
 		"conditions":        canary.Status.Conditions,
+		"blockedBy":         canary.Status.BlockedBy,
 		"analysisRun":       canary.Status.AnalysisRun,
+		"analysisHistory":   canary.Status.AnalysisHistory,
+		"history":           canary.Status.History,
+		"changeMetadata":    canary.Status.ChangeMetadata,
 		"canPause":          canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing,
 		"canResume":         canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhasePaused,
 		"canAbort":          canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing || canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhasePaused,
@@ -264,9 +510,15 @@ func (s *Server) getCanaryStatus(c *gin.Context) {
 
 // getCanaryMetrics returns metrics for a canary deployment
 func (s *Server) getCanaryMetrics(c *gin.Context) {
+	cluster, _, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
 	// This would integrate with your metrics provider
 	// For now, return mock data
 	metrics := map[string]interface{}{
+		"cluster":        cluster,
 		"successRate":    0.995,
 		"averageLatency": 150,
 		"requestCount":   1250,
@@ -278,36 +530,153 @@ func (s *Server) getCanaryMetrics(c *gin.Context) {
 	c.JSON(http.StatusOK, metrics)
 }
 
-// getCanaryHistory returns the deployment history
+// getCanaryHistory returns the step transitions of the canary newest first,
+// including those compacted into the history ConfigMap
 func (s *Server) getCanaryHistory(c *gin.Context) {
-	// Parse query parameters
-	limitStr := c.DefaultQuery("limit", "10")
-	limit, _ := strconv.Atoi(limitStr)
+	namespace := c.Param("namespace")
+	name := c.Param("name")
 
-	// This would query your database for historical data
-	// For now, return mock data
-	history := []map[string]interface{}{
-		{
-			"timestamp":   metav1.Now(),
-			"phase":       "Progressing",
-			"step":        2,
-			"weight":      25,
-			"message":     "Traffic split updated: 25% canary, 75% stable",
-		},
-		{
-			"timestamp":   metav1.Now(),
-			"phase":       "Progressing",
-			"step":        1,
-			"weight":      10,
-			"message":     "Traffic split updated: 10% canary, 90% stable",
-		},
-	}
-
-	if len(history) > limit {
-		history = history[:limit]
-	}
-
-	c.JSON(http.StatusOK, history)
+	cluster, cl, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if err := cl.Get(c.Request.Context(), types.NamespacedName{
+		Namespace: namespace,
+		Name:      name,
+	}, &canary); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Canary deployment not found"})
+		return
+	}
+
+	limit := 10
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	// Step transitions compacted out of the status precede status.history
+	history, err := compactedHistory(c.Request.Context(), cl, &canary)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	history = append(history, canary.Status.History...)
+
+	transitions := []gatewaycdv1alpha1.StepTransition{}
+	for i := len(history) - 1; i >= 0 && len(transitions) < limit; i-- {
+		transitions = append(transitions, history[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cluster":          cluster,
+		"history":          transitions,
+		"changeMetadata":   canary.Status.ChangeMetadata,
+		"historyConfigMap": canary.Status.HistoryConfigMap,
+	})
+}
+
+// compactedHistory returns the step transitions the controller moved from
+// status.history to the history ConfigMap, oldest first
+func compactedHistory(ctx context.Context, cl client.Client, canary *gatewaycdv1alpha1.CanaryDeployment) ([]gatewaycdv1alpha1.StepTransition, error) {
+	if canary.Status.HistoryConfigMap == "" {
+		return nil, nil
+	}
+	var cm corev1.ConfigMap
+	if err := cl.Get(ctx, types.NamespacedName{Namespace: canary.Namespace, Name: canary.Status.HistoryConfigMap}, &cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get history ConfigMap: %w", err)
+	}
+
+	var transitions []gatewaycdv1alpha1.StepTransition
+	for _, line := range strings.Split(cm.Data[controller.HistoryKey], "\n") {
+		var record struct {
+			Kind   string                           `json:"kind"`
+			Record gatewaycdv1alpha1.StepTransition `json:"record"`
+		}
+		// Skip the other record kinds and lines cut by trimming
+		if json.Unmarshal([]byte(line), &record) != nil || record.Kind != "StepTransition" {
+			continue
+		}
+		transitions = append(transitions, record.Record)
+	}
+	return transitions, nil
+}
+
+// getCanaryAudit returns the control actions applied to a canary deployment,
+// newest first, optionally filtered by action and limited in number
+func (s *Server) getCanaryAudit(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	cluster, cl, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if err := cl.Get(c.Request.Context(), types.NamespacedName{
+		Namespace: namespace,
+		Name:      name,
+	}, &canary); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Canary deployment not found"})
+		return
+	}
+
+	limit := len(canary.Status.Audit)
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+	filter := gatewaycdv1alpha1.ControlActionType(c.Query("action"))
+
+	audit := []gatewaycdv1alpha1.ControlAction{}
+	for i := len(canary.Status.Audit) - 1; i >= 0 && len(audit) < limit; i-- {
+		if action := canary.Status.Audit[i]; filter == "" || action.Action == filter {
+			audit = append(audit, action)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cluster":          cluster,
+		"audit":            audit,
+		"historyConfigMap": canary.Status.HistoryConfigMap,
+	})
+}
+
+// getNamespaceStats returns per-namespace rollout statistics for capacity planning
+func (s *Server) getNamespaceStats(c *gin.Context) {
+	summaries, err := fanOut(s, c, func(ctx context.Context, cluster string, cl client.Client) ([]ClusterNamespaceSummary, error) {
+		clusterSummaries, err := stats.Summarize(ctx, cl)
+		if err != nil {
+			return nil, err
+		}
+		var summaries []ClusterNamespaceSummary
+		for _, summary := range clusterSummaries {
+			summaries = append(summaries, ClusterNamespaceSummary{Cluster: cluster, NamespaceSummary: summary})
+		}
+		return summaries, nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if summaries == nil {
+		summaries = []ClusterNamespaceSummary{}
+	}
+
+	c.JSON(http.StatusOK, summaries)
 }
 
 // healthCheck returns the API health status
@@ -315,5 +684,6 @@ func (s *Server) healthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "healthy",
 		"timestamp": metav1.Now(),
+		"clusters":  s.clusterNames(),
 	})
 }
\ No newline at end of file
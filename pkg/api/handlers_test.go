@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/controller"
+)
+
+func TestGetCanaryHistory(t *testing.T) {
+	canary := &gatewaycdv1alpha1.CanaryDeployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout"},
+		Status: gatewaycdv1alpha1.CanaryDeploymentStatus{
+			History: []gatewaycdv1alpha1.StepTransition{
+				{Step: 2, Weight: 50, Actor: "controller"},
+				{Step: 3, Weight: 100, Actor: "alice"},
+			},
+			HistoryConfigMap: "checkout-history",
+			ChangeMetadata:   &gatewaycdv1alpha1.ChangeMetadata{GitSHA: "abc123"},
+		},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout-history"},
+		Data: map[string]string{controller.HistoryKey: `{"time":null,"kind":"StepTransition","record":{"step":0,"weight":10,"time":null,"actor":"controller"}}
+{"time":null,"kind":"ControlAction","record":{"action":"Pause"}}
+{"time":null,"kind":"StepTransition","record":{"step":1,"weight":20,"time":null,"actor":"controller"}}
+`},
+	}
+	withoutConfigMap := canary.DeepCopy()
+	withoutConfigMap.Name = "cart"
+	withoutConfigMap.Status.HistoryConfigMap = ""
+
+	tests := []struct {
+		name        string
+		path        string
+		wantCode    int
+		wantWeights []int32
+	}{
+		{"status and ConfigMap", "/api/v1/canaries/shop/checkout/history", http.StatusOK, []int32{100, 50, 20, 10}},
+		{"limit", "/api/v1/canaries/shop/checkout/history?limit=3", http.StatusOK, []int32{100, 50, 20}},
+		{"status only", "/api/v1/canaries/shop/cart/history", http.StatusOK, []int32{100, 50}},
+		{"invalid limit", "/api/v1/canaries/shop/checkout/history?limit=0", http.StatusBadRequest, nil},
+		{"unknown canary", "/api/v1/canaries/shop/gone/history", http.StatusNotFound, nil},
+	}
+	s := newTestServer(t, []client.Object{canary, cm, withoutConfigMap})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				History        []gatewaycdv1alpha1.StepTransition `json:"history"`
+				ChangeMetadata *gatewaycdv1alpha1.ChangeMetadata  `json:"changeMetadata"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var weights []int32
+			for _, transition := range resp.History {
+				weights = append(weights, transition.Weight)
+			}
+			if len(weights) != len(tt.wantWeights) {
+				t.Fatalf("history weights = %v, want %v", weights, tt.wantWeights)
+			}
+			for i := range weights {
+				if weights[i] != tt.wantWeights[i] {
+					t.Fatalf("history weights = %v, want %v", weights, tt.wantWeights)
+				}
+			}
+			if resp.ChangeMetadata == nil || resp.ChangeMetadata.GitSHA != "abc123" {
+				t.Errorf("changeMetadata = %+v, want git SHA abc123", resp.ChangeMetadata)
+			}
+		})
+	}
+}
+
+func TestSetCanaryAnnotations(t *testing.T) {
+	canary := &gatewaycdv1alpha1.CanaryDeployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout", Annotations: map[string]string{"team": "payments"}},
+		Spec:       gatewaycdv1alpha1.CanaryDeploymentSpec{TrafficSplit: []gatewaycdv1alpha1.TrafficSplitStep{{Weight: 10}}},
+	}
+	// A full update from a stale read would revert concurrent changes
+	cl := newTestClient(t, canary).WithInterceptorFuncs(interceptor.Funcs{
+		Update: func(ctx context.Context, cl client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			return errors.New("unexpected update")
+		},
+	}).Build()
+	ctx := context.Background()
+
+	if err := setCanaryAnnotations(ctx, cl, "shop", "checkout", map[string]string{"gateway-cd.io/pause": "true"}); err != nil {
+		t.Fatalf("setCanaryAnnotations() failed: %v", err)
+	}
+	var got gatewaycdv1alpha1.CanaryDeployment
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(canary), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Annotations["gateway-cd.io/pause"] != "true" || got.Annotations["team"] != "payments" {
+		t.Errorf("annotations = %v, want the pause annotation added to team", got.Annotations)
+	}
+	if len(got.Spec.TrafficSplit) != 1 {
+		t.Errorf("traffic split = %v, want it unchanged", got.Spec.TrafficSplit)
+	}
+
+	err := setCanaryAnnotations(ctx, cl, "shop", "gone", map[string]string{"gateway-cd.io/pause": "true"})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("setCanaryAnnotations() of a missing canary error = %v, want not found", err)
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// AnnotationAnalysisVerdict carries an external analysis verdict ("pass" or "fail")
+	AnnotationAnalysisVerdict = "gateway-cd.io/analysis-verdict"
+	// AnnotationAnalysisVerdictReason carries the reason reported with the verdict
+	AnnotationAnalysisVerdictReason = "gateway-cd.io/analysis-verdict-reason"
+)
+
+// controlAnnotations maps control actions to the annotation the controller reacts to
+var controlAnnotations = map[string]string{
+	"resume":  "gateway-cd.io/resume",
+	"pause":   "gateway-cd.io/pause",
+	"abort":   "gateway-cd.io/abort",
+	"promote": "gateway-cd.io/promote",
+	"retry":   "gateway-cd.io/retry",
+}
+
+// TriggerRequest applies a control action to a single canary
+type TriggerRequest struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace" binding:"required"`
+	Name      string `json:"name" binding:"required"`
+	Action    string `json:"action" binding:"required"`
+}
+
+// AnalysisVerdictRequest reports the result of an external analysis
+type AnalysisVerdictRequest struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace" binding:"required"`
+	Name      string `json:"name" binding:"required"`
+	Passed    bool   `json:"passed"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// BulkControlRequest applies a control action to every canary matching the
+// selector, in one cluster or in every cluster if Cluster is empty
+type BulkControlRequest struct {
+	Cluster       string `json:"cluster,omitempty"`
+	Namespace     string `json:"namespace,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+	Action        string `json:"action" binding:"required"`
+}
+
+// triggerHook applies a control action to a single canary deployment
+func (s *Server) triggerHook(c *gin.Context) {
+	var req TriggerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	annotation, ok := controlAnnotations[req.Action]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown action %q", req.Action)})
+		return
+	}
+
+	cluster, cl, ok := s.cluster(c, req.Cluster)
+	if !ok {
+		return
+	}
+
+	if err := setCanaryAnnotations(c.Request.Context(), cl, req.Namespace, req.Name, controlRequest(c, annotation, "true")); err != nil {
+		if apierrors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Canary deployment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Action %s applied", req.Action), "cluster": cluster})
+}
+
+// analysisVerdictHook records an external analysis verdict on a canary deployment
+func (s *Server) analysisVerdictHook(c *gin.Context) {
+	var req AnalysisVerdictRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	verdict := "fail"
+	if req.Passed {
+		verdict = "pass"
+	}
+
+	cluster, cl, ok := s.cluster(c, req.Cluster)
+	if !ok {
+		return
+	}
+
+	annotations := map[string]string{
+		AnnotationAnalysisVerdict:       verdict,
+		AnnotationAnalysisVerdictReason: req.Reason,
+	}
+	if err := setCanaryAnnotations(c.Request.Context(), cl, req.Namespace, req.Name, annotations); err != nil {
+		if apierrors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Canary deployment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Analysis verdict recorded", "cluster": cluster})
+}
+
+// bulkControlHook applies a control action to all matching canary deployments
+func (s *Server) bulkControlHook(c *gin.Context) {
+	var req BulkControlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	annotation, ok := controlAnnotations[req.Action]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown action %q", req.Action)})
+		return
+	}
+
+	var listOpts []client.ListOption
+	if req.Namespace != "" {
+		listOpts = append(listOpts, client.InNamespace(req.Namespace))
+	}
+	if req.LabelSelector != "" {
+		selector, err := labels.Parse(req.LabelSelector)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		listOpts = append(listOpts, client.MatchingLabelsSelector{Selector: selector})
+	}
+
+	clusters := s.clusterNames()
+	if req.Cluster != "" {
+		if _, _, ok := s.cluster(c, req.Cluster); !ok {
+			return
+		}
+		clusters = []string{req.Cluster}
+	}
+
+	applied := []string{}
+	failed := map[string]string{}
+	for _, cluster := range clusters {
+		cl := s.clusters[cluster]
+		var canaries gatewaycdv1alpha1.CanaryDeploymentList
+		if err := cl.List(c.Request.Context(), &canaries, listOpts...); err != nil {
+			if len(clusters) == 1 {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			failed[cluster] = err.Error()
+			continue
+		}
+
+		for _, canary := range canaries.Items {
+			key := s.canaryKey(cluster, &canary)
+			if err := setCanaryAnnotations(c.Request.Context(), cl, canary.Namespace, canary.Name, controlRequest(c, annotation, "true")); err != nil {
+				failed[key] = err.Error()
+				continue
+			}
+			applied = append(applied, key)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"applied": applied, "failed": failed})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// callerKey is the context key of the authenticated caller of a request
+type callerKey struct{}
+
+// withCaller returns ctx carrying user, whose identity the impersonating
+// clients assume. A nil user leaves ctx unchanged.
+func withCaller(ctx context.Context, user *authenticationv1.UserInfo) context.Context {
+	if user == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, callerKey{}, user)
+}
+
+// callerFrom returns the authenticated caller carried by ctx, or nil
+func callerFrom(ctx context.Context) *authenticationv1.UserInfo {
+	user, _ := ctx.Value(callerKey{}).(*authenticationv1.UserInfo)
+	return user
+}
+
+// WithImpersonation performs the canary reads and writes of authenticated
+// callers as the callers themselves, with the impersonating clients keyed by
+// cluster name, so the RBAC of each cluster rather than the server's service
+// account decides what a caller can do. Requests without a caller, e.g.
+// inbound webhooks, keep using the server's clients.
+func WithImpersonation(clients map[string]client.Client) Option {
+	return func(s *Server) {
+		s.impersonators = clients
+	}
+}
+
+// ImpersonatingClient creates a client for cfg that impersonates the caller
+// carried by the context of each call, and acts as cfg's own identity for
+// calls without one. It reads live, as a cache would bypass the caller's RBAC.
+func ImpersonatingClient(cfg *rest.Config, scheme *runtime.Scheme) (client.WithWatch, error) {
+	cfg = rest.CopyConfig(cfg)
+	cfg.Wrap(func(next http.RoundTripper) http.RoundTripper {
+		return &impersonatingTransport{next: next}
+	})
+	return client.NewWithWatch(cfg, client.Options{Scheme: scheme})
+}
+
+// impersonatingTransport sets the impersonation headers of the caller in the
+// request context
+type impersonatingTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip sends the request as the caller of its context
+func (t *impersonatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	user := callerFrom(req.Context())
+	if user == nil {
+		return t.next.RoundTrip(req)
+	}
+	extra := map[string][]string{}
+	for key, value := range user.Extra {
+		extra[key] = value
+	}
+	return transport.NewImpersonatingRoundTripper(transport.ImpersonationConfig{
+		UserName: user.Username,
+		UID:      user.UID,
+		Groups:   user.Groups,
+		Extra:    extra,
+	}, t.next).RoundTrip(req)
+}
+
+// clusterClient returns the client of cluster name performing the calls of
+// ctx: the impersonating client when the server impersonates and ctx carries
+// a caller, else the server's own. The cluster must be known.
+func (s *Server) clusterClient(ctx context.Context, name string) client.Client {
+	if cl, ok := s.impersonators[name]; ok && callerFrom(ctx) != nil {
+		return cl
+	}
+	return s.clusters[name]
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// Limits bounds what a single client can ask of the API, so a misbehaving
+// dashboard or script cannot overwhelm the Kubernetes API through it
+type Limits struct {
+	// RequestsPerSecond is the sustained request rate allowed per client IP;
+	// zero disables rate limiting
+	RequestsPerSecond float64
+	// TrustedProxies are the IPs and CIDRs of the proxies whose
+	// X-Forwarded-For header names the client IP. Requests from anywhere
+	// else are keyed on their remote address, so a client cannot pick the IP
+	// it is limited as.
+	TrustedProxies []string
+	// Burst is the number of requests a client may make at once. Defaults to
+	// twice RequestsPerSecond.
+	Burst int
+	// MaxBodyBytes caps request bodies; zero leaves them unbounded
+	MaxBodyBytes int64
+	// RequestTimeout bounds the handling of a request, including the
+	// Kubernetes API calls it makes; zero leaves it unbounded
+	RequestTimeout time.Duration
+	// ClusterTimeout bounds the calls to each cluster of a request fanning
+	// out to several, so one slow cluster is reported unreachable rather than
+	// failing the request; zero leaves them bounded by RequestTimeout only
+	ClusterTimeout time.Duration
+}
+
+// DefaultLimits caps request bodies at 1MiB, requests at 30s and the calls
+// to each cluster at 10s, without rate limiting
+var DefaultLimits = Limits{MaxBodyBytes: 1 << 20, RequestTimeout: 30 * time.Second, ClusterTimeout: 10 * time.Second}
+
+const (
+	// clientIdleTimeout is how long the rate limiter remembers an idle client
+	clientIdleTimeout = 10 * time.Minute
+
+	// readHeaderTimeout bounds how long a client may take to send request
+	// headers, so slow clients cannot hold connections open
+	readHeaderTimeout = 10 * time.Second
+)
+
+// WithLimits sets the per-client rate limit, body size limit and request
+// timeout of the API
+func WithLimits(limits Limits) Option {
+	return func(s *Server) {
+		s.limits = limits
+	}
+}
+
+// trustProxies makes the client IP the remote address of requests, or the
+// address forwarded by a trusted proxy. Invalid proxies trust none.
+func (s *Server) trustProxies() {
+	if err := s.router.SetTrustedProxies(s.limits.TrustedProxies); err != nil {
+		s.router.SetTrustedProxies(nil)
+	}
+}
+
+// limit enforces the server's limits on every request but health checks
+func (s *Server) limit() gin.HandlerFunc {
+	limits := s.limits
+	var limiter *rateLimiter
+	if limits.RequestsPerSecond > 0 {
+		burst := limits.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(limits.RequestsPerSecond * 2))
+		}
+		limiter = newRateLimiter(rate.Limit(limits.RequestsPerSecond), burst)
+	}
+
+	return func(c *gin.Context) {
+		if c.FullPath() == "/api/v1/health" {
+			c.Next()
+			return
+		}
+
+		if limiter != nil {
+			if ok, retryAfter := limiter.allow(c.ClientIP(), time.Now()); !ok {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+				return
+			}
+		}
+
+		if limits.MaxBodyBytes > 0 {
+			if c.Request.ContentLength > limits.MaxBodyBytes {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Request body exceeds %d bytes",
+					limits.MaxBodyBytes)})
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxBodyBytes)
+		}
+
+		if limits.RequestTimeout > 0 {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), limits.RequestTimeout)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimiter keeps a token bucket per client
+type rateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastPrune time.Time
+}
+
+// clientLimiter is the token bucket of a client and when it was last used
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newRateLimiter creates a limiter allowing limit requests per second per
+// client, in bursts of up to burst
+func newRateLimiter(limit rate.Limit, burst int) *rateLimiter {
+	return &rateLimiter{limit: limit, burst: burst, clients: map[string]*clientLimiter{}}
+}
+
+// allow takes a token from the bucket of client, or reports how long until
+// the next one is available
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > clientIdleTimeout {
+		for key, c := range l.clients {
+			if now.Sub(c.lastSeen) > clientIdleTimeout {
+				delete(l.clients, key)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	c, ok := l.clients[client]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[client] = c
+	}
+	c.lastSeen = now
+
+	reservation := c.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newRateLimiter(rate.Limit(1), 2)
+
+	tests := []struct {
+		name      string
+		client    string
+		now       time.Time
+		want      bool
+		wantRetry time.Duration
+	}{
+		{"first of the burst", "10.0.0.1", now, true, 0},
+		{"second of the burst", "10.0.0.1", now, true, 0},
+		{"burst exhausted", "10.0.0.1", now, false, time.Second},
+		{"other client", "10.0.0.2", now, true, 0},
+		{"token refilled", "10.0.0.1", now.Add(time.Second), true, 0},
+		{"idle client forgotten", "10.0.0.2", now.Add(clientIdleTimeout + 2*time.Second), true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, retry := l.allow(tt.client, tt.now)
+			if got != tt.want || retry != tt.wantRetry {
+				t.Errorf("allow() = %v, %s, want %v, %s", got, retry, tt.want, tt.wantRetry)
+			}
+		})
+	}
+	if _, ok := l.clients["10.0.0.1"]; ok {
+		t.Error("rate limiter still holds the idle client 10.0.0.1")
+	}
+}
+
+func TestLimitClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   []string
+		want           []int
+	}{
+		{
+			name:         "forwarded for ignored from untrusted clients",
+			remoteAddr:   "203.0.113.7:4000",
+			forwardedFor: []string{"198.51.100.1", "198.51.100.2"},
+			want:         []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:           "forwarded for of a trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.1.2.3:4000",
+			forwardedFor:   []string{"198.51.100.1", "198.51.100.2"},
+			want:           []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:           "same client behind a trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.1.2.3:4000",
+			forwardedFor:   []string{"198.51.100.1", "198.51.100.1"},
+			want:           []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:           "forwarded for ignored from other proxies",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "192.168.1.1:4000",
+			forwardedFor:   []string{"198.51.100.1", "198.51.100.2"},
+			want:           []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, nil, WithLimits(Limits{RequestsPerSecond: 0.001, Burst: 1, TrustedProxies: tt.trustedProxies}))
+			for i, forwardedFor := range tt.forwardedFor {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/canaries", nil)
+				req.RemoteAddr = tt.remoteAddr
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+				rec := httptest.NewRecorder()
+				s.router.ServeHTTP(rec, req)
+
+				if rec.Code != tt.want[i] {
+					t.Errorf("request %d: status = %d, want %d", i, rec.Code, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// ManagerOptions configures the API server run inside a controller manager
+type ManagerOptions struct {
+	// Addr is the address of the REST API
+	Addr string
+	// GRPCAddr is the address of the gRPC admin API; empty disables it
+	GRPCAddr string
+	// LeaderElection serves the API only on the elected leader. Replicas
+	// that are not leading report the "api" readiness check as failing, so
+	// a Service routes to the leader only.
+	LeaderElection bool
+	// ServerOptions configure the server
+	ServerOptions []Option
+}
+
+// AddToManager runs the API server as a Runnable of mgr. Canaries and the
+// other objects the controller watches are read from the manager's shared
+// cache, with the field indexes of IndexFields; Secrets, ConfigMaps and CRDs
+// are read live. The API's readiness is added to the manager's readyz
+// checks. The manager's scheme must include apiextensionsv1 for the generate
+// endpoint's schema validation.
+func AddToManager(mgr ctrl.Manager, opts ManagerOptions) (*Server, error) {
+	if err := IndexFields(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return nil, fmt.Errorf("failed to index canary deployments: %w", err)
+	}
+	c, err := cachedClient(mgr.GetConfig(), mgr.GetScheme(), mgr.GetRESTMapper(), mgr.GetCache())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API client: %w", err)
+	}
+
+	server := NewServer(c, append(opts.ServerOptions, WithFieldIndexes())...)
+	runnable := &serverRunnable{
+		server:         server,
+		addr:           opts.Addr,
+		grpcAddr:       opts.GRPCAddr,
+		leaderElection: opts.LeaderElection,
+	}
+	if err := mgr.Add(runnable); err != nil {
+		return nil, fmt.Errorf("failed to add API server: %w", err)
+	}
+	if err := mgr.AddReadyzCheck("api", runnable.readyz); err != nil {
+		return nil, fmt.Errorf("failed to add API readiness check: %w", err)
+	}
+	return server, nil
+}
+
+// serverRunnable serves the REST and gRPC APIs of a Server until the
+// manager stops
+type serverRunnable struct {
+	server         *Server
+	addr           string
+	grpcAddr       string
+	leaderElection bool
+	ready          atomic.Bool
+}
+
+// NeedLeaderElection reports whether the API only runs on the leader
+func (r *serverRunnable) NeedLeaderElection() bool {
+	return r.leaderElection
+}
+
+// Start serves the APIs until ctx is done, then drains them as Server.Serve
+// does
+func (r *serverRunnable) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", r.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", r.addr, err)
+	}
+	var grpcListener net.Listener
+	if r.grpcAddr != "" {
+		grpcListener, err = net.Listen("tcp", r.grpcAddr)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen on %s: %w", r.grpcAddr, err)
+		}
+	}
+
+	r.ready.Store(true)
+	defer r.ready.Store(false)
+	return r.server.serve(ctx, listener, grpcListener)
+}
+
+// readyz fails until the APIs are listening, and on replicas that are not
+// leading when the API needs leader election
+func (r *serverRunnable) readyz(_ *http.Request) error {
+	if !r.ready.Load() || r.server.draining.Load() {
+		return errors.New("API server is not serving")
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// RequestIDHeader carries the ID of a request, set by the caller or generated
+// by the RequestID middleware
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the gin context key of the request ID
+const requestIDKey = "requestID"
+
+var (
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gatewaycd_api_requests_total",
+		Help: "Number of API server requests by route and status code.",
+	}, []string{"method", "route", "code"})
+
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gatewaycd_api_request_duration_seconds",
+		Help:    "Latency of API server requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(apiRequests, apiRequestDuration)
+}
+
+// WithMiddleware adds handlers run on every request, in order, before CORS,
+// the request limits and the routes, e.g. to authenticate callers against an
+// identity provider the server doesn't support. Repeated options append.
+func WithMiddleware(handlers ...gin.HandlerFunc) Option {
+	return func(s *Server) {
+		s.middleware = append(s.middleware, handlers...)
+	}
+}
+
+// RequestID tags each request with the caller's X-Request-ID, or a generated
+// one, and returns it in the response header of the same name
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestIDFrom returns the ID the RequestID middleware gave the request, or
+// "" without it
+func RequestIDFrom(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// newRequestID returns a random 128-bit hex ID
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// RequestLogger logs each request with its status, latency, client IP and,
+// after the RequestID middleware, its request ID
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		line := []string{
+			c.Request.Method, path, strconv.Itoa(c.Writer.Status()),
+			time.Since(start).Round(time.Microsecond).String(), c.ClientIP(),
+		}
+		if id := RequestIDFrom(c); id != "" {
+			line = append(line, "request_id="+id)
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			line = append(line, strings.TrimSpace(errs))
+		}
+		log.Print(strings.Join(line, " "))
+	}
+}
+
+// HTTPMetrics records the count and latency of requests by route template in
+// the gatewaycd_api_requests_total and gatewaycd_api_request_duration_seconds
+// metrics of the controller-runtime registry
+func HTTPMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// The route template keeps the cardinality bounded; paths matching
+		// no route share one label
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		apiRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+		apiRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
+
+// Gzip compresses the responses of callers accepting gzip encoding
+func Gzip() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+		c.Header("Vary", "Accept-Encoding")
+		writer := &gzipWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer writer.close()
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, encoding := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
+// gzipWriter compresses the response body. The gzip stream is only started
+// on the first write, so bodiless responses such as 204 and 304 stay empty.
+type gzipWriter struct {
+	gin.ResponseWriter
+	gz *gzip.Writer
+}
+
+// Write compresses data, starting the gzip stream unless the handler encoded
+// the body itself
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if w.gz == nil {
+		if w.Header().Get("Content-Encoding") != "" {
+			return w.ResponseWriter.Write(data)
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	return w.gz.Write(data)
+}
+
+// WriteString compresses s
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends the data compressed so far
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close ends the gzip stream
+func (w *gzipWriter) close() {
+	if w.gz != nil {
+		_ = w.gz.Close()
+	}
+}
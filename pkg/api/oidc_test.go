@@ -0,0 +1,295 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testProvider is an OIDC provider serving its discovery document and the
+// signing keys it holds
+type testProvider struct {
+	server *httptest.Server
+
+	mu   sync.Mutex
+	keys map[string]crypto.Signer
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	p := &testProvider{keys: map[string]crypto.Signer{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": p.server.URL, "jwks_uri": p.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(p.jwks())
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// addKey generates a signing key of kty with the given ID
+func (p *testProvider) addKey(t *testing.T, kid, kty string) {
+	t.Helper()
+	var key crypto.Signer
+	var err error
+	switch kty {
+	case "RSA":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "EC":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys[kid] = key
+}
+
+// jwks returns the JSON Web Key Set of the provider's public keys
+func (p *testProvider) jwks() map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	encode := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+
+	var keys []map[string]string
+	for kid, key := range p.keys {
+		switch k := key.Public().(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]string{"kid": kid, "kty": "RSA", "n": encode(k.N), "e": encode(big.NewInt(int64(k.E)))})
+		case *ecdsa.PublicKey:
+			keys = append(keys, map[string]string{"kid": kid, "kty": "EC", "crv": "P-256", "x": encode(k.X), "y": encode(k.Y)})
+		}
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// sign returns a JWT of claims signed with the key kid using alg
+func (p *testProvider) sign(t *testing.T, kid, alg string, claims map[string]interface{}) string {
+	t.Helper()
+	p.mu.Lock()
+	key := p.keys[kid]
+	p.mu.Unlock()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := crypto.SHA256
+	digest := hash.New()
+	digest.Write([]byte(signed))
+	var signature []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest.Sum(nil))
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest.Sum(nil))
+		if err == nil {
+			signature = make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	p := newTestProvider(t)
+	p.addKey(t, "rsa", "RSA")
+	p.addKey(t, "ec", "EC")
+
+	now := time.Now()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":    p.server.URL,
+			"aud":    "gateway-cd",
+			"sub":    "1234",
+			"email":  "alice@example.com",
+			"groups": []string{"devs", "ops"},
+			"exp":    now.Add(time.Hour).Unix(),
+			"iat":    now.Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+	valid := p.sign(t, "rsa", "RS256", claims(nil))
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name       string
+		token      string
+		wantUser   string
+		wantGroups []string
+		wantErr    string
+	}{
+		{
+			name:       "RS256",
+			token:      valid,
+			wantUser:   "oidc:alice@example.com",
+			wantGroups: []string{"devs", "ops"},
+		},
+		{
+			name:       "ES256",
+			token:      p.sign(t, "ec", "ES256", claims(nil)),
+			wantUser:   "oidc:alice@example.com",
+			wantGroups: []string{"devs", "ops"},
+		},
+		{
+			name:       "issuer with trailing slash",
+			token:      p.sign(t, "rsa", "RS256", claims(map[string]interface{}{"iss": p.server.URL + "/"})),
+			wantUser:   "oidc:alice@example.com",
+			wantGroups: []string{"devs", "ops"},
+		},
+		{
+			name:       "audience list",
+			token:      p.sign(t, "rsa", "RS256", claims(map[string]interface{}{"aud": []string{"other", "gateway-cd"}})),
+			wantUser:   "oidc:alice@example.com",
+			wantGroups: []string{"devs", "ops"},
+		},
+		{
+			name:     "no groups",
+			token:    p.sign(t, "rsa", "RS256", claims(map[string]interface{}{"groups": nil})),
+			wantUser: "oidc:alice@example.com",
+		},
+		{
+			name:    "other issuer",
+			token:   p.sign(t, "rsa", "RS256", claims(map[string]interface{}{"iss": "https://evil.example.com"})),
+			wantErr: "token issued by",
+		},
+		{
+			name:    "other audience",
+			token:   p.sign(t, "rsa", "RS256", claims(map[string]interface{}{"aud": []string{"other"}})),
+			wantErr: `token audience does not include "gateway-cd"`,
+		},
+		{
+			name:    "expired",
+			token:   p.sign(t, "rsa", "RS256", claims(map[string]interface{}{"exp": now.Add(-time.Minute).Unix()})),
+			wantErr: "token expired",
+		},
+		{
+			name:    "no expiry",
+			token:   p.sign(t, "rsa", "RS256", claims(map[string]interface{}{"exp": nil})),
+			wantErr: "token expired",
+		},
+		{
+			name:    "not yet valid",
+			token:   p.sign(t, "rsa", "RS256", claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})),
+			wantErr: "token not yet valid",
+		},
+		{
+			name:    "no username",
+			token:   p.sign(t, "rsa", "RS256", claims(map[string]interface{}{"email": nil})),
+			wantErr: `token has no "email" claim`,
+		},
+		{
+			name:    "tampered claims",
+			token:   parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"email":"admin@example.com"}`)) + "." + parts[2],
+			wantErr: errInvalidToken.Error(),
+		},
+		{
+			name:    "algorithm of another key type",
+			token:   base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","kid":"rsa"}`)) + "." + parts[1] + "." + parts[2],
+			wantErr: errInvalidToken.Error(),
+		},
+		{
+			name:    "unsupported algorithm",
+			token:   base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa"}`)) + "." + parts[1] + ".",
+			wantErr: `unsupported token algorithm "none"`,
+		},
+		{
+			name:    "unknown key",
+			token:   base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"other"}`)) + "." + parts[1] + "." + parts[2],
+			wantErr: `unknown signing key "other"`,
+		},
+		{
+			name:    "not a JWT",
+			token:   "opaque-token",
+			wantErr: errInvalidToken.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewOIDCAuthenticator(p.server.URL+"/", "gateway-cd", "email", "oidc:", "groups")
+			user, err := a.Authenticate(context.Background(), tt.token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Authenticate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() failed: %v", err)
+			}
+			if user.Username != tt.wantUser || user.UID != "1234" {
+				t.Errorf("Authenticate() user = %q (uid %q), want %q (uid 1234)", user.Username, user.UID, tt.wantUser)
+			}
+			if strings.Join(user.Groups, ",") != strings.Join(tt.wantGroups, ",") {
+				t.Errorf("Authenticate() groups = %v, want %v", user.Groups, tt.wantGroups)
+			}
+		})
+	}
+}
+
+func TestOIDCAuthenticatorKeyRotation(t *testing.T) {
+	p := newTestProvider(t)
+	p.addKey(t, "old", "RSA")
+	a := NewOIDCAuthenticator(p.server.URL, "gateway-cd", "sub", "", "groups")
+	claims := map[string]interface{}{"iss": p.server.URL, "aud": "gateway-cd", "sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+
+	if _, err := a.Authenticate(context.Background(), p.sign(t, "old", "RS256", claims)); err != nil {
+		t.Fatalf("Authenticate() with the old key failed: %v", err)
+	}
+
+	// The key set was just fetched, so the new key isn't looked up yet
+	p.addKey(t, "new", "EC")
+	token := p.sign(t, "new", "ES256", claims)
+	if _, err := a.Authenticate(context.Background(), token); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("Authenticate() error = %v, want unknown signing key", err)
+	}
+
+	a.lastRefresh = time.Now().Add(-time.Minute)
+	user, err := a.Authenticate(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Authenticate() with the new key failed: %v", err)
+	}
+	if user.Username != "alice" {
+		t.Errorf("Authenticate() user = %q, want alice", user.Username)
+	}
+}
+
+func TestOIDCAuthenticatorDiscoveryError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	a := NewOIDCAuthenticator(server.URL, "gateway-cd", "sub", "", "groups")
+
+	_, err := a.Authenticate(context.Background(), "eyJhbGciOiJSUzI1NiIsImtpZCI6InJzYSJ9.e30.c2ln")
+	if err == nil || !strings.Contains(err.Error(), "failed to discover OIDC provider") {
+		t.Errorf("Authenticate() error = %v, want discovery error", err)
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/types"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/controller"
+)
+
+// planCanaryDeployment simulates the rollout of a canary without writing to
+// the cluster. The request body may hold a CanaryDeployment to plan instead
+// of the stored one, validating a change before it is applied.
+func (s *Server) planCanaryDeployment(c *gin.Context) {
+	cluster, cl, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if c.Request.ContentLength != 0 {
+		if !s.bindCanary(c, cluster, cl, &canary) {
+			return
+		}
+		canary.Namespace = c.Param("namespace")
+		canary.Name = c.Param("name")
+	} else if err := cl.Get(c.Request.Context(), types.NamespacedName{
+		Namespace: c.Param("namespace"),
+		Name:      c.Param("name"),
+	}, &canary); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Canary deployment not found"})
+		return
+	}
+
+	plan, err := controller.Plan(c.Request.Context(), cl, &canary)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
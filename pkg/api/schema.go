@@ -0,0 +1,281 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// CanaryDeploymentCRDName is the CustomResourceDefinition whose schema request bodies are validated against
+const CanaryDeploymentCRDName = "canarydeployments.gateway-cd.io"
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get
+
+// FieldError is a field-level validation error of a request body
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// canarySchema returns the OpenAPI schema of the served CanaryDeployment
+// version in a cluster, fetched once per cluster
+func (s *Server) canarySchema(ctx context.Context, cluster string, cl client.Client) (*apiextensionsv1.JSONSchemaProps, error) {
+	s.schemasMu.Lock()
+	defer s.schemasMu.Unlock()
+
+	if schema, ok := s.schemas[cluster]; ok {
+		return schema, nil
+	}
+
+	var crd apiextensionsv1.CustomResourceDefinition
+	if err := cl.Get(ctx, types.NamespacedName{Name: CanaryDeploymentCRDName}, &crd); err != nil {
+		return nil, fmt.Errorf("failed to get CustomResourceDefinition %s: %w", CanaryDeploymentCRDName, err)
+	}
+	for _, version := range crd.Spec.Versions {
+		if version.Name == gatewaycdv1alpha1.GroupVersion.Version && version.Schema != nil && version.Schema.OpenAPIV3Schema != nil {
+			s.schemas[cluster] = version.Schema.OpenAPIV3Schema
+			return version.Schema.OpenAPIV3Schema, nil
+		}
+	}
+	return nil, fmt.Errorf("CustomResourceDefinition %s has no schema for version %s", CanaryDeploymentCRDName, gatewaycdv1alpha1.GroupVersion.Version)
+}
+
+// bindCanary decodes a canary deployment from the request body after
+// validating it against the CRD schema. It writes the error response and
+// returns false when the body is invalid.
+func (s *Server) bindCanary(c *gin.Context, cluster string, cl client.Client, canary *gatewaycdv1alpha1.CanaryDeployment) bool {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return s.decodeCanary(c, cluster, cl, body, canary)
+}
+
+// decodeCanary decodes a canary deployment from JSON after validating it
+// against the CRD schema. It writes the error response and returns false
+// when the canary is invalid.
+func (s *Server) decodeCanary(c *gin.Context, cluster string, cl client.Client, body []byte, canary *gatewaycdv1alpha1.CanaryDeployment) bool {
+	var obj interface{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	// The apiserver validates again, so an unavailable schema only loses the detailed errors
+	schema, err := s.canarySchema(c.Request.Context(), cluster, cl)
+	if err != nil {
+		log.Printf("Skipping request validation: %v", err)
+	} else if errs := validateSchema(nil, obj, schema); len(errs) > 0 {
+		fields := make([]FieldError, 0, len(errs))
+		for _, e := range errs {
+			fields = append(fields, FieldError{Field: e.Field, Reason: e.ErrorBody()})
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  fmt.Sprintf("CanaryDeployment is invalid: %s", errs.ToAggregate().Error()),
+			"fields": fields,
+		})
+		return false
+	}
+
+	if err := json.Unmarshal(body, canary); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// validateSchema validates a decoded JSON value against an OpenAPI schema.
+// Unknown fields are rejected unless the schema preserves them. Object
+// metadata is left to the apiserver.
+func validateSchema(path *field.Path, value interface{}, schema *apiextensionsv1.JSONSchemaProps) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if value == nil {
+		if !schema.Nullable {
+			allErrs = append(allErrs, field.Invalid(path, value, "must not be null"))
+		}
+		return allErrs
+	}
+
+	if schema.XIntOrString {
+		switch v := value.(type) {
+		case string:
+		case float64:
+			if v != math.Trunc(v) {
+				allErrs = append(allErrs, field.TypeInvalid(path, value, "must be an integer or a string"))
+			}
+		default:
+			allErrs = append(allErrs, field.TypeInvalid(path, value, "must be an integer or a string"))
+		}
+		return allErrs
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return append(allErrs, field.TypeInvalid(path, value, "must be of type object"))
+		}
+		allErrs = append(allErrs, validateObject(path, obj, schema)...)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return append(allErrs, field.TypeInvalid(path, value, "must be of type array"))
+		}
+		if schema.MinItems != nil && int64(len(items)) < *schema.MinItems {
+			allErrs = append(allErrs, field.Invalid(path, len(items), fmt.Sprintf("must have at least %d items", *schema.MinItems)))
+		}
+		if schema.MaxItems != nil && int64(len(items)) > *schema.MaxItems {
+			allErrs = append(allErrs, field.TooMany(path, len(items), int(*schema.MaxItems)))
+		}
+		if schema.Items != nil && schema.Items.Schema != nil {
+			for i, item := range items {
+				allErrs = append(allErrs, validateSchema(path.Index(i), item, schema.Items.Schema)...)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return append(allErrs, field.TypeInvalid(path, value, "must be of type string"))
+		}
+		if schema.MinLength != nil && int64(len(str)) < *schema.MinLength {
+			allErrs = append(allErrs, field.Invalid(path, str, fmt.Sprintf("must be at least %d characters long", *schema.MinLength)))
+		}
+		if schema.MaxLength != nil && int64(len(str)) > *schema.MaxLength {
+			allErrs = append(allErrs, field.TooLong(path, str, int(*schema.MaxLength)))
+		}
+		if schema.Pattern != "" {
+			if re, err := regexp.Compile(schema.Pattern); err == nil && !re.MatchString(str) {
+				allErrs = append(allErrs, field.Invalid(path, str, fmt.Sprintf("must match %q", schema.Pattern)))
+			}
+		}
+	case "integer", "number":
+		num, ok := value.(float64)
+		if !ok || (schema.Type == "integer" && num != math.Trunc(num)) {
+			return append(allErrs, field.TypeInvalid(path, value, fmt.Sprintf("must be of type %s", schema.Type)))
+		}
+		if schema.Minimum != nil && (num < *schema.Minimum || schema.ExclusiveMinimum && num == *schema.Minimum) {
+			allErrs = append(allErrs, field.Invalid(path, value, fmt.Sprintf("must be greater than or equal to %v", *schema.Minimum)))
+		}
+		if schema.Maximum != nil && (num > *schema.Maximum || schema.ExclusiveMaximum && num == *schema.Maximum) {
+			allErrs = append(allErrs, field.Invalid(path, value, fmt.Sprintf("must be less than or equal to %v", *schema.Maximum)))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return append(allErrs, field.TypeInvalid(path, value, "must be of type boolean"))
+		}
+	}
+
+	if len(schema.Enum) > 0 {
+		allowed := make([]string, 0, len(schema.Enum))
+		found := false
+		for _, e := range schema.Enum {
+			var v interface{}
+			if err := json.Unmarshal(e.Raw, &v); err != nil {
+				continue
+			}
+			if reflect.DeepEqual(v, value) {
+				found = true
+				break
+			}
+			allowed = append(allowed, fmt.Sprint(v))
+		}
+		if !found {
+			allErrs = append(allErrs, field.NotSupported(path, value, allowed))
+		}
+	}
+	return allErrs
+}
+
+// validateObject validates the properties of an object and rejects unknown fields
+func validateObject(path *field.Path, obj map[string]interface{}, schema *apiextensionsv1.JSONSchemaProps) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			allErrs = append(allErrs, field.Required(path.Child(name), ""))
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := obj[key]
+		if path == nil && key == "metadata" {
+			continue
+		}
+		if property, ok := schema.Properties[key]; ok {
+			allErrs = append(allErrs, validateSchema(path.Child(key), value, &property)...)
+			continue
+		}
+		if additional := schema.AdditionalProperties; additional != nil {
+			if additional.Schema != nil {
+				allErrs = append(allErrs, validateSchema(path.Key(key), value, additional.Schema)...)
+				continue
+			}
+			if additional.Allows {
+				continue
+			}
+		}
+		if schema.XPreserveUnknownFields != nil && *schema.XPreserveUnknownFields {
+			continue
+		}
+		allErrs = append(allErrs, unknownField(path.Child(key), key, schema))
+	}
+	return allErrs
+}
+
+// unknownField reports a field missing from the schema, suggesting the known
+// field it most likely misspells
+func unknownField(path *field.Path, key string, schema *apiextensionsv1.JSONSchemaProps) *field.Error {
+	best, bestDistance := "", 3
+	for name := range schema.Properties {
+		if d := editDistance(key, name); d < bestDistance || (d == bestDistance && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	if best != "" {
+		return field.Forbidden(path, fmt.Sprintf("unknown field, did you mean %q?", best))
+	}
+	return field.Forbidden(path, "unknown field")
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
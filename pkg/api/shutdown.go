@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Shutdown configures how the API server stops
+type Shutdown struct {
+	// Delay is how long the server keeps serving after it starts failing its
+	// readiness check, so Service endpoints and load balancers stop routing
+	// to it before it closes its listeners
+	Delay time.Duration
+	// Timeout is how long in-flight requests may take to complete before
+	// their connections are closed
+	Timeout time.Duration
+}
+
+// DefaultShutdown is the shutdown of servers without WithShutdown
+var DefaultShutdown = Shutdown{Delay: 5 * time.Second, Timeout: 30 * time.Second}
+
+// WithShutdown sets how the server drains when it stops
+func WithShutdown(shutdown Shutdown) Option {
+	return func(s *Server) {
+		s.shutdown = shutdown
+	}
+}
+
+// Serve serves the REST API on addr, and the gRPC admin API on grpcAddr
+// when set, until ctx is done. It then fails the readiness check, keeps
+// serving for the shutdown delay, closes the gRPC watch streams and drains
+// in-flight requests, so rolling restarts drop no requests.
+func (s *Server) Serve(ctx context.Context, addr, grpcAddr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	var grpcListener net.Listener
+	if grpcAddr != "" {
+		grpcListener, err = net.Listen("tcp", grpcAddr)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen on %s: %w", grpcAddr, err)
+		}
+	}
+	return s.serve(ctx, listener, grpcListener)
+}
+
+// serve serves the REST API on listener and the gRPC admin API on
+// grpcListener, when not nil, until ctx is done and shuts down as Serve
+// describes
+func (s *Server) serve(ctx context.Context, listener, grpcListener net.Listener) error {
+	logger := log.FromContext(ctx).WithName("api")
+
+	httpServer := &http.Server{Handler: s.router, ReadHeaderTimeout: readHeaderTimeout}
+	errs := make(chan error, 2)
+	go func() {
+		errs <- httpServer.Serve(listener)
+	}()
+	logger.Info("Serving API", "addr", listener.Addr().String())
+
+	var grpcServer *grpc.Server
+	if grpcListener != nil {
+		grpcServer = s.GRPCServer()
+		go func() {
+			errs <- grpcServer.Serve(grpcListener)
+		}()
+		logger.Info("Serving gRPC admin API", "addr", grpcListener.Addr().String())
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-errs:
+	}
+
+	// Fail readiness and keep serving until traffic moves to other replicas
+	s.draining.Store(true)
+	httpServer.SetKeepAlivesEnabled(false)
+	if serveErr == nil && s.shutdown.Delay > 0 {
+		logger.Info("Shutting down, waiting for traffic to drain", "delay", s.shutdown.Delay)
+		select {
+		case <-time.After(s.shutdown.Delay):
+		case serveErr = <-errs:
+		}
+	}
+
+	// Watch streams never complete on their own, end them so clients reconnect
+	s.stopOnce.Do(func() { close(s.stopping) })
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdown.Timeout)
+	defer cancel()
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		defer func() {
+			select {
+			case <-stopped:
+			case <-shutdownCtx.Done():
+				grpcServer.Stop()
+			}
+		}()
+	}
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to drain API requests: %w", err)
+	}
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) && !errors.Is(serveErr, grpc.ErrServerStopped) {
+		return serveErr
+	}
+	return nil
+}
+
+// readyCheck fails once the server is shutting down, so it is taken out of
+// its Service's endpoints while it drains
+func (s *Server) readyCheck(c *gin.Context) {
+	if s.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":    "shutting down",
+			"timestamp": metav1.Now(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ready",
+		"timestamp": metav1.Now(),
+	})
+}
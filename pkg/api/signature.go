@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of
+	// "timestamp.delivery.METHOD/path.body", prefixed with "sha256="
+	SignatureHeader = "X-Gateway-CD-Signature"
+	// SignatureTimestampHeader carries the Unix time the request was signed at
+	SignatureTimestampHeader = "X-Gateway-CD-Timestamp"
+	// SignatureDeliveryHeader carries an ID unique to each signed request
+	SignatureDeliveryHeader = "X-Gateway-CD-Delivery"
+
+	// webhookSecretKey is the key inside the webhook Secret holding the shared secret
+	webhookSecretKey = "secret"
+
+	// maxSignatureAge rejects replayed webhook requests
+	maxSignatureAge = time.Minute * 5
+	// maxDeliveryIDLength bounds the delivery IDs remembered per request
+	maxDeliveryIDLength = 128
+	// maxSignedBodyBytes caps the body read before the signature is verified
+	maxSignedBodyBytes = 1 << 20
+)
+
+var errWebhookSecretNotConfigured = errors.New("webhook secret not configured")
+
+// verifySignature rejects requests that are not signed with the shared
+// webhook secret. The signature covers the timestamp, delivery ID, method and
+// path, so a signed body cannot be replayed later or against another hook,
+// and a delivery ID already seen while its signature is valid is rejected.
+func (s *Server) verifySignature() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret, err := s.loadWebhookSecret(c.Request.Context())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Webhook signing secret not configured"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSignedBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Request body exceeds %d bytes",
+					tooLarge.Limit)})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now()
+		timestamp, delivery := c.GetHeader(SignatureTimestampHeader), c.GetHeader(SignatureDeliveryHeader)
+		if !validSignature(secret, c.Request.Method, c.Request.URL.Path, body, timestamp, delivery,
+			c.GetHeader(SignatureHeader), now) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid request signature"})
+			return
+		}
+		signed, _ := strconv.ParseInt(timestamp, 10, 64)
+		if !s.deliveries.record(delivery, time.Unix(signed, 0).Add(maxSignatureAge), now) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Request %q already delivered", delivery)})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// loadWebhookSecret reads the shared secret from the configured Secret
+func (s *Server) loadWebhookSecret(ctx context.Context) ([]byte, error) {
+	if s.webhookSecret.Name == "" {
+		return nil, errWebhookSecretNotConfigured
+	}
+
+	var secret corev1.Secret
+	if err := s.client.Get(ctx, s.webhookSecret, &secret); err != nil {
+		return nil, err
+	}
+
+	key := secret.Data[webhookSecretKey]
+	if len(key) == 0 {
+		return nil, errWebhookSecretNotConfigured
+	}
+	return key, nil
+}
+
+// validSignature compares the signature header against the HMAC of
+// "timestamp.delivery.METHOD/path.body" and checks that the request was
+// signed recently
+func validSignature(secret []byte, method, path string, body []byte, timestamp, delivery, header string, now time.Time) bool {
+	if delivery == "" || len(delivery) > maxDeliveryIDLength {
+		return false
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return false
+	}
+
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s.%s.%s%s.", timestamp, delivery, method, path)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// deliveryLog remembers the delivery IDs of verified webhook requests until
+// their signatures expire
+type deliveryLog struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func newDeliveryLog() *deliveryLog {
+	return &deliveryLog{seen: map[string]time.Time{}}
+}
+
+// record remembers id until expires and reports whether it wasn't seen before
+func (l *deliveryLog) record(id string, expires, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for seen, at := range l.seen {
+		if now.After(at) {
+			delete(l.seen, seen)
+		}
+	}
+	if _, ok := l.seen[id]; ok {
+		return false
+	}
+	l.seen[id] = expires
+	return true
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+var testSecret = []byte("s3cret")
+
+// sign returns the signature header of a webhook request
+func sign(secret []byte, timestamp, delivery, method, path, body string) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s.%s.%s%s.%s", timestamp, delivery, method, path, body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	body := `{"namespace":"x","name":"foo","action":"abort"}`
+	valid := sign(testSecret, ts, "d1", http.MethodPost, "/api/v1/hooks/trigger", body)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		timestamp string
+		delivery  string
+		header    string
+		want      bool
+	}{
+		{"valid", http.MethodPost, "/api/v1/hooks/trigger", body, ts, "d1", valid, true},
+		{"other path", http.MethodPost, "/api/v1/hooks/bulk", body, ts, "d1", valid, false},
+		{"other method", http.MethodPut, "/api/v1/hooks/trigger", body, ts, "d1", valid, false},
+		{"tampered body", http.MethodPost, "/api/v1/hooks/trigger", strings.Replace(body, "foo", "bar", 1), ts, "d1", valid, false},
+		{"other timestamp", http.MethodPost, "/api/v1/hooks/trigger", body, strconv.FormatInt(now.Unix()-1, 10), "d1", valid, false},
+		{"missing timestamp", http.MethodPost, "/api/v1/hooks/trigger", body, "", "d1", valid, false},
+		{"other delivery", http.MethodPost, "/api/v1/hooks/trigger", body, ts, "d2", valid, false},
+		{"missing delivery", http.MethodPost, "/api/v1/hooks/trigger", body, ts, "",
+			sign(testSecret, ts, "", http.MethodPost, "/api/v1/hooks/trigger", body), false},
+		{"delivery too long", http.MethodPost, "/api/v1/hooks/trigger", body, ts, strings.Repeat("d", maxDeliveryIDLength+1),
+			sign(testSecret, ts, strings.Repeat("d", maxDeliveryIDLength+1), http.MethodPost, "/api/v1/hooks/trigger", body), false},
+		{"missing prefix", http.MethodPost, "/api/v1/hooks/trigger", body, ts, "d1", strings.TrimPrefix(valid, "sha256="), false},
+		{"invalid hex", http.MethodPost, "/api/v1/hooks/trigger", body, ts, "d1", "sha256=zz", false},
+		{"other secret", http.MethodPost, "/api/v1/hooks/trigger", body, ts, "d1",
+			sign([]byte("other"), ts, "d1", http.MethodPost, "/api/v1/hooks/trigger", body), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validSignature(testSecret, tt.method, tt.path, []byte(tt.body), tt.timestamp, tt.delivery, tt.header, now)
+			if got != tt.want {
+				t.Errorf("validSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidSignatureAge(t *testing.T) {
+	signed := time.Unix(1700000000, 0)
+	ts := strconv.FormatInt(signed.Unix(), 10)
+	header := sign(testSecret, ts, "d1", http.MethodPost, "/api/v1/hooks/trigger", "{}")
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"just signed", signed, true},
+		{"within max age", signed.Add(maxSignatureAge - time.Second), true},
+		{"replayed later", signed.Add(maxSignatureAge + time.Second), false},
+		{"signed in the future", signed.Add(-maxSignatureAge - time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validSignature(testSecret, http.MethodPost, "/api/v1/hooks/trigger", []byte("{}"), ts, "d1", header, tt.now)
+			if got != tt.want {
+				t.Errorf("validSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestServer returns a server on a fake client holding objs
+func newTestServer(t *testing.T, objs []client.Object, opts ...Option) *Server {
+	t.Helper()
+	return NewServer(newTestClient(t, objs...).Build(), opts...)
+}
+
+func TestVerifySignature(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "gateway-cd", Name: "webhook"},
+		Data:       map[string][]byte{webhookSecretKey: testSecret},
+	}
+	canary := &gatewaycdv1alpha1.CanaryDeployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "foo"},
+	}
+	body := `{"namespace":"x","name":"foo","action":"abort"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	tests := []struct {
+		name      string
+		configure bool
+		path      string
+		body      string
+		timestamp string
+		signature string
+		want      int
+	}{
+		{
+			name:      "signed trigger",
+			configure: true,
+			path:      "/api/v1/hooks/trigger",
+			body:      body,
+			timestamp: now,
+			signature: sign(testSecret, now, "d1", http.MethodPost, "/api/v1/hooks/trigger", body),
+			want:      http.StatusOK,
+		},
+		{
+			name:      "unsigned",
+			configure: true,
+			path:      "/api/v1/hooks/trigger",
+			body:      body,
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "trigger signature replayed on bulk",
+			configure: true,
+			path:      "/api/v1/hooks/bulk",
+			body:      body,
+			timestamp: now,
+			signature: sign(testSecret, now, "d1", http.MethodPost, "/api/v1/hooks/trigger", body),
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "stale",
+			configure: true,
+			path:      "/api/v1/hooks/trigger",
+			body:      body,
+			timestamp: "1700000000",
+			signature: sign(testSecret, "1700000000", "d1", http.MethodPost, "/api/v1/hooks/trigger", body),
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "body too large",
+			configure: true,
+			path:      "/api/v1/hooks/trigger",
+			body:      strings.Repeat("a", maxSignedBodyBytes+1),
+			want:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:      "secret not configured",
+			path:      "/api/v1/hooks/trigger",
+			body:      body,
+			timestamp: now,
+			signature: sign(testSecret, now, "d1", http.MethodPost, "/api/v1/hooks/trigger", body),
+			want:      http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Unbounded server limits leave the body cap to the middleware
+			opts := []Option{WithLimits(Limits{})}
+			if tt.configure {
+				opts = append(opts, WithWebhookSecret("gateway-cd", "webhook"))
+			}
+			s := newTestServer(t, []client.Object{secret, canary.DeepCopy()}, opts...)
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(SignatureTimestampHeader, tt.timestamp)
+			req.Header.Set(SignatureDeliveryHeader, "d1")
+			req.Header.Set(SignatureHeader, tt.signature)
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestVerifySignatureReplay(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "gateway-cd", Name: "webhook"},
+		Data:       map[string][]byte{webhookSecretKey: testSecret},
+	}
+	canary := &gatewaycdv1alpha1.CanaryDeployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "foo"},
+	}
+	s := newTestServer(t, []client.Object{secret, canary}, WithWebhookSecret("gateway-cd", "webhook"))
+	body := `{"namespace":"x","name":"foo","action":"pause"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	tests := []struct {
+		name     string
+		delivery string
+		want     int
+	}{
+		{"first delivery", "d1", http.StatusOK},
+		{"replayed delivery", "d1", http.StatusConflict},
+		{"next delivery", "d2", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/hooks/trigger", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(SignatureTimestampHeader, now)
+			req.Header.Set(SignatureDeliveryHeader, tt.delivery)
+			req.Header.Set(SignatureHeader, sign(testSecret, now, tt.delivery, http.MethodPost, "/api/v1/hooks/trigger", body))
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeliveryLog(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newDeliveryLog()
+
+	if !l.record("d1", now.Add(maxSignatureAge), now) {
+		t.Fatal("record() of a new delivery = false, want true")
+	}
+	if l.record("d1", now.Add(maxSignatureAge), now.Add(time.Minute)) {
+		t.Error("record() of a delivery seen within its signature age = true, want false")
+	}
+	if !l.record("d1", now.Add(2*maxSignatureAge), now.Add(maxSignatureAge+time.Second)) {
+		t.Error("record() of a delivery whose signature expired = false, want true")
+	}
+	if len(l.seen) != 1 {
+		t.Errorf("remembered %d deliveries, want 1", len(l.seen))
+	}
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/notifications"
+)
+
+const (
+	// SlackSignatureHeader carries Slack's "v0=" prefixed HMAC-SHA256 request signature
+	SlackSignatureHeader = "X-Slack-Signature"
+	// SlackTimestampHeader carries the Unix time Slack signed the request at
+	SlackTimestampHeader = "X-Slack-Request-Timestamp"
+
+	// AnnotationRequestedBy records who requested the pending control action
+	AnnotationRequestedBy = "gateway-cd.io/requested-by"
+	// AnnotationRequestedVia records where the pending control action was
+	// requested, for the audit trail of the canary
+	AnnotationRequestedVia = "gateway-cd.io/requested-via"
+
+	// slackSigningSecretKey is the key inside the Slack Secret holding the app signing secret
+	slackSigningSecretKey = "signing-secret"
+
+	// slackMaxRequestAge rejects replayed interaction requests
+	slackMaxRequestAge = time.Minute * 5
+)
+
+// slackInteraction is the part of a Slack block_actions payload the API server uses
+type slackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	ResponseURL string `json:"response_url"`
+}
+
+// WithSlackSigningSecret sets the Secret whose "signing-secret" key verifies
+// requests from the Slack app that posts approval buttons
+func WithSlackSigningSecret(namespace, name string) Option {
+	return func(s *Server) {
+		s.slackSecret = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+}
+
+// verifySlackSignature rejects requests that are not signed with the Slack app signing secret
+func (s *Server) verifySlackSignature() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret, err := s.loadSlackSigningSecret(c.Request.Context())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Slack signing secret not configured"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if !validSlackSignature(secret, body, c.GetHeader(SlackTimestampHeader), c.GetHeader(SlackSignatureHeader), time.Now()) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid request signature"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// loadSlackSigningSecret reads the Slack app signing secret from the configured Secret
+func (s *Server) loadSlackSigningSecret(ctx context.Context) ([]byte, error) {
+	if s.slackSecret.Name == "" {
+		return nil, errWebhookSecretNotConfigured
+	}
+
+	var secret corev1.Secret
+	if err := s.client.Get(ctx, s.slackSecret, &secret); err != nil {
+		return nil, err
+	}
+
+	key := secret.Data[slackSigningSecretKey]
+	if len(key) == 0 {
+		return nil, errWebhookSecretNotConfigured
+	}
+	return key, nil
+}
+
+// validSlackSignature checks a Slack v0 signature over "v0:timestamp:body"
+// and that the request was signed recently
+func validSlackSignature(secret, body []byte, timestamp, header string, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > slackMaxRequestAge || age < -slackMaxRequestAge {
+		return false
+	}
+
+	signature, ok := strings.CutPrefix(header, "v0=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// slackInteractionHook resumes or aborts a canary paused for approval when
+// an approval button is clicked, recording the Slack user as the requester
+func (s *Server) slackInteractionHook(c *gin.Context) {
+	var payload slackInteraction
+	if err := json.Unmarshal([]byte(c.PostForm("payload")), &payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interaction payload"})
+		return
+	}
+	if payload.Type != "block_actions" || len(payload.Actions) == 0 {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	action := payload.Actions[0]
+	var annotation, verb string
+	switch action.ActionID {
+	case notifications.SlackActionApprove:
+		annotation, verb = controlAnnotations["resume"], "approved"
+	case notifications.SlackActionAbort:
+		annotation, verb = controlAnnotations["abort"], "aborted"
+	default:
+		c.Status(http.StatusOK)
+		return
+	}
+
+	var approval notifications.SlackApproval
+	if err := json.Unmarshal([]byte(action.Value), &approval); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action value"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if err := s.client.Get(ctx, types.NamespacedName{Namespace: approval.Namespace, Name: approval.Name}, &canary); err != nil {
+		if apierrors.IsNotFound(err) {
+			s.respondSlack(payload.ResponseURL, false, fmt.Sprintf("Canary %s/%s no longer exists", approval.Namespace, approval.Name))
+			c.Status(http.StatusOK)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if canary.Status.Phase != gatewaycdv1alpha1.CanaryDeploymentPhasePaused || canary.Status.CurrentStep != approval.Step {
+		s.respondSlack(payload.ResponseURL, false, fmt.Sprintf("Canary %s/%s is no longer waiting for approval at step %d",
+			approval.Namespace, approval.Name, approval.Step+1))
+		c.Status(http.StatusOK)
+		return
+	}
+
+	if canary.Spec.Approvals != nil && annotation == controlAnnotations["resume"] {
+		s.respondSlack(payload.ResponseURL, false, fmt.Sprintf("Canary %s/%s requires an Approval for step %d, create one with kubectl",
+			approval.Namespace, approval.Name, approval.Step+1))
+		c.Status(http.StatusOK)
+		return
+	}
+
+	requestedBy := "slack:" + payload.User.ID
+	if payload.User.Username != "" {
+		requestedBy = fmt.Sprintf("slack:%s (%s)", payload.User.Username, payload.User.ID)
+	}
+	if err := setCanaryAnnotations(ctx, s.client, approval.Namespace, approval.Name, map[string]string{
+		annotation:             "true",
+		AnnotationRequestedBy:  requestedBy,
+		AnnotationRequestedVia: string(gatewaycdv1alpha1.ControlSourceSlack),
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.respondSlack(payload.ResponseURL, true, fmt.Sprintf("*%s/%s*: step %d %s by <@%s>",
+		approval.Namespace, approval.Name, approval.Step+1, verb, payload.User.ID))
+	c.Status(http.StatusOK)
+}
+
+// respondSlack posts a reply to the interaction's response URL, replacing the
+// approval message or, if replace is false, answering only the clicking user.
+// It runs in the background since Slack expects the interaction acknowledged
+// within three seconds.
+func (s *Server) respondSlack(responseURL string, replace bool, text string) {
+	if responseURL == "" {
+		return
+	}
+	responseType := "ephemeral"
+	if replace {
+		responseType = "in_channel"
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"text":             text,
+		"replace_original": replace,
+		"response_type":    responseType,
+	})
+	if err != nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, "POST", responseURL, bytes.NewReader(body))
+		if err != nil {
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Printf("Failed to respond to Slack interaction: %v", err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
@@ -0,0 +1,59 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AnalysisTemplateKind is the kind of namespaced analysis templates
+	AnalysisTemplateKind = "AnalysisTemplate"
+	// ClusterAnalysisTemplateKind is the kind of cluster-scoped analysis templates
+	ClusterAnalysisTemplateKind = "ClusterAnalysisTemplate"
+)
+
+// AnalysisTemplateRef references an AnalysisTemplate or ClusterAnalysisTemplate
+type AnalysisTemplateRef struct {
+	// Name of the template
+	Name string `json:"name"`
+	// Kind is AnalysisTemplate (default, in the canary namespace) or ClusterAnalysisTemplate
+	Kind string `json:"kind,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AnalysisTemplate is a reusable analysis policy referenced by CanaryDeployments in its namespace
+type AnalysisTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AnalysisSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AnalysisTemplateList contains a list of AnalysisTemplate
+type AnalysisTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AnalysisTemplate `json:"items"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// ClusterAnalysisTemplate is a reusable analysis policy referenced by CanaryDeployments in any namespace
+type ClusterAnalysisTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AnalysisSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterAnalysisTemplateList contains a list of ClusterAnalysisTemplate
+type ClusterAnalysisTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterAnalysisTemplate `json:"items"`
+}
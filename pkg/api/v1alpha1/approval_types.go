@@ -0,0 +1,76 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApprovalDecision is the outcome of an approval
+type ApprovalDecision string
+
+const (
+	// ApprovalDecisionApproved lets the rollout continue past the paused step
+	ApprovalDecisionApproved ApprovalDecision = "Approved"
+	// ApprovalDecisionRejected aborts the rollout and rolls the canary back
+	ApprovalDecisionRejected ApprovalDecision = "Rejected"
+)
+
+// ApprovalsSpec gates paused steps on Approval records instead of the resume annotation
+type ApprovalsSpec struct {
+	// Required is the number of distinct approvers a paused step needs. Defaults to 1.
+	Required int32 `json:"required,omitempty"`
+	// Approvers are the users whose approvals count. Empty allows anyone who
+	// can create Approvals in the canary namespace.
+	Approvers []string `json:"approvers,omitempty"`
+}
+
+// ApprovalSpec defines the decision on a paused step
+type ApprovalSpec struct {
+	// CanaryName is the CanaryDeployment in the approval's namespace
+	CanaryName string `json:"canaryName"`
+	// Step is the index of the traffic split step the decision applies to
+	Step int32 `json:"step"`
+	// Decision is Approved or Rejected. Defaults to Approved.
+	Decision ApprovalDecision `json:"decision,omitempty"`
+	// Comment explains the decision
+	Comment string `json:"comment,omitempty"`
+	// Approver is the user who created the approval, recorded by the admission webhook
+	Approver string `json:"approver,omitempty"`
+}
+
+// ApprovalRecord is an approval counted by the controller, kept in the canary status
+type ApprovalRecord struct {
+	// Step is the index of the traffic split step that was approved or rejected
+	Step int32 `json:"step"`
+	// Approver is the user who decided
+	Approver string `json:"approver"`
+	// Decision is Approved or Rejected
+	Decision ApprovalDecision `json:"decision"`
+	// Comment explains the decision
+	Comment string `json:"comment,omitempty"`
+	// Time is when the approval was created
+	Time metav1.Time `json:"time"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Canary",type="string",JSONPath=".spec.canaryName"
+//+kubebuilder:printcolumn:name="Step",type="integer",JSONPath=".spec.step"
+//+kubebuilder:printcolumn:name="Decision",type="string",JSONPath=".spec.decision"
+//+kubebuilder:printcolumn:name="Approver",type="string",JSONPath=".spec.approver"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// Approval records who approved or rejected a paused step of a CanaryDeployment
+type Approval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ApprovalSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ApprovalList contains a list of Approval
+type ApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Approval `json:"items"`
+}
@@ -0,0 +1,5 @@
+package v1alpha1
+
+// Hub marks v1alpha1, the storage version, as the version other
+// CanaryDeployment versions convert through
+func (*CanaryDeployment) Hub() {}
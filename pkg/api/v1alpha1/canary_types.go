@@ -1,6 +1,8 @@
 package v1alpha1
 
 import (
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -18,6 +20,70 @@ const (
 	CanaryDeploymentPhaseRollingBack CanaryDeploymentPhase = "RollingBack"
 )
 
+const (
+	// ConditionTypeReady is True once the canary has been promoted
+	ConditionTypeReady = "Ready"
+	// ConditionTypeProgressing is True while the rollout is moving traffic
+	ConditionTypeProgressing = "Progressing"
+	// ConditionTypeAnalysisSucceeded reflects the outcome of the latest analysis
+	ConditionTypeAnalysisSucceeded = "AnalysisSucceeded"
+	// ConditionTypeRolledBack is True once the canary has been rolled back
+	ConditionTypeRolledBack = "RolledBack"
+	// ConditionTypePausedByUser is True while the rollout is paused through the pause annotation
+	ConditionTypePausedByUser = "PausedByUser"
+	// ConditionTypeAnalysisSkipped is True once any step of the rollout advanced without analysis
+	ConditionTypeAnalysisSkipped = "AnalysisSkipped"
+	// ConditionTypeRouteConflict is True while another progressive delivery
+	// controller manages one of the routes and the rollout refuses to start
+	ConditionTypeRouteConflict = "RouteConflict"
+	// ConditionTypeCapacityInsufficient is True when the namespace quotas or
+	// the schedulable nodes have no room for the canary pods
+	ConditionTypeCapacityInsufficient = "CapacityInsufficient"
+	// ConditionTypeRoutesProgrammed is True once the gateway implementation
+	// serves the weights of the current step
+	ConditionTypeRoutesProgrammed = "RoutesProgrammed"
+	// ConditionTypeQueued is True while the rollout waits for its team's
+	// concurrent rollout quota
+	ConditionTypeQueued = "Queued"
+	// ConditionTypeDryRun is True while a dry-run canary holds a plan of its rollout
+	ConditionTypeDryRun = "DryRun"
+	// ConditionTypeDegraded is True while the rollout has not advanced within
+	// its progress deadline
+	ConditionTypeDegraded = "Degraded"
+	// ConditionTypeManualOverride is True while an operator holds the canary
+	// at a weight outside the steps
+	ConditionTypeManualOverride = "ManualOverride"
+	// ConditionTypeWaiting is True while the rollout holds its weight outside
+	// the windows of its schedule
+	ConditionTypeWaiting = "Waiting"
+	// ConditionTypeBlocked is True while another CanaryDeployment is rolling
+	// out on one of the routes and the rollout waits for it to finish
+	ConditionTypeBlocked = "Blocked"
+	// ConditionTypeReconciling is True while the rollout moves towards its
+	// desired state, following the kstatus conventions Flux checks health with
+	ConditionTypeReconciling = "Reconciling"
+	// ConditionTypeStalled is True while the rollout cannot reach its desired
+	// state without intervention, following the kstatus conventions
+	ConditionTypeStalled = "Stalled"
+)
+
+// HealthStatus is the health of a canary in the terms of the gitops-engine
+// health checks Argo CD uses
+// +kubebuilder:validation:Enum=Healthy;Progressing;Degraded;Suspended
+type HealthStatus string
+
+const (
+	// HealthStatusHealthy is a promoted canary
+	HealthStatusHealthy HealthStatus = "Healthy"
+	// HealthStatusProgressing is a rollout moving traffic or rolling back
+	HealthStatusProgressing HealthStatus = "Progressing"
+	// HealthStatusDegraded is a rolled back rollout or one past its progress deadline
+	HealthStatusDegraded HealthStatus = "Degraded"
+	// HealthStatusSuspended is a rollout paused, waiting for a window or
+	// blocked by a quota or another rollout
+	HealthStatusSuspended HealthStatus = "Suspended"
+)
+
 // TrafficSplitStep defines a traffic split configuration
 type TrafficSplitStep struct {
 	// Weight is the percentage of traffic to route to canary version (0-100)
@@ -26,18 +92,285 @@ type TrafficSplitStep struct {
 	Duration string `json:"duration,omitempty"`
 	// Pause indicates whether to pause at this step for manual approval
 	Pause bool `json:"pause,omitempty"`
+	// Tenants are tenant IDs routed to the canary from this step on, matched
+	// exactly against the tenant header
+	Tenants []string `json:"tenants,omitempty"`
+	// TenantPatterns are regular expressions matched against the tenant header
+	// to route more tenants to the canary from this step on
+	TenantPatterns []string `json:"tenantPatterns,omitempty"`
+	// FractionalWeight is a percentage below the integer weight granularity
+	// (e.g. "0.1") routed to the canary by combining an integer weight with a
+	// match on the hash bucket header. Weight must be 0 when it is set.
+	FractionalWeight string `json:"fractionalWeight,omitempty"`
+	// PreStep hooks run in order before the weight of the step is written,
+	// e.g. to warm the caches of the canary
+	PreStep []StepHook `json:"preStep,omitempty"`
+	// PostStep hooks run in order once the gateway serves the weight of the
+	// step, before its pause or analysis, e.g. smoke tests
+	PostStep []StepHook `json:"postStep,omitempty"`
 }
 
-// AnalysisTemplate defines success criteria for canary analysis
-type AnalysisTemplate struct {
+// AnalysisSpec defines success criteria for canary analysis
+type AnalysisSpec struct {
 	// Metrics to evaluate during canary analysis
 	Metrics []AnalysisMetric `json:"metrics,omitempty"`
 	// SuccessRate is the minimum success rate threshold (0.0-1.0)
 	SuccessRate float64 `json:"successRate,omitempty"`
 	// MaxLatency is the maximum acceptable latency in milliseconds
 	MaxLatency int32 `json:"maxLatency,omitempty"`
+	// MetricsProfile selects the built-in success rate and latency queries
+	// for the metrics of a mesh or gateway (Istio, Linkerd,
+	// NGINXGatewayFabric or EnvoyGateway). Defaults to the generic
+	// http_requests_total and http_request_duration_seconds metrics.
+	MetricsProfile MetricsProfile `json:"metricsProfile,omitempty"`
 	// AnalysisInterval is how often to run analysis
 	AnalysisInterval string `json:"analysisInterval,omitempty"`
+	// SuccessfulIntervals is the number of passed analysis intervals a step
+	// needs before it advances when AnalysisInterval is set. Defaults to the
+	// step duration divided by the analysis interval.
+	SuccessfulIntervals int32 `json:"successfulIntervals,omitempty"`
+	// FailureLimit is the number of consecutive failed analysis runs that
+	// trigger a rollback. Defaults to 1.
+	FailureLimit int32 `json:"failureLimit,omitempty"`
+	// Smoothing takes the verdict of an analysis run over the last runs of
+	// the rollout, so momentary metric blips at low traffic don't fail it
+	Smoothing *AnalysisSmoothing `json:"smoothing,omitempty"`
+	// ConsecutiveErrors is the number of consecutive analysis runs that could
+	// not be completed before the canary is rolled back. Unset retries forever.
+	ConsecutiveErrors int32 `json:"consecutiveErrors,omitempty"`
+	// ProviderUnavailablePolicy is applied when the metrics provider is
+	// unavailable (Retry, Skip, Pause or Rollback). Defaults to Retry.
+	ProviderUnavailablePolicy ProviderUnavailablePolicy `json:"providerUnavailablePolicy,omitempty"`
+	// Provider overrides the controller's metrics provider, e.g. to query the
+	// Prometheus instance of the canary's team
+	Provider *ProviderSpec `json:"provider,omitempty"`
+	// Traces analyses canary spans in a trace backend (Tempo or Jaeger)
+	Traces *TraceAnalysis `json:"traces,omitempty"`
+	// PodHealth rolls the canary back on crash-looping, restarting,
+	// OOMKilled or not ready canary pods, without waiting for metrics
+	PodHealth *PodHealthCheck `json:"podHealth,omitempty"`
+	// PostPromotion keeps analysing the canary at full traffic for a soak
+	// period after the last step, and rolls it back if it degrades, before
+	// the rollout succeeds and the stable workload is released
+	PostPromotion *PostPromotionAnalysis `json:"postPromotion,omitempty"`
+}
+
+// PostPromotionAnalysis is the soak period of a canary at full traffic.
+// Each run uses the thresholds of the analysis, and the pod health check
+// keeps running.
+type PostPromotionAnalysis struct {
+	// Duration is how long the canary soaks at full traffic, e.g. "30m"
+	Duration string `json:"duration"`
+	// Interval is how often analysis runs during the soak period. Defaults
+	// to the analysis interval, or 1m without one.
+	Interval string `json:"interval,omitempty"`
+}
+
+// PodHealthCheck rolls the canary back on Kubernetes signals of failing
+// canary pods. The pods are checked while the rollout progresses,
+// independently of the analysis interval and the metrics provider.
+// CrashLoopBackOff always fails the canary.
+type PodHealthCheck struct {
+	// MaxRestarts is the number of container restarts of the canary pods
+	// during the rollout that rolls the canary back. Defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	MaxRestarts int32 `json:"maxRestarts,omitempty"`
+	// NotReadyTimeout is how long a canary pod may have containers that are
+	// not ready before the canary is rolled back. Defaults to 5m.
+	NotReadyTimeout string `json:"notReadyTimeout,omitempty"`
+	// AllowOOMKills leaves OOMKilled canary containers to MaxRestarts
+	// instead of rolling back on the first one
+	AllowOOMKills bool `json:"allowOOMKills,omitempty"`
+}
+
+// AnalysisSmoothing takes the verdict of analysis over a window of runs,
+// either by averaging their values or by counting the passed runs
+type AnalysisSmoothing struct {
+	// Window is the number of most recent analysis runs the verdict is taken over
+	// +kubebuilder:validation:Minimum=2
+	// +kubebuilder:validation:Maximum=20
+	Window int32 `json:"window"`
+	// MinPassed is the number of runs in the window that must pass (K of M).
+	// When unset, the thresholds are checked against the values averaged
+	// over the window instead.
+	// +kubebuilder:validation:Minimum=1
+	MinPassed *int32 `json:"minPassed,omitempty"`
+}
+
+// TraceAnalysis defines thresholds evaluated on the spans of the canary version
+type TraceAnalysis struct {
+	// Service is the service name of canary spans. Defaults to the service name.
+	Service string `json:"service,omitempty"`
+	// Tags are span or resource attributes that identify canary spans,
+	// e.g. version: canary
+	Tags map[string]string `json:"tags,omitempty"`
+	// MaxErrorRate is the maximum ratio of error spans (0.0-1.0)
+	MaxErrorRate float64 `json:"maxErrorRate,omitempty"`
+	// MaxP95DurationMs is the maximum p95 span duration in milliseconds
+	MaxP95DurationMs int32 `json:"maxP95DurationMs,omitempty"`
+	// Lookback is how far back spans are searched. Defaults to 5m.
+	Lookback string `json:"lookback,omitempty"`
+	// Limit is the maximum number of traces fetched per analysis. Defaults to 500.
+	Limit int32 `json:"limit,omitempty"`
+}
+
+// MetricsProfile is a set of built-in analysis queries for the metrics a
+// mesh or gateway exports
+// +kubebuilder:validation:Enum=Istio;Linkerd;NGINXGatewayFabric;EnvoyGateway
+type MetricsProfile string
+
+const (
+	// MetricsProfileIstio queries istio_requests_total and
+	// istio_request_duration_milliseconds reported by the destination
+	MetricsProfileIstio MetricsProfile = "Istio"
+	// MetricsProfileLinkerd queries the response_total and
+	// response_latency_ms of the Linkerd proxies
+	MetricsProfileLinkerd MetricsProfile = "Linkerd"
+	// MetricsProfileNGINXGatewayFabric queries the upstream metrics NGINX
+	// Gateway Fabric exports with NGINX Plus
+	MetricsProfileNGINXGatewayFabric MetricsProfile = "NGINXGatewayFabric"
+	// MetricsProfileEnvoyGateway queries the upstream cluster metrics of
+	// Envoy Gateway's proxies
+	MetricsProfileEnvoyGateway MetricsProfile = "EnvoyGateway"
+)
+
+// ProviderType is a metrics provider implementation
+// +kubebuilder:validation:Enum=Prometheus;Tempo;Jaeger;CloudMonitoring
+type ProviderType string
+
+const (
+	ProviderTypePrometheus      ProviderType = "Prometheus"
+	ProviderTypeTempo           ProviderType = "Tempo"
+	ProviderTypeJaeger          ProviderType = "Jaeger"
+	ProviderTypeCloudMonitoring ProviderType = "CloudMonitoring"
+)
+
+// ProviderSpec connects the analysis of a canary to a metrics provider of its own
+type ProviderSpec struct {
+	// Type is the provider implementation (Prometheus, Tempo, Jaeger or CloudMonitoring)
+	Type ProviderType `json:"type"`
+	// Address is the base URL of the provider, https://monitoring.googleapis.com for CloudMonitoring
+	Address string `json:"address"`
+	// Project is the Google Cloud project whose metrics a CloudMonitoring provider queries
+	Project string `json:"project,omitempty"`
+	// SecretRef references a Secret in the canary namespace with the
+	// credentials: a "token" key sent as a bearer token, or "username" and
+	// "password" keys sent as basic auth
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+	// InsecureSkipVerify disables TLS certificate verification
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// Compatibility adapts a Prometheus provider to a Prometheus-compatible
+	// backend such as VictoriaMetrics, Thanos Query or Mimir
+	Compatibility *PrometheusCompatibility `json:"compatibility,omitempty"`
+}
+
+// PrometheusBackend is the server behind the address of a Prometheus provider
+// +kubebuilder:validation:Enum=Prometheus;VictoriaMetrics;Thanos;Mimir
+type PrometheusBackend string
+
+const (
+	PrometheusBackendPrometheus      PrometheusBackend = "Prometheus"
+	PrometheusBackendVictoriaMetrics PrometheusBackend = "VictoriaMetrics"
+	PrometheusBackendThanos          PrometheusBackend = "Thanos"
+	PrometheusBackendMimir           PrometheusBackend = "Mimir"
+)
+
+// PrometheusCompatibility handles the quirks of a Prometheus-compatible
+// backend: Thanos queries deduplicate replicas and reject partial responses,
+// and VictoriaMetrics queries bypass its response cache so the latest samples
+// of the canary are analysed
+type PrometheusCompatibility struct {
+	// Backend is the server behind the address. Defaults to Prometheus.
+	Backend PrometheusBackend `json:"backend,omitempty"`
+	// TenantID is the tenant queried on a multi-tenant Thanos or Mimir, sent
+	// in the THANOS-TENANT or X-Scope-OrgID header
+	TenantID string `json:"tenantID,omitempty"`
+	// Headers are sent with every query, e.g. the headers of a query proxy
+	Headers map[string]string `json:"headers,omitempty"`
+	// LookbackDelta is how far back a query looks for the latest sample of a
+	// series, e.g. "5m" for targets scraped less often than the backend's
+	// default. Sent as lookback_delta, or max_lookback on VictoriaMetrics;
+	// Mimir doesn't support it.
+	LookbackDelta string `json:"lookbackDelta,omitempty"`
+	// PartialResponse accepts Thanos responses missing the data of
+	// unavailable stores, which are rejected by default so the canary is
+	// never judged on partial data
+	PartialResponse bool `json:"partialResponse,omitempty"`
+}
+
+// ProviderUnavailablePolicy decides how analysis proceeds without a healthy metrics provider
+type ProviderUnavailablePolicy string
+
+const (
+	// ProviderUnavailablePolicyRetry holds the current step and retries later
+	ProviderUnavailablePolicyRetry ProviderUnavailablePolicy = "Retry"
+	// ProviderUnavailablePolicySkip treats the analysis as passed
+	ProviderUnavailablePolicySkip ProviderUnavailablePolicy = "Skip"
+	// ProviderUnavailablePolicyPause pauses the rollout for manual approval
+	ProviderUnavailablePolicyPause ProviderUnavailablePolicy = "Pause"
+	// ProviderUnavailablePolicyRollback rolls the canary back
+	ProviderUnavailablePolicyRollback ProviderUnavailablePolicy = "Rollback"
+)
+
+// RollbackMode is what a rollback tears down beyond the canary's traffic
+// +kubebuilder:validation:Enum=trafficOnly;scaleDownCanary;deleteCanaryResources
+type RollbackMode string
+
+const (
+	// RollbackModeTrafficOnly routes all traffic back to stable and leaves
+	// the canary running
+	RollbackModeTrafficOnly RollbackMode = "trafficOnly"
+	// RollbackModeScaleDownCanary also scales the canary Deployment to zero
+	// replicas, restored when the next rollout starts
+	RollbackModeScaleDownCanary RollbackMode = "scaleDownCanary"
+	// RollbackModeDeleteCanaryResources also scales the canary Deployment to
+	// zero and deletes the canary Service and NetworkPolicies the controller
+	// created, recreated when the next rollout starts
+	RollbackModeDeleteCanaryResources RollbackMode = "deleteCanaryResources"
+)
+
+// RollbackFailurePolicy decides how a rollback proceeds when its teardown fails
+// +kubebuilder:validation:Enum=retry;ignore
+type RollbackFailurePolicy string
+
+const (
+	// RollbackFailurePolicyRetry keeps the canary RollingBack and retries the teardown
+	RollbackFailurePolicyRetry RollbackFailurePolicy = "retry"
+	// RollbackFailurePolicyIgnore completes the rollback and reports the
+	// failed teardown in an event
+	RollbackFailurePolicyIgnore RollbackFailurePolicy = "ignore"
+)
+
+// RollbackSpec configures what a rollback tears down beyond the canary's traffic
+type RollbackSpec struct {
+	// Mode is what the rollback tears down (trafficOnly, scaleDownCanary or
+	// deleteCanaryResources). Defaults to trafficOnly.
+	Mode RollbackMode `json:"mode,omitempty"`
+	// FailurePolicy decides whether the rollback retries a failed teardown
+	// (retry) or completes without it (ignore). Defaults to retry.
+	FailurePolicy RollbackFailurePolicy `json:"failurePolicy,omitempty"`
+	// Steps lower the canary weight gradually before all traffic returns to
+	// stable, e.g. so connection pools and caches downstream drain. The
+	// weights must decrease; steps at or above the canary weight the
+	// rollback starts from are skipped.
+	Steps []RollbackStep `json:"steps,omitempty"`
+}
+
+// RollbackStep holds a canary weight for a while during a gradual rollback
+type RollbackStep struct {
+	// Weight is the percentage of traffic to the canary
+	Weight int32 `json:"weight"`
+	// Duration is how long the step holds its weight. Defaults to 30s.
+	Duration string `json:"duration,omitempty"`
+}
+
+// RollbackStepStatus is the progress of a gradual rollback
+type RollbackStepStatus struct {
+	// Step is the index of the current step in spec.rollback.steps, or their
+	// number once all of them completed
+	Step int32 `json:"step"`
+	// StartedTime is when the current step's weight was applied
+	StartedTime *metav1.Time `json:"startedTime,omitempty"`
 }
 
 // AnalysisMetric defines a metric to monitor during canary analysis
@@ -50,8 +383,33 @@ type AnalysisMetric struct {
 	Threshold float64 `json:"threshold"`
 	// Operator is the comparison operator (>, <, >=, <=, ==, !=)
 	Operator string `json:"operator"`
+	// Aggregation evaluates Query as a range query over Range and reduces
+	// its points to one value, so analysis covers the whole window rather
+	// than one instant. Unset evaluates Query as an instant query.
+	Aggregation RangeAggregation `json:"aggregation,omitempty"`
+	// Range is the window of a range query, ending when the analysis runs.
+	// Defaults to the analysis interval, or else the current step's duration.
+	Range string `json:"range,omitempty"`
+	// Resolution is the time between the points of a range query. Defaults
+	// to a sixtieth of the range, at least 15s.
+	Resolution string `json:"resolution,omitempty"`
 }
 
+// RangeAggregation reduces the points of a range query to one value
+// +kubebuilder:validation:Enum=avg;min;max;last;p50;p90;p95;p99
+type RangeAggregation string
+
+const (
+	RangeAggregationAvg  RangeAggregation = "avg"
+	RangeAggregationMin  RangeAggregation = "min"
+	RangeAggregationMax  RangeAggregation = "max"
+	RangeAggregationLast RangeAggregation = "last"
+	RangeAggregationP50  RangeAggregation = "p50"
+	RangeAggregationP90  RangeAggregation = "p90"
+	RangeAggregationP95  RangeAggregation = "p95"
+	RangeAggregationP99  RangeAggregation = "p99"
+)
+
 // CanaryDeploymentSpec defines the desired state of CanaryDeployment
 type CanaryDeploymentSpec struct {
 	// TargetRef references the target workload for canary deployment
@@ -63,17 +421,619 @@ type CanaryDeploymentSpec struct {
 	// Gateway configuration for traffic management
 	Gateway GatewayRef `json:"gateway"`
 
-	// TrafficSplit defines the traffic splitting strategy
-	TrafficSplit []TrafficSplitStep `json:"trafficSplit"`
+	// TemplateRef references a CanaryTemplate providing the traffic split
+	// steps, analysis and notifications the canary doesn't set itself
+	TemplateRef *CanaryTemplateRef `json:"templateRef,omitempty"`
+
+	// TrafficSplit defines the traffic splitting strategy. Required unless
+	// TemplateRef provides the steps.
+	TrafficSplit []TrafficSplitStep `json:"trafficSplit,omitempty"`
+
+	// TenantHeader is the request header identifying the tenant when steps list
+	// tenants to ramp by customer instead of by weight. Defaults to X-Tenant-ID.
+	TenantHeader string `json:"tenantHeader,omitempty"`
+
+	// BucketHeader is the request header carrying a hash bucket in [0, Buckets),
+	// set upstream from e.g. a user or request ID, used by fractional weight
+	// steps. Defaults to X-Canary-Bucket.
+	BucketHeader string `json:"bucketHeader,omitempty"`
+
+	// Buckets is the number of hash buckets in BucketHeader. Defaults to 100.
+	Buckets int32 `json:"buckets,omitempty"`
 
 	// Analysis defines success criteria and rollback conditions
-	Analysis AnalysisTemplate `json:"analysis,omitempty"`
+	Analysis AnalysisSpec `json:"analysis,omitempty"`
+
+	// AnalysisTemplateRef references a shared analysis policy. Fields set in
+	// Analysis override the template and metrics are merged by name.
+	AnalysisTemplateRef *AnalysisTemplateRef `json:"analysisTemplateRef,omitempty"`
 
 	// AutoPromote automatically promotes canary to stable if analysis succeeds
 	AutoPromote bool `json:"autoPromote,omitempty"`
 
 	// SkipAnalysis skips canary analysis (useful for testing)
 	SkipAnalysis bool `json:"skipAnalysis,omitempty"`
+
+	// DryRun keeps the canary Pending and records the simulated rollout
+	// instead of touching routes, workloads or other cluster objects
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Interval is how often the controller checks on the rollout while it
+	// progresses, e.g. 2s on a dev cluster or 30s on a large one. Defaults to
+	// the controller's --requeue-interval.
+	Interval string `json:"interval,omitempty"`
+
+	// ProgressDeadlineSeconds is how long the rollout may stay Pending or at
+	// a step without advancing before it is marked Degraded. Waiting for
+	// manual approval doesn't count.
+	// +kubebuilder:validation:Minimum=1
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+
+	// RollbackOnProgressDeadline rolls the canary back when it exceeds the
+	// progress deadline instead of only marking it Degraded
+	RollbackOnProgressDeadline bool `json:"rollbackOnProgressDeadline,omitempty"`
+
+	// TTLSecondsAfterFinished deletes the canary this long after it Succeeded
+	// or Failed, with the resources the controller created for it. Deletion
+	// restores the routes like any deletion of the canary. Unset keeps it.
+	// Canaries generated from a Deployment ignore it.
+	// +kubebuilder:validation:Minimum=0
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+
+	// Schedule limits the hours in which traffic steps advance. Outside its
+	// windows the canary holds its current weight.
+	Schedule *ScheduleSpec `json:"schedule,omitempty"`
+
+	// Mirror copies production traffic to the canary without serving its
+	// responses and runs analysis on it before the first traffic split step
+	Mirror bool `json:"mirror,omitempty"`
+
+	// MirrorDuration is how long traffic is mirrored before analysis (default 5m)
+	MirrorDuration string `json:"mirrorDuration,omitempty"`
+
+	// TimeSlice alternates the canary between exposure and zero weight for a
+	// number of cycles before the traffic split steps start
+	TimeSlice *TimeSliceSpec `json:"timeSlice,omitempty"`
+
+	// ABTest makes the assignment of users to the canary sticky, by an
+	// assignment cookie or a hash bucket header, instead of weighted per request
+	ABTest *ABTestSpec `json:"abTest,omitempty"`
+
+	// SessionAffinity keeps clients the canary served on the canary for the
+	// rest of the rollout, so stateful flows don't bounce between versions
+	SessionAffinity *SessionAffinitySpec `json:"sessionAffinity,omitempty"`
+
+	// Hooks are Jobs run in order at points of the rollout, e.g. a schema
+	// migration before the canary gets traffic and its reversal on rollback
+	Hooks []HookStep `json:"hooks,omitempty"`
+
+	// PreRolloutCheck smoke tests the canary Service before the first
+	// traffic shift and fails the canary unless it passes
+	PreRolloutCheck *PreRolloutCheck `json:"preRolloutCheck,omitempty"`
+
+	// PropagateRollbackReason annotates the target workload and emits an Event
+	// on it with the rollback reason when the canary is rolled back
+	PropagateRollbackReason bool `json:"propagateRollbackReason,omitempty"`
+
+	// RevertOnRollback restores the target Deployment's pod template to the
+	// last stable revision on rollback instead of only routing traffic away
+	RevertOnRollback bool `json:"revertOnRollback,omitempty"`
+
+	// Rollback configures what a rollback tears down beyond the canary's
+	// traffic, e.g. scaling the canary Deployment down
+	Rollback *RollbackSpec `json:"rollback,omitempty"`
+
+	// CloneNetworkPolicies copies the NetworkPolicies selecting the stable pods
+	// to the canary pods, selected by the canary Service, so the canary keeps
+	// the stable network posture
+	CloneNetworkPolicies bool `json:"cloneNetworkPolicies,omitempty"`
+
+	// ServiceAccount runs the canary pods under a ServiceAccount managed by
+	// the controller, e.g. to canary rotated credentials or an IAM policy
+	ServiceAccount *ServiceAccountSpec `json:"serviceAccount,omitempty"`
+
+	// CanaryScale sets the replicas of the target Deployment at every step and
+	// holds any HorizontalPodAutoscaler targeting it while the rollout runs
+	CanaryScale *CanaryScaleSpec `json:"canaryScale,omitempty"`
+
+	// Baseline runs a fresh copy of the stable version next to the canary
+	// with the same share of traffic, and analysis compares the canary
+	// against it instead of absolute thresholds
+	Baseline *BaselineSpec `json:"baseline,omitempty"`
+
+	// Approvals gates paused steps on Approval records that capture who
+	// approved, when and why, instead of the resume annotation
+	Approvals *ApprovalsSpec `json:"approvals,omitempty"`
+
+	// ConfigRevisions are ConfigMaps and Secrets whose canary version is
+	// mounted by the target Deployment, so config changes are canaried
+	// together with code
+	ConfigRevisions []ConfigRevision `json:"configRevisions,omitempty"`
+
+	// Metadata describes the change being canaried and is propagated to
+	// status, history, notifications and dashboard annotations
+	Metadata *ChangeMetadata `json:"metadata,omitempty"`
+
+	// Monitoring configures monitoring assets generated for the canary
+	Monitoring *MonitoringSpec `json:"monitoring,omitempty"`
+
+	// Notifications configures where rollout notifications are sent
+	Notifications *NotificationsSpec `json:"notifications,omitempty"`
+}
+
+// ScheduleSpec restricts a rollout to approved windows, e.g. business hours
+// on weekdays outside a release freeze
+type ScheduleSpec struct {
+	// TimeZone is the IANA time zone the windows are in. Defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Allowed are the windows in which steps may advance. Without any, steps
+	// advance at any time outside the blocked windows.
+	Allowed []ScheduleWindow `json:"allowed,omitempty"`
+
+	// Blocked are maintenance windows in which steps never advance, even
+	// inside an allowed window
+	Blocked []ScheduleWindow `json:"blocked,omitempty"`
+}
+
+// ScheduleWindow is a recurring window, given either by a cron expression of
+// its start and a duration, or by a time range on some days of the week
+type ScheduleWindow struct {
+	// Cron is a five-field cron expression of the window starts, e.g.
+	// "0 9 * * Mon-Fri"
+	Cron string `json:"cron,omitempty"`
+
+	// Duration is how long a window started by Cron stays open, e.g. 8h
+	Duration string `json:"duration,omitempty"`
+
+	// Start is the time of day the window opens, as HH:MM
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start,omitempty"`
+
+	// End is the time of day the window closes, as HH:MM. A window ending
+	// before it starts closes on the next day.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	End string `json:"end,omitempty"`
+
+	// Days are the days of the week the time range opens on. Defaults to
+	// every day.
+	Days []Weekday `json:"days,omitempty"`
+}
+
+// Weekday is a day of the week of a schedule window
+// +kubebuilder:validation:Enum=Mon;Tue;Wed;Thu;Fri;Sat;Sun
+type Weekday string
+
+// TimeSliceSpec exposes the canary periodically instead of to sustained
+// partial traffic, e.g. 10 minutes on and 50 minutes off for three cycles
+type TimeSliceSpec struct {
+	// Weight is the canary traffic percentage while exposed
+	Weight int32 `json:"weight"`
+
+	// OnDuration is how long each exposure lasts
+	OnDuration string `json:"onDuration"`
+
+	// OffDuration is how long the canary gets no traffic between exposures
+	OffDuration string `json:"offDuration"`
+
+	// Cycles is the number of exposures before ramping. Defaults to 3.
+	Cycles int32 `json:"cycles,omitempty"`
+}
+
+// ABTestSpec routes each user consistently to the stable or canary variant.
+// The step weights set the share of users on the canary.
+type ABTestSpec struct {
+	// Cookie is the assignment cookie. Requests carrying it with the value
+	// "canary" are routed to the canary, with "stable" to stable.
+	Cookie string `json:"cookie,omitempty"`
+
+	// SetCookie sets the assignment cookie on responses to requests without
+	// one, which are split by weight, so users stay on their first variant
+	SetCookie bool `json:"setCookie,omitempty"`
+
+	// CookieMaxAge is how long an assignment cookie lasts. Defaults to 24h.
+	CookieMaxAge string `json:"cookieMaxAge,omitempty"`
+
+	// Header is a request header carrying a hash bucket in [0, Buckets), set
+	// upstream from e.g. a user ID. The first weight percent of the buckets
+	// is routed to the canary, the rest to stable.
+	Header string `json:"header,omitempty"`
+}
+
+// SessionAffinitySpec pins clients to the canary through a cookie the canary
+// backend sets on its responses. Clients without it are split by weight, so
+// stable clients still move to the canary as its weight grows.
+type SessionAffinitySpec struct {
+	// Cookie is the affinity cookie. Defaults to gateway-cd-canary.
+	Cookie string `json:"cookie,omitempty"`
+
+	// MaxAge is how long the affinity cookie lasts. Defaults to 24h.
+	MaxAge string `json:"maxAge,omitempty"`
+}
+
+// HookType is the point of the rollout a hook runs at
+// +kubebuilder:validation:Enum=PreRollout;Rollback
+type HookType string
+
+const (
+	// HookTypePreRollout hooks run before the canary gets any traffic. A
+	// failed hook rolls the canary back.
+	HookTypePreRollout HookType = "PreRollout"
+	// HookTypeRollback hooks run on rollback once traffic and the workload
+	// are back on stable
+	HookTypeRollback HookType = "Rollback"
+)
+
+// HookStep runs a Job at a point of the rollout
+type HookStep struct {
+	// Name identifies the hook and prefixes the name of its Job
+	Name string `json:"name"`
+
+	// Type is the point of the rollout the hook runs at
+	Type HookType `json:"type"`
+
+	// Template is the Job run for the hook
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Template batchv1.JobTemplateSpec `json:"template"`
+
+	// Timeout fails the hook if its Job hasn't completed by then. Defaults to 30m.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// HookPhase is the state of a hook's Job
+type HookPhase string
+
+const (
+	HookPhaseRunning   HookPhase = "Running"
+	HookPhaseSucceeded HookPhase = "Succeeded"
+	HookPhaseFailed    HookPhase = "Failed"
+)
+
+// HookStatus records the Job run for a hook during the current rollout
+type HookStatus struct {
+	// Name of the hook
+	Name string `json:"name"`
+	// Type of the hook
+	Type HookType `json:"type"`
+	// JobName is the name of the Job run for the hook
+	JobName string `json:"jobName"`
+	// Phase is the state of the Job
+	Phase HookPhase `json:"phase"`
+	// Message explains a failure
+	Message string `json:"message,omitempty"`
+	// StartedTime is when the Job was created
+	StartedTime *metav1.Time `json:"startedTime,omitempty"`
+	// CompletedTime is when the Job succeeded or failed
+	CompletedTime *metav1.Time `json:"completedTime,omitempty"`
+}
+
+// PreRolloutCheck smoke tests the canary Service with a Job or HTTP checks
+// while it gets no live traffic. Exactly one of them is set.
+type PreRolloutCheck struct {
+	// Job is run with CANARY_URL set to the canary Service URL and passes
+	// once the Job completes
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Job *batchv1.JobTemplateSpec `json:"job,omitempty"`
+
+	// HTTP are requests the controller sends to the canary Service, passing
+	// once every request gets its expected response
+	HTTP []HTTPCheck `json:"http,omitempty"`
+
+	// Timeout fails the canary if the check hasn't passed by then. Defaults to 10m.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// HTTPCheck is a request sent to the canary Service and its expected response
+type HTTPCheck struct {
+	// Path is the request path, e.g. /healthz. Defaults to /.
+	Path string `json:"path,omitempty"`
+	// Method is the request method. Defaults to GET.
+	Method string `json:"method,omitempty"`
+	// Headers are set on the request
+	Headers map[string]string `json:"headers,omitempty"`
+	// ExpectedStatus is the expected response status. Defaults to any 2xx status.
+	ExpectedStatus int32 `json:"expectedStatus,omitempty"`
+	// ExpectedBody is a substring the response body must contain
+	ExpectedBody string `json:"expectedBody,omitempty"`
+}
+
+// PreRolloutCheckStatus records the pre-rollout check of the current rollout
+type PreRolloutCheckStatus struct {
+	// Phase is the state of the check
+	Phase HookPhase `json:"phase"`
+	// JobName is the name of the Job of a Job check
+	JobName string `json:"jobName,omitempty"`
+	// Message explains the last failure
+	Message string `json:"message,omitempty"`
+	// StartedTime is when the check started
+	StartedTime *metav1.Time `json:"startedTime,omitempty"`
+	// CompletedTime is when the check passed or failed
+	CompletedTime *metav1.Time `json:"completedTime,omitempty"`
+}
+
+// PodHealthStatus is the last pod health check of a rollout
+type PodHealthStatus struct {
+	// BaselineRestarts are the container restarts of the canary pods when
+	// the rollout was first checked, not counted against MaxRestarts
+	BaselineRestarts int32 `json:"baselineRestarts,omitempty"`
+	// Restarts are the container restarts of the canary pods during the rollout
+	Restarts int32 `json:"restarts,omitempty"`
+	// Pods is the number of canary pods checked
+	Pods int32 `json:"pods,omitempty"`
+	// CheckedTime is when the pods were last checked
+	CheckedTime *metav1.Time `json:"checkedTime,omitempty"`
+}
+
+// PostPromotionStatus is the progress of the post-promotion soak period
+type PostPromotionStatus struct {
+	// StartedTime is when the canary reached full traffic and the soak started
+	StartedTime *metav1.Time `json:"startedTime,omitempty"`
+	// LastRunTime is when analysis last ran during the soak
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+	// Runs is the number of analysis runs during the soak
+	Runs int32 `json:"runs,omitempty"`
+}
+
+// StepHookStage is when a step hook runs relative to the weight change of its step
+type StepHookStage string
+
+const (
+	StepHookStagePreStep  StepHookStage = "PreStep"
+	StepHookStagePostStep StepHookStage = "PostStep"
+)
+
+// StepHookFailurePolicy decides how the rollout proceeds when a step hook fails
+// +kubebuilder:validation:Enum=fail;ignore;retry
+type StepHookFailurePolicy string
+
+const (
+	// StepHookFailurePolicyFail rolls the canary back
+	StepHookFailurePolicyFail StepHookFailurePolicy = "fail"
+	// StepHookFailurePolicyIgnore reports the failure and continues the step
+	StepHookFailurePolicyIgnore StepHookFailurePolicy = "ignore"
+	// StepHookFailurePolicyRetry runs the hook again up to its retries, then
+	// rolls the canary back
+	StepHookFailurePolicyRetry StepHookFailurePolicy = "retry"
+)
+
+// StepHook runs a Job, calls a webhook or evaluates an expression before or
+// after the weight change of a step. Exactly one of them is set.
+type StepHook struct {
+	// Name identifies the hook within its step and prefixes the name of its Job
+	Name string `json:"name"`
+
+	// Job is run for the hook and succeeds once the Job completes
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Job *batchv1.JobTemplateSpec `json:"job,omitempty"`
+
+	// Webhook is called with the step and succeeds on a 2xx response
+	Webhook *StepWebhook `json:"webhook,omitempty"`
+
+	// Expression is a boolean expression in a subset of CEL over the rollout,
+	// e.g. "readyReplicas >= 3", succeeding when true. Its variables are
+	// step, weight, canaryReplicas, readyReplicas, successRate and latency.
+	Expression string `json:"expression,omitempty"`
+
+	// Timeout fails the hook if it hasn't completed by then. Defaults to 30m
+	// for Jobs and 30s for webhooks.
+	Timeout string `json:"timeout,omitempty"`
+
+	// FailurePolicy decides whether a failure rolls the canary back (fail),
+	// is ignored (ignore) or runs the hook again (retry). Defaults to fail.
+	FailurePolicy StepHookFailurePolicy `json:"failurePolicy,omitempty"`
+
+	// Retries is how many times the retry policy runs a failed hook again
+	// before rolling the canary back. Defaults to 3.
+	Retries int32 `json:"retries,omitempty"`
+}
+
+// StepWebhook is an HTTP endpoint called by a step hook
+type StepWebhook struct {
+	// URL is the webhook URL. Use URLSecretRef for URLs that embed credentials.
+	URL string `json:"url,omitempty"`
+	// URLSecretRef references a Secret key in the canary namespace holding the webhook URL
+	URLSecretRef *SecretKeyRef `json:"urlSecretRef,omitempty"`
+}
+
+// StepHookStatus records a step hook run at the current step
+type StepHookStatus struct {
+	// Name of the hook
+	Name string `json:"name"`
+	// Step is the index of the step the hook ran at
+	Step int32 `json:"step"`
+	// Stage is whether the hook ran before or after the weight change
+	Stage StepHookStage `json:"stage"`
+	// Phase is the state of the hook
+	Phase HookPhase `json:"phase"`
+	// Attempts is the number of times the hook ran
+	Attempts int32 `json:"attempts,omitempty"`
+	// JobName is the name of the Job of the current attempt of a Job hook
+	JobName string `json:"jobName,omitempty"`
+	// Message explains a failure
+	Message string `json:"message,omitempty"`
+	// StartedTime is when the current attempt started
+	StartedTime *metav1.Time `json:"startedTime,omitempty"`
+	// CompletedTime is when the hook succeeded or failed
+	CompletedTime *metav1.Time `json:"completedTime,omitempty"`
+}
+
+// MonitoringSpec configures monitoring assets generated for a canary
+type MonitoringSpec struct {
+	// PrometheusRule generates a PrometheusRule with recording rules for the
+	// canary and stable SLIs and an alert on rollback
+	PrometheusRule bool `json:"prometheusRule,omitempty"`
+	// RuleLabels are added to the PrometheusRule so Prometheus selects it
+	RuleLabels map[string]string `json:"ruleLabels,omitempty"`
+}
+
+// NotificationsSpec configures rollout notifications for a canary
+type NotificationsSpec struct {
+	// Channels receive notifications in addition to the controller-wide channels
+	Channels []NotificationChannel `json:"channels,omitempty"`
+	// DisableDefaults skips the channels configured on the controller
+	DisableDefaults bool `json:"disableDefaults,omitempty"`
+}
+
+// NotificationChannelType is the kind of a notification channel
+type NotificationChannelType string
+
+const (
+	// NotificationChannelSlack posts to a Slack incoming webhook
+	NotificationChannelSlack NotificationChannelType = "Slack"
+	// NotificationChannelTeams posts to a Microsoft Teams incoming webhook
+	NotificationChannelTeams NotificationChannelType = "Teams"
+	// NotificationChannelWebhook posts the notification as JSON to any HTTP endpoint
+	NotificationChannelWebhook NotificationChannelType = "Webhook"
+)
+
+// NotificationEvent is a rollout event notifications can be sent for
+type NotificationEvent string
+
+const (
+	// NotificationEventRolloutStarted is sent when a rollout starts
+	NotificationEventRolloutStarted NotificationEvent = "RolloutStarted"
+	// NotificationEventPausedForApproval is sent when a step pauses for manual approval
+	NotificationEventPausedForApproval NotificationEvent = "PausedForApproval"
+	// NotificationEventAnalysisFailed is sent when analysis fails
+	NotificationEventAnalysisFailed NotificationEvent = "AnalysisFailed"
+	// NotificationEventRolledBack is sent when the canary has been rolled back
+	NotificationEventRolledBack NotificationEvent = "RolledBack"
+	// NotificationEventPromoted is sent when the canary has been promoted
+	NotificationEventPromoted NotificationEvent = "Promoted"
+	// NotificationEventProgressDeadlineExceeded is sent when the rollout
+	// stops advancing within its progress deadline
+	NotificationEventProgressDeadlineExceeded NotificationEvent = "ProgressDeadlineExceeded"
+)
+
+// NotificationChannel is a destination for rollout notifications
+type NotificationChannel struct {
+	// Type is the kind of channel (Slack, Teams or Webhook)
+	Type NotificationChannelType `json:"type"`
+	// URL is the webhook URL. Use URLSecretRef for URLs that embed credentials.
+	URL string `json:"url,omitempty"`
+	// URLSecretRef references a Secret key in the canary namespace holding the webhook URL
+	URLSecretRef *SecretKeyRef `json:"urlSecretRef,omitempty"`
+	// Events limits the channel to the listed events. All events are sent when empty.
+	Events []NotificationEvent `json:"events,omitempty"`
+}
+
+// ConfigRevision co-versions a ConfigMap or Secret with the canary. The
+// controller copies CanaryName to a content-hashed copy of Name and points the
+// target Deployment's references to Name at it, deleting superseded copies.
+type ConfigRevision struct {
+	// Kind is ConfigMap or Secret
+	Kind string `json:"kind"`
+	// Name is the ConfigMap or Secret the pod template references
+	Name string `json:"name"`
+	// CanaryName is the ConfigMap or Secret holding the canary version
+	CanaryName string `json:"canaryName"`
+}
+
+// ServiceAccountSpec is the identity of the canary pods for the rollout. The
+// controller creates the ServiceAccount, switches the target Deployment to it
+// when the rollout starts and restores the original on rollback.
+type ServiceAccountSpec struct {
+	// Name of the ServiceAccount in the canary namespace
+	Name string `json:"name"`
+	// Annotations bind the ServiceAccount to a workload identity, e.g.
+	// eks.amazonaws.com/role-arn or iam.gke.io/gcp-service-account
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// CanaryScaleMode selects how the canary replicas of a step are computed
+// +kubebuilder:validation:Enum=Fixed;PercentOfStable;MatchWeight
+type CanaryScaleMode string
+
+const (
+	// CanaryScaleModeFixed runs Replicas canary pods at every step
+	CanaryScaleModeFixed CanaryScaleMode = "Fixed"
+	// CanaryScaleModePercentOfStable runs Percent of the stable replicas
+	CanaryScaleModePercentOfStable CanaryScaleMode = "PercentOfStable"
+	// CanaryScaleModeMatchWeight runs the share of the stable replicas
+	// matching the step's canary weight
+	CanaryScaleModeMatchWeight CanaryScaleMode = "MatchWeight"
+)
+
+// HPAPolicy selects how a HorizontalPodAutoscaler of the canary is held
+// +kubebuilder:validation:Enum=Pause;Adjust
+type HPAPolicy string
+
+const (
+	// HPAPolicyPause pins the HPA to the step's replicas so it can't scale
+	HPAPolicyPause HPAPolicy = "Pause"
+	// HPAPolicyAdjust raises the HPA minimum to the step's replicas and
+	// keeps autoscaling above it
+	HPAPolicyAdjust HPAPolicy = "Adjust"
+)
+
+// CanaryScaleSpec controls the replicas of the target Deployment per step.
+// An HPA targeting the Deployment is held for the rollout and its bounds
+// restored on promotion, rollback and deletion.
+type CanaryScaleSpec struct {
+	// Mode is Fixed, PercentOfStable or MatchWeight
+	Mode CanaryScaleMode `json:"mode"`
+	// Replicas is the number of canary replicas in Fixed mode
+	Replicas int32 `json:"replicas,omitempty"`
+	// Percent of the stable replicas to run in PercentOfStable mode
+	Percent int32 `json:"percent,omitempty"`
+	// StableDeployment is the Deployment in the canary namespace whose
+	// replicas PercentOfStable and MatchWeight scale from
+	StableDeployment string `json:"stableDeployment,omitempty"`
+	// MinReplicas is the fewest canary replicas at any step. Defaults to 1.
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+	// HPAPolicy is either Pause (default) or Adjust
+	HPAPolicy HPAPolicy `json:"hpaPolicy,omitempty"`
+}
+
+// BaselineSpec runs a baseline Deployment of the stable version for the
+// rollout. Unlike the long-lived stable pods, the baseline starts together
+// with the canary, so differences between the two come from the new version
+// and not from warm caches or leaked memory.
+type BaselineSpec struct {
+	// StableDeployment is the Deployment in the canary namespace whose pod
+	// template the baseline runs
+	StableDeployment string `json:"stableDeployment"`
+	// Replicas of the baseline. Defaults to the replicas of the canary.
+	Replicas int32 `json:"replicas,omitempty"`
+	// SuccessRateTolerance is how far the canary success rate may fall below
+	// the baseline's (0.0-1.0). Defaults to 0.01.
+	SuccessRateTolerance float64 `json:"successRateTolerance,omitempty"`
+	// Tolerance is the fraction by which the canary latency and metrics may
+	// be worse than the baseline's. Defaults to 0.1.
+	Tolerance float64 `json:"tolerance,omitempty"`
+}
+
+// HPABounds are the replica bounds of a HorizontalPodAutoscaler
+type HPABounds struct {
+	// Name of the HorizontalPodAutoscaler
+	Name string `json:"name"`
+	// MinReplicas is the HPA's minReplicas, unset for the default
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas is the HPA's maxReplicas
+	MaxReplicas int32 `json:"maxReplicas"`
+}
+
+// SecretKeyRef references a key of a Secret in the canary namespace
+type SecretKeyRef struct {
+	// Name of the Secret
+	Name string `json:"name"`
+	// Key within the Secret
+	Key string `json:"key"`
+}
+
+// ChangeMetadata links a rollout back to the change that produced it
+type ChangeMetadata struct {
+	// GitSHA is the commit being rolled out
+	GitSHA string `json:"gitSHA,omitempty"`
+	// PullRequestURL is the URL of the pull/merge request
+	PullRequestURL string `json:"pullRequestURL,omitempty"`
+	// Ticket is the change or issue tracker reference
+	Ticket string `json:"ticket,omitempty"`
+	// Author is who authored or triggered the change
+	Author string `json:"author,omitempty"`
 }
 
 // WorkloadRef references a Kubernetes workload
@@ -92,16 +1052,115 @@ type ServiceRef struct {
 	Name string `json:"name"`
 	// Port is the service port to use for canary traffic
 	Port int32 `json:"port"`
+	// Ports are further named ports of the service, e.g. grpc or metrics,
+	// that routes select by name. A route rule already sending traffic to one
+	// of them keeps it, so a route can serve several ports.
+	Ports []ServicePort `json:"ports,omitempty"`
+	// Managed has the controller own the Service and its -canary Service,
+	// pointing them at the stable and canary ReplicaSets of the target
+	// Deployment by pod-template-hash, so a single Deployment is rolled out
+	// without a separate canary Deployment and Service. The Deployment is
+	// paused for the rollout and reverted on rollback.
+	Managed bool `json:"managed,omitempty"`
+}
+
+// ServicePort is a named port of the stable and canary services
+type ServicePort struct {
+	// Name of the port
+	Name string `json:"name"`
+	// Port is the service port number
+	Port int32 `json:"port"`
 }
 
 // GatewayRef references Gateway API resources
 type GatewayRef struct {
 	// HTTPRoute is the name of the HTTPRoute to manage
-	HTTPRoute string `json:"httpRoute"`
+	HTTPRoute string `json:"httpRoute,omitempty"`
+	// HTTPRoutes are further HTTPRoutes in Namespace exposing the service,
+	// e.g. on an internal and an external Gateway. They shift together with
+	// HTTPRoute and are updated as a unit: if one fails, the others are
+	// reverted.
+	HTTPRoutes []string `json:"httpRoutes,omitempty"`
+	// GRPCRoute is the name of the GRPCRoute to manage
+	GRPCRoute string `json:"grpcRoute,omitempty"`
+	// Port is the name of the service port HTTPRoute and HTTPRoutes send
+	// traffic to, one of service.ports (default service.port)
+	Port string `json:"port,omitempty"`
+	// GRPCPort is the name of the service port GRPCRoute sends traffic to,
+	// one of service.ports (default service.port)
+	GRPCPort string `json:"grpcPort,omitempty"`
 	// Gateway is the name of the Gateway (optional)
 	Gateway string `json:"gateway,omitempty"`
 	// Namespace is the namespace of the Gateway API resources
 	Namespace string `json:"namespace,omitempty"`
+	// CanaryRewrite is a URL rewrite applied only to requests forwarded to the
+	// canary backend, for legacy services whose canary is mounted on a different path
+	CanaryRewrite *URLRewrite `json:"canaryRewrite,omitempty"`
+	// AdditionalRoutes are HTTPRoutes on other Gateways (e.g. an internal
+	// east-west Gateway) that must shift together with HTTPRoute
+	AdditionalRoutes []AdditionalRoute `json:"additionalRoutes,omitempty"`
+	// SectionNames limits the rollout to the parentRefs of HTTPRoute with these
+	// section names, e.g. only the HTTPS listener. The other parentRefs keep
+	// routing to stable until the rollout ends.
+	SectionNames []string `json:"sectionNames,omitempty"`
+	// RouteSelector limits the rollout to the HTTPRoute rules matching it,
+	// e.g. only /api/v2/*. The other rules keep their backends.
+	RouteSelector *RouteSelector `json:"routeSelector,omitempty"`
+	// ProgrammedTimeout is how long the gateway implementation may take to
+	// report a step's weights programmed before the canary is rolled back
+	// (default 5m). Analysis of a step only starts once they are.
+	ProgrammedTimeout string `json:"programmedTimeout,omitempty"`
+}
+
+// RouteSelector selects the HTTPRoute rules a rollout applies to. A rule is
+// selected when its route serves one of the hostnames and one of its path
+// matches matches one of the paths; an empty list selects any.
+type RouteSelector struct {
+	// Paths are request paths the canary applies to. A path ending in /*
+	// selects the path matches under it, e.g. /api/v2/* selects /api/v2 and
+	// /api/v2/orders; any other path selects the path match equal to it.
+	Paths []string `json:"paths,omitempty"`
+	// Hostnames are hostnames the canary applies to. Routes without
+	// hostnames serve every hostname and are always selected; a wildcard
+	// such as *.example.com selects the hostnames under it.
+	Hostnames []string `json:"hostnames,omitempty"`
+}
+
+// URLRewrite rewrites requests forwarded to a backend
+type URLRewrite struct {
+	// Hostname replaces the request Host header
+	Hostname string `json:"hostname,omitempty"`
+	// ReplacePrefixMatch replaces the path prefix matched by the rule
+	ReplacePrefixMatch string `json:"replacePrefixMatch,omitempty"`
+	// ReplaceFullPath replaces the full request path
+	ReplaceFullPath string `json:"replaceFullPath,omitempty"`
+}
+
+// RouteWeightPolicy controls how an additional route derives its canary weight
+type RouteWeightPolicy string
+
+const (
+	// RouteWeightPolicyLinked applies the same weight as the primary HTTPRoute
+	RouteWeightPolicyLinked RouteWeightPolicy = "Linked"
+	// RouteWeightPolicyIndependent applies the per-step weights listed on the route
+	RouteWeightPolicyIndependent RouteWeightPolicy = "Independent"
+)
+
+// AdditionalRoute references an extra HTTPRoute managed by the canary
+type AdditionalRoute struct {
+	// HTTPRoute is the name of the HTTPRoute to manage
+	HTTPRoute string `json:"httpRoute"`
+	// Gateway is the name of the Gateway the route is attached to (optional)
+	Gateway string `json:"gateway,omitempty"`
+	// Namespace is the namespace of the route, defaults to the primary route namespace
+	Namespace string `json:"namespace,omitempty"`
+	// WeightPolicy is either Linked (default) or Independent
+	WeightPolicy RouteWeightPolicy `json:"weightPolicy,omitempty"`
+	// Weights are the canary weights per traffic split step when WeightPolicy is Independent
+	Weights []int32 `json:"weights,omitempty"`
+	// Port is the name of the service port the route sends traffic to, one
+	// of service.ports (default service.port)
+	Port string `json:"port,omitempty"`
 }
 
 // CanaryDeploymentStatus defines the observed state of CanaryDeployment
@@ -109,26 +1168,289 @@ type CanaryDeploymentStatus struct {
 	// Phase is the current phase of the canary deployment
 	Phase CanaryDeploymentPhase `json:"phase,omitempty"`
 
+	// Health maps the phase and conditions to the Healthy, Progressing,
+	// Degraded and Suspended health of GitOps tools
+	Health HealthStatus `json:"health,omitempty"`
+
 	// Message provides human-readable details about the current state
 	Message string `json:"message,omitempty"`
 
 	// CurrentStep is the index of the current traffic split step
 	CurrentStep int32 `json:"currentStep,omitempty"`
 
+	// TrafficSplitHash identifies the traffic split steps CurrentStep refers to
+	TrafficSplitHash string `json:"trafficSplitHash,omitempty"`
+
 	// CanaryWeight is the current percentage of traffic routed to canary
 	CanaryWeight int32 `json:"canaryWeight,omitempty"`
 
 	// StableWeight is the current percentage of traffic routed to stable
 	StableWeight int32 `json:"stableWeight,omitempty"`
 
+	// CanaryTenants is the number of tenant IDs and patterns routed to canary
+	CanaryTenants int32 `json:"canaryTenants,omitempty"`
+
+	// StartedTime is when the current rollout started
+	StartedTime *metav1.Time `json:"startedTime,omitempty"`
+
+	// CanaryFraction is the effective canary percentage while a fractional
+	// weight step is active
+	CanaryFraction string `json:"canaryFraction,omitempty"`
+
+	// CanaryReplicas is the replica count spec.canaryScale set for the
+	// current step
+	CanaryReplicas int32 `json:"canaryReplicas,omitempty"`
+
+	// MirrorStartedTime is when traffic started being mirrored to the canary
+	MirrorStartedTime *metav1.Time `json:"mirrorStartedTime,omitempty"`
+
+	// MirrorCompleted is true once mirrored analysis passed and real traffic may shift
+	MirrorCompleted bool `json:"mirrorCompleted,omitempty"`
+
+	// TimeSliceCycle is the number of time slice exposures started
+	TimeSliceCycle int32 `json:"timeSliceCycle,omitempty"`
+
+	// TimeSliceExposed is true while a time slice exposure routes traffic to the canary
+	TimeSliceExposed bool `json:"timeSliceExposed,omitempty"`
+
+	// TimeSliceWindowStart is when the current time slice exposure or pause started
+	TimeSliceWindowStart *metav1.Time `json:"timeSliceWindowStart,omitempty"`
+
+	// TimeSliceCompleted is true once every time slice cycle completed and ramping may start
+	TimeSliceCompleted bool `json:"timeSliceCompleted,omitempty"`
+
+	// Hooks are the hook Jobs run during the current rollout
+	Hooks []HookStatus `json:"hooks,omitempty"`
+
+	// PreRolloutHooksCompleted is true once every PreRollout hook succeeded
+	PreRolloutHooksCompleted bool `json:"preRolloutHooksCompleted,omitempty"`
+
+	// PreRolloutCheck is the pre-rollout check of the current rollout
+	PreRolloutCheck *PreRolloutCheckStatus `json:"preRolloutCheck,omitempty"`
+
+	// PodHealth is the last pod health check of the current rollout
+	PodHealth *PodHealthStatus `json:"podHealth,omitempty"`
+	// PostPromotion is the soak period of the canary at full traffic
+	PostPromotion *PostPromotionStatus `json:"postPromotion,omitempty"`
+
+	// StepHooks are the step hooks run at the current step
+	StepHooks []StepHookStatus `json:"stepHooks,omitempty"`
+
+	// ManagedRoute is the namespace/name of the primary route written by the controller
+	ManagedRoute string `json:"managedRoute,omitempty"`
+
+	// LastAppliedWeight is the canary weight last written to the managed route
+	LastAppliedWeight int32 `json:"lastAppliedWeight,omitempty"`
+
+	// RouteGeneration is the generation of the managed route after the last write
+	RouteGeneration int64 `json:"routeGeneration,omitempty"`
+
+	// RouteUpdatedTime is when the managed route was last written
+	RouteUpdatedTime *metav1.Time `json:"routeUpdatedTime,omitempty"`
+
+	// Routes reports the last write to every route managed by the canary
+	Routes []RouteStatus `json:"routes,omitempty"`
+
+	// WeightsAppliedTime is when the weights of the current step were first written
+	WeightsAppliedTime *metav1.Time `json:"weightsAppliedTime,omitempty"`
+
+	// WeightsProgrammed is true once the gateway implementation reported the
+	// weights of the current step programmed
+	WeightsProgrammed bool `json:"weightsProgrammed,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was computed for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// Conditions represent the latest available observations
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 
+	// BlockedBy is the namespace/name of the CanaryDeployment rolling out on
+	// one of the routes while this rollout waits for it
+	BlockedBy string `json:"blockedBy,omitempty"`
+
 	// LastTransitionTime is when the current phase was entered
 	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
 
+	// LastProgressTime is when the rollout last advanced a step
+	LastProgressTime *metav1.Time `json:"lastProgressTime,omitempty"`
+
 	// Analysis results from the current or last analysis run
 	AnalysisRun *AnalysisRunStatus `json:"analysisRun,omitempty"`
+
+	// StepAnalysis tracks the analysis intervals of the current step
+	StepAnalysis *StepAnalysisStatus `json:"stepAnalysis,omitempty"`
+
+	// StableRevision is the revision of the target Deployment that last
+	// completed a rollout, restored on rollback when RevertOnRollback is set
+	StableRevision *WorkloadRevision `json:"stableRevision,omitempty"`
+
+	// CanaryRevision is the revision of the target Deployment being rolled out
+	CanaryRevision *WorkloadRevision `json:"canaryRevision,omitempty"`
+
+	// OriginalServiceAccount is the ServiceAccount of the target Deployment
+	// before it was switched to the canary ServiceAccount
+	OriginalServiceAccount string `json:"originalServiceAccount,omitempty"`
+
+	// OriginalHPA are the bounds of the HPA targeting the canary before the
+	// rollout held it
+	OriginalHPA *HPABounds `json:"originalHPA,omitempty"`
+
+	// Approvals are the approvals and rejections counted during the current rollout
+	Approvals []ApprovalRecord `json:"approvals,omitempty"`
+
+	// History is the timeline of the traffic split steps of the current
+	// rollout, oldest first. Beyond 50 transitions the oldest are moved to
+	// the history ConfigMap.
+	History []StepTransition `json:"history,omitempty"`
+
+	// Audit records the control actions applied to the canary across
+	// rollouts, oldest first. Beyond 50 actions the oldest are moved to the
+	// history ConfigMap.
+	Audit []ControlAction `json:"audit,omitempty"`
+
+	// AnalysisHistory are the last analysis runs of the rollout, kept for
+	// spec.analysis.smoothing
+	AnalysisHistory []AnalysisRunStatus `json:"analysisHistory,omitempty"`
+
+	// ConsecutiveFailures is the number of failed analysis runs since the last passed one
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+
+	// ConsecutiveErrors is the number of analysis runs in a row that could not be completed
+	ConsecutiveErrors int32 `json:"consecutiveErrors,omitempty"`
+
+	// RetryCount is the number of retries of the failing gateway operation
+	// since it last succeeded. The controller gives up once it reaches its
+	// configured max retries.
+	RetryCount int32 `json:"retryCount,omitempty"`
+
+	// RollbackReason explains why the canary was rolled back
+	RollbackReason string `json:"rollbackReason,omitempty"`
+
+	// RollbackStep is the progress of a gradual rollback
+	RollbackStep *RollbackStepStatus `json:"rollbackStep,omitempty"`
+
+	// ChangeMetadata is the change metadata of the rollout in progress
+	ChangeMetadata *ChangeMetadata `json:"changeMetadata,omitempty"`
+	// HistoryConfigMap is the ConfigMap holding status records compacted out
+	// of the status to keep it within size limits
+	HistoryConfigMap string `json:"historyConfigMap,omitempty"`
+}
+
+// StepTransition records the rollout entering a traffic split step
+type StepTransition struct {
+	// Step is the index of the traffic split step entered
+	Step int32 `json:"step"`
+	// Weight is the canary weight of the step
+	Weight int32 `json:"weight"`
+	// Time is when the step was entered
+	Time metav1.Time `json:"time"`
+	// Analysis summarizes the analysis run that completed at the previous step
+	Analysis string `json:"analysis,omitempty"`
+	// Actor is who moved the rollout to the step: the controller, or the
+	// users who resumed or approved it
+	Actor string `json:"actor,omitempty"`
+}
+
+// ControlActionType is an operator action on a rollout
+type ControlActionType string
+
+const (
+	ControlActionPause          ControlActionType = "Pause"
+	ControlActionResume         ControlActionType = "Resume"
+	ControlActionAbort          ControlActionType = "Abort"
+	ControlActionPromote        ControlActionType = "Promote"
+	ControlActionWeightOverride ControlActionType = "WeightOverride"
+	ControlActionRetry          ControlActionType = "Retry"
+)
+
+// ControlSource is where a control action was requested
+type ControlSource string
+
+const (
+	// ControlSourceAPI is the REST or gRPC API, including its webhooks
+	ControlSourceAPI ControlSource = "API"
+	// ControlSourceCLI is the gateway-cd CLI
+	ControlSourceCLI ControlSource = "CLI"
+	// ControlSourceSlack is a Slack approval button
+	ControlSourceSlack ControlSource = "Slack"
+	// ControlSourceAnnotation is a control annotation set on the canary directly
+	ControlSourceAnnotation ControlSource = "Annotation"
+)
+
+// ControlAction is an audit record of a control action the controller applied
+type ControlAction struct {
+	// Action is the control action
+	Action ControlActionType `json:"action"`
+	// Source is where the action was requested
+	Source ControlSource `json:"source"`
+	// User is who requested the action, if known
+	User string `json:"user,omitempty"`
+	// AdmittedBy is the authenticated user that set the control annotation,
+	// recorded by the admission webhook
+	AdmittedBy string `json:"admittedBy,omitempty"`
+	// Verified is true when the admission webhook confirmed User as the
+	// authenticated user that set the control annotation
+	Verified bool `json:"verified,omitempty"`
+	// Time is when the action was applied
+	Time metav1.Time `json:"time"`
+	// Value is the requested value of a WeightOverride or Retry
+	Value string `json:"value,omitempty"`
+	// PreviousPhase is the phase of the rollout before the action
+	PreviousPhase CanaryDeploymentPhase `json:"previousPhase,omitempty"`
+	// PreviousStep is the step of the rollout before the action
+	PreviousStep int32 `json:"previousStep"`
+	// PreviousWeight is the canary weight before the action
+	PreviousWeight int32 `json:"previousWeight"`
+	// Message describes the outcome, e.g. why an action was ignored
+	Message string `json:"message,omitempty"`
+}
+
+// RouteStatus is the last write to a route managed by the canary
+type RouteStatus struct {
+	// Kind is HTTPRoute or GRPCRoute
+	Kind string `json:"kind"`
+	// Namespace of the route
+	Namespace string `json:"namespace"`
+	// Name of the route
+	Name string `json:"name"`
+	// Weight is the canary weight last written to the route
+	Weight int32 `json:"weight"`
+	// Generation is the generation of the route after the last write
+	Generation int64 `json:"generation,omitempty"`
+	// UpdatedTime is when the route was last written
+	UpdatedTime *metav1.Time `json:"updatedTime,omitempty"`
+	// Error is why the last update of the route failed or was reverted
+	Error string `json:"error,omitempty"`
+}
+
+// WorkloadRevision identifies a pod template revision of the target Deployment
+type WorkloadRevision struct {
+	// Revision is the Deployment revision number
+	Revision string `json:"revision"`
+	// PodTemplateHash is the pod-template-hash label of the revision's ReplicaSet
+	PodTemplateHash string `json:"podTemplateHash"`
+	// Images are the container images of the revision as container=image
+	Images []string `json:"images,omitempty"`
+	// RecordedTime is when the revision was recorded
+	RecordedTime *metav1.Time `json:"recordedTime,omitempty"`
+}
+
+// StepAnalysisStatus aggregates the analysis intervals run at one traffic split step
+type StepAnalysisStatus struct {
+	// Step is the index of the traffic split step being analysed
+	Step int32 `json:"step"`
+	// StartedTime is when analysis of the step started
+	StartedTime *metav1.Time `json:"startedTime,omitempty"`
+	// LastRunTime is when analysis last ran at this step
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+	// Intervals is the number of analysis intervals completed at this step
+	Intervals int32 `json:"intervals,omitempty"`
+	// SuccessfulIntervals is the number of intervals that passed
+	SuccessfulIntervals int32 `json:"successfulIntervals,omitempty"`
+	// RequiredIntervals is the number of passed intervals the step needs
+	RequiredIntervals int32 `json:"requiredIntervals,omitempty"`
+	// SuccessRate is the mean success rate over the step's intervals
+	SuccessRate float64 `json:"successRate,omitempty"`
 }
 
 // AnalysisRunStatus contains the results of a canary analysis run
@@ -139,6 +1461,10 @@ type AnalysisRunStatus struct {
 	SuccessRate float64 `json:"successRate,omitempty"`
 	// AverageLatency observed during analysis
 	AverageLatency int32 `json:"averageLatency,omitempty"`
+	// BaselineSuccessRate observed on the baseline during analysis
+	BaselineSuccessRate float64 `json:"baselineSuccessRate,omitempty"`
+	// BaselineLatency observed on the baseline during analysis
+	BaselineLatency int32 `json:"baselineLatency,omitempty"`
 	// MetricResults contains results for each configured metric
 	MetricResults []MetricResult `json:"metricResults,omitempty"`
 	// StartedAt is when the analysis run started
@@ -153,17 +1479,27 @@ type MetricResult struct {
 	Name string `json:"name"`
 	// Value is the measured value
 	Value float64 `json:"value"`
-	// Threshold is the configured threshold
+	// Threshold is the configured threshold, or the bound derived from the
+	// baseline value when compared against a baseline
 	Threshold float64 `json:"threshold"`
+	// BaselineValue is the value measured on the baseline
+	BaselineValue float64 `json:"baselineValue,omitempty"`
 	// Passed indicates whether the metric passed the threshold check
 	Passed bool `json:"passed"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:storageversion
 //+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Health",type="string",JSONPath=".status.health",priority=1
 //+kubebuilder:printcolumn:name="Canary Weight",type="integer",JSONPath=".status.canaryWeight"
 //+kubebuilder:printcolumn:name="Step",type="integer",JSONPath=".status.currentStep"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+//+kubebuilder:printcolumn:name="Route",type="string",JSONPath=".status.managedRoute",priority=1
+//+kubebuilder:printcolumn:name="Applied Weight",type="integer",JSONPath=".status.lastAppliedWeight",priority=1
+//+kubebuilder:printcolumn:name="Route Generation",type="integer",JSONPath=".status.routeGeneration",priority=1
+//+kubebuilder:printcolumn:name="Route Updated",type="date",JSONPath=".status.routeUpdatedTime",priority=1
 //+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // CanaryDeployment is the Schema for the canarydeployments API
@@ -0,0 +1,51 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CanaryTemplateRef references a CanaryTemplate
+type CanaryTemplateRef struct {
+	// Name of the CanaryTemplate
+	Name string `json:"name"`
+}
+
+// CanaryTemplateSpec holds the rollout settings a CanaryTemplate provides to
+// the CanaryDeployments referencing it
+type CanaryTemplateSpec struct {
+	// TrafficSplit are the traffic split steps of canaries that list none
+	TrafficSplit []TrafficSplitStep `json:"trafficSplit,omitempty"`
+
+	// Analysis is the analysis of referencing canaries. Fields set in the
+	// canary's analysis override it and metrics are merged by name.
+	Analysis AnalysisSpec `json:"analysis,omitempty"`
+
+	// AnalysisTemplateRef references a shared analysis policy for canaries
+	// that reference none
+	AnalysisTemplateRef *AnalysisTemplateRef `json:"analysisTemplateRef,omitempty"`
+
+	// Notifications configures where notifications are sent for canaries
+	// that configure none
+	Notifications *NotificationsSpec `json:"notifications,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// CanaryTemplate is a rollout preset maintained by platform teams, so
+// CanaryDeployments in any namespace only name their workload and routes
+type CanaryTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CanaryTemplateSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CanaryTemplateList contains a list of CanaryTemplate
+type CanaryTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CanaryTemplate `json:"items"`
+}
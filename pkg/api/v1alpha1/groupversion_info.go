@@ -21,4 +21,8 @@ var (
 
 func init() {
 	SchemeBuilder.Register(&CanaryDeployment{}, &CanaryDeploymentList{})
+	SchemeBuilder.Register(&AnalysisTemplate{}, &AnalysisTemplateList{})
+	SchemeBuilder.Register(&ClusterAnalysisTemplate{}, &ClusterAnalysisTemplateList{})
+	SchemeBuilder.Register(&Approval{}, &ApprovalList{})
+	SchemeBuilder.Register(&CanaryTemplate{}, &CanaryTemplateList{})
 }
\ No newline at end of file
@@ -0,0 +1,162 @@
+package v1beta1
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"gateway-cd/pkg/api/v1alpha1"
+)
+
+var _ conversion.Convertible = &CanaryDeployment{}
+
+// ConvertTo converts the canary to the v1alpha1 hub version
+func (src *CanaryDeployment) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1alpha1.CanaryDeployment)
+	if !ok {
+		return fmt.Errorf("expected a v1alpha1 CanaryDeployment but got %T", dstRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Status = src.Status
+
+	spec := &src.Spec
+	dst.Spec = v1alpha1.CanaryDeploymentSpec{
+		TargetRef:                  spec.TargetRef,
+		Service:                    spec.Service,
+		Gateway:                    spec.TrafficRouting.GatewayRef,
+		TemplateRef:                spec.TemplateRef,
+		TrafficSplit:               spec.Strategy.Steps,
+		TenantHeader:               spec.TrafficRouting.TenantHeader,
+		BucketHeader:               spec.TrafficRouting.BucketHeader,
+		Buckets:                    spec.TrafficRouting.Buckets,
+		AnalysisTemplateRef:        spec.Analysis.TemplateRef,
+		AutoPromote:                spec.Strategy.AutoPromote,
+		SkipAnalysis:               spec.Analysis.Skip,
+		DryRun:                     spec.DryRun,
+		Interval:                   spec.Strategy.Interval,
+		ProgressDeadlineSeconds:    spec.Strategy.ProgressDeadlineSeconds,
+		RollbackOnProgressDeadline: spec.Strategy.RollbackOnProgressDeadline,
+		TTLSecondsAfterFinished:    spec.TTLSecondsAfterFinished,
+		Schedule:                   spec.Strategy.Schedule,
+		TimeSlice:                  spec.Strategy.TimeSlice,
+		ABTest:                     spec.Strategy.ABTest,
+		SessionAffinity:            spec.Strategy.SessionAffinity,
+		Hooks:                      spec.Hooks,
+		PreRolloutCheck:            spec.PreRolloutCheck,
+		PropagateRollbackReason:    spec.PropagateRollbackReason,
+		RevertOnRollback:           spec.RevertOnRollback,
+		Rollback:                   spec.Rollback,
+		CloneNetworkPolicies:       spec.CloneNetworkPolicies,
+		ServiceAccount:             spec.ServiceAccount,
+		CanaryScale:                spec.CanaryScale,
+		Baseline:                   spec.Baseline,
+		Approvals:                  spec.Approvals,
+		ConfigRevisions:            spec.ConfigRevisions,
+		Metadata:                   spec.Metadata,
+		Monitoring:                 spec.Monitoring,
+		Notifications:              spec.Notifications,
+	}
+	if mirror := spec.Strategy.Mirror; mirror != nil {
+		dst.Spec.Mirror = true
+		dst.Spec.MirrorDuration = mirror.Duration
+	}
+
+	analysis := &spec.Analysis
+	dst.Spec.Analysis = v1alpha1.AnalysisSpec{
+		Metrics:                   analysis.Metrics,
+		SuccessRate:               analysis.SuccessRate,
+		MaxLatency:                analysis.MaxLatency,
+		MetricsProfile:            analysis.MetricsProfile,
+		AnalysisInterval:          analysis.Interval,
+		SuccessfulIntervals:       analysis.SuccessfulIntervals,
+		FailureLimit:              analysis.FailureLimit,
+		Smoothing:                 analysis.Smoothing,
+		ConsecutiveErrors:         analysis.ConsecutiveErrors,
+		ProviderUnavailablePolicy: analysis.ProviderUnavailablePolicy,
+		Traces:                    analysis.Traces,
+		PodHealth:                 analysis.PodHealth,
+		PostPromotion:             analysis.PostPromotion,
+	}
+	switch len(analysis.Providers) {
+	case 0:
+	case 1:
+		dst.Spec.Analysis.Provider = &analysis.Providers[0]
+	default:
+		return fmt.Errorf("spec.analysis.providers supports one provider, got %d", len(analysis.Providers))
+	}
+	return nil
+}
+
+// ConvertFrom converts the canary from the v1alpha1 hub version
+func (dst *CanaryDeployment) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1alpha1.CanaryDeployment)
+	if !ok {
+		return fmt.Errorf("expected a v1alpha1 CanaryDeployment but got %T", srcRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Status = src.Status
+
+	spec := &src.Spec
+	dst.Spec = CanaryDeploymentSpec{
+		TargetRef:   spec.TargetRef,
+		Service:     spec.Service,
+		TemplateRef: spec.TemplateRef,
+		Strategy: Strategy{
+			Steps:                      spec.TrafficSplit,
+			AutoPromote:                spec.AutoPromote,
+			Interval:                   spec.Interval,
+			ProgressDeadlineSeconds:    spec.ProgressDeadlineSeconds,
+			RollbackOnProgressDeadline: spec.RollbackOnProgressDeadline,
+			Schedule:                   spec.Schedule,
+			TimeSlice:                  spec.TimeSlice,
+			ABTest:                     spec.ABTest,
+			SessionAffinity:            spec.SessionAffinity,
+		},
+		TrafficRouting: TrafficRouting{
+			GatewayRef:   spec.Gateway,
+			TenantHeader: spec.TenantHeader,
+			BucketHeader: spec.BucketHeader,
+			Buckets:      spec.Buckets,
+		},
+		Analysis: Analysis{
+			TemplateRef:               spec.AnalysisTemplateRef,
+			Skip:                      spec.SkipAnalysis,
+			Metrics:                   spec.Analysis.Metrics,
+			SuccessRate:               spec.Analysis.SuccessRate,
+			MaxLatency:                spec.Analysis.MaxLatency,
+			MetricsProfile:            spec.Analysis.MetricsProfile,
+			Interval:                  spec.Analysis.AnalysisInterval,
+			SuccessfulIntervals:       spec.Analysis.SuccessfulIntervals,
+			FailureLimit:              spec.Analysis.FailureLimit,
+			Smoothing:                 spec.Analysis.Smoothing,
+			ConsecutiveErrors:         spec.Analysis.ConsecutiveErrors,
+			ProviderUnavailablePolicy: spec.Analysis.ProviderUnavailablePolicy,
+			Traces:                    spec.Analysis.Traces,
+			PodHealth:                 spec.Analysis.PodHealth,
+			PostPromotion:             spec.Analysis.PostPromotion,
+		},
+		DryRun:                  spec.DryRun,
+		Hooks:                   spec.Hooks,
+		PreRolloutCheck:         spec.PreRolloutCheck,
+		PropagateRollbackReason: spec.PropagateRollbackReason,
+		RevertOnRollback:        spec.RevertOnRollback,
+		Rollback:                spec.Rollback,
+		CloneNetworkPolicies:    spec.CloneNetworkPolicies,
+		ServiceAccount:          spec.ServiceAccount,
+		CanaryScale:             spec.CanaryScale,
+		Baseline:                spec.Baseline,
+		Approvals:               spec.Approvals,
+		ConfigRevisions:         spec.ConfigRevisions,
+		Metadata:                spec.Metadata,
+		Monitoring:              spec.Monitoring,
+		Notifications:           spec.Notifications,
+		TTLSecondsAfterFinished: spec.TTLSecondsAfterFinished,
+	}
+	if spec.Mirror {
+		dst.Spec.Strategy.Mirror = &Mirror{Duration: spec.MirrorDuration}
+	}
+	if provider := spec.Analysis.Provider; provider != nil {
+		dst.Spec.Analysis.Providers = []v1alpha1.ProviderSpec{*provider}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package v1beta1
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"gateway-cd/pkg/api/v1alpha1"
+)
+
+// newHubCanary returns a v1alpha1 canary setting the fields the v1beta1
+// spec regroups
+func newHubCanary() *v1alpha1.CanaryDeployment {
+	deadline := int32(600)
+	return &v1alpha1.CanaryDeployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout", Labels: map[string]string{"team": "payments"}},
+		Spec: v1alpha1.CanaryDeploymentSpec{
+			TargetRef:   v1alpha1.WorkloadRef{APIVersion: "apps/v1", Kind: "Deployment", Name: "checkout"},
+			Service:     v1alpha1.ServiceRef{Name: "checkout", Port: 8080},
+			Gateway:     v1alpha1.GatewayRef{HTTPRoutes: []string{"checkout", "checkout-internal"}},
+			TemplateRef: &v1alpha1.CanaryTemplateRef{Name: "web"},
+			TrafficSplit: []v1alpha1.TrafficSplitStep{
+				{Weight: 10, Duration: "5m"},
+				{Weight: 50, Pause: true},
+			},
+			TenantHeader:        "X-Customer",
+			BucketHeader:        "X-Bucket",
+			Buckets:             1000,
+			AnalysisTemplateRef: &v1alpha1.AnalysisTemplateRef{Name: "http", Kind: "ClusterAnalysisTemplate"},
+			Analysis: v1alpha1.AnalysisSpec{
+				Metrics:                   []v1alpha1.AnalysisMetric{{Name: "errors", Query: "sum(errors)", Threshold: 1, Operator: "<"}},
+				SuccessRate:               0.99,
+				MaxLatency:                500,
+				AnalysisInterval:          "1m",
+				SuccessfulIntervals:       3,
+				FailureLimit:              2,
+				Smoothing:                 &v1alpha1.AnalysisSmoothing{Window: 5},
+				ConsecutiveErrors:         4,
+				ProviderUnavailablePolicy: v1alpha1.ProviderUnavailablePolicyPause,
+				Provider:                  &v1alpha1.ProviderSpec{Type: v1alpha1.ProviderTypePrometheus, Address: "http://prometheus.payments:9090"},
+			},
+			AutoPromote:                true,
+			Mirror:                     true,
+			MirrorDuration:             "10m",
+			ProgressDeadlineSeconds:    &deadline,
+			RollbackOnProgressDeadline: true,
+			Hooks:                      []v1alpha1.HookStep{{Name: "migrate", Type: v1alpha1.HookTypePreRollout}},
+			PropagateRollbackReason:    true,
+			RevertOnRollback:           true,
+			Baseline:                   &v1alpha1.BaselineSpec{StableDeployment: "checkout-stable", Replicas: 2},
+			Metadata:                   &v1alpha1.ChangeMetadata{GitSHA: "abc123", Author: "alice"},
+		},
+		Status: v1alpha1.CanaryDeploymentStatus{Phase: v1alpha1.CanaryDeploymentPhaseProgressing, CanaryWeight: 10},
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	hub := newHubCanary()
+
+	var canary CanaryDeployment
+	if err := canary.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom() failed: %v", err)
+	}
+	if canary.Spec.Strategy.Mirror == nil || canary.Spec.Strategy.Mirror.Duration != "10m" {
+		t.Errorf("strategy.mirror = %+v, want duration 10m", canary.Spec.Strategy.Mirror)
+	}
+	if len(canary.Spec.Analysis.Providers) != 1 || canary.Spec.Analysis.Providers[0].Address != hub.Spec.Analysis.Provider.Address {
+		t.Errorf("analysis.providers = %+v, want the v1alpha1 provider", canary.Spec.Analysis.Providers)
+	}
+	if canary.Spec.TrafficRouting.Buckets != 1000 || canary.Spec.Analysis.Interval != "1m" {
+		t.Errorf("trafficRouting.buckets = %d and analysis.interval = %q, want 1000 and 1m",
+			canary.Spec.TrafficRouting.Buckets, canary.Spec.Analysis.Interval)
+	}
+
+	var got v1alpha1.CanaryDeployment
+	if err := canary.ConvertTo(&got); err != nil {
+		t.Fatalf("ConvertTo() failed: %v", err)
+	}
+	if !equality.Semantic.DeepEqual(&got, hub) {
+		t.Errorf("v1alpha1 -> v1beta1 -> v1alpha1 changed the canary:\ngot  %+v\nwant %+v", got.Spec, hub.Spec)
+	}
+
+	var again CanaryDeployment
+	if err := again.ConvertFrom(&got); err != nil {
+		t.Fatalf("ConvertFrom() failed: %v", err)
+	}
+	if !equality.Semantic.DeepEqual(&again, &canary) {
+		t.Errorf("v1beta1 -> v1alpha1 -> v1beta1 changed the canary:\ngot  %+v\nwant %+v", again.Spec, canary.Spec)
+	}
+}
+
+func TestConvertToMultipleProviders(t *testing.T) {
+	canary := &CanaryDeployment{
+		Spec: CanaryDeploymentSpec{
+			Analysis: Analysis{Providers: []v1alpha1.ProviderSpec{
+				{Type: v1alpha1.ProviderTypePrometheus, Address: "http://a:9090"},
+				{Type: v1alpha1.ProviderTypePrometheus, Address: "http://b:9090"},
+			}},
+		},
+	}
+	err := canary.ConvertTo(&v1alpha1.CanaryDeployment{})
+	if err == nil || !strings.Contains(err.Error(), "supports one provider") {
+		t.Errorf("ConvertTo() error = %v, want one provider error", err)
+	}
+}
@@ -0,0 +1,253 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"gateway-cd/pkg/api/v1alpha1"
+)
+
+// CanaryDeploymentSpec defines the desired state of CanaryDeployment. It
+// groups the fields of the v1alpha1 spec by concern: how the rollout
+// progresses, where traffic is shifted and how the canary is analysed.
+type CanaryDeploymentSpec struct {
+	// TargetRef references the target workload for canary deployment
+	TargetRef v1alpha1.WorkloadRef `json:"targetRef"`
+
+	// Service is the Kubernetes service associated with the workload
+	Service v1alpha1.ServiceRef `json:"service"`
+
+	// TemplateRef references a CanaryTemplate providing the steps, analysis
+	// and notifications the canary doesn't set itself
+	TemplateRef *v1alpha1.CanaryTemplateRef `json:"templateRef,omitempty"`
+
+	// Strategy defines how the rollout progresses
+	Strategy Strategy `json:"strategy,omitempty"`
+
+	// TrafficRouting defines the routes traffic is shifted on
+	TrafficRouting TrafficRouting `json:"trafficRouting"`
+
+	// Analysis defines success criteria and rollback conditions
+	Analysis Analysis `json:"analysis,omitempty"`
+
+	// DryRun keeps the canary Pending and records the simulated rollout
+	// instead of touching routes, workloads or other cluster objects
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Hooks are Jobs run in order at points of the rollout, e.g. a schema
+	// migration before the canary gets traffic and its reversal on rollback
+	Hooks []v1alpha1.HookStep `json:"hooks,omitempty"`
+
+	// PreRolloutCheck smoke tests the canary Service before the first
+	// traffic shift and fails the canary unless it passes
+	PreRolloutCheck *v1alpha1.PreRolloutCheck `json:"preRolloutCheck,omitempty"`
+
+	// PropagateRollbackReason annotates the target workload and emits an Event
+	// on it with the rollback reason when the canary is rolled back
+	PropagateRollbackReason bool `json:"propagateRollbackReason,omitempty"`
+
+	// RevertOnRollback restores the target Deployment's pod template to the
+	// last stable revision on rollback instead of only routing traffic away
+	RevertOnRollback bool `json:"revertOnRollback,omitempty"`
+
+	// Rollback configures what a rollback tears down beyond the canary's
+	// traffic, e.g. scaling the canary Deployment down
+	Rollback *v1alpha1.RollbackSpec `json:"rollback,omitempty"`
+
+	// CloneNetworkPolicies copies the NetworkPolicies selecting the stable pods
+	// to the canary pods, selected by the canary Service, so the canary keeps
+	// the stable network posture
+	CloneNetworkPolicies bool `json:"cloneNetworkPolicies,omitempty"`
+
+	// ServiceAccount runs the canary pods under a ServiceAccount managed by
+	// the controller, e.g. to canary rotated credentials or an IAM policy
+	ServiceAccount *v1alpha1.ServiceAccountSpec `json:"serviceAccount,omitempty"`
+
+	// CanaryScale sets the replicas of the target Deployment at every step and
+	// holds any HorizontalPodAutoscaler targeting it while the rollout runs
+	CanaryScale *v1alpha1.CanaryScaleSpec `json:"canaryScale,omitempty"`
+
+	// Baseline runs a fresh copy of the stable version next to the canary
+	// with the same share of traffic, and analysis compares the canary
+	// against it instead of absolute thresholds
+	Baseline *v1alpha1.BaselineSpec `json:"baseline,omitempty"`
+
+	// Approvals gates paused steps on Approval records that capture who
+	// approved, when and why, instead of the resume annotation
+	Approvals *v1alpha1.ApprovalsSpec `json:"approvals,omitempty"`
+
+	// ConfigRevisions are ConfigMaps and Secrets whose canary version is
+	// mounted by the target Deployment, so config changes are canaried
+	// together with code
+	ConfigRevisions []v1alpha1.ConfigRevision `json:"configRevisions,omitempty"`
+
+	// Metadata describes the change being canaried and is propagated to
+	// status, history, notifications and dashboard annotations
+	Metadata *v1alpha1.ChangeMetadata `json:"metadata,omitempty"`
+
+	// Monitoring configures monitoring assets generated for the canary
+	Monitoring *v1alpha1.MonitoringSpec `json:"monitoring,omitempty"`
+
+	// Notifications configures where rollout notifications are sent
+	Notifications *v1alpha1.NotificationsSpec `json:"notifications,omitempty"`
+
+	// TTLSecondsAfterFinished deletes the canary this long after it Succeeded
+	// or Failed, with the resources the controller created for it. Deletion
+	// restores the routes like any deletion of the canary. Unset keeps it.
+	// Canaries generated from a Deployment ignore it.
+	// +kubebuilder:validation:Minimum=0
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+// Strategy defines how a rollout progresses: its steps, the phases before
+// them and when it may advance
+type Strategy struct {
+	// Steps are the traffic split steps of the rollout. Required unless
+	// TemplateRef provides them.
+	Steps []v1alpha1.TrafficSplitStep `json:"steps,omitempty"`
+
+	// AutoPromote automatically promotes canary to stable if analysis succeeds
+	AutoPromote bool `json:"autoPromote,omitempty"`
+
+	// Interval is how often the controller checks on the rollout while it
+	// progresses, e.g. 2s on a dev cluster or 30s on a large one. Defaults to
+	// the controller's --requeue-interval.
+	Interval string `json:"interval,omitempty"`
+
+	// ProgressDeadlineSeconds is how long the rollout may stay Pending or at
+	// a step without advancing before it is marked Degraded. Waiting for
+	// manual approval doesn't count.
+	// +kubebuilder:validation:Minimum=1
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+
+	// RollbackOnProgressDeadline rolls the canary back when it exceeds the
+	// progress deadline instead of only marking it Degraded
+	RollbackOnProgressDeadline bool `json:"rollbackOnProgressDeadline,omitempty"`
+
+	// Schedule limits the hours in which steps advance. Outside its windows
+	// the canary holds its current weight.
+	Schedule *v1alpha1.ScheduleSpec `json:"schedule,omitempty"`
+
+	// Mirror copies production traffic to the canary without serving its
+	// responses and runs analysis on it before the first step
+	Mirror *Mirror `json:"mirror,omitempty"`
+
+	// TimeSlice alternates the canary between exposure and zero weight for a
+	// number of cycles before the steps start
+	TimeSlice *v1alpha1.TimeSliceSpec `json:"timeSlice,omitempty"`
+
+	// ABTest makes the assignment of users to the canary sticky, by an
+	// assignment cookie or a hash bucket header, instead of weighted per request
+	ABTest *v1alpha1.ABTestSpec `json:"abTest,omitempty"`
+
+	// SessionAffinity keeps clients the canary served on the canary for the
+	// rest of the rollout, so stateful flows don't bounce between versions
+	SessionAffinity *v1alpha1.SessionAffinitySpec `json:"sessionAffinity,omitempty"`
+}
+
+// Mirror configures the traffic mirroring phase of a rollout
+type Mirror struct {
+	// Duration is how long traffic is mirrored before analysis (default 5m)
+	Duration string `json:"duration,omitempty"`
+}
+
+// TrafficRouting defines the routes and gateway traffic is shifted on and
+// the request headers steps split by
+type TrafficRouting struct {
+	v1alpha1.GatewayRef `json:",inline"`
+
+	// TenantHeader is the request header identifying the tenant when steps list
+	// tenants to ramp by customer instead of by weight. Defaults to X-Tenant-ID.
+	TenantHeader string `json:"tenantHeader,omitempty"`
+
+	// BucketHeader is the request header carrying a hash bucket in [0, Buckets),
+	// set upstream from e.g. a user or request ID, used by fractional weight
+	// steps. Defaults to X-Canary-Bucket.
+	BucketHeader string `json:"bucketHeader,omitempty"`
+
+	// Buckets is the number of hash buckets in BucketHeader. Defaults to 100.
+	Buckets int32 `json:"buckets,omitempty"`
+}
+
+// Analysis defines the success criteria of a canary and the providers they
+// are evaluated with
+type Analysis struct {
+	// TemplateRef references a shared analysis policy. Fields set here
+	// override the template and metrics are merged by name.
+	TemplateRef *v1alpha1.AnalysisTemplateRef `json:"templateRef,omitempty"`
+	// Skip skips canary analysis (useful for testing)
+	Skip bool `json:"skip,omitempty"`
+	// Metrics to evaluate during canary analysis
+	Metrics []v1alpha1.AnalysisMetric `json:"metrics,omitempty"`
+	// SuccessRate is the minimum success rate threshold (0.0-1.0)
+	SuccessRate float64 `json:"successRate,omitempty"`
+	// MaxLatency is the maximum acceptable latency in milliseconds
+	MaxLatency int32 `json:"maxLatency,omitempty"`
+	// MetricsProfile selects the built-in success rate and latency queries
+	// for the metrics of a mesh or gateway (Istio, Linkerd,
+	// NGINXGatewayFabric or EnvoyGateway). Defaults to the generic
+	// http_requests_total and http_request_duration_seconds metrics.
+	MetricsProfile v1alpha1.MetricsProfile `json:"metricsProfile,omitempty"`
+	// Interval is how often to run analysis
+	Interval string `json:"interval,omitempty"`
+	// SuccessfulIntervals is the number of passed analysis intervals a step
+	// needs before it advances when Interval is set. Defaults to the step
+	// duration divided by the interval.
+	SuccessfulIntervals int32 `json:"successfulIntervals,omitempty"`
+	// FailureLimit is the number of consecutive failed analysis runs that
+	// trigger a rollback. Defaults to 1.
+	FailureLimit int32 `json:"failureLimit,omitempty"`
+	// Smoothing takes the verdict of an analysis run over the last runs of
+	// the rollout, so momentary metric blips at low traffic don't fail it
+	Smoothing *v1alpha1.AnalysisSmoothing `json:"smoothing,omitempty"`
+	// ConsecutiveErrors is the number of consecutive analysis runs that could
+	// not be completed before the canary is rolled back. Unset retries forever.
+	ConsecutiveErrors int32 `json:"consecutiveErrors,omitempty"`
+	// ProviderUnavailablePolicy is applied when the metrics provider is
+	// unavailable (Retry, Skip, Pause or Rollback). Defaults to Retry.
+	ProviderUnavailablePolicy v1alpha1.ProviderUnavailablePolicy `json:"providerUnavailablePolicy,omitempty"`
+	// Providers override the controller's metrics provider, e.g. to query the
+	// Prometheus instance of the canary's team. One provider is supported.
+	// +kubebuilder:validation:MaxItems=1
+	Providers []v1alpha1.ProviderSpec `json:"providers,omitempty"`
+	// Traces analyses canary spans in a trace backend (Tempo or Jaeger)
+	Traces *v1alpha1.TraceAnalysis `json:"traces,omitempty"`
+	// PodHealth rolls the canary back on crash-looping, restarting,
+	// OOMKilled or not ready canary pods, without waiting for metrics
+	PodHealth *v1alpha1.PodHealthCheck `json:"podHealth,omitempty"`
+	// PostPromotion keeps analysing the canary at full traffic for a soak
+	// period after the last step, and rolls it back if it degrades, before
+	// the rollout succeeds and the stable workload is released
+	PostPromotion *v1alpha1.PostPromotionAnalysis `json:"postPromotion,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Health",type="string",JSONPath=".status.health",priority=1
+//+kubebuilder:printcolumn:name="Canary Weight",type="integer",JSONPath=".status.canaryWeight"
+//+kubebuilder:printcolumn:name="Step",type="integer",JSONPath=".status.currentStep"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+//+kubebuilder:printcolumn:name="Route",type="string",JSONPath=".status.managedRoute",priority=1
+//+kubebuilder:printcolumn:name="Applied Weight",type="integer",JSONPath=".status.lastAppliedWeight",priority=1
+//+kubebuilder:printcolumn:name="Route Generation",type="integer",JSONPath=".status.routeGeneration",priority=1
+//+kubebuilder:printcolumn:name="Route Updated",type="date",JSONPath=".status.routeUpdatedTime",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// CanaryDeployment is the Schema for the canarydeployments API. Objects are
+// stored as v1alpha1 and converted by the conversion webhook.
+type CanaryDeployment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CanaryDeploymentSpec            `json:"spec,omitempty"`
+	Status v1alpha1.CanaryDeploymentStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CanaryDeploymentList contains a list of CanaryDeployment
+type CanaryDeploymentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CanaryDeployment `json:"items"`
+}
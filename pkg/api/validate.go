@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/preflight"
+)
+
+// validateCanaryDeployment validates the canary deployment in the request
+// body against the CRD schema, the admission checks and the cluster without
+// creating it, returning every error and warning found
+func (s *Server) validateCanaryDeployment(c *gin.Context) {
+	cluster, cl, ok := s.requestCluster(c)
+	if !ok {
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var obj interface{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schema, err := s.canarySchema(c.Request.Context(), cluster, cl)
+	if err != nil {
+		log.Printf("Skipping schema validation: %v", err)
+	} else if errs := validateSchema(nil, obj, schema); len(errs) > 0 {
+		c.JSON(http.StatusOK, preflight.Invalid(errs))
+		return
+	}
+
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if err := json.Unmarshal(body, &canary); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preflight.Validate(c.Request.Context(), cl, &canary))
+}
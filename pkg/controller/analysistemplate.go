@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// resolveAnalysisTemplate merges the referenced analysis template into the
+// in-memory spec. The merged analysis is never written back, updateStatus and
+// removeAnnotations keep it across writes.
+func (r *CanaryDeploymentReconciler) resolveAnalysisTemplate(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	ref := canary.Spec.AnalysisTemplateRef
+	if ref == nil {
+		return nil
+	}
+
+	var template gatewaycdv1alpha1.AnalysisSpec
+	switch ref.Kind {
+	case "", gatewaycdv1alpha1.AnalysisTemplateKind:
+		var t gatewaycdv1alpha1.AnalysisTemplate
+		if err := r.Get(ctx, types.NamespacedName{Namespace: canary.Namespace, Name: ref.Name}, &t); err != nil {
+			return fmt.Errorf("failed to get AnalysisTemplate %s/%s: %w", canary.Namespace, ref.Name, err)
+		}
+		template = t.Spec
+	case gatewaycdv1alpha1.ClusterAnalysisTemplateKind:
+		var t gatewaycdv1alpha1.ClusterAnalysisTemplate
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name}, &t); err != nil {
+			return fmt.Errorf("failed to get ClusterAnalysisTemplate %s: %w", ref.Name, err)
+		}
+		template = t.Spec
+	default:
+		return fmt.Errorf("unsupported analysis template kind %q", ref.Kind)
+	}
+
+	canary.Spec.Analysis = mergeAnalysis(template, canary.Spec.Analysis)
+	return nil
+}
+
+// mergeAnalysis overlays the fields set inline on the template. Inline metrics
+// replace template metrics of the same name and are appended otherwise.
+func mergeAnalysis(template, inline gatewaycdv1alpha1.AnalysisSpec) gatewaycdv1alpha1.AnalysisSpec {
+	merged := *template.DeepCopy()
+
+	for _, metric := range inline.Metrics {
+		replaced := false
+		for i := range merged.Metrics {
+			if merged.Metrics[i].Name == metric.Name {
+				merged.Metrics[i] = metric
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged.Metrics = append(merged.Metrics, metric)
+		}
+	}
+	if inline.SuccessRate > 0 {
+		merged.SuccessRate = inline.SuccessRate
+	}
+	if inline.MaxLatency > 0 {
+		merged.MaxLatency = inline.MaxLatency
+	}
+	if inline.MetricsProfile != "" {
+		merged.MetricsProfile = inline.MetricsProfile
+	}
+	if inline.AnalysisInterval != "" {
+		merged.AnalysisInterval = inline.AnalysisInterval
+	}
+	if inline.SuccessfulIntervals > 0 {
+		merged.SuccessfulIntervals = inline.SuccessfulIntervals
+	}
+	if inline.FailureLimit > 0 {
+		merged.FailureLimit = inline.FailureLimit
+	}
+	if inline.Smoothing != nil {
+		merged.Smoothing = inline.Smoothing.DeepCopy()
+	}
+	if inline.ConsecutiveErrors > 0 {
+		merged.ConsecutiveErrors = inline.ConsecutiveErrors
+	}
+	if inline.Provider != nil {
+		merged.Provider = inline.Provider.DeepCopy()
+	}
+	if inline.ProviderUnavailablePolicy != "" {
+		merged.ProviderUnavailablePolicy = inline.ProviderUnavailablePolicy
+	}
+	if inline.Traces != nil {
+		merged.Traces = inline.Traces.DeepCopy()
+	}
+	if inline.PodHealth != nil {
+		merged.PodHealth = inline.PodHealth.DeepCopy()
+	}
+	if inline.PostPromotion != nil {
+		merged.PostPromotion = inline.PostPromotion.DeepCopy()
+	}
+	return merged
+}
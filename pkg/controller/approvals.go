@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=gateway-cd.io,resources=approvals,verbs=get;list;watch
+
+// requiredApprovals is the number of distinct approvers a paused step needs
+func requiredApprovals(canary *gatewaycdv1alpha1.CanaryDeployment) int {
+	if canary.Spec.Approvals.Required > 0 {
+		return int(canary.Spec.Approvals.Required)
+	}
+	return 1
+}
+
+// approverAllowed reports whether approvals of user count for the canary
+func approverAllowed(canary *gatewaycdv1alpha1.CanaryDeployment, user string) bool {
+	if user == "" {
+		return false
+	}
+	if len(canary.Spec.Approvals.Approvers) == 0 {
+		return true
+	}
+	for _, approver := range canary.Spec.Approvals.Approvers {
+		if approver == user {
+			return true
+		}
+	}
+	return false
+}
+
+// handleApprovals continues a paused step once enough distinct approvers have
+// approved it and rolls back on the first rejection. Approvals created before
+// the rollout started belong to an earlier rollout and are ignored.
+func (r *CanaryDeploymentReconciler) handleApprovals(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	// Resume and promote cannot skip the approvals of the step
+	for _, control := range []struct {
+		action     gatewaycdv1alpha1.ControlActionType
+		annotation string
+	}{
+		{gatewaycdv1alpha1.ControlActionResume, "gateway-cd.io/resume"},
+		{gatewaycdv1alpha1.ControlActionPromote, annotationPromote},
+	} {
+		annotation := control.annotation
+		if canary.Annotations[annotation] != "true" {
+			continue
+		}
+		action := newControlAction(canary, control.action)
+		r.auditControlAction(canary, action, "Ignored, the step requires an Approval")
+		if err := r.removeAnnotations(ctx, canary, annotation, annotationRequestedBy, annotationRequestedVia, annotationAdmittedBy); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonApprovalRequired, "Ignored %s of step %d, the step requires an Approval",
+			strings.ToLower(string(control.action)), canary.Status.CurrentStep+1)
+	}
+
+	var list gatewaycdv1alpha1.ApprovalList
+	if err := r.List(ctx, &list, client.InNamespace(canary.Namespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list approvals: %w", err)
+	}
+	approvals := list.Items
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].CreationTimestamp.Before(&approvals[j].CreationTimestamp)
+	})
+
+	step := canary.Status.CurrentStep
+	seen := map[string]bool{}
+	var approved []gatewaycdv1alpha1.ApprovalRecord
+	for _, approval := range approvals {
+		spec := approval.Spec
+		if spec.CanaryName != canary.Name || spec.Step != step || seen[spec.Approver] || !approverAllowed(canary, spec.Approver) {
+			continue
+		}
+		if started := canary.Status.StartedTime; started != nil && approval.CreationTimestamp.Time.Before(started.Time.Truncate(time.Second)) {
+			continue
+		}
+		seen[spec.Approver] = true
+
+		record := gatewaycdv1alpha1.ApprovalRecord{
+			Step:     step,
+			Approver: spec.Approver,
+			Decision: spec.Decision,
+			Comment:  spec.Comment,
+			Time:     approval.CreationTimestamp,
+		}
+		if record.Decision == gatewaycdv1alpha1.ApprovalDecisionRejected {
+			return r.rejectStep(ctx, canary, record)
+		}
+		record.Decision = gatewaycdv1alpha1.ApprovalDecisionApproved
+		approved = append(approved, record)
+	}
+
+	if len(approved) < requiredApprovals(canary) {
+		message := fmt.Sprintf("Waiting for approval of step %d (%d of %d approvals)", step+1, len(approved), requiredApprovals(canary))
+		if canary.Status.Message != message {
+			canary.Status.Message = message
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+
+	approvers := make([]string, 0, len(approved))
+	for _, record := range approved {
+		approvers = append(approvers, record.Approver)
+	}
+	canary.Status.Approvals = append(canary.Status.Approvals, approved...)
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing
+	canary.Status.CurrentStep++
+	canary.Status.WeightsAppliedTime = nil
+	canary.Status.WeightsProgrammed = false
+	recordStepTransition(canary, strings.Join(approvers, ", "))
+	canary.Status.Message = fmt.Sprintf("Step %d approved by %s", step+1, strings.Join(approvers, ", "))
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.event(canary, EventReasonApproved, "%s", canary.Status.Message)
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+}
+
+// rejectStep rolls the canary back after a paused step was rejected
+func (r *CanaryDeploymentReconciler) rejectStep(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, record gatewaycdv1alpha1.ApprovalRecord) (ctrl.Result, error) {
+	reason := fmt.Sprintf("Step %d rejected by %s", record.Step+1, record.Approver)
+	if record.Comment != "" {
+		reason = fmt.Sprintf("%s: %s", reason, record.Comment)
+	}
+
+	canary.Status.Approvals = append(canary.Status.Approvals, record)
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+	canary.Status.Message = reason
+	canary.Status.RollbackReason = reason
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.warning(canary, EventReasonRejected, "%s", reason)
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+}
+
+// approvalRequests maps an Approval to a reconcile of the canary it decides on
+func approvalRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	approval, ok := obj.(*gatewaycdv1alpha1.Approval)
+	if !ok || approval.Spec.CanaryName == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{
+		Namespace: approval.Namespace,
+		Name:      approval.Spec.CanaryName,
+	}}}
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// annotationRequestedBy records who requested the pending control action
+	annotationRequestedBy = "gateway-cd.io/requested-by"
+	// annotationRequestedVia records where the pending control action was
+	// requested (API, CLI or Slack). Actions without it were set on the
+	// canary directly.
+	annotationRequestedVia = "gateway-cd.io/requested-via"
+	// annotationAdmittedBy records the authenticated user that set the
+	// pending control action; only the admission webhook writes it
+	annotationAdmittedBy = "gateway-cd.io/admitted-by"
+	// annotationPromote skips the remaining steps of a paused rollout
+	annotationPromote = "gateway-cd.io/promote"
+)
+
+// newControlAction starts the audit record of a control action from the
+// requester annotations and the rollout state before the action. Call it
+// before the action changes the status. The requester annotations can be set
+// by anyone allowed to annotate the canary, so the user only counts as
+// verified when the admission webhook admitted the action as that user.
+func newControlAction(canary *gatewaycdv1alpha1.CanaryDeployment, action gatewaycdv1alpha1.ControlActionType) gatewaycdv1alpha1.ControlAction {
+	source := gatewaycdv1alpha1.ControlSource(canary.Annotations[annotationRequestedVia])
+	if source == "" {
+		source = gatewaycdv1alpha1.ControlSourceAnnotation
+	}
+	user := canary.Annotations[annotationRequestedBy]
+	admittedBy := canary.Annotations[annotationAdmittedBy]
+	return gatewaycdv1alpha1.ControlAction{
+		Action:         action,
+		Source:         source,
+		User:           user,
+		AdmittedBy:     admittedBy,
+		Verified:       admittedBy != "" && admittedBy == user,
+		Time:           metav1.Now(),
+		PreviousPhase:  canary.Status.Phase,
+		PreviousStep:   canary.Status.CurrentStep,
+		PreviousWeight: canary.Status.CanaryWeight,
+	}
+}
+
+// auditControlAction appends a control action to status.audit with the
+// outcome message and records it as a ControlAction event. The status is
+// written by the caller.
+func (r *CanaryDeploymentReconciler) auditControlAction(canary *gatewaycdv1alpha1.CanaryDeployment, action gatewaycdv1alpha1.ControlAction, message string) {
+	action.Message = message
+	canary.Status.Audit = append(canary.Status.Audit, action)
+
+	user := action.User
+	if user == "" {
+		user = "unknown user"
+	} else if !action.Verified {
+		user += " (unverified)"
+	}
+	r.event(canary, EventReasonControlAction, "%s by %s via %s at step %d (%s, %d%% canary): %s",
+		action.Action, user, action.Source, action.PreviousStep+1, action.PreviousPhase, action.PreviousWeight, message)
+}
@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// Annotations of Deployments the auto-canary controller generates a
+// CanaryDeployment for
+const (
+	// AnnotationEnabled opts a Deployment into a generated canary when "true"
+	AnnotationEnabled = "gateway-cd.io/enabled"
+	// AnnotationRoute names the HTTPRoute shifted by the generated canary
+	AnnotationRoute = "gateway-cd.io/route"
+	// AnnotationService names the Service of the Deployment (default its name)
+	AnnotationService = "gateway-cd.io/service"
+	// AnnotationPort is the Service port (default 80)
+	AnnotationPort = "gateway-cd.io/port"
+	// AnnotationSteps lists the step weights, optionally with a duration,
+	// e.g. "10,25:10m,50,100"
+	AnnotationSteps = "gateway-cd.io/steps"
+	// AnnotationStepDuration is the duration of steps listing none
+	AnnotationStepDuration = "gateway-cd.io/step-duration"
+	// AnnotationTemplate names the CanaryTemplate of the generated canary
+	AnnotationTemplate = "gateway-cd.io/template"
+	// AnnotationSuccessRate is the minimum success rate of the analysis
+	AnnotationSuccessRate = "gateway-cd.io/success-rate"
+)
+
+const (
+	// labelGeneratedFrom marks canaries generated from a Deployment
+	labelGeneratedFrom = "gateway-cd.io/generated-from"
+
+	defaultAutoCanarySteps        = "10,25,50,100"
+	defaultAutoCanaryStepDuration = "5m"
+	defaultAutoCanaryPort         = 80
+)
+
+// Event reasons emitted on annotated Deployments
+const (
+	EventReasonCanaryGenerated     = "CanaryGenerated"
+	EventReasonCanaryRemoved       = "CanaryRemoved"
+	EventReasonAutoCanaryInvalid   = "AutoCanaryInvalid"
+	EventReasonAutoCanaryConflicts = "AutoCanaryConflicts"
+)
+
+// errCanaryNotOwned is returned when a canary named after an annotated
+// Deployment exists but wasn't generated from it
+var errCanaryNotOwned = errors.New("canary is not owned by the Deployment")
+
+// AutoCanaryReconciler generates a CanaryDeployment for every Deployment
+// annotated with gateway-cd.io/enabled: "true" from its annotations, and
+// deletes it when the annotation is removed
+type AutoCanaryReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Selector restricts the Deployments canaries are generated for, e.g. to
+	// the labels of the canaries a sharded engine manages; nil selects all
+	Selector labels.Selector
+}
+
+// Reconcile creates, updates or deletes the canary of a Deployment
+func (r *AutoCanaryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var deployment appsv1.Deployment
+	if err := r.Get(ctx, req.NamespacedName, &deployment); err != nil {
+		// The generated canary is garbage collected with the Deployment
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if deployment.DeletionTimestamp != nil || deployment.Annotations[AnnotationEnabled] != "true" {
+		return ctrl.Result{}, r.removeCanary(ctx, &deployment)
+	}
+	if r.Selector != nil && !r.Selector.Matches(labels.Set(deployment.Labels)) {
+		return ctrl.Result{}, nil
+	}
+
+	spec, err := autoCanarySpec(&deployment)
+	if err != nil {
+		r.Recorder.Eventf(&deployment, corev1.EventTypeWarning, EventReasonAutoCanaryInvalid,
+			"Cannot generate a canary: %v", err)
+		return ctrl.Result{}, nil
+	}
+
+	canary := &gatewaycdv1alpha1.CanaryDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: deployment.Name, Namespace: deployment.Namespace},
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, canary, func() error {
+		if canary.ResourceVersion != "" && !metav1.IsControlledBy(canary, &deployment) {
+			return errCanaryNotOwned
+		}
+		if canary.Labels == nil {
+			canary.Labels = make(map[string]string)
+		}
+		// The Deployment's labels let sharded engines select the canary
+		for k, v := range deployment.Labels {
+			canary.Labels[k] = v
+		}
+		canary.Labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+		canary.Labels[labelGeneratedFrom] = deployment.Name
+
+		// Fields the annotations don't cover, e.g. the analysis metrics, are
+		// left to edits of the canary
+		canary.Spec.TargetRef = spec.TargetRef
+		canary.Spec.Service = spec.Service
+		canary.Spec.Gateway.HTTPRoute = spec.Gateway.HTTPRoute
+		canary.Spec.TemplateRef = spec.TemplateRef
+		canary.Spec.TrafficSplit = spec.TrafficSplit
+		canary.Spec.Analysis.SuccessRate = spec.Analysis.SuccessRate
+		return controllerutil.SetControllerReference(&deployment, canary, r.Scheme)
+	})
+	if errors.Is(err, errCanaryNotOwned) {
+		r.Recorder.Eventf(&deployment, corev1.EventTypeWarning, EventReasonAutoCanaryConflicts,
+			"CanaryDeployment %s already exists and was not generated from this Deployment", canary.Name)
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile CanaryDeployment %s/%s: %w", canary.Namespace, canary.Name, err)
+	}
+	if result != controllerutil.OperationResultNone {
+		log.Info("Generated canary from Deployment annotations", "canary", canary.Name, "operation", result)
+		r.Recorder.Eventf(&deployment, corev1.EventTypeNormal, EventReasonCanaryGenerated,
+			"CanaryDeployment %s %s from the Deployment's annotations", canary.Name, result)
+	}
+	return ctrl.Result{}, nil
+}
+
+// removeCanary deletes the canary generated from a Deployment, if any. Its
+// finalizer restores the routes before it is gone.
+func (r *AutoCanaryReconciler) removeCanary(ctx context.Context, deployment *appsv1.Deployment) error {
+	var canary gatewaycdv1alpha1.CanaryDeployment
+	if err := r.Get(ctx, client.ObjectKeyFromObject(deployment), &canary); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(&canary, deployment) || canary.DeletionTimestamp != nil {
+		return nil
+	}
+	if err := r.Delete(ctx, &canary); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete CanaryDeployment %s/%s: %w", canary.Namespace, canary.Name, err)
+	}
+	if deployment.DeletionTimestamp == nil {
+		r.Recorder.Eventf(deployment, corev1.EventTypeNormal, EventReasonCanaryRemoved,
+			"CanaryDeployment %s deleted as %s is no longer \"true\"", canary.Name, AnnotationEnabled)
+	}
+	return nil
+}
+
+// autoCanarySpec builds the spec of the canary of a Deployment from its
+// annotations. The Services are managed, so the single Deployment is rolled
+// out by pod-template-hash.
+func autoCanarySpec(deployment *appsv1.Deployment) (*gatewaycdv1alpha1.CanaryDeploymentSpec, error) {
+	annotations := deployment.Annotations
+	route := annotations[AnnotationRoute]
+	if route == "" {
+		return nil, fmt.Errorf("%s is required", AnnotationRoute)
+	}
+	service := annotations[AnnotationService]
+	if service == "" {
+		service = deployment.Name
+	}
+	port := int32(defaultAutoCanaryPort)
+	if value := annotations[AnnotationPort]; value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || parsed < 1 || parsed > 65535 {
+			return nil, fmt.Errorf("%s: invalid port %q", AnnotationPort, value)
+		}
+		port = int32(parsed)
+	}
+
+	spec := &gatewaycdv1alpha1.CanaryDeploymentSpec{
+		TargetRef: gatewaycdv1alpha1.WorkloadRef{APIVersion: "apps/v1", Kind: "Deployment", Name: deployment.Name},
+		Service:   gatewaycdv1alpha1.ServiceRef{Name: service, Port: port, Managed: true},
+		Gateway:   gatewaycdv1alpha1.GatewayRef{HTTPRoute: route},
+	}
+	if template := annotations[AnnotationTemplate]; template != "" {
+		spec.TemplateRef = &gatewaycdv1alpha1.CanaryTemplateRef{Name: template}
+	}
+
+	// The template provides the steps unless they are annotated
+	steps := annotations[AnnotationSteps]
+	if steps == "" && spec.TemplateRef == nil {
+		steps = defaultAutoCanarySteps
+	}
+	if steps != "" {
+		duration := annotations[AnnotationStepDuration]
+		if duration == "" {
+			duration = defaultAutoCanaryStepDuration
+		}
+		if _, err := time.ParseDuration(duration); err != nil {
+			return nil, fmt.Errorf("%s: invalid duration %q", AnnotationStepDuration, duration)
+		}
+		split, err := parseSteps(steps, duration)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", AnnotationSteps, err)
+		}
+		spec.TrafficSplit = split
+	}
+
+	if value := annotations[AnnotationSuccessRate]; value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%s: invalid success rate %q, must be between 0 and 1", AnnotationSuccessRate, value)
+		}
+		spec.Analysis.SuccessRate = rate
+	}
+	return spec, nil
+}
+
+// parseSteps parses comma-separated step weights, each optionally followed
+// by ":duration", e.g. "10,25:10m,50,100". The last weight must be 100.
+func parseSteps(value, defaultDuration string) ([]gatewaycdv1alpha1.TrafficSplitStep, error) {
+	var steps []gatewaycdv1alpha1.TrafficSplitStep
+	previous := int64(0)
+	for _, field := range strings.Split(value, ",") {
+		weightText, duration, hasDuration := strings.Cut(strings.TrimSpace(field), ":")
+		weight, err := strconv.ParseInt(strings.TrimSpace(weightText), 10, 32)
+		if err != nil || weight < 1 || weight > 100 {
+			return nil, fmt.Errorf("invalid weight %q, must be between 1 and 100", weightText)
+		}
+		if weight <= previous {
+			return nil, fmt.Errorf("weight %d must be greater than the previous step's %d", weight, previous)
+		}
+		previous = weight
+
+		// The canary serves all traffic at 100, so the last step only waits
+		// when it lists a duration
+		duration = strings.TrimSpace(duration)
+		if !hasDuration && weight < 100 {
+			duration = defaultDuration
+		} else if _, err := time.ParseDuration(duration); hasDuration && err != nil {
+			return nil, fmt.Errorf("invalid duration %q of weight %d", duration, weight)
+		}
+		steps = append(steps, gatewaycdv1alpha1.TrafficSplitStep{Weight: int32(weight), Duration: duration})
+	}
+	if previous != 100 {
+		return nil, fmt.Errorf("the last weight must be 100")
+	}
+	return steps, nil
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *AutoCanaryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Status updates of the generated canaries don't change their spec
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("autocanary").
+		For(&appsv1.Deployment{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}, predicate.LabelChangedPredicate{}))).
+		Owns(&gatewaycdv1alpha1.CanaryDeployment{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
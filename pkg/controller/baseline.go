@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/metrics"
+)
+
+// labelBaseline selects the baseline pods of a canary by the canary name
+const labelBaseline = "gateway-cd.io/baseline"
+
+// reconcileBaseline runs the baseline of the canary: a Deployment with the
+// pod template of the stable Deployment and a Service selecting it. Its pods
+// drop the labels the stable and canary Services select on, so only the
+// baseline Service routes to them. It reports whether the baseline runs its
+// replicas, so the step's traffic only shifts once the baseline can take its
+// share.
+func (r *CanaryDeploymentReconciler) reconcileBaseline(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (bool, error) {
+	spec := canary.Spec.Baseline
+	if spec == nil {
+		return true, nil
+	}
+
+	stable := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: spec.StableDeployment, Namespace: canary.Namespace}, stable); err != nil {
+		return false, fmt.Errorf("failed to get stable Deployment %s/%s: %w", canary.Namespace, spec.StableDeployment, err)
+	}
+	stableService := &corev1.Service{}
+	if err := r.Get(ctx, types.NamespacedName{Name: canary.Spec.Service.Name, Namespace: canary.Namespace}, stableService); err != nil {
+		return false, fmt.Errorf("failed to get Service %s/%s: %w", canary.Namespace, canary.Spec.Service.Name, err)
+	}
+	canaryPods, err := r.serviceSelector(ctx, canary.Namespace, canary.Spec.Service.Name+"-canary")
+	if err != nil {
+		return false, err
+	}
+	replicas, err := r.baselineReplicas(ctx, canary)
+	if err != nil {
+		return false, err
+	}
+
+	podLabels := map[string]string{labelBaseline: canary.Name}
+	for k, v := range stable.Spec.Template.Labels {
+		if _, selected := stableService.Spec.Selector[k]; selected {
+			continue
+		}
+		if _, selected := canaryPods[k]; selected {
+			continue
+		}
+		podLabels[k] = v
+	}
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: canary.Name + "-baseline", Namespace: canary.Namespace}}
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, deployment, func() error {
+		if deployment.Labels == nil {
+			deployment.Labels = make(map[string]string)
+		}
+		deployment.Labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+		deployment.Labels[labelCanary] = canary.Name
+		deployment.Spec.Replicas = &replicas
+		// The selector is immutable, so it is only set on creation
+		if deployment.Spec.Selector == nil {
+			deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{labelBaseline: canary.Name}}
+		}
+		deployment.Spec.Template = *stable.Spec.Template.DeepCopy()
+		deployment.Spec.Template.Labels = podLabels
+		return controllerutil.SetControllerReference(canary, deployment, r.Scheme)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to reconcile baseline Deployment %s/%s: %w", deployment.Namespace, deployment.Name, err)
+	}
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: metrics.BaselineService(canary), Namespace: canary.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, func() error {
+		if service.Labels == nil {
+			service.Labels = make(map[string]string)
+		}
+		service.Labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+		service.Labels[labelCanary] = canary.Name
+		service.Spec.Selector = map[string]string{labelBaseline: canary.Name}
+		service.Spec.Ports = make([]corev1.ServicePort, len(stableService.Spec.Ports))
+		for i, port := range stableService.Spec.Ports {
+			service.Spec.Ports[i] = corev1.ServicePort{
+				Name:        port.Name,
+				Protocol:    port.Protocol,
+				AppProtocol: port.AppProtocol,
+				Port:        port.Port,
+				TargetPort:  port.TargetPort,
+			}
+		}
+		return controllerutil.SetControllerReference(canary, service, r.Scheme)
+	}); err != nil {
+		return false, fmt.Errorf("failed to reconcile baseline Service %s/%s: %w", service.Namespace, service.Name, err)
+	}
+
+	if op == controllerutil.OperationResultCreated {
+		r.event(canary, EventReasonBaselineCreated, "Started baseline Deployment %s with %d replicas of Deployment %s",
+			deployment.Name, replicas, stable.Name)
+		return false, nil
+	}
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.ReadyReplicas >= replicas, nil
+}
+
+// baselineReplicas is the replicas of the baseline: the configured count or,
+// by default, as many as the canary runs
+func (r *CanaryDeploymentReconciler) baselineReplicas(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (int32, error) {
+	if replicas := canary.Spec.Baseline.Replicas; replicas > 0 {
+		return replicas, nil
+	}
+	if canary.Status.CanaryReplicas > 0 {
+		return canary.Status.CanaryReplicas, nil
+	}
+	if canary.Spec.TargetRef.Kind != "Deployment" {
+		return 1, nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return 0, err
+	}
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas < 1 {
+		return 1, nil
+	}
+	return *deployment.Spec.Replicas, nil
+}
+
+// removeBaseline deletes the baseline Deployment and Service of the canary
+// once no route sends traffic to them
+func (r *CanaryDeploymentReconciler) removeBaseline(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: canary.Name + "-baseline", Namespace: canary.Namespace}}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: metrics.BaselineService(canary), Namespace: canary.Namespace}}
+
+	removed := false
+	for _, obj := range []client.Object{deployment, service} {
+		err := r.Delete(ctx, obj)
+		if client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete baseline %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+		}
+		removed = removed || err == nil
+	}
+	if removed {
+		r.event(canary, EventReasonBaselineRemoved, "Removed baseline Deployment %s", deployment.Name)
+	}
+	return nil
+}
@@ -2,38 +2,93 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
 
 	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
 	"gateway-cd/pkg/gateway"
+	"gateway-cd/pkg/grafana"
+	"gateway-cd/pkg/integrations/scm"
 	"gateway-cd/pkg/metrics"
+	"gateway-cd/pkg/notifications"
+	"gateway-cd/pkg/otlp"
+	"gateway-cd/pkg/quota"
+	"gateway-cd/pkg/retry"
 )
 
 // CanaryDeploymentReconciler reconciles a CanaryDeployment object
 type CanaryDeploymentReconciler struct {
 	client.Client
 	Scheme          *runtime.Scheme
+	Recorder        record.EventRecorder
 	GatewayManager  *gateway.Manager
 	MetricsProvider metrics.Provider
+	Annotator       *grafana.Annotator
+	Timeline        *otlp.Exporter
+	Tracer          *otlp.Tracer
+	Notifier        *notifications.Notifier
+	// SCM reports rollout progress to GitHub or GitLab deployments when set
+	SCM *scm.Reporter
+	// APIReader reads pods, nodes and ResourceQuotas for the capacity check
+	// and provider Secrets without caching them cluster-wide, and the latest
+	// canary when a status patch conflicts
+	APIReader client.Reader
+	// Providers holds the metrics providers of canaries that override the
+	// controller's provider
+	Providers *metrics.ProviderCache
+	// Quotas queues rollouts of teams at their concurrent rollout quota; nil
+	// enforces none
+	Quotas *quota.Checker
+	// Retry is the backoff and max retries of failed gateway operations
+	Retry retry.Policy
+	// Requeue is how soon canaries are reconciled again
+	Requeue RequeueIntervals
+	// DriftCheckInterval is how often the routes of active rollouts are
+	// compared with the weights last written to them; 0 only checks them on
+	// route events and reconciles
+	DriftCheckInterval time.Duration
 }
 
+// FinalizerName holds deletion of a CanaryDeployment until its routes are restored
+const FinalizerName = "gateway-cd.io/finalizer"
+
 //+kubebuilder:rbac:groups=gateway-cd.io,resources=canarydeployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=gateway-cd.io,resources=canarydeployments/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=gateway-cd.io,resources=canarydeployments/finalizers,verbs=update
+//+kubebuilder:rbac:groups=gateway-cd.io,resources=analysistemplates;canarytemplates;clusteranalysistemplates,verbs=get;list;watch
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
-//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=grpcroutes,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update;delete
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop
-func (r *CanaryDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *CanaryDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	log := log.FromContext(ctx)
 
 	// Fetch the CanaryDeployment instance
@@ -41,17 +96,40 @@ func (r *CanaryDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	if err := r.Get(ctx, req.NamespacedName, &canary); err != nil {
 		if apierrors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
+			forgetCanaryMetrics(req.Namespace, req.Name)
+			r.Providers.Forget(req.String())
 			return ctrl.Result{}, nil
 		}
 		log.Error(err, "unable to fetch CanaryDeployment")
 		return ctrl.Result{}, err
 	}
+	ctx = withStatusBase(ctx, &canary)
+
+	// Trace the reconcile in the rollout's trace, with the analysis and route
+	// updates it triggers as children
+	ctx, span := otlp.StartRolloutSpan(ctx, r.Tracer, &canary, "reconcile CanaryDeployment",
+		otlp.String("gateway_cd.canary.phase", string(canary.Status.Phase)),
+		otlp.Int("gateway_cd.canary.step", int64(canary.Status.CurrentStep)))
+	defer func() {
+		span.SetAttributes(otlp.String("gateway_cd.canary.next_phase", string(canary.Status.Phase)))
+		span.RecordError(err)
+		span.End()
+	}()
 
 	// Handle deletion
 	if canary.DeletionTimestamp != nil {
 		return r.handleDeletion(ctx, &canary)
 	}
 
+	// Hold deletion until the routes are restored
+	if !controllerutil.ContainsFinalizer(&canary, FinalizerName) {
+		patch := client.MergeFromWithOptions(canary.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		controllerutil.AddFinalizer(&canary, FinalizerName)
+		if err := r.Patch(ctx, &canary, patch); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
+		}
+	}
+
 	// Initialize status if needed
 	if canary.Status.Phase == "" {
 		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhasePending
@@ -59,10 +137,78 @@ func (r *CanaryDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		canary.Status.CanaryWeight = 0
 		canary.Status.StableWeight = 100
 		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
-		if err := r.Status().Update(ctx, &canary); err != nil {
+		if err := r.updateStatus(ctx, &canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.activeInterval(&canary)}, nil
+	}
+
+	// Fill in the rollout preset before anything reads the steps or analysis
+	if err := r.resolveCanaryTemplate(ctx, &canary); err != nil {
+		log.Error(err, "Failed to resolve canary template")
+		canary.Status.Message = fmt.Sprintf("Failed to resolve canary template: %v", err)
+		if err := r.updateStatus(ctx, &canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(&canary, EventReasonCanaryTemplateInvalid, "Failed to resolve canary template: %v", err)
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+
+	// Restart or re-plan a rollout whose spec or workload changed under it
+	if changed, err := r.reconcileSpecChange(ctx, &canary); err != nil {
+		log.Error(err, "Failed to handle spec change")
+	} else if changed {
+		return ctrl.Result{RequeueAfter: r.activeInterval(&canary)}, nil
+	}
+
+	// Resolve the shared analysis policy before anything reads the analysis spec
+	if err := r.resolveAnalysisTemplate(ctx, &canary); err != nil {
+		log.Error(err, "Failed to resolve analysis template")
+		canary.Status.Message = fmt.Sprintf("Failed to resolve analysis template: %v", err)
+		if err := r.updateStatus(ctx, &canary); err != nil {
 			return ctrl.Result{}, err
 		}
-		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		r.warning(&canary, EventReasonAnalysisTemplateInvalid, "Failed to resolve analysis template: %v", err)
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+
+	// Simulate the rollout instead of writing to the cluster
+	if canary.Spec.DryRun && canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhasePending {
+		return r.handleDryRun(ctx, &canary)
+	}
+
+	// Keep generated monitoring assets in lockstep with the analysis spec
+	if err := r.reconcilePrometheusRule(ctx, &canary); err != nil {
+		log.Error(err, "Failed to reconcile PrometheusRule")
+	}
+
+	// Mount the canary version of co-versioned config before traffic reaches the canary
+	if err := r.reconcileConfigRevisions(ctx, &canary); err != nil {
+		log.Error(err, "Failed to reconcile config revisions")
+		r.warning(&canary, EventReasonConfigRevisionFailed, "Failed to reconcile config revisions: %v", err)
+	}
+
+	// Point the managed Services at the stable and canary ReplicaSets
+	if err := r.reconcileManagedServices(ctx, &canary); err != nil {
+		log.Error(err, "Failed to reconcile managed Services")
+		r.warning(&canary, EventReasonServiceSelectorFailed, "Failed to reconcile managed Services: %v", err)
+	}
+
+	// Keep the canary pods under the same network policies as the stable pods
+	if err := r.reconcileNetworkPolicies(ctx, &canary); err != nil {
+		log.Error(err, "Failed to reconcile NetworkPolicies")
+	}
+
+	// Flag, and optionally roll back, a rollout that stopped advancing
+	if rolledBack, err := r.checkProgressDeadline(ctx, &canary); err != nil {
+		return ctrl.Result{}, err
+	} else if rolledBack {
+		return ctrl.Result{RequeueAfter: r.activeInterval(&canary)}, nil
+	}
+
+	// Retry restarts a Failed rollout before its TTL deletes it
+	if _, ok := canary.Annotations[annotationRetry]; ok {
+		return r.retryRollout(ctx, &canary)
 	}
 
 	// Main reconciliation logic based on phase
@@ -77,8 +223,8 @@ func (r *CanaryDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return r.handleRollingBack(ctx, &canary)
 	case gatewaycdv1alpha1.CanaryDeploymentPhaseSucceeded,
 		 gatewaycdv1alpha1.CanaryDeploymentPhaseFailed:
-		// Terminal phases - no action needed
-		return ctrl.Result{}, nil
+		// Terminal phases - only deleted once their TTL passed
+		return r.expireFinished(ctx, &canary)
 	}
 
 	return ctrl.Result{}, nil
@@ -91,136 +237,809 @@ func (r *CanaryDeploymentReconciler) handlePending(ctx context.Context, canary *
 	if err := r.validateCanaryDeployment(ctx, canary); err != nil {
 		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseFailed
 		canary.Status.Message = fmt.Sprintf("Validation failed: %v", err)
-		r.Status().Update(ctx, canary)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonValidationFailed, "Validation failed: %v", err)
 		return ctrl.Result{}, err
 	}
 
+	// Never fight another progressive delivery controller over route weights
+	waiting, err := r.checkRouteConflicts(ctx, canary)
+	if err != nil {
+		log.Error(err, "Failed to check for conflicting controllers")
+		canary.Status.Message = fmt.Sprintf("Failed to check routes for conflicting controllers: %v", err)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+	if waiting {
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+
+	// Let one canary at a time shift the weights of a route
+	blocked, err := r.checkParallelRollouts(ctx, canary)
+	if err != nil {
+		log.Error(err, "Failed to check for parallel rollouts")
+		canary.Status.Message = fmt.Sprintf("Failed to check routes for parallel rollouts: %v", err)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+	if blocked {
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+
+	// Queue the rollout while the team is at its concurrent rollout quota
+	queued, err := r.checkQuota(ctx, canary)
+	if err != nil {
+		log.Error(err, "Failed to check rollout quota")
+		canary.Status.Message = fmt.Sprintf("Failed to check rollout quota: %v", err)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+	if queued {
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+
+	// Fail fast rather than leave the rollout stuck behind unschedulable pods
+	insufficient, err := r.checkCapacity(ctx, canary)
+	if err != nil {
+		log.Error(err, "Failed to check capacity for the canary pods")
+	}
+	if insufficient {
+		message := meta.FindStatusCondition(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeCapacityInsufficient).Message
+		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseFailed
+		canary.Status.Message = fmt.Sprintf("Insufficient capacity for the canary pods: %s", message)
+		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonCapacityInsufficient, "Insufficient capacity for the canary pods: %s", message)
+		return ctrl.Result{}, nil
+	}
+
+	// Snapshot the revision being rolled out and the stable one to revert to
+	if err := r.recordRevisions(ctx, canary); err != nil {
+		log.Error(err, "Failed to record workload revisions")
+	}
+
+	// Bring back a canary Deployment the last rollback scaled down
+	if err := r.restoreCanaryReplicas(ctx, canary); err != nil {
+		log.Error(err, "Failed to restore canary replicas")
+		canary.Status.Message = fmt.Sprintf("Failed to restore canary replicas: %v", err)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonCanaryScaleFailed, "Failed to restore canary replicas: %v", err)
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+
+	// Keep the stable and canary ReplicaSets of a single Deployment running side by side
+	if err := r.pauseTargetDeployment(ctx, canary, true); err != nil {
+		log.Error(err, "Failed to pause target Deployment")
+		canary.Status.Message = fmt.Sprintf("Failed to pause target Deployment: %v", err)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonServiceSelectorFailed, "Failed to pause target Deployment: %v", err)
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+
+	// Run the canary pods under the rollout's ServiceAccount before any traffic shifts
+	if err := r.switchServiceAccount(ctx, canary); err != nil {
+		log.Error(err, "Failed to switch ServiceAccount")
+		canary.Status.Message = fmt.Sprintf("Failed to switch ServiceAccount: %v", err)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonServiceAccountFailed, "Failed to switch ServiceAccount: %v", err)
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+
 	// Start the canary deployment
 	log.Info("Starting canary deployment", "canary", canary.Name)
 	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing
 	canary.Status.Message = "Starting canary deployment"
+	canary.Status.ChangeMetadata = canary.Spec.Metadata.DeepCopy()
+	canary.Status.TrafficSplitHash = trafficSplitHash(canary.Spec.TrafficSplit)
+	canary.Status.MirrorStartedTime = nil
+	canary.Status.MirrorCompleted = false
+	canary.Status.TimeSliceCycle = 0
+	canary.Status.TimeSliceExposed = false
+	canary.Status.TimeSliceWindowStart = nil
+	canary.Status.TimeSliceCompleted = false
+	canary.Status.Hooks = nil
+	canary.Status.PreRolloutHooksCompleted = false
+	canary.Status.PreRolloutCheck = nil
+	canary.Status.PodHealth = nil
+	canary.Status.PostPromotion = nil
+	canary.Status.RollbackStep = nil
+	canary.Status.StepHooks = nil
+	canary.Status.ConsecutiveFailures = 0
+	canary.Status.ConsecutiveErrors = 0
+	canary.Status.AnalysisHistory = nil
+	canary.Status.RetryCount = 0
+	canary.Status.StepAnalysis = nil
+	canary.Status.WeightsAppliedTime = nil
+	canary.Status.WeightsProgrammed = false
+	canary.Status.Approvals = nil
+	canary.Status.History = nil
+	meta.RemoveStatusCondition(&canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeAnalysisSucceeded)
+	meta.RemoveStatusCondition(&canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeDryRun)
+	meta.RemoveStatusCondition(&canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeAnalysisSkipped)
+	meta.RemoveStatusCondition(&canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeManualOverride)
+	meta.RemoveStatusCondition(&canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeWaiting)
 	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	canary.Status.StartedTime = canary.Status.LastTransitionTime
+	recordStepTransition(canary, actorController)
 
-	if err := r.Status().Update(ctx, canary); err != nil {
+	if err := r.updateStatus(ctx, canary); err != nil {
 		return ctrl.Result{}, err
 	}
+	r.annotate(ctx, canary, "canary rollout started")
+	r.event(canary, EventReasonRolloutStarted, "Started canary rollout with %d steps", len(canary.Spec.TrafficSplit))
 
-	return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
 }
 
 func (r *CanaryDeploymentReconciler) handleProgressing(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
+	// Outside the rollout windows neither move to the next step nor promote
+	if result, waiting, err := r.awaitSchedule(ctx, canary); waiting || err != nil {
+		return result, err
+	}
+
 	// Check if we have more steps to process
 	if int(canary.Status.CurrentStep) >= len(canary.Spec.TrafficSplit) {
+		// Promote the listeners kept out of the rollout together with the rest
+		if err := r.GatewayManager.RestoreSections(ctx, canary); err != nil {
+			return r.retryFailure(ctx, canary, EventReasonTrafficUpdateFailed, "Failed to restore route sections", err)
+		}
+		canary.Status.RetryCount = 0
+
+		// Soak the canary at full traffic while the stable workload can still take it back
+		if result, soaking, err := r.handlePostPromotion(ctx, canary); soaking || err != nil {
+			return result, err
+		}
+
+		// Hand the promoted workload back to its autoscaler
+		if err := r.releaseHPA(ctx, canary); err != nil {
+			log.Error(err, "Failed to restore HorizontalPodAutoscaler")
+			r.warning(canary, EventReasonCanaryScaleFailed, "Failed to restore HorizontalPodAutoscaler: %v", err)
+			return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+		}
+
+		// The last step sent all traffic to the canary, the baseline is idle
+		if err := r.removeBaseline(ctx, canary); err != nil {
+			log.Error(err, "Failed to remove baseline")
+			r.warning(canary, EventReasonBaselineFailed, "Failed to remove baseline: %v", err)
+			return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+		}
+
+		// Let the Deployment finish rolling out the promoted revision
+		if err := r.pauseTargetDeployment(ctx, canary, false); err != nil {
+			log.Error(err, "Failed to resume target Deployment")
+			r.warning(canary, EventReasonServiceSelectorFailed, "Failed to resume target Deployment: %v", err)
+			return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+		}
+
 		// All steps completed successfully
 		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseSucceeded
 		canary.Status.Message = "Canary deployment completed successfully"
+		// The canary ServiceAccount is now the one to keep
+		canary.Status.OriginalServiceAccount = ""
+		if err := r.promoteRevision(ctx, canary); err != nil {
+			log.Error(err, "Failed to record stable workload revision")
+		}
 		canary.Status.CanaryWeight = 100
 		canary.Status.StableWeight = 0
 		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
-		r.Status().Update(ctx, canary)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		recordRolloutFinished(canary, "succeeded")
+		r.annotate(ctx, canary, "canary promoted")
+		r.event(canary, EventReasonPromoted, "Canary promoted after %d steps", len(canary.Spec.TrafficSplit))
 		return ctrl.Result{}, nil
 	}
 
+	// Honour a user pause before touching traffic so the current weight is frozen
+	if canary.Annotations["gateway-cd.io/pause"] == "true" {
+		return r.pauseByUser(ctx, canary)
+	}
+	if _, ok := canary.Annotations[annotationWeight]; ok {
+		return r.overrideWeight(ctx, canary)
+	}
+
+	// Roll back on failing canary pods without waiting for metrics
+	if result, failed, err := r.handlePodHealth(ctx, canary); failed || err != nil {
+		return result, err
+	}
+
+	// Run migrations and other PreRollout hooks before the canary gets any traffic
+	if hasHooks(canary, gatewaycdv1alpha1.HookTypePreRollout) && !canary.Status.PreRolloutHooksCompleted {
+		return r.handlePreRolloutHooks(ctx, canary)
+	}
+
+	// Smoke test the canary Service before it gets any traffic
+	if canary.Spec.PreRolloutCheck != nil && !preRolloutCheckPassed(canary) {
+		return r.handlePreRolloutCheck(ctx, canary)
+	}
+
+	// Analyse the canary on mirrored traffic before any real traffic shift
+	if canary.Spec.Mirror && !canary.Status.MirrorCompleted {
+		return r.handleMirroring(ctx, canary)
+	}
+
+	// Expose the canary in short time slices before sustained partial traffic
+	if canary.Spec.TimeSlice != nil && !canary.Status.TimeSliceCompleted {
+		return r.handleTimeSlicing(ctx, canary)
+	}
+
 	currentStep := canary.Spec.TrafficSplit[canary.Status.CurrentStep]
 
+	// Give the canary the replicas of the step before it gets the step's traffic
+	ready, err := r.scaleCanary(ctx, canary, int(canary.Status.CurrentStep))
+	if err != nil {
+		log.Error(err, "Failed to scale canary")
+		canary.Status.Message = fmt.Sprintf("Failed to scale canary: %v", err)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonCanaryScaleFailed, "Failed to scale canary: %v", err)
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+	if !ready {
+		canary.Status.Message = fmt.Sprintf("Waiting for %d canary replicas before step %d",
+			canary.Status.CanaryReplicas, canary.Status.CurrentStep+1)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+	}
+
+	// Start the baseline so it takes its share of traffic with the canary
+	ready, err = r.reconcileBaseline(ctx, canary)
+	if err != nil {
+		log.Error(err, "Failed to reconcile baseline")
+		canary.Status.Message = fmt.Sprintf("Failed to reconcile baseline: %v", err)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonBaselineFailed, "Failed to reconcile baseline: %v", err)
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+	if !ready {
+		canary.Status.Message = fmt.Sprintf("Waiting for the baseline before step %d", canary.Status.CurrentStep+1)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+	}
+
+	// Warm up the canary for the step's traffic before the weights change
+	if result, waiting, err := r.runStepHooks(ctx, canary, gatewaycdv1alpha1.StepHookStagePreStep); waiting || err != nil {
+		return result, err
+	}
+
+	// Record weights changed on the routes outside the rollout, the write below restores them
+	if _, err := r.correctRouteDrift(ctx, canary); err != nil {
+		log.Error(err, "Failed to check routes for drift")
+	}
+
 	// Update traffic split
-	if err := r.GatewayManager.UpdateTrafficSplit(ctx, canary, int(currentStep.Weight)); err != nil {
-		log.Error(err, "Failed to update traffic split")
-		canary.Status.Message = fmt.Sprintf("Failed to update traffic split: %v", err)
-		r.Status().Update(ctx, canary)
-		return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+	if err := r.GatewayManager.UpdateTrafficSplitForStep(ctx, canary, int(canary.Status.CurrentStep)); err != nil {
+		return r.retryFailure(ctx, canary, EventReasonTrafficUpdateFailed, "Failed to update traffic split", err)
 	}
+	canary.Status.RetryCount = 0
 
 	// Update status
+	previous := canary.Status.CanaryWeight
 	canary.Status.CanaryWeight = currentStep.Weight
 	canary.Status.StableWeight = 100 - currentStep.Weight
 	canary.Status.Message = fmt.Sprintf("Traffic split updated: %d%% canary, %d%% stable",
 		currentStep.Weight, 100-currentStep.Weight)
+	if canary.Status.CanaryTenants > 0 {
+		canary.Status.Message = fmt.Sprintf("%s, %d tenant selector(s) on canary", canary.Status.Message, canary.Status.CanaryTenants)
+	}
+	if canary.Status.CanaryFraction != "" {
+		canary.Status.Message = fmt.Sprintf("%s, %s of requests on canary by hash bucket", canary.Status.Message, canary.Status.CanaryFraction)
+	}
+	if previous != currentStep.Weight {
+		// Announce the weight only once it is recorded, a lost write would
+		// announce it again
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.event(canary, EventReasonWeightChanged, "Canary weight changed from %d%% to %d%% at step %d",
+			previous, currentStep.Weight, canary.Status.CurrentStep+1)
+	}
+
+	// Start the analysis clock only once the gateway serves the new weights
+	if !canary.Status.WeightsProgrammed {
+		if result, waiting, err := r.awaitRoutesProgrammed(ctx, canary); waiting || err != nil {
+			return result, err
+		}
+	}
+
+	// Smoke test the canary at the step's traffic before it pauses or is analysed
+	if result, waiting, err := r.runStepHooks(ctx, canary, gatewaycdv1alpha1.StepHookStagePostStep); waiting || err != nil {
+		return result, err
+	}
 
 	// Check if step requires pause
 	if currentStep.Pause {
 		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhasePaused
 		canary.Status.Message = fmt.Sprintf("Paused at step %d for manual approval", canary.Status.CurrentStep+1)
 		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
-		r.Status().Update(ctx, canary)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.event(canary, EventReasonPaused, "%s", canary.Status.Message)
 		return ctrl.Result{}, nil
 	}
 
+	// An external analysis verdict takes precedence over provider analysis
+	if verdict, ok := canary.Annotations["gateway-cd.io/analysis-verdict"]; ok {
+		return r.handleAnalysisVerdict(ctx, canary, verdict)
+	}
+
 	// Run analysis if configured
-	if !canary.Spec.SkipAnalysis && canary.Spec.Analysis.SuccessRate > 0 {
+	if analysisEnabled(canary) {
+		// With an analysis interval, the step runs analysis once per interval
+		interval := analysisInterval(canary)
+		if interval > 0 {
+			loop := stepAnalysis(canary, currentStep, interval)
+			if wait := nextIntervalIn(loop, interval); wait > 0 {
+				if err := r.updateStatus(ctx, canary); err != nil {
+					return ctrl.Result{}, err
+				}
+				return ctrl.Result{RequeueAfter: wait}, nil
+			}
+		}
+
 		passed, err := r.runAnalysis(ctx, canary)
+		if errors.Is(err, metrics.ErrProviderUnavailable) {
+			return r.handleProviderUnavailable(ctx, canary)
+		}
 		if err != nil {
 			log.Error(err, "Analysis failed")
+			if analysisErrorLimitReached(canary) {
+				canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+				canary.Status.Message = "Analysis could not be completed, rolling back"
+				canary.Status.RollbackReason = fmt.Sprintf("Analysis could not be completed %d times in a row: %v",
+					canary.Status.ConsecutiveErrors, err)
+				canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+				if err := r.updateStatus(ctx, canary); err != nil {
+					return ctrl.Result{}, err
+				}
+				r.warning(canary, EventReasonAnalysisFailed, "%s", canary.Status.RollbackReason)
+				return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+			}
 			canary.Status.Message = fmt.Sprintf("Analysis failed: %v", err)
-			r.Status().Update(ctx, canary)
-			return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.warning(canary, EventReasonAnalysisError, "Analysis could not be completed: %v", err)
+			return ctrl.Result{RequeueAfter: r.analysisErrorBackoff(canary)}, nil
 		}
 
+		if interval > 0 {
+			recordInterval(canary, passed)
+		}
+		setAnalysisCondition(canary, passed, "AnalysisRun", fmt.Sprintf("Analysis at step %d: %s",
+			canary.Status.CurrentStep+1, analysisOutcome(passed)))
+		if !passed && !analysisFailureLimitReached(canary) {
+			canary.Status.Message = fmt.Sprintf("Analysis failed at step %d (%d of %d consecutive failures), retrying",
+				canary.Status.CurrentStep+1, canary.Status.ConsecutiveFailures, analysisFailureLimit(canary))
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.warning(canary, EventReasonAnalysisRetrying, "%s: %s", canary.Status.Message, failingMetricsSummary(canary))
+			return ctrl.Result{RequeueAfter: analysisRetryInterval(canary)}, nil
+		}
 		if !passed {
-			log.Info("Analysis failed, initiating rollback")
+			log.Info("Analysis failed, initiating rollback", "consecutiveFailures", canary.Status.ConsecutiveFailures)
 			canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
 			canary.Status.Message = "Analysis failed, rolling back"
+			canary.Status.RollbackReason = "Analysis failed"
+			if canary.Status.ConsecutiveFailures > 1 {
+				canary.Status.RollbackReason = fmt.Sprintf("Analysis failed %d times in a row", canary.Status.ConsecutiveFailures)
+			}
 			canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
-			r.Status().Update(ctx, canary)
-			return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.warning(canary, EventReasonAnalysisFailed, "Analysis failed at step %d: %s",
+				canary.Status.CurrentStep+1, failingMetricsSummary(canary))
+			return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+		}
+		if loop := canary.Status.StepAnalysis; interval > 0 && loop != nil {
+			if wait := stepRemaining(loop, currentStep, interval); wait > 0 {
+				canary.Status.Message = fmt.Sprintf("Analysis passed %d of %d required intervals at step %d",
+					loop.SuccessfulIntervals, loop.RequiredIntervals, canary.Status.CurrentStep+1)
+				if err := r.updateStatus(ctx, canary); err != nil {
+					return ctrl.Result{}, err
+				}
+				r.event(canary, EventReasonAnalysisPassed, "Analysis passed at step %d", canary.Status.CurrentStep+1)
+				return ctrl.Result{RequeueAfter: wait}, nil
+			}
+		}
+
+		step := canary.Status.CurrentStep
+		result, err := r.advanceStep(ctx, canary)
+		if err == nil {
+			r.event(canary, EventReasonAnalysisPassed, "Analysis passed at step %d", step+1)
+		}
+		return result, err
+	}
+
+	markAnalysisSkipped(ctx, canary, analysisSkipReason(canary), fmt.Sprintf("Step %d advanced without analysis", canary.Status.CurrentStep+1))
+	return r.advanceStep(ctx, canary)
+}
+
+// advanceStep moves the canary to the next traffic split step
+func (r *CanaryDeploymentReconciler) advanceStep(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	currentStep := canary.Spec.TrafficSplit[canary.Status.CurrentStep]
+
+	// Calculate requeue time based on step duration, less the time the
+	// analysis interval loop already spent at this step
+	requeueAfter := stepDuration(currentStep)
+	if loop := canary.Status.StepAnalysis; loop != nil && loop.Step == canary.Status.CurrentStep {
+		requeueAfter -= time.Since(loop.StartedTime.Time)
+		if minimum := r.activeInterval(canary); requeueAfter < minimum {
+			requeueAfter = minimum
 		}
 	}
 
 	// Move to next step
 	canary.Status.CurrentStep++
-	r.Status().Update(ctx, canary)
+	canary.Status.StepAnalysis = nil
+	canary.Status.WeightsAppliedTime = nil
+	canary.Status.WeightsProgrammed = false
+	canary.Status.LastProgressTime = &metav1.Time{Time: time.Now()}
+	recordStepTransition(canary, actorController)
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// pauseByUser freezes the rollout at its current weight until it is resumed
+func (r *CanaryDeploymentReconciler) pauseByUser(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	log.FromContext(ctx).Info("Pausing canary deployment on user request")
+	action := newControlAction(canary, gatewaycdv1alpha1.ControlActionPause)
+
+	by := "user"
+	if requestedBy := canary.Annotations[annotationRequestedBy]; requestedBy != "" {
+		by = requestedBy
+	}
+
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhasePaused
+	canary.Status.Message = fmt.Sprintf("Paused by %s at step %d with %d%% canary traffic",
+		by, canary.Status.CurrentStep+1, canary.Status.CanaryWeight)
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypePausedByUser, metav1.ConditionTrue, "PauseRequested", canary.Status.Message)
+	r.auditControlAction(canary, action, canary.Status.Message)
+
+	if err := r.removeAnnotations(ctx, canary, "gateway-cd.io/pause", annotationRequestedBy, annotationRequestedVia, annotationAdmittedBy); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.event(canary, EventReasonPausedByUser, "%s", canary.Status.Message)
+	return ctrl.Result{}, nil
+}
 
-	// Calculate requeue time based on step duration
-	requeueAfter := time.Second * 30 // default
-	if currentStep.Duration != "" {
-		if duration, err := time.ParseDuration(currentStep.Duration); err == nil {
-			requeueAfter = duration
+// promoteByUser skips the remaining steps of a paused rollout. Below the last
+// step the rollout continues at the last step, running its analysis; paused
+// at the last step it completes.
+func (r *CanaryDeploymentReconciler) promoteByUser(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, by string) (ctrl.Result, error) {
+	log.FromContext(ctx).Info("Promoting canary deployment on user request")
+	action := newControlAction(canary, gatewaycdv1alpha1.ControlActionPromote)
+
+	if last := int32(len(canary.Spec.TrafficSplit) - 1); canary.Status.CurrentStep < last {
+		canary.Status.CurrentStep = last
+	} else {
+		canary.Status.CurrentStep++
+	}
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing
+	canary.Status.WeightsAppliedTime = nil
+	canary.Status.WeightsProgrammed = false
+	for _, conditionType := range []string{gatewaycdv1alpha1.ConditionTypeManualOverride, gatewaycdv1alpha1.ConditionTypePausedByUser} {
+		if meta.IsStatusConditionTrue(canary.Status.Conditions, conditionType) {
+			setCondition(canary, conditionType, metav1.ConditionFalse, "Promoted", "Rollout promoted by user")
 		}
 	}
+	recordStepTransition(canary, by)
+	canary.Status.Message = fmt.Sprintf("Promoted by %s from step %d", by, action.PreviousStep+1)
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	r.auditControlAction(canary, action, canary.Status.Message)
 
-	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	if err := r.removeAnnotations(ctx, canary, annotationPromote, "gateway-cd.io/resume", "gateway-cd.io/pause", annotationRequestedBy, annotationRequestedVia, annotationAdmittedBy); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.event(canary, EventReasonPromotedByUser, "%s", canary.Status.Message)
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+}
+
+// removeAnnotations deletes the given annotations from the canary while
+// keeping the in-memory spec and status, which the patch would otherwise overwrite
+func (r *CanaryDeploymentReconciler) removeAnnotations(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, keys ...string) error {
+	patch := client.MergeFrom(canary.DeepCopy())
+	for _, key := range keys {
+		delete(canary.Annotations, key)
+	}
+
+	spec := canary.Spec.DeepCopy()
+	status := canary.Status.DeepCopy()
+	if err := r.Patch(ctx, canary, patch); err != nil {
+		return err
+	}
+	canary.Spec = *spec
+	canary.Status = *status
+	return nil
+}
+
+// handleAnalysisVerdict consumes an analysis verdict posted by an external system
+func (r *CanaryDeploymentReconciler) handleAnalysisVerdict(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, verdict string) (ctrl.Result, error) {
+	reason := canary.Annotations["gateway-cd.io/analysis-verdict-reason"]
+	if err := r.removeAnnotations(ctx, canary, "gateway-cd.io/analysis-verdict", "gateway-cd.io/analysis-verdict-reason"); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	setAnalysisCondition(canary, verdict != "fail", "ExternalVerdict", fmt.Sprintf("External analysis at step %d: %s",
+		canary.Status.CurrentStep+1, analysisOutcome(verdict != "fail")))
+	if verdict != "fail" {
+		step := canary.Status.CurrentStep
+		result, err := r.advanceStep(ctx, canary)
+		if err == nil {
+			r.event(canary, EventReasonAnalysisPassed, "External analysis passed at step %d", step+1)
+		}
+		return result, err
+	}
+
+	log.FromContext(ctx).Info("External analysis failed, initiating rollback", "reason", reason)
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+	canary.Status.Message = "External analysis failed, rolling back"
+	canary.Status.RollbackReason = "External analysis failed"
+	if reason != "" {
+		canary.Status.RollbackReason = fmt.Sprintf("External analysis failed: %s", reason)
+	}
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.warning(canary, EventReasonAnalysisFailed, "%s", canary.Status.RollbackReason)
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+}
+
+// handleProviderUnavailable applies the configured policy when the metrics provider is unavailable
+func (r *CanaryDeploymentReconciler) handleProviderUnavailable(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	r.warning(canary, EventReasonProviderUnavailable, "Metrics provider unavailable, applying %q policy",
+		canary.Spec.Analysis.ProviderUnavailablePolicy)
+
+	switch canary.Spec.Analysis.ProviderUnavailablePolicy {
+	case gatewaycdv1alpha1.ProviderUnavailablePolicySkip:
+		log.Info("Metrics provider unavailable, skipping analysis for this step")
+		markAnalysisSkipped(ctx, canary, "ProviderUnavailable", fmt.Sprintf("Step %d advanced without analysis, metrics provider unavailable",
+			canary.Status.CurrentStep+1))
+		return r.advanceStep(ctx, canary)
+	case gatewaycdv1alpha1.ProviderUnavailablePolicyPause:
+		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhasePaused
+		canary.Status.Message = fmt.Sprintf("Metrics provider unavailable, paused at step %d for manual approval", canary.Status.CurrentStep+1)
+		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	case gatewaycdv1alpha1.ProviderUnavailablePolicyRollback:
+		log.Info("Metrics provider unavailable, initiating rollback")
+		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+		canary.Status.Message = "Metrics provider unavailable, rolling back"
+		canary.Status.RollbackReason = "Metrics provider unavailable"
+		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+	default:
+		canary.Status.Message = "Metrics provider unavailable, retrying analysis"
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
 }
 
 func (r *CanaryDeploymentReconciler) handlePaused(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	// Control actions may record who requested them, e.g. a Slack user
+	by := "user"
+	if requestedBy := canary.Annotations[annotationRequestedBy]; requestedBy != "" {
+		by = requestedBy
+	}
+
+	// A weight override applies to any pause and holds the rollout at the new weight
+	if _, ok := canary.Annotations[annotationWeight]; ok {
+		return r.overrideWeight(ctx, canary)
+	}
+
+	// Restore weights changed on the routes outside the rollout while it holds its weight
+	if canary.Annotations["gateway-cd.io/resume"] != "true" && canary.Annotations["gateway-cd.io/abort"] != "true" {
+		if corrected, err := r.correctRouteDrift(ctx, canary); err != nil {
+			return r.retryFailure(ctx, canary, EventReasonTrafficUpdateFailed, "Failed to restore traffic split", err)
+		} else if corrected {
+			canary.Status.RetryCount = 0
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	// Steps gated on approvals continue only on Approval records, abort still applies
+	if canary.Spec.Approvals != nil && canary.Annotations["gateway-cd.io/abort"] != "true" && !pausedManually(canary) {
+		return r.handleApprovals(ctx, canary)
+	}
+
 	// Check for resume annotation or other resume conditions
 	if canary.Annotations["gateway-cd.io/resume"] == "true" {
-		delete(canary.Annotations, "gateway-cd.io/resume")
+		action := newControlAction(canary, gatewaycdv1alpha1.ControlActionResume)
 		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing
-		canary.Status.CurrentStep++
-		canary.Status.Message = "Resumed from pause"
+		if meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeManualOverride) {
+			// Restore the weight of the step and analyse it again before moving on
+			setCondition(canary, gatewaycdv1alpha1.ConditionTypeManualOverride, metav1.ConditionFalse, "Resumed", "Rollout plan resumed by user")
+			canary.Status.WeightsAppliedTime = nil
+			canary.Status.WeightsProgrammed = false
+		} else if meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypePausedByUser) {
+			// A user pause interrupted the step before it completed, so run it again
+			setCondition(canary, gatewaycdv1alpha1.ConditionTypePausedByUser, metav1.ConditionFalse, "Resumed", "Rollout resumed by user")
+		} else {
+			canary.Status.CurrentStep++
+			canary.Status.WeightsAppliedTime = nil
+			canary.Status.WeightsProgrammed = false
+			recordStepTransition(canary, by)
+		}
+		canary.Status.Message = fmt.Sprintf("Resumed from pause by %s", by)
 		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+		r.auditControlAction(canary, action, canary.Status.Message)
 
-		if err := r.Update(ctx, canary); err != nil {
+		if err := r.removeAnnotations(ctx, canary, "gateway-cd.io/resume", "gateway-cd.io/pause", annotationRequestedBy, annotationRequestedVia, annotationAdmittedBy); err != nil {
 			return ctrl.Result{}, err
 		}
-		if err := r.Status().Update(ctx, canary); err != nil {
+		if err := r.updateStatus(ctx, canary); err != nil {
 			return ctrl.Result{}, err
 		}
-		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		r.event(canary, EventReasonResumed, "Rollout resumed at step %d by %s", canary.Status.CurrentStep+1, by)
+		return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
 	}
 
 	// Check for abort annotation
 	if canary.Annotations["gateway-cd.io/abort"] == "true" {
+		action := newControlAction(canary, gatewaycdv1alpha1.ControlActionAbort)
 		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
-		canary.Status.Message = "Aborted by user"
+		canary.Status.Message = fmt.Sprintf("Aborted by %s", by)
+		canary.Status.RollbackReason = canary.Status.Message
 		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
-		r.Status().Update(ctx, canary)
-		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		r.auditControlAction(canary, action, canary.Status.Message)
+
+		// A left-over abort would end the next rollout at its first pause
+		if err := r.removeAnnotations(ctx, canary, "gateway-cd.io/abort", annotationRequestedBy, annotationRequestedVia, annotationAdmittedBy); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonAborted, "Rollout aborted by %s", by)
+		return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+	}
+
+	// Promote skips the remaining steps: the rollout continues at the last
+	// step, or completes when it is paused there
+	if canary.Annotations[annotationPromote] == "true" {
+		return r.promoteByUser(ctx, canary, by)
 	}
 
 	// Stay paused
-	return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+	return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
 }
 
 func (r *CanaryDeploymentReconciler) handleRollingBack(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
+	// Drain the canary gradually when the rollback has steps
+	if result, wait, err := r.stepDownCanary(ctx, canary); wait || err != nil {
+		return result, err
+	}
+
 	// Reset traffic to 100% stable
 	if err := r.GatewayManager.UpdateTrafficSplit(ctx, canary, 0); err != nil {
-		log.Error(err, "Failed to rollback traffic split")
-		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		return r.retryFailure(ctx, canary, EventReasonTrafficUpdateFailed, "Failed to roll back traffic split", err)
+	}
+
+	// Attach the listeners kept out of the rollout to the route again
+	if err := r.GatewayManager.RestoreSections(ctx, canary); err != nil {
+		return r.retryFailure(ctx, canary, EventReasonTrafficUpdateFailed, "Failed to restore route sections", err)
+	}
+	canary.Status.RetryCount = 0
+
+	// Revert the workload itself, not just its traffic, to the stable revision
+	if err := r.revertWorkload(ctx, canary); err != nil {
+		log.Error(err, "Failed to revert workload")
+		r.warning(canary, EventReasonWorkloadRevertFailed, "Failed to revert workload: %v", err)
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+	}
+
+	// Let the reverted Deployment scale the stable ReplicaSet back up
+	if err := r.pauseTargetDeployment(ctx, canary, false); err != nil {
+		log.Error(err, "Failed to resume target Deployment")
+		r.warning(canary, EventReasonServiceSelectorFailed, "Failed to resume target Deployment: %v", err)
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+	}
+
+	// Run the canary pods under their original ServiceAccount again
+	if err := r.restoreServiceAccount(ctx, canary); err != nil {
+		log.Error(err, "Failed to restore ServiceAccount")
+		r.warning(canary, EventReasonServiceAccountFailed, "Failed to restore ServiceAccount: %v", err)
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+	}
+
+	// Let the HPA scale the workload on its own again
+	if err := r.releaseHPA(ctx, canary); err != nil {
+		log.Error(err, "Failed to restore HorizontalPodAutoscaler")
+		r.warning(canary, EventReasonCanaryScaleFailed, "Failed to restore HorizontalPodAutoscaler: %v", err)
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+	}
+
+	// Stable serves all traffic again, the baseline is idle
+	if err := r.removeBaseline(ctx, canary); err != nil {
+		log.Error(err, "Failed to remove baseline")
+		r.warning(canary, EventReasonBaselineFailed, "Failed to remove baseline: %v", err)
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+	}
+
+	// Scale down or delete the canary resources as the rollback mode asks
+	if err := r.tearDownCanary(ctx, canary); err != nil {
+		log.Error(err, "Failed to tear down canary")
+		r.warning(canary, EventReasonCanaryTeardownFailed, "Failed to tear down canary: %v", err)
+		if !ignoreTeardownFailure(canary) {
+			return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+		}
+	}
+
+	// Reverse migrations once no canary pod depends on them. A failed
+	// Rollback hook is reported but doesn't hold the rollback.
+	done, failure, err := r.runHooks(ctx, canary, gatewaycdv1alpha1.HookTypeRollback)
+	if err != nil {
+		log.Error(err, "Failed to run Rollback hooks")
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+	}
+	if !done && failure == "" {
+		canary.Status.Message = "Waiting for Rollback hooks to complete"
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
 	}
 
 	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseFailed
@@ -228,16 +1047,53 @@ func (r *CanaryDeploymentReconciler) handleRollingBack(ctx context.Context, cana
 	canary.Status.StableWeight = 100
 	canary.Status.Message = "Rollback completed"
 	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
-
-	r.Status().Update(ctx, canary)
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	canaryRollbacks.WithLabelValues(canary.Namespace, canary.Name).Inc()
+	recordRolloutFinished(canary, "failed")
+	r.annotate(ctx, canary, "canary rolled back")
+	r.warning(canary, EventReasonRolledBack, "Rolled back to stable: %s", canary.Status.RollbackReason)
+	if err := r.propagateRollbackReason(ctx, canary); err != nil {
+		log.Error(err, "Failed to propagate rollback reason to target workload")
+	}
 	return ctrl.Result{}, nil
 }
 
+// handleDeletion restores the routes, the workload's ServiceAccount and its
+// HPA, removes the baseline and releases managed Services, then removes the finalizer so the canary can be deleted. Every step is
+// idempotent, a failed cleanup is retried with the finalizer in place.
 func (r *CanaryDeploymentReconciler) handleDeletion(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
-	// Cleanup Gateway API resources if needed
-	if err := r.GatewayManager.Cleanup(ctx, canary); err != nil {
-		return ctrl.Result{}, err
+	if !controllerutil.ContainsFinalizer(canary, FinalizerName) {
+		return ctrl.Result{}, nil
 	}
+
+	// Cleanup Gateway API resources if needed, dry runs never wrote any
+	if !canary.Spec.DryRun || canary.Status.ManagedRoute != "" {
+		if err := r.GatewayManager.Cleanup(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.restoreServiceAccount(ctx, canary); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.releaseHPA(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.removeBaseline(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.releaseManagedServices(ctx, canary); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	patch := client.MergeFromWithOptions(canary.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	controllerutil.RemoveFinalizer(canary, FinalizerName)
+	if err := r.Patch(ctx, canary, patch); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
+	}
+	forgetCanaryMetrics(canary.Namespace, canary.Name)
+	r.Providers.Forget(canary.Namespace + "/" + canary.Name)
 	return ctrl.Result{}, nil
 }
 
@@ -249,36 +1105,136 @@ func (r *CanaryDeploymentReconciler) validateCanaryDeployment(ctx context.Contex
 	return nil
 }
 
+// analysisEnabled reports whether the canary has success rate or trace
+// thresholds, or a baseline, to analyse
+func analysisEnabled(canary *gatewaycdv1alpha1.CanaryDeployment) bool {
+	if canary.Spec.SkipAnalysis {
+		return false
+	}
+	return canary.Spec.Analysis.SuccessRate > 0 || canary.Spec.Analysis.Traces != nil || canary.Spec.Baseline != nil
+}
+
+// analysisFailureLimit is the number of consecutive failed analysis runs that trigger a rollback
+func analysisFailureLimit(canary *gatewaycdv1alpha1.CanaryDeployment) int32 {
+	if canary.Spec.Analysis.FailureLimit > 0 {
+		return canary.Spec.Analysis.FailureLimit
+	}
+	return 1
+}
+
+// analysisFailureLimitReached reports whether failed analysis runs should roll the canary back
+func analysisFailureLimitReached(canary *gatewaycdv1alpha1.CanaryDeployment) bool {
+	return canary.Status.ConsecutiveFailures >= analysisFailureLimit(canary)
+}
+
+// analysisErrorLimitReached reports whether analysis runs that could not be
+// completed should roll the canary back
+func analysisErrorLimitReached(canary *gatewaycdv1alpha1.CanaryDeployment) bool {
+	limit := canary.Spec.Analysis.ConsecutiveErrors
+	return limit > 0 && canary.Status.ConsecutiveErrors >= limit
+}
+
+// analysisRetryInterval is how long to wait before analysing the same step again
+func analysisRetryInterval(canary *gatewaycdv1alpha1.CanaryDeployment) time.Duration {
+	if interval := analysisInterval(canary); interval > 0 {
+		return interval
+	}
+	return time.Second * 30
+}
+
 func (r *CanaryDeploymentReconciler) runAnalysis(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (bool, error) {
 	log := log.FromContext(ctx)
 
-	if r.MetricsProvider == nil {
+	provider, err := r.metricsProvider(ctx, canary)
+	if err != nil {
+		recordAnalysisRun(canary, "error")
+		canary.Status.ConsecutiveErrors++
+		return false, err
+	}
+	if provider == nil {
 		log.Info("No metrics provider configured, skipping analysis")
+		markAnalysisSkipped(ctx, canary, "NoMetricsProvider", "No metrics provider configured")
 		return true, nil
 	}
 
 	// Run analysis using the metrics provider
-	result, err := r.MetricsProvider.RunAnalysis(ctx, canary)
+	result, err := provider.RunAnalysis(ctx, canary)
 	if err != nil {
+		recordAnalysisRun(canary, "error")
+		if !errors.Is(err, metrics.ErrProviderUnavailable) {
+			canary.Status.ConsecutiveErrors++
+		}
 		return false, err
 	}
+	recordAnalysisRun(canary, analysisOutcome(result.Passed))
+	canary.Status.ConsecutiveErrors = 0
 
 	// Update analysis run status
 	canary.Status.AnalysisRun = &gatewaycdv1alpha1.AnalysisRunStatus{
-		Phase:          result.Phase,
-		SuccessRate:    result.SuccessRate,
-		AverageLatency: result.AverageLatency,
-		MetricResults:  result.MetricResults,
-		StartedAt:      result.StartedAt,
-		CompletedAt:    result.CompletedAt,
+		Phase:               result.Phase,
+		SuccessRate:         result.SuccessRate,
+		AverageLatency:      result.AverageLatency,
+		BaselineSuccessRate: result.BaselineSuccessRate,
+		BaselineLatency:     result.BaselineLatency,
+		MetricResults:       result.MetricResults,
+		StartedAt:           result.StartedAt,
+		CompletedAt:         result.CompletedAt,
 	}
 
-	return result.Passed, nil
+	passed := result.Passed
+	if canary.Spec.Analysis.Smoothing != nil {
+		passed = r.smoothAnalysis(canary)
+	}
+	if passed {
+		canary.Status.ConsecutiveFailures = 0
+	} else {
+		canary.Status.ConsecutiveFailures++
+	}
+	return passed, nil
+}
+
+// annotate records a dashboard annotation if an annotator is configured
+func (r *CanaryDeploymentReconciler) annotate(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, text string) {
+	if r.Annotator == nil {
+		return
+	}
+	if err := r.Annotator.Annotate(ctx, canary, text); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to write Grafana annotation")
+	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *CanaryDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := indexFields(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return fmt.Errorf("failed to index canaries: %w", err)
+	}
+	// Edits to the managed HTTPRoutes and new revisions of the target
+	// Deployment are handled as they happen instead of on the next requeue
+	blder := ctrl.NewControllerManagedBy(mgr).
 		For(&gatewaycdv1alpha1.CanaryDeployment{}).
-		Complete(r)
+		Owns(&batchv1.Job{}).
+		Watches(&gatewaycdv1alpha1.Approval{}, handler.EnqueueRequestsFromMapFunc(approvalRequests)).
+		Watches(&gatewayapi.HTTPRoute{}, handler.EnqueueRequestsFromMapFunc(r.httpRouteRequests),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&appsv1.Deployment{}, handler.EnqueueRequestsFromMapFunc(r.deploymentRequests),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+
+	if r.DriftCheckInterval > 0 {
+		drifted := make(chan event.GenericEvent)
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return r.detectDrift(ctx, drifted)
+		})); err != nil {
+			return fmt.Errorf("failed to add drift detection: %w", err)
+		}
+		blder = blder.WatchesRawSource(&source.Channel{Source: drifted}, &handler.EnqueueRequestForObject{})
+	}
+
+	failing := make(chan event.GenericEvent)
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		return r.watchPodHealth(ctx, failing)
+	})); err != nil {
+		return fmt.Errorf("failed to add pod health checks: %w", err)
+	}
+	blder = blder.WatchesRawSource(&source.Channel{Source: failing}, &handler.EnqueueRequestForObject{})
+	return blder.Complete(r)
 }
\ No newline at end of file
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;update;patch
+
+// scaleCanary sets the target Deployment to the canary replicas of the step,
+// holding any HPA targeting it first so autoscaling doesn't undo the change.
+// It reports whether the canary runs its replicas, so the step's traffic
+// only shifts once the canary can absorb it.
+func (r *CanaryDeploymentReconciler) scaleCanary(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, step int) (bool, error) {
+	if canary.Spec.CanaryScale == nil {
+		return true, nil
+	}
+
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return false, err
+	}
+	replicas, err := r.canaryReplicas(ctx, canary, step)
+	if err != nil {
+		return false, err
+	}
+	if err := r.holdHPA(ctx, canary, deployment, replicas); err != nil {
+		return false, err
+	}
+
+	canary.Status.CanaryReplicas = replicas
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != replicas {
+		from := int32(1)
+		if deployment.Spec.Replicas != nil {
+			from = *deployment.Spec.Replicas
+		}
+		patch := client.MergeFrom(deployment.DeepCopy())
+		deployment.Spec.Replicas = &replicas
+		if err := r.Patch(ctx, deployment, patch); err != nil {
+			return false, fmt.Errorf("failed to scale Deployment %s/%s: %w", deployment.Namespace, deployment.Name, err)
+		}
+		r.event(canary, EventReasonCanaryScaled, "Scaled Deployment %s from %d to %d replicas for step %d",
+			deployment.Name, from, replicas, step+1)
+		return false, nil
+	}
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.ReadyReplicas >= replicas, nil
+}
+
+// canaryReplicas computes the canary replicas of a step from spec.canaryScale
+func (r *CanaryDeploymentReconciler) canaryReplicas(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, step int) (int32, error) {
+	scale := canary.Spec.CanaryScale
+	var replicas int32
+	switch scale.Mode {
+	case gatewaycdv1alpha1.CanaryScaleModeFixed:
+		replicas = scale.Replicas
+	case gatewaycdv1alpha1.CanaryScaleModePercentOfStable, gatewaycdv1alpha1.CanaryScaleModeMatchWeight:
+		stable := &appsv1.Deployment{}
+		if err := r.Get(ctx, types.NamespacedName{Name: scale.StableDeployment, Namespace: canary.Namespace}, stable); err != nil {
+			return 0, fmt.Errorf("failed to get stable Deployment %s/%s: %w", canary.Namespace, scale.StableDeployment, err)
+		}
+		stableReplicas := int32(1)
+		if stable.Spec.Replicas != nil {
+			stableReplicas = *stable.Spec.Replicas
+		}
+		percent := scale.Percent
+		if scale.Mode == gatewaycdv1alpha1.CanaryScaleModeMatchWeight {
+			percent = canary.Spec.TrafficSplit[step].Weight
+		}
+		// Round up so a small share of traffic still gets a pod
+		replicas = (stableReplicas*percent + 99) / 100
+	default:
+		return 0, fmt.Errorf("unsupported canary scale mode %q", scale.Mode)
+	}
+
+	minReplicas := scale.MinReplicas
+	if minReplicas == 0 {
+		minReplicas = 1
+	}
+	if replicas < minReplicas {
+		replicas = minReplicas
+	}
+	return replicas, nil
+}
+
+// holdHPA pins or raises the bounds of the HPA targeting the Deployment to
+// the canary replicas, remembering the bounds it had before the rollout
+func (r *CanaryDeploymentReconciler) holdHPA(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, deployment *appsv1.Deployment, replicas int32) error {
+	hpa, err := r.deploymentHPA(ctx, deployment)
+	if err != nil || hpa == nil {
+		return err
+	}
+
+	if canary.Status.OriginalHPA == nil {
+		original := &gatewaycdv1alpha1.HPABounds{Name: hpa.Name, MaxReplicas: hpa.Spec.MaxReplicas}
+		if hpa.Spec.MinReplicas != nil {
+			minReplicas := *hpa.Spec.MinReplicas
+			original.MinReplicas = &minReplicas
+		}
+		canary.Status.OriginalHPA = original
+	}
+
+	maxReplicas := replicas
+	if canary.Spec.CanaryScale.HPAPolicy == gatewaycdv1alpha1.HPAPolicyAdjust {
+		maxReplicas = max(canary.Status.OriginalHPA.MaxReplicas, replicas)
+	}
+	if hpa.Spec.MinReplicas != nil && *hpa.Spec.MinReplicas == replicas && hpa.Spec.MaxReplicas == maxReplicas {
+		return nil
+	}
+
+	patch := client.MergeFrom(hpa.DeepCopy())
+	hpa.Spec.MinReplicas = &replicas
+	hpa.Spec.MaxReplicas = maxReplicas
+	if err := r.Patch(ctx, hpa, patch); err != nil {
+		return fmt.Errorf("failed to hold HorizontalPodAutoscaler %s/%s: %w", hpa.Namespace, hpa.Name, err)
+	}
+	r.event(canary, EventReasonHPAHeld, "HorizontalPodAutoscaler %s held at %d-%d replicas for the rollout",
+		hpa.Name, replicas, maxReplicas)
+	return nil
+}
+
+// releaseHPA restores the bounds the HPA targeting the canary had before
+// the rollout held it
+func (r *CanaryDeploymentReconciler) releaseHPA(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	original := canary.Status.OriginalHPA
+	if original == nil {
+		return nil
+	}
+
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	if err := r.Get(ctx, types.NamespacedName{Name: original.Name, Namespace: canary.Namespace}, hpa); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			canary.Status.OriginalHPA = nil
+			return nil
+		}
+		return fmt.Errorf("failed to get HorizontalPodAutoscaler %s/%s: %w", canary.Namespace, original.Name, err)
+	}
+	patch := client.MergeFrom(hpa.DeepCopy())
+	hpa.Spec.MinReplicas = original.MinReplicas
+	hpa.Spec.MaxReplicas = original.MaxReplicas
+	if err := r.Patch(ctx, hpa, patch); err != nil {
+		return fmt.Errorf("failed to restore HorizontalPodAutoscaler %s/%s: %w", hpa.Namespace, hpa.Name, err)
+	}
+	canary.Status.OriginalHPA = nil
+	r.event(canary, EventReasonHPARestored, "HorizontalPodAutoscaler %s autoscales Deployment %s again",
+		hpa.Name, canary.Spec.TargetRef.Name)
+	return nil
+}
+
+// deploymentHPA finds the HPA scaling a Deployment, or nil if there is none
+func (r *CanaryDeploymentReconciler) deploymentHPA(ctx context.Context, deployment *appsv1.Deployment) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	var hpas autoscalingv2.HorizontalPodAutoscalerList
+	if err := r.List(ctx, &hpas, client.InNamespace(deployment.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list HorizontalPodAutoscalers: %w", err)
+	}
+	for i := range hpas.Items {
+		target := hpas.Items[i].Spec.ScaleTargetRef
+		if target.Kind == "Deployment" && target.Name == deployment.Name {
+			return &hpas.Items[i], nil
+		}
+	}
+	return nil, nil
+}
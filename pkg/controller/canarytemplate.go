@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// resolveCanaryTemplate merges the referenced CanaryTemplate into the
+// in-memory spec. Steps and notifications set on the canary replace the
+// template's, its analysis is overlaid like an analysis template. Like the
+// resolved analysis template, the merge is never written back.
+func (r *CanaryDeploymentReconciler) resolveCanaryTemplate(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	ref := canary.Spec.TemplateRef
+	if ref == nil {
+		return nil
+	}
+
+	var template gatewaycdv1alpha1.CanaryTemplate
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name}, &template); err != nil {
+		return fmt.Errorf("failed to get CanaryTemplate %s: %w", ref.Name, err)
+	}
+	mergeCanaryTemplate(&canary.Spec, &template.Spec)
+	if len(canary.Spec.TrafficSplit) == 0 {
+		return fmt.Errorf("neither the canary nor CanaryTemplate %s define traffic split steps", ref.Name)
+	}
+	return nil
+}
+
+// mergeCanaryTemplate fills the fields of spec left unset from the template
+func mergeCanaryTemplate(spec *gatewaycdv1alpha1.CanaryDeploymentSpec, template *gatewaycdv1alpha1.CanaryTemplateSpec) {
+	if len(spec.TrafficSplit) == 0 {
+		spec.TrafficSplit = template.DeepCopy().TrafficSplit
+	}
+	spec.Analysis = mergeAnalysis(template.Analysis, spec.Analysis)
+	if spec.AnalysisTemplateRef == nil && template.AnalysisTemplateRef != nil {
+		spec.AnalysisTemplateRef = template.AnalysisTemplateRef.DeepCopy()
+	}
+	if spec.Notifications == nil && template.Notifications != nil {
+		spec.Notifications = template.Notifications.DeepCopy()
+	}
+}
@@ -0,0 +1,304 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups="",resources=nodes;pods;resourcequotas,verbs=list
+
+// checkCapacity records the CapacityInsufficient condition and reports
+// whether the namespace quotas or the schedulable nodes lack room for the
+// canary pods not scheduled yet. Node selectors, affinities and taints are
+// not considered, so the check only catches clusters that are plainly full.
+func (r *CanaryDeploymentReconciler) checkCapacity(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (bool, error) {
+	if canary.Spec.TargetRef.Kind != "Deployment" || r.APIReader == nil {
+		return false, nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return false, err
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	created, scheduled, err := r.canaryPods(ctx, deployment)
+	if err != nil {
+		return false, err
+	}
+	missing := int(replicas) - scheduled
+	if missing <= 0 {
+		setCondition(canary, gatewaycdv1alpha1.ConditionTypeCapacityInsufficient, metav1.ConditionFalse, "PodsScheduled",
+			"All canary pods are scheduled")
+		return false, nil
+	}
+
+	// Pods already created are charged to the quotas, but still need a node
+	requests := podRequests(&deployment.Spec.Template.Spec)
+	var shortfalls []string
+	if uncreated := int(replicas) - created; uncreated > 0 {
+		shortfalls, err = r.quotaShortfalls(ctx, deployment.Namespace, requests, uncreated)
+		if err != nil {
+			return false, err
+		}
+	}
+	if len(shortfalls) == 0 {
+		shortfall, err := r.nodeShortfall(ctx, requests, missing)
+		if err != nil {
+			return false, err
+		}
+		if shortfall != "" {
+			shortfalls = append(shortfalls, shortfall)
+		}
+	}
+	if len(shortfalls) == 0 {
+		setCondition(canary, gatewaycdv1alpha1.ConditionTypeCapacityInsufficient, metav1.ConditionFalse, "CapacityAvailable",
+			fmt.Sprintf("Room for %d canary pods", missing))
+		return false, nil
+	}
+
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeCapacityInsufficient, metav1.ConditionTrue, "CapacityInsufficient",
+		strings.Join(shortfalls, "; "))
+	return true, nil
+}
+
+// canaryPods counts the pods of the target Deployment's current revision
+// and those of them bound to a node
+func (r *CanaryDeploymentReconciler) canaryPods(ctx context.Context, deployment *appsv1.Deployment) (created, scheduled int, err error) {
+	pods, err := r.revisionPods(ctx, deployment)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, pod := range pods {
+		created++
+		if pod.Spec.NodeName != "" {
+			scheduled++
+		}
+	}
+	return created, scheduled, nil
+}
+
+// revisionPods returns the pods of the target Deployment's current revision
+// that are not being deleted
+func (r *CanaryDeploymentReconciler) revisionPods(ctx context.Context, deployment *appsv1.Deployment) ([]corev1.Pod, error) {
+	replicaSets, err := r.revisionReplicaSets(ctx, deployment)
+	if err != nil {
+		return nil, err
+	}
+	hash := ""
+	current := deploymentRevision(&deployment.ObjectMeta)
+	for _, rs := range replicaSets {
+		if deploymentRevision(&rs.ObjectMeta) == current {
+			hash = rs.Labels[labelPodTemplateHash]
+		}
+	}
+	if hash == "" {
+		return nil, nil
+	}
+
+	var pods corev1.PodList
+	if err := r.APIReader.List(ctx, &pods, client.InNamespace(deployment.Namespace),
+		client.MatchingLabels{labelPodTemplateHash: hash}); err != nil {
+		return nil, fmt.Errorf("failed to list canary pods: %w", err)
+	}
+	var live []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp == nil {
+			live = append(live, pod)
+		}
+	}
+	return live, nil
+}
+
+// quotaShortfalls returns the ResourceQuotas of the namespace that cannot
+// admit count more pods with the given requests. Only pod counts and
+// requests are checked, not limits or object counts.
+func (r *CanaryDeploymentReconciler) quotaShortfalls(ctx context.Context, namespace string, requests corev1.ResourceList, count int) ([]string, error) {
+	var quotas corev1.ResourceQuotaList
+	if err := r.APIReader.List(ctx, &quotas, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list ResourceQuotas: %w", err)
+	}
+
+	var shortfalls []string
+	for _, quota := range quotas.Items {
+		names := make([]string, 0, len(quota.Status.Hard))
+		for name := range quota.Status.Hard {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			var needed resource.Quantity
+			switch {
+			case name == string(corev1.ResourcePods) || name == "count/pods":
+				needed = *resource.NewQuantity(int64(count), resource.DecimalSI)
+			case name == string(corev1.ResourceCPU) || name == string(corev1.ResourceMemory) ||
+				name == string(corev1.ResourceEphemeralStorage) || strings.HasPrefix(name, "requests."):
+				request, ok := requests[corev1.ResourceName(strings.TrimPrefix(name, "requests."))]
+				if !ok {
+					continue
+				}
+				needed = multiply(request, count)
+			default:
+				continue
+			}
+
+			left := quota.Status.Hard[corev1.ResourceName(name)].DeepCopy()
+			left.Sub(quota.Status.Used[corev1.ResourceName(name)])
+			if needed.Cmp(left) > 0 {
+				shortfalls = append(shortfalls, fmt.Sprintf("ResourceQuota %s: %s needs %s, %s left",
+					quota.Name, name, needed.String(), left.String()))
+			}
+		}
+	}
+	return shortfalls, nil
+}
+
+// nodeShortfall places count pods with the given requests on the free
+// allocatable capacity of the ready, schedulable nodes, first fit, and
+// describes the shortfall if they don't all fit
+func (r *CanaryDeploymentReconciler) nodeShortfall(ctx context.Context, requests corev1.ResourceList, count int) (string, error) {
+	var nodes corev1.NodeList
+	if err := r.APIReader.List(ctx, &nodes); err != nil {
+		return "", fmt.Errorf("failed to list nodes: %w", err)
+	}
+	var pods corev1.PodList
+	if err := r.APIReader.List(ctx, &pods, client.MatchingFieldsSelector{
+		Selector: fields.ParseSelectorOrDie("status.phase!=Succeeded,status.phase!=Failed"),
+	}); err != nil {
+		return "", fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	free := make(map[string]corev1.ResourceList)
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable || !nodeReady(&node) {
+			continue
+		}
+		free[node.Name] = node.Status.Allocatable.DeepCopy()
+	}
+	for _, pod := range pods.Items {
+		available, ok := free[pod.Spec.NodeName]
+		if !ok {
+			continue
+		}
+		for name, quantity := range podRequests(&pod.Spec) {
+			if left, ok := available[name]; ok {
+				left.Sub(quantity)
+				available[name] = left
+			}
+		}
+		if left, ok := available[corev1.ResourcePods]; ok {
+			left.Sub(*resource.NewQuantity(1, resource.DecimalSI))
+			available[corev1.ResourcePods] = left
+		}
+	}
+
+	names := make([]string, 0, len(free))
+	for name := range free {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	placed := 0
+	for _, name := range names {
+		for placed < count && fits(free[name], requests) {
+			for resourceName, quantity := range requests {
+				if left, ok := free[name][resourceName]; ok {
+					left.Sub(quantity)
+					free[name][resourceName] = left
+				}
+			}
+			if left, ok := free[name][corev1.ResourcePods]; ok {
+				left.Sub(*resource.NewQuantity(1, resource.DecimalSI))
+				free[name][corev1.ResourcePods] = left
+			}
+			placed++
+		}
+	}
+	if placed >= count {
+		return "", nil
+	}
+	return fmt.Sprintf("only %d of %d canary pods requesting %s fit on the %d schedulable nodes",
+		placed, count, formatResources(requests), len(names)), nil
+}
+
+// podRequests returns the resources a pod requests from the scheduler: the
+// larger of its containers' sum and any init container, plus its overhead
+func podRequests(spec *corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			sum := requests[name]
+			sum.Add(quantity)
+			requests[name] = sum
+		}
+	}
+	for _, container := range spec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if current, ok := requests[name]; !ok || quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	for name, quantity := range spec.Overhead {
+		sum := requests[name]
+		sum.Add(quantity)
+		requests[name] = sum
+	}
+	return requests
+}
+
+// fits reports whether a pod with the given requests fits in the free
+// resources, including a free pod slot
+func fits(free, requests corev1.ResourceList) bool {
+	if pods, ok := free[corev1.ResourcePods]; ok && pods.Sign() <= 0 {
+		return false
+	}
+	for name, quantity := range requests {
+		if left, ok := free[name]; ok && quantity.Cmp(left) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// multiply returns quantity times n
+func multiply(quantity resource.Quantity, n int) resource.Quantity {
+	return *resource.NewMilliQuantity(quantity.MilliValue()*int64(n), quantity.Format)
+}
+
+// formatResources renders requests as name=quantity pairs in name order
+func formatResources(requests corev1.ResourceList) string {
+	if len(requests) == 0 {
+		return "no resources"
+	}
+	pairs := make([]string, 0, len(requests))
+	for name, quantity := range requests {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, quantity.String()))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// nodeReady reports whether the node's Ready condition is True
+func nodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// Limits that keep the status of long-running rollouts well below the etcd
+// object size limit
+const (
+	// maxStatusMessageLength is the number of bytes kept of status and condition messages
+	maxStatusMessageLength = 1024
+	// maxStatusMetricResults is the number of metric results kept in the analysis run status
+	maxStatusMetricResults = 20
+	// maxStatusApprovals is the number of approval records kept in the status
+	maxStatusApprovals = 20
+	// maxStatusHistory is the number of step transitions kept in status.history
+	maxStatusHistory = 50
+	// maxStatusAudit is the number of control actions kept in status.audit
+	maxStatusAudit = 50
+	// maxHistoryBytes is the size the history ConfigMap is trimmed to, oldest records first
+	maxHistoryBytes = 512 * 1024
+)
+
+// HistoryKey is the history ConfigMap key holding one JSON record per line
+const HistoryKey = "history.jsonl"
+
+// historyRecord is a status entry compacted out of the canary status
+type historyRecord struct {
+	Time   metav1.Time `json:"time"`
+	Kind   string      `json:"kind"`
+	Record interface{} `json:"record"`
+}
+
+// compactStatus bounds the status fields that grow with the rollout. Approval
+// records, step transitions, control actions and metric results over the
+// limits are moved to the history ConfigMap; messages are truncated.
+func (r *CanaryDeploymentReconciler) compactStatus(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) {
+	var overflow []historyRecord
+	now := metav1.Now()
+
+	if n := len(canary.Status.Approvals) - maxStatusApprovals; n > 0 {
+		for _, record := range canary.Status.Approvals[:n] {
+			overflow = append(overflow, historyRecord{Time: now, Kind: "Approval", Record: record})
+		}
+		canary.Status.Approvals = append([]gatewaycdv1alpha1.ApprovalRecord(nil), canary.Status.Approvals[n:]...)
+	}
+	if n := len(canary.Status.History) - maxStatusHistory; n > 0 {
+		for _, transition := range canary.Status.History[:n] {
+			overflow = append(overflow, historyRecord{Time: now, Kind: "StepTransition", Record: transition})
+		}
+		canary.Status.History = append([]gatewaycdv1alpha1.StepTransition(nil), canary.Status.History[n:]...)
+	}
+	if n := len(canary.Status.Audit) - maxStatusAudit; n > 0 {
+		for _, action := range canary.Status.Audit[:n] {
+			overflow = append(overflow, historyRecord{Time: now, Kind: "ControlAction", Record: action})
+		}
+		canary.Status.Audit = append([]gatewaycdv1alpha1.ControlAction(nil), canary.Status.Audit[n:]...)
+	}
+	if run := canary.Status.AnalysisRun; run != nil && len(run.MetricResults) > maxStatusMetricResults {
+		run.MetricResults = compactMetricResults(run.MetricResults)
+		overflow = append(overflow, historyRecord{Time: now, Kind: "MetricResults", Record: run.MetricResults[maxStatusMetricResults:]})
+		run.MetricResults = run.MetricResults[:maxStatusMetricResults]
+	}
+
+	canary.Status.Message = truncateMessage(canary.Status.Message)
+	canary.Status.RollbackReason = truncateMessage(canary.Status.RollbackReason)
+	for i := range canary.Status.Conditions {
+		canary.Status.Conditions[i].Message = truncateMessage(canary.Status.Conditions[i].Message)
+	}
+
+	if len(overflow) == 0 {
+		return
+	}
+	// The status is compacted even when the history cannot be written, an
+	// oversized status would block every later update
+	if err := r.appendHistory(ctx, canary, overflow); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to record compacted status history")
+	}
+}
+
+// compactMetricResults orders failed metrics first so the results kept in the
+// status are the ones explaining a failure
+func compactMetricResults(results []gatewaycdv1alpha1.MetricResult) []gatewaycdv1alpha1.MetricResult {
+	ordered := make([]gatewaycdv1alpha1.MetricResult, 0, len(results))
+	for _, result := range results {
+		if !result.Passed {
+			ordered = append(ordered, result)
+		}
+	}
+	for _, result := range results {
+		if result.Passed {
+			ordered = append(ordered, result)
+		}
+	}
+	return ordered
+}
+
+// truncateMessage shortens a message to maxStatusMessageLength bytes without
+// splitting a UTF-8 character
+func truncateMessage(message string) string {
+	if len(message) <= maxStatusMessageLength {
+		return message
+	}
+	const marker = "... (truncated)"
+	cut := maxStatusMessageLength - len(marker)
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut] + marker
+}
+
+// historyConfigMapName is the name of the ConfigMap holding a canary's compacted status
+func historyConfigMapName(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	return fmt.Sprintf("%s-history", canary.Name)
+}
+
+// appendHistory appends records to the canary's history ConfigMap, dropping
+// the oldest records once it exceeds maxHistoryBytes
+func (r *CanaryDeploymentReconciler) appendHistory(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, records []historyRecord) error {
+	var lines []string
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode history record: %w", err)
+		}
+		lines = append(lines, string(data))
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: historyConfigMapName(canary), Namespace: canary.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		if cm.Labels == nil {
+			cm.Labels = make(map[string]string)
+		}
+		cm.Labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+		cm.Labels[labelCanary] = canary.Name
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		cm.Data[HistoryKey] = trimHistory(cm.Data[HistoryKey] + strings.Join(lines, "\n") + "\n")
+		return controllerutil.SetControllerReference(canary, cm, r.Scheme)
+	}); err != nil {
+		return fmt.Errorf("failed to update history ConfigMap %s/%s: %w", cm.Namespace, cm.Name, err)
+	}
+	canary.Status.HistoryConfigMap = cm.Name
+	return nil
+}
+
+// trimHistory drops whole records from the start of the history until it fits
+// in maxHistoryBytes
+func trimHistory(history string) string {
+	for len(history) > maxHistoryBytes {
+		i := strings.IndexByte(history, '\n')
+		if i < 0 {
+			return ""
+		}
+		history = history[i+1:]
+	}
+	return history
+}
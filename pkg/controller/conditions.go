@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// updateStatus derives the standard conditions from the current phase and
+// patches the status, so every phase change is reflected in the conditions.
+// The in-memory spec, which may hold a resolved analysis template, is kept.
+func (r *CanaryDeploymentReconciler) updateStatus(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	setPhaseConditions(canary)
+	r.compactStatus(ctx, canary)
+	recordStatusMetrics(canary)
+
+	spec := canary.Spec.DeepCopy()
+	err := r.patchStatus(ctx, canary)
+	canary.Spec = *spec
+	return err
+}
+
+// setPhaseConditions sets Ready, Progressing and RolledBack from the phase and
+// records the generation they were computed for
+func setPhaseConditions(canary *gatewaycdv1alpha1.CanaryDeployment) {
+	canary.Status.ObservedGeneration = canary.Generation
+
+	ready := metav1.ConditionFalse
+	progressing := metav1.ConditionFalse
+	reason := string(canary.Status.Phase)
+	switch canary.Status.Phase {
+	case gatewaycdv1alpha1.CanaryDeploymentPhaseSucceeded:
+		ready = metav1.ConditionTrue
+	case gatewaycdv1alpha1.CanaryDeploymentPhasePending,
+		gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing,
+		gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack:
+		progressing = metav1.ConditionTrue
+	}
+
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeReady, ready, reason, canary.Status.Message)
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeProgressing, progressing, reason, canary.Status.Message)
+
+	rolledBack := metav1.ConditionFalse
+	rolledBackMessage := "Canary has not been rolled back"
+	if canary.Status.RollbackReason != "" &&
+		(canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack ||
+			canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhaseFailed) {
+		rolledBack = metav1.ConditionTrue
+		rolledBackMessage = canary.Status.RollbackReason
+	}
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeRolledBack, rolledBack, reason, rolledBackMessage)
+
+	setHealthConditions(canary)
+}
+
+// setHealthConditions sets status.health and the kstatus Reconciling and
+// Stalled conditions, so Argo CD and Flux report the health of the rollout
+func setHealthConditions(canary *gatewaycdv1alpha1.CanaryDeployment) {
+	health, message := canaryHealth(canary)
+	canary.Status.Health = health
+
+	reconciling := metav1.ConditionFalse
+	stalled := metav1.ConditionFalse
+	switch health {
+	case gatewaycdv1alpha1.HealthStatusProgressing:
+		reconciling = metav1.ConditionTrue
+	case gatewaycdv1alpha1.HealthStatusDegraded:
+		stalled = metav1.ConditionTrue
+	}
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeReconciling, reconciling, string(health), message)
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeStalled, stalled, string(health), message)
+}
+
+// suspendingConditions hold a progressing rollout without it failing
+var suspendingConditions = []string{
+	gatewaycdv1alpha1.ConditionTypeWaiting,
+	gatewaycdv1alpha1.ConditionTypeQueued,
+	gatewaycdv1alpha1.ConditionTypeBlocked,
+}
+
+// canaryHealth maps the phase and conditions of a canary to its health and
+// the message explaining it
+func canaryHealth(canary *gatewaycdv1alpha1.CanaryDeployment) (gatewaycdv1alpha1.HealthStatus, string) {
+	message := canary.Status.Message
+	switch canary.Status.Phase {
+	case gatewaycdv1alpha1.CanaryDeploymentPhaseSucceeded:
+		return gatewaycdv1alpha1.HealthStatusHealthy, message
+	case gatewaycdv1alpha1.CanaryDeploymentPhaseFailed:
+		if canary.Status.RollbackReason != "" {
+			message = canary.Status.RollbackReason
+		}
+		return gatewaycdv1alpha1.HealthStatusDegraded, message
+	case gatewaycdv1alpha1.CanaryDeploymentPhasePaused:
+		return gatewaycdv1alpha1.HealthStatusSuspended, message
+	case gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack:
+		return gatewaycdv1alpha1.HealthStatusProgressing, message
+	}
+
+	if condition := meta.FindStatusCondition(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeDegraded); condition != nil && condition.Status == metav1.ConditionTrue {
+		return gatewaycdv1alpha1.HealthStatusDegraded, condition.Message
+	}
+	for _, conditionType := range suspendingConditions {
+		if condition := meta.FindStatusCondition(canary.Status.Conditions, conditionType); condition != nil && condition.Status == metav1.ConditionTrue {
+			return gatewaycdv1alpha1.HealthStatusSuspended, condition.Message
+		}
+	}
+	return gatewaycdv1alpha1.HealthStatusProgressing, message
+}
+
+// setAnalysisCondition records the outcome of the latest analysis
+func setAnalysisCondition(canary *gatewaycdv1alpha1.CanaryDeployment, passed bool, reason, message string) {
+	status := metav1.ConditionFalse
+	if passed {
+		status = metav1.ConditionTrue
+	}
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeAnalysisSucceeded, status, reason, message)
+}
+
+// markAnalysisSkipped records that the rollout advanced without analysis. The
+// condition stays True for the rest of the rollout so audits find rollouts
+// promoted without a quality gate.
+func markAnalysisSkipped(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, reason, message string) {
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeAnalysisSkipped, metav1.ConditionTrue, reason, message)
+	afterStatusWrite(ctx, func() { recordAnalysisSkipped(canary, reason) })
+}
+
+// analysisSkipReason explains why analysisEnabled is false
+func analysisSkipReason(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	if canary.Spec.SkipAnalysis {
+		return "SkipAnalysis"
+	}
+	return "NoAnalysisCriteria"
+}
+
+// analysisOutcome renders an analysis result for condition messages
+func analysisOutcome(passed bool) string {
+	if passed {
+		return "passed"
+	}
+	return "failed"
+}
+
+// setCondition sets a condition observed at the current generation
+func setCondition(canary *gatewaycdv1alpha1.CanaryDeployment, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&canary.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: canary.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// configRevisionHashLength is the number of hex characters of the content hash suffixed to copies
+const configRevisionHashLength = 10
+
+// reconcileConfigRevisions copies the canary version of each config revision
+// to a content-hashed copy, points the target Deployment at the copies and
+// deletes the copies they supersede
+func (r *CanaryDeploymentReconciler) reconcileConfigRevisions(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	if len(canary.Spec.ConfigRevisions) == 0 {
+		return nil
+	}
+	if canary.Spec.TargetRef.Kind != "Deployment" {
+		log.FromContext(ctx).Info("Config revisions only support Deployments", "kind", canary.Spec.TargetRef.Kind)
+		return nil
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      canary.Spec.TargetRef.Name,
+		Namespace: canary.Namespace,
+	}, deployment); err != nil {
+		return fmt.Errorf("failed to get Deployment %s/%s: %w", canary.Namespace, canary.Spec.TargetRef.Name, err)
+	}
+	patch := client.MergeFrom(deployment.DeepCopy())
+
+	var updated []string
+	var superseded []client.Object
+	for _, revision := range canary.Spec.ConfigRevisions {
+		copyName, err := r.copyConfigRevision(ctx, canary, revision)
+		if err != nil {
+			return err
+		}
+
+		replaced := retargetConfigReferences(&deployment.Spec.Template.Spec, revision, copyName)
+		if len(replaced) == 0 {
+			continue
+		}
+		updated = append(updated, fmt.Sprintf("%s %s", revision.Kind, copyName))
+		for _, name := range replaced {
+			if isConfigRevisionCopy(name, revision.Name) {
+				superseded = append(superseded, configObject(revision.Kind, canary.Namespace, name))
+			}
+		}
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+
+	if err := r.Patch(ctx, deployment, patch); err != nil {
+		return fmt.Errorf("failed to update Deployment %s/%s: %w", canary.Namespace, deployment.Name, err)
+	}
+	r.event(canary, EventReasonConfigRevisionUpdated, "Deployment %s now mounts %s", deployment.Name, strings.Join(updated, ", "))
+
+	for _, obj := range superseded {
+		if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete superseded config revision %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// copyConfigRevision creates the content-hashed copy of the canary version of
+// a ConfigMap or Secret and returns its name
+func (r *CanaryDeploymentReconciler) copyConfigRevision(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, revision gatewaycdv1alpha1.ConfigRevision) (string, error) {
+	source := configObject(revision.Kind, canary.Namespace, revision.CanaryName)
+	if source == nil {
+		return "", fmt.Errorf("unsupported config revision kind %q", revision.Kind)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(source), source); err != nil {
+		return "", fmt.Errorf("failed to get %s %s/%s: %w", revision.Kind, canary.Namespace, revision.CanaryName, err)
+	}
+
+	var target client.Object
+	var mutate func()
+	switch src := source.(type) {
+	case *corev1.ConfigMap:
+		data := make(map[string][]byte, len(src.Data)+len(src.BinaryData))
+		for k, v := range src.Data {
+			data[k] = []byte(v)
+		}
+		for k, v := range src.BinaryData {
+			data[k] = v
+		}
+		dst := configObject(revision.Kind, canary.Namespace, fmt.Sprintf("%s-%s", revision.Name, contentHash(data))).(*corev1.ConfigMap)
+		target, mutate = dst, func() {
+			dst.Data = src.Data
+			dst.BinaryData = src.BinaryData
+		}
+	case *corev1.Secret:
+		dst := configObject(revision.Kind, canary.Namespace, fmt.Sprintf("%s-%s", revision.Name, contentHash(src.Data))).(*corev1.Secret)
+		target, mutate = dst, func() {
+			dst.Type = src.Type
+			dst.Data = src.Data
+		}
+	}
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, target, func() error {
+		labels := target.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+		labels[labelCanary] = canary.Name
+		target.SetLabels(labels)
+		mutate()
+		return controllerutil.SetControllerReference(canary, target, r.Scheme)
+	}); err != nil {
+		return "", fmt.Errorf("failed to reconcile %s %s/%s: %w", revision.Kind, canary.Namespace, target.GetName(), err)
+	}
+	return target.GetName(), nil
+}
+
+// configObject returns an empty ConfigMap or Secret with the given name, or
+// nil for other kinds
+func configObject(kind, namespace, name string) client.Object {
+	meta := metav1.ObjectMeta{Namespace: namespace, Name: name}
+	switch kind {
+	case "ConfigMap":
+		return &corev1.ConfigMap{ObjectMeta: meta}
+	case "Secret":
+		return &corev1.Secret{ObjectMeta: meta}
+	}
+	return nil
+}
+
+// contentHash hashes config data independently of key order
+func contentHash(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write(data[k])
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:configRevisionHashLength]
+}
+
+// isConfigRevisionCopy reports whether name is a content-hashed copy of base
+func isConfigRevisionCopy(name, base string) bool {
+	hash, ok := strings.CutPrefix(name, base+"-")
+	if !ok || len(hash) != configRevisionHashLength {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// retargetConfigReferences points the pod spec's volume and environment
+// references to the revision's ConfigMap or Secret, or an older copy of it, at
+// copyName and returns the names it replaced
+func retargetConfigReferences(spec *corev1.PodSpec, revision gatewaycdv1alpha1.ConfigRevision, copyName string) []string {
+	replaced := map[string]bool{}
+	retarget := func(name *string) {
+		if *name != copyName && (*name == revision.Name || isConfigRevisionCopy(*name, revision.Name)) {
+			replaced[*name] = true
+			*name = copyName
+		}
+	}
+	configMap := revision.Kind == "ConfigMap"
+
+	for i := range spec.Volumes {
+		volume := &spec.Volumes[i]
+		if configMap && volume.ConfigMap != nil {
+			retarget(&volume.ConfigMap.Name)
+		}
+		if !configMap && volume.Secret != nil {
+			retarget(&volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for j := range volume.Projected.Sources {
+				source := &volume.Projected.Sources[j]
+				if configMap && source.ConfigMap != nil {
+					retarget(&source.ConfigMap.Name)
+				}
+				if !configMap && source.Secret != nil {
+					retarget(&source.Secret.Name)
+				}
+			}
+		}
+	}
+
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			container := &containers[i]
+			for j := range container.EnvFrom {
+				envFrom := &container.EnvFrom[j]
+				if configMap && envFrom.ConfigMapRef != nil {
+					retarget(&envFrom.ConfigMapRef.Name)
+				}
+				if !configMap && envFrom.SecretRef != nil {
+					retarget(&envFrom.SecretRef.Name)
+				}
+			}
+			for j := range container.Env {
+				valueFrom := container.Env[j].ValueFrom
+				if valueFrom == nil {
+					continue
+				}
+				if configMap && valueFrom.ConfigMapKeyRef != nil {
+					retarget(&valueFrom.ConfigMapKeyRef.Name)
+				}
+				if !configMap && valueFrom.SecretKeyRef != nil {
+					retarget(&valueFrom.SecretKeyRef.Name)
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(replaced))
+	for name := range replaced {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
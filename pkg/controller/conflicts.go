@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// AnnotationAllowSharedRoute set to "true" starts the rollout even though
+// another progressive delivery controller manages one of its routes
+const AnnotationAllowSharedRoute = "gateway-cd.io/allow-shared-route"
+
+// checkRouteConflicts records the RouteConflict condition and reports whether
+// the rollout must wait because another controller manages one of its routes
+func (r *CanaryDeploymentReconciler) checkRouteConflicts(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (bool, error) {
+	conflicts, err := r.GatewayManager.DetectRouteConflicts(ctx, canary)
+	if err != nil {
+		return false, err
+	}
+	if len(conflicts) == 0 {
+		setCondition(canary, gatewaycdv1alpha1.ConditionTypeRouteConflict, metav1.ConditionFalse, "NoConflict",
+			"No other progressive delivery controller manages the routes")
+		return false, nil
+	}
+
+	descriptions := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		descriptions = append(descriptions, conflict.String())
+	}
+	summary := strings.Join(descriptions, "; ")
+
+	if canary.Annotations[AnnotationAllowSharedRoute] == "true" {
+		setCondition(canary, gatewaycdv1alpha1.ConditionTypeRouteConflict, metav1.ConditionFalse, "ConflictOverridden",
+			fmt.Sprintf("Starting despite shared routes, %s is set: %s", AnnotationAllowSharedRoute, summary))
+		return false, nil
+	}
+
+	if !meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeRouteConflict) {
+		r.warning(canary, EventReasonRouteConflict, "Refusing to start, %s", summary)
+	}
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeRouteConflict, metav1.ConditionTrue, "ControllerConflict", summary)
+	canary.Status.Message = fmt.Sprintf("Waiting, another controller manages the routes: %s. Set %s: \"true\" to start anyway",
+		summary, AnnotationAllowSharedRoute)
+	return true, nil
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// checkProgressDeadline marks a rollout that has stayed Pending or at a step
+// longer than spec.progressDeadlineSeconds as Degraded, once, and rolls it
+// back when spec.rollbackOnProgressDeadline is set. It reports whether the
+// rollout was rolled back. The condition is cleared once the rollout advances
+// again; paused rollouts are waiting on a person and rollouts outside their
+// schedule on a window, so neither is ever degraded.
+func (r *CanaryDeploymentReconciler) checkProgressDeadline(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (bool, error) {
+	if canary.Spec.ProgressDeadlineSeconds == nil ||
+		meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeWaiting) {
+		return false, nil
+	}
+	if canary.Status.Phase != gatewaycdv1alpha1.CanaryDeploymentPhasePending &&
+		canary.Status.Phase != gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing {
+		return false, nil
+	}
+
+	lastProgress := canary.Status.LastTransitionTime
+	if canary.Status.LastProgressTime != nil && (lastProgress == nil || lastProgress.Before(canary.Status.LastProgressTime)) {
+		lastProgress = canary.Status.LastProgressTime
+	}
+	if lastProgress == nil {
+		return false, nil
+	}
+
+	deadline := time.Duration(*canary.Spec.ProgressDeadlineSeconds) * time.Second
+	degraded := meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeDegraded)
+	if time.Since(lastProgress.Time) <= deadline {
+		if degraded {
+			setCondition(canary, gatewaycdv1alpha1.ConditionTypeDegraded, metav1.ConditionFalse, "ProgressResumed", "Rollout is progressing again")
+		}
+		return false, nil
+	}
+	if degraded && !canary.Spec.RollbackOnProgressDeadline {
+		return false, nil
+	}
+
+	message := fmt.Sprintf("Rollout has not progressed past step %d in %s", canary.Status.CurrentStep+1, deadline)
+	if canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhasePending {
+		message = fmt.Sprintf("Rollout has been Pending for more than %s", deadline)
+	}
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeDegraded, metav1.ConditionTrue, EventReasonProgressDeadlineExceeded, message)
+
+	if !canary.Spec.RollbackOnProgressDeadline {
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return false, err
+		}
+		r.warning(canary, EventReasonProgressDeadlineExceeded, "%s", message)
+		return false, nil
+	}
+
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+	canary.Status.RollbackReason = fmt.Sprintf("Progress deadline exceeded: %s", message)
+	canary.Status.Message = "Progress deadline exceeded, rolling back"
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return false, err
+	}
+	r.warning(canary, EventReasonProgressDeadlineExceeded, "%s, rolling back", message)
+	return true, nil
+}
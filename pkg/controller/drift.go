@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/gateway"
+)
+
+// correctRouteDrift restores the split of the managed routes when another
+// controller or a human changed their weights since the controller last
+// wrote them, and records what changed in a DriftCorrected event. Paused
+// rollouts get the weights they hold written back; progressing rollouts
+// rewrite the routes of their step on every reconcile and only record the
+// drift. It reports whether any route had drifted.
+func (r *CanaryDeploymentReconciler) correctRouteDrift(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (bool, error) {
+	drifted, err := r.GatewayManager.DriftedRoutes(ctx, canary)
+	if err != nil || len(drifted) == 0 {
+		return false, err
+	}
+	r.warning(canary, EventReasonDriftCorrected, "Restoring the traffic split of routes changed outside the rollout: %s",
+		driftSummary(drifted))
+	if canary.Status.Phase != gatewaycdv1alpha1.CanaryDeploymentPhasePaused {
+		return true, nil
+	}
+	return true, r.applyHeldWeights(ctx, canary)
+}
+
+// applyHeldWeights writes the weights a paused rollout holds: those of the
+// current step, or the canary weight of an override or of a pause that
+// interrupted the step before its weights were written
+func (r *CanaryDeploymentReconciler) applyHeldWeights(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	step := int(canary.Status.CurrentStep)
+	if !meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeManualOverride) &&
+		step < len(canary.Spec.TrafficSplit) && canary.Spec.TrafficSplit[step].Weight == canary.Status.CanaryWeight {
+		return r.GatewayManager.UpdateTrafficSplitForStep(ctx, canary, step)
+	}
+	return r.GatewayManager.UpdateTrafficSplit(ctx, canary, int(canary.Status.CanaryWeight))
+}
+
+// driftSummary joins the drifted routes into one line
+func driftSummary(drifted []gateway.RouteDrift) string {
+	parts := make([]string, len(drifted))
+	for i, drift := range drifted {
+		parts[i] = drift.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// detectDrift compares the routes of progressing and paused rollouts with
+// the weights last written to them every DriftCheckInterval and queues the
+// canaries whose routes drifted, for changes the route watch misses, e.g.
+// ones made while the controller was down
+func (r *CanaryDeploymentReconciler) detectDrift(ctx context.Context, queue chan<- event.GenericEvent) error {
+	log := log.FromContext(ctx).WithName("drift")
+	ticker := time.NewTicker(r.DriftCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		var canaries gatewaycdv1alpha1.CanaryDeploymentList
+		if err := r.List(ctx, &canaries); err != nil {
+			log.Error(err, "Failed to list canaries")
+			continue
+		}
+		for i := range canaries.Items {
+			canary := &canaries.Items[i]
+			if canary.Status.Phase != gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing &&
+				canary.Status.Phase != gatewaycdv1alpha1.CanaryDeploymentPhasePaused {
+				continue
+			}
+			drifted, err := r.GatewayManager.DriftedRoutes(ctx, canary)
+			if err != nil {
+				log.Error(err, "Failed to check routes for drift", "canary", canary.Namespace+"/"+canary.Name)
+				continue
+			}
+			if len(drifted) == 0 {
+				continue
+			}
+			select {
+			case queue <- event.GenericEvent{Object: canary}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/integrations/scm"
+)
+
+// Event reasons emitted on CanaryDeployments
+const (
+	EventReasonValidationFailed         = "ValidationFailed"
+	EventReasonRolloutStarted           = "RolloutStarted"
+	EventReasonWeightChanged            = "WeightChanged"
+	EventReasonTrafficUpdateFailed      = "TrafficUpdateFailed"
+	EventReasonPaused                   = "Paused"
+	EventReasonPausedByUser             = "PausedByUser"
+	EventReasonResumed                  = "Resumed"
+	EventReasonAnalysisPassed           = "AnalysisPassed"
+	EventReasonAnalysisFailed           = "AnalysisFailed"
+	EventReasonAnalysisError            = "AnalysisError"
+	EventReasonAnalysisRetrying         = "AnalysisRetrying"
+	EventReasonAnalysisSmoothed         = "AnalysisSmoothed"
+	EventReasonProviderUnavailable      = "ProviderUnavailable"
+	EventReasonAborted                  = "Aborted"
+	EventReasonApproved                 = "Approved"
+	EventReasonRejected                 = "Rejected"
+	EventReasonApprovalRequired         = "ApprovalRequired"
+	EventReasonRolledBack               = "RolledBack"
+	EventReasonPromoted                 = "Promoted"
+	EventReasonMirroring                = "Mirroring"
+	EventReasonMirrorCompleted          = "MirrorCompleted"
+	EventReasonTimeSliceExposed         = "TimeSliceExposed"
+	EventReasonTimeSliceWithdrawn       = "TimeSliceWithdrawn"
+	EventReasonTimeSliceCompleted       = "TimeSliceCompleted"
+	EventReasonAnalysisTemplateInvalid  = "AnalysisTemplateInvalid"
+	EventReasonCanaryTemplateInvalid    = "CanaryTemplateInvalid"
+	EventReasonConfigRevisionUpdated    = "ConfigRevisionUpdated"
+	EventReasonConfigRevisionFailed     = "ConfigRevisionFailed"
+	EventReasonServiceAccountSwitched   = "ServiceAccountSwitched"
+	EventReasonServiceAccountRestored   = "ServiceAccountRestored"
+	EventReasonServiceAccountFailed     = "ServiceAccountFailed"
+	EventReasonWorkloadReverted         = "WorkloadReverted"
+	EventReasonWorkloadRevertFailed     = "WorkloadRevertFailed"
+	EventReasonWorkloadRestored         = "WorkloadRestored"
+	EventReasonRouteConflict            = "RouteConflict"
+	EventReasonCapacityInsufficient     = "CapacityInsufficient"
+	EventReasonHookStarted              = "HookStarted"
+	EventReasonHookSucceeded            = "HookSucceeded"
+	EventReasonHookFailed               = "HookFailed"
+	EventReasonRoutesProgrammed         = "RoutesProgrammed"
+	EventReasonRoutesNotProgrammed      = "RoutesNotProgrammed"
+	EventReasonRolloutRestarted         = "RolloutRestarted"
+	EventReasonRolloutReplanned         = "RolloutReplanned"
+	EventReasonQueued                   = "Queued"
+	EventReasonDryRun                   = "DryRun"
+	EventReasonDryRunFailed             = "DryRunFailed"
+	EventReasonCanaryScaled             = "CanaryScaled"
+	EventReasonCanaryScaleFailed        = "CanaryScaleFailed"
+	EventReasonHPAHeld                  = "HPAHeld"
+	EventReasonHPARestored              = "HPARestored"
+	EventReasonRetriesExhausted         = "RetriesExhausted"
+	EventReasonBaselineCreated          = "BaselineCreated"
+	EventReasonBaselineFailed           = "BaselineFailed"
+	EventReasonBaselineRemoved          = "BaselineRemoved"
+	EventReasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+	EventReasonServiceSelectorUpdated   = "ServiceSelectorUpdated"
+	EventReasonServiceSelectorFailed    = "ServiceSelectorFailed"
+	EventReasonWeightOverridden         = "WeightOverridden"
+	EventReasonWeightOverrideInvalid    = "WeightOverrideInvalid"
+	EventReasonWaitingForWindow         = "WaitingForWindow"
+	EventReasonWindowOpened             = "WindowOpened"
+	EventReasonDriftCorrected           = "DriftCorrected"
+	EventReasonCanaryScaledDown         = "CanaryScaledDown"
+	EventReasonCanaryResourcesDeleted   = "CanaryResourcesDeleted"
+	EventReasonCanaryTeardownFailed     = "CanaryTeardownFailed"
+	EventReasonBlocked                  = "Blocked"
+	EventReasonPreRolloutCheckStarted   = "PreRolloutCheckStarted"
+	EventReasonPreRolloutCheckPassed    = "PreRolloutCheckPassed"
+	EventReasonPreRolloutCheckFailed    = "PreRolloutCheckFailed"
+	EventReasonRollbackStep             = "RollbackStep"
+	EventReasonPodHealthFailed          = "PodHealthFailed"
+	EventReasonPostPromotionStarted     = "PostPromotionStarted"
+	EventReasonPromotedByUser           = "PromotedByUser"
+	EventReasonControlAction            = "ControlAction"
+	EventReasonExpired                  = "Expired"
+	EventReasonRetried                  = "Retried"
+	EventReasonRetryIgnored             = "RetryIgnored"
+)
+
+// notificationEvents maps event reasons to the notifications they trigger
+var notificationEvents = map[string]gatewaycdv1alpha1.NotificationEvent{
+	EventReasonRolloutStarted:           gatewaycdv1alpha1.NotificationEventRolloutStarted,
+	EventReasonPaused:                   gatewaycdv1alpha1.NotificationEventPausedForApproval,
+	EventReasonAnalysisFailed:           gatewaycdv1alpha1.NotificationEventAnalysisFailed,
+	EventReasonRolledBack:               gatewaycdv1alpha1.NotificationEventRolledBack,
+	EventReasonPromoted:                 gatewaycdv1alpha1.NotificationEventPromoted,
+	EventReasonProgressDeadlineExceeded: gatewaycdv1alpha1.NotificationEventProgressDeadlineExceeded,
+}
+
+// scmStates maps event reasons to the deployment states reported to the SCM
+var scmStates = map[string]scm.State{
+	EventReasonRolloutStarted: scm.StateInProgress,
+	EventReasonWeightChanged:  scm.StateInProgress,
+	EventReasonResumed:        scm.StateInProgress,
+	EventReasonRetried:        scm.StateInProgress,
+	EventReasonPaused:         scm.StatePending,
+	EventReasonPromoted:       scm.StateSuccess,
+	EventReasonRolledBack:     scm.StateFailure,
+}
+
+// event records a Normal event on the canary if a recorder is configured
+func (r *CanaryDeploymentReconciler) event(canary *gatewaycdv1alpha1.CanaryDeployment, reason, messageFmt string, args ...interface{}) {
+	r.record(canary, corev1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+// warning records a Warning event on the canary if a recorder is configured
+func (r *CanaryDeploymentReconciler) warning(canary *gatewaycdv1alpha1.CanaryDeployment, reason, messageFmt string, args ...interface{}) {
+	r.record(canary, corev1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// record sends an event to the Kubernetes event recorder, the OTLP timeline
+// and, for notable events, the notification channels and the SCM, whichever
+// are configured
+func (r *CanaryDeploymentReconciler) record(canary *gatewaycdv1alpha1.CanaryDeployment, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder != nil {
+		r.Recorder.Eventf(canary, eventType, reason, messageFmt, args...)
+	}
+	if r.Timeline != nil {
+		r.Timeline.Emit(canary, eventType, reason, fmt.Sprintf(messageFmt, args...))
+	}
+	if notification, ok := notificationEvents[reason]; ok && r.Notifier != nil {
+		r.Notifier.Notify(canary, notification, fmt.Sprintf(messageFmt, args...))
+	}
+	if state, ok := scmStates[reason]; ok && r.SCM != nil {
+		r.SCM.Report(canary, state, fmt.Sprintf(messageFmt, args...))
+	}
+}
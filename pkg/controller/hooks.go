@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+
+const (
+	// labelHook is set on hook Jobs with the hook name
+	labelHook = "gateway-cd.io/hook"
+
+	// defaultHookTimeout is how long a hook Job may run when the hook doesn't set it
+	defaultHookTimeout = time.Minute * 30
+)
+
+// hasHooks reports whether the canary has hooks of the type
+func hasHooks(canary *gatewaycdv1alpha1.CanaryDeployment, hookType gatewaycdv1alpha1.HookType) bool {
+	for _, hook := range canary.Spec.Hooks {
+		if hook.Type == hookType {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePreRolloutHooks runs the PreRollout hooks before the canary gets any
+// traffic, mirrored or real, and rolls the canary back if one fails
+func (r *CanaryDeploymentReconciler) handlePreRolloutHooks(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	done, failure, err := r.runHooks(ctx, canary, gatewaycdv1alpha1.HookTypePreRollout)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to run PreRollout hooks")
+		canary.Status.Message = fmt.Sprintf("Failed to run PreRollout hooks: %v", err)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+	}
+
+	if failure != "" {
+		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+		canary.Status.Message = fmt.Sprintf("%s, rolling back", failure)
+		canary.Status.RollbackReason = failure
+		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+	}
+
+	if !done {
+		canary.Status.Message = "Waiting for PreRollout hooks to complete"
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+	}
+
+	canary.Status.PreRolloutHooksCompleted = true
+	canary.Status.Message = "PreRollout hooks completed, shifting traffic"
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+}
+
+// runHooks runs the hooks of a type one Job at a time, in spec order. It
+// reports whether all succeeded or, once one failed, its failure. Job
+// transitions are recorded as events, which feed the rollout timeline.
+func (r *CanaryDeploymentReconciler) runHooks(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, hookType gatewaycdv1alpha1.HookType) (bool, string, error) {
+	for _, hook := range canary.Spec.Hooks {
+		if hook.Type != hookType {
+			continue
+		}
+
+		status := hookStatus(canary, hook.Name)
+		if status == nil {
+			if err := r.startHook(ctx, canary, hook); err != nil {
+				return false, "", err
+			}
+			return false, "", nil
+		}
+
+		switch status.Phase {
+		case gatewaycdv1alpha1.HookPhaseSucceeded:
+			continue
+		case gatewaycdv1alpha1.HookPhaseFailed:
+			return false, fmt.Sprintf("Hook %s failed: %s", hook.Name, status.Message), nil
+		}
+
+		job := &batchv1.Job{}
+		err := r.Get(ctx, types.NamespacedName{Name: status.JobName, Namespace: canary.Namespace}, job)
+		if apierrors.IsNotFound(err) {
+			r.failHook(canary, status, fmt.Sprintf("Job %s was deleted", status.JobName))
+			return false, fmt.Sprintf("Hook %s failed: %s", hook.Name, status.Message), nil
+		}
+		if err != nil {
+			return false, "", fmt.Errorf("failed to get Job %s: %w", status.JobName, err)
+		}
+
+		if jobCondition(job, batchv1.JobComplete) {
+			status.Phase = gatewaycdv1alpha1.HookPhaseSucceeded
+			status.CompletedTime = &metav1.Time{Time: time.Now()}
+			r.event(canary, EventReasonHookSucceeded, "Hook %s succeeded, Job %s completed", hook.Name, job.Name)
+			continue
+		}
+		if jobCondition(job, batchv1.JobFailed) {
+			r.failHook(canary, status, jobFailureMessage(job))
+			return false, fmt.Sprintf("Hook %s failed: %s", hook.Name, status.Message), nil
+		}
+		if timeout := hookTimeout(hook); status.StartedTime != nil && time.Since(status.StartedTime.Time) > timeout {
+			if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+				return false, "", fmt.Errorf("failed to delete timed out Job %s: %w", job.Name, err)
+			}
+			r.failHook(canary, status, fmt.Sprintf("Job %s did not complete within %s", job.Name, timeout))
+			return false, fmt.Sprintf("Hook %s failed: %s", hook.Name, status.Message), nil
+		}
+		return false, "", nil
+	}
+	return true, "", nil
+}
+
+// startHook creates the Job of a hook, owned by the canary, and records it
+func (r *CanaryDeploymentReconciler) startHook(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, hook gatewaycdv1alpha1.HookStep) error {
+	job := &batchv1.Job{
+		ObjectMeta: *hook.Template.ObjectMeta.DeepCopy(),
+		Spec:       *hook.Template.Spec.DeepCopy(),
+	}
+	job.Name = hookJobName(canary, hook)
+	job.Namespace = canary.Namespace
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+	job.Labels[labelCanary] = canary.Name
+	job.Labels[labelHook] = hook.Name
+	if job.Spec.Template.Spec.RestartPolicy == "" {
+		job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	}
+	if err := controllerutil.SetControllerReference(canary, job, r.Scheme); err != nil {
+		return err
+	}
+
+	if err := r.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create Job %s for hook %s: %w", job.Name, hook.Name, err)
+	}
+	canary.Status.Hooks = append(canary.Status.Hooks, gatewaycdv1alpha1.HookStatus{
+		Name:        hook.Name,
+		Type:        hook.Type,
+		JobName:     job.Name,
+		Phase:       gatewaycdv1alpha1.HookPhaseRunning,
+		StartedTime: &metav1.Time{Time: time.Now()},
+	})
+	r.event(canary, EventReasonHookStarted, "Started %s hook %s as Job %s", hook.Type, hook.Name, job.Name)
+	return nil
+}
+
+// failHook records a hook failure
+func (r *CanaryDeploymentReconciler) failHook(canary *gatewaycdv1alpha1.CanaryDeployment, status *gatewaycdv1alpha1.HookStatus, message string) {
+	status.Phase = gatewaycdv1alpha1.HookPhaseFailed
+	status.Message = message
+	status.CompletedTime = &metav1.Time{Time: time.Now()}
+	r.warning(canary, EventReasonHookFailed, "Hook %s failed: %s", status.Name, message)
+}
+
+// hookStatus returns the status of the named hook in the current rollout, or nil if it hasn't started
+func hookStatus(canary *gatewaycdv1alpha1.CanaryDeployment, name string) *gatewaycdv1alpha1.HookStatus {
+	for i := range canary.Status.Hooks {
+		if canary.Status.Hooks[i].Name == name {
+			return &canary.Status.Hooks[i]
+		}
+	}
+	return nil
+}
+
+// hookJobName names the Job of a hook after the canary, the hook and the
+// start of the rollout, so every rollout runs its hooks afresh
+func hookJobName(canary *gatewaycdv1alpha1.CanaryDeployment, hook gatewaycdv1alpha1.HookStep) string {
+	suffix := "0"
+	if canary.Status.StartedTime != nil {
+		suffix = strconv.FormatInt(canary.Status.StartedTime.Unix(), 36)
+	}
+	prefix := fmt.Sprintf("%s-%s", canary.Name, hook.Name)
+	if limit := 63 - len(suffix) - 1; len(prefix) > limit {
+		prefix = prefix[:limit]
+	}
+	return fmt.Sprintf("%s-%s", prefix, suffix)
+}
+
+// hookTimeout parses the hook timeout, falling back to the default for
+// values the webhook would have rejected
+func hookTimeout(hook gatewaycdv1alpha1.HookStep) time.Duration {
+	if d, err := time.ParseDuration(hook.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultHookTimeout
+}
+
+// jobCondition reports whether the Job has the condition with status True
+func jobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// jobFailureMessage explains why a Job failed
+func jobFailureMessage(job *batchv1.Job) string {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return fmt.Sprintf("Job %s failed: %s", job.Name, condition.Message)
+		}
+	}
+	return fmt.Sprintf("Job %s failed", job.Name)
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// analysisInterval is the time between analysis runs within a step, or zero
+// when analysis runs once per step
+func analysisInterval(canary *gatewaycdv1alpha1.CanaryDeployment) time.Duration {
+	interval, err := time.ParseDuration(canary.Spec.Analysis.AnalysisInterval)
+	if err != nil || interval <= 0 {
+		return 0
+	}
+	return interval
+}
+
+// stepDuration is how long the canary stays at a step's weight
+func stepDuration(step gatewaycdv1alpha1.TrafficSplitStep) time.Duration {
+	if step.Duration != "" {
+		if duration, err := time.ParseDuration(step.Duration); err == nil {
+			return duration
+		}
+	}
+	return time.Second * 30
+}
+
+// requiredIntervals is the number of passed analysis intervals a step needs
+func requiredIntervals(canary *gatewaycdv1alpha1.CanaryDeployment, step gatewaycdv1alpha1.TrafficSplitStep, interval time.Duration) int32 {
+	if canary.Spec.Analysis.SuccessfulIntervals > 0 {
+		return canary.Spec.Analysis.SuccessfulIntervals
+	}
+	if n := int32(stepDuration(step) / interval); n > 1 {
+		return n
+	}
+	return 1
+}
+
+// stepAnalysis returns the interval loop of the current step, starting a new
+// one when the step has just been entered
+func stepAnalysis(canary *gatewaycdv1alpha1.CanaryDeployment, step gatewaycdv1alpha1.TrafficSplitStep, interval time.Duration) *gatewaycdv1alpha1.StepAnalysisStatus {
+	loop := canary.Status.StepAnalysis
+	if loop == nil || loop.Step != canary.Status.CurrentStep {
+		loop = &gatewaycdv1alpha1.StepAnalysisStatus{
+			Step:              canary.Status.CurrentStep,
+			StartedTime:       &metav1.Time{Time: time.Now()},
+			RequiredIntervals: requiredIntervals(canary, step, interval),
+		}
+		canary.Status.StepAnalysis = loop
+	}
+	return loop
+}
+
+// nextIntervalIn is how long to wait until the next analysis interval is due
+func nextIntervalIn(loop *gatewaycdv1alpha1.StepAnalysisStatus, interval time.Duration) time.Duration {
+	last := loop.StartedTime
+	if loop.LastRunTime != nil {
+		last = loop.LastRunTime
+	}
+	return interval - time.Since(last.Time)
+}
+
+// recordInterval aggregates an analysis run into the interval loop of the current step
+func recordInterval(canary *gatewaycdv1alpha1.CanaryDeployment, passed bool) {
+	loop := canary.Status.StepAnalysis
+	if loop == nil || loop.Step != canary.Status.CurrentStep {
+		return
+	}
+
+	loop.LastRunTime = &metav1.Time{Time: time.Now()}
+	loop.Intervals++
+	if passed {
+		loop.SuccessfulIntervals++
+	}
+	if run := canary.Status.AnalysisRun; run != nil {
+		loop.SuccessRate += (run.SuccessRate - loop.SuccessRate) / float64(loop.Intervals)
+	}
+}
+
+// stepRemaining is how long the current step must keep running analysis
+// intervals before it may advance, or zero once it has passed enough of them
+// and run for its duration
+func stepRemaining(loop *gatewaycdv1alpha1.StepAnalysisStatus, step gatewaycdv1alpha1.TrafficSplitStep, interval time.Duration) time.Duration {
+	if loop.SuccessfulIntervals < loop.RequiredIntervals {
+		return interval
+	}
+	if remaining := stepDuration(step) - time.Since(loop.StartedTime.Time); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
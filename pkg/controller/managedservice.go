@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// reconcileManagedServices points the Service and its -canary Service at the
+// stable and canary ReplicaSets of the target Deployment by pod-template-hash,
+// creating them when missing. The canary Service is owned by the canary; the
+// stable Service serves the workload after the canary is gone and is never
+// owned. A stable revision without available pods is not selected by hash,
+// so the stable Service never loses its endpoints.
+func (r *CanaryDeploymentReconciler) reconcileManagedServices(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	if !canary.Spec.Service.Managed || canary.Spec.TargetRef.Kind != "Deployment" {
+		return nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return err
+	}
+	replicaSets, err := r.revisionReplicaSets(ctx, deployment)
+	if err != nil {
+		return err
+	}
+
+	stableHash := availableRevisionHash(replicaSets, canary.Status.StableRevision)
+	canaryHash := stableHash
+	if revision := canary.Status.CanaryRevision; revision != nil {
+		canaryHash = revision.PodTemplateHash
+	}
+
+	stable, err := r.reconcileManagedService(ctx, canary, deployment, canary.Spec.Service.Name, stableHash, nil, false)
+	if err != nil || canaryTornDown(canary) {
+		return err
+	}
+	_, err = r.reconcileManagedService(ctx, canary, deployment, canary.Spec.Service.Name+"-canary", canaryHash, stable.Spec.Ports, true)
+	return err
+}
+
+// reconcileManagedService sets the selector of a managed Service to the pods
+// of the Deployment with the given pod-template-hash, or all its pods without
+// one. A new Service gets ports, or the service port of the canary.
+func (r *CanaryDeploymentReconciler) reconcileManagedService(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment,
+	deployment *appsv1.Deployment, name, hash string, ports []corev1.ServicePort, owned bool) (*corev1.Service, error) {
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: canary.Namespace}}
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, func() error {
+		if service.Labels == nil {
+			service.Labels = make(map[string]string)
+		}
+		service.Labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+		service.Labels[labelCanary] = canary.Name
+		service.Spec.Selector = podTemplateSelector(deployment, hash)
+		if len(service.Spec.Ports) == 0 {
+			service.Spec.Ports = servicePorts(ports, canary.Spec.Service)
+		}
+		if owned {
+			return controllerutil.SetControllerReference(canary, service, r.Scheme)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile Service %s/%s: %w", service.Namespace, service.Name, err)
+	}
+
+	if op != controllerutil.OperationResultNone {
+		selected := fmt.Sprintf("pod-template-hash %s", hash)
+		if hash == "" {
+			selected = "all pods"
+		}
+		r.event(canary, EventReasonServiceSelectorUpdated, "Service %s selects %s of Deployment %s", service.Name, selected, deployment.Name)
+	}
+	return service, nil
+}
+
+// releaseManagedServices points the stable Service at all pods of the target
+// Deployment again and resumes the Deployment, so the workload keeps being
+// served and rolled out without the canary. The canary Service is garbage
+// collected with the canary.
+func (r *CanaryDeploymentReconciler) releaseManagedServices(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	if !canary.Spec.Service.Managed || canary.Spec.TargetRef.Kind != "Deployment" {
+		return nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return err
+	}
+
+	service := &corev1.Service{}
+	if err := r.Get(ctx, client.ObjectKey{Name: canary.Spec.Service.Name, Namespace: canary.Namespace}, service); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to get Service %s/%s: %w", canary.Namespace, canary.Spec.Service.Name, err)
+	} else if err == nil {
+		patch := client.MergeFrom(service.DeepCopy())
+		service.Spec.Selector = podTemplateSelector(deployment, "")
+		if err := r.Patch(ctx, service, patch); err != nil {
+			return fmt.Errorf("failed to restore Service %s/%s: %w", service.Namespace, service.Name, err)
+		}
+	}
+	return r.pauseTargetDeployment(ctx, canary, false)
+}
+
+// pauseTargetDeployment pauses or resumes the target Deployment of a canary
+// with managed Services. While paused, the Deployment controller keeps the
+// stable and canary ReplicaSets as they are, so both serve their Service.
+func (r *CanaryDeploymentReconciler) pauseTargetDeployment(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, paused bool) error {
+	if !canary.Spec.Service.Managed || canary.Spec.TargetRef.Kind != "Deployment" {
+		return nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return err
+	}
+	if deployment.Spec.Paused == paused {
+		return nil
+	}
+
+	patch := client.MergeFrom(deployment.DeepCopy())
+	deployment.Spec.Paused = paused
+	if err := r.Patch(ctx, deployment, patch); err != nil {
+		return fmt.Errorf("failed to set paused=%t on Deployment %s/%s: %w", paused, deployment.Namespace, deployment.Name, err)
+	}
+	return nil
+}
+
+// podTemplateSelector selects the pods of a Deployment, narrowed to one
+// ReplicaSet by its pod-template-hash when hash is set
+func podTemplateSelector(deployment *appsv1.Deployment, hash string) map[string]string {
+	selector := map[string]string{}
+	if deployment.Spec.Selector != nil {
+		for k, v := range deployment.Spec.Selector.MatchLabels {
+			selector[k] = v
+		}
+	}
+	if hash != "" {
+		selector[labelPodTemplateHash] = hash
+	}
+	return selector
+}
+
+// availableRevisionHash is the pod-template-hash of revision when one of the
+// ReplicaSets runs it with available pods
+func availableRevisionHash(replicaSets []appsv1.ReplicaSet, revision *gatewaycdv1alpha1.WorkloadRevision) string {
+	if revision == nil {
+		return ""
+	}
+	for _, rs := range replicaSets {
+		if rs.Labels[labelPodTemplateHash] == revision.PodTemplateHash && rs.Status.AvailableReplicas > 0 {
+			return revision.PodTemplateHash
+		}
+	}
+	return ""
+}
+
+// servicePorts copies the ports of another Service without their node ports,
+// or exposes the port and named ports of service when there are none to copy
+func servicePorts(ports []corev1.ServicePort, service gatewaycdv1alpha1.ServiceRef) []corev1.ServicePort {
+	if len(ports) == 0 {
+		exposed := []corev1.ServicePort{{Name: "http", Port: service.Port, TargetPort: intstr.FromInt(int(service.Port))}}
+		for _, named := range service.Ports {
+			if named.Port != service.Port {
+				exposed = append(exposed, corev1.ServicePort{Name: named.Name, Port: named.Port, TargetPort: intstr.FromInt(int(named.Port))})
+			}
+		}
+		return exposed
+	}
+	copied := make([]corev1.ServicePort, len(ports))
+	for i, p := range ports {
+		copied[i] = corev1.ServicePort{
+			Name:        p.Name,
+			Protocol:    p.Protocol,
+			AppProtocol: p.AppProtocol,
+			Port:        p.Port,
+			TargetPort:  p.TargetPort,
+		}
+	}
+	return copied
+}
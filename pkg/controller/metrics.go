@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// phases are the canary phases exported by the phase gauge
+var phases = []gatewaycdv1alpha1.CanaryDeploymentPhase{
+	gatewaycdv1alpha1.CanaryDeploymentPhasePending,
+	gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing,
+	gatewaycdv1alpha1.CanaryDeploymentPhasePaused,
+	gatewaycdv1alpha1.CanaryDeploymentPhaseSucceeded,
+	gatewaycdv1alpha1.CanaryDeploymentPhaseFailed,
+	gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack,
+}
+
+var (
+	canaryRollbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gatewaycd_canary_rollbacks_total",
+		Help: "Number of completed canary rollbacks.",
+	}, []string{"namespace", "canary"})
+
+	canaryPhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gatewaycd_canary_phase",
+		Help: "Current phase of each canary, 1 for the active phase and 0 otherwise. Sum by phase to count canaries per phase.",
+	}, []string{"namespace", "canary", "phase"})
+
+	canaryWeight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gatewaycd_canary_weight",
+		Help: "Percentage of traffic currently routed to the canary.",
+	}, []string{"namespace", "canary"})
+
+	canaryAnalysisRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gatewaycd_canary_analysis_runs_total",
+		Help: "Number of canary analysis runs by result (passed, failed or error).",
+	}, []string{"namespace", "canary", "result"})
+
+	canaryAnalysisSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gatewaycd_canary_analysis_skipped_total",
+		Help: "Number of steps or pre-ramp phases that advanced without analysis by reason.",
+	}, []string{"namespace", "canary", "reason"})
+
+	rolloutDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gatewaycd_canary_rollout_duration_seconds",
+		Help:    "Duration of finished canary rollouts by result (succeeded or failed).",
+		Buckets: []float64{60, 300, 600, 1800, 3600, 7200, 14400, 43200, 86400},
+	}, []string{"namespace", "result"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(canaryRollbacks, canaryPhase, canaryWeight, canaryAnalysisRuns, canaryAnalysisSkipped, rolloutDuration)
+}
+
+// recordStatusMetrics exports the phase and traffic weight of a canary
+func recordStatusMetrics(canary *gatewaycdv1alpha1.CanaryDeployment) {
+	for _, phase := range phases {
+		value := 0.0
+		if canary.Status.Phase == phase {
+			value = 1
+		}
+		canaryPhase.WithLabelValues(canary.Namespace, canary.Name, string(phase)).Set(value)
+	}
+	canaryWeight.WithLabelValues(canary.Namespace, canary.Name).Set(float64(canary.Status.CanaryWeight))
+}
+
+// recordAnalysisRun counts an analysis run by result
+func recordAnalysisRun(canary *gatewaycdv1alpha1.CanaryDeployment, result string) {
+	canaryAnalysisRuns.WithLabelValues(canary.Namespace, canary.Name, result).Inc()
+}
+
+// recordAnalysisSkipped counts a step or phase that advanced without analysis
+func recordAnalysisSkipped(canary *gatewaycdv1alpha1.CanaryDeployment, reason string) {
+	canaryAnalysisSkipped.WithLabelValues(canary.Namespace, canary.Name, reason).Inc()
+}
+
+// recordRolloutFinished observes the duration of a rollout that reached a terminal phase
+func recordRolloutFinished(canary *gatewaycdv1alpha1.CanaryDeployment, result string) {
+	if canary.Status.StartedTime == nil {
+		return
+	}
+	rolloutDuration.WithLabelValues(canary.Namespace, result).Observe(time.Since(canary.Status.StartedTime.Time).Seconds())
+}
+
+// forgetCanaryMetrics drops the series of a deleted canary
+func forgetCanaryMetrics(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "canary": name}
+	canaryPhase.DeletePartialMatch(labels)
+	canaryWeight.DeletePartialMatch(labels)
+	canaryAnalysisRuns.DeletePartialMatch(labels)
+	canaryAnalysisSkipped.DeletePartialMatch(labels)
+	canaryRollbacks.DeletePartialMatch(labels)
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/metrics"
+)
+
+// defaultMirrorDuration is how long traffic is mirrored when the canary doesn't set it
+const defaultMirrorDuration = time.Minute * 5
+
+// handleMirroring mirrors production traffic to the canary and analyses the
+// canary on it before the first traffic split step shifts real traffic
+func (r *CanaryDeploymentReconciler) handleMirroring(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	duration := mirrorDuration(canary)
+
+	// Start mirroring
+	if canary.Status.MirrorStartedTime == nil {
+		if err := r.GatewayManager.MirrorTraffic(ctx, canary); err != nil {
+			return r.retryFailure(ctx, canary, EventReasonTrafficUpdateFailed, "Failed to mirror traffic", err)
+		}
+
+		canary.Status.RetryCount = 0
+		canary.Status.MirrorStartedTime = &metav1.Time{Time: time.Now()}
+		canary.Status.CanaryWeight = 0
+		canary.Status.StableWeight = 100
+		canary.Status.Message = fmt.Sprintf("Mirroring production traffic to canary for %s", duration)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.event(canary, EventReasonMirroring, "%s", canary.Status.Message)
+		return ctrl.Result{RequeueAfter: duration}, nil
+	}
+
+	// Keep mirroring until the duration has elapsed
+	if remaining := duration - time.Since(canary.Status.MirrorStartedTime.Time); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	// Analyse the canary on mirrored traffic
+	if analysisEnabled(canary) {
+		passed, err := r.runAnalysis(ctx, canary)
+		if errors.Is(err, metrics.ErrProviderUnavailable) {
+			return r.handleMirrorProviderUnavailable(ctx, canary)
+		}
+		if err != nil {
+			log.Error(err, "Mirrored analysis failed")
+			if analysisErrorLimitReached(canary) {
+				return r.rollbackMirror(ctx, canary, fmt.Sprintf("Mirrored analysis could not be completed %d times in a row",
+					canary.Status.ConsecutiveErrors))
+			}
+			canary.Status.Message = fmt.Sprintf("Mirrored analysis failed: %v", err)
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.warning(canary, EventReasonAnalysisError, "Mirrored analysis could not be completed: %v", err)
+			return ctrl.Result{RequeueAfter: r.analysisErrorBackoff(canary)}, nil
+		}
+
+		setAnalysisCondition(canary, passed, "MirroredAnalysis", fmt.Sprintf("Analysis on mirrored traffic: %s", analysisOutcome(passed)))
+		if !passed && !analysisFailureLimitReached(canary) {
+			canary.Status.Message = fmt.Sprintf("Mirrored analysis failed (%d of %d consecutive failures), retrying",
+				canary.Status.ConsecutiveFailures, analysisFailureLimit(canary))
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.warning(canary, EventReasonAnalysisRetrying, "%s: %s", canary.Status.Message, failingMetricsSummary(canary))
+			return ctrl.Result{RequeueAfter: analysisRetryInterval(canary)}, nil
+		}
+		if !passed {
+			return r.rollbackMirror(ctx, canary, "Mirrored analysis failed")
+		}
+	} else {
+		markAnalysisSkipped(ctx, canary, analysisSkipReason(canary), "Mirroring completed without analysis")
+	}
+
+	return r.completeMirror(ctx, canary)
+}
+
+// handleMirrorProviderUnavailable applies the provider unavailable policy while
+// mirroring. Pause is treated as Retry since no traffic has shifted yet.
+func (r *CanaryDeploymentReconciler) handleMirrorProviderUnavailable(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	r.warning(canary, EventReasonProviderUnavailable, "Metrics provider unavailable while mirroring, applying %q policy",
+		canary.Spec.Analysis.ProviderUnavailablePolicy)
+
+	switch canary.Spec.Analysis.ProviderUnavailablePolicy {
+	case gatewaycdv1alpha1.ProviderUnavailablePolicySkip:
+		markAnalysisSkipped(ctx, canary, "ProviderUnavailable", "Mirroring completed without analysis, metrics provider unavailable")
+		return r.completeMirror(ctx, canary)
+	case gatewaycdv1alpha1.ProviderUnavailablePolicyRollback:
+		return r.rollbackMirror(ctx, canary, "Metrics provider unavailable")
+	default:
+		canary.Status.Message = "Metrics provider unavailable, retrying mirrored analysis"
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+}
+
+// completeMirror lets the rollout continue with the first traffic split step,
+// which also removes the mirror filter
+func (r *CanaryDeploymentReconciler) completeMirror(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	canary.Status.MirrorCompleted = true
+	canary.Status.Message = "Mirrored analysis passed, shifting traffic"
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.event(canary, EventReasonMirrorCompleted, "Mirrored analysis passed, starting traffic split")
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+}
+
+// rollbackMirror rolls the canary back before it served any real traffic
+func (r *CanaryDeploymentReconciler) rollbackMirror(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, reason string) (ctrl.Result, error) {
+	log.FromContext(ctx).Info("Rolling back canary after mirroring", "reason", reason)
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+	canary.Status.Message = fmt.Sprintf("%s, rolling back", reason)
+	canary.Status.RollbackReason = reason
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.warning(canary, EventReasonAnalysisFailed, "%s: %s", reason, failingMetricsSummary(canary))
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+}
+
+// mirrorDuration is how long traffic is mirrored before the first step
+func mirrorDuration(canary *gatewaycdv1alpha1.CanaryDeployment) time.Duration {
+	if canary.Spec.MirrorDuration != "" {
+		if d, err := time.ParseDuration(canary.Spec.MirrorDuration); err == nil {
+			return d
+		}
+	}
+	return defaultMirrorDuration
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// labelCanary marks NetworkPolicies generated for a canary with its name
+	labelCanary = "gateway-cd.io/canary"
+	// labelSourcePolicy names the stable NetworkPolicy a canary policy was cloned from
+	labelSourcePolicy = "gateway-cd.io/source-policy"
+)
+
+// reconcileNetworkPolicies clones the NetworkPolicies that select the stable
+// pods but not the canary pods, retargeted at the canary pods, and removes
+// clones whose source no longer applies. Pods are identified by the selectors
+// of the stable and canary Services.
+func (r *CanaryDeploymentReconciler) reconcileNetworkPolicies(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	if !canary.Spec.CloneNetworkPolicies || canaryTornDown(canary) {
+		return nil
+	}
+
+	stablePods, err := r.serviceSelector(ctx, canary.Namespace, canary.Spec.Service.Name)
+	if err != nil {
+		return err
+	}
+	canaryPods, err := r.serviceSelector(ctx, canary.Namespace, canary.Spec.Service.Name+"-canary")
+	if err != nil {
+		return err
+	}
+
+	var policies networkingv1.NetworkPolicyList
+	if err := r.List(ctx, &policies, client.InNamespace(canary.Namespace)); err != nil {
+		return fmt.Errorf("failed to list NetworkPolicies: %w", err)
+	}
+
+	wanted := map[string]bool{}
+	for i := range policies.Items {
+		source := &policies.Items[i]
+		if _, generated := source.Labels[labelCanary]; generated {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&source.Spec.PodSelector)
+		if err != nil || !selector.Matches(stablePods) || selector.Matches(canaryPods) {
+			continue
+		}
+
+		clone := &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%s", canary.Name, source.Name),
+				Namespace: canary.Namespace,
+			},
+		}
+		wanted[clone.Name] = true
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, clone, func() error {
+			if clone.Labels == nil {
+				clone.Labels = make(map[string]string)
+			}
+			clone.Labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+			clone.Labels[labelCanary] = canary.Name
+			clone.Labels[labelSourcePolicy] = source.Name
+
+			clone.Spec = *source.Spec.DeepCopy()
+			clone.Spec.PodSelector = metav1.LabelSelector{MatchLabels: canaryPods}
+			return controllerutil.SetControllerReference(canary, clone, r.Scheme)
+		}); err != nil {
+			return fmt.Errorf("failed to reconcile NetworkPolicy %s/%s: %w", clone.Namespace, clone.Name, err)
+		}
+	}
+
+	// Remove clones of policies that were deleted or no longer select the stable pods
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if policy.Labels[labelCanary] != canary.Name || wanted[policy.Name] {
+			continue
+		}
+		if err := r.Delete(ctx, policy); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete NetworkPolicy %s/%s: %w", policy.Namespace, policy.Name, err)
+		}
+	}
+	return nil
+}
+
+// serviceSelector returns the pod labels a Service selects
+func (r *CanaryDeploymentReconciler) serviceSelector(ctx context.Context, namespace, name string) (labels.Set, error) {
+	var service corev1.Service
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &service); err != nil {
+		return nil, fmt.Errorf("failed to get Service %s/%s: %w", namespace, name, err)
+	}
+	if len(service.Spec.Selector) == 0 {
+		return nil, fmt.Errorf("service %s/%s has no pod selector", namespace, name)
+	}
+	return labels.Set(service.Spec.Selector), nil
+}
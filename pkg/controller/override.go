@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// annotationWeight holds the canary weight an operator requested outside the steps
+const annotationWeight = "gateway-cd.io/weight"
+
+// overrideWeight sends the weight requested through the weight annotation to
+// the canary and holds the rollout there with a ManualOverride condition. The
+// resume annotation continues the plan by running the current step again.
+func (r *CanaryDeploymentReconciler) overrideWeight(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	by := "user"
+	if requestedBy := canary.Annotations[annotationRequestedBy]; requestedBy != "" {
+		by = requestedBy
+	}
+	action := newControlAction(canary, gatewaycdv1alpha1.ControlActionWeightOverride)
+
+	value := canary.Annotations[annotationWeight]
+	action.Value = value
+	weight, err := strconv.Atoi(value)
+	if err != nil || weight < 0 || weight > 100 {
+		r.auditControlAction(canary, action, "Ignored, the weight must be between 0 and 100")
+		if err := r.removeAnnotations(ctx, canary, annotationWeight, annotationRequestedBy, annotationRequestedVia, annotationAdmittedBy); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonWeightOverrideInvalid, "Ignored weight override %q by %s, the weight must be between 0 and 100", value, by)
+		return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+	}
+
+	log.FromContext(ctx).Info("Overriding canary weight on user request", "weight", weight)
+	if err := r.GatewayManager.UpdateTrafficSplit(ctx, canary, weight); err != nil {
+		return r.retryFailure(ctx, canary, EventReasonTrafficUpdateFailed, "Failed to override traffic split", err)
+	}
+	canary.Status.RetryCount = 0
+
+	previous := canary.Status.CanaryWeight
+	canary.Status.CanaryWeight = int32(weight)
+	canary.Status.StableWeight = int32(100 - weight)
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhasePaused
+	canary.Status.Message = fmt.Sprintf("Weight overridden by %s at step %d: %d%% canary, %d%% stable",
+		by, canary.Status.CurrentStep+1, weight, 100-weight)
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeManualOverride, metav1.ConditionTrue, "WeightOverridden", canary.Status.Message)
+	if meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypePausedByUser) {
+		// The override supersedes the pause, a single resume continues the plan
+		setCondition(canary, gatewaycdv1alpha1.ConditionTypePausedByUser, metav1.ConditionFalse, "WeightOverridden", "Pause superseded by a weight override")
+	}
+	r.auditControlAction(canary, action, canary.Status.Message)
+
+	if err := r.removeAnnotations(ctx, canary, annotationWeight, "gateway-cd.io/pause", annotationRequestedBy, annotationRequestedVia, annotationAdmittedBy); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.event(canary, EventReasonWeightOverridden, "Canary weight overridden from %d%% to %d%% at step %d by %s",
+		previous, weight, canary.Status.CurrentStep+1, by)
+	return ctrl.Result{}, nil
+}
+
+// pausedManually reports whether the rollout is paused by an operator rather
+// than by a step waiting for approval
+func pausedManually(canary *gatewaycdv1alpha1.CanaryDeployment) bool {
+	return meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypePausedByUser) ||
+		meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeManualOverride)
+}
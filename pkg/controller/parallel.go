@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/gateway"
+)
+
+// checkParallelRollouts records the Blocked condition and reports whether
+// the rollout must wait because another CanaryDeployment is rolling out on
+// one of its routes, so two canaries never fight over the same weights
+func (r *CanaryDeploymentReconciler) checkParallelRollouts(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (bool, error) {
+	var blocking *gatewaycdv1alpha1.CanaryDeployment
+	var shared []string
+	for _, route := range gateway.ManagedRoutes(canary) {
+		var canaries gatewaycdv1alpha1.CanaryDeploymentList
+		if err := r.List(ctx, &canaries, client.MatchingFields{routeIndex: route}); err != nil {
+			return false, fmt.Errorf("failed to list canaries of %s: %w", route, err)
+		}
+		for i := range canaries.Items {
+			other := &canaries.Items[i]
+			if other.UID == canary.UID || !rolloutActive(other) {
+				continue
+			}
+			if blocking == nil {
+				blocking = other
+			}
+			if other.UID == blocking.UID {
+				shared = append(shared, route)
+			}
+		}
+	}
+
+	if blocking == nil {
+		meta.RemoveStatusCondition(&canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeBlocked)
+		canary.Status.BlockedBy = ""
+		return false, nil
+	}
+
+	blockedBy := client.ObjectKeyFromObject(blocking).String()
+	message := fmt.Sprintf("CanaryDeployment %s is rolling out on %s", blockedBy, strings.Join(shared, ", "))
+	if !meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeBlocked) || canary.Status.BlockedBy != blockedBy {
+		r.warning(canary, EventReasonBlocked, "Waiting, %s", message)
+	}
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeBlocked, metav1.ConditionTrue, "RouteInUse", message)
+	canary.Status.BlockedBy = blockedBy
+	canary.Status.Message = fmt.Sprintf("Waiting, %s", message)
+	return true, nil
+}
+
+// rolloutActive reports whether a canary currently holds the weights of its routes
+func rolloutActive(canary *gatewaycdv1alpha1.CanaryDeployment) bool {
+	switch canary.Status.Phase {
+	case gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing,
+		gatewaycdv1alpha1.CanaryDeploymentPhasePaused,
+		gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/gateway"
+	"gateway-cd/pkg/metrics"
+)
+
+// RolloutPlan is the simulated course of a rollout
+type RolloutPlan struct {
+	Namespace string        `json:"namespace"`
+	Name      string        `json:"name"`
+	Steps     []PlannedStep `json:"steps"`
+	// EstimatedDuration is the time from start to promotion when every
+	// analysis passes, excluding approvals and hooks
+	EstimatedDuration string `json:"estimatedDuration"`
+	// Notes lists what the simulation cannot predict
+	Notes []string `json:"notes,omitempty"`
+}
+
+// PlannedStep is a single stage of a simulated rollout
+type PlannedStep struct {
+	// Step is the 1-based traffic split step, or 0 for mirroring
+	Step        int    `json:"step"`
+	Description string `json:"description"`
+	// StartsAfter is the time from the start of the rollout to the step
+	StartsAfter string `json:"startsAfter"`
+	// Duration is how long the rollout stays at the step
+	Duration string `json:"duration"`
+	// Replicas is the canary replica count spec.canaryScale sets for the step
+	Replicas int32 `json:"replicas,omitempty"`
+	// Approval reports whether the step pauses for manual approval
+	Approval bool `json:"approval,omitempty"`
+	// RouteChanges are the routes the step writes
+	RouteChanges []gateway.RouteChange `json:"routeChanges"`
+	// Analysis are the queries the step runs before it advances
+	Analysis []PlannedQuery `json:"analysis,omitempty"`
+}
+
+// PlannedQuery is an analysis query a step would run
+type PlannedQuery struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	Query    string `json:"query"`
+	// Condition is what the result must satisfy for analysis to pass
+	Condition string `json:"condition"`
+	// Runs is how many times the step runs the query
+	Runs int32 `json:"runs"`
+}
+
+// Plan simulates the rollout of a canary without writing to the cluster. It
+// resolves the canary's templates and reads its routes to compute the
+// changes every step would make.
+func Plan(ctx context.Context, c client.Client, canary *gatewaycdv1alpha1.CanaryDeployment) (*RolloutPlan, error) {
+	canary, err := ResolveTemplates(ctx, c, canary)
+	if err != nil {
+		return nil, err
+	}
+	r := &CanaryDeploymentReconciler{Client: c, GatewayManager: gateway.NewManager(c)}
+	return r.plan(ctx, canary)
+}
+
+// ResolveTemplates returns a copy of the canary with its canary and analysis
+// templates merged into the spec, as the controller rolls it out
+func ResolveTemplates(ctx context.Context, c client.Client, canary *gatewaycdv1alpha1.CanaryDeployment) (*gatewaycdv1alpha1.CanaryDeployment, error) {
+	r := &CanaryDeploymentReconciler{Client: c}
+	canary = canary.DeepCopy()
+	if err := r.resolveCanaryTemplate(ctx, canary); err != nil {
+		return nil, err
+	}
+	if err := r.resolveAnalysisTemplate(ctx, canary); err != nil {
+		return nil, err
+	}
+	return canary, nil
+}
+
+// plan simulates the rollout of a canary whose templates are resolved
+func (r *CanaryDeploymentReconciler) plan(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (*RolloutPlan, error) {
+	if len(canary.Spec.TrafficSplit) == 0 {
+		return nil, fmt.Errorf("the canary has no traffic split steps")
+	}
+	plan := &RolloutPlan{Namespace: canary.Namespace, Name: canary.Name}
+	if hasHooks(canary, gatewaycdv1alpha1.HookTypePreRollout) {
+		plan.Notes = append(plan.Notes, "PreRollout hooks run before any traffic shifts and are not included in the estimate")
+	}
+	if canary.Spec.PreRolloutCheck != nil {
+		plan.Notes = append(plan.Notes, "The pre-rollout check runs before any traffic shifts and is not included in the estimate")
+	}
+
+	var elapsed time.Duration
+	if canary.Spec.Mirror {
+		changes, err := r.GatewayManager.PlanMirrorTraffic(ctx, canary)
+		if err != nil {
+			return nil, err
+		}
+		duration := mirrorDuration(canary)
+		plan.Steps = append(plan.Steps, PlannedStep{
+			Description:  fmt.Sprintf("Mirror production traffic to the canary for %s", duration),
+			StartsAfter:  elapsed.String(),
+			Duration:     duration.String(),
+			RouteChanges: changes,
+			Analysis:     plannedQueries(canary, 1),
+		})
+		elapsed += duration
+	}
+	if canary.Spec.TimeSlice != nil {
+		plan.Notes = append(plan.Notes, "Time-sliced exposure runs before step 1 and is not simulated")
+	}
+
+	interval := analysisInterval(canary)
+	approvals := 0
+	for i, step := range canary.Spec.TrafficSplit {
+		changes, err := r.GatewayManager.PlanTrafficSplitForStep(ctx, canary, i)
+		if err != nil {
+			return nil, err
+		}
+		hold := stepDuration(step)
+		runs := int32(1)
+		if interval > 0 {
+			runs = requiredIntervals(canary, step, interval)
+			if d := time.Duration(runs) * interval; d > hold {
+				hold = d
+			}
+		}
+		if step.Pause {
+			approvals++
+		}
+		var replicas int32
+		if canary.Spec.CanaryScale != nil {
+			if replicas, err = r.canaryReplicas(ctx, canary, i); err != nil {
+				return nil, err
+			}
+		}
+		plan.Steps = append(plan.Steps, PlannedStep{
+			Step:         i + 1,
+			Description:  stepDescription(step),
+			StartsAfter:  elapsed.String(),
+			Duration:     hold.String(),
+			Replicas:     replicas,
+			Approval:     step.Pause,
+			RouteChanges: changes,
+			Analysis:     plannedQueries(canary, runs),
+		})
+		elapsed += hold
+	}
+	if approvals > 0 {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("%d step(s) wait for approval, which is not included in the estimate", approvals))
+	}
+	plan.EstimatedDuration = elapsed.String()
+	return plan, nil
+}
+
+// Summary describes the plan in a single line
+func (p *RolloutPlan) Summary() string {
+	var weights []string
+	queries, approvals := 0, 0
+	for _, step := range p.Steps {
+		if step.Step == 0 {
+			weights = append(weights, "mirror")
+		} else if len(step.RouteChanges) > 0 {
+			weights = append(weights, fmt.Sprintf("%d%%", step.RouteChanges[0].Weight))
+		}
+		queries += len(step.Analysis)
+		if step.Approval {
+			approvals++
+		}
+	}
+	return fmt.Sprintf("%d stage(s) (%s) over %s, %d analysis queries, %d approval(s)",
+		len(p.Steps), strings.Join(weights, " -> "), p.EstimatedDuration, queries, approvals)
+}
+
+// handleDryRun records the simulated rollout of a Pending canary on its
+// status instead of starting it. The plan is refreshed whenever the canary
+// changes; clearing spec.dryRun starts the rollout.
+func (r *CanaryDeploymentReconciler) handleDryRun(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	plan, err := r.plan(ctx, canary)
+	if err != nil {
+		setCondition(canary, gatewaycdv1alpha1.ConditionTypeDryRun, metav1.ConditionFalse, "PlanFailed", err.Error())
+		canary.Status.Message = fmt.Sprintf("Dry run failed: %v", err)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonDryRunFailed, "Dry run failed: %v", err)
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+
+	summary := plan.Summary()
+	condition := meta.FindStatusCondition(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeDryRun)
+	changed := condition == nil || condition.Status != metav1.ConditionTrue || condition.Message != summary
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeDryRun, metav1.ConditionTrue, "Planned", summary)
+	canary.Status.Message = fmt.Sprintf("Dry run planned %s", summary)
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	if changed {
+		r.event(canary, EventReasonDryRun, "Dry run planned %s", summary)
+	}
+	return ctrl.Result{}, nil
+}
+
+// stepDescription describes the traffic a step sends to the canary
+func stepDescription(step gatewaycdv1alpha1.TrafficSplitStep) string {
+	description := fmt.Sprintf("Route %d%% of traffic to the canary", step.Weight)
+	if step.FractionalWeight != "" {
+		description = fmt.Sprintf("Route %s%% of traffic to the canary by hash bucket", step.FractionalWeight)
+	}
+	if tenants := len(step.Tenants) + len(step.TenantPatterns); tenants > 0 {
+		description = fmt.Sprintf("%s, plus %d tenant selector(s)", description, tenants)
+	}
+	if step.Pause {
+		description += ", then pause for approval"
+	}
+	return description
+}
+
+// plannedQueries lists the analysis queries a stage runs, or none when
+// analysis is disabled. With a baseline, the canary queries are compared
+// against the same queries on the baseline.
+func plannedQueries(canary *gatewaycdv1alpha1.CanaryDeployment, runs int32) []PlannedQuery {
+	if !analysisEnabled(canary) {
+		return nil
+	}
+	analysis := canary.Spec.Analysis
+	provider := "default"
+	if analysis.Provider != nil {
+		provider = string(analysis.Provider.Type)
+	}
+	canaryService := canary.Spec.Service.Name + "-canary"
+	baseline := canary.Spec.Baseline
+
+	var queries []PlannedQuery
+	for _, metric := range analysis.Metrics {
+		condition := fmt.Sprintf("%s %g", metric.Operator, metric.Threshold)
+		if baseline != nil {
+			condition = fmt.Sprintf("%s baseline within %g%%", metric.Operator, metrics.BaselineTolerance(baseline)*100)
+		}
+		queries = append(queries, PlannedQuery{
+			Name:      metric.Name,
+			Provider:  provider,
+			Query:     metrics.RenderMetricQuery(canary, metric),
+			Condition: condition,
+			Runs:      runs,
+		})
+	}
+	if analysis.SuccessRate > 0 || baseline != nil {
+		condition := fmt.Sprintf(">= %g", analysis.SuccessRate)
+		if baseline != nil {
+			condition = fmt.Sprintf(">= baseline - %g", metrics.SuccessRateTolerance(baseline))
+		}
+		queries = append(queries, PlannedQuery{
+			Name:      "success-rate",
+			Provider:  provider,
+			Query:     strings.TrimSpace(metrics.SuccessRateQuery(analysis.MetricsProfile, canary.Namespace, canaryService)),
+			Condition: condition,
+			Runs:      runs,
+		})
+	}
+	if analysis.MaxLatency > 0 || baseline != nil {
+		condition := fmt.Sprintf("<= %dms", analysis.MaxLatency)
+		if baseline != nil {
+			condition = fmt.Sprintf("<= baseline + %g%%", metrics.BaselineTolerance(baseline)*100)
+		}
+		queries = append(queries, PlannedQuery{
+			Name:      "latency",
+			Provider:  provider,
+			Query:     strings.TrimSpace(metrics.LatencyQuery(analysis.MetricsProfile, canary.Namespace, canaryService)),
+			Condition: condition,
+			Runs:      runs,
+		})
+	}
+	if traces := analysis.Traces; traces != nil {
+		var conditions []string
+		if traces.MaxErrorRate > 0 {
+			conditions = append(conditions, fmt.Sprintf("error rate <= %g", traces.MaxErrorRate))
+		}
+		if traces.MaxP95DurationMs > 0 {
+			conditions = append(conditions, fmt.Sprintf("p95 <= %dms", traces.MaxP95DurationMs))
+		}
+		queries = append(queries, PlannedQuery{
+			Name:      "traces",
+			Provider:  provider,
+			Query:     metrics.TraceQuery(canary),
+			Condition: strings.Join(conditions, ", "),
+			Runs:      runs,
+		})
+	}
+	return queries
+}
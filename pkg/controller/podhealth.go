@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// defaultMaxRestarts is the number of canary container restarts that
+	// rolls the canary back
+	defaultMaxRestarts = 3
+	// defaultNotReadyTimeout is how long canary containers may stay not ready
+	defaultNotReadyTimeout = 5 * time.Minute
+)
+
+// handlePodHealth rolls the canary back when its pods are failing, and
+// reports whether it did
+func (r *CanaryDeploymentReconciler) handlePodHealth(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, bool, error) {
+	failure, err := r.checkPodHealth(ctx, canary)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to check canary pod health")
+		return ctrl.Result{}, false, nil
+	}
+	if failure == "" {
+		return ctrl.Result{}, false, nil
+	}
+
+	reason := fmt.Sprintf("Canary pods are failing: %s", failure)
+	setAnalysisCondition(canary, false, "PodHealthFailed", reason)
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+	canary.Status.Message = fmt.Sprintf("%s, rolling back", reason)
+	canary.Status.RollbackReason = reason
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, true, err
+	}
+	r.warning(canary, EventReasonPodHealthFailed, "%s", reason)
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, true, nil
+}
+
+// checkPodHealth records the restarts of the canary pods in status.podHealth
+// and returns why the pods are failing, or "" while they are healthy
+func (r *CanaryDeploymentReconciler) checkPodHealth(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (string, error) {
+	check := canary.Spec.Analysis.PodHealth
+	if check == nil || canary.Spec.TargetRef.Kind != "Deployment" || r.APIReader == nil {
+		return "", nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return "", err
+	}
+	pods, err := r.revisionPods(ctx, deployment)
+	if err != nil {
+		return "", err
+	}
+
+	var since time.Time
+	if canary.Status.StartedTime != nil {
+		since = canary.Status.StartedTime.Time
+	}
+	var restarts int32
+	failure := ""
+	for i := range pods {
+		pod := &pods[i]
+		for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+			restarts += status.RestartCount
+			if failure != "" {
+				continue
+			}
+			if waiting := status.State.Waiting; waiting != nil && waiting.Reason == "CrashLoopBackOff" {
+				failure = fmt.Sprintf("container %s of pod %s is in CrashLoopBackOff", status.Name, pod.Name)
+			} else if !check.AllowOOMKills && oomKilledSince(status, since) {
+				failure = fmt.Sprintf("container %s of pod %s was OOMKilled", status.Name, pod.Name)
+			}
+		}
+		if failure == "" {
+			if notReady := notReadyFor(pod); notReady > notReadyTimeout(check) {
+				failure = fmt.Sprintf("pod %s has had containers not ready for %s", pod.Name, notReady.Round(time.Second))
+			}
+		}
+	}
+
+	// Restarts before the first check, or of pods since replaced, don't count
+	status := canary.Status.PodHealth
+	if status == nil || restarts < status.BaselineRestarts {
+		status = &gatewaycdv1alpha1.PodHealthStatus{BaselineRestarts: restarts}
+		canary.Status.PodHealth = status
+	}
+	status.Restarts = restarts - status.BaselineRestarts
+	status.Pods = int32(len(pods))
+	status.CheckedTime = &metav1.Time{Time: time.Now()}
+
+	if failure == "" && status.Restarts >= maxRestarts(check) {
+		failure = fmt.Sprintf("%d container restarts during the rollout", status.Restarts)
+	}
+	return failure, nil
+}
+
+// oomKilledSince reports whether the container was OOMKilled after since
+func oomKilledSince(status corev1.ContainerStatus, since time.Time) bool {
+	for _, terminated := range []*corev1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+		if terminated != nil && terminated.Reason == "OOMKilled" && !terminated.FinishedAt.Time.Before(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// notReadyFor is how long a pod has had containers that are not ready
+func notReadyFor(pod *corev1.Pod) time.Duration {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.ContainersReady && condition.Status != corev1.ConditionTrue {
+			return time.Since(condition.LastTransitionTime.Time)
+		}
+	}
+	return 0
+}
+
+// maxRestarts is the number of canary container restarts that rolls the canary back
+func maxRestarts(check *gatewaycdv1alpha1.PodHealthCheck) int32 {
+	if check.MaxRestarts > 0 {
+		return check.MaxRestarts
+	}
+	return defaultMaxRestarts
+}
+
+// notReadyTimeout is how long canary containers may stay not ready
+func notReadyTimeout(check *gatewaycdv1alpha1.PodHealthCheck) time.Duration {
+	if timeout, err := time.ParseDuration(check.NotReadyTimeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return defaultNotReadyTimeout
+}
+
+// watchPodHealth checks the pods of progressing canaries every poll interval
+// and queues the canaries whose pods are failing, so they roll back without
+// waiting for the next step or analysis run
+func (r *CanaryDeploymentReconciler) watchPodHealth(ctx context.Context, queue chan<- event.GenericEvent) error {
+	log := log.FromContext(ctx).WithName("podhealth")
+	ticker := time.NewTicker(orDefault(r.Requeue.Poll, DefaultRequeueIntervals.Poll))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		var canaries gatewaycdv1alpha1.CanaryDeploymentList
+		if err := r.List(ctx, &canaries); err != nil {
+			log.Error(err, "Failed to list canaries")
+			continue
+		}
+		for i := range canaries.Items {
+			canary := &canaries.Items[i]
+			if canary.Status.Phase != gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing {
+				continue
+			}
+			if r.resolveCanaryTemplate(ctx, canary) != nil || r.resolveAnalysisTemplate(ctx, canary) != nil {
+				continue
+			}
+			failure, err := r.checkPodHealth(ctx, canary)
+			if err != nil {
+				log.Error(err, "Failed to check canary pod health", "canary", canary.Namespace+"/"+canary.Name)
+				continue
+			}
+			if failure == "" {
+				continue
+			}
+			select {
+			case queue <- event.GenericEvent{Object: canary}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
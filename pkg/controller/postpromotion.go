@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/metrics"
+)
+
+// defaultPostPromotionInterval is how often analysis runs during the soak
+// period without an analysis interval
+const defaultPostPromotionInterval = time.Minute
+
+// handlePostPromotion keeps analysing the canary at full traffic for the
+// soak period of spec.analysis.postPromotion, and reports whether the
+// rollout is still soaking or was rolled back. The stable workload is only
+// released once the soak period passed.
+func (r *CanaryDeploymentReconciler) handlePostPromotion(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, bool, error) {
+	spec := canary.Spec.Analysis.PostPromotion
+	if spec == nil {
+		return ctrl.Result{}, false, nil
+	}
+	duration, err := time.ParseDuration(spec.Duration)
+	if err != nil || duration <= 0 {
+		return ctrl.Result{}, false, nil
+	}
+	interval := postPromotionInterval(canary)
+
+	status := canary.Status.PostPromotion
+	if status == nil {
+		now := metav1.Now()
+		canary.Status.PostPromotion = &gatewaycdv1alpha1.PostPromotionStatus{StartedTime: &now}
+		canary.Status.Message = fmt.Sprintf("Canary at full traffic, soaking for %s", duration)
+		canary.Status.LastProgressTime = &now
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		r.event(canary, EventReasonPostPromotionStarted, "Post-promotion analysis started for %s", duration)
+		return ctrl.Result{RequeueAfter: minDuration(interval, duration)}, true, nil
+	}
+
+	// Roll back on failing canary pods without waiting for metrics
+	if result, failed, err := r.handlePodHealth(ctx, canary); failed || err != nil {
+		return result, true, err
+	}
+
+	remaining := duration - time.Since(status.StartedTime.Time)
+	if remaining <= 0 {
+		return ctrl.Result{}, false, nil
+	}
+	if !analysisEnabled(canary) {
+		canary.Status.Message = fmt.Sprintf("Canary at full traffic, soaking for another %s", remaining.Round(time.Second))
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		return ctrl.Result{RequeueAfter: remaining}, true, nil
+	}
+
+	last := status.StartedTime
+	if status.LastRunTime != nil {
+		last = status.LastRunTime
+	}
+	if wait := interval - time.Since(last.Time); wait > 0 {
+		return ctrl.Result{RequeueAfter: minDuration(wait, remaining)}, true, nil
+	}
+
+	passed, err := r.runAnalysis(ctx, canary)
+	status.LastRunTime = &metav1.Time{Time: time.Now()}
+	if errors.Is(err, metrics.ErrProviderUnavailable) {
+		if canary.Spec.Analysis.ProviderUnavailablePolicy == gatewaycdv1alpha1.ProviderUnavailablePolicyRollback {
+			result, err := r.handleProviderUnavailable(ctx, canary)
+			return result, true, err
+		}
+		// There is no step to skip or pause at, the soak keeps its clock
+		canary.Status.Message = "Metrics provider unavailable, retrying post-promotion analysis"
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		r.warning(canary, EventReasonProviderUnavailable, "Metrics provider unavailable during post-promotion analysis")
+		return ctrl.Result{RequeueAfter: minDuration(interval, remaining)}, true, nil
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Post-promotion analysis failed")
+		if analysisErrorLimitReached(canary) {
+			return r.rollBackPromotion(ctx, canary, fmt.Sprintf("Post-promotion analysis could not be completed %d times in a row: %v",
+				canary.Status.ConsecutiveErrors, err))
+		}
+		canary.Status.Message = fmt.Sprintf("Post-promotion analysis failed: %v", err)
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		r.warning(canary, EventReasonAnalysisError, "Post-promotion analysis could not be completed: %v", err)
+		return ctrl.Result{RequeueAfter: minDuration(r.analysisErrorBackoff(canary), remaining)}, true, nil
+	}
+
+	status.Runs++
+	setAnalysisCondition(canary, passed, "PostPromotionAnalysis", fmt.Sprintf("Post-promotion analysis: %s", analysisOutcome(passed)))
+	if !passed && analysisFailureLimitReached(canary) {
+		return r.rollBackPromotion(ctx, canary, fmt.Sprintf("Post-promotion analysis failed: %s", failingMetricsSummary(canary)))
+	}
+	if !passed {
+		canary.Status.Message = fmt.Sprintf("Post-promotion analysis failed (%d of %d consecutive failures), retrying",
+			canary.Status.ConsecutiveFailures, analysisFailureLimit(canary))
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		r.warning(canary, EventReasonAnalysisRetrying, "%s: %s", canary.Status.Message, failingMetricsSummary(canary))
+		return ctrl.Result{RequeueAfter: minDuration(interval, remaining)}, true, nil
+	}
+
+	// A passed run is progress, the soak may outlast the progress deadline
+	canary.Status.LastProgressTime = status.LastRunTime
+	canary.Status.Message = fmt.Sprintf("Post-promotion analysis passed %d run(s), soaking for another %s",
+		status.Runs, remaining.Round(time.Second))
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, true, err
+	}
+	return ctrl.Result{RequeueAfter: minDuration(interval, remaining)}, true, nil
+}
+
+// rollBackPromotion rolls back a canary that degraded at full traffic
+func (r *CanaryDeploymentReconciler) rollBackPromotion(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, reason string) (ctrl.Result, bool, error) {
+	log.FromContext(ctx).Info("Post-promotion analysis failed, initiating rollback", "reason", reason)
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+	canary.Status.Message = "Post-promotion analysis failed, rolling back"
+	canary.Status.RollbackReason = reason
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, true, err
+	}
+	r.warning(canary, EventReasonAnalysisFailed, "%s", reason)
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, true, nil
+}
+
+// postPromotionInterval is how often analysis runs during the soak period
+func postPromotionInterval(canary *gatewaycdv1alpha1.CanaryDeployment) time.Duration {
+	if spec := canary.Spec.Analysis.PostPromotion; spec != nil && spec.Interval != "" {
+		if interval, err := time.ParseDuration(spec.Interval); err == nil && interval > 0 {
+			return interval
+		}
+	}
+	if interval := analysisInterval(canary); interval > 0 {
+		return interval
+	}
+	return defaultPostPromotionInterval
+}
+
+// minDuration returns the shorter of a and b
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// defaultPreRolloutCheckTimeout is how long the pre-rollout check may take
+	// to pass when it doesn't set a timeout
+	defaultPreRolloutCheckTimeout = time.Minute * 10
+
+	// httpCheckTimeout is how long a single HTTP check request may take
+	httpCheckTimeout = time.Second * 10
+
+	// envCanaryURL is the environment variable carrying the canary Service
+	// URL to the containers of a pre-rollout check Job
+	envCanaryURL = "CANARY_URL"
+)
+
+// preRolloutCheckPassed reports whether the pre-rollout check of the current rollout passed
+func preRolloutCheckPassed(canary *gatewaycdv1alpha1.CanaryDeployment) bool {
+	status := canary.Status.PreRolloutCheck
+	return status != nil && status.Phase == gatewaycdv1alpha1.HookPhaseSucceeded
+}
+
+// handlePreRolloutCheck smoke tests the canary Service while it gets no live
+// traffic. A failed Job fails the check at once; HTTP checks are repeated
+// until they pass, as the canary pods may still be starting. A check that
+// hasn't passed within its timeout rolls the canary back.
+func (r *CanaryDeploymentReconciler) handlePreRolloutCheck(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	check := canary.Spec.PreRolloutCheck
+	status := canary.Status.PreRolloutCheck
+	if status == nil {
+		status = &gatewaycdv1alpha1.PreRolloutCheckStatus{
+			Phase:       gatewaycdv1alpha1.HookPhaseRunning,
+			StartedTime: &metav1.Time{Time: time.Now()},
+		}
+		if check.Job != nil {
+			if err := r.startPreRolloutCheckJob(ctx, canary, status); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to start pre-rollout check")
+				canary.Status.Message = fmt.Sprintf("Failed to start pre-rollout check: %v", err)
+				if err := r.updateStatus(ctx, canary); err != nil {
+					return ctrl.Result{}, err
+				}
+				return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+			}
+		}
+		canary.Status.PreRolloutCheck = status
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		if check.Job != nil {
+			r.event(canary, EventReasonPreRolloutCheckStarted, "Started pre-rollout check as Job %s", status.JobName)
+		} else {
+			r.event(canary, EventReasonPreRolloutCheckStarted, "Started pre-rollout check of %s", canaryServiceURL(canary))
+		}
+	}
+
+	var passed bool
+	var failure string
+	if check.Job != nil {
+		var err error
+		passed, failure, err = r.checkPreRolloutJob(ctx, canary, status)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Failed to check pre-rollout check Job")
+			canary.Status.Message = fmt.Sprintf("Failed to check pre-rollout check: %v", err)
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+		}
+	} else {
+		status.Message = runHTTPChecks(ctx, canary)
+		passed = status.Message == ""
+	}
+
+	timeout := preRolloutCheckTimeout(check)
+	if !passed && failure == "" && time.Since(status.StartedTime.Time) > timeout {
+		failure = fmt.Sprintf("did not pass within %s", timeout)
+		if status.Message != "" {
+			failure = fmt.Sprintf("%s: %s", failure, status.Message)
+		}
+		if status.JobName != "" {
+			job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: status.JobName, Namespace: canary.Namespace}}
+			if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+				log.FromContext(ctx).Error(err, "Failed to delete timed out pre-rollout check Job", "job", job.Name)
+			}
+		}
+	}
+
+	if failure != "" {
+		status.Phase = gatewaycdv1alpha1.HookPhaseFailed
+		status.Message = failure
+		status.CompletedTime = &metav1.Time{Time: time.Now()}
+
+		reason := fmt.Sprintf("Pre-rollout check failed: %s", failure)
+		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+		canary.Status.Message = fmt.Sprintf("%s, rolling back", reason)
+		canary.Status.RollbackReason = reason
+		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonPreRolloutCheckFailed, "Pre-rollout check failed: %s", failure)
+		return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+	}
+
+	if !passed {
+		canary.Status.Message = "Waiting for the pre-rollout check to pass"
+		if status.Message != "" {
+			canary.Status.Message = fmt.Sprintf("%s: %s", canary.Status.Message, status.Message)
+		}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+	}
+
+	status.Phase = gatewaycdv1alpha1.HookPhaseSucceeded
+	status.Message = ""
+	status.CompletedTime = &metav1.Time{Time: time.Now()}
+	canary.Status.Message = "Pre-rollout check passed, shifting traffic"
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.event(canary, EventReasonPreRolloutCheckPassed, "Pre-rollout check passed")
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+}
+
+// startPreRolloutCheckJob creates the Job of the pre-rollout check, owned by
+// the canary, with CANARY_URL set in its containers
+func (r *CanaryDeploymentReconciler) startPreRolloutCheckJob(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment,
+	status *gatewaycdv1alpha1.PreRolloutCheckStatus) error {
+	template := canary.Spec.PreRolloutCheck.Job
+	job := &batchv1.Job{
+		ObjectMeta: *template.ObjectMeta.DeepCopy(),
+		Spec:       *template.Spec.DeepCopy(),
+	}
+	job.Name = preRolloutCheckJobName(canary)
+	job.Namespace = canary.Namespace
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+	job.Labels[labelCanary] = canary.Name
+	job.Labels[labelHook] = "pre-rollout-check"
+	if job.Spec.Template.Spec.RestartPolicy == "" {
+		job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	}
+	url := canaryServiceURL(canary)
+	for i := range job.Spec.Template.Spec.Containers {
+		container := &job.Spec.Template.Spec.Containers[i]
+		if !hasEnv(container, envCanaryURL) {
+			container.Env = append(container.Env, corev1.EnvVar{Name: envCanaryURL, Value: url})
+		}
+	}
+	if err := controllerutil.SetControllerReference(canary, job, r.Scheme); err != nil {
+		return err
+	}
+
+	if err := r.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create Job %s for the pre-rollout check: %w", job.Name, err)
+	}
+	status.JobName = job.Name
+	return nil
+}
+
+// checkPreRolloutJob reports whether the Job of the pre-rollout check
+// completed or, once it failed or was deleted, its failure
+func (r *CanaryDeploymentReconciler) checkPreRolloutJob(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment,
+	status *gatewaycdv1alpha1.PreRolloutCheckStatus) (bool, string, error) {
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: status.JobName, Namespace: canary.Namespace}, job)
+	if apierrors.IsNotFound(err) {
+		return false, fmt.Sprintf("Job %s was deleted", status.JobName), nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("failed to get Job %s: %w", status.JobName, err)
+	}
+	if jobCondition(job, batchv1.JobComplete) {
+		return true, "", nil
+	}
+	if jobCondition(job, batchv1.JobFailed) {
+		return false, jobFailureMessage(job), nil
+	}
+	return false, "", nil
+}
+
+// runHTTPChecks sends the HTTP checks to the canary Service in order and
+// returns the first failure, or an empty string once all of them passed
+func runHTTPChecks(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	baseURL := canaryServiceURL(canary)
+	for i, check := range canary.Spec.PreRolloutCheck.HTTP {
+		if failure := runHTTPCheck(ctx, baseURL, check); failure != "" {
+			return fmt.Sprintf("HTTP check %d: %s", i+1, failure)
+		}
+	}
+	return ""
+}
+
+// runHTTPCheck sends a request to the canary Service and returns why its
+// response isn't the expected one
+func runHTTPCheck(ctx context.Context, baseURL string, check gatewaycdv1alpha1.HTTPCheck) string {
+	method := check.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := check.Path
+	if path == "" {
+		path = "/"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, nil)
+	if err != nil {
+		return fmt.Sprintf("invalid request: %v", err)
+	}
+	for name, value := range check.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Sprintf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if check.ExpectedStatus != 0 && int32(resp.StatusCode) != check.ExpectedStatus {
+		return fmt.Sprintf("%s %s returned status %d, expected %d", method, path, resp.StatusCode, check.ExpectedStatus)
+	}
+	if check.ExpectedStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode >= 300) {
+		return fmt.Sprintf("%s %s returned status %d", method, path, resp.StatusCode)
+	}
+	if check.ExpectedBody != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return fmt.Sprintf("%s %s failed reading the body: %v", method, path, err)
+		}
+		if !strings.Contains(string(body), check.ExpectedBody) {
+			return fmt.Sprintf("%s %s body does not contain %q", method, path, check.ExpectedBody)
+		}
+	}
+	return ""
+}
+
+// canaryServiceURL is the in-cluster URL of the canary Service at the service port
+func canaryServiceURL(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	return fmt.Sprintf("http://%s-canary.%s.svc:%d", canary.Spec.Service.Name, canary.Namespace, canary.Spec.Service.Port)
+}
+
+// preRolloutCheckJobName names the Job of the pre-rollout check after the
+// canary and the start of the rollout, so every rollout checks afresh
+func preRolloutCheckJobName(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	suffix := "0"
+	if canary.Status.StartedTime != nil {
+		suffix = strconv.FormatInt(canary.Status.StartedTime.Unix(), 36)
+	}
+	prefix := canary.Name + "-check"
+	if limit := 63 - len(suffix) - 1; len(prefix) > limit {
+		prefix = prefix[:limit]
+	}
+	return fmt.Sprintf("%s-%s", prefix, suffix)
+}
+
+// preRolloutCheckTimeout parses the check timeout, falling back to the
+// default for values the webhook would have rejected
+func preRolloutCheckTimeout(check *gatewaycdv1alpha1.PreRolloutCheck) time.Duration {
+	if d, err := time.ParseDuration(check.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultPreRolloutCheckTimeout
+}
+
+// hasEnv reports whether the container sets the environment variable
+func hasEnv(container *corev1.Container, name string) bool {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// defaultProgrammedTimeout is how long the gateway may take to program a
+// step's weights when the canary doesn't set it
+const defaultProgrammedTimeout = time.Minute * 5
+
+// awaitRoutesProgrammed holds the step until the gateway implementation
+// reports its weights programmed, so analysis windows don't include traffic
+// routed before the change, and rolls the canary back once the gateway took
+// longer than the programmed timeout. It reports whether the step must wait.
+func (r *CanaryDeploymentReconciler) awaitRoutesProgrammed(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, bool, error) {
+	if canary.Status.WeightsAppliedTime == nil {
+		canary.Status.WeightsAppliedTime = &metav1.Time{Time: time.Now()}
+	}
+	step := canary.Status.CurrentStep + 1
+
+	pending, err := r.GatewayManager.RoutesProgrammed(ctx, canary)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to read route status")
+		pending = err.Error()
+	}
+	if pending == "" {
+		waited := time.Since(canary.Status.WeightsAppliedTime.Time).Round(time.Second)
+		canary.Status.WeightsProgrammed = true
+		setCondition(canary, gatewaycdv1alpha1.ConditionTypeRoutesProgrammed, metav1.ConditionTrue, "Programmed",
+			fmt.Sprintf("Gateway programmed the weights of step %d", step))
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		r.event(canary, EventReasonRoutesProgrammed, "Gateway programmed the weights of step %d after %s", step, waited)
+		return ctrl.Result{}, false, nil
+	}
+
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeRoutesProgrammed, metav1.ConditionFalse, "Pending", pending)
+	if timeout := programmedTimeout(canary); time.Since(canary.Status.WeightsAppliedTime.Time) > timeout {
+		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+		canary.Status.Message = "Gateway did not program the traffic split, rolling back"
+		canary.Status.RollbackReason = fmt.Sprintf("Gateway did not program the weights of step %d within %s: %s",
+			step, timeout, pending)
+		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		r.warning(canary, EventReasonRoutesNotProgrammed, "%s", canary.Status.RollbackReason)
+		return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, true, nil
+	}
+
+	canary.Status.Message = fmt.Sprintf("Waiting for the gateway to program the weights of step %d: %s", step, pending)
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, true, err
+	}
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, true, nil
+}
+
+// programmedTimeout parses the programmed timeout, falling back to the
+// default for values the webhook would have rejected
+func programmedTimeout(canary *gatewaycdv1alpha1.CanaryDeployment) time.Duration {
+	if d, err := time.ParseDuration(canary.Spec.Gateway.ProgrammedTimeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultProgrammedTimeout
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/metrics"
+)
+
+// prometheusRuleGVK is the prometheus-operator PrometheusRule kind
+var prometheusRuleGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "PrometheusRule",
+}
+
+// reconcilePrometheusRule creates or updates the PrometheusRule of the canary
+// so recording and alerting rules query exactly what the analysis engine does
+func (r *CanaryDeploymentReconciler) reconcilePrometheusRule(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	if canary.Spec.Monitoring == nil || !canary.Spec.Monitoring.PrometheusRule {
+		return nil
+	}
+
+	rule := &unstructured.Unstructured{}
+	rule.SetGroupVersionKind(prometheusRuleGVK)
+	rule.SetName(fmt.Sprintf("%s-canary", canary.Name))
+	rule.SetNamespace(canary.Namespace)
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, rule, func() error {
+		labels := rule.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		for k, v := range canary.Spec.Monitoring.RuleLabels {
+			labels[k] = v
+		}
+		labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+		rule.SetLabels(labels)
+
+		if err := unstructured.SetNestedSlice(rule.Object, prometheusRuleGroups(canary), "spec", "groups"); err != nil {
+			return err
+		}
+		return controllerutil.SetControllerReference(canary, rule, r.Scheme)
+	})
+	if meta.IsNoMatchError(err) {
+		log.FromContext(ctx).Info("PrometheusRule CRD not installed, skipping rule generation")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reconcile PrometheusRule %s/%s: %w", rule.GetNamespace(), rule.GetName(), err)
+	}
+	return nil
+}
+
+// prometheusRuleGroups renders the rule groups of the canary as unstructured content
+func prometheusRuleGroups(canary *gatewaycdv1alpha1.CanaryDeployment) []interface{} {
+	stableService := canary.Spec.Service.Name
+	canaryService := stableService + "-canary"
+	profile := canary.Spec.Analysis.MetricsProfile
+
+	trackLabels := func(track string) map[string]interface{} {
+		return map[string]interface{}{
+			"namespace": canary.Namespace,
+			"canary":    canary.Name,
+			"track":     track,
+		}
+	}
+	canaryLabels := map[string]interface{}{
+		"namespace": canary.Namespace,
+		"canary":    canary.Name,
+	}
+	selector := fmt.Sprintf(`namespace="%s",canary="%s"`, canary.Namespace, canary.Name)
+
+	rules := []interface{}{
+		recordingRule("gatewaycd:request_success_ratio:rate5m", metrics.SuccessRateQuery(profile, canary.Namespace, stableService), trackLabels("stable")),
+		recordingRule("gatewaycd:request_success_ratio:rate5m", metrics.SuccessRateQuery(profile, canary.Namespace, canaryService), trackLabels("canary")),
+		recordingRule("gatewaycd:request_latency_p95_ms", metrics.LatencyQuery(profile, canary.Namespace, stableService), trackLabels("stable")),
+		recordingRule("gatewaycd:request_latency_p95_ms", metrics.LatencyQuery(profile, canary.Namespace, canaryService), trackLabels("canary")),
+		recordingRule("gatewaycd:canary_vs_stable_success_ratio",
+			fmt.Sprintf(`gatewaycd:request_success_ratio:rate5m{%s,track="canary"} / ignoring(track) gatewaycd:request_success_ratio:rate5m{%s,track="stable"}`, selector, selector),
+			canaryLabels),
+	}
+	for _, metric := range canary.Spec.Analysis.Metrics {
+		labels := trackLabels("canary")
+		labels["metric"] = metric.Name
+		rules = append(rules, recordingRule("gatewaycd:analysis_metric", metrics.RenderMetricQuery(canary, metric), labels))
+	}
+
+	rules = append(rules, map[string]interface{}{
+		"alert": "GatewayCDCanaryRolledBack",
+		"expr":  fmt.Sprintf(`increase(gatewaycd_canary_rollbacks_total{%s}[10m]) > 0`, selector),
+		"labels": map[string]interface{}{
+			"severity": "warning",
+		},
+		"annotations": map[string]interface{}{
+			"summary": fmt.Sprintf("Canary %s/%s was rolled back", canary.Namespace, canary.Name),
+			"description": fmt.Sprintf("The canary of %s was rolled back to stable. Success rate threshold: %s.",
+				canary.Spec.TargetRef.Name, strconv.FormatFloat(canary.Spec.Analysis.SuccessRate, 'g', -1, 64)),
+		},
+	})
+
+	return []interface{}{
+		map[string]interface{}{
+			"name":  fmt.Sprintf("gateway-cd.%s.%s", canary.Namespace, canary.Name),
+			"rules": rules,
+		},
+	}
+}
+
+// recordingRule renders a single recording rule
+func recordingRule(record, expr string, labels map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"record": record,
+		"expr":   expr,
+		"labels": labels,
+	}
+}
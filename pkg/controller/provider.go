@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/metrics"
+)
+
+// metricsProvider returns the provider analysing the canary: the one its
+// analysis configures, or the controller's
+func (r *CanaryDeploymentReconciler) metricsProvider(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (metrics.Provider, error) {
+	spec := canary.Spec.Analysis.Provider
+	if spec == nil {
+		return r.MetricsProvider, nil
+	}
+	if r.Providers == nil || r.APIReader == nil {
+		return nil, fmt.Errorf("per-canary metrics providers are not enabled")
+	}
+
+	conn := metrics.Connection{
+		Address:            spec.Address,
+		Project:            spec.Project,
+		InsecureSkipVerify: spec.InsecureSkipVerify,
+	}
+	compat, err := metrics.ParseCompatibility(spec.Compatibility)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provider compatibility: %w", err)
+	}
+	conn.Compatibility = compat
+	if ref := spec.SecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: canary.Namespace, Name: ref.Name}, secret); err != nil {
+			return nil, fmt.Errorf("failed to get provider secret %s/%s: %w", canary.Namespace, ref.Name, err)
+		}
+		conn.BearerToken = strings.TrimSpace(string(secret.Data["token"]))
+		conn.Username = string(secret.Data["username"])
+		conn.Password = string(secret.Data["password"])
+		if conn.BearerToken == "" && conn.Username == "" {
+			return nil, fmt.Errorf("provider secret %s/%s has neither a token nor a username key", canary.Namespace, ref.Name)
+		}
+	}
+	return r.Providers.Get(canary.Namespace+"/"+canary.Name, spec.Type, conn)
+}
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/quota"
+)
+
+// checkQuota queues the rollout while its team runs as many rollouts as its
+// quota allows, so a burst of releases can't crowd a shared gateway. It
+// reports whether the rollout must wait.
+func (r *CanaryDeploymentReconciler) checkQuota(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (bool, error) {
+	err := r.Quotas.CheckStart(ctx, r.Client, canary)
+	if err == nil {
+		meta.RemoveStatusCondition(&canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeQueued)
+		return false, nil
+	}
+	if !quota.IsExceeded(err) {
+		return false, err
+	}
+
+	if !meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeQueued) {
+		r.event(canary, EventReasonQueued, "Queued, %v", err)
+	}
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeQueued, metav1.ConditionTrue, "QuotaExceeded", err.Error())
+	canary.Status.Message = fmt.Sprintf("Queued until the team's rollouts free up, %v", err)
+	return true, nil
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"time"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// RequeueIntervals are how soon a canary is reconciled again. A zero
+// interval takes its value from DefaultRequeueIntervals.
+type RequeueIntervals struct {
+	// Active is how soon a rollout that just changed state, e.g. shifted
+	// traffic or started a step, is reconciled again
+	Active time.Duration
+	// Poll is how often hooks, jobs and analysis in flight are checked
+	Poll time.Duration
+	// Idle is how soon a rollout that is blocked or failed to reconcile is
+	// retried
+	Idle time.Duration
+}
+
+// DefaultRequeueIntervals requeue active rollouts after 5s, poll after 10s
+// and retry after 30s
+var DefaultRequeueIntervals = RequeueIntervals{Active: 5 * time.Second, Poll: 10 * time.Second, Idle: 30 * time.Second}
+
+// activeInterval is how soon a rollout that just changed state is reconciled
+// again: spec.interval, else the controller's interval
+func (r *CanaryDeploymentReconciler) activeInterval(canary *gatewaycdv1alpha1.CanaryDeployment) time.Duration {
+	if interval, ok := canaryInterval(canary); ok {
+		return interval
+	}
+	return orDefault(r.Requeue.Active, DefaultRequeueIntervals.Active)
+}
+
+// pollInterval is how often the hooks, jobs and analysis of a rollout are
+// checked: spec.interval, else the controller's poll interval
+func (r *CanaryDeploymentReconciler) pollInterval(canary *gatewaycdv1alpha1.CanaryDeployment) time.Duration {
+	if interval, ok := canaryInterval(canary); ok {
+		return interval
+	}
+	return orDefault(r.Requeue.Poll, DefaultRequeueIntervals.Poll)
+}
+
+// idleInterval is how soon a blocked or failing rollout is retried
+func (r *CanaryDeploymentReconciler) idleInterval() time.Duration {
+	return orDefault(r.Requeue.Idle, DefaultRequeueIntervals.Idle)
+}
+
+// canaryInterval parses spec.interval, which the webhook validates
+func canaryInterval(canary *gatewaycdv1alpha1.CanaryDeployment) (time.Duration, bool) {
+	if canary.Spec.Interval == "" {
+		return 0, false
+	}
+	interval, err := time.ParseDuration(canary.Spec.Interval)
+	if err != nil || interval <= 0 {
+		return 0, false
+	}
+	return interval, true
+}
+
+// orDefault returns d, or def when d isn't positive
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/retry"
+)
+
+// retryFailure records a failed gateway operation and requeues the canary
+// with exponential backoff. A terminal failure, or one past the max retries,
+// opens the circuit and rolls the rollout back instead. A rollback itself is
+// never given up on, as it restores traffic to stable.
+func (r *CanaryDeploymentReconciler) retryFailure(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, reason, action string, err error) (ctrl.Result, error) {
+	log.FromContext(ctx).Error(err, action)
+	canary.Status.RetryCount++
+
+	if canary.Status.Phase != gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack &&
+		(retry.IsTerminal(err) || r.Retry.Exhausted(canary.Status.RetryCount)) {
+		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+		canary.Status.RollbackReason = fmt.Sprintf("%s after %d attempt(s): %v", action, canary.Status.RetryCount, err)
+		if retry.IsTerminal(err) {
+			canary.Status.RollbackReason = fmt.Sprintf("%s, retrying cannot fix it: %v", action, err)
+		}
+		canary.Status.Message = fmt.Sprintf("%s, rolling back", action)
+		canary.Status.RetryCount = 0
+		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonRetriesExhausted, "%s", canary.Status.RollbackReason)
+		return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+	}
+
+	delay := r.Retry.Delay(canary.Status.RetryCount)
+	canary.Status.Message = fmt.Sprintf("%s: %v, retry %d in %s", action, err, canary.Status.RetryCount, delay.Round(time.Second))
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.warning(canary, reason, "%s: %v", action, err)
+	return ctrl.Result{RequeueAfter: delay}, nil
+}
+
+// analysisErrorBackoff is the delay before analysis that could not be
+// completed runs again
+func (r *CanaryDeploymentReconciler) analysisErrorBackoff(canary *gatewaycdv1alpha1.CanaryDeployment) time.Duration {
+	return r.Retry.Delay(canary.Status.ConsecutiveErrors)
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// annotationRetry restarts the rollout of a Failed canary, from the first
+	// step with "true" or from the last step that passed with retryLastGood
+	annotationRetry = "gateway-cd.io/retry"
+	// retryLastGood is the retry annotation value resuming at the last step
+	// that passed
+	retryLastGood = "last-good"
+)
+
+// retryRollout consumes the retry annotation. A Failed canary goes back to
+// Pending, which restores the resources the rollback scaled down or deleted
+// and starts the rollout again; the annotation is ignored in other phases.
+// A Deployment the rollback reverted gets the failed revision back first, and
+// the retry is ignored when that revision's ReplicaSet is gone.
+func (r *CanaryDeploymentReconciler) retryRollout(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	by := "user"
+	if requestedBy := canary.Annotations[annotationRequestedBy]; requestedBy != "" {
+		by = requestedBy
+	}
+	action := newControlAction(canary, gatewaycdv1alpha1.ControlActionRetry)
+	value := canary.Annotations[annotationRetry]
+	action.Value = value
+
+	var ignored string
+	switch {
+	case value != "true" && value != retryLastGood:
+		ignored = fmt.Sprintf("Ignored, the retry must be \"true\" or %q", retryLastGood)
+	case canary.Status.Phase != gatewaycdv1alpha1.CanaryDeploymentPhaseFailed:
+		ignored = fmt.Sprintf("Ignored, only Failed canaries can be retried, not %s ones", canary.Status.Phase)
+	}
+	if ignored == "" {
+		// A rollback that reverted the workload left the stable revision in
+		// the Deployment; restore the failed one before rolling out again
+		restored, err := r.restoreCanaryRevision(ctx, canary)
+		switch {
+		case errors.Is(err, errRevisionNotFound):
+			ignored = fmt.Sprintf("Ignored, the failed revision cannot be restored: %v", err)
+		case err != nil:
+			log.FromContext(ctx).Error(err, "Failed to restore the failed revision for the retry")
+			r.warning(canary, EventReasonWorkloadRevertFailed, "Failed to restore the failed revision: %v", err)
+			return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+		case !restored:
+			canary.Status.Message = fmt.Sprintf("Retried by %s, waiting for the Deployment to run the failed revision again", by)
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, nil
+		}
+	}
+	if ignored != "" {
+		r.auditControlAction(canary, action, ignored)
+		if err := r.removeAnnotations(ctx, canary, annotationRetry, annotationRequestedBy, annotationRequestedVia, annotationAdmittedBy); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.warning(canary, EventReasonRetryIgnored, "Ignored retry %q by %s: %s", value, by, ignored)
+		return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+	}
+
+	step := int32(0)
+	if value == retryLastGood {
+		step = lastGoodStep(canary)
+	}
+	log.FromContext(ctx).Info("Retrying failed rollout on user request", "step", step+1)
+
+	// Pending keeps the step index, the rollout restarts at the chosen step
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhasePending
+	canary.Status.CurrentStep = step
+	canary.Status.CanaryWeight = 0
+	canary.Status.StableWeight = 100
+	canary.Status.RollbackReason = ""
+	canary.Status.Message = fmt.Sprintf("Retried by %s, restarting the rollout at step %d of %d",
+		by, step+1, len(canary.Spec.TrafficSplit))
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	r.auditControlAction(canary, action, canary.Status.Message)
+
+	if err := r.removeAnnotations(ctx, canary, annotationRetry, annotationRequestedBy, annotationRequestedVia, annotationAdmittedBy); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.event(canary, EventReasonRetried, "Failed rollout retried by %s at step %d of %d",
+		by, step+1, len(canary.Spec.TrafficSplit))
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+}
+
+// lastGoodStep returns the step before the one the rollout failed at, whose
+// analysis passed, or the first step when the rollout failed there. A failure
+// after the last step, e.g. in post-promotion analysis, resumes at the last
+// step.
+func lastGoodStep(canary *gatewaycdv1alpha1.CanaryDeployment) int32 {
+	step := canary.Status.CurrentStep - 1
+	if last := int32(len(canary.Spec.TrafficSplit)) - 1; step > last {
+		step = last
+	}
+	if step < 0 {
+		step = 0
+	}
+	return step
+}
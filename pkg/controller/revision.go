@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch
+
+const (
+	// annotationDeploymentRevision is set by the Deployment controller on
+	// Deployments and their ReplicaSets with the revision number
+	annotationDeploymentRevision = "deployment.kubernetes.io/revision"
+	// labelPodTemplateHash is set by the Deployment controller on ReplicaSets
+	labelPodTemplateHash = "pod-template-hash"
+)
+
+// recordRevisions snapshots the revision of the target Deployment being
+// rolled out. Without a stable revision from an earlier rollout, the
+// revision before it is taken as stable.
+func (r *CanaryDeploymentReconciler) recordRevisions(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	if canary.Spec.TargetRef.Kind != "Deployment" {
+		return nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return err
+	}
+	replicaSets, err := r.revisionReplicaSets(ctx, deployment)
+	if err != nil {
+		return err
+	}
+
+	current := deploymentRevision(&deployment.ObjectMeta)
+	canary.Status.CanaryRevision = nil
+	var previous *appsv1.ReplicaSet
+	for i := range replicaSets {
+		rs := &replicaSets[i]
+		revision := deploymentRevision(&rs.ObjectMeta)
+		if revision == current {
+			canary.Status.CanaryRevision = workloadRevision(rs)
+		} else if revision < current && (previous == nil || revision > deploymentRevision(&previous.ObjectMeta)) {
+			previous = rs
+		}
+	}
+	if canary.Status.StableRevision == nil && previous != nil {
+		canary.Status.StableRevision = workloadRevision(previous)
+	}
+	return nil
+}
+
+// promoteRevision records the revision the target Deployment runs after a
+// successful rollout as the stable revision
+func (r *CanaryDeploymentReconciler) promoteRevision(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	if canary.Spec.TargetRef.Kind != "Deployment" {
+		return nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return err
+	}
+	replicaSets, err := r.revisionReplicaSets(ctx, deployment)
+	if err != nil {
+		return err
+	}
+
+	current := deploymentRevision(&deployment.ObjectMeta)
+	for i := range replicaSets {
+		if deploymentRevision(&replicaSets[i].ObjectMeta) == current {
+			canary.Status.StableRevision = workloadRevision(&replicaSets[i])
+			break
+		}
+	}
+	canary.Status.CanaryRevision = nil
+	return nil
+}
+
+// revertWorkload restores the pod template of the stable revision on the
+// target Deployment, like kubectl rollout undo. With managed Services the
+// stable and canary revisions share the Deployment, so it is always reverted.
+func (r *CanaryDeploymentReconciler) revertWorkload(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	stable := canary.Status.StableRevision
+	if (!canary.Spec.RevertOnRollback && !canary.Spec.Service.Managed) || stable == nil {
+		return nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return err
+	}
+	replicaSets, err := r.revisionReplicaSets(ctx, deployment)
+	if err != nil {
+		return err
+	}
+
+	var source *appsv1.ReplicaSet
+	for i := range replicaSets {
+		if replicaSets[i].Labels[labelPodTemplateHash] == stable.PodTemplateHash {
+			source = &replicaSets[i]
+			break
+		}
+	}
+	if source == nil {
+		return fmt.Errorf("ReplicaSet of stable revision %s (pod-template-hash %s) of Deployment %s/%s not found",
+			stable.Revision, stable.PodTemplateHash, deployment.Namespace, deployment.Name)
+	}
+	if deploymentRevision(&source.ObjectMeta) == deploymentRevision(&deployment.ObjectMeta) {
+		return nil
+	}
+
+	patch := client.MergeFrom(deployment.DeepCopy())
+	deployment.Spec.Template = *source.Spec.Template.DeepCopy()
+	delete(deployment.Spec.Template.Labels, labelPodTemplateHash)
+	if err := r.Patch(ctx, deployment, patch); err != nil {
+		return fmt.Errorf("failed to revert Deployment %s/%s: %w", deployment.Namespace, deployment.Name, err)
+	}
+	r.event(canary, EventReasonWorkloadReverted, "Reverted Deployment %s to stable revision %s", deployment.Name, stable.Revision)
+	return nil
+}
+
+// errRevisionNotFound is returned when the ReplicaSet of a recorded revision
+// is gone, e.g. pruned by the Deployment's revisionHistoryLimit
+var errRevisionNotFound = errors.New("ReplicaSet of the revision not found")
+
+// restoreCanaryRevision re-applies the pod template of the recorded canary
+// revision on the target Deployment, undoing the revert of a rollback so a
+// retry rolls out the failed revision again rather than the stable one. It
+// reports whether the Deployment runs the canary revision, waiting for the
+// Deployment controller to pick up the restored template.
+func (r *CanaryDeploymentReconciler) restoreCanaryRevision(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (bool, error) {
+	revision := canary.Status.CanaryRevision
+	if canary.Spec.TargetRef.Kind != "Deployment" || revision == nil {
+		return true, nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return false, err
+	}
+	replicaSets, err := r.revisionReplicaSets(ctx, deployment)
+	if err != nil {
+		return false, err
+	}
+
+	var source *appsv1.ReplicaSet
+	for i := range replicaSets {
+		if replicaSets[i].Labels[labelPodTemplateHash] == revision.PodTemplateHash {
+			source = &replicaSets[i]
+			break
+		}
+	}
+	if source == nil {
+		return false, fmt.Errorf("%w: revision %s (pod-template-hash %s) of Deployment %s/%s",
+			errRevisionNotFound, revision.Revision, revision.PodTemplateHash, deployment.Namespace, deployment.Name)
+	}
+	if deploymentRevision(&source.ObjectMeta) == deploymentRevision(&deployment.ObjectMeta) {
+		return true, nil
+	}
+	// The Deployment controller has yet to roll out the restored template
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return false, nil
+	}
+
+	patch := client.MergeFrom(deployment.DeepCopy())
+	deployment.Spec.Template = *source.Spec.Template.DeepCopy()
+	delete(deployment.Spec.Template.Labels, labelPodTemplateHash)
+	if err := r.Patch(ctx, deployment, patch); err != nil {
+		return false, fmt.Errorf("failed to restore Deployment %s/%s: %w", deployment.Namespace, deployment.Name, err)
+	}
+	r.event(canary, EventReasonWorkloadRestored, "Restored Deployment %s to failed revision %s for the retry", deployment.Name, revision.Revision)
+	return false, nil
+}
+
+// revisionReplicaSets lists the ReplicaSets owned by a Deployment
+func (r *CanaryDeploymentReconciler) revisionReplicaSets(ctx context.Context, deployment *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	var list appsv1.ReplicaSetList
+	if err := r.List(ctx, &list, client.InNamespace(deployment.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list ReplicaSets: %w", err)
+	}
+	var owned []appsv1.ReplicaSet
+	for _, rs := range list.Items {
+		if owner := metav1.GetControllerOf(&rs); owner != nil && owner.UID == deployment.UID {
+			owned = append(owned, rs)
+		}
+	}
+	return owned, nil
+}
+
+// deploymentRevision parses the revision annotation of a Deployment or
+// ReplicaSet, returning 0 when it is missing
+func deploymentRevision(meta *metav1.ObjectMeta) int64 {
+	revision, _ := strconv.ParseInt(meta.Annotations[annotationDeploymentRevision], 10, 64)
+	return revision
+}
+
+// workloadRevision snapshots the revision of a ReplicaSet
+func workloadRevision(rs *appsv1.ReplicaSet) *gatewaycdv1alpha1.WorkloadRevision {
+	return &gatewaycdv1alpha1.WorkloadRevision{
+		Revision:        rs.Annotations[annotationDeploymentRevision],
+		PodTemplateHash: rs.Labels[labelPodTemplateHash],
+		Images:          podImages(&rs.Spec.Template.Spec),
+		RecordedTime:    &metav1.Time{Time: time.Now()},
+	}
+}
+
+// podImages lists the images of a pod's init and app containers as container=image
+func podImages(spec *corev1.PodSpec) []string {
+	var images []string
+	for _, container := range spec.InitContainers {
+		images = append(images, fmt.Sprintf("%s=%s", container.Name, container.Image))
+	}
+	for _, container := range spec.Containers {
+		images = append(images, fmt.Sprintf("%s=%s", container.Name, container.Image))
+	}
+	return images
+}
@@ -0,0 +1,315 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/metrics"
+)
+
+const (
+	// AnnotationRollbackReason is set on the target workload with the rollback reason
+	AnnotationRollbackReason = "gateway-cd.io/rollback-reason"
+	// AnnotationRollbackMetrics is set on the target workload with the failing metrics summary
+	AnnotationRollbackMetrics = "gateway-cd.io/rollback-metrics"
+	// AnnotationRolledBackAt is set on the target workload with the rollback time
+	AnnotationRolledBackAt = "gateway-cd.io/rolled-back-at"
+	// AnnotationRolledBackBy is set on the target workload with the CanaryDeployment name
+	AnnotationRolledBackBy = "gateway-cd.io/rolled-back-by"
+	// annotationScaledDownReplicas records the replicas of a canary Deployment
+	// scaled down by a rollback, restored when the next rollout starts
+	annotationScaledDownReplicas = "gateway-cd.io/scaled-down-replicas"
+
+	// defaultRollbackStepDuration is how long a rollback step holds its
+	// weight when it doesn't set a duration
+	defaultRollbackStepDuration = time.Second * 30
+)
+
+// failingMetricsSummary renders the failed checks of the last analysis run
+func failingMetricsSummary(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	run := canary.Status.AnalysisRun
+	if run == nil {
+		return ""
+	}
+
+	var failed []string
+	for _, result := range run.MetricResults {
+		if !result.Passed {
+			failed = append(failed, fmt.Sprintf("%s=%g (threshold %g)", result.Name, result.Value, result.Threshold))
+		}
+	}
+	if baseline := canary.Spec.Baseline; baseline != nil {
+		if minRate := run.BaselineSuccessRate - metrics.SuccessRateTolerance(baseline); run.SuccessRate < minRate {
+			failed = append(failed, fmt.Sprintf("successRate=%g (baseline %g)", run.SuccessRate, run.BaselineSuccessRate))
+		}
+		if maxLatency := int32(float64(run.BaselineLatency) * (1 + metrics.BaselineTolerance(baseline))); run.AverageLatency > maxLatency {
+			failed = append(failed, fmt.Sprintf("latency=%dms (baseline %dms)", run.AverageLatency, run.BaselineLatency))
+		}
+		return strings.Join(failed, ", ")
+	}
+	if minRate := canary.Spec.Analysis.SuccessRate; minRate > 0 && run.SuccessRate < minRate {
+		failed = append(failed, fmt.Sprintf("successRate=%g (min %g)", run.SuccessRate, minRate))
+	}
+	if maxLatency := canary.Spec.Analysis.MaxLatency; maxLatency > 0 && run.AverageLatency > maxLatency {
+		failed = append(failed, fmt.Sprintf("latency=%dms (max %dms)", run.AverageLatency, maxLatency))
+	}
+
+	return strings.Join(failed, ", ")
+}
+
+// propagateRollbackReason annotates the target Deployment with the rollback
+// reason and emits an Event on it so the failure is visible from the workload
+func (r *CanaryDeploymentReconciler) propagateRollbackReason(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	if !canary.Spec.PropagateRollbackReason {
+		return nil
+	}
+	if canary.Spec.TargetRef.Kind != "Deployment" {
+		log.FromContext(ctx).Info("Rollback reason propagation only supports Deployments", "kind", canary.Spec.TargetRef.Kind)
+		return nil
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      canary.Spec.TargetRef.Name,
+		Namespace: canary.Namespace,
+	}, deployment); err != nil {
+		return fmt.Errorf("failed to get Deployment %s/%s: %w", canary.Namespace, canary.Spec.TargetRef.Name, err)
+	}
+
+	reason := canary.Status.RollbackReason
+	summary := failingMetricsSummary(canary)
+
+	patch := client.MergeFrom(deployment.DeepCopy())
+	if deployment.Annotations == nil {
+		deployment.Annotations = make(map[string]string)
+	}
+	deployment.Annotations[AnnotationRollbackReason] = reason
+	deployment.Annotations[AnnotationRolledBackAt] = time.Now().UTC().Format(time.RFC3339)
+	deployment.Annotations[AnnotationRolledBackBy] = canary.Name
+	if summary != "" {
+		deployment.Annotations[AnnotationRollbackMetrics] = summary
+	} else {
+		delete(deployment.Annotations, AnnotationRollbackMetrics)
+	}
+	if err := r.Patch(ctx, deployment, patch); err != nil {
+		return fmt.Errorf("failed to annotate Deployment %s/%s: %w", canary.Namespace, deployment.Name, err)
+	}
+
+	if r.Recorder != nil {
+		message := fmt.Sprintf("Canary %s rolled back: %s", canary.Name, reason)
+		if summary != "" {
+			message = fmt.Sprintf("%s (failing: %s)", message, summary)
+		}
+		r.Recorder.Event(deployment, corev1.EventTypeWarning, "CanaryRolledBack", message)
+	}
+
+	return nil
+}
+
+// rollbackMode is the rollback mode of a canary, trafficOnly by default
+func rollbackMode(canary *gatewaycdv1alpha1.CanaryDeployment) gatewaycdv1alpha1.RollbackMode {
+	if canary.Spec.Rollback == nil || canary.Spec.Rollback.Mode == "" {
+		return gatewaycdv1alpha1.RollbackModeTrafficOnly
+	}
+	return canary.Spec.Rollback.Mode
+}
+
+// ignoreTeardownFailure reports whether a rollback completes despite a failed teardown
+func ignoreTeardownFailure(canary *gatewaycdv1alpha1.CanaryDeployment) bool {
+	return canary.Spec.Rollback != nil && canary.Spec.Rollback.FailurePolicy == gatewaycdv1alpha1.RollbackFailurePolicyIgnore
+}
+
+// canaryTornDown reports whether the canary resources were deleted by the
+// last rollback and must not be recreated before the next rollout
+func canaryTornDown(canary *gatewaycdv1alpha1.CanaryDeployment) bool {
+	return canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhaseFailed &&
+		rollbackMode(canary) == gatewaycdv1alpha1.RollbackModeDeleteCanaryResources
+}
+
+// tearDownCanary removes what the rollback mode asks for beyond the traffic:
+// the canary Deployment's replicas, and with deleteCanaryResources the canary
+// Service and NetworkPolicies the controller created
+func (r *CanaryDeploymentReconciler) tearDownCanary(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	mode := rollbackMode(canary)
+	if mode == gatewaycdv1alpha1.RollbackModeTrafficOnly {
+		return nil
+	}
+	if err := r.scaleDownCanary(ctx, canary); err != nil {
+		return err
+	}
+	if mode == gatewaycdv1alpha1.RollbackModeDeleteCanaryResources {
+		return r.deleteCanaryResources(ctx, canary)
+	}
+	return nil
+}
+
+// scaleDownCanary scales a separate canary Deployment to zero replicas and
+// records the replicas it ran. A target Deployment that also runs the stable
+// pods, with managed Services, is left running.
+func (r *CanaryDeploymentReconciler) scaleDownCanary(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	if canary.Spec.Service.Managed || canary.Spec.TargetRef.Kind != "Deployment" {
+		return nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return err
+	}
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0 {
+		return nil
+	}
+
+	from := int32(1)
+	if deployment.Spec.Replicas != nil {
+		from = *deployment.Spec.Replicas
+	}
+	patch := client.MergeFrom(deployment.DeepCopy())
+	if deployment.Annotations == nil {
+		deployment.Annotations = make(map[string]string)
+	}
+	deployment.Annotations[annotationScaledDownReplicas] = fmt.Sprint(from)
+	deployment.Spec.Replicas = new(int32)
+	if err := r.Patch(ctx, deployment, patch); err != nil {
+		return fmt.Errorf("failed to scale down Deployment %s/%s: %w", deployment.Namespace, deployment.Name, err)
+	}
+	r.event(canary, EventReasonCanaryScaledDown, "Scaled Deployment %s from %d to 0 replicas", deployment.Name, from)
+	return nil
+}
+
+// restoreCanaryReplicas scales a canary Deployment scaled down by a rollback
+// back to the replicas it ran, unless it was scaled since
+func (r *CanaryDeploymentReconciler) restoreCanaryReplicas(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	if canary.Spec.TargetRef.Kind != "Deployment" {
+		return nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return err
+	}
+	recorded, ok := deployment.Annotations[annotationScaledDownReplicas]
+	if !ok {
+		return nil
+	}
+
+	patch := client.MergeFrom(deployment.DeepCopy())
+	delete(deployment.Annotations, annotationScaledDownReplicas)
+	var replicas int32
+	if _, err := fmt.Sscan(recorded, &replicas); err == nil && replicas > 0 &&
+		deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0 {
+		deployment.Spec.Replicas = &replicas
+		r.event(canary, EventReasonCanaryScaled, "Scaled Deployment %s from 0 to %d replicas", deployment.Name, replicas)
+	}
+	if err := r.Patch(ctx, deployment, patch); err != nil {
+		return fmt.Errorf("failed to restore replicas of Deployment %s/%s: %w", deployment.Namespace, deployment.Name, err)
+	}
+	return nil
+}
+
+// deleteCanaryResources deletes the canary Service and NetworkPolicies the
+// controller created for the canary. Resources it only labels, like the
+// stable Service, are kept.
+func (r *CanaryDeploymentReconciler) deleteCanaryResources(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	selector := client.MatchingLabels{labelCanary: canary.Name}
+	var services corev1.ServiceList
+	if err := r.List(ctx, &services, client.InNamespace(canary.Namespace), selector); err != nil {
+		return fmt.Errorf("failed to list canary Services: %w", err)
+	}
+	var policies networkingv1.NetworkPolicyList
+	if err := r.List(ctx, &policies, client.InNamespace(canary.Namespace), selector); err != nil {
+		return fmt.Errorf("failed to list canary NetworkPolicies: %w", err)
+	}
+
+	var objs []client.Object
+	for i := range services.Items {
+		objs = append(objs, &services.Items[i])
+	}
+	for i := range policies.Items {
+		objs = append(objs, &policies.Items[i])
+	}
+	var deleted []string
+	for _, obj := range objs {
+		if !metav1.IsControlledBy(obj, canary) {
+			continue
+		}
+		if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+		}
+		deleted = append(deleted, obj.GetName())
+	}
+	if len(deleted) > 0 {
+		r.event(canary, EventReasonCanaryResourcesDeleted, "Deleted canary resources %s", strings.Join(deleted, ", "))
+	}
+	return nil
+}
+
+// stepDownCanary lowers the canary weight along the rollback steps, holding
+// each for its duration, before the rollback routes all traffic to stable.
+// It reports whether the rollback must wait with the returned result.
+func (r *CanaryDeploymentReconciler) stepDownCanary(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, bool, error) {
+	var steps []gatewaycdv1alpha1.RollbackStep
+	if canary.Spec.Rollback != nil {
+		steps = canary.Spec.Rollback.Steps
+	}
+	status := canary.Status.RollbackStep
+	if len(steps) == 0 || (status != nil && int(status.Step) >= len(steps)) {
+		return ctrl.Result{}, false, nil
+	}
+
+	next := 0
+	if status != nil {
+		if status.StartedTime != nil {
+			remaining := rollbackStepDuration(steps[status.Step]) - time.Since(status.StartedTime.Time)
+			if remaining > 0 {
+				return ctrl.Result{RequeueAfter: remaining}, true, nil
+			}
+		}
+		next = int(status.Step) + 1
+	}
+	// Never raise the canary weight on the way back
+	for next < len(steps) && steps[next].Weight >= canary.Status.CanaryWeight {
+		next++
+	}
+	if next == len(steps) {
+		canary.Status.RollbackStep = &gatewaycdv1alpha1.RollbackStepStatus{Step: int32(len(steps))}
+		return ctrl.Result{}, false, nil
+	}
+
+	weight := steps[next].Weight
+	if err := r.GatewayManager.UpdateTrafficSplit(ctx, canary, int(weight)); err != nil {
+		result, err := r.retryFailure(ctx, canary, EventReasonTrafficUpdateFailed, "Failed to lower canary weight", err)
+		return result, true, err
+	}
+	canary.Status.RetryCount = 0
+	canary.Status.RollbackStep = &gatewaycdv1alpha1.RollbackStepStatus{
+		Step:        int32(next),
+		StartedTime: &metav1.Time{Time: time.Now()},
+	}
+	canary.Status.CanaryWeight = weight
+	canary.Status.StableWeight = 100 - weight
+	canary.Status.Message = fmt.Sprintf("Rolling back gradually, canary weight lowered to %d%%", weight)
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, true, err
+	}
+	r.event(canary, EventReasonRollbackStep, "Lowered canary weight to %d%% (rollback step %d of %d)", weight, next+1, len(steps))
+	return ctrl.Result{RequeueAfter: rollbackStepDuration(steps[next])}, true, nil
+}
+
+// rollbackStepDuration parses the duration of a rollback step, falling back
+// to the default for values the webhook would have rejected
+func rollbackStepDuration(step gatewaycdv1alpha1.RollbackStep) time.Duration {
+	if d, err := time.ParseDuration(step.Duration); err == nil && d > 0 {
+		return d
+	}
+	return defaultRollbackStepDuration
+}
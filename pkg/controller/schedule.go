@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/schedule"
+)
+
+// maxScheduleRequeue bounds how long a rollout waiting for its window sleeps,
+// so schedule changes are picked up
+const maxScheduleRequeue = time.Minute * 10
+
+// awaitSchedule holds the rollout at its current weight while spec.schedule
+// is closed, before the weight of the next step is applied or the canary is
+// promoted, and reports a Waiting condition until the next window opens. A
+// step already under way completes its analysis. It reports whether the
+// rollout must wait.
+func (r *CanaryDeploymentReconciler) awaitSchedule(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, bool, error) {
+	waiting := meta.IsStatusConditionTrue(canary.Status.Conditions, gatewaycdv1alpha1.ConditionTypeWaiting)
+	if canary.Spec.Schedule == nil || canary.Status.WeightsAppliedTime != nil {
+		if waiting {
+			setCondition(canary, gatewaycdv1alpha1.ConditionTypeWaiting, metav1.ConditionFalse, "NotWaiting", "Rollout is not waiting for a window")
+		}
+		return ctrl.Result{}, false, nil
+	}
+
+	now := time.Now()
+	sched, err := schedule.New(canary.Spec.Schedule)
+	if err != nil {
+		return r.holdForSchedule(ctx, canary, waiting, "InvalidSchedule",
+			fmt.Sprintf("Holding at %d%% canary traffic, the schedule is invalid: %v", canary.Status.CanaryWeight, err), maxScheduleRequeue)
+	}
+	if sched.Open(now) {
+		if waiting {
+			setCondition(canary, gatewaycdv1alpha1.ConditionTypeWaiting, metav1.ConditionFalse, "WindowOpen", "Rollout window is open")
+			// Time spent waiting doesn't count against the progress deadline
+			canary.Status.LastProgressTime = &metav1.Time{Time: now}
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, true, err
+			}
+			r.event(canary, EventReasonWindowOpened, "Rollout window opened, continuing at step %d", canary.Status.CurrentStep+1)
+		}
+		return ctrl.Result{}, false, nil
+	}
+
+	message := fmt.Sprintf("Holding at %d%% canary traffic outside the rollout windows", canary.Status.CanaryWeight)
+	requeue := maxScheduleRequeue
+	if next, ok := sched.NextOpen(now); ok {
+		message = fmt.Sprintf("%s until %s", message, next.Format(time.RFC3339))
+		if until := next.Sub(now) + time.Second; until < requeue {
+			requeue = until
+		}
+	}
+	return r.holdForSchedule(ctx, canary, waiting, "OutsideWindow", message, requeue)
+}
+
+// holdForSchedule sets the Waiting condition, recording an event when the
+// rollout starts waiting
+func (r *CanaryDeploymentReconciler) holdForSchedule(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment,
+	waiting bool, reason, message string, requeue time.Duration) (ctrl.Result, bool, error) {
+	setCondition(canary, gatewaycdv1alpha1.ConditionTypeWaiting, metav1.ConditionTrue, reason, message)
+	canary.Status.Message = message
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, true, err
+	}
+	if !waiting {
+		r.event(canary, EventReasonWaitingForWindow, "%s", message)
+	}
+	return ctrl.Result{RequeueAfter: requeue}, true, nil
+}
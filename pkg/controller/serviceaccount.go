@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// switchServiceAccount creates the canary ServiceAccount and runs the target
+// Deployment's pods under it, remembering the ServiceAccount it replaced
+func (r *CanaryDeploymentReconciler) switchServiceAccount(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	spec := canary.Spec.ServiceAccount
+	if spec == nil {
+		return nil
+	}
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: spec.Name, Namespace: canary.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, sa, func() error {
+		if sa.Labels == nil {
+			sa.Labels = make(map[string]string)
+		}
+		sa.Labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+		sa.Labels[labelCanary] = canary.Name
+		if sa.Annotations == nil {
+			sa.Annotations = make(map[string]string)
+		}
+		for k, v := range spec.Annotations {
+			sa.Annotations[k] = v
+		}
+		return controllerutil.SetControllerReference(canary, sa, r.Scheme)
+	}); err != nil {
+		return fmt.Errorf("failed to reconcile ServiceAccount %s/%s: %w", sa.Namespace, sa.Name, err)
+	}
+
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return err
+	}
+	current := deployment.Spec.Template.Spec.ServiceAccountName
+	if current == "" {
+		current = "default"
+	}
+	if current == spec.Name {
+		return nil
+	}
+
+	if err := r.setServiceAccount(ctx, deployment, spec.Name); err != nil {
+		return err
+	}
+	canary.Status.OriginalServiceAccount = current
+	r.event(canary, EventReasonServiceAccountSwitched, "Deployment %s now runs as ServiceAccount %s instead of %s",
+		deployment.Name, spec.Name, current)
+	return nil
+}
+
+// restoreServiceAccount runs the target Deployment's pods under the
+// ServiceAccount they used before the rollout switched it
+func (r *CanaryDeploymentReconciler) restoreServiceAccount(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	original := canary.Status.OriginalServiceAccount
+	if original == "" {
+		return nil
+	}
+
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return err
+	}
+	if err := r.setServiceAccount(ctx, deployment, original); err != nil {
+		return err
+	}
+	canary.Status.OriginalServiceAccount = ""
+	r.event(canary, EventReasonServiceAccountRestored, "Deployment %s runs as ServiceAccount %s again", deployment.Name, original)
+	return nil
+}
+
+// targetDeployment fetches the Deployment the canary targets
+func (r *CanaryDeploymentReconciler) targetDeployment(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (*appsv1.Deployment, error) {
+	if canary.Spec.TargetRef.Kind != "Deployment" {
+		return nil, fmt.Errorf("target kind %q is not a Deployment", canary.Spec.TargetRef.Kind)
+	}
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      canary.Spec.TargetRef.Name,
+		Namespace: canary.Namespace,
+	}, deployment); err != nil {
+		return nil, fmt.Errorf("failed to get Deployment %s/%s: %w", canary.Namespace, canary.Spec.TargetRef.Name, err)
+	}
+	return deployment, nil
+}
+
+// setServiceAccount patches the ServiceAccount of a Deployment's pod template
+func (r *CanaryDeploymentReconciler) setServiceAccount(ctx context.Context, deployment *appsv1.Deployment, name string) error {
+	patch := client.MergeFrom(deployment.DeepCopy())
+	deployment.Spec.Template.Spec.ServiceAccountName = name
+	// The deprecated field would otherwise keep the previous account
+	deployment.Spec.Template.Spec.DeprecatedServiceAccount = ""
+	if err := r.Patch(ctx, deployment, patch); err != nil {
+		return fmt.Errorf("failed to set ServiceAccount of Deployment %s/%s: %w", deployment.Namespace, deployment.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/metrics"
+)
+
+// smoothAnalysis adds the last analysis run to the history of the rollout,
+// keeping the window of spec.analysis.smoothing, and takes the verdict over
+// the window. A failed run the window absorbs is recorded as an event.
+func (r *CanaryDeploymentReconciler) smoothAnalysis(canary *gatewaycdv1alpha1.CanaryDeployment) bool {
+	smoothing := canary.Spec.Analysis.Smoothing
+	run := canary.Status.AnalysisRun
+
+	history := append(canary.Status.AnalysisHistory, *run.DeepCopy())
+	if n := len(history) - int(smoothing.Window); n > 0 {
+		history = append([]gatewaycdv1alpha1.AnalysisRunStatus(nil), history[n:]...)
+	}
+	canary.Status.AnalysisHistory = history
+
+	passed := metrics.Smooth(canary, history)
+	if passed && run.Phase != "Successful" {
+		r.event(canary, EventReasonAnalysisSmoothed, "Analysis run failed at step %d but passed over the last %d runs: %s",
+			canary.Status.CurrentStep+1, len(history), failingMetricsSummary(canary))
+	}
+	return passed
+}
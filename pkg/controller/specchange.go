@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// trafficSplitHashLength is the number of hex characters of the traffic split hash
+const trafficSplitHashLength = 16
+
+// reconcileSpecChange restarts a rollout whose target Deployment moved to new
+// images and re-plans one whose traffic split steps were edited, so the step
+// index never points into steps it wasn't computed for. A finished canary
+// generated from a Deployment rolls out the Deployment's next images. It
+// reports whether it changed the rollout.
+func (r *CanaryDeploymentReconciler) reconcileSpecChange(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (bool, error) {
+	switch canary.Status.Phase {
+	case gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing, gatewaycdv1alpha1.CanaryDeploymentPhasePaused:
+	case gatewaycdv1alpha1.CanaryDeploymentPhaseSucceeded, gatewaycdv1alpha1.CanaryDeploymentPhaseFailed:
+		return r.restartGenerated(ctx, canary)
+	default:
+		return false, nil
+	}
+
+	// Only the images are compared, as the rollout itself patches the pod
+	// template, e.g. to switch the ServiceAccount
+	if recorded := canary.Status.CanaryRevision; recorded != nil && len(recorded.Images) > 0 {
+		deployment, err := r.targetDeployment(ctx, canary)
+		if err != nil {
+			return false, err
+		}
+		if images := podImages(&deployment.Spec.Template.Spec); !slices.Equal(images, recorded.Images) {
+			return true, r.restartRollout(ctx, canary, fmt.Sprintf("Deployment %s moved to a new image", deployment.Name))
+		}
+	}
+
+	hash := trafficSplitHash(canary.Spec.TrafficSplit)
+	switch canary.Status.TrafficSplitHash {
+	case hash:
+		return false, nil
+	case "":
+		// Rollouts started before the steps were recorded keep their step index
+		canary.Status.TrafficSplitHash = hash
+		return false, nil
+	}
+	return true, r.replanRollout(ctx, canary, hash)
+}
+
+// restartGenerated restarts a Succeeded or Failed canary generated from a
+// Deployment once the Deployment moves to images other than the promoted
+// stable revision or the failed canary revision the rollback reverted. The
+// generated canary lives as long as the Deployment, so unlike a canary
+// created per release it has to roll out every new revision.
+func (r *CanaryDeploymentReconciler) restartGenerated(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (bool, error) {
+	stable := canary.Status.StableRevision
+	if canary.Labels[labelGeneratedFrom] == "" || canary.Spec.TargetRef.Kind != "Deployment" || stable == nil {
+		return false, nil
+	}
+	deployment, err := r.targetDeployment(ctx, canary)
+	if err != nil {
+		return false, err
+	}
+	images := podImages(&deployment.Spec.Template.Spec)
+	if slices.Equal(images, stable.Images) {
+		return false, nil
+	}
+	// Only a retry rolls the failed revision out again
+	if failed := canary.Status.CanaryRevision; failed != nil && slices.Equal(images, failed.Images) {
+		return false, nil
+	}
+	return true, r.restartRollout(ctx, canary, fmt.Sprintf("Deployment %s moved to a new image", deployment.Name))
+}
+
+// restartRollout routes all traffic back to stable and starts the rollout
+// over from Pending, where the new revision is recorded
+func (r *CanaryDeploymentReconciler) restartRollout(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, reason string) error {
+	if err := r.GatewayManager.UpdateTrafficSplit(ctx, canary, 0); err != nil {
+		return fmt.Errorf("failed to route traffic back to stable: %w", err)
+	}
+	// A paused Deployment doesn't create the ReplicaSet of the new revision
+	if err := r.pauseTargetDeployment(ctx, canary, false); err != nil {
+		return err
+	}
+
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhasePending
+	canary.Status.CurrentStep = 0
+	canary.Status.CanaryWeight = 0
+	canary.Status.StableWeight = 100
+	canary.Status.RollbackReason = ""
+	canary.Status.Message = fmt.Sprintf("%s, restarting the rollout", reason)
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return err
+	}
+	r.event(canary, EventReasonRolloutRestarted, "%s, restarting the rollout from stable", reason)
+	return nil
+}
+
+// replanRollout maps the rollout onto edited traffic split steps. It resumes
+// at the last step whose weight doesn't exceed the current canary weight, so
+// the edit never exposes the canary to more traffic than analysis approved.
+func (r *CanaryDeploymentReconciler) replanRollout(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, hash string) error {
+	from := canary.Status.CurrentStep
+	step := int32(0)
+	for i, s := range canary.Spec.TrafficSplit {
+		if s.Weight <= canary.Status.CanaryWeight {
+			step = int32(i)
+		}
+	}
+
+	canary.Status.CurrentStep = step
+	canary.Status.TrafficSplitHash = hash
+	canary.Status.StepAnalysis = nil
+	canary.Status.WeightsAppliedTime = nil
+	canary.Status.WeightsProgrammed = false
+	if canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhasePaused && !pausedManually(canary) {
+		// The step paused for approval belongs to the old steps
+		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing
+	}
+	canary.Status.Message = fmt.Sprintf("Traffic split steps changed, resuming at step %d of %d",
+		step+1, len(canary.Spec.TrafficSplit))
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	recordStepTransition(canary, actorController)
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return err
+	}
+	r.event(canary, EventReasonRolloutReplanned, "Traffic split steps changed at step %d, resuming at step %d of %d",
+		from+1, step+1, len(canary.Spec.TrafficSplit))
+	return nil
+}
+
+// trafficSplitHash identifies a list of traffic split steps
+func trafficSplitHash(steps []gatewaycdv1alpha1.TrafficSplitStep) string {
+	data, _ := json.Marshal(steps)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:trafficSplitHashLength]
+}
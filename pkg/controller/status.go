@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// statusBaseKey is the context key of the status a reconcile started from
+type statusBaseKey struct{}
+
+// statusBase is the status last read or written by a reconcile, which status
+// patches are computed against
+type statusBase struct {
+	status gatewaycdv1alpha1.CanaryDeploymentStatus
+	// written runs once the status changes made so far are written
+	written []func()
+}
+
+// withStatusBase records the status of the canary as read at the start of a
+// reconcile
+func withStatusBase(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) context.Context {
+	return context.WithValue(ctx, statusBaseKey{}, &statusBase{status: *canary.Status.DeepCopy()})
+}
+
+// afterStatusWrite defers fn, such as counting a metric, until the next status
+// write succeeds, so a write lost to a conflict doesn't count it twice. It
+// runs at once outside a reconcile.
+func afterStatusWrite(ctx context.Context, fn func()) {
+	base, ok := ctx.Value(statusBaseKey{}).(*statusBase)
+	if !ok {
+		fn()
+		return
+	}
+	base.written = append(base.written, fn)
+}
+
+// patchStatus writes the fields of the status changed since the reconcile
+// read it, guarded by the resourceVersion so a reconcile working from a stale
+// canary never overwrites newer status or advances a step twice. A conflict
+// caused only by changes outside the status, such as annotations set through
+// the API server, is retried against the latest resourceVersion; a conflict
+// over the status itself is returned so the canary is reconciled afresh.
+func (r *CanaryDeploymentReconciler) patchStatus(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	base, ok := ctx.Value(statusBaseKey{}).(*statusBase)
+	if !ok {
+		return r.Status().Update(ctx, canary)
+	}
+
+	stale := false
+	retriable := func(err error) bool {
+		return apierrors.IsConflict(err) && !stale
+	}
+	err := retry.OnError(retry.DefaultRetry, retriable, func() error {
+		original := canary.DeepCopy()
+		original.Status = base.status
+		err := r.Status().Patch(ctx, canary, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+		if err == nil {
+			base.status = *canary.Status.DeepCopy()
+			return nil
+		}
+		if !apierrors.IsConflict(err) {
+			return err
+		}
+
+		latest := &gatewaycdv1alpha1.CanaryDeployment{}
+		if getErr := r.APIReader.Get(ctx, client.ObjectKeyFromObject(canary), latest); getErr != nil {
+			return getErr
+		}
+		if !equality.Semantic.DeepEqual(latest.Status, base.status) {
+			// Another reconcile wrote the status since this one read it
+			stale = true
+			return err
+		}
+		canary.ResourceVersion = latest.ResourceVersion
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, fn := range base.written {
+		fn()
+	}
+	base.written = nil
+	return nil
+}
@@ -0,0 +1,391 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/expression"
+)
+
+const (
+	// defaultStepWebhookTimeout is how long a step hook webhook may take when
+	// the hook doesn't set a timeout
+	defaultStepWebhookTimeout = time.Second * 30
+	// defaultStepHookRetries is how many times the retry policy runs a failed
+	// hook again when the hook doesn't set its retries
+	defaultStepHookRetries = 3
+)
+
+// stepWebhookClient calls step hook webhooks, each call bounded by the hook
+// timeout. It doesn't follow redirects, which would turn the POST into a GET
+// without the step and let the hook pass on the redirect target's response.
+var stepWebhookClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// stepHookRequest is the JSON payload posted to step hook webhooks
+type stepHookRequest struct {
+	Namespace string                          `json:"namespace"`
+	Name      string                          `json:"name"`
+	Hook      string                          `json:"hook"`
+	Stage     gatewaycdv1alpha1.StepHookStage `json:"stage"`
+	Step      int32                           `json:"step"`
+	Weight    int32                           `json:"weight"`
+	Attempt   int32                           `json:"attempt"`
+}
+
+// runStepHooks runs the hooks of a stage of the current step one at a time,
+// in spec order, and applies their failure policies. It reports whether the
+// rollout must wait with the returned result, because a hook is running or
+// retried, or a failed hook rolled the canary back.
+func (r *CanaryDeploymentReconciler) runStepHooks(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, stage gatewaycdv1alpha1.StepHookStage) (ctrl.Result, bool, error) {
+	step := canary.Status.CurrentStep
+	hooks := canary.Spec.TrafficSplit[step].PreStep
+	if stage == gatewaycdv1alpha1.StepHookStagePostStep {
+		hooks = canary.Spec.TrafficSplit[step].PostStep
+	}
+	if len(hooks) == 0 {
+		return ctrl.Result{}, false, nil
+	}
+	pruneStepHooks(canary)
+
+	for _, hook := range hooks {
+		status := stepHookStatus(canary, stage, hook.Name)
+		if status.Phase == gatewaycdv1alpha1.HookPhaseSucceeded || status.Phase == gatewaycdv1alpha1.HookPhaseFailed {
+			continue
+		}
+
+		done, failure, err := r.checkStepHook(ctx, canary, hook, status)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Failed to run step hook", "hook", hook.Name)
+			canary.Status.Message = fmt.Sprintf("Failed to run %s hook %s of step %d: %v", stage, hook.Name, step+1, err)
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, true, err
+			}
+			return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, true, nil
+		}
+		if !done {
+			canary.Status.Message = fmt.Sprintf("Waiting for %s hook %s of step %d to complete", stage, hook.Name, step+1)
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, true, err
+			}
+			return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, true, nil
+		}
+		if failure == "" {
+			status.Phase = gatewaycdv1alpha1.HookPhaseSucceeded
+			status.Message = ""
+			status.CompletedTime = &metav1.Time{Time: time.Now()}
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, true, err
+			}
+			r.event(canary, EventReasonHookSucceeded, "%s hook %s of step %d succeeded", stage, hook.Name, step+1)
+			continue
+		}
+
+		status.Message = failure
+		switch hook.FailurePolicy {
+		case gatewaycdv1alpha1.StepHookFailurePolicyIgnore:
+			status.Phase = gatewaycdv1alpha1.HookPhaseFailed
+			status.CompletedTime = &metav1.Time{Time: time.Now()}
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, true, err
+			}
+			r.warning(canary, EventReasonHookFailed, "%s hook %s of step %d failed, ignoring: %s", stage, hook.Name, step+1, failure)
+			continue
+		case gatewaycdv1alpha1.StepHookFailurePolicyRetry:
+			if status.Attempts <= stepHookRetries(hook) {
+				// A nil start time starts the next attempt
+				status.StartedTime = nil
+				status.JobName = ""
+				canary.Status.Message = fmt.Sprintf("%s hook %s of step %d failed (attempt %d of %d), retrying",
+					stage, hook.Name, step+1, status.Attempts, stepHookRetries(hook)+1)
+				if err := r.updateStatus(ctx, canary); err != nil {
+					return ctrl.Result{}, true, err
+				}
+				r.warning(canary, EventReasonHookFailed, "%s: %s", canary.Status.Message, failure)
+				return ctrl.Result{RequeueAfter: r.pollInterval(canary)}, true, nil
+			}
+		}
+
+		status.Phase = gatewaycdv1alpha1.HookPhaseFailed
+		status.CompletedTime = &metav1.Time{Time: time.Now()}
+		canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+		canary.Status.RollbackReason = fmt.Sprintf("%s hook %s of step %d failed: %s", stage, hook.Name, step+1, failure)
+		canary.Status.Message = fmt.Sprintf("%s, rolling back", canary.Status.RollbackReason)
+		canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		r.warning(canary, EventReasonHookFailed, "%s hook %s of step %d failed: %s", stage, hook.Name, step+1, failure)
+		return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, true, nil
+	}
+	return ctrl.Result{}, false, nil
+}
+
+// checkStepHook starts the next attempt of a hook, or checks on the Job of a
+// running attempt. It reports whether the attempt is done and, when it is,
+// its failure. Webhooks and expressions complete when they start.
+func (r *CanaryDeploymentReconciler) checkStepHook(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment,
+	hook gatewaycdv1alpha1.StepHook, status *gatewaycdv1alpha1.StepHookStatus) (bool, string, error) {
+	if status.StartedTime == nil {
+		status.Attempts++
+		status.StartedTime = &metav1.Time{Time: time.Now()}
+		var failure string
+		var err error
+		switch {
+		case hook.Job != nil:
+			err = r.startStepHookJob(ctx, canary, hook, status)
+		case hook.Webhook != nil:
+			failure, err = r.callStepWebhook(ctx, canary, hook, status)
+		default:
+			failure, err = r.evalStepExpression(ctx, canary, hook)
+		}
+		if err != nil {
+			// The attempt didn't start, make it again on the next reconcile
+			status.Attempts--
+			status.StartedTime = nil
+			return false, "", err
+		}
+		return hook.Job == nil, failure, nil
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: status.JobName, Namespace: canary.Namespace}, job)
+	if apierrors.IsNotFound(err) {
+		return true, fmt.Sprintf("Job %s was deleted", status.JobName), nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("failed to get Job %s: %w", status.JobName, err)
+	}
+	if jobCondition(job, batchv1.JobComplete) {
+		return true, "", nil
+	}
+	if jobCondition(job, batchv1.JobFailed) {
+		return true, jobFailureMessage(job), nil
+	}
+	if timeout := stepHookTimeout(hook, defaultHookTimeout); time.Since(status.StartedTime.Time) > timeout {
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return false, "", fmt.Errorf("failed to delete timed out Job %s: %w", job.Name, err)
+		}
+		return true, fmt.Sprintf("Job %s did not complete within %s", job.Name, timeout), nil
+	}
+	return false, "", nil
+}
+
+// startStepHookJob creates the Job of an attempt of a hook, owned by the canary
+func (r *CanaryDeploymentReconciler) startStepHookJob(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment,
+	hook gatewaycdv1alpha1.StepHook, status *gatewaycdv1alpha1.StepHookStatus) error {
+	job := &batchv1.Job{
+		ObjectMeta: *hook.Job.ObjectMeta.DeepCopy(),
+		Spec:       *hook.Job.Spec.DeepCopy(),
+	}
+	job.Name = stepHookJobName(canary, hook, status)
+	job.Namespace = canary.Namespace
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+	job.Labels[labelCanary] = canary.Name
+	job.Labels[labelHook] = hook.Name
+	if job.Spec.Template.Spec.RestartPolicy == "" {
+		job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	}
+	if err := controllerutil.SetControllerReference(canary, job, r.Scheme); err != nil {
+		return err
+	}
+
+	if err := r.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create Job %s for hook %s: %w", job.Name, hook.Name, err)
+	}
+	status.JobName = job.Name
+	r.event(canary, EventReasonHookStarted, "Started %s hook %s of step %d as Job %s", status.Stage, hook.Name, status.Step+1, job.Name)
+	return nil
+}
+
+// callStepWebhook posts the step to the webhook of a hook and returns the
+// failure of a non-2xx response or an unreachable webhook
+func (r *CanaryDeploymentReconciler) callStepWebhook(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment,
+	hook gatewaycdv1alpha1.StepHook, status *gatewaycdv1alpha1.StepHookStatus) (string, error) {
+	url, err := r.stepWebhookURL(ctx, canary, hook.Webhook)
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(stepHookRequest{
+		Namespace: canary.Namespace,
+		Name:      canary.Name,
+		Hook:      hook.Name,
+		Stage:     status.Stage,
+		Step:      status.Step + 1,
+		Weight:    canary.Spec.TrafficSplit[status.Step].Weight,
+		Attempt:   status.Attempts,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, stepHookTimeout(hook, defaultStepWebhookTimeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := stepWebhookClient.Do(req)
+	if err != nil {
+		return fmt.Sprintf("webhook call failed: %v", err), nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		failure := fmt.Sprintf("webhook returned status %d", resp.StatusCode)
+		if detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512)); len(bytes.TrimSpace(detail)) > 0 {
+			failure += ": " + strings.TrimSpace(string(detail))
+		}
+		return failure, nil
+	}
+	return "", nil
+}
+
+// stepWebhookURL resolves the URL of a webhook, reading it from a Secret if referenced
+func (r *CanaryDeploymentReconciler) stepWebhookURL(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, webhook *gatewaycdv1alpha1.StepWebhook) (string, error) {
+	ref := webhook.URLSecretRef
+	if ref == nil {
+		return webhook.URL, nil
+	}
+	reader := client.Reader(r.Client)
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: canary.Namespace, Name: ref.Name}, secret); err != nil {
+		return "", fmt.Errorf("failed to get secret %s/%s: %w", canary.Namespace, ref.Name, err)
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s has no key %q", canary.Namespace, ref.Name, ref.Key)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// evalStepExpression evaluates the expression of a hook over the rollout and
+// returns a failure unless it is true
+func (r *CanaryDeploymentReconciler) evalStepExpression(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, hook gatewaycdv1alpha1.StepHook) (string, error) {
+	vars, err := r.stepExpressionVars(ctx, canary)
+	if err != nil {
+		return "", err
+	}
+	ok, err := expression.Eval(hook.Expression, vars)
+	if err != nil {
+		return fmt.Sprintf("expression %q could not be evaluated: %v", hook.Expression, err), nil
+	}
+	if !ok {
+		return fmt.Sprintf("expression %q is false", hook.Expression), nil
+	}
+	return "", nil
+}
+
+// stepExpressionVars are the variables of step hook expressions
+func (r *CanaryDeploymentReconciler) stepExpressionVars(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (map[string]interface{}, error) {
+	step := canary.Status.CurrentStep
+	vars := map[string]interface{}{
+		"step":           step + 1,
+		"weight":         canary.Spec.TrafficSplit[step].Weight,
+		"canaryReplicas": canary.Status.CanaryReplicas,
+		"readyReplicas":  int32(0),
+		"successRate":    float64(0),
+		"latency":        int32(0),
+	}
+	if run := canary.Status.AnalysisRun; run != nil {
+		vars["successRate"] = run.SuccessRate
+		vars["latency"] = run.AverageLatency
+	}
+	if canary.Spec.TargetRef.Kind == "Deployment" {
+		deployment, err := r.targetDeployment(ctx, canary)
+		if err != nil {
+			return nil, err
+		}
+		vars["readyReplicas"] = deployment.Status.ReadyReplicas
+	}
+	return vars, nil
+}
+
+// stepHookStatus returns the status of a hook at the current step, adding it
+// when the hook hasn't run yet
+func stepHookStatus(canary *gatewaycdv1alpha1.CanaryDeployment, stage gatewaycdv1alpha1.StepHookStage, name string) *gatewaycdv1alpha1.StepHookStatus {
+	for i := range canary.Status.StepHooks {
+		status := &canary.Status.StepHooks[i]
+		if status.Stage == stage && status.Name == name {
+			return status
+		}
+	}
+	canary.Status.StepHooks = append(canary.Status.StepHooks, gatewaycdv1alpha1.StepHookStatus{
+		Name:  name,
+		Step:  canary.Status.CurrentStep,
+		Stage: stage,
+		Phase: gatewaycdv1alpha1.HookPhaseRunning,
+	})
+	return &canary.Status.StepHooks[len(canary.Status.StepHooks)-1]
+}
+
+// pruneStepHooks drops the hook statuses of earlier steps, so the status only
+// grows with the hooks of one step
+func pruneStepHooks(canary *gatewaycdv1alpha1.CanaryDeployment) {
+	kept := canary.Status.StepHooks[:0]
+	for _, status := range canary.Status.StepHooks {
+		if status.Step == canary.Status.CurrentStep {
+			kept = append(kept, status)
+		}
+	}
+	canary.Status.StepHooks = kept
+}
+
+// stepHookJobName names the Job of an attempt of a hook after the canary,
+// the hook, its step and stage, the attempt and the start of the rollout
+func stepHookJobName(canary *gatewaycdv1alpha1.CanaryDeployment, hook gatewaycdv1alpha1.StepHook, status *gatewaycdv1alpha1.StepHookStatus) string {
+	rollout := "0"
+	if canary.Status.StartedTime != nil {
+		rollout = strconv.FormatInt(canary.Status.StartedTime.Unix(), 36)
+	}
+	suffix := fmt.Sprintf("%s%d-%d-%s", strings.ToLower(string(status.Stage)), status.Step+1, status.Attempts, rollout)
+	prefix := fmt.Sprintf("%s-%s", canary.Name, hook.Name)
+	if limit := 63 - len(suffix) - 1; len(prefix) > limit {
+		prefix = prefix[:limit]
+	}
+	return fmt.Sprintf("%s-%s", prefix, suffix)
+}
+
+// stepHookTimeout parses the hook timeout, falling back to fallback for
+// values the webhook would have rejected
+func stepHookTimeout(hook gatewaycdv1alpha1.StepHook, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(hook.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+// stepHookRetries is how many times the retry policy runs a failed hook again
+func stepHookRetries(hook gatewaycdv1alpha1.StepHook) int32 {
+	if hook.Retries > 0 {
+		return hook.Retries
+	}
+	return defaultStepHookRetries
+}
@@ -0,0 +1,353 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// newStepHookCanary returns a Progressing canary at its first step, whose
+// pre-step hooks are hooks
+func newStepHookCanary(hooks ...gatewaycdv1alpha1.StepHook) *gatewaycdv1alpha1.CanaryDeployment {
+	return &gatewaycdv1alpha1.CanaryDeployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout"},
+		Spec: gatewaycdv1alpha1.CanaryDeploymentSpec{
+			TrafficSplit: []gatewaycdv1alpha1.TrafficSplitStep{
+				{Weight: 10, PreStep: hooks},
+				{Weight: 100},
+			},
+		},
+		Status: gatewaycdv1alpha1.CanaryDeploymentStatus{
+			Phase:        gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing,
+			CanaryWeight: 10,
+			StableWeight: 90,
+		},
+	}
+}
+
+// newTestReconciler returns a reconciler on a fake client holding canary
+func newTestReconciler(t *testing.T, canary *gatewaycdv1alpha1.CanaryDeployment) (*CanaryDeploymentReconciler, *record.FakeRecorder) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := gatewaycdv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cl := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(canary).
+		WithStatusSubresource(&gatewaycdv1alpha1.CanaryDeployment{}).
+		Build()
+	recorder := record.NewFakeRecorder(100)
+	return &CanaryDeploymentReconciler{Client: cl, Scheme: scheme, Recorder: recorder, APIReader: cl}, recorder
+}
+
+// stepWebhookServer serves a step hook webhook answering with the statuses
+// in turn, repeating the last one, and records the requests it received
+func stepWebhookServer(t *testing.T, statuses ...int) (*httptest.Server, *[]stepHookRequest) {
+	t.Helper()
+	var received []stepHookRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload stepHookRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode webhook payload: %v", err)
+		}
+		received = append(received, payload)
+		status := statuses[len(statuses)-1]
+		if len(received) <= len(statuses) {
+			status = statuses[len(received)-1]
+		}
+		if status >= 300 && status < 400 {
+			w.Header().Set("Location", "/elsewhere")
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+// drainEvents returns the events recorded so far
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestRunStepHooksFailurePolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       gatewaycdv1alpha1.StepHookFailurePolicy
+		status       int
+		wantWaiting  bool
+		wantPhase    gatewaycdv1alpha1.CanaryDeploymentPhase
+		wantHook     gatewaycdv1alpha1.HookPhase
+		wantEvent    string
+		wantRollback string
+	}{
+		{
+			name:      "success",
+			status:    http.StatusOK,
+			wantPhase: gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing,
+			wantHook:  gatewaycdv1alpha1.HookPhaseSucceeded,
+			wantEvent: "Normal HookSucceeded PreStep hook notify of step 1 succeeded",
+		},
+		{
+			name:         "fail by default",
+			status:       http.StatusInternalServerError,
+			wantWaiting:  true,
+			wantPhase:    gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack,
+			wantHook:     gatewaycdv1alpha1.HookPhaseFailed,
+			wantEvent:    "Warning HookFailed PreStep hook notify of step 1 failed: webhook returned status 500",
+			wantRollback: "PreStep hook notify of step 1 failed: webhook returned status 500",
+		},
+		{
+			name:      "ignore",
+			policy:    gatewaycdv1alpha1.StepHookFailurePolicyIgnore,
+			status:    http.StatusServiceUnavailable,
+			wantPhase: gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing,
+			wantHook:  gatewaycdv1alpha1.HookPhaseFailed,
+			wantEvent: "Warning HookFailed PreStep hook notify of step 1 failed, ignoring: webhook returned status 503",
+		},
+		{
+			name:         "redirect is a failure",
+			status:       http.StatusFound,
+			wantWaiting:  true,
+			wantPhase:    gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack,
+			wantHook:     gatewaycdv1alpha1.HookPhaseFailed,
+			wantEvent:    "Warning HookFailed PreStep hook notify of step 1 failed: webhook returned status 302",
+			wantRollback: "PreStep hook notify of step 1 failed: webhook returned status 302",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, received := stepWebhookServer(t, tt.status)
+			canary := newStepHookCanary(gatewaycdv1alpha1.StepHook{
+				Name:          "notify",
+				Webhook:       &gatewaycdv1alpha1.StepWebhook{URL: server.URL},
+				FailurePolicy: tt.policy,
+			})
+			r, recorder := newTestReconciler(t, canary)
+
+			_, waiting, err := r.runStepHooks(context.Background(), canary, gatewaycdv1alpha1.StepHookStagePreStep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if waiting != tt.wantWaiting {
+				t.Errorf("waiting = %v, want %v", waiting, tt.wantWaiting)
+			}
+			if canary.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %s, want %s", canary.Status.Phase, tt.wantPhase)
+			}
+			if canary.Status.RollbackReason != tt.wantRollback {
+				t.Errorf("rollback reason = %q, want %q", canary.Status.RollbackReason, tt.wantRollback)
+			}
+			if len(canary.Status.StepHooks) != 1 {
+				t.Fatalf("got %d hook statuses, want 1", len(canary.Status.StepHooks))
+			}
+			if hook := canary.Status.StepHooks[0]; hook.Phase != tt.wantHook || hook.Attempts != 1 {
+				t.Errorf("hook status = %s after %d attempts, want %s after 1", hook.Phase, hook.Attempts, tt.wantHook)
+			}
+			if len(*received) != 1 {
+				t.Errorf("webhook called %d times, want 1", len(*received))
+			}
+			if events := drainEvents(recorder); len(events) != 1 || !strings.HasPrefix(events[0], tt.wantEvent) {
+				t.Errorf("events = %q, want one starting with %q", events, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestRunStepHooksRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int32
+		statuses     []int
+		wantAttempts int32
+		wantHook     gatewaycdv1alpha1.HookPhase
+		wantPhase    gatewaycdv1alpha1.CanaryDeploymentPhase
+	}{
+		{
+			name:         "succeeds on a retry",
+			retries:      2,
+			statuses:     []int{http.StatusInternalServerError, http.StatusOK},
+			wantAttempts: 2,
+			wantHook:     gatewaycdv1alpha1.HookPhaseSucceeded,
+			wantPhase:    gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing,
+		},
+		{
+			name:         "rolls back after its retries",
+			retries:      2,
+			statuses:     []int{http.StatusInternalServerError},
+			wantAttempts: 3,
+			wantHook:     gatewaycdv1alpha1.HookPhaseFailed,
+			wantPhase:    gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack,
+		},
+		{
+			name:         "defaults to three retries",
+			statuses:     []int{http.StatusInternalServerError},
+			wantAttempts: defaultStepHookRetries + 1,
+			wantHook:     gatewaycdv1alpha1.HookPhaseFailed,
+			wantPhase:    gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, received := stepWebhookServer(t, tt.statuses...)
+			canary := newStepHookCanary(gatewaycdv1alpha1.StepHook{
+				Name:          "smoke",
+				Webhook:       &gatewaycdv1alpha1.StepWebhook{URL: server.URL},
+				FailurePolicy: gatewaycdv1alpha1.StepHookFailurePolicyRetry,
+				Retries:       tt.retries,
+			})
+			r, _ := newTestReconciler(t, canary)
+
+			// Each reconcile makes one attempt until the hook finishes
+			for i := 0; i < 10 && canary.Status.Phase == gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing; i++ {
+				_, waiting, err := r.runStepHooks(context.Background(), canary, gatewaycdv1alpha1.StepHookStagePreStep)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !waiting {
+					break
+				}
+			}
+
+			hook := canary.Status.StepHooks[0]
+			if hook.Attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", hook.Attempts, tt.wantAttempts)
+			}
+			if hook.Phase != tt.wantHook {
+				t.Errorf("hook phase = %s, want %s", hook.Phase, tt.wantHook)
+			}
+			if canary.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %s, want %s", canary.Status.Phase, tt.wantPhase)
+			}
+			if int32(len(*received)) != tt.wantAttempts {
+				t.Fatalf("webhook called %d times, want %d", len(*received), tt.wantAttempts)
+			}
+			for i, payload := range *received {
+				if payload.Attempt != int32(i+1) || payload.Step != 1 || payload.Weight != 10 || payload.Hook != "smoke" {
+					t.Errorf("request %d = %+v, want attempt %d of hook smoke at step 1 with weight 10", i, payload, i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestRunStepHooksExpression(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantHook   gatewaycdv1alpha1.HookPhase
+		wantPhase  gatewaycdv1alpha1.CanaryDeploymentPhase
+	}{
+		{"true", "weight == 10 && step == 1", gatewaycdv1alpha1.HookPhaseSucceeded, gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing},
+		{"false", "weight > 50", gatewaycdv1alpha1.HookPhaseFailed, gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack},
+		{"invalid", "unknown > 1", gatewaycdv1alpha1.HookPhaseFailed, gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canary := newStepHookCanary(gatewaycdv1alpha1.StepHook{Name: "gate", Expression: tt.expression})
+			r, _ := newTestReconciler(t, canary)
+
+			if _, _, err := r.runStepHooks(context.Background(), canary, gatewaycdv1alpha1.StepHookStagePreStep); err != nil {
+				t.Fatal(err)
+			}
+			if hook := canary.Status.StepHooks[0]; hook.Phase != tt.wantHook {
+				t.Errorf("hook phase = %s, want %s: %s", hook.Phase, tt.wantHook, hook.Message)
+			}
+			if canary.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %s, want %s", canary.Status.Phase, tt.wantPhase)
+			}
+		})
+	}
+}
+
+func TestStepHookJobName(t *testing.T) {
+	started := metav1.NewTime(time.Unix(1700000000, 0))
+	tests := []struct {
+		name     string
+		canary   string
+		hook     string
+		stage    gatewaycdv1alpha1.StepHookStage
+		step     int32
+		attempts int32
+		started  *metav1.Time
+		want     string
+	}{
+		{
+			name:     "short",
+			canary:   "checkout",
+			hook:     "smoke",
+			stage:    gatewaycdv1alpha1.StepHookStagePreStep,
+			step:     0,
+			attempts: 1,
+			started:  &started,
+			want:     "checkout-smoke-prestep1-1-s44we8",
+		},
+		{
+			name:     "post-step retry",
+			canary:   "checkout",
+			hook:     "smoke",
+			stage:    gatewaycdv1alpha1.StepHookStagePostStep,
+			step:     2,
+			attempts: 3,
+			started:  &started,
+			want:     "checkout-smoke-poststep3-3-s44we8",
+		},
+		{
+			name:     "rollout not started",
+			canary:   "checkout",
+			hook:     "smoke",
+			stage:    gatewaycdv1alpha1.StepHookStagePreStep,
+			attempts: 1,
+			want:     "checkout-smoke-prestep1-1-0",
+		},
+		{
+			name:     "long names are truncated before the suffix",
+			canary:   strings.Repeat("c", 40),
+			hook:     strings.Repeat("h", 30),
+			stage:    gatewaycdv1alpha1.StepHookStagePostStep,
+			step:     11,
+			attempts: 4,
+			started:  &started,
+			want:     strings.Repeat("c", 40) + "-hh-poststep12-4-s44we8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canary := &gatewaycdv1alpha1.CanaryDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: tt.canary},
+				Status:     gatewaycdv1alpha1.CanaryDeploymentStatus{StartedTime: tt.started},
+			}
+			status := &gatewaycdv1alpha1.StepHookStatus{Stage: tt.stage, Step: tt.step, Attempts: tt.attempts}
+			got := stepHookJobName(canary, gatewaycdv1alpha1.StepHook{Name: tt.hook}, status)
+			if got != tt.want {
+				t.Errorf("stepHookJobName() = %q, want %q", got, tt.want)
+			}
+			if len(got) > 63 {
+				t.Errorf("stepHookJobName() is %d characters, longer than 63", len(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// actorController is the actor of the step transitions the controller makes on its own
+const actorController = "controller"
+
+// recordStepTransition appends the rollout entering its current step to
+// status.history. The analysis run is summarized when it completed after the
+// previous transition, so a step without analysis doesn't repeat an older run.
+func recordStepTransition(canary *gatewaycdv1alpha1.CanaryDeployment, actor string) {
+	step := canary.Status.CurrentStep
+	transition := gatewaycdv1alpha1.StepTransition{
+		Step:  step,
+		Time:  metav1.Now(),
+		Actor: actor,
+	}
+	if int(step) < len(canary.Spec.TrafficSplit) {
+		transition.Weight = canary.Spec.TrafficSplit[step].Weight
+	}
+	if history := canary.Status.History; len(history) > 0 {
+		run := canary.Status.AnalysisRun
+		if run != nil && run.CompletedAt != nil && run.CompletedAt.After(history[len(history)-1].Time.Time) {
+			transition.Analysis = analysisSummary(run)
+		}
+	}
+	canary.Status.History = append(canary.Status.History, transition)
+}
+
+// analysisSummary describes an analysis run in one line, e.g.
+// "Successful: success rate 99.50%, latency 120ms, 2/2 metrics passed"
+func analysisSummary(run *gatewaycdv1alpha1.AnalysisRunStatus) string {
+	var parts []string
+	if run.SuccessRate > 0 {
+		parts = append(parts, fmt.Sprintf("success rate %.2f%%", run.SuccessRate*100))
+	}
+	if run.AverageLatency > 0 {
+		parts = append(parts, fmt.Sprintf("latency %dms", run.AverageLatency))
+	}
+	if len(run.MetricResults) > 0 {
+		passed := 0
+		for _, result := range run.MetricResults {
+			if result.Passed {
+				passed++
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%d/%d metrics passed", passed, len(run.MetricResults)))
+	}
+	if len(parts) == 0 {
+		return run.Phase
+	}
+	return run.Phase + ": " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/metrics"
+)
+
+// defaultTimeSliceCycles is the number of exposures when the canary doesn't set it
+const defaultTimeSliceCycles = 3
+
+// timeSliceCycles is the number of exposures before ramping
+func timeSliceCycles(canary *gatewaycdv1alpha1.CanaryDeployment) int32 {
+	if canary.Spec.TimeSlice.Cycles > 0 {
+		return canary.Spec.TimeSlice.Cycles
+	}
+	return defaultTimeSliceCycles
+}
+
+// timeSliceWindow parses an exposure or pause duration, falling back to a
+// minute for values the webhook would have rejected
+func timeSliceWindow(duration string) time.Duration {
+	if d, err := time.ParseDuration(duration); err == nil && d > 0 {
+		return d
+	}
+	return time.Minute
+}
+
+// handleTimeSlicing alternates the canary between short exposures at the time
+// slice weight and pauses without traffic, analysing each exposure, before the
+// first traffic split step shifts sustained traffic
+func (r *CanaryDeploymentReconciler) handleTimeSlicing(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	slice := canary.Spec.TimeSlice
+	onDuration := timeSliceWindow(slice.OnDuration)
+	offDuration := timeSliceWindow(slice.OffDuration)
+
+	// Wait for the current pause to end, then start the next exposure
+	if !canary.Status.TimeSliceExposed {
+		if start := canary.Status.TimeSliceWindowStart; start != nil {
+			if remaining := offDuration - time.Since(start.Time); remaining > 0 {
+				return ctrl.Result{RequeueAfter: remaining}, nil
+			}
+		}
+		return r.exposeTimeSlice(ctx, canary, onDuration)
+	}
+
+	// Keep the canary exposed until the exposure has lasted its duration
+	if remaining := onDuration - time.Since(canary.Status.TimeSliceWindowStart.Time); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	// Analyse the exposure before withdrawing traffic
+	if analysisEnabled(canary) {
+		passed, err := r.runAnalysis(ctx, canary)
+		if errors.Is(err, metrics.ErrProviderUnavailable) {
+			return r.handleTimeSliceProviderUnavailable(ctx, canary, offDuration)
+		}
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Time slice analysis failed")
+			if analysisErrorLimitReached(canary) {
+				return r.rollbackTimeSlice(ctx, canary, fmt.Sprintf("Time slice analysis could not be completed %d times in a row",
+					canary.Status.ConsecutiveErrors))
+			}
+			canary.Status.Message = fmt.Sprintf("Time slice analysis failed: %v", err)
+			if err := r.updateStatus(ctx, canary); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.warning(canary, EventReasonAnalysisError, "Time slice analysis could not be completed: %v", err)
+			return ctrl.Result{RequeueAfter: r.analysisErrorBackoff(canary)}, nil
+		}
+
+		setAnalysisCondition(canary, passed, "TimeSliceAnalysis", fmt.Sprintf("Analysis of time slice exposure %d: %s",
+			canary.Status.TimeSliceCycle, analysisOutcome(passed)))
+		if !passed && analysisFailureLimitReached(canary) {
+			return r.rollbackTimeSlice(ctx, canary, fmt.Sprintf("Time slice exposure %d failed analysis", canary.Status.TimeSliceCycle))
+		}
+		if !passed {
+			r.warning(canary, EventReasonAnalysisRetrying, "Time slice exposure %d failed analysis (%d of %d consecutive failures): %s",
+				canary.Status.TimeSliceCycle, canary.Status.ConsecutiveFailures, analysisFailureLimit(canary), failingMetricsSummary(canary))
+			return r.withdrawTimeSlice(ctx, canary, offDuration, true)
+		}
+	} else {
+		markAnalysisSkipped(ctx, canary, analysisSkipReason(canary), fmt.Sprintf("Time slice exposure %d ended without analysis",
+			canary.Status.TimeSliceCycle))
+	}
+
+	if canary.Status.TimeSliceCycle >= timeSliceCycles(canary) {
+		return r.completeTimeSlice(ctx, canary)
+	}
+	return r.withdrawTimeSlice(ctx, canary, offDuration, false)
+}
+
+// exposeTimeSlice starts the next exposure by routing the time slice weight to the canary
+func (r *CanaryDeploymentReconciler) exposeTimeSlice(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, onDuration time.Duration) (ctrl.Result, error) {
+	weight := canary.Spec.TimeSlice.Weight
+	if err := r.GatewayManager.UpdateTrafficSplit(ctx, canary, int(weight)); err != nil {
+		return r.retryFailure(ctx, canary, EventReasonTrafficUpdateFailed, "Failed to expose canary", err)
+	}
+
+	canary.Status.RetryCount = 0
+	canary.Status.TimeSliceCycle++
+	canary.Status.TimeSliceExposed = true
+	canary.Status.TimeSliceWindowStart = &metav1.Time{Time: time.Now()}
+	canary.Status.CanaryWeight = weight
+	canary.Status.StableWeight = 100 - weight
+	canary.Status.Message = fmt.Sprintf("Time slice exposure %d of %d: %d%% canary for %s",
+		canary.Status.TimeSliceCycle, timeSliceCycles(canary), weight, onDuration)
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.event(canary, EventReasonTimeSliceExposed, "%s", canary.Status.Message)
+	return ctrl.Result{RequeueAfter: onDuration}, nil
+}
+
+// withdrawTimeSlice ends an exposure by routing all traffic back to stable
+// until the next one. A repeated exposure doesn't count towards the cycles.
+func (r *CanaryDeploymentReconciler) withdrawTimeSlice(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, offDuration time.Duration, repeat bool) (ctrl.Result, error) {
+	if err := r.GatewayManager.UpdateTrafficSplit(ctx, canary, 0); err != nil {
+		return r.retryFailure(ctx, canary, EventReasonTrafficUpdateFailed, "Failed to withdraw canary traffic", err)
+	}
+
+	canary.Status.RetryCount = 0
+	canary.Status.TimeSliceExposed = false
+	canary.Status.TimeSliceWindowStart = &metav1.Time{Time: time.Now()}
+	canary.Status.CanaryWeight = 0
+	canary.Status.StableWeight = 100
+	canary.Status.Message = fmt.Sprintf("Time slice exposure %d of %d ended, next exposure in %s",
+		canary.Status.TimeSliceCycle, timeSliceCycles(canary), offDuration)
+	if repeat {
+		canary.Status.Message = fmt.Sprintf("Time slice exposure %d of %d failed analysis, repeating it in %s",
+			canary.Status.TimeSliceCycle, timeSliceCycles(canary), offDuration)
+		canary.Status.TimeSliceCycle--
+	}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.event(canary, EventReasonTimeSliceWithdrawn, "%s", canary.Status.Message)
+	return ctrl.Result{RequeueAfter: offDuration}, nil
+}
+
+// handleTimeSliceProviderUnavailable applies the provider unavailable policy at
+// the end of an exposure. Pause is treated as Retry since the canary only
+// serves a short slice of traffic.
+func (r *CanaryDeploymentReconciler) handleTimeSliceProviderUnavailable(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, offDuration time.Duration) (ctrl.Result, error) {
+	r.warning(canary, EventReasonProviderUnavailable, "Metrics provider unavailable during time slicing, applying %q policy",
+		canary.Spec.Analysis.ProviderUnavailablePolicy)
+
+	switch canary.Spec.Analysis.ProviderUnavailablePolicy {
+	case gatewaycdv1alpha1.ProviderUnavailablePolicySkip:
+		markAnalysisSkipped(ctx, canary, "ProviderUnavailable", fmt.Sprintf("Time slice exposure %d ended without analysis, metrics provider unavailable",
+			canary.Status.TimeSliceCycle))
+		if canary.Status.TimeSliceCycle >= timeSliceCycles(canary) {
+			return r.completeTimeSlice(ctx, canary)
+		}
+		return r.withdrawTimeSlice(ctx, canary, offDuration, false)
+	case gatewaycdv1alpha1.ProviderUnavailablePolicyRollback:
+		return r.rollbackTimeSlice(ctx, canary, "Metrics provider unavailable")
+	default:
+		canary.Status.Message = "Metrics provider unavailable, retrying time slice analysis"
+		if err := r.updateStatus(ctx, canary); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.idleInterval()}, nil
+	}
+}
+
+// completeTimeSlice lets the rollout continue with the first traffic split step
+func (r *CanaryDeploymentReconciler) completeTimeSlice(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	canary.Status.TimeSliceCompleted = true
+	canary.Status.TimeSliceExposed = false
+	canary.Status.TimeSliceWindowStart = nil
+	canary.Status.Message = fmt.Sprintf("Completed %d time slice exposures, shifting traffic", canary.Status.TimeSliceCycle)
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.event(canary, EventReasonTimeSliceCompleted, "Completed %d time slice exposures, starting traffic split", canary.Status.TimeSliceCycle)
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+}
+
+// rollbackTimeSlice rolls the canary back before it served sustained traffic
+func (r *CanaryDeploymentReconciler) rollbackTimeSlice(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, reason string) (ctrl.Result, error) {
+	log.FromContext(ctx).Info("Rolling back canary during time slicing", "reason", reason)
+	canary.Status.Phase = gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack
+	canary.Status.Message = fmt.Sprintf("%s, rolling back", reason)
+	canary.Status.RollbackReason = reason
+	canary.Status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	if err := r.updateStatus(ctx, canary); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.warning(canary, EventReasonAnalysisFailed, "%s: %s", reason, failingMetricsSummary(canary))
+	return ctrl.Result{RequeueAfter: r.activeInterval(canary)}, nil
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// expireFinished deletes a Succeeded or Failed canary once its
+// ttlSecondsAfterFinished has passed, and otherwise requeues it for then. The
+// finalizer restores the routes and the resources the controller created are
+// garbage collected with the canary. Canaries generated from a Deployment
+// never expire: the auto-canary controller would recreate the canary at once
+// and roll the same revision out again.
+func (r *CanaryDeploymentReconciler) expireFinished(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (ctrl.Result, error) {
+	ttl := canary.Spec.TTLSecondsAfterFinished
+	if ttl == nil || canary.Labels[labelGeneratedFrom] != "" {
+		return ctrl.Result{}, nil
+	}
+
+	// The canary finished when it entered its terminal phase
+	finished := canary.CreationTimestamp.Time
+	if canary.Status.LastTransitionTime != nil {
+		finished = canary.Status.LastTransitionTime.Time
+	}
+	if remaining := time.Until(finished.Add(time.Duration(*ttl) * time.Second)); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	log.FromContext(ctx).Info("Deleting finished canary after its TTL", "phase", canary.Status.Phase, "ttlSeconds", *ttl)
+	r.event(canary, EventReasonExpired, "Deleting the %s canary %ds after it finished", canary.Status.Phase, *ttl)
+	if err := r.Delete(ctx, canary, client.Preconditions{UID: &canary.UID}); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to delete expired canary: %w", err)
+	}
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+func TestExpireFinished(t *testing.T) {
+	ttl := int32(3600)
+	tests := []struct {
+		name        string
+		ttl         *int32
+		generated   bool
+		created     time.Duration
+		finished    time.Duration
+		wantDeleted bool
+		wantRequeue time.Duration
+	}{
+		{
+			name:     "no TTL",
+			created:  -48 * time.Hour,
+			finished: -24 * time.Hour,
+		},
+		{
+			name:        "TTL not reached",
+			ttl:         &ttl,
+			created:     -48 * time.Hour,
+			finished:    -30 * time.Minute,
+			wantRequeue: 30 * time.Minute,
+		},
+		{
+			name:        "TTL reached",
+			ttl:         &ttl,
+			created:     -48 * time.Hour,
+			finished:    -2 * time.Hour,
+			wantDeleted: true,
+		},
+		{
+			name:        "finished when created without a transition",
+			ttl:         &ttl,
+			created:     -2 * time.Hour,
+			wantDeleted: true,
+		},
+		{
+			name:      "generated from a Deployment",
+			ttl:       &ttl,
+			generated: true,
+			created:   -48 * time.Hour,
+			finished:  -2 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			canary := &gatewaycdv1alpha1.CanaryDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:         "shop",
+					Name:              "checkout",
+					UID:               "1234",
+					CreationTimestamp: metav1.NewTime(now.Add(tt.created)),
+				},
+				Spec: gatewaycdv1alpha1.CanaryDeploymentSpec{TTLSecondsAfterFinished: tt.ttl},
+				Status: gatewaycdv1alpha1.CanaryDeploymentStatus{
+					Phase: gatewaycdv1alpha1.CanaryDeploymentPhaseSucceeded,
+				},
+			}
+			if tt.generated {
+				canary.Labels = map[string]string{labelGeneratedFrom: "checkout"}
+			}
+			if tt.finished != 0 {
+				canary.Status.LastTransitionTime = &metav1.Time{Time: now.Add(tt.finished)}
+			}
+			r, recorder := newTestReconciler(t, canary)
+
+			result, err := r.expireFinished(context.Background(), canary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := result.RequeueAfter - tt.wantRequeue; diff > time.Second || diff < -time.Second {
+				t.Errorf("requeue after %s, want %s", result.RequeueAfter, tt.wantRequeue)
+			}
+
+			err = r.Get(context.Background(), client.ObjectKeyFromObject(canary), &gatewaycdv1alpha1.CanaryDeployment{})
+			if deleted := apierrors.IsNotFound(err); deleted != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %v (get error %v)", deleted, tt.wantDeleted, err)
+			}
+			events := drainEvents(recorder)
+			if tt.wantDeleted && (len(events) != 1 || !strings.Contains(events[0], EventReasonExpired)) {
+				t.Errorf("events = %q, want one %s event", events, EventReasonExpired)
+			}
+			if !tt.wantDeleted && len(events) > 0 {
+				t.Errorf("events = %q, want none", events)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/gateway"
+)
+
+const (
+	// httpRouteIndex indexes canaries by the namespace/name of the HTTPRoutes
+	// they manage
+	httpRouteIndex = "spec.gateway.httpRoutes"
+	// targetDeploymentIndex indexes canaries by the name of their target Deployment
+	targetDeploymentIndex = "spec.targetRef.deployment"
+	// routeIndex indexes canaries by the kind/namespace/name of every route
+	// they manage, for the guard against parallel rollouts on a route
+	routeIndex = "spec.gateway.routes"
+)
+
+// indexFields registers the field indexes the route and Deployment watches
+// map events to canaries by, and the route index of the parallel rollout guard
+func indexFields(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &gatewaycdv1alpha1.CanaryDeployment{}, httpRouteIndex, func(obj client.Object) []string {
+		return gateway.ManagedHTTPRoutes(obj.(*gatewaycdv1alpha1.CanaryDeployment))
+	}); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, &gatewaycdv1alpha1.CanaryDeployment{}, routeIndex, func(obj client.Object) []string {
+		return gateway.ManagedRoutes(obj.(*gatewaycdv1alpha1.CanaryDeployment))
+	}); err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, &gatewaycdv1alpha1.CanaryDeployment{}, targetDeploymentIndex, func(obj client.Object) []string {
+		canary := obj.(*gatewaycdv1alpha1.CanaryDeployment)
+		if canary.Spec.TargetRef.Kind != "Deployment" {
+			return nil
+		}
+		return []string{canary.Spec.TargetRef.Name}
+	})
+}
+
+// httpRouteRequests maps an HTTPRoute to the canaries managing it, so edits
+// made to the route outside the rollout are corrected right away
+func (r *CanaryDeploymentReconciler) httpRouteRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	if _, ok := obj.(*gatewayapi.HTTPRoute); !ok {
+		return nil
+	}
+	var canaries gatewaycdv1alpha1.CanaryDeploymentList
+	if err := r.List(ctx, &canaries, client.MatchingFields{httpRouteIndex: client.ObjectKeyFromObject(obj).String()}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list canaries of HTTPRoute", "httpRoute", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+	return canaryRequests(canaries.Items)
+}
+
+// deploymentRequests maps a Deployment to the canaries targeting it, so a new
+// revision restarts the rollout without waiting for the next requeue
+func (r *CanaryDeploymentReconciler) deploymentRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	if _, ok := obj.(*appsv1.Deployment); !ok {
+		return nil
+	}
+	var canaries gatewaycdv1alpha1.CanaryDeploymentList
+	if err := r.List(ctx, &canaries, client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{targetDeploymentIndex: obj.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list canaries of Deployment", "deployment", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+	return canaryRequests(canaries.Items)
+}
+
+// canaryRequests returns a reconcile request for every canary
+func canaryRequests(canaries []gatewaycdv1alpha1.CanaryDeployment) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, len(canaries))
+	for i := range canaries {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&canaries[i])})
+	}
+	return requests
+}
@@ -0,0 +1,342 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/gateway"
+)
+
+//+kubebuilder:rbac:groups="",resources=events,verbs=list
+
+// Severity ranks how likely a finding blocks the rollout
+type Severity string
+
+const (
+	// SeverityCritical findings block the rollout until fixed
+	SeverityCritical Severity = "Critical"
+	// SeverityWarning findings may slow down or fail the rollout
+	SeverityWarning Severity = "Warning"
+	// SeverityInfo findings explain the rollout's state
+	SeverityInfo Severity = "Info"
+)
+
+// rank orders severities from most to least likely to block
+var rank = map[Severity]int{
+	SeverityCritical: 0,
+	SeverityWarning:  1,
+	SeverityInfo:     2,
+}
+
+const (
+	// eventWindow is how far back warning events are considered
+	eventWindow = time.Hour
+	// stuckAfter is how long a rollout may stay in one phase before it is
+	// reported as stuck
+	stuckAfter = time.Minute * 30
+)
+
+// Finding is a likely blocker of a rollout
+type Finding struct {
+	Severity Severity `json:"severity"`
+	// Check names the inspected component: Phase, Route, Service, Pods,
+	// Provider or Events
+	Check string `json:"check"`
+	// Message describes what was found
+	Message string `json:"message"`
+	// Hint suggests how to unblock the rollout
+	Hint string `json:"hint,omitempty"`
+}
+
+// Report lists the likely blockers of a rollout, most severe first
+type Report struct {
+	Namespace string                                  `json:"namespace"`
+	Name      string                                  `json:"name"`
+	Phase     gatewaycdv1alpha1.CanaryDeploymentPhase `json:"phase"`
+	Message   string                                  `json:"message,omitempty"`
+	Findings  []Finding                               `json:"findings"`
+}
+
+// Diagnose inspects a canary, its routes, Services, pods, metrics provider
+// and recent events and ranks what most likely keeps the rollout from
+// progressing. Checks that cannot read their objects report that instead of
+// failing the diagnosis.
+func Diagnose(ctx context.Context, c client.Client, canary *gatewaycdv1alpha1.CanaryDeployment) *Report {
+	d := &diagnosis{client: c, canary: canary}
+	d.checkPhase()
+	d.checkRoutes(ctx)
+	d.checkServices(ctx)
+	d.checkProvider(ctx)
+	d.checkEvents(ctx)
+
+	sort.SliceStable(d.findings, func(i, j int) bool {
+		return rank[d.findings[i].Severity] < rank[d.findings[j].Severity]
+	})
+	return &Report{
+		Namespace: canary.Namespace,
+		Name:      canary.Name,
+		Phase:     canary.Status.Phase,
+		Message:   canary.Status.Message,
+		Findings:  d.findings,
+	}
+}
+
+// diagnosis collects the findings of one canary
+type diagnosis struct {
+	client   client.Client
+	canary   *gatewaycdv1alpha1.CanaryDeployment
+	findings []Finding
+}
+
+// add records a finding
+func (d *diagnosis) add(severity Severity, check, hint, messageFmt string, args ...interface{}) {
+	d.findings = append(d.findings, Finding{
+		Severity: severity,
+		Check:    check,
+		Message:  fmt.Sprintf(messageFmt, args...),
+		Hint:     hint,
+	})
+}
+
+// inFlight reports whether the canary is receiving traffic
+func (d *diagnosis) inFlight() bool {
+	switch d.canary.Status.Phase {
+	case gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing,
+		gatewaycdv1alpha1.CanaryDeploymentPhasePaused,
+		gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack:
+		return true
+	}
+	return false
+}
+
+// checkPhase explains the phase and the conditions the controller waits on
+func (d *diagnosis) checkPhase() {
+	status := d.canary.Status
+	conditions := status.Conditions
+
+	if condition := meta.FindStatusCondition(conditions, gatewaycdv1alpha1.ConditionTypeRouteConflict); condition != nil && condition.Status == metav1.ConditionTrue {
+		d.add(SeverityCritical, "Phase", "Remove the other controller from the routes or set gateway-cd.io/allow-shared-route",
+			"Another controller manages the routes: %s", condition.Message)
+	}
+	if condition := meta.FindStatusCondition(conditions, gatewaycdv1alpha1.ConditionTypeCapacityInsufficient); condition != nil && condition.Status == metav1.ConditionTrue {
+		d.add(SeverityCritical, "Phase", "Free up node capacity or raise the namespace ResourceQuota",
+			"Insufficient capacity for the canary pods: %s", condition.Message)
+	}
+	if condition := meta.FindStatusCondition(conditions, gatewaycdv1alpha1.ConditionTypeQueued); condition != nil && condition.Status == metav1.ConditionTrue {
+		d.add(SeverityWarning, "Phase", "Wait for another rollout of the team to finish or raise the team's quota",
+			"Rollout is queued: %s", condition.Message)
+	}
+	if condition := meta.FindStatusCondition(conditions, gatewaycdv1alpha1.ConditionTypeBlocked); condition != nil && condition.Status == metav1.ConditionTrue {
+		d.add(SeverityWarning, "Phase", fmt.Sprintf("Wait for %s to finish or abort it", status.BlockedBy),
+			"Rollout is blocked: %s", condition.Message)
+	}
+
+	switch status.Phase {
+	case gatewaycdv1alpha1.CanaryDeploymentPhasePaused:
+		if meta.IsStatusConditionTrue(conditions, gatewaycdv1alpha1.ConditionTypePausedByUser) {
+			d.add(SeverityWarning, "Phase", "Resume the rollout", "Rollout was paused by a user")
+		} else {
+			d.add(SeverityWarning, "Phase", "Approve the step or resume the rollout",
+				"Rollout is waiting for approval of step %d", status.CurrentStep+1)
+		}
+	case gatewaycdv1alpha1.CanaryDeploymentPhaseFailed:
+		reason := status.RollbackReason
+		if reason == "" {
+			reason = status.Message
+		}
+		d.add(SeverityInfo, "Phase", "Fix the cause and update the target to start a new rollout",
+			"Rollout failed: %s", reason)
+	}
+
+	if d.inFlight() && status.LastTransitionTime != nil {
+		if since := time.Since(status.LastTransitionTime.Time); since > stuckAfter {
+			d.add(SeverityWarning, "Phase", "", "Rollout has been %s for %s", status.Phase, since.Round(time.Minute))
+		}
+	}
+}
+
+// checkRoutes reports routes that are missing or not accepted by their parents
+func (d *diagnosis) checkRoutes(ctx context.Context) {
+	pending, err := gateway.NewManager(d.client).RoutesProgrammed(ctx, d.canary)
+	switch {
+	case err != nil:
+		d.add(SeverityCritical, "Route", "Create the route or fix spec.gateway", "%v", err)
+	case pending != "":
+		d.add(SeverityCritical, "Route", "Check the route's parentRefs and backendRefs and the gateway's status",
+			"Route not accepted: %s", pending)
+	}
+	if retries := d.canary.Status.RetryCount; retries > 0 {
+		d.add(SeverityWarning, "Route", "Check the controller logs for the failing route update",
+			"The last route update failed and has been retried %d times", retries)
+	}
+}
+
+// checkServices reports a missing stable or canary Service and Services
+// without ready pods behind them
+func (d *diagnosis) checkServices(ctx context.Context) {
+	names := []string{d.canary.Spec.Service.Name}
+	if d.inFlight() {
+		names = append(names, d.canary.Spec.Service.Name+"-canary")
+	}
+	for _, name := range names {
+		var service corev1.Service
+		err := d.client.Get(ctx, types.NamespacedName{Name: name, Namespace: d.canary.Namespace}, &service)
+		switch {
+		case apierrors.IsNotFound(err):
+			d.add(SeverityCritical, "Service", "Create the Service or fix spec.service",
+				"Service %s/%s does not exist", d.canary.Namespace, name)
+		case err != nil:
+			d.add(SeverityWarning, "Service", "", "Failed to get Service %s/%s: %v", d.canary.Namespace, name, err)
+		default:
+			d.checkPods(ctx, &service)
+		}
+	}
+}
+
+// checkPods reports a Service without ready pods behind it
+func (d *diagnosis) checkPods(ctx context.Context, service *corev1.Service) {
+	if len(service.Spec.Selector) == 0 {
+		return
+	}
+
+	var pods corev1.PodList
+	if err := d.client.List(ctx, &pods, client.InNamespace(service.Namespace),
+		client.MatchingLabelsSelector{Selector: labels.SelectorFromSet(service.Spec.Selector)}); err != nil {
+		d.add(SeverityWarning, "Pods", "", "Failed to list pods of Service %s: %v", service.Name, err)
+		return
+	}
+	ready, waiting := 0, ""
+	for i := range pods.Items {
+		if podReady(&pods.Items[i]) {
+			ready++
+		} else if waiting == "" {
+			waiting = podWaitingReason(&pods.Items[i])
+		}
+	}
+	if ready > 0 {
+		return
+	}
+	message := fmt.Sprintf("Service %s has zero ready pods out of %d", service.Name, len(pods.Items))
+	if waiting != "" {
+		message += ": " + waiting
+	}
+	d.add(SeverityCritical, "Pods", "Check the pods' events and logs", "%s", message)
+}
+
+// checkProvider reports missing provider credentials and analysis that
+// keeps failing to run
+func (d *diagnosis) checkProvider(ctx context.Context) {
+	if provider := d.canary.Spec.Analysis.Provider; provider != nil && provider.SecretRef != nil {
+		var secret corev1.Secret
+		err := d.client.Get(ctx, types.NamespacedName{Name: provider.SecretRef.Name, Namespace: d.canary.Namespace}, &secret)
+		if apierrors.IsNotFound(err) {
+			d.add(SeverityCritical, "Provider", "Create the Secret or fix spec.analysis.provider.secretRef",
+				"Provider credentials Secret %s/%s does not exist", d.canary.Namespace, provider.SecretRef.Name)
+		}
+	}
+	if failed := d.canary.Status.ConsecutiveErrors; failed > 0 {
+		d.add(SeverityWarning, "Provider", "Check the metrics provider's availability and queries",
+			"The last %d analysis runs could not be completed", failed)
+	}
+}
+
+// checkEvents reports the recent warning events of the canary, flagging
+// provider authentication failures
+func (d *diagnosis) checkEvents(ctx context.Context) {
+	var events corev1.EventList
+	if err := d.client.List(ctx, &events, client.InNamespace(d.canary.Namespace), client.MatchingFields{
+		"involvedObject.kind": "CanaryDeployment",
+		"involvedObject.name": d.canary.Name,
+	}); err != nil {
+		d.add(SeverityInfo, "Events", "", "Failed to list events: %v", err)
+		return
+	}
+
+	latest := map[string]*corev1.Event{}
+	for i := range events.Items {
+		event := &events.Items[i]
+		if event.Type != corev1.EventTypeWarning || time.Since(eventTime(event)) > eventWindow {
+			continue
+		}
+		if current, ok := latest[event.Reason]; !ok || eventTime(event).After(eventTime(current)) {
+			latest[event.Reason] = event
+		}
+	}
+
+	reasons := make([]string, 0, len(latest))
+	for reason := range latest {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		event := latest[reason]
+		if authFailure(event.Message) {
+			d.add(SeverityCritical, "Provider", "Check the provider credentials in spec.analysis.provider.secretRef",
+				"Provider authentication is failing: %s", event.Message)
+			continue
+		}
+		d.add(SeverityWarning, "Events", "", "%s %s ago: %s", reason,
+			time.Since(eventTime(event)).Round(time.Second), event.Message)
+	}
+}
+
+// podReady reports whether the pod's Ready condition is True
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podWaitingReason describes why a pod isn't ready, e.g. ImagePullBackOff
+func podWaitingReason(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if waiting := status.State.Waiting; waiting != nil && waiting.Reason != "" {
+			return fmt.Sprintf("pod %s container %s is %s", pod.Name, status.Name, waiting.Reason)
+		}
+	}
+	if pod.Status.Phase == corev1.PodPending {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+				return fmt.Sprintf("pod %s is unschedulable: %s", pod.Name, condition.Message)
+			}
+		}
+	}
+	return ""
+}
+
+// eventTime returns when an event last occurred
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// authFailure reports whether a provider error message looks like rejected credentials
+func authFailure(message string) bool {
+	message = strings.ToLower(message)
+	for _, marker := range []string{"status 401", "status 403", "unauthorized", "forbidden"} {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package expression
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]interface{}{
+		"readyReplicas": int32(3),
+		"successRate":   0.995,
+		"errors":        int64(0),
+		"requests":      10,
+		"phase":         "Progressing",
+		"paused":        false,
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		want   bool
+	}{
+		{"comparison", "readyReplicas >= 3", true},
+		{"and", "readyReplicas >= 3 && successRate > 0.99", true},
+		{"or", "paused || errors == 0", true},
+		{"not", "!paused", true},
+		{"double negation", "!!paused", false},
+		{"product before sum", "1 + 2 * 3 == 7", true},
+		{"left associative difference", "10 - 4 - 3 == 3", true},
+		{"left associative quotient", "8 / 4 / 2 == 1", true},
+		{"unary minus", "-requests + 12 == 2", true},
+		{"parentheses", "(1 + 2) * 3 == 9", true},
+		{"and before or", "true || false && false", true},
+		{"and before or on the right", "false && true || true", true},
+		{"parenthesized or", "(true || false) && false", false},
+		{"comparison before and", "requests > 5 && requests < 20", true},
+		{"arithmetic before comparison", "requests * 2 > 19", true},
+		{"string equality", "phase == 'Progressing'", true},
+		{"double quoted string", `phase != "Paused"`, true},
+		{"string ordering", "'a' < 'b'", true},
+		{"string concatenation", "'Prog' + 'ressing' == phase", true},
+		{"or short-circuits", "true || unknown > 1", true},
+		{"and short-circuits", "false && unknown > 1", false},
+		{"mixed types are not equal", "requests == '10'", false},
+		{"mixed types differ", "requests != '10'", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Eval(tt.source, vars)
+			if err != nil {
+				t.Fatalf("Eval(%q) failed: %v", tt.source, err)
+			}
+			if got != tt.want {
+				t.Errorf("Eval(%q) = %v, want %v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	vars := map[string]interface{}{
+		"replicas": 3,
+		"phase":    "Progressing",
+		"paused":   false,
+		"labels":   map[string]string{},
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{"unknown identifier", "unknown > 1", `undefined variable "unknown"`},
+		{"unknown identifier on the right", "paused || missing", `undefined variable "missing"`},
+		{"unsupported variable type", "labels == 1", `variable "labels" has unsupported type`},
+		{"number compared with string", "replicas < 'a'", "operator < does not apply to a number and a string"},
+		{"string minus string", "phase - 'x'", "operator - does not apply to a string and a string"},
+		{"bool arithmetic", "true + 1 == 2", "operator + does not apply to a bool and a number"},
+		{"and on number", "replicas && true", "operator && does not apply to a number"},
+		{"or on string right", "paused || phase", "operator || does not apply to a string"},
+		{"not on number", "!replicas", "operator ! does not apply to a number"},
+		{"minus on string", "-phase == 'x'", "operator - does not apply to a string"},
+		{"division by zero", "replicas / 0 > 1", "division by zero"},
+		{"non-bool result", "replicas + 1", "expression is a number, not a bool"},
+		{"string result", "phase", "expression is a string, not a bool"},
+		{"unterminated double quote", `phase == "Progressing`, "unterminated string at 9"},
+		{"unterminated single quote", "phase == 'Progressing", "unterminated string at 9"},
+		{"mismatched quotes", `phase == "Progressing'`, "unterminated string at 9"},
+		{"unexpected character", "replicas # 1", `unexpected '#' at 9`},
+		{"invalid number", "1.2.3 > 1", `invalid number "1.2.3" at 0`},
+		{"missing operand", "replicas >", `unexpected "end of expression" at 10`},
+		{"chained comparison", "1 < 2 < 3", `unexpected "<" at 6`},
+		{"unclosed parenthesis", "(replicas > 1", `expected ) at 13, got "end of expression"`},
+		{"stray parenthesis", "replicas > 1)", `unexpected ")" at 12`},
+		{"empty", "", `unexpected "end of expression" at 0`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Eval(tt.source, vars)
+			if err == nil {
+				t.Fatalf("Eval(%q) succeeded, want error %q", tt.source, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Eval(%q) error = %q, want %q", tt.source, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseString(t *testing.T) {
+	source := "readyReplicas >= 3"
+	expr, err := Parse(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr.String() != source {
+		t.Errorf("String() = %q, want %q", expr.String(), source)
+	}
+	for _, replicas := range []int{2, 3} {
+		got, err := expr.Eval(map[string]interface{}{"readyReplicas": replicas})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := replicas >= 3; got != want {
+			t.Errorf("Eval(readyReplicas=%d) = %v, want %v", replicas, got, want)
+		}
+	}
+}
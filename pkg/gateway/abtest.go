@@ -0,0 +1,214 @@
+package gateway
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// VariantCanary is the assignment cookie value of users on the canary
+	VariantCanary = "canary"
+	// VariantStable is the assignment cookie value of users on stable
+	VariantStable = "stable"
+
+	// DefaultCookieMaxAge is how long assignment cookies last when the canary doesn't set it
+	DefaultCookieMaxAge = time.Hour * 24
+)
+
+// abAssignment pins users assigned to a variant, by cookie or hash bucket
+// header, to that variant while the canary gets partial traffic
+type abAssignment struct {
+	cookie    string
+	setCookie bool
+	maxAge    time.Duration
+	header    string
+	buckets   int
+	// weight is the canary weight the assignment applies to
+	weight int
+}
+
+// abAssignmentFor returns the A/B assignment of the canary at weight, or an
+// empty assignment if the canary doesn't run an A/B test
+func abAssignmentFor(canary *gatewaycdv1alpha1.CanaryDeployment, weight int) abAssignment {
+	spec := canary.Spec.ABTest
+	if spec == nil {
+		return abAssignment{}
+	}
+	maxAge := DefaultCookieMaxAge
+	if d, err := time.ParseDuration(spec.CookieMaxAge); err == nil && d > 0 {
+		maxAge = d
+	}
+	return abAssignment{
+		cookie:    spec.Cookie,
+		setCookie: spec.SetCookie && spec.Cookie != "",
+		maxAge:    maxAge,
+		header:    spec.Header,
+		buckets:   bucketCount(canary),
+		weight:    weight,
+	}
+}
+
+// active reports whether users are split between the variants. At 0 and 100
+// percent every user is on one variant and assignments are not pinned.
+func (a abAssignment) active() bool {
+	return (a.cookie != "" || a.header != "") && a.weight > 0 && a.weight < 100
+}
+
+// canaryBuckets is the number of hash buckets, from 0, routed to the canary
+func (a abAssignment) canaryBuckets() int {
+	return int(math.Round(float64(a.weight) * float64(a.buckets) / 100))
+}
+
+// cookieMatch matches requests whose assignment cookie has the given variant
+func (a abAssignment) cookieMatch(variant string) gatewayapi.HTTPHeaderMatch {
+	regex := gatewayapi.HeaderMatchRegularExpression
+	return gatewayapi.HTTPHeaderMatch{
+		Type:  &regex,
+		Name:  "Cookie",
+		Value: fmt.Sprintf(`(^|;\s*)%s=%s(;|$)`, regexp.QuoteMeta(a.cookie), variant),
+	}
+}
+
+// headerMatches returns the bucket header matches of the canary buckets and,
+// after them so the canary buckets take precedence, of any bucket
+func (a abAssignment) headerMatches() (canary, any gatewayapi.HTTPHeaderMatch) {
+	regex := gatewayapi.HeaderMatchRegularExpression
+	values := make([]string, a.canaryBuckets())
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	canary = gatewayapi.HTTPHeaderMatch{
+		Type:  &regex,
+		Name:  gatewayapi.HTTPHeaderName(a.header),
+		Value: fmt.Sprintf("^(%s)$", strings.Join(values, "|")),
+	}
+	any = gatewayapi.HTTPHeaderMatch{
+		Type:  &regex,
+		Name:  gatewayapi.HTTPHeaderName(a.header),
+		Value: "^[0-9]+$",
+	}
+	return canary, any
+}
+
+// variantRules derives rules from a base rule that route requests assigned to
+// a variant entirely to it. Their header match makes them take precedence
+// over the base rule, which splits unassigned requests by weight.
+func (a abAssignment) variantRules(base gatewayapi.HTTPRouteRule, stableBackend, canaryBackend gatewayapi.HTTPBackendRef) []gatewayapi.HTTPRouteRule {
+	if !a.active() {
+		return nil
+	}
+	stableOnly := *stableBackend.DeepCopy()
+	stableOnly.Weight = nil
+	canaryOnly := *canaryBackend.DeepCopy()
+	canaryOnly.Weight = nil
+
+	var rules []gatewayapi.HTTPRouteRule
+	rule := func(header gatewayapi.HTTPHeaderMatch, backend gatewayapi.HTTPBackendRef) {
+		var matches []gatewayapi.HTTPRouteMatch
+		for _, match := range base.Matches {
+			variantMatch := *match.DeepCopy()
+			variantMatch.Headers = append(variantMatch.Headers, header)
+			matches = append(matches, variantMatch)
+		}
+		for start := 0; start < len(matches); start += maxMatchesPerRule {
+			end := min(start+maxMatchesPerRule, len(matches))
+			r := *base.DeepCopy()
+			r.Matches = matches[start:end]
+			r.BackendRefs = []gatewayapi.HTTPBackendRef{backend}
+			rules = append(rules, r)
+		}
+	}
+
+	if a.cookie != "" {
+		rule(a.cookieMatch(VariantCanary), canaryOnly)
+		rule(a.cookieMatch(VariantStable), stableOnly)
+	}
+	if a.header != "" {
+		canaryBuckets, anyBucket := a.headerMatches()
+		if a.canaryBuckets() > 0 {
+			rule(canaryBuckets, canaryOnly)
+		}
+		rule(anyBucket, stableOnly)
+	}
+	return rules
+}
+
+// isVariantRule reports whether rule was generated by variantRules, i.e. it
+// targets a single variant and every match selects on the assignment cookie
+// or bucket header
+func (a abAssignment) isVariantRule(rule gatewayapi.HTTPRouteRule, stableName, canaryName gatewayapi.ObjectName) bool {
+	if a.cookie == "" && a.header == "" {
+		return false
+	}
+	if len(rule.BackendRefs) != 1 || len(rule.Matches) == 0 {
+		return false
+	}
+	if name := rule.BackendRefs[0].Name; name != stableName && name != canaryName {
+		return false
+	}
+	for _, match := range rule.Matches {
+		if !a.isAssignmentMatch(match) {
+			return false
+		}
+	}
+	return true
+}
+
+// isAssignmentMatch reports whether match selects on the assignment cookie or bucket header
+func (a abAssignment) isAssignmentMatch(match gatewayapi.HTTPRouteMatch) bool {
+	if a.header != "" && hasHeaderMatch(match, a.header) {
+		return true
+	}
+	if a.cookie == "" {
+		return false
+	}
+	for _, variant := range []string{VariantCanary, VariantStable} {
+		cookie := a.cookieMatch(variant)
+		for _, h := range match.Headers {
+			if strings.EqualFold(string(h.Name), string(cookie.Name)) && h.Value == cookie.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withAssignmentCookie removes any filter setting the assignment cookie and,
+// while users are split, adds one assigning them to variant
+func (a abAssignment) withAssignmentCookie(filters []gatewayapi.HTTPRouteFilter, variant string) []gatewayapi.HTTPRouteFilter {
+	if a.cookie == "" {
+		return filters
+	}
+	prefix := a.cookie + "="
+	var result []gatewayapi.HTTPRouteFilter
+	for _, filter := range filters {
+		if filter.Type == gatewayapi.HTTPRouteFilterResponseHeaderModifier && filter.ResponseHeaderModifier != nil &&
+			len(filter.ResponseHeaderModifier.Add) == 1 &&
+			strings.EqualFold(string(filter.ResponseHeaderModifier.Add[0].Name), "Set-Cookie") &&
+			strings.HasPrefix(filter.ResponseHeaderModifier.Add[0].Value, prefix) {
+			continue
+		}
+		result = append(result, filter)
+	}
+
+	if a.setCookie && a.active() {
+		result = append(result, gatewayapi.HTTPRouteFilter{
+			Type: gatewayapi.HTTPRouteFilterResponseHeaderModifier,
+			ResponseHeaderModifier: &gatewayapi.HTTPHeaderFilter{
+				Add: []gatewayapi.HTTPHeader{{
+					Name:  "Set-Cookie",
+					Value: fmt.Sprintf("%s%s; Path=/; Max-Age=%d", prefix, variant, int(a.maxAge.Seconds())),
+				}},
+			},
+		})
+	}
+	return result
+}
@@ -0,0 +1,141 @@
+package gateway
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// DefaultAffinityCookie is the session affinity cookie when the canary doesn't set one
+const DefaultAffinityCookie = "gateway-cd-canary"
+
+// sessionAffinity keeps clients the canary served on the canary. The canary
+// backend sets the affinity cookie on its responses and rules matching the
+// cookie route those clients to the canary only.
+type sessionAffinity struct {
+	cookie string
+	maxAge time.Duration
+	// weight is the canary weight the affinity applies to
+	weight int
+}
+
+// affinityFor returns the session affinity of the canary at weight, or an
+// empty affinity if the canary doesn't enable it
+func affinityFor(canary *gatewaycdv1alpha1.CanaryDeployment, weight int) sessionAffinity {
+	spec := canary.Spec.SessionAffinity
+	if spec == nil {
+		return sessionAffinity{}
+	}
+	cookie := spec.Cookie
+	if cookie == "" {
+		cookie = DefaultAffinityCookie
+	}
+	maxAge := DefaultCookieMaxAge
+	if d, err := time.ParseDuration(spec.MaxAge); err == nil && d > 0 {
+		maxAge = d
+	}
+	return sessionAffinity{cookie: cookie, maxAge: maxAge, weight: weight}
+}
+
+// active reports whether clients are pinned. At 0 and 100 percent every
+// client is on one version and the affinity rules are dropped.
+func (a sessionAffinity) active() bool {
+	return a.cookie != "" && a.weight > 0 && a.weight < 100
+}
+
+// cookieMatch matches requests carrying the affinity cookie
+func (a sessionAffinity) cookieMatch() gatewayapi.HTTPHeaderMatch {
+	regex := gatewayapi.HeaderMatchRegularExpression
+	return gatewayapi.HTTPHeaderMatch{
+		Type:  &regex,
+		Name:  "Cookie",
+		Value: fmt.Sprintf(`(^|;\s*)%s=%s(;|$)`, regexp.QuoteMeta(a.cookie), VariantCanary),
+	}
+}
+
+// affinityRules derives rules from a base rule that route requests carrying
+// the affinity cookie entirely to the canary. Their header match makes them
+// take precedence over the base rule, which splits the other requests by
+// weight.
+func (a sessionAffinity) affinityRules(base gatewayapi.HTTPRouteRule, canaryBackend gatewayapi.HTTPBackendRef) []gatewayapi.HTTPRouteRule {
+	if !a.active() {
+		return nil
+	}
+	canaryOnly := *canaryBackend.DeepCopy()
+	canaryOnly.Weight = nil
+
+	var matches []gatewayapi.HTTPRouteMatch
+	for _, match := range base.Matches {
+		affinityMatch := *match.DeepCopy()
+		affinityMatch.Headers = append(affinityMatch.Headers, a.cookieMatch())
+		matches = append(matches, affinityMatch)
+	}
+	var rules []gatewayapi.HTTPRouteRule
+	for start := 0; start < len(matches); start += maxMatchesPerRule {
+		end := min(start+maxMatchesPerRule, len(matches))
+		r := *base.DeepCopy()
+		r.Matches = matches[start:end]
+		r.BackendRefs = []gatewayapi.HTTPBackendRef{canaryOnly}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// isAffinityRule reports whether rule was generated by affinityRules, i.e. it
+// targets the canary only and every match selects on the affinity cookie
+func (a sessionAffinity) isAffinityRule(rule gatewayapi.HTTPRouteRule, canaryName gatewayapi.ObjectName) bool {
+	if a.cookie == "" || len(rule.BackendRefs) != 1 || rule.BackendRefs[0].Name != canaryName || len(rule.Matches) == 0 {
+		return false
+	}
+	cookie := a.cookieMatch()
+	for _, match := range rule.Matches {
+		found := false
+		for _, h := range match.Headers {
+			if strings.EqualFold(string(h.Name), string(cookie.Name)) && h.Value == cookie.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// withAffinityCookie removes any filter setting the affinity cookie and,
+// while clients are pinned, adds one so the canary pins the clients it serves
+func (a sessionAffinity) withAffinityCookie(filters []gatewayapi.HTTPRouteFilter) []gatewayapi.HTTPRouteFilter {
+	if a.cookie == "" {
+		return filters
+	}
+	prefix := a.cookie + "="
+	var result []gatewayapi.HTTPRouteFilter
+	for _, filter := range filters {
+		if filter.Type == gatewayapi.HTTPRouteFilterResponseHeaderModifier && filter.ResponseHeaderModifier != nil &&
+			len(filter.ResponseHeaderModifier.Add) == 1 &&
+			strings.EqualFold(string(filter.ResponseHeaderModifier.Add[0].Name), "Set-Cookie") &&
+			strings.HasPrefix(filter.ResponseHeaderModifier.Add[0].Value, prefix) {
+			continue
+		}
+		result = append(result, filter)
+	}
+
+	if a.active() {
+		result = append(result, gatewayapi.HTTPRouteFilter{
+			Type: gatewayapi.HTTPRouteFilterResponseHeaderModifier,
+			ResponseHeaderModifier: &gatewayapi.HTTPHeaderFilter{
+				Add: []gatewayapi.HTTPHeader{{
+					Name:  "Set-Cookie",
+					Value: fmt.Sprintf("%s%s; Path=/; Max-Age=%d", prefix, VariantCanary, int(a.maxAge.Seconds())),
+				}},
+			},
+		})
+	}
+	return result
+}
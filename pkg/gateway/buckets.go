@@ -0,0 +1,153 @@
+package gateway
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// DefaultBucketHeader carries the request hash bucket when the canary doesn't set one
+	DefaultBucketHeader = "X-Canary-Bucket"
+	// DefaultBuckets is the number of hash buckets when the canary doesn't set one
+	DefaultBuckets = 100
+)
+
+// bucketSlice routes weight percent of the requests in the first count hash
+// buckets to the canary, for an effective exposure of count/buckets*weight percent
+type bucketSlice struct {
+	header  string
+	buckets int
+	count   int
+	weight  int
+}
+
+// bucketsForStep returns the bucket slice of a fractional weight step, or an
+// empty slice for integer weight steps
+func bucketsForStep(canary *gatewaycdv1alpha1.CanaryDeployment, step int) (bucketSlice, error) {
+	slice := bucketSlice{header: bucketHeader(canary), buckets: bucketCount(canary)}
+	fractional := canary.Spec.TrafficSplit[step].FractionalWeight
+	if fractional == "" {
+		return slice, nil
+	}
+
+	percent, err := strconv.ParseFloat(fractional, 64)
+	if err != nil {
+		return slice, fmt.Errorf("invalid fractional weight %q: %w", fractional, err)
+	}
+	slice.weight, slice.count, err = FractionalSplit(percent, slice.buckets)
+	return slice, err
+}
+
+// FractionalSplit finds the smallest integer weight and the number of hash
+// buckets that together route percent of all traffic to the canary. The
+// smallest weight spreads the exposure over as many buckets as possible.
+func FractionalSplit(percent float64, buckets int) (weight, count int, err error) {
+	if percent <= 0 || percent >= 100 {
+		return 0, 0, fmt.Errorf("fractional weight %g must be between 0 and 100", percent)
+	}
+	for weight = 1; weight <= 100; weight++ {
+		count = int(math.Round(percent * float64(buckets) / float64(weight)))
+		if count >= 1 && count <= buckets {
+			return weight, count, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("fractional weight %g is too small for %d buckets, the minimum is %g",
+		percent, buckets, 1/float64(buckets))
+}
+
+// bucketHeader returns the header carrying the hash bucket for the canary
+func bucketHeader(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	if canary.Spec.BucketHeader != "" {
+		return canary.Spec.BucketHeader
+	}
+	return DefaultBucketHeader
+}
+
+// bucketCount returns the number of hash buckets for the canary
+func bucketCount(canary *gatewaycdv1alpha1.CanaryDeployment) int {
+	if canary.Spec.Buckets > 0 {
+		return int(canary.Spec.Buckets)
+	}
+	return DefaultBuckets
+}
+
+// fraction renders the effective canary percentage, or "" if the slice is empty
+func (s bucketSlice) fraction() string {
+	if s.count == 0 {
+		return ""
+	}
+	percent := float64(s.count) * float64(s.weight) / float64(s.buckets)
+	return strconv.FormatFloat(percent, 'f', -1, 64) + "%"
+}
+
+// headerMatch matches the bucket header against buckets 0 to count-1, so
+// requests already on the canary stay there as the fraction grows
+func (s bucketSlice) headerMatch() gatewayapi.HTTPHeaderMatch {
+	regex := gatewayapi.HeaderMatchRegularExpression
+	values := make([]string, s.count)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	return gatewayapi.HTTPHeaderMatch{
+		Type:  &regex,
+		Name:  gatewayapi.HTTPHeaderName(s.header),
+		Value: fmt.Sprintf("^(%s)$", strings.Join(values, "|")),
+	}
+}
+
+// bucketRules derives rules from a base rule that match the same requests
+// plus the bucket header and split them by the slice weight. Requests outside
+// the buckets keep hitting the base rule.
+func (s bucketSlice) bucketRules(base gatewayapi.HTTPRouteRule, stableBackend, canaryBackend gatewayapi.HTTPBackendRef) []gatewayapi.HTTPRouteRule {
+	if s.count == 0 {
+		return nil
+	}
+
+	header := s.headerMatch()
+	var matches []gatewayapi.HTTPRouteMatch
+	for _, match := range base.Matches {
+		bucketMatch := *match.DeepCopy()
+		bucketMatch.Headers = append(bucketMatch.Headers, header)
+		matches = append(matches, bucketMatch)
+	}
+
+	stableWeight := int32(100 - s.weight)
+	canaryWeight := int32(s.weight)
+	stableBackend = *stableBackend.DeepCopy()
+	stableBackend.Weight = &stableWeight
+	canaryBackend = *canaryBackend.DeepCopy()
+	canaryBackend.Weight = &canaryWeight
+
+	var rules []gatewayapi.HTTPRouteRule
+	for start := 0; start < len(matches); start += maxMatchesPerRule {
+		end := start + maxMatchesPerRule
+		if end > len(matches) {
+			end = len(matches)
+		}
+		rule := *base.DeepCopy()
+		rule.Matches = matches[start:end]
+		rule.BackendRefs = []gatewayapi.HTTPBackendRef{stableBackend, canaryBackend}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// isBucketRule reports whether rule was generated by bucketRules, i.e. it
+// targets the canary and every match selects on the bucket header
+func (s bucketSlice) isBucketRule(rule gatewayapi.HTTPRouteRule, canaryName gatewayapi.ObjectName) bool {
+	if len(rule.BackendRefs) != 2 || rule.BackendRefs[1].Name != canaryName || len(rule.Matches) == 0 {
+		return false
+	}
+	for _, match := range rule.Matches {
+		if !hasHeaderMatch(match, s.header) {
+			return false
+		}
+	}
+	return true
+}
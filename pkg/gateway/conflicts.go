@@ -0,0 +1,126 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// foreignController describes how another progressive delivery controller
+// marks the routes it manages
+type foreignController struct {
+	name string
+	// groups are API groups of owner references the controller sets
+	groups []string
+	// managers are substrings of the field managers the controller writes as
+	managers []string
+	// keyPrefixes are prefixes of annotation and label keys the controller sets
+	keyPrefixes []string
+}
+
+// foreignControllers are the progressive delivery controllers known to rewrite route weights
+var foreignControllers = []foreignController{
+	{
+		name:        "Flagger",
+		groups:      []string{"flagger.app"},
+		managers:    []string{"flagger"},
+		keyPrefixes: []string{"flagger.app/"},
+	},
+	{
+		name:        "Argo Rollouts",
+		groups:      []string{"argoproj.io"},
+		managers:    []string{"argo-rollouts", "rollouts-controller", "gatewayapi-plugin"},
+		keyPrefixes: []string{"rollouts.argoproj.io/", "argo-rollouts.argoproj.io/"},
+	},
+}
+
+// RouteConflict is another progressive delivery controller managing a route of the canary
+type RouteConflict struct {
+	// Route is the kind and namespace/name of the route
+	Route string
+	// Controller is the name of the other controller
+	Controller string
+	// Evidence is what identified the other controller
+	Evidence string
+}
+
+// String describes the conflict
+func (c RouteConflict) String() string {
+	return fmt.Sprintf("%s is managed by %s (%s)", c.Route, c.Controller, c.Evidence)
+}
+
+// DetectRouteConflicts returns the routes of the canary that another
+// progressive delivery controller manages, identified by owner references,
+// field managers or annotations and labels. Two controllers writing weights
+// to one route would undo each other's traffic shifts.
+func (m *Manager) DetectRouteConflicts(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) ([]RouteConflict, error) {
+	var conflicts []RouteConflict
+	for _, target := range routeTargets(canary) {
+		route := newRouteObject(target.kind)
+		if err := m.client.Get(ctx, types.NamespacedName{
+			Name:      target.name,
+			Namespace: target.namespace,
+		}, route); err != nil {
+			return nil, fmt.Errorf("failed to get %s %s/%s: %w", target.kind, target.namespace, target.name, err)
+		}
+
+		for _, controller := range foreignControllers {
+			if evidence, ok := controller.manages(route); ok {
+				conflicts = append(conflicts, RouteConflict{
+					Route:      fmt.Sprintf("%s %s/%s", target.kind, target.namespace, target.name),
+					Controller: controller.name,
+					Evidence:   evidence,
+				})
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// manages reports whether the controller's markers are on obj and which one was found
+func (c foreignController) manages(obj client.Object) (string, bool) {
+	for _, owner := range obj.GetOwnerReferences() {
+		gv, err := schema.ParseGroupVersion(owner.APIVersion)
+		if err != nil {
+			continue
+		}
+		for _, group := range c.groups {
+			if gv.Group == group || strings.HasSuffix(gv.Group, "."+group) {
+				return fmt.Sprintf("owned by %s %s", owner.Kind, owner.Name), true
+			}
+		}
+	}
+
+	for _, entry := range obj.GetManagedFields() {
+		manager := strings.ToLower(entry.Manager)
+		for _, name := range c.managers {
+			if strings.Contains(manager, name) {
+				return fmt.Sprintf("field manager %s", entry.Manager), true
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(obj.GetAnnotations())+len(obj.GetLabels()))
+	for key := range obj.GetAnnotations() {
+		keys = append(keys, key)
+	}
+	for key := range obj.GetLabels() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, prefix := range c.keyPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return fmt.Sprintf("metadata key %s", key), true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,171 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// RouteDrift is a managed route whose canary weight differs from the weight
+// the controller last wrote to it
+type RouteDrift struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Desired is the canary weight recorded in status.routes
+	Desired int32
+	// Actual is the canary weight of the first rule that differs
+	Actual int32
+}
+
+// String describes the drift, e.g. "HTTPRoute default/web: 50% canary instead of 10%"
+func (d RouteDrift) String() string {
+	return fmt.Sprintf("%s %s/%s: %d%% canary instead of %d%%", d.Kind, d.Namespace, d.Name, d.Actual, d.Desired)
+}
+
+// DriftedRoutes compares the backend weights of the managed routes with the
+// weights last written to them, recorded in status.routes, and returns the
+// routes another controller or a human changed. Routes whose last write
+// failed, or that were never written, are skipped.
+func (m *Manager) DriftedRoutes(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) ([]RouteDrift, error) {
+	var drifted []RouteDrift
+	for _, target := range routeTargets(canary) {
+		status := recordedRouteStatus(canary, target)
+		if status == nil || status.UpdatedTime == nil || status.Error != "" {
+			continue
+		}
+		route := newRouteObject(target.kind)
+		if err := m.client.Get(ctx, target.key(), route); err != nil {
+			if apierrors.IsNotFound(err) {
+				// A deleted route fails the next write instead
+				continue
+			}
+			return nil, fmt.Errorf("failed to get %s %s/%s: %w", target.kind, target.namespace, target.name, err)
+		}
+
+		var weights []int32
+		switch route := route.(type) {
+		case *gatewayapi.HTTPRoute:
+			weights = httpRouteWeights(route, canary, int(status.Weight))
+		case *gatewayapiv1alpha2.GRPCRoute:
+			weights = grpcRouteWeights(route, canary)
+		}
+		for _, weight := range weights {
+			if weight != status.Weight {
+				drifted = append(drifted, RouteDrift{
+					Kind:      target.kind,
+					Namespace: target.namespace,
+					Name:      target.name,
+					Desired:   status.Weight,
+					Actual:    weight,
+				})
+				break
+			}
+		}
+	}
+	return drifted, nil
+}
+
+// httpRouteWeights returns the canary weight of every rule of the route the
+// rollout splits by weight. Rules outside the route selector and the tenant,
+// variant, affinity and bucket rules the rollout adds are skipped.
+func httpRouteWeights(route *gatewayapi.HTTPRoute, canary *gatewaycdv1alpha1.CanaryDeployment, weight int) []int32 {
+	stable, canaryRef := backendRefs(canary, weight)
+	tenants := tenantSlice{header: tenantHeader(canary)}
+	buckets := bucketSlice{header: bucketHeader(canary)}
+	assignment := abAssignmentFor(canary, weight)
+	affinity := affinityFor(canary, weight)
+	selector := ruleSelectorFor(canary)
+	if !selector.selectsRoute(route.Spec.Hostnames) {
+		return nil
+	}
+
+	var weights []int32
+	for _, rule := range route.Spec.Rules {
+		if !selector.selectsRule(rule) || tenants.isTenantRule(rule, canaryRef.Name) || buckets.isBucketRule(rule, canaryRef.Name) ||
+			assignment.isVariantRule(rule, stable.Name, canaryRef.Name) || affinity.isAffinityRule(rule, canaryRef.Name) {
+			continue
+		}
+		refs := make([]gatewayapi.BackendRef, len(rule.BackendRefs))
+		for i := range rule.BackendRefs {
+			refs[i] = rule.BackendRefs[i].BackendRef
+		}
+		weights = append(weights, canaryShare(refs, canaryRef.Name))
+	}
+	return weights
+}
+
+// grpcRouteWeights returns the canary weight of every rule of the route
+func grpcRouteWeights(route *gatewayapiv1alpha2.GRPCRoute, canary *gatewaycdv1alpha1.CanaryDeployment) []int32 {
+	_, canaryRef := backendRefs(canary, 0)
+	var weights []int32
+	for _, rule := range route.Spec.Rules {
+		refs := make([]gatewayapi.BackendRef, len(rule.BackendRefs))
+		for i := range rule.BackendRefs {
+			refs[i] = rule.BackendRefs[i].BackendRef
+		}
+		weights = append(weights, canaryShare(refs, canaryRef.Name))
+	}
+	return weights
+}
+
+// canaryShare is the percentage of the traffic of a rule's backends served
+// by the canary. A backend without a weight has weight 1.
+func canaryShare(refs []gatewayapi.BackendRef, canaryName gatewayapi.ObjectName) int32 {
+	var total, canary int32
+	for _, ref := range refs {
+		weight := int32(1)
+		if ref.Weight != nil {
+			weight = *ref.Weight
+		}
+		total += weight
+		if ref.Name == canaryName {
+			canary += weight
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return int32(math.Round(float64(canary) * 100 / float64(total)))
+}
+
+// ManagedHTTPRoutes returns the namespace/name of every HTTPRoute the canary
+// manages, the primary HTTPRoutes followed by the additional routes
+func ManagedHTTPRoutes(canary *gatewaycdv1alpha1.CanaryDeployment) []string {
+	var keys []string
+	for _, target := range routeTargets(canary) {
+		if target.kind == KindHTTPRoute {
+			keys = append(keys, target.key().String())
+		}
+	}
+	return keys
+}
+
+// ManagedRoutes returns the kind/namespace/name of every route the canary
+// manages, HTTPRoutes and GRPCRoutes alike
+func ManagedRoutes(canary *gatewaycdv1alpha1.CanaryDeployment) []string {
+	targets := routeTargets(canary)
+	keys := make([]string, 0, len(targets))
+	for _, target := range targets {
+		keys = append(keys, target.kind+"/"+target.key().String())
+	}
+	return keys
+}
+
+// recordedRouteStatus returns the status entry of a route, or nil when the
+// controller never wrote it
+func recordedRouteStatus(canary *gatewaycdv1alpha1.CanaryDeployment, target routeTarget) *gatewaycdv1alpha1.RouteStatus {
+	for i := range canary.Status.Routes {
+		status := &canary.Status.Routes[i]
+		if status.Kind == target.kind && status.Namespace == target.namespace && status.Name == target.name {
+			return status
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// updateGRPCRoute fetches a GRPCRoute and writes the new traffic split
+func (m *Manager) updateGRPCRoute(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, target routeTarget, canaryWeight int) (int64, error) {
+	grpcRoute := &gatewayapiv1alpha2.GRPCRoute{}
+	err := m.client.Get(ctx, types.NamespacedName{
+		Name:      target.name,
+		Namespace: target.namespace,
+	}, grpcRoute)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get GRPCRoute %s/%s: %w", target.namespace, target.name, err)
+	}
+
+	m.updateGRPCRouteBackends(grpcRoute, canary, canaryWeight, target.port)
+
+	if err := m.client.Update(ctx, grpcRoute); err != nil {
+		return 0, fmt.Errorf("failed to update GRPCRoute %s/%s: %w", target.namespace, target.name, err)
+	}
+
+	return grpcRoute.Generation, nil
+}
+
+// updateGRPCRouteBackends rewrites the backendRefs of every GRPCRoute rule
+// with the weighted split on port, or the named service port a rule already uses
+func (m *Manager) updateGRPCRouteBackends(grpcRoute *gatewayapiv1alpha2.GRPCRoute, canary *gatewaycdv1alpha1.CanaryDeployment, canaryWeight int, port int32) {
+	stable, canaryRef := backendRefs(canary, canaryWeight)
+
+	for i := range grpcRoute.Spec.Rules {
+		refs := make([]gatewayapi.BackendRef, len(grpcRoute.Spec.Rules[i].BackendRefs))
+		for j := range grpcRoute.Spec.Rules[i].BackendRefs {
+			refs[j] = grpcRoute.Spec.Rules[i].BackendRefs[j].BackendRef
+		}
+		backendPort := rulePort(canary, refs, port)
+
+		// Keep per-backend filters across weight updates
+		var stableFilters, canaryFilters []gatewayapiv1alpha2.GRPCRouteFilter
+		for _, ref := range grpcRoute.Spec.Rules[i].BackendRefs {
+			switch ref.Name {
+			case stable.Name:
+				stableFilters = ref.Filters
+			case canaryRef.Name:
+				canaryFilters = ref.Filters
+			}
+		}
+		stableBackend := gatewayapiv1alpha2.GRPCBackendRef{BackendRef: withPort(stable, backendPort), Filters: stableFilters}
+		canaryBackend := gatewayapiv1alpha2.GRPCBackendRef{BackendRef: withPort(canaryRef, backendPort), Filters: canaryFilters}
+
+		if canaryWeight == 0 {
+			// Only stable backend
+			grpcRoute.Spec.Rules[i].BackendRefs = []gatewayapiv1alpha2.GRPCBackendRef{stableBackend}
+		} else if canaryWeight == 100 {
+			// Only canary backend (promotion complete)
+			grpcRoute.Spec.Rules[i].BackendRefs = []gatewayapiv1alpha2.GRPCBackendRef{canaryBackend}
+		} else {
+			// Both backends with weights
+			grpcRoute.Spec.Rules[i].BackendRefs = []gatewayapiv1alpha2.GRPCBackendRef{stableBackend, canaryBackend}
+			if baseline := baselineRef(canary, canaryWeight); *baseline.Weight > 0 {
+				grpcRoute.Spec.Rules[i].BackendRefs = append(grpcRoute.Spec.Rules[i].BackendRefs,
+					gatewayapiv1alpha2.GRPCBackendRef{BackendRef: withPort(baseline, backendPort), Filters: stableFilters})
+			}
+		}
+	}
+}
@@ -3,12 +3,18 @@ package gateway
 import (
 	"context"
 	"fmt"
+	"slices"
+	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/otlp"
+	"gateway-cd/pkg/retry"
 )
 
 // Manager handles Gateway API operations for canary deployments
@@ -23,84 +29,481 @@ func NewManager(client client.Client) *Manager {
 	}
 }
 
-// UpdateTrafficSplit updates the HTTPRoute to split traffic between stable and canary services
+const (
+	// KindHTTPRoute is the kind of HTTPRoute targets
+	KindHTTPRoute = "HTTPRoute"
+	// KindGRPCRoute is the kind of GRPCRoute targets
+	KindGRPCRoute = "GRPCRoute"
+)
+
+// routeTarget is a single route managed for a canary together with its weight policy
+type routeTarget struct {
+	kind      string
+	name      string
+	namespace string
+	gateway   string
+	policy    gatewaycdv1alpha1.RouteWeightPolicy
+	weights   []int32
+	// sections limits the rollout to the parentRefs with these section names
+	sections []string
+	// port is the service port the route sends traffic to
+	port int32
+}
+
+// routeTargets returns the primary HTTPRoutes and GRPCRoute followed by any additional routes
+func routeTargets(canary *gatewaycdv1alpha1.CanaryDeployment) []routeTarget {
+	namespace := canary.Spec.Gateway.Namespace
+	if namespace == "" {
+		namespace = canary.Namespace
+	}
+
+	var targets []routeTarget
+	for i, name := range HTTPRouteNames(&canary.Spec.Gateway) {
+		target := routeTarget{
+			kind:      KindHTTPRoute,
+			name:      name,
+			namespace: namespace,
+			policy:    gatewaycdv1alpha1.RouteWeightPolicyLinked,
+			port:      routePort(canary, canary.Spec.Gateway.Port),
+		}
+		// The Gateway and section names refer to the first HTTPRoute
+		if i == 0 {
+			target.gateway = canary.Spec.Gateway.Gateway
+			target.sections = canary.Spec.Gateway.SectionNames
+		}
+		targets = append(targets, target)
+	}
+	if canary.Spec.Gateway.GRPCRoute != "" {
+		targets = append(targets, routeTarget{
+			kind:      KindGRPCRoute,
+			name:      canary.Spec.Gateway.GRPCRoute,
+			namespace: namespace,
+			gateway:   canary.Spec.Gateway.Gateway,
+			policy:    gatewaycdv1alpha1.RouteWeightPolicyLinked,
+			port:      routePort(canary, canary.Spec.Gateway.GRPCPort),
+		})
+	}
+	for _, route := range canary.Spec.Gateway.AdditionalRoutes {
+		routeNamespace := route.Namespace
+		if routeNamespace == "" {
+			routeNamespace = namespace
+		}
+		policy := route.WeightPolicy
+		if policy == "" {
+			policy = gatewaycdv1alpha1.RouteWeightPolicyLinked
+		}
+		targets = append(targets, routeTarget{
+			kind:      KindHTTPRoute,
+			name:      route.HTTPRoute,
+			namespace: routeNamespace,
+			gateway:   route.Gateway,
+			policy:    policy,
+			weights:   route.Weights,
+			port:      routePort(canary, route.Port),
+		})
+	}
+	return targets
+}
+
+// HTTPRouteNames returns the HTTPRoute of a gateway reference followed by its
+// HTTPRoutes, without duplicates
+func HTTPRouteNames(ref *gatewaycdv1alpha1.GatewayRef) []string {
+	var names []string
+	for _, name := range append([]string{ref.HTTPRoute}, ref.HTTPRoutes...) {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// weightForStep returns the canary weight of the target at the given step
+func (t routeTarget) weightForStep(canary *gatewaycdv1alpha1.CanaryDeployment, step int) int {
+	if t.policy == gatewaycdv1alpha1.RouteWeightPolicyIndependent && step < len(t.weights) {
+		return int(t.weights[step])
+	}
+	return int(canary.Spec.TrafficSplit[step].Weight)
+}
+
+// splitForStep returns the routing of the target at the given step. Bucket
+// slices only apply to linked routes.
+func (t routeTarget) splitForStep(canary *gatewaycdv1alpha1.CanaryDeployment, step int, tenants tenantSlice, buckets bucketSlice) trafficSplit {
+	split := trafficSplit{weight: t.weightForStep(canary, step), tenants: tenants, buckets: bucketSlice{header: buckets.header}}
+	if t.policy == gatewaycdv1alpha1.RouteWeightPolicyLinked {
+		split.buckets = buckets
+	}
+	return split
+}
+
+// trafficSplit is the routing written to a single route
+type trafficSplit struct {
+	// weight is the percentage of traffic served by the canary
+	weight int
+	// tenants are routed entirely to the canary on HTTPRoutes
+	tenants tenantSlice
+	// mirror copies stable traffic to the canary on HTTPRoutes
+	mirror bool
+	// buckets route a fraction of a percent to the canary on HTTPRoutes
+	buckets bucketSlice
+	// port is the service port of rules not already on a named port,
+	// the default service port when zero
+	port int32
+}
+
+// UpdateTrafficSplit updates every managed HTTPRoute to send canaryWeight percent of traffic to the canary
 func (m *Manager) UpdateTrafficSplit(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, canaryWeight int) error {
-	// Get the HTTPRoute
-	httpRoute := &gatewayapi.HTTPRoute{}
-	httpRouteNamespace := canary.Spec.Gateway.Namespace
-	if httpRouteNamespace == "" {
-		httpRouteNamespace = canary.Namespace
+	// An explicit weight drops any tenant slice, bucket slice or mirror
+	split := trafficSplit{
+		weight:  canaryWeight,
+		tenants: tenantSlice{header: tenantHeader(canary)},
+		buckets: bucketSlice{header: bucketHeader(canary)},
+	}
+	if err := m.applySplit(ctx, canary, func(routeTarget) trafficSplit { return split }); err != nil {
+		return err
 	}
+	canary.Status.CanaryTenants = 0
+	canary.Status.CanaryFraction = ""
+	return nil
+}
 
-	err := m.client.Get(ctx, types.NamespacedName{
-		Name:      canary.Spec.Gateway.HTTPRoute,
-		Namespace: httpRouteNamespace,
+// MirrorTraffic keeps all traffic on stable and mirrors a copy of it to the
+// canary through a RequestMirror filter on every managed HTTPRoute
+func (m *Manager) MirrorTraffic(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	split := trafficSplit{
+		tenants: tenantSlice{header: tenantHeader(canary)},
+		buckets: bucketSlice{header: bucketHeader(canary)},
+		mirror:  true,
+	}
+	if err := m.applySplit(ctx, canary, func(routeTarget) trafficSplit { return split }); err != nil {
+		return err
+	}
+	canary.Status.CanaryTenants = 0
+	canary.Status.CanaryFraction = ""
+	return nil
+}
+
+// UpdateTrafficSplitForStep applies the weights, tenant slice and bucket slice
+// of the given traffic split step, honouring the weight policy of each
+// additional route. Bucket slices only apply to linked HTTPRoutes.
+func (m *Manager) UpdateTrafficSplitForStep(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, step int) error {
+	if step < 0 || step >= len(canary.Spec.TrafficSplit) {
+		return retry.Terminal(fmt.Errorf("step %d is out of range", step))
+	}
+	tenants := tenantsForStep(canary, step)
+	buckets, err := bucketsForStep(canary, step)
+	if err != nil {
+		return retry.Terminal(err)
+	}
+	if err := m.applySplit(ctx, canary, func(target routeTarget) trafficSplit {
+		return target.splitForStep(canary, step, tenants, buckets)
+	}); err != nil {
+		return err
+	}
+	canary.Status.CanaryTenants = int32(tenants.size())
+	canary.Status.CanaryFraction = buckets.fraction()
+	return nil
+}
+
+// recordRouteWrite reflects the last write to the primary route in the canary status
+func recordRouteWrite(canary *gatewaycdv1alpha1.CanaryDeployment, target routeTarget, weight int, generation int64) {
+	canary.Status.ManagedRoute = fmt.Sprintf("%s/%s", target.namespace, target.name)
+	canary.Status.LastAppliedWeight = int32(weight)
+	canary.Status.RouteGeneration = generation
+	canary.Status.RouteUpdatedTime = &metav1.Time{Time: time.Now()}
+}
+
+// updateRoute fetches a single route, writes the new traffic split and
+// returns the generation of the updated route
+func (m *Manager) updateRoute(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, target routeTarget, split trafficSplit) (generation int64, err error) {
+	ctx, span := otlp.StartSpan(ctx, "update "+target.kind,
+		otlp.String("gateway_cd.route.name", target.namespace+"/"+target.name),
+		otlp.Int("gateway_cd.canary.weight", int64(split.weight)))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Tenant slices and mirroring only apply to HTTPRoutes
+	if target.kind == KindGRPCRoute {
+		return m.updateGRPCRoute(ctx, canary, target, split.weight)
+	}
+	split.port = target.port
+
+	// Get the HTTPRoute
+	httpRoute := &gatewayapi.HTTPRoute{}
+	err = m.client.Get(ctx, types.NamespacedName{
+		Name:      target.name,
+		Namespace: target.namespace,
 	}, httpRoute)
 	if err != nil {
-		return fmt.Errorf("failed to get HTTPRoute %s/%s: %w", httpRouteNamespace, canary.Spec.Gateway.HTTPRoute, err)
+		return 0, fmt.Errorf("failed to get HTTPRoute %s/%s: %w", target.namespace, target.name, err)
+	}
+
+	// Keep the parentRefs outside the rollout's sections on stable
+	if len(target.sections) > 0 {
+		if err := m.pinExcludedSections(ctx, canary, httpRoute, target.sections); err != nil {
+			return 0, err
+		}
 	}
 
 	// Update the HTTPRoute with new traffic split
-	if err := m.updateHTTPRouteBackends(httpRoute, canary, canaryWeight); err != nil {
-		return fmt.Errorf("failed to update HTTPRoute backends: %w", err)
+	if err := m.updateHTTPRouteBackends(httpRoute, canary, split); err != nil {
+		return 0, retry.Terminal(fmt.Errorf("failed to update HTTPRoute backends: %w", err))
 	}
 
 	// Update the HTTPRoute in the cluster
 	if err := m.client.Update(ctx, httpRoute); err != nil {
-		return fmt.Errorf("failed to update HTTPRoute: %w", err)
+		return 0, fmt.Errorf("failed to update HTTPRoute %s/%s: %w", target.namespace, target.name, err)
 	}
 
-	return nil
+	return httpRoute.Generation, nil
 }
 
-// updateHTTPRouteBackends modifies the HTTPRoute to include traffic splitting
-func (m *Manager) updateHTTPRouteBackends(httpRoute *gatewayapi.HTTPRoute, canary *gatewaycdv1alpha1.CanaryDeployment, canaryWeight int) error {
-	stableWeight := 100 - canaryWeight
+// updateHTTPRouteBackends modifies the HTTPRoute to include traffic splitting,
+// canary-only rules for the tenant slice, variant rules for A/B assignments,
+// canary-only rules for clients pinned by session affinity, weighted rules for the bucket slice and the canary mirror filter.
+// Only the rules selected by spec.gateway.routeSelector are changed.
+func (m *Manager) updateHTTPRouteBackends(httpRoute *gatewayapi.HTTPRoute, canary *gatewaycdv1alpha1.CanaryDeployment, split trafficSplit) error {
+	canaryWeight := split.weight
+	tenants := split.tenants
+	assignment := abAssignmentFor(canary, canaryWeight)
+	affinity := affinityFor(canary, canaryWeight)
 
 	// Create backend references
-	stableBackend := gatewayapi.HTTPBackendRef{
-		BackendRef: gatewayapi.BackendRef{
-			BackendObjectReference: gatewayapi.BackendObjectReference{
-				Name: gatewayapi.ObjectName(canary.Spec.Service.Name),
-				Port: (*gatewayapi.PortNumber)(&canary.Spec.Service.Port),
-			},
-			Weight: func(w int) *int32 { i := int32(w); return &i }(stableWeight),
-		},
+	stable, canaryRef := backendRefs(canary, canaryWeight)
+	port := split.port
+	if port == 0 {
+		port = canary.Spec.Service.Port
 	}
 
-	canaryBackend := gatewayapi.HTTPBackendRef{
-		BackendRef: gatewayapi.BackendRef{
-			BackendObjectReference: gatewayapi.BackendObjectReference{
-				Name: gatewayapi.ObjectName(fmt.Sprintf("%s-canary", canary.Spec.Service.Name)),
-				Port: (*gatewayapi.PortNumber)(&canary.Spec.Service.Port),
-			},
-			Weight: func(w int) *int32 { i := int32(w); return &i }(canaryWeight),
-		},
+	// Drop tenant, variant, affinity and bucket rules from the previous step, they are rebuilt below
+	rules := make([]gatewayapi.HTTPRouteRule, 0, len(httpRoute.Spec.Rules))
+	for _, rule := range httpRoute.Spec.Rules {
+		if !tenants.isTenantRule(rule, canaryRef.Name) && !split.buckets.isBucketRule(rule, canaryRef.Name) &&
+			!assignment.isVariantRule(rule, stable.Name, canaryRef.Name) && !affinity.isAffinityRule(rule, canaryRef.Name) {
+			rules = append(rules, rule)
+		}
 	}
 
-	// Update all rules with the new backend configuration
-	for i := range httpRoute.Spec.Rules {
+	// Update the selected rules with the new backend configuration
+	selector := ruleSelectorFor(canary)
+	routeSelected := selector.selectsRoute(httpRoute.Spec.Hostnames)
+	selected := 0
+	var tenantRules, variantRules, affinityRules, bucketRules []gatewayapi.HTTPRouteRule
+	for i := range rules {
+		rule := &rules[i]
+
+		// Rules outside the route selector keep their backends
+		if !routeSelected || !selector.selectsRule(*rule) {
+			releaseRule(rule, canary)
+			continue
+		}
+		selected++
+
 		// Find or create the default match (all traffic)
-		if len(httpRoute.Spec.Rules[i].Matches) == 0 {
-			httpRoute.Spec.Rules[i].Matches = []gatewayapi.HTTPRouteMatch{{}}
+		if len(rule.Matches) == 0 {
+			rule.Matches = []gatewayapi.HTTPRouteMatch{{}}
 		}
 
+		// Keep the rule on the named service port it already uses
+		backendPort := rulePort(canary, httpBackendRefs(rule.BackendRefs), port)
+		ruleStable, ruleCanary := withPort(stable, backendPort), withPort(canaryRef, backendPort)
+
+		// Carry over per-backend filters (e.g. URLRewrite on legacy path-mapped
+		// services) so weight updates don't silently drop them
+		stableFilters, canaryFilters := backendFilters(rule.BackendRefs, stable.Name, canaryRef.Name)
+		if canary.Spec.Gateway.CanaryRewrite != nil {
+			canaryFilters = withURLRewrite(canaryFilters, canary.Spec.Gateway.CanaryRewrite)
+		}
+		stableFilters = assignment.withAssignmentCookie(stableFilters, VariantStable)
+		canaryFilters = assignment.withAssignmentCookie(canaryFilters, VariantCanary)
+		canaryFilters = affinity.withAffinityCookie(canaryFilters)
+		stableBackend := gatewayapi.HTTPBackendRef{BackendRef: ruleStable, Filters: stableFilters}
+		canaryBackend := gatewayapi.HTTPBackendRef{BackendRef: ruleCanary, Filters: canaryFilters}
+
 		// Update backend references
 		if canaryWeight == 0 {
 			// Only stable backend
-			httpRoute.Spec.Rules[i].BackendRefs = []gatewayapi.HTTPBackendRef{stableBackend}
+			rule.BackendRefs = []gatewayapi.HTTPBackendRef{stableBackend}
 		} else if canaryWeight == 100 {
 			// Only canary backend (promotion complete)
-			httpRoute.Spec.Rules[i].BackendRefs = []gatewayapi.HTTPBackendRef{canaryBackend}
+			rule.BackendRefs = []gatewayapi.HTTPBackendRef{canaryBackend}
 		} else {
 			// Both backends with weights
-			httpRoute.Spec.Rules[i].BackendRefs = []gatewayapi.HTTPBackendRef{stableBackend, canaryBackend}
+			rule.BackendRefs = []gatewayapi.HTTPBackendRef{stableBackend, canaryBackend}
+			if baseline := baselineRef(canary, canaryWeight); *baseline.Weight > 0 {
+				// The baseline runs the stable version, so it gets the stable filters
+				rule.BackendRefs = append(rule.BackendRefs, gatewayapi.HTTPBackendRef{
+					BackendRef: withPort(baseline, backendPort),
+					Filters:    copyFilters(stableFilters),
+				})
+			}
 		}
+		rule.Filters = withMirror(rule.Filters, ruleCanary.BackendObjectReference, split.mirror)
+
+		// Route the tenant slice entirely to the canary
+		if canaryWeight < 100 {
+			canaryOnly := canaryBackend
+			canaryOnly.Weight = nil
+			tenantRules = append(tenantRules, tenants.tenantRules(*rule, canaryOnly)...)
+		}
+
+		// Keep users assigned to a variant on it
+		variantRules = append(variantRules, assignment.variantRules(*rule, stableBackend, canaryBackend)...)
+
+		// Keep clients the canary served on it
+		affinityRules = append(affinityRules, affinity.affinityRules(*rule, canaryBackend)...)
+
+		// Split the bucket slice by its own weight, the rest stays on stable
+		if canaryWeight == 0 {
+			bucketRules = append(bucketRules, split.buckets.bucketRules(*rule, stableBackend, canaryBackend)...)
+		}
+	}
+	if selector.restricted() && routeSelected && selected == 0 && canaryWeight > 0 {
+		return fmt.Errorf("no rule of HTTPRoute %s/%s matches the route selector", httpRoute.Namespace, httpRoute.Name)
 	}
 
+	// Tenant rules come first so pinned tenants win over assigned users and
+	// the bucket slice
+	httpRoute.Spec.Rules = append(append(append(append(rules, tenantRules...), variantRules...), affinityRules...), bucketRules...)
+
 	return nil
 }
 
+// backendFilters returns the filters currently attached to the stable and
+// canary backendRefs of a rule. A canary without filters of its own inherits
+// the stable filters so both versions see the same rewritten requests.
+func backendFilters(refs []gatewayapi.HTTPBackendRef, stableName, canaryName gatewayapi.ObjectName) ([]gatewayapi.HTTPRouteFilter, []gatewayapi.HTTPRouteFilter) {
+	var stableFilters, canaryFilters []gatewayapi.HTTPRouteFilter
+	for _, ref := range refs {
+		switch ref.Name {
+		case stableName:
+			stableFilters = ref.Filters
+		case canaryName:
+			canaryFilters = ref.Filters
+		}
+	}
+	if canaryFilters == nil && stableFilters != nil {
+		canaryFilters = copyFilters(stableFilters)
+	}
+	return stableFilters, canaryFilters
+}
+
+// copyFilters deep copies backend filters
+func copyFilters(filters []gatewayapi.HTTPRouteFilter) []gatewayapi.HTTPRouteFilter {
+	if filters == nil {
+		return nil
+	}
+	copied := make([]gatewayapi.HTTPRouteFilter, len(filters))
+	for i := range filters {
+		filters[i].DeepCopyInto(&copied[i])
+	}
+	return copied
+}
+
+// withURLRewrite replaces any URLRewrite filter with the configured canary rewrite
+func withURLRewrite(filters []gatewayapi.HTTPRouteFilter, rewrite *gatewaycdv1alpha1.URLRewrite) []gatewayapi.HTTPRouteFilter {
+	result := make([]gatewayapi.HTTPRouteFilter, 0, len(filters)+1)
+	for _, filter := range filters {
+		if filter.Type != gatewayapi.HTTPRouteFilterURLRewrite {
+			result = append(result, filter)
+		}
+	}
+
+	urlRewrite := &gatewayapi.HTTPURLRewriteFilter{}
+	if rewrite.Hostname != "" {
+		hostname := gatewayapi.PreciseHostname(rewrite.Hostname)
+		urlRewrite.Hostname = &hostname
+	}
+	if rewrite.ReplaceFullPath != "" {
+		path := rewrite.ReplaceFullPath
+		urlRewrite.Path = &gatewayapi.HTTPPathModifier{
+			Type:            gatewayapi.FullPathHTTPPathModifier,
+			ReplaceFullPath: &path,
+		}
+	} else if rewrite.ReplacePrefixMatch != "" {
+		prefix := rewrite.ReplacePrefixMatch
+		urlRewrite.Path = &gatewayapi.HTTPPathModifier{
+			Type:               gatewayapi.PrefixMatchHTTPPathModifier,
+			ReplacePrefixMatch: &prefix,
+		}
+	}
+
+	return append(result, gatewayapi.HTTPRouteFilter{
+		Type:       gatewayapi.HTTPRouteFilterURLRewrite,
+		URLRewrite: urlRewrite,
+	})
+}
+
+// withMirror removes any RequestMirror filter targeting the canary and, when
+// mirror is set, adds one so the canary receives a copy of the rule's traffic
+func withMirror(filters []gatewayapi.HTTPRouteFilter, canaryRef gatewayapi.BackendObjectReference, mirror bool) []gatewayapi.HTTPRouteFilter {
+	var result []gatewayapi.HTTPRouteFilter
+	for _, filter := range filters {
+		if filter.Type == gatewayapi.HTTPRouteFilterRequestMirror && filter.RequestMirror != nil &&
+			filter.RequestMirror.BackendRef.Name == canaryRef.Name {
+			continue
+		}
+		result = append(result, filter)
+	}
+
+	if mirror {
+		result = append(result, gatewayapi.HTTPRouteFilter{
+			Type: gatewayapi.HTTPRouteFilterRequestMirror,
+			RequestMirror: &gatewayapi.HTTPRequestMirrorFilter{
+				BackendRef: canaryRef,
+			},
+		})
+	}
+	return result
+}
+
+// backendRefs builds the weighted stable and canary backend references. The
+// share of a baseline is taken from stable.
+func backendRefs(canary *gatewaycdv1alpha1.CanaryDeployment, canaryWeight int) (gatewayapi.BackendRef, gatewayapi.BackendRef) {
+	stableWeight := 100 - canaryWeight - BaselineWeight(canary, canaryWeight)
+
+	stable := gatewayapi.BackendRef{
+		BackendObjectReference: gatewayapi.BackendObjectReference{
+			Name: gatewayapi.ObjectName(canary.Spec.Service.Name),
+			Port: (*gatewayapi.PortNumber)(&canary.Spec.Service.Port),
+		},
+		Weight: func(w int) *int32 { i := int32(w); return &i }(stableWeight),
+	}
+
+	canaryRef := gatewayapi.BackendRef{
+		BackendObjectReference: gatewayapi.BackendObjectReference{
+			Name: gatewayapi.ObjectName(fmt.Sprintf("%s-canary", canary.Spec.Service.Name)),
+			Port: (*gatewayapi.PortNumber)(&canary.Spec.Service.Port),
+		},
+		Weight: func(w int) *int32 { i := int32(w); return &i }(canaryWeight),
+	}
+
+	return stable, canaryRef
+}
+
+// BaselineWeight is the percentage of traffic the baseline of a canary serves
+// at canaryWeight: the same share as the canary, or above 50 percent all that
+// stable has left
+func BaselineWeight(canary *gatewaycdv1alpha1.CanaryDeployment, canaryWeight int) int {
+	if canary.Spec.Baseline == nil {
+		return 0
+	}
+	return min(canaryWeight, 100-canaryWeight)
+}
+
+// baselineRef builds the weighted baseline backend reference
+func baselineRef(canary *gatewaycdv1alpha1.CanaryDeployment, canaryWeight int) gatewayapi.BackendRef {
+	weight := int32(BaselineWeight(canary, canaryWeight))
+	return gatewayapi.BackendRef{
+		BackendObjectReference: gatewayapi.BackendObjectReference{
+			Name: gatewayapi.ObjectName(fmt.Sprintf("%s-baseline", canary.Spec.Service.Name)),
+			Port: (*gatewayapi.PortNumber)(&canary.Spec.Service.Port),
+		},
+		Weight: &weight,
+	}
+}
+
 // CreateCanaryService creates a canary service for the deployment
 func (m *Manager) CreateCanaryService(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
 	// This would create a canary service that points to the canary deployment
@@ -109,10 +512,23 @@ func (m *Manager) CreateCanaryService(ctx context.Context, canary *gatewaycdv1al
 }
 
 // Cleanup removes any Gateway API resources created for the canary deployment
+// Cleanup is idempotent and skips routes that no longer exist, so it can be
+// retried until it succeeds.
 func (m *Manager) Cleanup(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
-	// Reset HTTPRoute to only point to stable service
-	if err := m.UpdateTrafficSplit(ctx, canary, 0); err != nil {
-		return fmt.Errorf("failed to cleanup traffic split: %w", err)
+	// Reset routes to only point to stable service
+	split := trafficSplit{
+		tenants: tenantSlice{header: tenantHeader(canary)},
+		buckets: bucketSlice{header: bucketHeader(canary)},
+	}
+	for _, target := range routeTargets(canary) {
+		// The sections kept out of the rollout are attached again below
+		target.sections = nil
+		if _, err := m.updateRoute(ctx, canary, target, split); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to cleanup traffic split: %w", err)
+		}
+	}
+	if err := m.RestoreSections(ctx, canary); err != nil {
+		return err
 	}
 
 	// Clean up any canary-specific services if needed
@@ -121,37 +537,42 @@ func (m *Manager) Cleanup(ctx context.Context, canary *gatewaycdv1alpha1.CanaryD
 
 // ValidateGatewayConfiguration validates that the required Gateway API resources exist
 func (m *Manager) ValidateGatewayConfiguration(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
-	// Check if HTTPRoute exists
-	httpRoute := &gatewayapi.HTTPRoute{}
-	httpRouteNamespace := canary.Spec.Gateway.Namespace
-	if httpRouteNamespace == "" {
-		httpRouteNamespace = canary.Namespace
-	}
-
-	err := m.client.Get(ctx, types.NamespacedName{
-		Name:      canary.Spec.Gateway.HTTPRoute,
-		Namespace: httpRouteNamespace,
-	}, httpRoute)
-	if err != nil {
-		return fmt.Errorf("HTTPRoute %s/%s not found: %w", httpRouteNamespace, canary.Spec.Gateway.HTTPRoute, err)
+	targets := routeTargets(canary)
+	if len(targets) == 0 {
+		return fmt.Errorf("no HTTPRoute or GRPCRoute configured")
 	}
 
-	// Check if Gateway exists (if specified)
-	if canary.Spec.Gateway.Gateway != "" {
-		gateway := &gatewayapi.Gateway{}
-		gatewayNamespace := canary.Spec.Gateway.Namespace
-		if gatewayNamespace == "" {
-			gatewayNamespace = canary.Namespace
-		}
-
+	for _, target := range targets {
+		// Check if the route exists
+		route := newRouteObject(target.kind)
 		err := m.client.Get(ctx, types.NamespacedName{
-			Name:      canary.Spec.Gateway.Gateway,
-			Namespace: gatewayNamespace,
-		}, gateway)
+			Name:      target.name,
+			Namespace: target.namespace,
+		}, route)
 		if err != nil {
-			return fmt.Errorf("Gateway %s/%s not found: %w", gatewayNamespace, canary.Spec.Gateway.Gateway, err)
+			return fmt.Errorf("%s %s/%s not found: %w", target.kind, target.namespace, target.name, err)
+		}
+
+		// Check if Gateway exists (if specified)
+		if target.gateway != "" {
+			gateway := &gatewayapi.Gateway{}
+			err := m.client.Get(ctx, types.NamespacedName{
+				Name:      target.gateway,
+				Namespace: target.namespace,
+			}, gateway)
+			if err != nil {
+				return fmt.Errorf("Gateway %s/%s not found: %w", target.namespace, target.gateway, err)
+			}
 		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// newRouteObject returns an empty route object of the given kind
+func newRouteObject(kind string) client.Object {
+	if kind == KindGRPCRoute {
+		return &gatewayapiv1alpha2.GRPCRoute{}
+	}
+	return &gatewayapi.HTTPRoute{}
+}
@@ -0,0 +1,98 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// RouteChange is the routing a rollout step would write to a single route
+type RouteChange struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Weight is the percentage of traffic sent to the canary
+	Weight int `json:"weight"`
+	// BaselineWeight is the percentage of traffic sent to the baseline
+	BaselineWeight int `json:"baselineWeight,omitempty"`
+	// Mirror reports whether stable traffic is mirrored to the canary
+	Mirror bool `json:"mirror,omitempty"`
+	// Sections are the parentRef section names the rollout is limited to;
+	// the other parentRefs move to a pinned route that stays on stable
+	Sections []string `json:"sections,omitempty"`
+	// HTTPRules are the rules of an HTTPRoute after the change
+	HTTPRules []gatewayapi.HTTPRouteRule `json:"httpRules,omitempty"`
+	// GRPCRules are the rules of a GRPCRoute after the change
+	GRPCRules []gatewayapiv1alpha2.GRPCRouteRule `json:"grpcRules,omitempty"`
+}
+
+// PlanTrafficSplitForStep computes the route changes UpdateTrafficSplitForStep
+// would write for the step from the current routes, without writing them
+func (m *Manager) PlanTrafficSplitForStep(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, step int) ([]RouteChange, error) {
+	if step < 0 || step >= len(canary.Spec.TrafficSplit) {
+		return nil, fmt.Errorf("step %d is out of range", step)
+	}
+	tenants := tenantsForStep(canary, step)
+	buckets, err := bucketsForStep(canary, step)
+	if err != nil {
+		return nil, err
+	}
+	return m.planRoutes(ctx, canary, func(target routeTarget) trafficSplit {
+		return target.splitForStep(canary, step, tenants, buckets)
+	})
+}
+
+// PlanMirrorTraffic computes the route changes MirrorTraffic would write,
+// without writing them
+func (m *Manager) PlanMirrorTraffic(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) ([]RouteChange, error) {
+	split := trafficSplit{
+		tenants: tenantSlice{header: tenantHeader(canary)},
+		buckets: bucketSlice{header: bucketHeader(canary)},
+		mirror:  true,
+	}
+	return m.planRoutes(ctx, canary, func(routeTarget) trafficSplit { return split })
+}
+
+// planRoutes applies the split of every managed route to a copy of the route
+func (m *Manager) planRoutes(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, splitFor func(routeTarget) trafficSplit) ([]RouteChange, error) {
+	var changes []RouteChange
+	for _, target := range routeTargets(canary) {
+		split := splitFor(target)
+		change := RouteChange{
+			Kind:           target.kind,
+			Namespace:      target.namespace,
+			Name:           target.name,
+			Weight:         split.weight,
+			Mirror:         split.mirror,
+			BaselineWeight: BaselineWeight(canary, split.weight),
+			Sections:       target.sections,
+		}
+		key := types.NamespacedName{Name: target.name, Namespace: target.namespace}
+
+		if target.kind == KindGRPCRoute {
+			grpcRoute := &gatewayapiv1alpha2.GRPCRoute{}
+			if err := m.client.Get(ctx, key, grpcRoute); err != nil {
+				return nil, fmt.Errorf("failed to get GRPCRoute %s: %w", key, err)
+			}
+			m.updateGRPCRouteBackends(grpcRoute, canary, split.weight, target.port)
+			change.GRPCRules = grpcRoute.Spec.Rules
+		} else {
+			httpRoute := &gatewayapi.HTTPRoute{}
+			if err := m.client.Get(ctx, key, httpRoute); err != nil {
+				return nil, fmt.Errorf("failed to get HTTPRoute %s: %w", key, err)
+			}
+			split.port = target.port
+			if err := m.updateHTTPRouteBackends(httpRoute, canary, split); err != nil {
+				return nil, fmt.Errorf("failed to plan HTTPRoute %s backends: %w", key, err)
+			}
+			change.HTTPRules = httpRoute.Spec.Rules
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
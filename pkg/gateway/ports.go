@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// ServicePort returns the number of the named port of a service reference,
+// or its default port when name is empty
+func ServicePort(service *gatewaycdv1alpha1.ServiceRef, name string) (int32, bool) {
+	if name == "" {
+		return service.Port, true
+	}
+	for _, port := range service.Ports {
+		if port.Name == name {
+			return port.Port, true
+		}
+	}
+	return 0, false
+}
+
+// routePort returns the service port a route sends traffic to, falling back
+// to the default port for an unknown name
+func routePort(canary *gatewaycdv1alpha1.CanaryDeployment, name string) int32 {
+	if port, ok := ServicePort(&canary.Spec.Service, name); ok {
+		return port
+	}
+	return canary.Spec.Service.Port
+}
+
+// rulePort returns the port a rule's backends use: the named service port
+// its stable, canary or baseline backend already targets, else port
+func rulePort(canary *gatewaycdv1alpha1.CanaryDeployment, refs []gatewayapi.BackendRef, port int32) int32 {
+	stable, canaryRef := backendRefs(canary, 0)
+	baseline := baselineRef(canary, 0)
+	for _, ref := range refs {
+		if ref.Port == nil || (ref.Name != stable.Name && ref.Name != canaryRef.Name && ref.Name != baseline.Name) {
+			continue
+		}
+		for _, named := range canary.Spec.Service.Ports {
+			if named.Port == int32(*ref.Port) {
+				return named.Port
+			}
+		}
+	}
+	return port
+}
+
+// withPort returns a copy of a backend reference targeting port
+func withPort(ref gatewayapi.BackendRef, port int32) gatewayapi.BackendRef {
+	ref.Port = (*gatewayapi.PortNumber)(&port)
+	return ref
+}
+
+// httpBackendRefs returns the backend references of HTTPRoute backends
+func httpBackendRefs(refs []gatewayapi.HTTPBackendRef) []gatewayapi.BackendRef {
+	result := make([]gatewayapi.BackendRef, len(refs))
+	for i := range refs {
+		result[i] = refs[i].BackendRef
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// RoutesProgrammed checks that the gateway implementation serves the last
+// write to every managed route: each route's parents accepted its current
+// generation and the Gateways it names report Programmed. It describes the
+// first route still pending, or returns an empty string once all are live.
+func (m *Manager) RoutesProgrammed(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (string, error) {
+	for _, target := range routeTargets(canary) {
+		route := newRouteObject(target.kind)
+		if err := m.client.Get(ctx, types.NamespacedName{Name: target.name, Namespace: target.namespace}, route); err != nil {
+			return "", fmt.Errorf("failed to get %s %s/%s: %w", target.kind, target.namespace, target.name, err)
+		}
+
+		if pending := parentsPending(route.GetGeneration(), routeParents(route)); pending != "" {
+			return fmt.Sprintf("%s %s/%s %s", target.kind, target.namespace, target.name, pending), nil
+		}
+
+		if target.gateway == "" {
+			continue
+		}
+		gateway := &gatewayapi.Gateway{}
+		if err := m.client.Get(ctx, types.NamespacedName{Name: target.gateway, Namespace: target.namespace}, gateway); err != nil {
+			return "", fmt.Errorf("failed to get Gateway %s/%s: %w", target.namespace, target.gateway, err)
+		}
+		programmed := meta.FindStatusCondition(gateway.Status.Conditions, string(gatewayapi.GatewayConditionProgrammed))
+		if programmed == nil || programmed.Status != metav1.ConditionTrue {
+			return fmt.Sprintf("Gateway %s/%s is not Programmed", target.namespace, target.gateway), nil
+		}
+	}
+	return "", nil
+}
+
+// routeParents returns the parent statuses of an HTTPRoute or GRPCRoute
+func routeParents(route client.Object) []gatewayapi.RouteParentStatus {
+	switch r := route.(type) {
+	case *gatewayapi.HTTPRoute:
+		return r.Status.Parents
+	case *gatewayapiv1alpha2.GRPCRoute:
+		return r.Status.Parents
+	}
+	return nil
+}
+
+// parentsPending describes why the parents of a route haven't accepted its
+// generation yet, or returns an empty string if they all have
+func parentsPending(generation int64, parents []gatewayapi.RouteParentStatus) string {
+	if len(parents) == 0 {
+		return "has no parent status yet"
+	}
+	for _, parent := range parents {
+		accepted := meta.FindStatusCondition(parent.Conditions, string(gatewayapi.RouteConditionAccepted))
+		switch {
+		case accepted == nil || accepted.ObservedGeneration < generation:
+			return fmt.Sprintf("generation %d not yet observed by %s", generation, parent.ControllerName)
+		case accepted.Status != metav1.ConditionTrue:
+			return fmt.Sprintf("not accepted by %s: %s", parent.ControllerName, accepted.Message)
+		}
+		resolved := meta.FindStatusCondition(parent.Conditions, string(gatewayapi.RouteConditionResolvedRefs))
+		if resolved != nil && resolved.ObservedGeneration >= generation && resolved.Status == metav1.ConditionFalse {
+			return fmt.Sprintf("has unresolved backends on %s: %s", parent.ControllerName, resolved.Message)
+		}
+	}
+	return ""
+}
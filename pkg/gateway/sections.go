@@ -0,0 +1,165 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// annotationDetachedParentRefs holds the parentRefs moved from the primary
+	// HTTPRoute to its pinned route while the rollout is limited to sections
+	annotationDetachedParentRefs = "gateway-cd.io/detached-parent-refs"
+	// pinnedRouteSuffix is appended to the primary HTTPRoute name to name the
+	// route that keeps the other sections on stable
+	pinnedRouteSuffix = "-pinned"
+)
+
+// pinExcludedSections limits the rollout on the primary HTTPRoute to the
+// parentRefs with the target's section names. The other parentRefs move to a
+// pinned copy of the route that always routes to stable, since the rules of
+// one route apply to every listener it is attached to. The moved parentRefs
+// are recorded on the route so RestoreSections can attach them again.
+func (m *Manager) pinExcludedSections(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, httpRoute *gatewayapi.HTTPRoute, sections []string) error {
+	detached, err := detachedParentRefs(httpRoute)
+	if err != nil {
+		return err
+	}
+	selected, excluded := partitionParentRefs(httpRoute.Spec.ParentRefs, sections)
+	if len(selected) == 0 {
+		return fmt.Errorf("no parentRef of HTTPRoute %s/%s has one of the section names %v", httpRoute.Namespace, httpRoute.Name, sections)
+	}
+	detached = append(detached, excluded...)
+	if len(detached) == 0 {
+		return nil
+	}
+
+	pinned := &gatewayapi.HTTPRoute{ObjectMeta: metav1.ObjectMeta{
+		Name:      httpRoute.Name + pinnedRouteSuffix,
+		Namespace: httpRoute.Namespace,
+	}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, m.client, pinned, func() error {
+		if pinned.Labels == nil {
+			pinned.Labels = make(map[string]string)
+		}
+		pinned.Labels["app.kubernetes.io/managed-by"] = "gateway-cd"
+		pinned.Labels["gateway-cd.io/canary"] = canary.Name
+		pinned.Spec.ParentRefs = detached
+		pinned.Spec.Hostnames = httpRoute.Spec.Hostnames
+		pinned.Spec.Rules = httpRoute.DeepCopy().Spec.Rules
+		return m.updateHTTPRouteBackends(pinned, canary, trafficSplit{
+			tenants: tenantSlice{header: tenantHeader(canary)},
+			buckets: bucketSlice{header: bucketHeader(canary)},
+		})
+	}); err != nil {
+		return fmt.Errorf("failed to reconcile pinned HTTPRoute %s/%s: %w", pinned.Namespace, pinned.Name, err)
+	}
+
+	if len(excluded) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(detached)
+	if err != nil {
+		return fmt.Errorf("failed to encode detached parentRefs: %w", err)
+	}
+	if httpRoute.Annotations == nil {
+		httpRoute.Annotations = make(map[string]string)
+	}
+	httpRoute.Annotations[annotationDetachedParentRefs] = string(data)
+	httpRoute.Spec.ParentRefs = selected
+	return nil
+}
+
+// RestoreSections attaches the parentRefs moved to the pinned route back to
+// the primary HTTPRoute and deletes the pinned route, once the rollout ended
+func (m *Manager) RestoreSections(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	targets := routeTargets(canary)
+	if len(targets) == 0 || targets[0].kind != KindHTTPRoute {
+		return nil
+	}
+	target := targets[0]
+
+	httpRoute := &gatewayapi.HTTPRoute{}
+	if err := m.client.Get(ctx, types.NamespacedName{Name: target.name, Namespace: target.namespace}, httpRoute); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	detached, err := detachedParentRefs(httpRoute)
+	if err != nil {
+		return err
+	}
+	if len(detached) > 0 {
+		for _, ref := range detached {
+			if !containsParentRef(httpRoute.Spec.ParentRefs, ref) {
+				httpRoute.Spec.ParentRefs = append(httpRoute.Spec.ParentRefs, ref)
+			}
+		}
+		delete(httpRoute.Annotations, annotationDetachedParentRefs)
+		if err := m.client.Update(ctx, httpRoute); err != nil {
+			return fmt.Errorf("failed to restore parentRefs of HTTPRoute %s/%s: %w", httpRoute.Namespace, httpRoute.Name, err)
+		}
+	}
+
+	pinned := &gatewayapi.HTTPRoute{ObjectMeta: metav1.ObjectMeta{
+		Name:      target.name + pinnedRouteSuffix,
+		Namespace: target.namespace,
+	}}
+	if err := m.client.Delete(ctx, pinned); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete pinned HTTPRoute %s/%s: %w", pinned.Namespace, pinned.Name, err)
+	}
+	return nil
+}
+
+// detachedParentRefs decodes the parentRefs recorded as moved to the pinned route
+func detachedParentRefs(httpRoute *gatewayapi.HTTPRoute) ([]gatewayapi.ParentReference, error) {
+	data, ok := httpRoute.Annotations[annotationDetachedParentRefs]
+	if !ok {
+		return nil, nil
+	}
+	var refs []gatewayapi.ParentReference
+	if err := json.Unmarshal([]byte(data), &refs); err != nil {
+		return nil, fmt.Errorf("failed to decode %s of HTTPRoute %s/%s: %w", annotationDetachedParentRefs, httpRoute.Namespace, httpRoute.Name, err)
+	}
+	return refs, nil
+}
+
+// partitionParentRefs splits parentRefs into those whose section name is one
+// of sections and the rest. ParentRefs without a section name attach to every
+// listener and are never selected.
+func partitionParentRefs(refs []gatewayapi.ParentReference, sections []string) (selected, excluded []gatewayapi.ParentReference) {
+	for _, ref := range refs {
+		matched := false
+		if ref.SectionName != nil {
+			for _, section := range sections {
+				if string(*ref.SectionName) == section {
+					matched = true
+					break
+				}
+			}
+		}
+		if matched {
+			selected = append(selected, ref)
+		} else {
+			excluded = append(excluded, ref)
+		}
+	}
+	return selected, excluded
+}
+
+// containsParentRef reports whether refs contains ref
+func containsParentRef(refs []gatewayapi.ParentReference, ref gatewayapi.ParentReference) bool {
+	for _, r := range refs {
+		if reflect.DeepEqual(r, ref) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"strings"
+
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// ruleSelector decides which rules of an HTTPRoute a rollout applies to
+type ruleSelector struct {
+	selector *gatewaycdv1alpha1.RouteSelector
+}
+
+// ruleSelectorFor returns the rule selector of the canary, which selects
+// every rule when spec.gateway.routeSelector is not set
+func ruleSelectorFor(canary *gatewaycdv1alpha1.CanaryDeployment) ruleSelector {
+	return ruleSelector{selector: canary.Spec.Gateway.RouteSelector}
+}
+
+// restricted reports whether the selector leaves rules out
+func (s ruleSelector) restricted() bool {
+	return s.selector != nil && (len(s.selector.Paths) > 0 || len(s.selector.Hostnames) > 0)
+}
+
+// selectsRoute reports whether the route serves one of the selected
+// hostnames. A route without hostnames serves all of them.
+func (s ruleSelector) selectsRoute(hostnames []gatewayapi.Hostname) bool {
+	if s.selector == nil || len(s.selector.Hostnames) == 0 || len(hostnames) == 0 {
+		return true
+	}
+	for _, hostname := range hostnames {
+		for _, selected := range s.selector.Hostnames {
+			if hostnameMatches(selected, string(hostname)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectsRule reports whether one of the path matches of the rule is
+// selected. A rule without matches matches the path prefix /.
+func (s ruleSelector) selectsRule(rule gatewayapi.HTTPRouteRule) bool {
+	if s.selector == nil || len(s.selector.Paths) == 0 {
+		return true
+	}
+	if len(rule.Matches) == 0 {
+		return s.selectsPath("/")
+	}
+	for _, match := range rule.Matches {
+		path := "/"
+		if match.Path != nil && match.Path.Value != nil {
+			path = *match.Path.Value
+		}
+		if s.selectsPath(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectsPath reports whether the value of a path match is selected
+func (s ruleSelector) selectsPath(path string) bool {
+	for _, selected := range s.selector.Paths {
+		if prefix, ok := strings.CutSuffix(selected, "/*"); ok {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") || (prefix == "" && strings.HasPrefix(path, "/")) {
+				return true
+			}
+		} else if path == selected {
+			return true
+		}
+	}
+	return false
+}
+
+// hostnameMatches reports whether hostname is selected, the wildcard
+// *.example.com selecting the hostnames under example.com
+func hostnameMatches(selected, hostname string) bool {
+	if selected == hostname {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(selected, "*"); ok {
+		return strings.HasSuffix(hostname, suffix) && len(hostname) > len(suffix)
+	}
+	return false
+}
+
+// releaseRule routes a rule outside the selector back to stable when an
+// earlier step or selector left it on the canary or baseline. Other rules
+// keep their backends and filters untouched.
+func releaseRule(rule *gatewayapi.HTTPRouteRule, canary *gatewaycdv1alpha1.CanaryDeployment) {
+	stable, canaryRef := backendRefs(canary, 0)
+	baseline := baselineRef(canary, 0)
+
+	onCanary := false
+	for _, ref := range rule.BackendRefs {
+		if ref.Name == canaryRef.Name || ref.Name == baseline.Name {
+			onCanary = true
+		}
+	}
+	if onCanary {
+		stableFilters, _ := backendFilters(rule.BackendRefs, stable.Name, canaryRef.Name)
+		port := rulePort(canary, httpBackendRefs(rule.BackendRefs), canary.Spec.Service.Port)
+		rule.BackendRefs = []gatewayapi.HTTPBackendRef{{BackendRef: withPort(stable, port), Filters: stableFilters}}
+	}
+	rule.Filters = withMirror(rule.Filters, canaryRef.BackendObjectReference, false)
+}
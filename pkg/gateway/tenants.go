@@ -0,0 +1,124 @@
+package gateway
+
+import (
+	"strings"
+
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// DefaultTenantHeader identifies the tenant of a request when the canary doesn't set one
+const DefaultTenantHeader = "X-Tenant-ID"
+
+// maxMatchesPerRule is the Gateway API limit on matches in a single HTTPRoute rule
+const maxMatchesPerRule = 8
+
+// tenantSlice is the set of tenants routed to the canary at a step
+type tenantSlice struct {
+	header   string
+	exact    []string
+	patterns []string
+}
+
+// tenantsForStep accumulates the tenants of every step up to and including
+// step, so a tenant stays on the canary once it has been moved there
+func tenantsForStep(canary *gatewaycdv1alpha1.CanaryDeployment, step int) tenantSlice {
+	slice := tenantSlice{header: tenantHeader(canary)}
+	for i := 0; i <= step && i < len(canary.Spec.TrafficSplit); i++ {
+		slice.exact = append(slice.exact, canary.Spec.TrafficSplit[i].Tenants...)
+		slice.patterns = append(slice.patterns, canary.Spec.TrafficSplit[i].TenantPatterns...)
+	}
+	return slice
+}
+
+// tenantHeader returns the header carrying the tenant ID for the canary
+func tenantHeader(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	if canary.Spec.TenantHeader != "" {
+		return canary.Spec.TenantHeader
+	}
+	return DefaultTenantHeader
+}
+
+// size returns the number of tenant IDs and patterns in the slice
+func (s tenantSlice) size() int {
+	return len(s.exact) + len(s.patterns)
+}
+
+// headerMatches returns one header match per tenant ID or pattern
+func (s tenantSlice) headerMatches() []gatewayapi.HTTPHeaderMatch {
+	exact := gatewayapi.HeaderMatchExact
+	regex := gatewayapi.HeaderMatchRegularExpression
+
+	matches := make([]gatewayapi.HTTPHeaderMatch, 0, s.size())
+	for _, tenant := range s.exact {
+		matches = append(matches, gatewayapi.HTTPHeaderMatch{
+			Type:  &exact,
+			Name:  gatewayapi.HTTPHeaderName(s.header),
+			Value: tenant,
+		})
+	}
+	for _, pattern := range s.patterns {
+		matches = append(matches, gatewayapi.HTTPHeaderMatch{
+			Type:  &regex,
+			Name:  gatewayapi.HTTPHeaderName(s.header),
+			Value: pattern,
+		})
+	}
+	return matches
+}
+
+// tenantRules derives canary-only rules from a base rule that match the same
+// requests plus the tenant header. Header matches make these rules take
+// precedence over the base rule under Gateway API matching rules.
+func (s tenantSlice) tenantRules(base gatewayapi.HTTPRouteRule, canaryBackend gatewayapi.HTTPBackendRef) []gatewayapi.HTTPRouteRule {
+	if s.size() == 0 {
+		return nil
+	}
+
+	var matches []gatewayapi.HTTPRouteMatch
+	for _, match := range base.Matches {
+		for _, header := range s.headerMatches() {
+			tenantMatch := *match.DeepCopy()
+			tenantMatch.Headers = append(tenantMatch.Headers, header)
+			matches = append(matches, tenantMatch)
+		}
+	}
+
+	var rules []gatewayapi.HTTPRouteRule
+	for start := 0; start < len(matches); start += maxMatchesPerRule {
+		end := start + maxMatchesPerRule
+		if end > len(matches) {
+			end = len(matches)
+		}
+		rule := *base.DeepCopy()
+		rule.Matches = matches[start:end]
+		rule.BackendRefs = []gatewayapi.HTTPBackendRef{*canaryBackend.DeepCopy()}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// isTenantRule reports whether rule was generated by tenantRules, i.e. it only
+// targets the canary and every match selects on the tenant header
+func (s tenantSlice) isTenantRule(rule gatewayapi.HTTPRouteRule, canaryName gatewayapi.ObjectName) bool {
+	if len(rule.BackendRefs) != 1 || rule.BackendRefs[0].Name != canaryName || len(rule.Matches) == 0 {
+		return false
+	}
+	for _, match := range rule.Matches {
+		if !hasHeaderMatch(match, s.header) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasHeaderMatch reports whether match selects on the given header
+func hasHeaderMatch(match gatewayapi.HTTPRouteMatch, header string) bool {
+	for _, h := range match.Headers {
+		if strings.EqualFold(string(h.Name), header) {
+			return true
+		}
+	}
+	return false
+}
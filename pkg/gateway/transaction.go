@@ -0,0 +1,131 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// applySplit writes the split of every managed route as a unit. All routes
+// are read before the first write; if a write fails, the routes already
+// written are restored to what they were, so the gateways never serve a mix
+// of two steps. The outcome for every route is recorded in status.routes.
+func (m *Manager) applySplit(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, splitFor func(routeTarget) trafficSplit) error {
+	targets := routeTargets(canary)
+	pruneRouteStatus(canary, targets)
+
+	snapshots := make([]client.Object, len(targets))
+	for i, target := range targets {
+		route := newRouteObject(target.kind)
+		if err := m.client.Get(ctx, target.key(), route); err != nil {
+			err = fmt.Errorf("failed to get %s %s/%s: %w", target.kind, target.namespace, target.name, err)
+			routeStatus(canary, target).Error = err.Error()
+			return err
+		}
+		snapshots[i] = route
+	}
+
+	previous := make([]gatewaycdv1alpha1.RouteStatus, len(targets))
+	for i, target := range targets {
+		routeStatus(canary, target).DeepCopyInto(&previous[i])
+	}
+
+	for i, target := range targets {
+		split := splitFor(target)
+		generation, err := m.updateRoute(ctx, canary, target, split)
+		if err != nil {
+			routeStatus(canary, target).Error = err.Error()
+			return errors.Join(err, m.revertRoutes(ctx, canary, targets[:i], snapshots[:i], previous[:i], target))
+		}
+
+		status := routeStatus(canary, target)
+		status.Weight = int32(split.weight)
+		status.Generation = generation
+		status.UpdatedTime = &metav1.Time{Time: time.Now()}
+		status.Error = ""
+		if i == 0 {
+			recordRouteWrite(canary, target, split.weight, generation)
+		}
+	}
+	return nil
+}
+
+// revertRoutes restores the routes written before the write to the failed route
+func (m *Manager) revertRoutes(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, targets []routeTarget,
+	snapshots []client.Object, previous []gatewaycdv1alpha1.RouteStatus, failed routeTarget) error {
+	var errs []error
+	for i := len(targets) - 1; i >= 0; i-- {
+		target := targets[i]
+		status := routeStatus(canary, target)
+		if err := m.restoreRoute(ctx, target, snapshots[i]); err != nil {
+			errs = append(errs, err)
+			status.Error = fmt.Sprintf("failed to revert after %s %s/%s failed: %v", failed.kind, failed.namespace, failed.name, err)
+			continue
+		}
+		previous[i].DeepCopyInto(status)
+		status.Error = fmt.Sprintf("reverted after %s %s/%s failed", failed.kind, failed.namespace, failed.name)
+	}
+	return errors.Join(errs...)
+}
+
+// restoreRoute writes the spec of a snapshot back to the route
+func (m *Manager) restoreRoute(ctx context.Context, target routeTarget, snapshot client.Object) error {
+	route := newRouteObject(target.kind)
+	if err := m.client.Get(ctx, target.key(), route); err != nil {
+		return fmt.Errorf("failed to get %s %s/%s: %w", target.kind, target.namespace, target.name, err)
+	}
+	switch route := route.(type) {
+	case *gatewayapi.HTTPRoute:
+		route.Spec = *snapshot.(*gatewayapi.HTTPRoute).Spec.DeepCopy()
+	case *gatewayapiv1alpha2.GRPCRoute:
+		route.Spec = *snapshot.(*gatewayapiv1alpha2.GRPCRoute).Spec.DeepCopy()
+	}
+	if err := m.client.Update(ctx, route); err != nil {
+		return fmt.Errorf("failed to revert %s %s/%s: %w", target.kind, target.namespace, target.name, err)
+	}
+	return nil
+}
+
+// key returns the namespaced name of the route
+func (t routeTarget) key() types.NamespacedName {
+	return types.NamespacedName{Name: t.name, Namespace: t.namespace}
+}
+
+// routeStatus returns the status entry of a route, adding it if missing
+func routeStatus(canary *gatewaycdv1alpha1.CanaryDeployment, target routeTarget) *gatewaycdv1alpha1.RouteStatus {
+	for i := range canary.Status.Routes {
+		status := &canary.Status.Routes[i]
+		if status.Kind == target.kind && status.Namespace == target.namespace && status.Name == target.name {
+			return status
+		}
+	}
+	canary.Status.Routes = append(canary.Status.Routes, gatewaycdv1alpha1.RouteStatus{
+		Kind:      target.kind,
+		Namespace: target.namespace,
+		Name:      target.name,
+	})
+	return &canary.Status.Routes[len(canary.Status.Routes)-1]
+}
+
+// pruneRouteStatus drops the status of routes the canary no longer manages
+func pruneRouteStatus(canary *gatewaycdv1alpha1.CanaryDeployment, targets []routeTarget) {
+	var kept []gatewaycdv1alpha1.RouteStatus
+	for _, status := range canary.Status.Routes {
+		for _, target := range targets {
+			if status.Kind == target.kind && status.Namespace == target.namespace && status.Name == target.name {
+				kept = append(kept, status)
+				break
+			}
+		}
+	}
+	canary.Status.Routes = kept
+}
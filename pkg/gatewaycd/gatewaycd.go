@@ -0,0 +1,189 @@
+// Package gatewaycd lets other operators embed the gateway-cd rollout engine
+// into their own controller manager
+package gatewaycd
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	gatewaycdv1beta1 "gateway-cd/pkg/api/v1beta1"
+	"gateway-cd/pkg/controller"
+	"gateway-cd/pkg/gateway"
+	"gateway-cd/pkg/grafana"
+	"gateway-cd/pkg/integrations/scm"
+	"gateway-cd/pkg/metrics"
+	"gateway-cd/pkg/notifications"
+	"gateway-cd/pkg/otlp"
+	"gateway-cd/pkg/quota"
+	"gateway-cd/pkg/retry"
+	"gateway-cd/pkg/stats"
+	"gateway-cd/pkg/webhook"
+)
+
+// DefaultEventRecorderName is the component name events are recorded under
+const DefaultEventRecorderName = "gateway-cd-controller"
+
+// Options configures the embedded rollout engine
+type Options struct {
+	// MetricsProvider runs canary analysis. Analysis is skipped when nil.
+	MetricsProvider metrics.Provider
+	// ProviderCircuitBreaker configures the circuit breakers of the providers
+	// canaries configure in their analysis
+	ProviderCircuitBreaker metrics.CircuitBreakerOptions
+	// ProviderQueryCacheTTL is how long the providers canaries configure in
+	// their analysis cache query results. 0 only shares queries in flight.
+	ProviderQueryCacheTTL time.Duration
+	// Annotator writes rollout annotations to Grafana when set
+	Annotator *grafana.Annotator
+	// Timeline exports rollout events as OpenTelemetry log records when set
+	Timeline *otlp.Exporter
+	// Tracer exports reconcile, analysis and route update spans when set
+	Tracer *otlp.Tracer
+	// NotificationChannels receive the notifications of every canary that
+	// doesn't disable them. Canaries can add their own channels.
+	NotificationChannels []notifications.Channel
+	// SCM reports rollout progress to GitHub or GitLab deployments of
+	// canaries carrying the commit annotations when set
+	SCM *scm.Reporter
+	// EventRecorderName is the component name of recorded events
+	EventRecorderName string
+	// EnableWebhooks registers the CanaryDeployment validating and conversion
+	// webhooks, the CanaryDeployment webhook recording who set control
+	// annotations and the Approval webhooks recording the approver
+	EnableWebhooks bool
+	// TrustedRequesters are the users, e.g. the API server's service account,
+	// whose control actions the CanaryDeployment webhook admits as the API
+	// caller they record in requested-by
+	TrustedRequesters []string
+	// DisableStats skips registering the per-namespace rollout statistics collector
+	DisableStats bool
+	// Quotas queues rollouts of teams at their concurrent rollout quota when set
+	Quotas *quota.Checker
+	// Retry is the backoff and max retries of failed gateway operations.
+	// Defaults to retry.DefaultPolicy.
+	Retry retry.Policy
+	// Requeue is how soon canaries are reconciled again. Zero intervals
+	// default to controller.DefaultRequeueIntervals.
+	Requeue controller.RequeueIntervals
+	// DriftCheckInterval is how often the routes of active rollouts are
+	// checked for weights changed outside the rollout. 0 only checks them on
+	// route events.
+	DriftCheckInterval time.Duration
+	// AutoCanary generates a CanaryDeployment for every Deployment annotated
+	// with gateway-cd.io/enabled: "true", from its route and steps annotations
+	AutoCanary bool
+	// AutoCanarySelector restricts the Deployments AutoCanary generates
+	// canaries for, e.g. to the canary selector of a sharded engine
+	AutoCanarySelector labels.Selector
+}
+
+// Engine holds the components wired into a manager by AddToManager
+type Engine struct {
+	Reconciler     *controller.CanaryDeploymentReconciler
+	GatewayManager *gateway.Manager
+}
+
+// AddToScheme registers the API types the rollout engine reads and writes
+func AddToScheme(scheme *runtime.Scheme) error {
+	for _, add := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		gatewaycdv1alpha1.AddToScheme,
+		gatewaycdv1beta1.AddToScheme,
+		gatewayapi.AddToScheme,
+		gatewayapiv1alpha2.AddToScheme,
+	} {
+		if err := add(scheme); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddToManager constructs the reconciler, gateway manager and analysis engine
+// and registers them with mgr. The manager's scheme must include the types
+// registered by AddToScheme.
+func AddToManager(mgr ctrl.Manager, opts Options) (*Engine, error) {
+	if opts.EventRecorderName == "" {
+		opts.EventRecorderName = DefaultEventRecorderName
+	}
+	if opts.Retry == (retry.Policy{}) {
+		opts.Retry = retry.DefaultPolicy
+	}
+
+	engine := &Engine{
+		GatewayManager: gateway.NewManager(mgr.GetClient()),
+	}
+	engine.Reconciler = &controller.CanaryDeploymentReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor(opts.EventRecorderName),
+		GatewayManager:  engine.GatewayManager,
+		MetricsProvider: opts.MetricsProvider,
+		Annotator:       opts.Annotator,
+		Timeline:        opts.Timeline,
+		Tracer:          opts.Tracer,
+		Notifier:        notifications.NewNotifier(mgr.GetAPIReader(), opts.NotificationChannels, ctrl.Log.WithName("notifications")),
+		SCM:             opts.SCM,
+		APIReader:       mgr.GetAPIReader(),
+		Providers:       metrics.NewProviderCache(opts.ProviderCircuitBreaker, opts.ProviderQueryCacheTTL),
+		Quotas:          opts.Quotas,
+		Retry:           opts.Retry,
+		Requeue:         opts.Requeue,
+
+		DriftCheckInterval: opts.DriftCheckInterval,
+	}
+	if err := engine.Reconciler.SetupWithManager(mgr); err != nil {
+		return nil, fmt.Errorf("failed to set up CanaryDeployment controller: %w", err)
+	}
+
+	if opts.AutoCanary {
+		if err := (&controller.AutoCanaryReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor(opts.EventRecorderName),
+			Selector: opts.AutoCanarySelector,
+		}).SetupWithManager(mgr); err != nil {
+			return nil, fmt.Errorf("failed to set up auto-canary controller: %w", err)
+		}
+	}
+
+	if opts.Timeline != nil {
+		if err := mgr.Add(opts.Timeline); err != nil {
+			return nil, fmt.Errorf("failed to add rollout event exporter: %w", err)
+		}
+	}
+	if opts.Tracer != nil {
+		if err := mgr.Add(opts.Tracer); err != nil {
+			return nil, fmt.Errorf("failed to add span exporter: %w", err)
+		}
+	}
+
+	if !opts.DisableStats {
+		if err := ctrlmetrics.Registry.Register(stats.NewCollector(mgr.GetClient(), ctrl.Log.WithName("stats"))); err != nil {
+			return nil, fmt.Errorf("failed to register rollout statistics: %w", err)
+		}
+	}
+
+	if opts.EnableWebhooks {
+		if err := (&webhook.CanaryDeploymentValidator{}).SetupWithManager(mgr); err != nil {
+			return nil, fmt.Errorf("failed to set up CanaryDeployment webhook: %w", err)
+		}
+		if err := (&webhook.CanaryDeploymentDefaulter{TrustedRequesters: opts.TrustedRequesters}).SetupWithManager(mgr); err != nil {
+			return nil, fmt.Errorf("failed to set up CanaryDeployment mutating webhook: %w", err)
+		}
+		if err := (&webhook.ApprovalWebhook{}).SetupWithManager(mgr); err != nil {
+			return nil, fmt.Errorf("failed to set up Approval webhook: %w", err)
+		}
+	}
+
+	return engine, nil
+}
@@ -0,0 +1,59 @@
+package gatewaycd
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// Scope restricts the canaries a rollout engine manages, so several engines
+// can shard the canaries of a cluster by namespace or team
+type Scope struct {
+	// Namespaces are watched instead of all namespaces when set
+	Namespaces []string
+	// CanarySelector selects the CanaryDeployments the engine manages when set
+	CanarySelector labels.Selector
+}
+
+// ParseScope parses comma-separated namespaces and a label selector, either
+// of which may be empty
+func ParseScope(namespaces, selector string) (Scope, error) {
+	var scope Scope
+	for _, namespace := range strings.Split(namespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			scope.Namespaces = append(scope.Namespaces, namespace)
+		}
+	}
+	if selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return Scope{}, fmt.Errorf("invalid label selector %q: %w", selector, err)
+		}
+		scope.CanarySelector = parsed
+	}
+	return scope, nil
+}
+
+// CacheOptions returns the options of a manager cache that only watches the
+// namespaced resources of the scope's namespaces and the CanaryDeployments
+// matching its selector. Cluster-scoped resources are watched as usual.
+func (s Scope) CacheOptions() cache.Options {
+	var opts cache.Options
+	if len(s.Namespaces) > 0 {
+		opts.DefaultNamespaces = make(map[string]cache.Config, len(s.Namespaces))
+		for _, namespace := range s.Namespaces {
+			opts.DefaultNamespaces[namespace] = cache.Config{}
+		}
+	}
+	if s.CanarySelector != nil && !s.CanarySelector.Empty() {
+		opts.ByObject = map[client.Object]cache.ByObject{
+			&gatewaycdv1alpha1.CanaryDeployment{}: {Label: s.CanarySelector},
+		}
+	}
+	return opts
+}
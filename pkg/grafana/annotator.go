@@ -0,0 +1,117 @@
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// Annotator writes rollout annotations to Grafana so dashboards show when a
+// canary started, shifted, or finished and which change it carried
+type Annotator struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewAnnotator creates a new Grafana annotator
+func NewAnnotator(grafanaURL, token string) *Annotator {
+	return &Annotator{
+		baseURL: strings.TrimSuffix(grafanaURL, "/"),
+		token:   token,
+		client: &http.Client{
+			Timeout: time.Second * 10,
+		},
+	}
+}
+
+// annotationRequest is the payload of Grafana's POST /api/annotations
+type annotationRequest struct {
+	Time int64    `json:"time"`
+	Tags []string `json:"tags"`
+	Text string   `json:"text"`
+}
+
+// Annotate records an annotation for the canary with the given text
+func (a *Annotator) Annotate(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, text string) error {
+	payload := annotationRequest{
+		Time: time.Now().UnixMilli(),
+		Tags: Tags(canary),
+		Text: fmt.Sprintf("%s/%s: %s%s", canary.Namespace, canary.Name, text, describeChange(canary.Spec.Metadata)),
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/api/annotations", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("grafana annotation failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Tags returns the annotation tags identifying the canary and its change
+func Tags(canary *gatewaycdv1alpha1.CanaryDeployment) []string {
+	tags := []string{
+		"gateway-cd",
+		"namespace:" + canary.Namespace,
+		"canary:" + canary.Name,
+	}
+	if md := canary.Spec.Metadata; md != nil {
+		if md.GitSHA != "" {
+			tags = append(tags, "sha:"+md.GitSHA)
+		}
+		if md.Ticket != "" {
+			tags = append(tags, "ticket:"+md.Ticket)
+		}
+	}
+	return tags
+}
+
+// describeChange renders change metadata as a suffix for annotation text
+func describeChange(md *gatewaycdv1alpha1.ChangeMetadata) string {
+	if md == nil {
+		return ""
+	}
+
+	var parts []string
+	if md.GitSHA != "" {
+		parts = append(parts, "sha "+md.GitSHA)
+	}
+	if md.Author != "" {
+		parts = append(parts, "by "+md.Author)
+	}
+	if md.Ticket != "" {
+		parts = append(parts, "ticket "+md.Ticket)
+	}
+	if md.PullRequestURL != "" {
+		parts = append(parts, md.PullRequestURL)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
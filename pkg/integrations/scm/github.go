@@ -0,0 +1,125 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// gitHubDescriptionLimit is the longest description GitHub accepts on a
+// deployment status
+const gitHubDescriptionLimit = 140
+
+// gitHubProvider reports rollouts as GitHub Deployments of the commit, with
+// a deployment status per state
+type gitHubProvider struct {
+	baseURL string
+	token   string
+	client  *http.Client
+
+	mu  sync.Mutex
+	ids map[Deployment]int64
+}
+
+// newGitHubProvider creates a provider calling the GitHub REST API at baseURL
+func newGitHubProvider(baseURL, token string, client *http.Client) *gitHubProvider {
+	return &gitHubProvider{baseURL: baseURL, token: token, client: client, ids: map[Deployment]int64{}}
+}
+
+// gitHubDeployment is the part of a GitHub deployment the provider reads
+type gitHubDeployment struct {
+	ID int64 `json:"id"`
+}
+
+// Report posts a deployment status, creating the deployment of the commit
+// to the environment on the first report
+func (p *gitHubProvider) Report(ctx context.Context, deployment Deployment, state State, description string) error {
+	id, err := p.deploymentID(ctx, deployment)
+	if err != nil {
+		return err
+	}
+	if len(description) > gitHubDescriptionLimit {
+		description = description[:gitHubDescriptionLimit-3] + "..."
+	}
+
+	statusURL := fmt.Sprintf("%s/repos/%s/deployments/%d/statuses", p.baseURL, deployment.Repository, id)
+	if err := doJSON(ctx, p.client, http.MethodPost, statusURL, p.headers(), map[string]interface{}{
+		"state":       gitHubState(state),
+		"description": description,
+		"environment": deployment.Environment,
+	}, nil); err != nil {
+		return fmt.Errorf("failed to post GitHub deployment status: %w", err)
+	}
+
+	if state == StateSuccess || state == StateFailure {
+		p.mu.Lock()
+		delete(p.ids, deployment)
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// deploymentID finds the deployment of the commit to the environment, or
+// creates it
+func (p *gitHubProvider) deploymentID(ctx context.Context, deployment Deployment) (int64, error) {
+	p.mu.Lock()
+	id, ok := p.ids[deployment]
+	p.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	query := url.Values{"sha": {deployment.Commit}, "environment": {deployment.Environment}}
+	var existing []gitHubDeployment
+	if err := doJSON(ctx, p.client, http.MethodGet, fmt.Sprintf("%s/repos/%s/deployments?%s", p.baseURL, deployment.Repository, query.Encode()),
+		p.headers(), nil, &existing); err != nil {
+		return 0, fmt.Errorf("failed to list GitHub deployments: %w", err)
+	}
+
+	var created gitHubDeployment
+	if len(existing) > 0 {
+		created = existing[0]
+	} else if err := doJSON(ctx, p.client, http.MethodPost, fmt.Sprintf("%s/repos/%s/deployments", p.baseURL, deployment.Repository),
+		p.headers(), map[string]interface{}{
+			"ref":               deployment.Commit,
+			"environment":       deployment.Environment,
+			"description":       "Canary rollout",
+			"auto_merge":        false,
+			"required_contexts": []string{},
+		}, &created); err != nil {
+		return 0, fmt.Errorf("failed to create GitHub deployment: %w", err)
+	}
+
+	p.mu.Lock()
+	p.ids[deployment] = created.ID
+	p.mu.Unlock()
+	return created.ID, nil
+}
+
+// headers are the headers of GitHub API requests
+func (p *gitHubProvider) headers() map[string]string {
+	headers := map[string]string{
+		"Accept":               "application/vnd.github+json",
+		"X-GitHub-Api-Version": "2022-11-28",
+	}
+	if p.token != "" {
+		headers["Authorization"] = "Bearer " + p.token
+	}
+	return headers
+}
+
+// gitHubState is the GitHub deployment status state of a rollout state
+func gitHubState(state State) string {
+	switch state {
+	case StatePending:
+		return "pending"
+	case StateSuccess:
+		return "success"
+	case StateFailure:
+		return "failure"
+	default:
+		return "in_progress"
+	}
+}
@@ -0,0 +1,123 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// gitLabProvider reports rollouts as GitLab deployments of the commit to the
+// environment, updating the deployment's status with each state
+type gitLabProvider struct {
+	baseURL string
+	token   string
+	client  *http.Client
+
+	mu  sync.Mutex
+	ids map[Deployment]int64
+}
+
+// newGitLabProvider creates a provider calling the GitLab REST API at baseURL
+func newGitLabProvider(baseURL, token string, client *http.Client) *gitLabProvider {
+	return &gitLabProvider{baseURL: baseURL, token: token, client: client, ids: map[Deployment]int64{}}
+}
+
+// gitLabDeployment is the part of a GitLab deployment the provider reads
+type gitLabDeployment struct {
+	ID     int64  `json:"id"`
+	SHA    string `json:"sha"`
+	Status string `json:"status"`
+}
+
+// Report creates the deployment of the commit on the first report and
+// updates its status afterwards. GitLab deployments carry no description.
+func (p *gitLabProvider) Report(ctx context.Context, deployment Deployment, state State, _ string) error {
+	deployments := fmt.Sprintf("%s/projects/%s/deployments", p.baseURL, url.PathEscape(deployment.Repository))
+	status := gitLabStatus(state)
+
+	existing, err := p.find(ctx, deployments, deployment)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		var created gitLabDeployment
+		if err := doJSON(ctx, p.client, http.MethodPost, deployments, p.headers(), map[string]interface{}{
+			"environment": deployment.Environment,
+			"sha":         deployment.Commit,
+			"ref":         deployment.Ref,
+			"tag":         false,
+			"status":      status,
+		}, &created); err != nil {
+			return fmt.Errorf("failed to create GitLab deployment: %w", err)
+		}
+		p.remember(deployment, state, created.ID)
+		return nil
+	}
+
+	if existing.Status != status {
+		if err := doJSON(ctx, p.client, http.MethodPut, fmt.Sprintf("%s/%d", deployments, existing.ID), p.headers(),
+			map[string]string{"status": status}, nil); err != nil {
+			return fmt.Errorf("failed to update GitLab deployment: %w", err)
+		}
+	}
+	p.remember(deployment, state, existing.ID)
+	return nil
+}
+
+// find returns the deployment of the commit to the environment, if any
+func (p *gitLabProvider) find(ctx context.Context, deployments string, deployment Deployment) (*gitLabDeployment, error) {
+	p.mu.Lock()
+	id, ok := p.ids[deployment]
+	p.mu.Unlock()
+	if ok {
+		return &gitLabDeployment{ID: id}, nil
+	}
+
+	query := url.Values{"environment": {deployment.Environment}, "order_by": {"id"}, "sort": {"desc"}, "per_page": {"50"}}
+	var recent []gitLabDeployment
+	if err := doJSON(ctx, p.client, http.MethodGet, deployments+"?"+query.Encode(), p.headers(), nil, &recent); err != nil {
+		return nil, fmt.Errorf("failed to list GitLab deployments: %w", err)
+	}
+	for i := range recent {
+		if recent[i].SHA == deployment.Commit {
+			return &recent[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// remember caches the deployment ID until the rollout reaches a final state
+func (p *gitLabProvider) remember(deployment Deployment, state State, id int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if state == StateSuccess || state == StateFailure {
+		delete(p.ids, deployment)
+		return
+	}
+	p.ids[deployment] = id
+}
+
+// headers are the headers of GitLab API requests
+func (p *gitLabProvider) headers() map[string]string {
+	headers := map[string]string{}
+	if p.token != "" {
+		headers["PRIVATE-TOKEN"] = p.token
+	}
+	return headers
+}
+
+// gitLabStatus is the GitLab deployment status of a rollout state. GitLab has
+// no waiting status for a started deployment, so a pending rollout stays
+// running.
+func gitLabStatus(state State) string {
+	switch state {
+	case StateSuccess:
+		return "success"
+	case StateFailure:
+		return "failed"
+	default:
+		return "running"
+	}
+}
@@ -0,0 +1,201 @@
+// Package scm reports rollout progress to GitHub Deployments and GitLab
+// environments, so pull requests show the canary status of their commit and
+// whether it was promoted or rolled back
+package scm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// Annotations on a CanaryDeployment selecting the commit its rollout is
+// reported for
+const (
+	// AnnotationRepository is the GitHub owner/repo or GitLab project path
+	AnnotationRepository = "gateway-cd.io/scm-repository"
+	// AnnotationCommit is the commit SHA being rolled out. Defaults to
+	// spec.metadata.gitSHA.
+	AnnotationCommit = "gateway-cd.io/scm-commit"
+	// AnnotationRef is the branch or tag of the commit, which GitLab
+	// deployments require. Defaults to main.
+	AnnotationRef = "gateway-cd.io/scm-ref"
+	// AnnotationEnvironment is the deployment environment. Defaults to the
+	// canary namespace.
+	AnnotationEnvironment = "gateway-cd.io/scm-environment"
+)
+
+// State is the state of a rollout as reported to the SCM
+type State string
+
+const (
+	// StateInProgress is reported while traffic shifts to the canary
+	StateInProgress State = "InProgress"
+	// StatePending is reported while the rollout waits for approval
+	StatePending State = "Pending"
+	// StateSuccess is reported when the canary is promoted
+	StateSuccess State = "Success"
+	// StateFailure is reported when the canary is rolled back
+	StateFailure State = "Failure"
+)
+
+// Deployment is the rollout of a commit to an environment
+type Deployment struct {
+	Repository  string
+	Commit      string
+	Ref         string
+	Environment string
+}
+
+// Provider posts deployment states to an SCM
+type Provider interface {
+	Report(ctx context.Context, deployment Deployment, state State, description string) error
+}
+
+// NewProvider creates the provider of the given type (github or gitlab)
+// calling the API at baseURL, or the public API when empty
+func NewProvider(providerType, baseURL, token string) (Provider, error) {
+	client := &http.Client{Timeout: time.Second * 10}
+	switch providerType {
+	case "github":
+		if baseURL == "" {
+			baseURL = "https://api.github.com"
+		}
+		return newGitHubProvider(strings.TrimSuffix(baseURL, "/"), token, client), nil
+	case "gitlab":
+		if baseURL == "" {
+			baseURL = "https://gitlab.com/api/v4"
+		}
+		return newGitLabProvider(strings.TrimSuffix(baseURL, "/"), token, client), nil
+	default:
+		return nil, fmt.Errorf("unsupported SCM provider %q", providerType)
+	}
+}
+
+// queueSize is the number of reports waiting to be posted before new ones
+// are dropped
+const queueSize = 100
+
+// report is a state waiting to be posted
+type report struct {
+	deployment  Deployment
+	state       State
+	description string
+}
+
+// Reporter posts the rollout states of annotated canaries to a provider.
+// Reports are posted one at a time in the background, in the order they were
+// made, so a slow SCM never blocks reconciliation and a final state is never
+// overtaken by an earlier one.
+type Reporter struct {
+	provider Provider
+	queue    chan report
+	log      logr.Logger
+}
+
+// NewReporter creates a reporter posting to provider
+func NewReporter(provider Provider, log logr.Logger) *Reporter {
+	r := &Reporter{
+		provider: provider,
+		queue:    make(chan report, queueSize),
+		log:      log,
+	}
+	go r.run()
+	return r
+}
+
+// Report posts the state of the canary's rollout if it carries the
+// repository annotation and a commit
+func (r *Reporter) Report(canary *gatewaycdv1alpha1.CanaryDeployment, state State, description string) {
+	deployment, ok := deploymentOf(canary)
+	if !ok {
+		return
+	}
+	select {
+	case r.queue <- report{deployment: deployment, state: state, description: description}:
+	default:
+		r.log.Info("Dropped SCM deployment status, too many pending", "canary", canary.Namespace+"/"+canary.Name, "state", state)
+	}
+}
+
+// run posts queued reports
+func (r *Reporter) run() {
+	for report := range r.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		if err := r.provider.Report(ctx, report.deployment, report.state, report.description); err != nil {
+			r.log.Error(err, "Failed to report deployment status", "repository", report.deployment.Repository,
+				"commit", report.deployment.Commit, "state", report.state)
+		}
+		cancel()
+	}
+}
+
+// deploymentOf reads the deployment a canary reports to from its annotations
+func deploymentOf(canary *gatewaycdv1alpha1.CanaryDeployment) (Deployment, bool) {
+	deployment := Deployment{
+		Repository:  canary.Annotations[AnnotationRepository],
+		Commit:      canary.Annotations[AnnotationCommit],
+		Ref:         canary.Annotations[AnnotationRef],
+		Environment: canary.Annotations[AnnotationEnvironment],
+	}
+	if deployment.Commit == "" && canary.Spec.Metadata != nil {
+		deployment.Commit = canary.Spec.Metadata.GitSHA
+	}
+	if deployment.Repository == "" || deployment.Commit == "" {
+		return Deployment{}, false
+	}
+	if deployment.Ref == "" {
+		deployment.Ref = "main"
+	}
+	if deployment.Environment == "" {
+		deployment.Environment = canary.Namespace
+	}
+	return deployment, true
+}
+
+// doJSON sends payload, if any, as JSON with the given headers and decodes
+// the response into out, if set
+func doJSON(ctx context.Context, client *http.Client, method, url string, headers map[string]string, payload, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s failed with status %d", method, req.URL.Path, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
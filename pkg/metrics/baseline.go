@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// DefaultSuccessRateTolerance is how far the canary success rate may fall
+	// below the baseline's when the canary doesn't set it
+	DefaultSuccessRateTolerance = 0.01
+	// DefaultBaselineTolerance is the fraction by which canary latency and
+	// metrics may be worse than the baseline's when the canary doesn't set it
+	DefaultBaselineTolerance = 0.1
+)
+
+// BaselineService is the Service of the baseline of a canary
+func BaselineService(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	return canary.Spec.Service.Name + "-baseline"
+}
+
+// RenderBaselineQuery renders a metric query for the baseline: the
+// {{.CanaryService}} placeholder selects the baseline Service instead
+func RenderBaselineQuery(query string, canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	return RenderQuery(strings.ReplaceAll(query, "{{.CanaryService}}", BaselineService(canary)), canary)
+}
+
+// SuccessRateTolerance is the success rate tolerance of a baseline
+func SuccessRateTolerance(baseline *gatewaycdv1alpha1.BaselineSpec) float64 {
+	if baseline.SuccessRateTolerance > 0 {
+		return baseline.SuccessRateTolerance
+	}
+	return DefaultSuccessRateTolerance
+}
+
+// BaselineTolerance is the latency and metric tolerance of a baseline
+func BaselineTolerance(baseline *gatewaycdv1alpha1.BaselineSpec) float64 {
+	if baseline.Tolerance > 0 {
+		return baseline.Tolerance
+	}
+	return DefaultBaselineTolerance
+}
+
+// baselineBound derives the bound a canary metric is held to from its
+// baseline value. The operator tells which direction is better: for < and <=
+// the canary may be up to the tolerance above the baseline, for > and >= up
+// to the tolerance below it. Other operators have no direction and keep the
+// configured threshold.
+func baselineBound(baseline, threshold, tolerance float64, operator string) float64 {
+	switch operator {
+	case "<", "<=":
+		return baseline * (1 + tolerance)
+	case ">", ">=":
+		return baseline * (1 - tolerance)
+	default:
+		return threshold
+	}
+}
+
+// compareToBaseline evaluates a metric on the canary and the baseline and
+// checks the canary against the bound derived from the baseline
+func (p *PrometheusProvider) compareToBaseline(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, metric gatewaycdv1alpha1.AnalysisMetric) (*gatewaycdv1alpha1.MetricResult, error) {
+	value, err := p.metricValue(ctx, canary, metric, RenderQuery(metric.Query, canary))
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := p.metricValue(ctx, canary, metric, RenderBaselineQuery(metric.Query, canary))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query baseline: %w", err)
+	}
+
+	operator := metric.Operator
+	if operator == "<" || operator == ">" {
+		// The bound allows a canary as good as the baseline
+		operator += "="
+	}
+	bound := baselineBound(baseline, metric.Threshold, BaselineTolerance(canary.Spec.Baseline), metric.Operator)
+	return &gatewaycdv1alpha1.MetricResult{
+		Name:          metric.Name,
+		Value:         value,
+		Threshold:     bound,
+		BaselineValue: baseline,
+		Passed:        compareValues(value, bound, operator),
+	}, nil
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	providerCacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gatewaycd_provider_query_cache_hits_total",
+		Help: "Number of metrics provider queries answered from the cache or by an identical query in flight.",
+	}, []string{"provider"})
+
+	providerCacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gatewaycd_provider_query_cache_misses_total",
+		Help: "Number of metrics provider queries sent to the provider.",
+	}, []string{"provider"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(providerCacheHits, providerCacheMisses)
+}
+
+// QueryCache keeps the results of a provider's queries for a short TTL and
+// shares a query in flight with the concurrent callers of the same query, so
+// canaries analysed with identical queries query the provider once. Failed
+// queries aren't cached.
+type QueryCache struct {
+	name string
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	results   map[string]cachedResult
+	inflight  map[string]*inflightQuery
+	lastSweep time.Time
+}
+
+// cachedResult is the result of a query and when it expires
+type cachedResult struct {
+	value   float64
+	expires time.Time
+}
+
+// inflightQuery is a query being sent to the provider. done is closed once
+// value and err are set.
+type inflightQuery struct {
+	done  chan struct{}
+	value float64
+	err   error
+}
+
+// NewQueryCache creates a cache for the provider called name whose results
+// expire after ttl. A zero ttl only shares queries in flight.
+func NewQueryCache(name string, ttl time.Duration) *QueryCache {
+	return &QueryCache{
+		name:     name,
+		ttl:      ttl,
+		results:  map[string]cachedResult{},
+		inflight: map[string]*inflightQuery{},
+	}
+}
+
+// CacheQueries has the PromQL and MQL queries of a Prometheus or Cloud
+// Monitoring provider, including those of its analysis, go through cache.
+// Other providers are returned unchanged.
+func CacheQueries(provider Provider, cache *QueryCache) Provider {
+	if p, ok := provider.(*PrometheusProvider); ok {
+		p.cache = cache
+	}
+	return provider
+}
+
+// Do returns the cached result of query, the result of the same query in
+// flight, or else the result of fetch. fetch runs detached from the caller's
+// cancellation, as other callers may wait for it.
+func (c *QueryCache) Do(ctx context.Context, query string, fetch func(ctx context.Context, query string) (float64, error)) (float64, error) {
+	c.mu.Lock()
+	if result, ok := c.results[query]; ok && time.Now().Before(result.expires) {
+		c.mu.Unlock()
+		providerCacheHits.WithLabelValues(c.name).Inc()
+		return result.value, nil
+	}
+	call, ok := c.inflight[query]
+	if ok {
+		providerCacheHits.WithLabelValues(c.name).Inc()
+	} else {
+		call = &inflightQuery{done: make(chan struct{})}
+		c.inflight[query] = call
+		providerCacheMisses.WithLabelValues(c.name).Inc()
+		go c.fetch(context.WithoutCancel(ctx), query, call, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// fetch runs a query in flight and caches its result unless it failed
+func (c *QueryCache) fetch(ctx context.Context, query string, call *inflightQuery, fetch func(ctx context.Context, query string) (float64, error)) {
+	call.value, call.err = fetch(ctx, query)
+
+	c.mu.Lock()
+	now := time.Now()
+	delete(c.inflight, query)
+	if call.err == nil && c.ttl > 0 {
+		c.results[query] = cachedResult{value: call.value, expires: now.Add(c.ttl)}
+	}
+	c.sweep(now)
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// sweep drops the expired results at most once per TTL, so queries of
+// canaries that are gone don't accumulate. c.mu must be held.
+func (c *QueryCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	for query, result := range c.results {
+		if !now.Before(result.expires) {
+			delete(c.results, query)
+		}
+	}
+	c.lastSweep = now
+}
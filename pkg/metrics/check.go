@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// closing maps the opening brackets of PromQL to their closing bracket
+var closing = map[rune]rune{'(': ')', '[': ']', '{': '}'}
+
+// CheckQuery checks a rendered Prometheus query for the mistakes that keep
+// it from parsing: unbalanced brackets, unterminated strings and placeholders
+// RenderQuery does not know. It doesn't type-check the query.
+func CheckQuery(query string) error {
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("query is empty")
+	}
+	if i := strings.Index(query, "{{"); i >= 0 {
+		end := strings.Index(query[i:], "}}")
+		if end < 0 {
+			return fmt.Errorf("unterminated placeholder at offset %d", i)
+		}
+		return fmt.Errorf("unknown placeholder %s", query[i:i+end+2])
+	}
+
+	var open []rune
+	var quote rune
+	escaped, comment := false, false
+	for i, r := range query {
+		if comment {
+			// A comment runs to the end of the line
+			comment = r != '\n'
+			continue
+		}
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\' && quote != '`':
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch r {
+		case '"', '\'', '`':
+			quote = r
+		case '#':
+			comment = true
+		case '(', '[', '{':
+			open = append(open, r)
+		case ')', ']', '}':
+			if len(open) == 0 || closing[open[len(open)-1]] != r {
+				return fmt.Errorf("unexpected %q at offset %d", r, i)
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if quote != 0 {
+		return fmt.Errorf("unterminated string")
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("unclosed %q", open[len(open)-1])
+	}
+	return nil
+}
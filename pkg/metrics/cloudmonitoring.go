@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCloudMonitoringAddress is the Google Cloud Monitoring API endpoint
+const DefaultCloudMonitoringAddress = "https://monitoring.googleapis.com"
+
+// metadataTokenURL is the GKE metadata server endpoint returning an access
+// token of the workload's Google service account
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// NewCloudMonitoringProvider creates a provider querying the Google Cloud
+// Monitoring metrics of project, authenticated as the workload's Google
+// service account through workload identity
+func NewCloudMonitoringProvider(address, project string) Provider {
+	client := &http.Client{
+		Timeout:   time.Second * 30,
+		Transport: &metadataTokenTransport{next: http.DefaultTransport},
+	}
+	return newCloudMonitoringProvider(strings.TrimSuffix(address, "/"), project, client)
+}
+
+// newCloudMonitoringProvider creates a Prometheus provider whose PromQL
+// queries go to the Managed Service for Prometheus API of the project and
+// whose MQL queries go to the Cloud Monitoring timeSeries:query API, so
+// analysis and baselines work the same as with Prometheus
+func newCloudMonitoringProvider(baseURL, project string, client *http.Client) *PrometheusProvider {
+	projectURL := fmt.Sprintf("%s/v1/projects/%s", baseURL, url.PathEscape(project))
+	provider := &PrometheusProvider{
+		baseURL: projectURL + "/location/global/prometheus",
+		client:  client,
+	}
+	mqlURL := fmt.Sprintf("%s/v3/projects/%s/timeSeries:query", baseURL, url.PathEscape(project))
+	provider.instant = func(ctx context.Context, query string) (float64, error) {
+		if isMQL(query) {
+			return queryMQL(ctx, client, mqlURL, query)
+		}
+		return provider.query(ctx, query)
+	}
+	return provider
+}
+
+// isMQL reports whether a query is written in the Monitoring Query Language,
+// whose queries start with a fetch operation
+func isMQL(query string) bool {
+	query = strings.TrimLeft(strings.TrimSpace(query), "{ \t\n")
+	return strings.HasPrefix(query, "fetch ") || strings.HasPrefix(query, "fetch\n")
+}
+
+// mqlResponse is the part of a timeSeries:query response holding the points
+type mqlResponse struct {
+	TimeSeriesData []struct {
+		PointData []struct {
+			Values []struct {
+				DoubleValue *float64 `json:"doubleValue"`
+				// Int64Value is encoded as a string
+				Int64Value *json.Number `json:"int64Value"`
+			} `json:"values"`
+		} `json:"pointData"`
+	} `json:"timeSeriesData"`
+}
+
+// queryMQL executes an MQL query and returns the latest value of its first time series
+func queryMQL(ctx context.Context, client *http.Client, mqlURL, query string) (float64, error) {
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", mqlURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("cloud monitoring query failed with status %d", resp.StatusCode)
+	}
+
+	var mqlResp mqlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mqlResp); err != nil {
+		return 0, fmt.Errorf("failed to decode cloud monitoring response: %w", err)
+	}
+	if len(mqlResp.TimeSeriesData) == 0 || len(mqlResp.TimeSeriesData[0].PointData) == 0 ||
+		len(mqlResp.TimeSeriesData[0].PointData[0].Values) == 0 {
+		return 0, fmt.Errorf("no data returned from cloud monitoring query")
+	}
+
+	// Points are returned newest first
+	value := mqlResp.TimeSeriesData[0].PointData[0].Values[0]
+	switch {
+	case value.DoubleValue != nil:
+		return *value.DoubleValue, nil
+	case value.Int64Value != nil:
+		return value.Int64Value.Float64()
+	}
+	return 0, fmt.Errorf("unexpected value type from cloud monitoring")
+}
+
+// metadataTokenTransport authenticates requests with an access token of the
+// workload's Google service account, fetched from the metadata server and
+// refreshed shortly before it expires
+type metadataTokenTransport struct {
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// RoundTrip sets the Authorization header and sends the request
+func (t *metadataTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.accessToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.next.RoundTrip(req)
+}
+
+// accessToken returns the cached token, fetching a new one when it expires
+// within a minute
+func (t *metadataTokenTransport) accessToken(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Until(t.expires) > time.Minute {
+		return t.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", metadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token from the metadata server: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("metadata server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode access token: %w", err)
+	}
+	t.token = token.AccessToken
+	t.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return t.token, nil
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// Compatibility adapts the queries of a Prometheus provider to a
+// Prometheus-compatible backend
+type Compatibility struct {
+	// Backend is the server behind the provider's address; empty is Prometheus
+	Backend gatewaycdv1alpha1.PrometheusBackend
+	// TenantID is the tenant queried on a multi-tenant Thanos or Mimir
+	TenantID string
+	// Headers are sent with every query
+	Headers map[string]string
+	// LookbackDelta overrides how far back queries look for the latest
+	// sample of a series; zero keeps the backend's default
+	LookbackDelta time.Duration
+	// PartialResponse accepts partial Thanos responses
+	PartialResponse bool
+}
+
+// ParseCompatibility converts the compatibility settings of a provider spec.
+// A nil spec is plain Prometheus.
+func ParseCompatibility(spec *gatewaycdv1alpha1.PrometheusCompatibility) (Compatibility, error) {
+	if spec == nil {
+		return Compatibility{}, nil
+	}
+	compat := Compatibility{
+		Backend:         spec.Backend,
+		TenantID:        spec.TenantID,
+		Headers:         spec.Headers,
+		PartialResponse: spec.PartialResponse,
+	}
+	if spec.LookbackDelta != "" {
+		lookback, err := time.ParseDuration(spec.LookbackDelta)
+		if err != nil {
+			return Compatibility{}, err
+		}
+		compat.LookbackDelta = lookback
+	}
+	return compat, nil
+}
+
+// prometheusBackends are the supported Prometheus-compatible backends
+var prometheusBackends = []gatewaycdv1alpha1.PrometheusBackend{
+	gatewaycdv1alpha1.PrometheusBackendPrometheus,
+	gatewaycdv1alpha1.PrometheusBackendVictoriaMetrics,
+	gatewaycdv1alpha1.PrometheusBackendThanos,
+	gatewaycdv1alpha1.PrometheusBackendMimir,
+}
+
+// ParseBackend returns the Prometheus-compatible backend named name, ignoring case
+func ParseBackend(name string) (gatewaycdv1alpha1.PrometheusBackend, error) {
+	for _, backend := range prometheusBackends {
+		if strings.EqualFold(name, string(backend)) {
+			return backend, nil
+		}
+	}
+	return "", fmt.Errorf("unknown Prometheus backend %q", name)
+}
+
+// NewPrometheusCompatibleProvider creates a Prometheus provider querying a
+// Prometheus-compatible backend
+func NewPrometheusCompatibleProvider(address string, compat Compatibility) Provider {
+	return &PrometheusProvider{
+		baseURL: strings.TrimSuffix(address, "/"),
+		client:  &http.Client{Timeout: time.Second * 30},
+		compat:  compat,
+	}
+}
+
+// setParams adds the query parameters the backend needs to q
+func (c Compatibility) setParams(q url.Values) {
+	lookback := ""
+	if c.LookbackDelta > 0 {
+		lookback = strconv.FormatFloat(c.LookbackDelta.Seconds(), 'f', -1, 64)
+	}
+	switch c.Backend {
+	case gatewaycdv1alpha1.PrometheusBackendVictoriaMetrics:
+		// VictoriaMetrics caches the responses of recent time ranges, which
+		// would hide the latest samples of a canary
+		q.Set("nocache", "1")
+		if lookback != "" {
+			q.Set("max_lookback", lookback)
+		}
+	case gatewaycdv1alpha1.PrometheusBackendThanos:
+		q.Set("dedup", "true")
+		q.Set("partial_response", strconv.FormatBool(c.PartialResponse))
+		if lookback != "" {
+			q.Set("lookback_delta", lookback)
+		}
+	case gatewaycdv1alpha1.PrometheusBackendMimir:
+	default:
+		if lookback != "" {
+			q.Set("lookback_delta", lookback)
+		}
+	}
+}
+
+// setHeaders adds the tenant and extra headers of the backend to req
+func (c Compatibility) setHeaders(req *http.Request) {
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+	if c.TenantID == "" {
+		return
+	}
+	switch c.Backend {
+	case gatewaycdv1alpha1.PrometheusBackendThanos:
+		req.Header.Set("THANOS-TENANT", c.TenantID)
+	case gatewaycdv1alpha1.PrometheusBackendMimir:
+		req.Header.Set("X-Scope-OrgID", c.TenantID)
+	}
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// CompositeProvider runs the analysis of several providers, e.g. Prometheus
+// for HTTP metrics and a trace backend for span metrics, and passes only if
+// all of them pass
+type CompositeProvider struct {
+	providers []Provider
+}
+
+// NewCompositeProvider combines providers. GetMetric queries the first one.
+func NewCompositeProvider(providers ...Provider) *CompositeProvider {
+	return &CompositeProvider{providers: providers}
+}
+
+// RunAnalysis runs every provider and merges their results
+func (p *CompositeProvider) RunAnalysis(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (*AnalysisResult, error) {
+	merged := &AnalysisResult{
+		Phase:     "Running",
+		StartedAt: &metav1.Time{Time: time.Now()},
+		Passed:    true,
+	}
+
+	for _, provider := range p.providers {
+		result, err := provider.RunAnalysis(ctx, canary)
+		if err != nil {
+			merged.Phase = "Failed"
+			merged.Passed = false
+			return merged, err
+		}
+
+		merged.MetricResults = append(merged.MetricResults, result.MetricResults...)
+		if result.SuccessRate != 0 {
+			merged.SuccessRate = result.SuccessRate
+		}
+		if result.AverageLatency != 0 {
+			merged.AverageLatency = result.AverageLatency
+		}
+		if result.BaselineSuccessRate != 0 {
+			merged.BaselineSuccessRate = result.BaselineSuccessRate
+		}
+		if result.BaselineLatency != 0 {
+			merged.BaselineLatency = result.BaselineLatency
+		}
+		merged.Passed = merged.Passed && result.Passed
+	}
+
+	merged.Phase = "Successful"
+	if !merged.Passed {
+		merged.Phase = "Failed"
+	}
+	merged.CompletedAt = &metav1.Time{Time: time.Now()}
+	return merged, nil
+}
+
+// GetMetric runs the query against the first provider
+func (p *CompositeProvider) GetMetric(ctx context.Context, query string) (float64, error) {
+	if len(p.providers) == 0 {
+		return 0, errors.New("no metrics provider configured")
+	}
+	return p.providers[0].GetMetric(ctx, query)
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// Connection holds the address and credentials of a provider configured on a canary
+type Connection struct {
+	// Address is the base URL of the provider
+	Address string
+	// Project is the Google Cloud project of a CloudMonitoring provider
+	Project string
+	// BearerToken is sent in the Authorization header when set
+	BearerToken string
+	// Username and Password are sent as basic auth when Username is set
+	Username string
+	Password string
+	// InsecureSkipVerify disables TLS certificate verification
+	InsecureSkipVerify bool
+	// Compatibility adapts a Prometheus provider to a compatible backend
+	Compatibility Compatibility
+}
+
+// NewProvider creates a provider of the given type for a connection
+func NewProvider(providerType gatewaycdv1alpha1.ProviderType, conn Connection) (Provider, error) {
+	baseURL := strings.TrimSuffix(conn.Address, "/")
+	client := conn.httpClient()
+	switch providerType {
+	case gatewaycdv1alpha1.ProviderTypePrometheus:
+		return &PrometheusProvider{baseURL: baseURL, client: client, compat: conn.Compatibility}, nil
+	case gatewaycdv1alpha1.ProviderTypeTempo:
+		return &TempoProvider{baseURL: baseURL, client: client}, nil
+	case gatewaycdv1alpha1.ProviderTypeJaeger:
+		return &JaegerProvider{baseURL: baseURL, client: client}, nil
+	case gatewaycdv1alpha1.ProviderTypeCloudMonitoring:
+		if conn.Project == "" {
+			return nil, fmt.Errorf("provider type %q requires a project", providerType)
+		}
+		if conn.BearerToken == "" && conn.Username == "" {
+			// Without a secret, authenticate through workload identity
+			client.Transport = &metadataTokenTransport{next: client.Transport}
+		}
+		return newCloudMonitoringProvider(baseURL, conn.Project, client), nil
+	}
+	return nil, fmt.Errorf("unsupported provider type %q", providerType)
+}
+
+// httpClient returns a client sending the connection's credentials
+func (c Connection) httpClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{
+		Timeout:   time.Second * 30,
+		Transport: &authTransport{conn: c, next: transport},
+	}
+}
+
+// authTransport adds the connection's credentials to every request
+type authTransport struct {
+	conn Connection
+	next http.RoundTripper
+}
+
+// RoundTrip sets the Authorization header and sends the request
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch {
+	case t.conn.BearerToken != "":
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+t.conn.BearerToken)
+	case t.conn.Username != "":
+		req = req.Clone(req.Context())
+		req.SetBasicAuth(t.conn.Username, t.conn.Password)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// ProviderCache keeps the providers of canaries that override the metrics
+// provider, so their circuit breakers persist across reconciles. A provider
+// is recreated when the canary's connection changes.
+type ProviderCache struct {
+	opts     CircuitBreakerOptions
+	queryTTL time.Duration
+
+	mu        sync.Mutex
+	providers map[string]cachedProvider
+}
+
+// cachedProvider is a provider together with the configuration it was created for
+type cachedProvider struct {
+	providerType gatewaycdv1alpha1.ProviderType
+	conn         Connection
+	provider     Provider
+}
+
+// NewProviderCache creates a cache whose providers trip their circuit breakers
+// per opts and cache query results for queryTTL
+func NewProviderCache(opts CircuitBreakerOptions, queryTTL time.Duration) *ProviderCache {
+	return &ProviderCache{
+		opts:      opts,
+		queryTTL:  queryTTL,
+		providers: map[string]cachedProvider{},
+	}
+}
+
+// Get returns the provider of the canary identified by key, creating it if
+// the canary has none yet or its configuration changed
+func (c *ProviderCache) Get(key string, providerType gatewaycdv1alpha1.ProviderType, conn Connection) (Provider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.providers[key]; ok && cached.providerType == providerType && reflect.DeepEqual(cached.conn, conn) {
+		return cached.provider, nil
+	}
+	provider, err := NewProvider(providerType, conn)
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s/%s", strings.ToLower(string(providerType)), key)
+	provider = CacheQueries(provider, NewQueryCache(name, c.queryTTL))
+	instrumented := NewInstrumentedProvider(name, provider, c.opts)
+	c.providers[key] = cachedProvider{providerType: providerType, conn: conn, provider: instrumented}
+	return instrumented, nil
+}
+
+// Forget drops the provider of the canary identified by key. A nil cache holds nothing.
+func (c *ProviderCache) Forget(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.providers, key)
+}
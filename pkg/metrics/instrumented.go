@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/otlp"
+)
+
+// ErrProviderUnavailable is returned while a provider's circuit breaker is open
+var ErrProviderUnavailable = errors.New("metrics provider unavailable")
+
+var (
+	providerQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gatewaycd_provider_query_duration_seconds",
+		Help:    "Latency of metrics provider queries.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider", "operation"})
+
+	providerQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gatewaycd_provider_query_errors_total",
+		Help: "Number of failed metrics provider queries.",
+	}, []string{"provider", "operation"})
+
+	providerCircuitOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gatewaycd_provider_circuit_open",
+		Help: "Whether the circuit breaker of a metrics provider is open (1) or closed (0).",
+	}, []string{"provider"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(providerQueryDuration, providerQueryErrors, providerCircuitOpen)
+}
+
+// CircuitBreakerOptions configures when a provider is considered unhealthy
+type CircuitBreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit
+	FailureThreshold int
+	// OpenDuration is how long the circuit stays open before a trial query is allowed
+	OpenDuration time.Duration
+}
+
+// InstrumentedProvider wraps a Provider with query metrics and a circuit breaker
+type InstrumentedProvider struct {
+	name     string
+	provider Provider
+	opts     CircuitBreakerOptions
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	openUntil           time.Time
+}
+
+// NewInstrumentedProvider wraps provider with instrumentation and a circuit breaker
+func NewInstrumentedProvider(name string, provider Provider, opts CircuitBreakerOptions) *InstrumentedProvider {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 5
+	}
+	if opts.OpenDuration <= 0 {
+		opts.OpenDuration = time.Minute
+	}
+	providerCircuitOpen.WithLabelValues(name).Set(0)
+	return &InstrumentedProvider{
+		name:     name,
+		provider: provider,
+		opts:     opts,
+	}
+}
+
+// RunAnalysis runs the wrapped provider's analysis unless the circuit is open
+func (p *InstrumentedProvider) RunAnalysis(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (*AnalysisResult, error) {
+	if !p.allow() {
+		return nil, ErrProviderUnavailable
+	}
+
+	ctx, span := otlp.StartSpan(ctx, "analysis "+p.name, otlp.String("gateway_cd.provider", p.name))
+	defer span.End()
+
+	start := time.Now()
+	result, err := p.provider.RunAnalysis(ctx, canary)
+	p.observe("analysis", start, err)
+	span.RecordError(err)
+	return result, err
+}
+
+// GetMetric runs the wrapped provider's query unless the circuit is open
+func (p *InstrumentedProvider) GetMetric(ctx context.Context, query string) (float64, error) {
+	if !p.allow() {
+		return 0, ErrProviderUnavailable
+	}
+
+	ctx, span := otlp.StartSpan(ctx, "query "+p.name, otlp.String("gateway_cd.provider", p.name))
+	defer span.End()
+
+	start := time.Now()
+	value, err := p.provider.GetMetric(ctx, query)
+	p.observe("query", start, err)
+	span.RecordError(err)
+	return value, err
+}
+
+// Healthy reports whether the circuit breaker is currently closed
+func (p *InstrumentedProvider) Healthy() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return time.Now().After(p.openUntil)
+}
+
+// allow reports whether a call may go through to the wrapped provider
+func (p *InstrumentedProvider) allow() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !time.Now().Before(p.openUntil)
+}
+
+// observe records the call outcome and trips the breaker after repeated failures
+func (p *InstrumentedProvider) observe(operation string, start time.Time, err error) {
+	providerQueryDuration.WithLabelValues(p.name, operation).Observe(time.Since(start).Seconds())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		p.consecutiveFailures = 0
+		providerCircuitOpen.WithLabelValues(p.name).Set(0)
+		return
+	}
+
+	providerQueryErrors.WithLabelValues(p.name, operation).Inc()
+	p.consecutiveFailures++
+	if p.consecutiveFailures >= p.opts.FailureThreshold {
+		p.openUntil = time.Now().Add(p.opts.OpenDuration)
+		providerCircuitOpen.WithLabelValues(p.name).Set(1)
+	}
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"strings"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// profileQueries are the success rate and p95 latency queries of a metrics
+// profile, with {{.Namespace}} and {{.Service}} placeholders
+type profileQueries struct {
+	successRate string
+	latency     string
+}
+
+// defaultQueries read the generic metrics of instrumented applications
+var defaultQueries = profileQueries{
+	successRate: `
+		sum(rate(http_requests_total{service="{{.Service}}",code!~"5.."}[5m])) /
+		sum(rate(http_requests_total{service="{{.Service}}"}[5m]))
+	`,
+	latency: `
+		histogram_quantile(0.95,
+			sum(rate(http_request_duration_seconds_bucket{service="{{.Service}}"}[5m])) by (le)
+		) * 1000
+	`,
+}
+
+// profiles are the built-in queries of each metrics profile
+var profiles = map[gatewaycdv1alpha1.MetricsProfile]profileQueries{
+	gatewaycdv1alpha1.MetricsProfileIstio: {
+		successRate: `
+		sum(rate(istio_requests_total{reporter="destination",destination_service_namespace="{{.Namespace}}",destination_service_name="{{.Service}}",response_code!~"5.*"}[5m])) /
+		sum(rate(istio_requests_total{reporter="destination",destination_service_namespace="{{.Namespace}}",destination_service_name="{{.Service}}"}[5m]))
+	`,
+		latency: `
+		histogram_quantile(0.95,
+			sum(rate(istio_request_duration_milliseconds_bucket{reporter="destination",destination_service_namespace="{{.Namespace}}",destination_service_name="{{.Service}}"}[5m])) by (le)
+		)
+	`,
+	},
+	gatewaycdv1alpha1.MetricsProfileLinkerd: {
+		successRate: `
+		sum(rate(response_total{direction="outbound",dst_namespace="{{.Namespace}}",dst_service="{{.Service}}",classification!="failure"}[5m])) /
+		sum(rate(response_total{direction="outbound",dst_namespace="{{.Namespace}}",dst_service="{{.Service}}"}[5m]))
+	`,
+		latency: `
+		histogram_quantile(0.95,
+			sum(rate(response_latency_ms_bucket{direction="outbound",dst_namespace="{{.Namespace}}",dst_service="{{.Service}}"}[5m])) by (le)
+		)
+	`,
+	},
+	// NGINX names upstreams <namespace>_<service>_<port> and reports the
+	// average response time only, which stands in for the p95
+	gatewaycdv1alpha1.MetricsProfileNGINXGatewayFabric: {
+		successRate: `
+		sum(rate(nginxplus_upstream_server_responses{upstream=~"{{.Namespace}}_{{.Service}}_[0-9]+",code!="5xx"}[5m])) /
+		sum(rate(nginxplus_upstream_server_responses{upstream=~"{{.Namespace}}_{{.Service}}_[0-9]+"}[5m]))
+	`,
+		latency: `
+		max(nginxplus_upstream_server_response_time{upstream=~"{{.Namespace}}_{{.Service}}_[0-9]+"})
+	`,
+	},
+	gatewaycdv1alpha1.MetricsProfileEnvoyGateway: {
+		successRate: `
+		sum(rate(envoy_cluster_upstream_rq_xx{envoy_cluster_name=~".*/{{.Namespace}}/{{.Service}}(/.*)?",envoy_response_code_class!="5"}[5m])) /
+		sum(rate(envoy_cluster_upstream_rq_xx{envoy_cluster_name=~".*/{{.Namespace}}/{{.Service}}(/.*)?"}[5m]))
+	`,
+		latency: `
+		histogram_quantile(0.95,
+			sum(rate(envoy_cluster_upstream_rq_time_bucket{envoy_cluster_name=~".*/{{.Namespace}}/{{.Service}}(/.*)?"}[5m])) by (le)
+		)
+	`,
+	},
+}
+
+// SuccessRateQuery returns the success rate query of profile used for
+// analysis of a service
+func SuccessRateQuery(profile gatewaycdv1alpha1.MetricsProfile, namespace, service string) string {
+	return renderProfileQuery(queriesFor(profile).successRate, namespace, service)
+}
+
+// LatencyQuery returns the p95 latency query in milliseconds of profile used
+// for analysis of a service
+func LatencyQuery(profile gatewaycdv1alpha1.MetricsProfile, namespace, service string) string {
+	return renderProfileQuery(queriesFor(profile).latency, namespace, service)
+}
+
+// queriesFor returns the queries of profile, or the generic ones
+func queriesFor(profile gatewaycdv1alpha1.MetricsProfile) profileQueries {
+	if queries, ok := profiles[profile]; ok {
+		return queries
+	}
+	return defaultQueries
+}
+
+// renderProfileQuery fills in the placeholders of a profile query
+func renderProfileQuery(query, namespace, service string) string {
+	return strings.NewReplacer("{{.Namespace}}", namespace, "{{.Service}}", service).Replace(query)
+}
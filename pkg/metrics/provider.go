@@ -14,6 +14,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/otlp"
 )
 
 // Provider defines the interface for metrics collection
@@ -24,19 +25,30 @@ type Provider interface {
 
 // AnalysisResult represents the result of running canary analysis
 type AnalysisResult struct {
-	Phase          string                                   `json:"phase"`
-	SuccessRate    float64                                  `json:"successRate"`
-	AverageLatency int32                                    `json:"averageLatency"`
-	MetricResults  []gatewaycdv1alpha1.MetricResult         `json:"metricResults"`
-	StartedAt      *metav1.Time                             `json:"startedAt"`
-	CompletedAt    *metav1.Time                             `json:"completedAt"`
-	Passed         bool                                     `json:"passed"`
+	Phase          string  `json:"phase"`
+	SuccessRate    float64 `json:"successRate"`
+	AverageLatency int32   `json:"averageLatency"`
+	// BaselineSuccessRate and BaselineLatency are observed on the baseline
+	// when the canary runs one
+	BaselineSuccessRate float64                          `json:"baselineSuccessRate,omitempty"`
+	BaselineLatency     int32                            `json:"baselineLatency,omitempty"`
+	MetricResults       []gatewaycdv1alpha1.MetricResult `json:"metricResults"`
+	StartedAt           *metav1.Time                     `json:"startedAt"`
+	CompletedAt         *metav1.Time                     `json:"completedAt"`
+	Passed              bool                             `json:"passed"`
 }
 
 // PrometheusProvider implements metrics collection using Prometheus
 type PrometheusProvider struct {
 	baseURL string
 	client  *http.Client
+	// instant executes queries instead of query when set, for providers
+	// speaking more than PromQL
+	instant func(ctx context.Context, query string) (float64, error)
+	// cache deduplicates queries when set
+	cache *QueryCache
+	// compat adapts queries to a Prometheus-compatible backend
+	compat Compatibility
 }
 
 // NewPrometheusProvider creates a new Prometheus metrics provider
@@ -58,22 +70,31 @@ type PrometheusResponse struct {
 		Result     []struct {
 			Metric map[string]string `json:"metric"`
 			Value  []interface{}     `json:"value"`
+			// Values are the points of a range query
+			Values [][]interface{} `json:"values"`
 		} `json:"result"`
 	} `json:"data"`
 }
 
-// RunAnalysis performs canary analysis using Prometheus metrics
+// RunAnalysis performs canary analysis using Prometheus metrics. With a
+// baseline, the canary is compared against the baseline instead of the
+// thresholds, and success rate and latency are always compared.
 func (p *PrometheusProvider) RunAnalysis(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (*AnalysisResult, error) {
+	baseline := canary.Spec.Baseline
 	startTime := time.Now()
 	result := &AnalysisResult{
-		Phase:       "Running",
-		StartedAt:   &metav1.Time{Time: startTime},
-		Passed:      true,
+		Phase:     "Running",
+		StartedAt: &metav1.Time{Time: startTime},
+		Passed:    true,
 	}
 
 	// Run analysis for configured metrics
 	for _, metric := range canary.Spec.Analysis.Metrics {
-		metricResult, err := p.evaluateMetric(ctx, canary, metric)
+		evaluate := p.evaluateMetric
+		if baseline != nil {
+			evaluate = p.compareToBaseline
+		}
+		metricResult, err := evaluate(ctx, canary, metric)
 		if err != nil {
 			result.Phase = "Failed"
 			result.Passed = false
@@ -87,7 +108,7 @@ func (p *PrometheusProvider) RunAnalysis(ctx context.Context, canary *gatewaycdv
 	}
 
 	// Check success rate if configured
-	if canary.Spec.Analysis.SuccessRate > 0 {
+	if canary.Spec.Analysis.SuccessRate > 0 || baseline != nil {
 		successRate, err := p.getSuccessRate(ctx, canary)
 		if err != nil {
 			result.Phase = "Failed"
@@ -96,13 +117,24 @@ func (p *PrometheusProvider) RunAnalysis(ctx context.Context, canary *gatewaycdv
 		}
 
 		result.SuccessRate = successRate
-		if successRate < canary.Spec.Analysis.SuccessRate {
+		minRate := canary.Spec.Analysis.SuccessRate
+		if baseline != nil {
+			baselineRate, err := p.GetMetric(ctx, SuccessRateQuery(canary.Spec.Analysis.MetricsProfile, canary.Namespace, BaselineService(canary)))
+			if err != nil {
+				result.Phase = "Failed"
+				result.Passed = false
+				return result, fmt.Errorf("failed to get baseline success rate: %w", err)
+			}
+			result.BaselineSuccessRate = baselineRate
+			minRate = baselineRate - SuccessRateTolerance(baseline)
+		}
+		if successRate < minRate {
 			result.Passed = false
 		}
 	}
 
 	// Check latency if configured
-	if canary.Spec.Analysis.MaxLatency > 0 {
+	if canary.Spec.Analysis.MaxLatency > 0 || baseline != nil {
 		latency, err := p.getAverageLatency(ctx, canary)
 		if err != nil {
 			result.Phase = "Failed"
@@ -111,7 +143,18 @@ func (p *PrometheusProvider) RunAnalysis(ctx context.Context, canary *gatewaycdv
 		}
 
 		result.AverageLatency = latency
-		if latency > canary.Spec.Analysis.MaxLatency {
+		maxLatency := canary.Spec.Analysis.MaxLatency
+		if baseline != nil {
+			baselineLatency, err := p.GetMetric(ctx, LatencyQuery(canary.Spec.Analysis.MetricsProfile, canary.Namespace, BaselineService(canary)))
+			if err != nil {
+				result.Phase = "Failed"
+				result.Passed = false
+				return result, fmt.Errorf("failed to get baseline latency: %w", err)
+			}
+			result.BaselineLatency = int32(baselineLatency)
+			maxLatency = int32(baselineLatency * (1 + BaselineTolerance(baseline)))
+		}
+		if latency > maxLatency {
 			result.Passed = false
 		}
 	}
@@ -130,14 +173,14 @@ func (p *PrometheusProvider) RunAnalysis(ctx context.Context, canary *gatewaycdv
 // evaluateMetric evaluates a single metric against its threshold
 func (p *PrometheusProvider) evaluateMetric(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, metric gatewaycdv1alpha1.AnalysisMetric) (*gatewaycdv1alpha1.MetricResult, error) {
 	// Replace placeholders in the query
-	query := p.replaceQueryPlaceholders(metric.Query, canary)
+	query := RenderQuery(metric.Query, canary)
 
-	value, err := p.GetMetric(ctx, query)
+	value, err := p.metricValue(ctx, canary, metric, query)
 	if err != nil {
 		return nil, err
 	}
 
-	passed := p.compareValues(value, metric.Threshold, metric.Operator)
+	passed := compareValues(value, metric.Threshold, metric.Operator)
 
 	return &gatewaycdv1alpha1.MetricResult{
 		Name:      metric.Name,
@@ -149,25 +192,12 @@ func (p *PrometheusProvider) evaluateMetric(ctx context.Context, canary *gateway
 
 // getSuccessRate calculates the success rate for canary traffic
 func (p *PrometheusProvider) getSuccessRate(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (float64, error) {
-	// Example query for success rate (customize based on your metrics)
-	query := fmt.Sprintf(`
-		sum(rate(http_requests_total{service="%s-canary",code!~"5.."}[5m])) /
-		sum(rate(http_requests_total{service="%s-canary"}[5m]))
-	`, canary.Spec.Service.Name, canary.Spec.Service.Name)
-
-	return p.GetMetric(ctx, query)
+	return p.GetMetric(ctx, SuccessRateQuery(canary.Spec.Analysis.MetricsProfile, canary.Namespace, canary.Spec.Service.Name+"-canary"))
 }
 
 // getAverageLatency calculates the average latency for canary traffic
 func (p *PrometheusProvider) getAverageLatency(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (int32, error) {
-	// Example query for latency (customize based on your metrics)
-	query := fmt.Sprintf(`
-		histogram_quantile(0.95,
-			sum(rate(http_request_duration_seconds_bucket{service="%s-canary"}[5m])) by (le)
-		) * 1000
-	`, canary.Spec.Service.Name)
-
-	value, err := p.GetMetric(ctx, query)
+	value, err := p.GetMetric(ctx, LatencyQuery(canary.Spec.Analysis.MetricsProfile, canary.Namespace, canary.Spec.Service.Name+"-canary"))
 	if err != nil {
 		return 0, err
 	}
@@ -177,6 +207,26 @@ func (p *PrometheusProvider) getAverageLatency(ctx context.Context, canary *gate
 
 // GetMetric executes a Prometheus query and returns the first result value
 func (p *PrometheusProvider) GetMetric(ctx context.Context, query string) (float64, error) {
+	ctx, span := otlp.StartClientSpan(ctx, "prometheus query", otlp.String("db.statement", query))
+	defer span.End()
+
+	instant := p.query
+	if p.instant != nil {
+		instant = p.instant
+	}
+	var value float64
+	var err error
+	if p.cache != nil {
+		value, err = p.cache.Do(ctx, query, instant)
+	} else {
+		value, err = instant(ctx, query)
+	}
+	span.RecordError(err)
+	return value, err
+}
+
+// query executes a Prometheus instant query
+func (p *PrometheusProvider) query(ctx context.Context, query string) (float64, error) {
 	// Build the query URL
 	u, err := url.Parse(fmt.Sprintf("%s/api/v1/query", p.baseURL))
 	if err != nil {
@@ -185,6 +235,7 @@ func (p *PrometheusProvider) GetMetric(ctx context.Context, query string) (float
 
 	q := u.Query()
 	q.Set("query", query)
+	p.compat.setParams(q)
 	u.RawQuery = q.Encode()
 
 	// Execute the request
@@ -192,6 +243,7 @@ func (p *PrometheusProvider) GetMetric(ctx context.Context, query string) (float
 	if err != nil {
 		return 0, err
 	}
+	p.compat.setHeaders(req)
 
 	resp, err := p.client.Do(req)
 	if err != nil {
@@ -237,13 +289,13 @@ func (p *PrometheusProvider) GetMetric(ctx context.Context, query string) (float
 	return value, nil
 }
 
-// replaceQueryPlaceholders replaces placeholders in Prometheus queries
-func (p *PrometheusProvider) replaceQueryPlaceholders(query string, canary *gatewaycdv1alpha1.CanaryDeployment) string {
+// RenderQuery replaces placeholders in Prometheus queries
+func RenderQuery(query string, canary *gatewaycdv1alpha1.CanaryDeployment) string {
 	replacements := map[string]string{
-		"{{.Service}}":         canary.Spec.Service.Name,
-		"{{.CanaryService}}":   fmt.Sprintf("%s-canary", canary.Spec.Service.Name),
-		"{{.Namespace}}":       canary.Namespace,
-		"{{.Name}}":           canary.Name,
+		"{{.Service}}":       canary.Spec.Service.Name,
+		"{{.CanaryService}}": fmt.Sprintf("%s-canary", canary.Spec.Service.Name),
+		"{{.Namespace}}":     canary.Namespace,
+		"{{.Name}}":          canary.Name,
 	}
 
 	result := query
@@ -255,7 +307,7 @@ func (p *PrometheusProvider) replaceQueryPlaceholders(query string, canary *gate
 }
 
 // compareValues compares two values using the specified operator
-func (p *PrometheusProvider) compareValues(value, threshold float64, operator string) bool {
+func compareValues(value, threshold float64, operator string) bool {
 	switch operator {
 	case ">":
 		return value > threshold
@@ -272,4 +324,4 @@ func (p *PrometheusProvider) compareValues(value, threshold float64, operator st
 	default:
 		return false
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,231 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/otlp"
+)
+
+const (
+	// rangePoints is the number of points a range query returns at its default resolution
+	rangePoints = 60
+	// minRangeResolution is the finest default resolution of a range query
+	minRangeResolution = time.Second * 15
+	// defaultStepDuration is how long a step without a duration lasts
+	defaultStepDuration = time.Second * 30
+)
+
+// MetricRange returns the window and resolution of the range query of a
+// metric that aggregates, resolving their defaults
+func MetricRange(canary *gatewaycdv1alpha1.CanaryDeployment, metric gatewaycdv1alpha1.AnalysisMetric) (time.Duration, time.Duration) {
+	window := parsePositiveDuration(metric.Range)
+	if window == 0 {
+		window = parsePositiveDuration(canary.Spec.Analysis.AnalysisInterval)
+	}
+	if step := int(canary.Status.CurrentStep); window == 0 && step < len(canary.Spec.TrafficSplit) {
+		window = parsePositiveDuration(canary.Spec.TrafficSplit[step].Duration)
+	}
+	if window == 0 {
+		window = defaultStepDuration
+	}
+
+	resolution := parsePositiveDuration(metric.Resolution)
+	if resolution == 0 {
+		resolution = window / rangePoints
+		if resolution < minRangeResolution {
+			resolution = minRangeResolution
+		}
+	}
+	return window, resolution
+}
+
+// RangeQueryExpr renders the instant query equivalent to the range query of
+// a metric, as a PromQL subquery, e.g. for recording rules
+func RangeQueryExpr(query string, window, resolution time.Duration, aggregation gatewaycdv1alpha1.RangeAggregation) string {
+	subquery := fmt.Sprintf("(%s)[%ds:%ds]", query, int64(window.Seconds()), int64(resolution.Seconds()))
+	switch aggregation {
+	case gatewaycdv1alpha1.RangeAggregationMin:
+		return fmt.Sprintf("min_over_time(%s)", subquery)
+	case gatewaycdv1alpha1.RangeAggregationMax:
+		return fmt.Sprintf("max_over_time(%s)", subquery)
+	case gatewaycdv1alpha1.RangeAggregationLast:
+		return fmt.Sprintf("last_over_time(%s)", subquery)
+	}
+	if q, ok := aggregationQuantile(aggregation); ok {
+		return fmt.Sprintf("quantile_over_time(%g, %s)", q, subquery)
+	}
+	return fmt.Sprintf("avg_over_time(%s)", subquery)
+}
+
+// RenderMetricQuery renders the query of a metric on the canary as an
+// instant query, the range query of a metric that aggregates as the
+// equivalent subquery
+func RenderMetricQuery(canary *gatewaycdv1alpha1.CanaryDeployment, metric gatewaycdv1alpha1.AnalysisMetric) string {
+	query := RenderQuery(metric.Query, canary)
+	if metric.Aggregation == "" {
+		return query
+	}
+	window, resolution := MetricRange(canary, metric)
+	return RangeQueryExpr(query, window, resolution, metric.Aggregation)
+}
+
+// metricValue evaluates the query of a metric, on the canary or the
+// baseline, as a range query when the metric aggregates
+func (p *PrometheusProvider) metricValue(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, metric gatewaycdv1alpha1.AnalysisMetric, query string) (float64, error) {
+	if metric.Aggregation == "" {
+		return p.GetMetric(ctx, query)
+	}
+	window, resolution := MetricRange(canary, metric)
+	return p.GetRangeMetric(ctx, query, window, resolution, metric.Aggregation)
+}
+
+// GetRangeMetric executes a Prometheus range query over the window ending
+// now and reduces the points of the first series with aggregation
+func (p *PrometheusProvider) GetRangeMetric(ctx context.Context, query string, window, resolution time.Duration, aggregation gatewaycdv1alpha1.RangeAggregation) (float64, error) {
+	ctx, span := otlp.StartClientSpan(ctx, "prometheus range query", otlp.String("db.statement", query))
+	defer span.End()
+
+	fetch := func(ctx context.Context, _ string) (float64, error) {
+		return p.queryRange(ctx, query, window, resolution, aggregation)
+	}
+	var value float64
+	var err error
+	if p.cache != nil {
+		key := fmt.Sprintf("%s over %s at %s: %s", aggregation, window, resolution, query)
+		value, err = p.cache.Do(ctx, key, fetch)
+	} else {
+		value, err = fetch(ctx, query)
+	}
+	span.RecordError(err)
+	return value, err
+}
+
+// queryRange executes a Prometheus range query and aggregates its points
+func (p *PrometheusProvider) queryRange(ctx context.Context, query string, window, resolution time.Duration, aggregation gatewaycdv1alpha1.RangeAggregation) (float64, error) {
+	if isMQL(query) {
+		return 0, fmt.Errorf("range queries require a PromQL query")
+	}
+	u, err := url.Parse(fmt.Sprintf("%s/api/v1/query_range", p.baseURL))
+	if err != nil {
+		return 0, err
+	}
+	end := time.Now()
+	q := u.Query()
+	q.Set("query", query)
+	q.Set("start", prometheusTime(end.Add(-window)))
+	q.Set("end", prometheusTime(end))
+	q.Set("step", strconv.FormatFloat(resolution.Seconds(), 'f', -1, 64))
+	p.compat.setParams(q)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	p.compat.setHeaders(req)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("prometheus range query failed with status %d", resp.StatusCode)
+	}
+	var promResp PrometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&promResp); err != nil {
+		return 0, err
+	}
+	if promResp.Status != "success" {
+		return 0, fmt.Errorf("prometheus range query failed: %s", promResp.Status)
+	}
+	if len(promResp.Data.Result) == 0 {
+		return 0, fmt.Errorf("no data returned from prometheus range query")
+	}
+
+	var values []float64
+	for _, point := range promResp.Data.Result[0].Values {
+		if len(point) != 2 {
+			continue
+		}
+		valueStr, ok := point[1].(string)
+		if !ok {
+			return 0, fmt.Errorf("unexpected value type from prometheus")
+		}
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse prometheus value: %w", err)
+		}
+		if !math.IsNaN(value) {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("no data returned from prometheus range query")
+	}
+	return aggregate(values, aggregation), nil
+}
+
+// aggregate reduces the points of a range query, in time order, to one value
+func aggregate(values []float64, aggregation gatewaycdv1alpha1.RangeAggregation) float64 {
+	switch aggregation {
+	case gatewaycdv1alpha1.RangeAggregationMin:
+		min := values[0]
+		for _, value := range values[1:] {
+			min = math.Min(min, value)
+		}
+		return min
+	case gatewaycdv1alpha1.RangeAggregationMax:
+		max := values[0]
+		for _, value := range values[1:] {
+			max = math.Max(max, value)
+		}
+		return max
+	case gatewaycdv1alpha1.RangeAggregationLast:
+		return values[len(values)-1]
+	}
+	if q, ok := aggregationQuantile(aggregation); ok {
+		return percentile(values, q)
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// aggregationQuantile returns the quantile of a percentile aggregation
+func aggregationQuantile(aggregation gatewaycdv1alpha1.RangeAggregation) (float64, bool) {
+	switch aggregation {
+	case gatewaycdv1alpha1.RangeAggregationP50:
+		return 0.5, true
+	case gatewaycdv1alpha1.RangeAggregationP90:
+		return 0.9, true
+	case gatewaycdv1alpha1.RangeAggregationP95:
+		return 0.95, true
+	case gatewaycdv1alpha1.RangeAggregationP99:
+		return 0.99, true
+	}
+	return 0, false
+}
+
+// prometheusTime formats a time as the Unix timestamp Prometheus expects
+func prometheusTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', 3, 64)
+}
+
+// parsePositiveDuration parses a duration, returning 0 for invalid or non-positive values
+func parsePositiveDuration(s string) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
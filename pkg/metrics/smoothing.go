@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// Smooth takes the verdict of analysis over the recent runs of the rollout,
+// the last of them the run just completed, as set by spec.analysis.smoothing.
+// With minPassed, it fails once too many runs of the window failed for
+// minPassed of them to pass; otherwise it checks the thresholds against the
+// values averaged over the runs.
+func Smooth(canary *gatewaycdv1alpha1.CanaryDeployment, runs []gatewaycdv1alpha1.AnalysisRunStatus) bool {
+	smoothing := canary.Spec.Analysis.Smoothing
+	if smoothing.MinPassed != nil {
+		failed := int32(0)
+		for _, run := range runs {
+			if run.Phase != "Successful" {
+				failed++
+			}
+		}
+		return failed <= smoothing.Window-*smoothing.MinPassed
+	}
+	return Evaluate(canary, Average(runs))
+}
+
+// Average averages the values, thresholds and baseline values of analysis
+// runs, matching metric results by name. The average is not evaluated.
+func Average(runs []gatewaycdv1alpha1.AnalysisRunStatus) *gatewaycdv1alpha1.AnalysisRunStatus {
+	average := &gatewaycdv1alpha1.AnalysisRunStatus{}
+	if len(runs) == 0 {
+		return average
+	}
+
+	var latency, baselineLatency float64
+	counts := map[string]int{}
+	index := map[string]int{}
+	for _, run := range runs {
+		average.SuccessRate += run.SuccessRate
+		average.BaselineSuccessRate += run.BaselineSuccessRate
+		latency += float64(run.AverageLatency)
+		baselineLatency += float64(run.BaselineLatency)
+		for _, result := range run.MetricResults {
+			i, ok := index[result.Name]
+			if !ok {
+				i = len(average.MetricResults)
+				index[result.Name] = i
+				average.MetricResults = append(average.MetricResults, gatewaycdv1alpha1.MetricResult{Name: result.Name})
+			}
+			average.MetricResults[i].Value += result.Value
+			average.MetricResults[i].Threshold += result.Threshold
+			average.MetricResults[i].BaselineValue += result.BaselineValue
+			counts[result.Name]++
+		}
+	}
+
+	n := float64(len(runs))
+	average.SuccessRate /= n
+	average.BaselineSuccessRate /= n
+	average.AverageLatency = int32(latency/n + 0.5)
+	average.BaselineLatency = int32(baselineLatency/n + 0.5)
+	for i := range average.MetricResults {
+		count := float64(counts[average.MetricResults[i].Name])
+		average.MetricResults[i].Value /= count
+		average.MetricResults[i].Threshold /= count
+		average.MetricResults[i].BaselineValue /= count
+	}
+	average.CompletedAt = runs[len(runs)-1].CompletedAt
+	return average
+}
+
+// Evaluate checks the metric results, success rate and latency of a run
+// against the analysis thresholds of the canary, or the bounds derived from
+// the baseline, as the providers do. It sets the Passed field of the metric
+// results and the phase of the run and reports whether it passed.
+func Evaluate(canary *gatewaycdv1alpha1.CanaryDeployment, run *gatewaycdv1alpha1.AnalysisRunStatus) bool {
+	analysis := canary.Spec.Analysis
+	baseline := canary.Spec.Baseline
+	passed := true
+
+	for i := range run.MetricResults {
+		result := &run.MetricResults[i]
+		result.Passed = compareValues(result.Value, result.Threshold, metricOperator(canary, result.Name))
+		passed = passed && result.Passed
+	}
+
+	if analysis.SuccessRate > 0 || baseline != nil {
+		minRate := analysis.SuccessRate
+		if baseline != nil {
+			minRate = run.BaselineSuccessRate - SuccessRateTolerance(baseline)
+		}
+		if run.SuccessRate < minRate {
+			passed = false
+		}
+	}
+	if analysis.MaxLatency > 0 || baseline != nil {
+		maxLatency := analysis.MaxLatency
+		if baseline != nil {
+			maxLatency = int32(float64(run.BaselineLatency) * (1 + BaselineTolerance(baseline)))
+		}
+		if run.AverageLatency > maxLatency {
+			passed = false
+		}
+	}
+
+	run.Phase = "Successful"
+	if !passed {
+		run.Phase = "Failed"
+	}
+	return passed
+}
+
+// metricOperator is the operator a metric result was checked with: the
+// operator of the analysis metric, widened when compared against a baseline,
+// or <= for the trace metrics
+func metricOperator(canary *gatewaycdv1alpha1.CanaryDeployment, name string) string {
+	if name == TraceMetricErrorRate || name == TraceMetricP95Duration {
+		return "<="
+	}
+	for _, metric := range canary.Spec.Analysis.Metrics {
+		if metric.Name != name {
+			continue
+		}
+		if canary.Spec.Baseline != nil && (metric.Operator == "<" || metric.Operator == ">") {
+			return metric.Operator + "="
+		}
+		return metric.Operator
+	}
+	return ""
+}
@@ -0,0 +1,369 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/otlp"
+)
+
+const (
+	// TraceMetricErrorRate is the metric result name of the canary error span rate
+	TraceMetricErrorRate = "trace-error-rate"
+	// TraceMetricP95Duration is the metric result name of the canary p95 span duration in milliseconds
+	TraceMetricP95Duration = "trace-p95-duration"
+
+	defaultTraceLookback = time.Minute * 5
+	defaultTraceLimit    = 500
+)
+
+// spanSample is a single canary span reduced to what analysis needs
+type spanSample struct {
+	durationMs float64
+	err        bool
+}
+
+// TempoProvider analyses canary spans stored in Grafana Tempo using TraceQL
+type TempoProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewTempoProvider creates a new Tempo trace provider
+func NewTempoProvider(tempoURL string) Provider {
+	return &TempoProvider{
+		baseURL: strings.TrimSuffix(tempoURL, "/"),
+		client: &http.Client{
+			Timeout: time.Second * 30,
+		},
+	}
+}
+
+// tempoSearchResponse is the subset of Tempo's /api/search response used for analysis
+type tempoSearchResponse struct {
+	Traces []struct {
+		SpanSets []struct {
+			Spans []struct {
+				DurationNanos string `json:"durationNanos"`
+				Attributes    []struct {
+					Key   string `json:"key"`
+					Value struct {
+						StringValue string `json:"stringValue"`
+					} `json:"value"`
+				} `json:"attributes"`
+			} `json:"spans"`
+			Matched int `json:"matched"`
+		} `json:"spanSets"`
+	} `json:"traces"`
+}
+
+// RunAnalysis evaluates the error span rate and p95 span duration of the canary
+func (p *TempoProvider) RunAnalysis(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (*AnalysisResult, error) {
+	return analyzeTraces(ctx, canary, p.samples)
+}
+
+// GetMetric runs a TraceQL query and returns the number of matched spans
+func (p *TempoProvider) GetMetric(ctx context.Context, query string) (float64, error) {
+	resp, err := p.search(ctx, query, defaultTraceLookback, defaultTraceLimit)
+	if err != nil {
+		return 0, err
+	}
+	matched := 0
+	for _, trace := range resp.Traces {
+		for _, spanSet := range trace.SpanSets {
+			matched += spanSet.Matched
+		}
+	}
+	return float64(matched), nil
+}
+
+// samples fetches the canary spans matching the trace analysis selector
+func (p *TempoProvider) samples(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, lookback time.Duration) ([]spanSample, error) {
+	traces := canary.Spec.Analysis.Traces
+	resp, err := p.search(ctx, tempoSelector(canary), lookback, traceLimit(traces))
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []spanSample
+	for _, trace := range resp.Traces {
+		for _, spanSet := range trace.SpanSets {
+			for _, span := range spanSet.Spans {
+				nanos, err := strconv.ParseFloat(span.DurationNanos, 64)
+				if err != nil {
+					continue
+				}
+				sample := spanSample{durationMs: nanos / 1e6}
+				for _, attr := range span.Attributes {
+					if attr.Key == "status" && attr.Value.StringValue == "error" {
+						sample.err = true
+					}
+				}
+				samples = append(samples, sample)
+			}
+		}
+	}
+	return samples, nil
+}
+
+// search runs a TraceQL search over the lookback window
+func (p *TempoProvider) search(ctx context.Context, traceQL string, lookback time.Duration, limit int) (*tempoSearchResponse, error) {
+	end := time.Now()
+	q := url.Values{}
+	q.Set("q", traceQL)
+	q.Set("start", strconv.FormatInt(end.Add(-lookback).Unix(), 10))
+	q.Set("end", strconv.FormatInt(end.Unix(), 10))
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("spss", strconv.Itoa(limit))
+
+	var resp tempoSearchResponse
+	if err := getJSON(ctx, p.client, fmt.Sprintf("%s/api/search?%s", p.baseURL, q.Encode()), &resp); err != nil {
+		return nil, fmt.Errorf("tempo search failed: %w", err)
+	}
+	return &resp, nil
+}
+
+// tempoSelector builds the TraceQL selector of canary spans, selecting the
+// span status so error spans can be told apart
+func tempoSelector(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	traces := canary.Spec.Analysis.Traces
+	conditions := []string{fmt.Sprintf(`resource.service.name = %q`, traceService(canary))}
+
+	keys := make([]string, 0, len(traces.Tags))
+	for key := range traces.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		conditions = append(conditions, fmt.Sprintf(`.%s = %q`, key, traces.Tags[key]))
+	}
+	return fmt.Sprintf("{ %s } | select(status)", strings.Join(conditions, " && "))
+}
+
+// TraceQuery returns the TraceQL selector of the canary spans analysed on Tempo
+func TraceQuery(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	return tempoSelector(canary)
+}
+
+// JaegerProvider analyses canary spans stored in Jaeger using its query API
+type JaegerProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewJaegerProvider creates a new Jaeger trace provider
+func NewJaegerProvider(jaegerURL string) Provider {
+	return &JaegerProvider{
+		baseURL: strings.TrimSuffix(jaegerURL, "/"),
+		client: &http.Client{
+			Timeout: time.Second * 30,
+		},
+	}
+}
+
+// jaegerTracesResponse is the subset of Jaeger's /api/traces response used for analysis
+type jaegerTracesResponse struct {
+	Data []struct {
+		Spans []struct {
+			Duration  int64  `json:"duration"`
+			ProcessID string `json:"processID"`
+			Tags      []struct {
+				Key   string      `json:"key"`
+				Value interface{} `json:"value"`
+			} `json:"tags"`
+		} `json:"spans"`
+		Processes map[string]struct {
+			ServiceName string `json:"serviceName"`
+		} `json:"processes"`
+	} `json:"data"`
+}
+
+// RunAnalysis evaluates the error span rate and p95 span duration of the canary
+func (p *JaegerProvider) RunAnalysis(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) (*AnalysisResult, error) {
+	return analyzeTraces(ctx, canary, p.samples)
+}
+
+// GetMetric is not supported by Jaeger, which has no query language
+func (p *JaegerProvider) GetMetric(ctx context.Context, query string) (float64, error) {
+	return 0, fmt.Errorf("jaeger does not support metric queries")
+}
+
+// samples fetches the spans of the canary service matching the configured tags
+func (p *JaegerProvider) samples(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment, lookback time.Duration) ([]spanSample, error) {
+	traces := canary.Spec.Analysis.Traces
+	service := traceService(canary)
+
+	q := url.Values{}
+	q.Set("service", service)
+	q.Set("lookback", lookback.String())
+	q.Set("limit", strconv.Itoa(traceLimit(traces)))
+	if len(traces.Tags) > 0 {
+		tags, err := json.Marshal(traces.Tags)
+		if err != nil {
+			return nil, err
+		}
+		q.Set("tags", string(tags))
+	}
+
+	var resp jaegerTracesResponse
+	if err := getJSON(ctx, p.client, fmt.Sprintf("%s/api/traces?%s", p.baseURL, q.Encode()), &resp); err != nil {
+		return nil, fmt.Errorf("jaeger query failed: %w", err)
+	}
+
+	var samples []spanSample
+	for _, trace := range resp.Data {
+		for _, span := range trace.Spans {
+			if trace.Processes[span.ProcessID].ServiceName != service {
+				continue
+			}
+			sample := spanSample{durationMs: float64(span.Duration) / 1000}
+			for _, tag := range span.Tags {
+				value := fmt.Sprint(tag.Value)
+				if (tag.Key == "error" && value == "true") || (tag.Key == "otel.status_code" && value == "ERROR") {
+					sample.err = true
+				}
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// analyzeTraces evaluates the canary spans returned by fetch against the trace analysis thresholds
+func analyzeTraces(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment,
+	fetch func(context.Context, *gatewaycdv1alpha1.CanaryDeployment, time.Duration) ([]spanSample, error)) (*AnalysisResult, error) {
+	result := &AnalysisResult{
+		Phase:     "Running",
+		StartedAt: &metav1.Time{Time: time.Now()},
+		Passed:    true,
+	}
+
+	traces := canary.Spec.Analysis.Traces
+	if traces == nil {
+		result.Phase = "Successful"
+		result.CompletedAt = &metav1.Time{Time: time.Now()}
+		return result, nil
+	}
+
+	lookback := defaultTraceLookback
+	if traces.Lookback != "" {
+		if d, err := time.ParseDuration(traces.Lookback); err == nil {
+			lookback = d
+		}
+	}
+
+	samples, err := fetch(ctx, canary, lookback)
+	if err != nil {
+		result.Phase = "Failed"
+		result.Passed = false
+		return result, err
+	}
+	if len(samples) == 0 {
+		result.Phase = "Failed"
+		result.Passed = false
+		return result, fmt.Errorf("no canary spans found for service %s", traceService(canary))
+	}
+
+	errors := 0
+	durations := make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		if sample.err {
+			errors++
+		}
+		durations = append(durations, sample.durationMs)
+	}
+	errorRate := float64(errors) / float64(len(samples))
+	p95 := percentile(durations, 0.95)
+
+	if traces.MaxErrorRate > 0 {
+		passed := errorRate <= traces.MaxErrorRate
+		result.MetricResults = append(result.MetricResults, gatewaycdv1alpha1.MetricResult{
+			Name:      TraceMetricErrorRate,
+			Value:     errorRate,
+			Threshold: traces.MaxErrorRate,
+			Passed:    passed,
+		})
+		result.Passed = result.Passed && passed
+	}
+	if traces.MaxP95DurationMs > 0 {
+		passed := p95 <= float64(traces.MaxP95DurationMs)
+		result.MetricResults = append(result.MetricResults, gatewaycdv1alpha1.MetricResult{
+			Name:      TraceMetricP95Duration,
+			Value:     p95,
+			Threshold: float64(traces.MaxP95DurationMs),
+			Passed:    passed,
+		})
+		result.Passed = result.Passed && passed
+	}
+
+	result.Phase = "Successful"
+	if !result.Passed {
+		result.Phase = "Failed"
+	}
+	result.CompletedAt = &metav1.Time{Time: time.Now()}
+	return result, nil
+}
+
+// traceService returns the service name of canary spans
+func traceService(canary *gatewaycdv1alpha1.CanaryDeployment) string {
+	if service := canary.Spec.Analysis.Traces.Service; service != "" {
+		return service
+	}
+	return canary.Spec.Service.Name
+}
+
+// traceLimit returns the maximum number of traces fetched per analysis
+func traceLimit(traces *gatewaycdv1alpha1.TraceAnalysis) int {
+	if traces.Limit > 0 {
+		return int(traces.Limit)
+	}
+	return defaultTraceLimit
+}
+
+// percentile returns the q-th percentile of values using nearest-rank
+func percentile(values []float64, q float64) float64 {
+	sort.Float64s(values)
+	rank := int(q*float64(len(values))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(values) {
+		rank = len(values) - 1
+	}
+	return values[rank]
+}
+
+// getJSON performs a GET request and decodes the JSON response into out
+func getJSON(ctx context.Context, client *http.Client, u string, out interface{}) (err error) {
+	ctx, span := otlp.StartClientSpan(ctx, "trace query", otlp.String("url.full", u))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,157 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// Channel delivers notifications to a single destination
+type Channel interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// NewChannel creates a channel of the given type posting to url
+func NewChannel(channelType gatewaycdv1alpha1.NotificationChannelType, url string) (Channel, error) {
+	client := &http.Client{Timeout: time.Second * 10}
+	switch channelType {
+	case gatewaycdv1alpha1.NotificationChannelSlack:
+		return &SlackChannel{url: url, client: client}, nil
+	case gatewaycdv1alpha1.NotificationChannelTeams:
+		return &TeamsChannel{url: url, client: client}, nil
+	case gatewaycdv1alpha1.NotificationChannelWebhook:
+		return &WebhookChannel{url: url, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unsupported notification channel type %q", channelType)
+	}
+}
+
+// SlackChannel posts notifications to a Slack incoming webhook
+type SlackChannel struct {
+	url    string
+	client *http.Client
+}
+
+// Slack action IDs of the approval buttons
+const (
+	SlackActionApprove = "gateway-cd.approve"
+	SlackActionAbort   = "gateway-cd.abort"
+)
+
+// SlackApproval is the value of the approval buttons, identifying the paused step they act on
+type SlackApproval struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Step      int32  `json:"step"`
+}
+
+// Send posts the notification as a Slack message. Pauses for approval get
+// approve and abort buttons, which need a Slack app whose interactivity
+// request URL is the API server's /api/v1/slack/interactions endpoint.
+func (c *SlackChannel) Send(ctx context.Context, n Notification) error {
+	text := fmt.Sprintf("%s *%s*\n%s", n.emoji(), n.Title(), n.Text())
+	if n.Event != gatewaycdv1alpha1.NotificationEventPausedForApproval {
+		return postJSON(ctx, c.client, c.url, map[string]string{"text": text})
+	}
+
+	value, err := json.Marshal(SlackApproval{Namespace: n.Namespace, Name: n.Name, Step: n.Step})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, c.client, c.url, map[string]interface{}{
+		"text": text,
+		"blocks": []interface{}{
+			map[string]interface{}{
+				"type": "section",
+				"text": map[string]string{"type": "mrkdwn", "text": text},
+			},
+			map[string]interface{}{
+				"type": "actions",
+				"elements": []interface{}{
+					slackButton(SlackActionApprove, "Approve", "primary", string(value), nil),
+					slackButton(SlackActionAbort, "Abort", "danger", string(value), map[string]interface{}{
+						"title":   map[string]string{"type": "plain_text", "text": "Abort rollout?"},
+						"text":    map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("Roll back *%s/%s*?", n.Namespace, n.Name)},
+						"confirm": map[string]string{"type": "plain_text", "text": "Abort"},
+						"deny":    map[string]string{"type": "plain_text", "text": "Cancel"},
+					}),
+				},
+			},
+		},
+	})
+}
+
+// slackButton renders a Block Kit button, asking for confirmation if confirm is set
+func slackButton(actionID, label, style, value string, confirm map[string]interface{}) map[string]interface{} {
+	button := map[string]interface{}{
+		"type":      "button",
+		"action_id": actionID,
+		"text":      map[string]string{"type": "plain_text", "text": label},
+		"style":     style,
+		"value":     value,
+	}
+	if confirm != nil {
+		button["confirm"] = confirm
+	}
+	return button
+}
+
+// TeamsChannel posts notifications to a Microsoft Teams incoming webhook
+type TeamsChannel struct {
+	url    string
+	client *http.Client
+}
+
+// Send posts the notification as a Teams message card
+func (c *TeamsChannel) Send(ctx context.Context, n Notification) error {
+	return postJSON(ctx, c.client, c.url, map[string]string{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    n.Title(),
+		"themeColor": n.color(),
+		"title":      n.Title(),
+		"text":       strings.ReplaceAll(n.Text(), "\n", "<br>"),
+	})
+}
+
+// WebhookChannel posts notifications as JSON to a generic HTTP endpoint
+type WebhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+// Send posts the notification as JSON
+func (c *WebhookChannel) Send(ctx context.Context, n Notification) error {
+	return postJSON(ctx, c.client, c.url, n)
+}
+
+// postJSON posts payload as JSON and expects a 2xx response
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
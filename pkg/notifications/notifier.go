@@ -0,0 +1,193 @@
+// Package notifications sends rollout notifications to Slack, Microsoft
+// Teams and generic HTTP webhooks
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// Notification is a rollout event sent to notification channels. It is also
+// the JSON payload of webhook channels.
+type Notification struct {
+	Event     gatewaycdv1alpha1.NotificationEvent `json:"event"`
+	Namespace string                              `json:"namespace"`
+	Name      string                              `json:"name"`
+	Phase     string                              `json:"phase"`
+	Step      int32                               `json:"step"`
+	Weight    int32                               `json:"weight"`
+	Message   string                              `json:"message"`
+	Change    *gatewaycdv1alpha1.ChangeMetadata   `json:"change,omitempty"`
+	Time      time.Time                           `json:"time"`
+}
+
+// Title summarises the notification in one line
+func (n Notification) Title() string {
+	return fmt.Sprintf("%s/%s: %s", n.Namespace, n.Name, n.Event)
+}
+
+// Text is the notification message followed by the change being rolled out
+func (n Notification) Text() string {
+	text := n.Message
+	if md := n.Change; md != nil {
+		var parts []string
+		if md.GitSHA != "" {
+			parts = append(parts, "sha "+md.GitSHA)
+		}
+		if md.Author != "" {
+			parts = append(parts, "by "+md.Author)
+		}
+		if md.Ticket != "" {
+			parts = append(parts, "ticket "+md.Ticket)
+		}
+		if md.PullRequestURL != "" {
+			parts = append(parts, md.PullRequestURL)
+		}
+		if len(parts) > 0 {
+			text += "\nChange: " + strings.Join(parts, ", ")
+		}
+	}
+	return text
+}
+
+// emoji marks the event in Slack messages
+func (n Notification) emoji() string {
+	switch n.Event {
+	case gatewaycdv1alpha1.NotificationEventPromoted:
+		return ":white_check_mark:"
+	case gatewaycdv1alpha1.NotificationEventAnalysisFailed, gatewaycdv1alpha1.NotificationEventRolledBack:
+		return ":rotating_light:"
+	case gatewaycdv1alpha1.NotificationEventPausedForApproval:
+		return ":pause_button:"
+	case gatewaycdv1alpha1.NotificationEventProgressDeadlineExceeded:
+		return ":warning:"
+	default:
+		return ":rocket:"
+	}
+}
+
+// color is the Teams card theme color of the event
+func (n Notification) color() string {
+	switch n.Event {
+	case gatewaycdv1alpha1.NotificationEventPromoted:
+		return "2EB886"
+	case gatewaycdv1alpha1.NotificationEventAnalysisFailed, gatewaycdv1alpha1.NotificationEventRolledBack:
+		return "D00000"
+	case gatewaycdv1alpha1.NotificationEventPausedForApproval, gatewaycdv1alpha1.NotificationEventProgressDeadlineExceeded:
+		return "DAA038"
+	default:
+		return "0076D7"
+	}
+}
+
+// Notifier sends rollout notifications to the controller-wide channels and
+// the channels configured on each canary
+type Notifier struct {
+	reader   client.Reader
+	defaults []Channel
+	log      logr.Logger
+}
+
+// NewNotifier creates a notifier. reader resolves webhook URLs stored in
+// Secrets; an uncached reader avoids caching every Secret in the cluster.
+func NewNotifier(reader client.Reader, defaults []Channel, log logr.Logger) *Notifier {
+	return &Notifier{
+		reader:   reader,
+		defaults: defaults,
+		log:      log,
+	}
+}
+
+// Notify sends the event to every channel subscribed to it. Notifications
+// are sent in the background so a slow endpoint never blocks reconciliation.
+func (n *Notifier) Notify(canary *gatewaycdv1alpha1.CanaryDeployment, event gatewaycdv1alpha1.NotificationEvent, message string) {
+	notification := Notification{
+		Event:     event,
+		Namespace: canary.Namespace,
+		Name:      canary.Name,
+		Phase:     string(canary.Status.Phase),
+		Step:      canary.Status.CurrentStep,
+		Weight:    canary.Status.CanaryWeight,
+		Message:   message,
+		Change:    canary.Spec.Metadata.DeepCopy(),
+		Time:      time.Now(),
+	}
+	spec := canary.Spec.Notifications.DeepCopy()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+		n.send(ctx, spec, notification)
+	}()
+}
+
+// send delivers a notification to the default and per-canary channels
+func (n *Notifier) send(ctx context.Context, spec *gatewaycdv1alpha1.NotificationsSpec, notification Notification) {
+	log := n.log.WithValues("canary", notification.Namespace+"/"+notification.Name, "event", notification.Event)
+
+	var channels []Channel
+	if spec == nil || !spec.DisableDefaults {
+		channels = append(channels, n.defaults...)
+	}
+	if spec != nil {
+		for _, config := range spec.Channels {
+			if !subscribed(config.Events, notification.Event) {
+				continue
+			}
+			channel, err := n.channel(ctx, notification.Namespace, config)
+			if err != nil {
+				log.Error(err, "Failed to configure notification channel", "type", config.Type)
+				continue
+			}
+			channels = append(channels, channel)
+		}
+	}
+
+	for _, channel := range channels {
+		if err := channel.Send(ctx, notification); err != nil {
+			log.Error(err, "Failed to send notification")
+		}
+	}
+}
+
+// channel builds a per-canary channel, resolving its URL from a Secret if referenced
+func (n *Notifier) channel(ctx context.Context, namespace string, config gatewaycdv1alpha1.NotificationChannel) (Channel, error) {
+	url := config.URL
+	if ref := config.URLSecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		if err := n.reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+			return nil, fmt.Errorf("failed to get secret %s/%s: %w", namespace, ref.Name, err)
+		}
+		value, ok := secret.Data[ref.Key]
+		if !ok {
+			return nil, fmt.Errorf("secret %s/%s has no key %q", namespace, ref.Name, ref.Key)
+		}
+		url = strings.TrimSpace(string(value))
+	}
+	if url == "" {
+		return nil, fmt.Errorf("no URL configured")
+	}
+	return NewChannel(config.Type, url)
+}
+
+// subscribed reports whether a channel listing events receives event
+func subscribed(events []gatewaycdv1alpha1.NotificationEvent, event gatewaycdv1alpha1.NotificationEvent) bool {
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
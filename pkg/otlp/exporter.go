@@ -0,0 +1,267 @@
+// Package otlp exports the rollout event timeline as OpenTelemetry log
+// records and controller spans over OTLP/HTTP, correlated to a trace per
+// rollout
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// TraceparentAnnotation holds a W3C traceparent, e.g. set by the CI job
+	// that created the rollout, so its events join the pipeline's trace
+	TraceparentAnnotation = "gateway-cd.io/traceparent"
+
+	// ServiceName is the service.name resource attribute of exported records
+	ServiceName = "gateway-cd"
+
+	scopeName     = "gateway-cd.io/rollout"
+	queueSize     = 1024
+	maxBatchSize  = 512
+	flushInterval = time.Second * 5
+)
+
+// Exporter batches rollout events and posts them to an OTLP/HTTP logs endpoint.
+// It runs as a manager Runnable.
+type Exporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+	records  chan logRecord
+}
+
+// NewExporter creates an exporter posting to endpoint, the base URL of an
+// OTLP/HTTP receiver such as http://otel-collector:4318. Headers are sent
+// with every request, e.g. for authentication.
+func NewExporter(endpoint string, headers map[string]string) *Exporter {
+	return &Exporter{
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/logs",
+		headers:  headers,
+		client: &http.Client{
+			Timeout: time.Second * 10,
+		},
+		records: make(chan logRecord, queueSize),
+	}
+}
+
+// ParseHeaders parses headers in the OTEL_EXPORTER_OTLP_HEADERS format (key1=value1,key2=value2)
+func ParseHeaders(s string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+// Emit queues a rollout event. Events are dropped if the queue is full so
+// a slow collector never blocks reconciliation.
+func (e *Exporter) Emit(canary *gatewaycdv1alpha1.CanaryDeployment, eventType, reason, message string) {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	traceID, spanID := TraceContext(canary)
+
+	severityNumber, severityText := 9, "INFO"
+	if eventType == corev1.EventTypeWarning {
+		severityNumber, severityText = 13, "WARN"
+	}
+
+	record := logRecord{
+		TimeUnixNano:         now,
+		ObservedTimeUnixNano: now,
+		SeverityNumber:       severityNumber,
+		SeverityText:         severityText,
+		Body:                 stringValue(message),
+		Attributes:           eventAttributes(canary, reason),
+		TraceID:              traceID,
+		SpanID:               spanID,
+	}
+
+	select {
+	case e.records <- record:
+	default:
+	}
+}
+
+// Start flushes queued events until ctx is done, then flushes what is left
+func (e *Exporter) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("otlp")
+	return runBatches(ctx, e.records, func(ctx context.Context, batch []logRecord) {
+		if err := e.export(ctx, batch); err != nil {
+			logger.Error(err, "Failed to export rollout events", "records", len(batch))
+		}
+	})
+}
+
+// export posts a batch of records as an OTLP ExportLogsServiceRequest
+func (e *Exporter) export(ctx context.Context, records []logRecord) error {
+	return post(ctx, e.client, e.endpoint, e.headers, exportLogsRequest{
+		ResourceLogs: []resourceLogs{{
+			Resource: serviceResource(),
+			ScopeLogs: []scopeLogs{{
+				Scope:      scope{Name: scopeName},
+				LogRecords: records,
+			}},
+		}},
+	})
+}
+
+// runBatches passes queued items to flush in batches of up to maxBatchSize,
+// at least every flushInterval, until ctx is done, then flushes what is left
+func runBatches[T any](ctx context.Context, queue chan T, flush func(context.Context, []T)) error {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []T
+	send := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		flush(ctx, batch)
+		batch = nil
+	}
+
+	for {
+		select {
+		case item := <-queue:
+			batch = append(batch, item)
+			if len(batch) >= maxBatchSize {
+				send(ctx)
+			}
+		case <-ticker.C:
+			send(ctx)
+		case <-ctx.Done():
+			for len(queue) > 0 {
+				batch = append(batch, <-queue)
+			}
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			send(shutdownCtx)
+			cancel()
+			return nil
+		}
+	}
+}
+
+// post sends payload as OTLP/JSON to endpoint
+func post(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("otlp export failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// serviceResource describes the controller as the source of exported telemetry
+func serviceResource() resource {
+	return resource{Attributes: []keyValue{
+		{Key: "service.name", Value: stringValue(ServiceName)},
+	}}
+}
+
+// TraceContext returns the trace and span IDs events of the canary's current
+// rollout are correlated with. A valid traceparent annotation wins; otherwise
+// the IDs are derived from the canary UID and rollout start so every event of
+// one rollout shares a trace.
+func TraceContext(canary *gatewaycdv1alpha1.CanaryDeployment) (traceID, spanID string) {
+	if parts := strings.Split(canary.Annotations[TraceparentAnnotation], "-"); len(parts) == 4 &&
+		len(parts[1]) == 32 && len(parts[2]) == 16 && isHex(parts[1]) && isHex(parts[2]) {
+		return parts[1], parts[2]
+	}
+
+	seed := string(canary.UID)
+	if canary.Status.StartedTime != nil {
+		seed += "/" + canary.Status.StartedTime.UTC().Format(time.RFC3339)
+	}
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:16]), hex.EncodeToString(sum[16:24])
+}
+
+// eventAttributes describes the event and the canary state it was emitted in
+func eventAttributes(canary *gatewaycdv1alpha1.CanaryDeployment, reason string) []keyValue {
+	attrs := []keyValue{
+		{Key: "event.name", Value: stringValue("gateway_cd.rollout." + snakeCase(reason))},
+		{Key: "k8s.namespace.name", Value: stringValue(canary.Namespace)},
+		{Key: "gateway_cd.canary.name", Value: stringValue(canary.Name)},
+		{Key: "gateway_cd.canary.phase", Value: stringValue(string(canary.Status.Phase))},
+		{Key: "gateway_cd.canary.step", Value: intValue(int64(canary.Status.CurrentStep))},
+		{Key: "gateway_cd.canary.weight", Value: intValue(int64(canary.Status.CanaryWeight))},
+		{Key: "gateway_cd.event.reason", Value: stringValue(reason)},
+	}
+	if md := canary.Spec.Metadata; md != nil {
+		if md.GitSHA != "" {
+			attrs = append(attrs, keyValue{Key: "vcs.ref.head.revision", Value: stringValue(md.GitSHA)})
+		}
+		if md.Author != "" {
+			attrs = append(attrs, keyValue{Key: "gateway_cd.change.author", Value: stringValue(md.Author)})
+		}
+		if md.Ticket != "" {
+			attrs = append(attrs, keyValue{Key: "gateway_cd.change.ticket", Value: stringValue(md.Ticket)})
+		}
+	}
+	return attrs
+}
+
+// snakeCase converts an event reason such as WeightChanged to weight_changed
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isHex reports whether s is a non-zero lowercase hex string
+func isHex(s string) bool {
+	nonZero := false
+	for _, r := range s {
+		switch {
+		case r == '0':
+		case (r >= '1' && r <= '9') || (r >= 'a' && r <= 'f'):
+			nonZero = true
+		default:
+			return false
+		}
+	}
+	return nonZero
+}
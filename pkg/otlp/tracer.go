@@ -0,0 +1,219 @@
+package otlp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	tracerScopeName = "gateway-cd.io/controller"
+
+	// Span kinds and status codes of the OTLP trace data model
+	spanKindInternal = 1
+	spanKindClient   = 3
+	statusCodeError  = 2
+)
+
+// Tracer batches controller spans and posts them to an OTLP/HTTP traces
+// endpoint. It runs as a manager Runnable.
+type Tracer struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+	spans    chan spanRecord
+}
+
+// NewTracer creates a tracer posting to endpoint, the base URL of an
+// OTLP/HTTP receiver such as http://otel-collector:4318
+func NewTracer(endpoint string, headers map[string]string) *Tracer {
+	return &Tracer{
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		headers:  headers,
+		client: &http.Client{
+			Timeout: time.Second * 10,
+		},
+		spans: make(chan spanRecord, queueSize),
+	}
+}
+
+// Start flushes ended spans until ctx is done, then flushes what is left
+func (t *Tracer) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("otlp")
+	return runBatches(ctx, t.spans, func(ctx context.Context, batch []spanRecord) {
+		if err := t.export(ctx, batch); err != nil {
+			logger.Error(err, "Failed to export spans", "spans", len(batch))
+		}
+	})
+}
+
+// export posts a batch of spans as an OTLP ExportTraceServiceRequest
+func (t *Tracer) export(ctx context.Context, spans []spanRecord) error {
+	return post(ctx, t.client, t.endpoint, t.headers, exportTraceRequest{
+		ResourceSpans: []resourceSpans{{
+			Resource: serviceResource(),
+			ScopeSpans: []scopeSpans{{
+				Scope: scope{Name: tracerScopeName},
+				Spans: spans,
+			}},
+		}},
+	})
+}
+
+// Attribute is a key and value recorded on a span
+type Attribute struct {
+	key   string
+	value anyValue
+}
+
+// String returns a string attribute
+func String(key, value string) Attribute {
+	return Attribute{key: key, value: stringValue(value)}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int64) Attribute {
+	return Attribute{key: key, value: intValue(value)}
+}
+
+// Span times an operation. A nil Span, returned when no tracer is
+// configured, ignores all calls.
+type Span struct {
+	tracer   *Tracer
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	kind     int
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs []keyValue
+	err   error
+	ended bool
+}
+
+type spanContextKey struct{}
+
+// StartRolloutSpan starts a span in the trace of the canary's current rollout,
+// the trace its timeline events are correlated with. Spans started from the
+// returned context with StartSpan are its children. With a nil tracer the
+// span is a no-op.
+func StartRolloutSpan(ctx context.Context, t *Tracer, canary *gatewaycdv1alpha1.CanaryDeployment, name string, attrs ...Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	traceID, parentID := TraceContext(canary)
+	attrs = append([]Attribute{
+		String("k8s.namespace.name", canary.Namespace),
+		String("gateway_cd.canary.name", canary.Name),
+	}, attrs...)
+	return t.start(ctx, traceID, parentID, name, spanKindInternal, attrs)
+}
+
+// StartSpan starts a child of the span in ctx. Without a span in ctx, i.e.
+// outside a traced reconcile, the span is a no-op.
+func StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return startChild(ctx, name, spanKindInternal, attrs)
+}
+
+// StartClientSpan starts a child of the span in ctx for a call to another
+// service, such as a Prometheus query
+func StartClientSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return startChild(ctx, name, spanKindClient, attrs)
+}
+
+// startChild starts a child of the span in ctx, if any
+func startChild(ctx context.Context, name string, kind int, attrs []Attribute) (context.Context, *Span) {
+	parent, _ := ctx.Value(spanContextKey{}).(*Span)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.start(ctx, parent.traceID, parent.spanID, name, kind, attrs)
+}
+
+// start creates a span and returns a context carrying it
+func (t *Tracer) start(ctx context.Context, traceID, parentID, name string, kind int, attrs []Attribute) (context.Context, *Span) {
+	span := &Span{
+		tracer:   t,
+		traceID:  traceID,
+		spanID:   newSpanID(),
+		parentID: parentID,
+		name:     name,
+		kind:     kind,
+		start:    time.Now(),
+	}
+	span.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// SetAttributes records attributes on the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attr := range attrs {
+		s.attrs = append(s.attrs, keyValue{Key: attr.key, Value: attr.value})
+	}
+}
+
+// RecordError marks the span as failed with err, if err is not nil
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// End queues the span for export. Spans are dropped if the queue is full so
+// a slow collector never blocks reconciliation.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.ended = true
+
+	record := spanRecord{
+		TraceID:           s.traceID,
+		SpanID:            s.spanID,
+		ParentSpanID:      s.parentID,
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Attributes:        s.attrs,
+	}
+	if s.err != nil {
+		record.Status = spanStatus{Code: statusCodeError, Message: s.err.Error()}
+	}
+
+	select {
+	case s.tracer.spans <- record:
+	default:
+	}
+}
+
+// newSpanID returns a random span ID
+func newSpanID() string {
+	var id [8]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,347 @@
+// Package preflight validates a CanaryDeployment against the cluster before
+// it is created: the admission checks plus whether its workload, Service,
+// routes and analysis queries would let the rollout start
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/controller"
+	"gateway-cd/pkg/gateway"
+	"gateway-cd/pkg/metrics"
+	"gateway-cd/pkg/webhook"
+)
+
+// Issue is a problem found with a field of the canary
+type Issue struct {
+	// Field is the path of the field, e.g. spec.service.port
+	Field string `json:"field"`
+	// Message describes the problem
+	Message string `json:"message"`
+}
+
+// Result lists the errors that would keep the canary from being admitted or
+// rolled out and the warnings that may make its rollout fail
+type Result struct {
+	Valid    bool    `json:"valid"`
+	Errors   []Issue `json:"errors"`
+	Warnings []Issue `json:"warnings"`
+}
+
+// addError records an error on a field
+func (r *Result) addError(path *field.Path, messageFmt string, args ...interface{}) {
+	r.Errors = append(r.Errors, Issue{Field: path.String(), Message: fmt.Sprintf(messageFmt, args...)})
+	r.Valid = false
+}
+
+// addWarning records a warning on a field
+func (r *Result) addWarning(path *field.Path, messageFmt string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, Issue{Field: path.String(), Message: fmt.Sprintf(messageFmt, args...)})
+}
+
+// addFieldErrors records the errors of an admission check
+func (r *Result) addFieldErrors(errs field.ErrorList) {
+	for _, err := range errs {
+		r.Errors = append(r.Errors, Issue{Field: err.Field, Message: err.ErrorBody()})
+		r.Valid = false
+	}
+}
+
+// Invalid returns the result of a canary failing validation with errs, e.g.
+// the errors of its schema validation
+func Invalid(errs field.ErrorList) *Result {
+	result := &Result{Valid: true, Errors: []Issue{}, Warnings: []Issue{}}
+	result.addFieldErrors(errs)
+	return result
+}
+
+// Validate runs the admission checks of the canary and then, with its
+// templates resolved, checks that the target workload exists, the Service
+// exposes the port, the route parents accept routes from the namespace and
+// the analysis queries parse. Objects that cannot be read are reported as
+// warnings rather than failing the validation.
+func Validate(ctx context.Context, c client.Client, canary *gatewaycdv1alpha1.CanaryDeployment) *Result {
+	result := &Result{Valid: true, Errors: []Issue{}, Warnings: []Issue{}}
+	if canary.Namespace == "" {
+		result.addError(field.NewPath("metadata", "namespace"), "a namespace is required")
+		return result
+	}
+
+	result.addFieldErrors(webhook.ValidateSpec(&canary.Spec))
+	result.addFieldErrors((&webhook.CanaryDeploymentValidator{Client: c}).ValidateReferences(ctx, canary))
+	if !result.Valid {
+		return result
+	}
+
+	resolved, err := controller.ResolveTemplates(ctx, c, canary)
+	if err != nil {
+		result.addError(field.NewPath("spec"), "%v", err)
+		return result
+	}
+
+	v := &validation{client: c, canary: resolved, result: result}
+	template := v.checkWorkload(ctx)
+	v.checkService(ctx, template)
+	v.checkRoutes(ctx)
+	v.checkQueries()
+	return result
+}
+
+// validation holds the state of the cluster checks of one canary
+type validation struct {
+	client client.Client
+	canary *gatewaycdv1alpha1.CanaryDeployment
+	result *Result
+}
+
+// checkWorkload checks that the target workload exists and returns the
+// labels of its pod template, if it has one
+func (v *validation) checkWorkload(ctx context.Context) map[string]string {
+	ref := v.canary.Spec.TargetRef
+	path := field.NewPath("spec", "targetRef")
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		v.result.addError(path.Child("apiVersion"), "%v", err)
+		return nil
+	}
+	workload := &unstructured.Unstructured{}
+	workload.SetGroupVersionKind(gv.WithKind(ref.Kind))
+	err = v.client.Get(ctx, types.NamespacedName{Namespace: v.canary.Namespace, Name: ref.Name}, workload)
+	switch {
+	case apierrors.IsNotFound(err):
+		v.result.addError(path.Child("name"), "%s %s/%s does not exist", ref.Kind, v.canary.Namespace, ref.Name)
+		return nil
+	case err != nil:
+		v.result.addWarning(path, "Failed to get %s %s/%s: %v", ref.Kind, v.canary.Namespace, ref.Name, err)
+		return nil
+	}
+
+	template, _, _ := unstructured.NestedStringMap(workload.Object, "spec", "template", "metadata", "labels")
+	return template
+}
+
+// checkService checks that the Service exposes the canary's port and selects
+// the pods of the workload. A managed Service may not exist yet.
+func (v *validation) checkService(ctx context.Context, podLabels map[string]string) {
+	ref := v.canary.Spec.Service
+	path := field.NewPath("spec", "service")
+
+	var service corev1.Service
+	err := v.client.Get(ctx, types.NamespacedName{Namespace: v.canary.Namespace, Name: ref.Name}, &service)
+	switch {
+	case apierrors.IsNotFound(err) && ref.Managed:
+		v.result.addWarning(path.Child("name"), "Service %s/%s does not exist and will be created", v.canary.Namespace, ref.Name)
+		return
+	case apierrors.IsNotFound(err):
+		v.result.addError(path.Child("name"), "Service %s/%s does not exist", v.canary.Namespace, ref.Name)
+		return
+	case err != nil:
+		v.result.addWarning(path, "Failed to get Service %s/%s: %v", v.canary.Namespace, ref.Name, err)
+		return
+	}
+
+	var ports []string
+	found := false
+	for _, port := range service.Spec.Ports {
+		ports = append(ports, fmt.Sprint(port.Port))
+		found = found || port.Port == ref.Port
+	}
+	if !found {
+		v.result.addError(path.Child("port"), "Service %s exposes port(s) %s, not %d", ref.Name, strings.Join(ports, ", "), ref.Port)
+	}
+
+	if !ref.Managed && podLabels != nil && len(service.Spec.Selector) > 0 &&
+		!labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(podLabels)) {
+		v.result.addWarning(path.Child("name"), "Service %s does not select the pods of %s %s", ref.Name,
+			v.canary.Spec.TargetRef.Kind, v.canary.Spec.TargetRef.Name)
+	}
+}
+
+// route is a route of the canary with the path of the field naming it
+type route struct {
+	path      *field.Path
+	kind      string
+	namespace string
+	name      string
+}
+
+// routes lists the routes the canary shifts traffic on
+func (v *validation) routes() []route {
+	ref := v.canary.Spec.Gateway
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = v.canary.Namespace
+	}
+	gatewayPath := field.NewPath("spec", "gateway")
+
+	var routes []route
+	if ref.HTTPRoute != "" {
+		routes = append(routes, route{gatewayPath.Child("httpRoute"), gateway.KindHTTPRoute, namespace, ref.HTTPRoute})
+	}
+	for i, name := range ref.HTTPRoutes {
+		routes = append(routes, route{gatewayPath.Child("httpRoutes").Index(i), gateway.KindHTTPRoute, namespace, name})
+	}
+	if ref.GRPCRoute != "" {
+		routes = append(routes, route{gatewayPath.Child("grpcRoute"), gateway.KindGRPCRoute, namespace, ref.GRPCRoute})
+	}
+	for i, additional := range ref.AdditionalRoutes {
+		routeNamespace := additional.Namespace
+		if routeNamespace == "" {
+			routeNamespace = namespace
+		}
+		routes = append(routes, route{gatewayPath.Child("additionalRoutes").Index(i).Child("httpRoute"),
+			gateway.KindHTTPRoute, routeNamespace, additional.HTTPRoute})
+	}
+	return routes
+}
+
+// checkRoutes checks that a listener of every parent Gateway of the routes
+// accepts the route from its namespace, and warns about routes a parent has
+// not accepted
+func (v *validation) checkRoutes(ctx context.Context) {
+	for _, r := range v.routes() {
+		var parentRefs []gatewayapi.ParentReference
+		var parents []gatewayapi.RouteParentStatus
+		key := types.NamespacedName{Namespace: r.namespace, Name: r.name}
+		if r.kind == gateway.KindGRPCRoute {
+			var grpcRoute gatewayapiv1alpha2.GRPCRoute
+			if err := v.client.Get(ctx, key, &grpcRoute); err != nil {
+				// Missing routes are reported by the admission checks
+				continue
+			}
+			parentRefs, parents = grpcRoute.Spec.ParentRefs, grpcRoute.Status.Parents
+		} else {
+			var httpRoute gatewayapi.HTTPRoute
+			if err := v.client.Get(ctx, key, &httpRoute); err != nil {
+				continue
+			}
+			parentRefs, parents = httpRoute.Spec.ParentRefs, httpRoute.Status.Parents
+		}
+
+		if len(parentRefs) == 0 {
+			v.result.addError(r.path, "%s %s has no parentRefs", r.kind, key)
+		}
+		for _, parentRef := range parentRefs {
+			v.checkParent(ctx, r, parentRef)
+		}
+		for _, parent := range parents {
+			for _, condition := range parent.Conditions {
+				if condition.Type == string(gatewayapi.RouteConditionAccepted) && condition.Status == metav1.ConditionFalse {
+					v.result.addWarning(r.path, "%s %s is not accepted by %s: %s", r.kind, key, parent.ParentRef.Name, condition.Message)
+				}
+			}
+		}
+	}
+}
+
+// checkParent checks that the Gateway of a parentRef has a listener
+// accepting the route. Parents other than Gateways are not checked.
+func (v *validation) checkParent(ctx context.Context, r route, parentRef gatewayapi.ParentReference) {
+	if (parentRef.Group != nil && *parentRef.Group != gatewayapi.GroupName) ||
+		(parentRef.Kind != nil && *parentRef.Kind != "Gateway") {
+		return
+	}
+	namespace := r.namespace
+	if parentRef.Namespace != nil {
+		namespace = string(*parentRef.Namespace)
+	}
+
+	var gw gatewayapi.Gateway
+	err := v.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: string(parentRef.Name)}, &gw)
+	switch {
+	case apierrors.IsNotFound(err):
+		v.result.addError(r.path, "Gateway %s/%s, a parent of %s %s, does not exist", namespace, parentRef.Name, r.kind, r.name)
+		return
+	case err != nil:
+		v.result.addWarning(r.path, "Failed to get Gateway %s/%s: %v", namespace, parentRef.Name, err)
+		return
+	}
+
+	var routeNamespace corev1.Namespace
+	if err := v.client.Get(ctx, types.NamespacedName{Name: r.namespace}, &routeNamespace); err != nil {
+		v.result.addWarning(r.path, "Failed to get Namespace %s: %v", r.namespace, err)
+		return
+	}
+	for _, listener := range gw.Spec.Listeners {
+		if parentRef.SectionName != nil && listener.Name != *parentRef.SectionName {
+			continue
+		}
+		if parentRef.Port != nil && listener.Port != *parentRef.Port {
+			continue
+		}
+		if allowsRoute(&gw, listener, r.kind, &routeNamespace) {
+			return
+		}
+	}
+
+	if parentRef.SectionName != nil {
+		v.result.addError(r.path, "Listener %s of Gateway %s/%s does not accept %ss from namespace %s",
+			*parentRef.SectionName, namespace, parentRef.Name, r.kind, r.namespace)
+		return
+	}
+	v.result.addError(r.path, "No listener of Gateway %s/%s accepts %ss from namespace %s", namespace, parentRef.Name, r.kind, r.namespace)
+}
+
+// allowsRoute applies the allowedRoutes of a listener to a route of the kind
+// in namespace. Listeners allow routes of their protocol's kind from their
+// own namespace by default.
+func allowsRoute(gw *gatewayapi.Gateway, listener gatewayapi.Listener, kind string, namespace *corev1.Namespace) bool {
+	allowed := listener.AllowedRoutes
+	if allowed != nil && len(allowed.Kinds) > 0 {
+		match := false
+		for _, k := range allowed.Kinds {
+			match = match || string(k.Kind) == kind
+		}
+		if !match {
+			return false
+		}
+	}
+
+	from := gatewayapi.NamespacesFromSame
+	if allowed != nil && allowed.Namespaces != nil && allowed.Namespaces.From != nil {
+		from = *allowed.Namespaces.From
+	}
+	switch from {
+	case gatewayapi.NamespacesFromAll:
+		return true
+	case gatewayapi.NamespacesFromSelector:
+		if allowed.Namespaces.Selector == nil {
+			return false
+		}
+		selector, err := metav1.LabelSelectorAsSelector(allowed.Namespaces.Selector)
+		return err == nil && selector.Matches(labels.Set(namespace.Labels))
+	default:
+		return gw.Namespace == namespace.Name
+	}
+}
+
+// checkQueries checks that the Prometheus queries of the analysis parse once
+// their placeholders are rendered
+func (v *validation) checkQueries() {
+	analysis := v.canary.Spec.Analysis
+	if v.canary.Spec.SkipAnalysis || (analysis.Provider != nil && analysis.Provider.Type != gatewaycdv1alpha1.ProviderTypePrometheus) {
+		return
+	}
+	metricsPath := field.NewPath("spec", "analysis", "metrics")
+	for i, metric := range analysis.Metrics {
+		if err := metrics.CheckQuery(metrics.RenderQuery(metric.Query, v.canary)); err != nil {
+			v.result.addError(metricsPath.Index(i).Child("query"), "Query of metric %s does not parse: %v", metric.Name, err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/stats"
+)
+
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+const (
+	// LabelTeam assigns a namespace to a team. Namespaces without it form a
+	// team of their own.
+	LabelTeam = "gateway-cd.io/team"
+	// ConfigKey is the ConfigMap key holding the quota configuration
+	ConfigKey = "quotas.yaml"
+)
+
+// Limits are the quotas of a team. A zero limit is unlimited.
+type Limits struct {
+	// MaxConcurrentRollouts caps the rollouts of the team in flight at once
+	MaxConcurrentRollouts int `json:"maxConcurrentRollouts,omitempty"`
+	// MaxCanariesPerDay caps the CanaryDeployments the team creates in 24 hours
+	MaxCanariesPerDay int `json:"maxCanariesPerDay,omitempty"`
+}
+
+// Config holds the quotas of every team
+type Config struct {
+	// Default applies to teams without limits of their own
+	Default Limits `json:"default,omitempty"`
+	// Teams overrides the default limits per team. Unset limits fall back
+	// to the default.
+	Teams map[string]Limits `json:"teams,omitempty"`
+}
+
+// limits returns the effective limits of a team
+func (c *Config) limits(team string) Limits {
+	limits := c.Default
+	if override, ok := c.Teams[team]; ok {
+		if override.MaxConcurrentRollouts != 0 {
+			limits.MaxConcurrentRollouts = override.MaxConcurrentRollouts
+		}
+		if override.MaxCanariesPerDay != 0 {
+			limits.MaxCanariesPerDay = override.MaxCanariesPerDay
+		}
+	}
+	return limits
+}
+
+// ExceededError reports a team reaching one of its quotas
+type ExceededError struct {
+	Team  string
+	Quota string
+	Limit int
+	Used  int
+}
+
+// Error implements error
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("team %s reached its %s quota (%d/%d)", e.Team, e.Quota, e.Used, e.Limit)
+}
+
+// IsExceeded reports whether err is a quota violation
+func IsExceeded(err error) bool {
+	var exceeded *ExceededError
+	return errors.As(err, &exceeded)
+}
+
+// Checker enforces the quotas configured in a ConfigMap. The ConfigMap is
+// read on every check so platform admins can change quotas without a
+// restart; without it no quotas apply. A nil Checker enforces nothing.
+type Checker struct {
+	configMap types.NamespacedName
+}
+
+// NewChecker creates a checker reading its configuration from configMap
+func NewChecker(configMap types.NamespacedName) *Checker {
+	return &Checker{configMap: configMap}
+}
+
+// CheckCreate returns an ExceededError if creating a CanaryDeployment in the
+// namespace would exceed the team's daily quota
+func (q *Checker) CheckCreate(ctx context.Context, c client.Reader, namespace string) error {
+	if q == nil {
+		return nil
+	}
+	config, err := q.load(ctx, c)
+	if err != nil || config == nil {
+		return err
+	}
+	team, teams, err := resolveTeams(ctx, c, namespace)
+	if err != nil {
+		return err
+	}
+	limit := config.limits(team).MaxCanariesPerDay
+	if limit == 0 {
+		return nil
+	}
+
+	var canaries gatewaycdv1alpha1.CanaryDeploymentList
+	if err := c.List(ctx, &canaries); err != nil {
+		return fmt.Errorf("failed to list CanaryDeployments: %w", err)
+	}
+	since := time.Now().Add(-24 * time.Hour)
+	used := 0
+	for i := range canaries.Items {
+		canary := &canaries.Items[i]
+		if teamOf(teams, canary.Namespace) == team && canary.CreationTimestamp.After(since) {
+			used++
+		}
+	}
+	if used >= limit {
+		return &ExceededError{Team: team, Quota: "maxCanariesPerDay", Limit: limit, Used: used}
+	}
+	return nil
+}
+
+// CheckStart returns an ExceededError if starting the canary's rollout would
+// exceed the team's concurrent rollout quota
+func (q *Checker) CheckStart(ctx context.Context, c client.Reader, canary *gatewaycdv1alpha1.CanaryDeployment) error {
+	if q == nil {
+		return nil
+	}
+	config, err := q.load(ctx, c)
+	if err != nil || config == nil {
+		return err
+	}
+	team, teams, err := resolveTeams(ctx, c, canary.Namespace)
+	if err != nil {
+		return err
+	}
+	limit := config.limits(team).MaxConcurrentRollouts
+	if limit == 0 {
+		return nil
+	}
+
+	var canaries gatewaycdv1alpha1.CanaryDeploymentList
+	if err := c.List(ctx, &canaries); err != nil {
+		return fmt.Errorf("failed to list CanaryDeployments: %w", err)
+	}
+	used := 0
+	for i := range canaries.Items {
+		other := &canaries.Items[i]
+		if other.UID == canary.UID || teamOf(teams, other.Namespace) != team {
+			continue
+		}
+		if stats.IsActive(other.Status.Phase) {
+			used++
+		}
+	}
+	if used >= limit {
+		return &ExceededError{Team: team, Quota: "maxConcurrentRollouts", Limit: limit, Used: used}
+	}
+	return nil
+}
+
+// load reads the quota configuration, returning nil if the ConfigMap doesn't exist
+func (q *Checker) load(ctx context.Context, c client.Reader) (*Config, error) {
+	var configMap corev1.ConfigMap
+	if err := c.Get(ctx, q.configMap, &configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get quota ConfigMap %s: %w", q.configMap, err)
+	}
+	data, ok := configMap.Data[ConfigKey]
+	if !ok {
+		return nil, nil
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal([]byte(data), config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s of quota ConfigMap %s: %w", ConfigKey, q.configMap, err)
+	}
+	return config, nil
+}
+
+// resolveTeams returns the team of the namespace and the teams of all
+// labelled namespaces
+func resolveTeams(ctx context.Context, c client.Reader, namespace string) (string, map[string]string, error) {
+	var namespaces corev1.NamespaceList
+	if err := c.List(ctx, &namespaces, client.HasLabels{LabelTeam}); err != nil {
+		return "", nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	teams := make(map[string]string, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		teams[ns.Name] = ns.Labels[LabelTeam]
+	}
+	return teamOf(teams, namespace), teams, nil
+}
+
+// teamOf returns the team of a namespace
+func teamOf(teams map[string]string, namespace string) string {
+	if team, ok := teams[namespace]; ok && team != "" {
+		return team
+	}
+	return namespace
+}
+
+// ParseConfigMap parses a namespace/name reference to the quota ConfigMap
+func ParseConfigMap(ref string) (types.NamespacedName, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return types.NamespacedName{}, fmt.Errorf("quota ConfigMap %q must be namespace/name", ref)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
@@ -0,0 +1,123 @@
+package retry
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// TerminalError wraps a failure retrying cannot fix, e.g. a route the API
+// server rejects as invalid
+type TerminalError struct {
+	Err error
+}
+
+// Error implements error
+func (e *TerminalError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *TerminalError) Unwrap() error {
+	return e.Err
+}
+
+// TransientError wraps a failure expected to clear on its own, e.g. a
+// conflict or an unreachable metrics provider
+type TransientError struct {
+	Err error
+}
+
+// Error implements error
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// Terminal marks err as terminal
+func Terminal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TerminalError{Err: err}
+}
+
+// Transient marks err as transient
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TransientError{Err: err}
+}
+
+// IsTerminal reports whether retrying err is pointless. Errors marked by
+// Terminal or Transient keep their class; of the others, API server
+// rejections of the request itself are terminal and everything else is
+// transient.
+func IsTerminal(err error) bool {
+	if err == nil {
+		return false
+	}
+	var terminal *TerminalError
+	var transient *TransientError
+	switch {
+	case errors.As(err, &terminal):
+		return true
+	case errors.As(err, &transient):
+		return false
+	}
+	return apierrors.IsInvalid(err) || apierrors.IsBadRequest(err) || apierrors.IsMethodNotSupported(err)
+}
+
+// Policy configures the backoff between retries of a failed operation and
+// the number of retries before giving up
+type Policy struct {
+	// BaseDelay is the delay before the first retry. Defaults to 5s.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries. Defaults to 5m.
+	MaxDelay time.Duration
+	// Jitter is the fraction of the delay added at random so failing canaries
+	// don't retry in lockstep. Defaults to 0.2.
+	Jitter float64
+	// MaxRetries is the number of retries before the circuit opens; zero
+	// retries forever
+	MaxRetries int32
+}
+
+// DefaultPolicy retries after 5s, doubling up to 5m, ten times
+var DefaultPolicy = Policy{BaseDelay: 5 * time.Second, MaxDelay: 5 * time.Minute, Jitter: 0.2, MaxRetries: 10}
+
+// Delay returns the jittered delay before the given retry, counting from 1
+func (p Policy) Delay(retry int32) time.Duration {
+	base, maxDelay, jitter := p.BaseDelay, p.MaxDelay, p.Jitter
+	if base <= 0 {
+		base = DefaultPolicy.BaseDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultPolicy.MaxDelay
+	}
+	if jitter <= 0 {
+		jitter = DefaultPolicy.Jitter
+	}
+	if retry < 1 {
+		retry = 1
+	}
+
+	delay := maxDelay
+	if exp := math.Pow(2, float64(retry-1)); float64(base)*exp < float64(maxDelay) {
+		delay = time.Duration(float64(base) * exp)
+	}
+	return wait.Jitter(delay, jitter)
+}
+
+// Exhausted reports whether the given number of retries opens the circuit
+func (p Policy) Exhausted(retries int32) bool {
+	return p.MaxRetries > 0 && retries >= p.MaxRetries
+}
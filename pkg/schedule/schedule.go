@@ -0,0 +1,234 @@
+// Package schedule evaluates the rollout windows of a canary's spec.schedule
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// horizon is how far ahead the next open window is searched for
+const horizon = time.Hour * 24 * 8
+
+// weekdays maps the day names of a window to time weekdays
+var weekdays = map[gatewaycdv1alpha1.Weekday]time.Weekday{
+	"Sun": time.Sunday, "Mon": time.Monday, "Tue": time.Tuesday, "Wed": time.Wednesday,
+	"Thu": time.Thursday, "Fri": time.Friday, "Sat": time.Saturday,
+}
+
+// Window is a parsed recurring window
+type Window struct {
+	cron     *cronSpec
+	duration time.Duration
+
+	// start and end are minutes of the day of a time range
+	start, end int
+	days       [7]bool
+}
+
+// interval is one occurrence of a window
+type interval struct {
+	start, end time.Time
+}
+
+// ParseWindow parses a window given by a cron expression and duration or by
+// a time range
+func ParseWindow(spec gatewaycdv1alpha1.ScheduleWindow) (Window, error) {
+	if spec.Cron != "" {
+		if spec.Start != "" || spec.End != "" {
+			return Window{}, fmt.Errorf("a window takes either a cron expression or a time range")
+		}
+		cron, err := parseCron(spec.Cron)
+		if err != nil {
+			return Window{}, fmt.Errorf("invalid cron expression %q: %w", spec.Cron, err)
+		}
+		duration, err := time.ParseDuration(spec.Duration)
+		if err != nil || duration < time.Minute {
+			return Window{}, fmt.Errorf("a cron window needs a duration of at least 1m, got %q", spec.Duration)
+		}
+		return Window{cron: cron, duration: duration}, nil
+	}
+
+	if spec.Start == "" || spec.End == "" {
+		return Window{}, fmt.Errorf("a window needs a cron expression or a start and end time")
+	}
+	window := Window{}
+	var err error
+	if window.start, err = minuteOfDay(spec.Start); err != nil {
+		return Window{}, err
+	}
+	if window.end, err = minuteOfDay(spec.End); err != nil {
+		return Window{}, err
+	}
+	if window.start == window.end {
+		return Window{}, fmt.Errorf("the window from %s to %s is empty", spec.Start, spec.End)
+	}
+	for _, day := range spec.Days {
+		weekday, ok := weekdays[day]
+		if !ok {
+			return Window{}, fmt.Errorf("unknown day %q", day)
+		}
+		window.days[weekday] = true
+	}
+	if len(spec.Days) == 0 {
+		window.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	return window, nil
+}
+
+// minuteOfDay parses an HH:MM time of day
+func minuteOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// intervals returns the occurrences of the window overlapping [from, to)
+func (w Window) intervals(from, to time.Time) []interval {
+	var occurrences []interval
+	if w.cron != nil {
+		// Only starts within the duration before from can still be open
+		for t := from.Add(-w.duration).Truncate(time.Minute); t.Before(to); t = t.Add(time.Minute) {
+			if w.cron.matches(t) && t.Add(w.duration).After(from) {
+				occurrences = append(occurrences, interval{start: t, end: t.Add(w.duration)})
+			}
+		}
+		return occurrences
+	}
+
+	// A range past midnight opens the day before from
+	year, month, day := from.AddDate(0, 0, -1).Date()
+	for date := time.Date(year, month, day, 0, 0, 0, 0, from.Location()); date.Before(to); date = date.AddDate(0, 0, 1) {
+		if !w.days[date.Weekday()] {
+			continue
+		}
+		start := date.Add(time.Duration(w.start) * time.Minute)
+		end := date.Add(time.Duration(w.end) * time.Minute)
+		if w.end < w.start {
+			end = date.AddDate(0, 0, 1).Add(time.Duration(w.end) * time.Minute)
+		}
+		if start.Before(to) && end.After(from) {
+			occurrences = append(occurrences, interval{start: start, end: end})
+		}
+	}
+	return occurrences
+}
+
+// Schedule decides whether a rollout may advance at a given time
+type Schedule struct {
+	location *time.Location
+	allowed  []Window
+	blocked  []Window
+}
+
+// New parses a spec.schedule
+func New(spec *gatewaycdv1alpha1.ScheduleSpec) (*Schedule, error) {
+	location, err := LoadLocation(spec.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schedule{location: location}
+	for i, w := range spec.Allowed {
+		window, err := ParseWindow(w)
+		if err != nil {
+			return nil, fmt.Errorf("allowed window %d: %w", i, err)
+		}
+		s.allowed = append(s.allowed, window)
+	}
+	for i, w := range spec.Blocked {
+		window, err := ParseWindow(w)
+		if err != nil {
+			return nil, fmt.Errorf("blocked window %d: %w", i, err)
+		}
+		s.blocked = append(s.blocked, window)
+	}
+	return s, nil
+}
+
+// LoadLocation loads the time zone of a schedule, UTC when empty
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return location, nil
+}
+
+// Open reports whether t lies in an allowed window and in no blocked window
+func (s *Schedule) Open(t time.Time) bool {
+	t = t.In(s.location)
+	allowed, blocked := s.occurrences(t, t.Add(time.Nanosecond))
+	return open(t, allowed, blocked, len(s.allowed) > 0)
+}
+
+// NextOpen returns the earliest time from t on at which the schedule is open,
+// or false when it stays closed for the next week
+func (s *Schedule) NextOpen(t time.Time) (time.Time, bool) {
+	t = t.In(s.location)
+	allowed, blocked := s.occurrences(t, t.Add(horizon))
+
+	// The schedule can only open at t, when an allowed window opens or when
+	// a blocked window closes
+	candidates := []time.Time{t}
+	for _, i := range allowed {
+		if i.start.After(t) {
+			candidates = append(candidates, i.start)
+		}
+	}
+	for _, i := range blocked {
+		if i.end.After(t) {
+			candidates = append(candidates, i.end)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+
+	for _, candidate := range candidates {
+		if open(candidate, allowed, blocked, len(s.allowed) > 0) {
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// occurrences returns the allowed and blocked intervals overlapping [from, to)
+func (s *Schedule) occurrences(from, to time.Time) ([]interval, []interval) {
+	var allowed, blocked []interval
+	for _, w := range s.allowed {
+		allowed = append(allowed, w.intervals(from, to)...)
+	}
+	for _, w := range s.blocked {
+		blocked = append(blocked, w.intervals(from, to)...)
+	}
+	return allowed, blocked
+}
+
+// open reports whether t lies in one of the allowed intervals, if restricted,
+// and in none of the blocked ones
+func open(t time.Time, allowed, blocked []interval, restricted bool) bool {
+	for _, i := range blocked {
+		if i.contains(t) {
+			return false
+		}
+	}
+	if !restricted {
+		return true
+	}
+	for _, i := range allowed {
+		if i.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether t lies in [start, end)
+func (i interval) contains(t time.Time) bool {
+	return !t.Before(i.start) && t.Before(i.end)
+}
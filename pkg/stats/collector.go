@@ -0,0 +1,63 @@
+package stats
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	activeRolloutsDesc = prometheus.NewDesc(
+		"gatewaycd_namespace_active_rollouts",
+		"Number of canary rollouts currently in flight per namespace.",
+		[]string{"namespace"}, nil)
+
+	canaryReplicasDesc = prometheus.NewDesc(
+		"gatewaycd_namespace_canary_replicas",
+		"Total canary replicas of in-flight rollouts per namespace.",
+		[]string{"namespace"}, nil)
+
+	averageCanaryReplicasDesc = prometheus.NewDesc(
+		"gatewaycd_namespace_canary_replicas_average",
+		"Average canary replica overhead per in-flight rollout per namespace.",
+		[]string{"namespace"}, nil)
+)
+
+// Collector exports per-namespace rollout statistics at scrape time
+type Collector struct {
+	client client.Reader
+	log    logr.Logger
+}
+
+// NewCollector creates a new namespace statistics collector
+func NewCollector(c client.Reader, log logr.Logger) *Collector {
+	return &Collector{client: c, log: log}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeRolloutsDesc
+	ch <- canaryReplicasDesc
+	ch <- averageCanaryReplicasDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	summaries, err := Summarize(ctx, c.client)
+	if err != nil {
+		c.log.Error(err, "Failed to collect namespace rollout statistics")
+		return
+	}
+
+	for _, summary := range summaries {
+		ch <- prometheus.MustNewConstMetric(activeRolloutsDesc, prometheus.GaugeValue, float64(summary.Active), summary.Namespace)
+		ch <- prometheus.MustNewConstMetric(canaryReplicasDesc, prometheus.GaugeValue, float64(summary.CanaryReplicas), summary.Namespace)
+		ch <- prometheus.MustNewConstMetric(averageCanaryReplicasDesc, prometheus.GaugeValue, summary.AverageCanaryReplicas, summary.Namespace)
+	}
+}
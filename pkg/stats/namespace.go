@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"context"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// NamespaceSummary aggregates rollout activity of a single namespace
+type NamespaceSummary struct {
+	Namespace string `json:"namespace"`
+	// Total is the number of CanaryDeployments in the namespace
+	Total int `json:"total"`
+	// Active is the number of rollouts currently in flight
+	Active int `json:"active"`
+	// ByPhase counts CanaryDeployments per phase
+	ByPhase map[string]int `json:"byPhase"`
+	// CanaryReplicas is the number of canary replicas of the active rollouts
+	CanaryReplicas int32 `json:"canaryReplicas"`
+	// AverageCanaryReplicas is the mean canary replica overhead per active rollout
+	AverageCanaryReplicas float64 `json:"averageCanaryReplicas"`
+}
+
+// IsActive reports whether a canary in the given phase is consuming rollout capacity
+func IsActive(phase gatewaycdv1alpha1.CanaryDeploymentPhase) bool {
+	switch phase {
+	case gatewaycdv1alpha1.CanaryDeploymentPhaseProgressing,
+		gatewaycdv1alpha1.CanaryDeploymentPhasePaused,
+		gatewaycdv1alpha1.CanaryDeploymentPhaseRollingBack:
+		return true
+	}
+	return false
+}
+
+// Summarize computes per-namespace rollout statistics sorted by namespace
+func Summarize(ctx context.Context, c client.Reader) ([]NamespaceSummary, error) {
+	var canaries gatewaycdv1alpha1.CanaryDeploymentList
+	if err := c.List(ctx, &canaries); err != nil {
+		return nil, err
+	}
+
+	summaries := map[string]*NamespaceSummary{}
+	for i := range canaries.Items {
+		canary := &canaries.Items[i]
+
+		summary, ok := summaries[canary.Namespace]
+		if !ok {
+			summary = &NamespaceSummary{Namespace: canary.Namespace, ByPhase: map[string]int{}}
+			summaries[canary.Namespace] = summary
+		}
+
+		summary.Total++
+		summary.ByPhase[string(canary.Status.Phase)]++
+		if !IsActive(canary.Status.Phase) {
+			continue
+		}
+
+		summary.Active++
+		summary.CanaryReplicas += canaryReplicas(ctx, c, canary)
+	}
+
+	result := make([]NamespaceSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		if summary.Active > 0 {
+			summary.AverageCanaryReplicas = float64(summary.CanaryReplicas) / float64(summary.Active)
+		}
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Namespace < result[j].Namespace })
+
+	return result, nil
+}
+
+// canaryReplicas returns the replicas of the canary's target Deployment, or zero if unknown
+func canaryReplicas(ctx context.Context, c client.Reader, canary *gatewaycdv1alpha1.CanaryDeployment) int32 {
+	if canary.Spec.TargetRef.Kind != "Deployment" {
+		return 0
+	}
+
+	var deployment appsv1.Deployment
+	if err := c.Get(ctx, types.NamespacedName{
+		Name:      canary.Spec.TargetRef.Name,
+		Namespace: canary.Namespace,
+	}, &deployment); err != nil {
+		return 0
+	}
+	return deployment.Status.Replicas
+}
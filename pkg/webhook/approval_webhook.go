@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+//+kubebuilder:webhook:path=/mutate-gateway-cd-io-v1alpha1-approval,mutating=true,failurePolicy=fail,sideEffects=None,groups=gateway-cd.io,resources=approvals,verbs=create,versions=v1alpha1,name=mapproval.gateway-cd.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-gateway-cd-io-v1alpha1-approval,mutating=false,failurePolicy=fail,sideEffects=None,groups=gateway-cd.io,resources=approvals,verbs=create;update,versions=v1alpha1,name=vapproval.gateway-cd.io,admissionReviewVersions=v1
+
+// ApprovalWebhook records the requesting user as the approver of new
+// Approvals and keeps approvals immutable
+type ApprovalWebhook struct{}
+
+var (
+	_ admission.CustomDefaulter = &ApprovalWebhook{}
+	_ admission.CustomValidator = &ApprovalWebhook{}
+)
+
+// SetupWithManager registers the mutating and validating webhooks with the Manager.
+func (w *ApprovalWebhook) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&gatewaycdv1alpha1.Approval{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+// Default sets the approver to the user creating the Approval, overwriting
+// any approver in the request, and defaults the decision to Approved
+func (w *ApprovalWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	approval, ok := obj.(*gatewaycdv1alpha1.Approval)
+	if !ok {
+		return fmt.Errorf("expected an Approval but got %T", obj)
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if approval.Spec.Decision == "" {
+		approval.Spec.Decision = gatewaycdv1alpha1.ApprovalDecisionApproved
+	}
+	if req.Operation == admissionv1.Create {
+		approval.Spec.Approver = req.UserInfo.Username
+	}
+	return nil
+}
+
+// ValidateCreate validates an Approval on creation
+func (w *ApprovalWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	approval, ok := obj.(*gatewaycdv1alpha1.Approval)
+	if !ok {
+		return nil, fmt.Errorf("expected an Approval but got %T", obj)
+	}
+
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if approval.Spec.CanaryName == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("canaryName"), ""))
+	}
+	if approval.Spec.Step < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("step"), approval.Spec.Step, "must not be negative"))
+	}
+	switch approval.Spec.Decision {
+	case "", gatewaycdv1alpha1.ApprovalDecisionApproved, gatewaycdv1alpha1.ApprovalDecisionRejected:
+	default:
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("decision"), approval.Spec.Decision,
+			[]string{string(gatewaycdv1alpha1.ApprovalDecisionApproved), string(gatewaycdv1alpha1.ApprovalDecisionRejected)}))
+	}
+	return nil, approvalError(approval, allErrs)
+}
+
+// ValidateUpdate rejects changes to the decision of an Approval
+func (w *ApprovalWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldApproval, ok := oldObj.(*gatewaycdv1alpha1.Approval)
+	if !ok {
+		return nil, fmt.Errorf("expected an Approval but got %T", oldObj)
+	}
+	approval, ok := newObj.(*gatewaycdv1alpha1.Approval)
+	if !ok {
+		return nil, fmt.Errorf("expected an Approval but got %T", newObj)
+	}
+
+	var allErrs field.ErrorList
+	if !reflect.DeepEqual(oldApproval.Spec, approval.Spec) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "approvals are immutable, create a new Approval instead"))
+	}
+	return nil, approvalError(approval, allErrs)
+}
+
+// ValidateDelete allows all deletions
+func (w *ApprovalWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// approvalError wraps field errors into an Invalid status error, or returns nil
+func approvalError(approval *gatewaycdv1alpha1.Approval, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		gatewaycdv1alpha1.GroupVersion.WithKind("Approval").GroupKind(),
+		approval.Name, allErrs)
+}
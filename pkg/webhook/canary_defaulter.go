@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+const (
+	// annotationRequestedBy records who requested the pending control action
+	annotationRequestedBy = "gateway-cd.io/requested-by"
+	// annotationRequestedVia records where the pending control action was requested
+	annotationRequestedVia = "gateway-cd.io/requested-via"
+	// annotationAdmittedBy records the authenticated user that set the
+	// pending control action
+	annotationAdmittedBy = "gateway-cd.io/admitted-by"
+)
+
+// controlAnnotations are the annotations requesting a control action from
+// the controller
+var controlAnnotations = []string{
+	"gateway-cd.io/pause",
+	"gateway-cd.io/resume",
+	"gateway-cd.io/promote",
+	"gateway-cd.io/abort",
+	"gateway-cd.io/retry",
+	"gateway-cd.io/weight",
+}
+
+//+kubebuilder:webhook:path=/mutate-gateway-cd-io-v1alpha1-canarydeployment,mutating=true,failurePolicy=fail,sideEffects=None,groups=gateway-cd.io,resources=canarydeployments,verbs=create;update,versions=v1alpha1,name=mcanarydeployment.gateway-cd.io,admissionReviewVersions=v1
+
+// CanaryDeploymentDefaulter records the user setting a control annotation on
+// a CanaryDeployment, so the audit trail doesn't rely on the self-asserted
+// requested-by annotation alone
+type CanaryDeploymentDefaulter struct {
+	// TrustedRequesters are the users, e.g. the API server's service account,
+	// that authenticate the callers they record in requested-by. Control
+	// actions they set for API and CLI requests are admitted as that caller.
+	TrustedRequesters []string
+}
+
+var _ admission.CustomDefaulter = &CanaryDeploymentDefaulter{}
+
+// SetupWithManager registers the mutating webhook with the Manager.
+func (d *CanaryDeploymentDefaulter) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&gatewaycdv1alpha1.CanaryDeployment{}).
+		WithDefaulter(d).
+		Complete()
+}
+
+// Default sets the admitted-by annotation to the user of a request setting
+// a control annotation, or to the caller a trusted requester relayed, and
+// requested-by too when the request leaves it empty. Other requests cannot set admitted-by, only remove it, as the
+// controller does with the control annotation.
+func (d *CanaryDeploymentDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	canary, ok := obj.(*gatewaycdv1alpha1.CanaryDeployment)
+	if !ok {
+		return fmt.Errorf("expected a CanaryDeployment but got %T", obj)
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var old gatewaycdv1alpha1.CanaryDeployment
+	if len(req.OldObject.Raw) > 0 {
+		if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+			return fmt.Errorf("failed to decode the old CanaryDeployment: %w", err)
+		}
+	}
+
+	if setsControlAnnotation(old.Annotations, canary.Annotations) {
+		if canary.Annotations[annotationRequestedBy] == "" {
+			canary.Annotations[annotationRequestedBy] = req.UserInfo.Username
+		}
+		canary.Annotations[annotationAdmittedBy] = d.admittedUser(req.UserInfo.Username, canary.Annotations)
+		return nil
+	}
+	if admittedBy := canary.Annotations[annotationAdmittedBy]; admittedBy != "" && admittedBy != old.Annotations[annotationAdmittedBy] {
+		if previous, ok := old.Annotations[annotationAdmittedBy]; ok {
+			canary.Annotations[annotationAdmittedBy] = previous
+		} else {
+			delete(canary.Annotations, annotationAdmittedBy)
+		}
+	}
+	return nil
+}
+
+// admittedUser is the user a control action set by username is admitted as:
+// the recorded requester when a trusted requester relays an API or CLI
+// request, else username. Slack users are no Kubernetes users, so actions
+// from Slack stay admitted as the relaying service account.
+func (d *CanaryDeploymentDefaulter) admittedUser(username string, annotations map[string]string) string {
+	if !slices.Contains(d.TrustedRequesters, username) {
+		return username
+	}
+	switch gatewaycdv1alpha1.ControlSource(annotations[annotationRequestedVia]) {
+	case gatewaycdv1alpha1.ControlSourceAPI, gatewaycdv1alpha1.ControlSourceCLI:
+		return annotations[annotationRequestedBy]
+	}
+	return username
+}
+
+// setsControlAnnotation reports whether a control annotation is added or
+// changed from the old annotations to the new ones
+func setsControlAnnotation(old, annotations map[string]string) bool {
+	for _, annotation := range controlAnnotations {
+		value, ok := annotations[annotation]
+		if previous, existed := old[annotation]; ok && (!existed || previous != value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+func TestDefault(t *testing.T) {
+	const apiServer = "system:serviceaccount:gateway-cd:gateway-cd-controller"
+	d := &CanaryDeploymentDefaulter{TrustedRequesters: []string{apiServer}}
+
+	tests := []struct {
+		name            string
+		user            string
+		old             map[string]string
+		annotations     map[string]string
+		wantRequestedBy string
+		wantAdmittedBy  string
+	}{
+		{
+			name:            "kubectl annotate",
+			user:            "alice",
+			annotations:     map[string]string{"gateway-cd.io/abort": "true"},
+			wantRequestedBy: "alice",
+			wantAdmittedBy:  "alice",
+		},
+		{
+			name:            "self-asserted requester",
+			user:            "alice",
+			annotations:     map[string]string{"gateway-cd.io/abort": "true", annotationRequestedBy: "bob"},
+			wantRequestedBy: "bob",
+			wantAdmittedBy:  "alice",
+		},
+		{
+			name: "API request relayed by a trusted requester",
+			user: apiServer,
+			annotations: map[string]string{"gateway-cd.io/pause": "true", annotationRequestedBy: "bob",
+				annotationRequestedVia: string(gatewaycdv1alpha1.ControlSourceAPI)},
+			wantRequestedBy: "bob",
+			wantAdmittedBy:  "bob",
+		},
+		{
+			name: "CLI request relayed by a trusted requester",
+			user: apiServer,
+			annotations: map[string]string{"gateway-cd.io/promote": "true", annotationRequestedBy: "bob",
+				annotationRequestedVia: string(gatewaycdv1alpha1.ControlSourceCLI)},
+			wantRequestedBy: "bob",
+			wantAdmittedBy:  "bob",
+		},
+		{
+			name: "unauthenticated API request of a trusted requester",
+			user: apiServer,
+			annotations: map[string]string{"gateway-cd.io/pause": "true",
+				annotationRequestedVia: string(gatewaycdv1alpha1.ControlSourceAPI)},
+			wantRequestedBy: apiServer,
+			wantAdmittedBy:  apiServer,
+		},
+		{
+			name: "Slack request relayed by a trusted requester",
+			user: apiServer,
+			annotations: map[string]string{"gateway-cd.io/resume": "true", annotationRequestedBy: "slack:bob (U123)",
+				annotationRequestedVia: string(gatewaycdv1alpha1.ControlSourceSlack)},
+			wantRequestedBy: "slack:bob (U123)",
+			wantAdmittedBy:  apiServer,
+		},
+		{
+			name: "API request asserted by another user",
+			user: "alice",
+			annotations: map[string]string{"gateway-cd.io/abort": "true", annotationRequestedBy: "bob",
+				annotationRequestedVia: string(gatewaycdv1alpha1.ControlSourceAPI)},
+			wantRequestedBy: "bob",
+			wantAdmittedBy:  "alice",
+		},
+		{
+			name:           "admitted-by set without a control action",
+			user:           "alice",
+			old:            map[string]string{annotationAdmittedBy: "carol"},
+			annotations:    map[string]string{annotationAdmittedBy: "alice"},
+			wantAdmittedBy: "carol",
+		},
+		{
+			name:        "admitted-by added without a control action",
+			user:        "alice",
+			annotations: map[string]string{annotationAdmittedBy: "alice"},
+		},
+		{
+			name:           "unchanged control annotation",
+			user:           "alice",
+			old:            map[string]string{"gateway-cd.io/abort": "true", annotationAdmittedBy: "carol"},
+			annotations:    map[string]string{"gateway-cd.io/abort": "true", annotationAdmittedBy: "carol", "team": "payments"},
+			wantAdmittedBy: "carol",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := &gatewaycdv1alpha1.CanaryDeployment{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout", Annotations: tt.old}}
+			raw, err := json.Marshal(old)
+			if err != nil {
+				t.Fatal(err)
+			}
+			canary := old.DeepCopy()
+			canary.Annotations = tt.annotations
+			ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Update,
+				UserInfo:  authenticationv1.UserInfo{Username: tt.user},
+				OldObject: runtime.RawExtension{Raw: raw},
+			}})
+
+			if err := d.Default(ctx, canary); err != nil {
+				t.Fatalf("Default() failed: %v", err)
+			}
+			if got := canary.Annotations[annotationRequestedBy]; got != tt.wantRequestedBy {
+				t.Errorf("requested-by = %q, want %q", got, tt.wantRequestedBy)
+			}
+			if got := canary.Annotations[annotationAdmittedBy]; got != tt.wantAdmittedBy {
+				t.Errorf("admitted-by = %q, want %q", got, tt.wantAdmittedBy)
+			}
+		})
+	}
+}
@@ -0,0 +1,964 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+	"gateway-cd/pkg/expression"
+	"gateway-cd/pkg/gateway"
+	"gateway-cd/pkg/schedule"
+)
+
+// validOperators mirrors the operators understood by the metrics provider
+var validOperators = map[string]bool{
+	">":  true,
+	">=": true,
+	"<":  true,
+	"<=": true,
+	"==": true,
+	"!=": true,
+}
+
+//+kubebuilder:webhook:path=/validate-gateway-cd-io-v1alpha1-canarydeployment,mutating=false,failurePolicy=fail,sideEffects=None,groups=gateway-cd.io,resources=canarydeployments,verbs=create;update,versions=v1alpha1,name=vcanarydeployment.gateway-cd.io,admissionReviewVersions=v1
+
+// CanaryDeploymentValidator validates CanaryDeployment resources on admission
+type CanaryDeploymentValidator struct {
+	Client client.Reader
+}
+
+var _ admission.CustomValidator = &CanaryDeploymentValidator{}
+
+// SetupWithManager registers the validating webhook with the Manager.
+func (v *CanaryDeploymentValidator) SetupWithManager(mgr ctrl.Manager) error {
+	if v.Client == nil {
+		v.Client = mgr.GetAPIReader()
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&gatewaycdv1alpha1.CanaryDeployment{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate validates a CanaryDeployment on creation
+func (v *CanaryDeploymentValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	canary, ok := obj.(*gatewaycdv1alpha1.CanaryDeployment)
+	if !ok {
+		return nil, fmt.Errorf("expected a CanaryDeployment but got %T", obj)
+	}
+	return nil, v.validate(ctx, canary, nil)
+}
+
+// ValidateUpdate validates a CanaryDeployment on update. Updates of a
+// deleting canary and updates leaving the spec unchanged, such as finalizer
+// removal, status or control annotations, are always allowed, so a deleted
+// route doesn't block them.
+func (v *CanaryDeploymentValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	canary, ok := newObj.(*gatewaycdv1alpha1.CanaryDeployment)
+	if !ok {
+		return nil, fmt.Errorf("expected a CanaryDeployment but got %T", newObj)
+	}
+	old, ok := oldObj.(*gatewaycdv1alpha1.CanaryDeployment)
+	if !ok {
+		return nil, fmt.Errorf("expected a CanaryDeployment but got %T", oldObj)
+	}
+	if canary.DeletionTimestamp != nil || equality.Semantic.DeepEqual(old.Spec, canary.Spec) {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, canary, old)
+}
+
+// ValidateDelete allows all deletions
+func (v *CanaryDeploymentValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate runs the static spec checks followed by the cluster lookups of
+// the references old, the canary before an update, doesn't have
+func (v *CanaryDeploymentValidator) validate(ctx context.Context, canary, old *gatewaycdv1alpha1.CanaryDeployment) error {
+	allErrs := ValidateSpec(&canary.Spec)
+	allErrs = append(allErrs, v.validateReferences(ctx, canary, old)...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		gatewaycdv1alpha1.GroupVersion.WithKind("CanaryDeployment").GroupKind(),
+		canary.Name, allErrs)
+}
+
+// ValidateSpec performs the checks that need no cluster access
+func ValidateSpec(spec *gatewaycdv1alpha1.CanaryDeploymentSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	stepsPath := specPath.Child("trafficSplit")
+	if len(spec.TrafficSplit) == 0 && spec.TemplateRef == nil {
+		allErrs = append(allErrs, field.Required(stepsPath, "at least one traffic split step is required unless templateRef provides them"))
+	}
+	for i, step := range spec.TrafficSplit {
+		stepPath := stepsPath.Index(i)
+		if step.Weight < 0 || step.Weight > 100 {
+			allErrs = append(allErrs, field.Invalid(stepPath.Child("weight"), step.Weight, "must be between 0 and 100"))
+		}
+		if step.Duration != "" {
+			if _, err := time.ParseDuration(step.Duration); err != nil {
+				allErrs = append(allErrs, field.Invalid(stepPath.Child("duration"), step.Duration, err.Error()))
+			}
+		}
+		for j, pattern := range step.TenantPatterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				allErrs = append(allErrs, field.Invalid(stepPath.Child("tenantPatterns").Index(j), pattern, err.Error()))
+			}
+		}
+		if (len(step.Tenants) > 0 || len(step.TenantPatterns) > 0) && len(gateway.HTTPRouteNames(&spec.Gateway)) == 0 {
+			allErrs = append(allErrs, field.Invalid(stepPath.Child("tenants"), step.Tenants, "tenant slices require an HTTPRoute"))
+		}
+		if step.FractionalWeight != "" {
+			allErrs = append(allErrs, validateFractionalWeight(spec, step, stepPath)...)
+		}
+		allErrs = append(allErrs, validateStepHooks(step.PreStep, stepPath.Child("preStep"))...)
+		allErrs = append(allErrs, validateStepHooks(step.PostStep, stepPath.Child("postStep"))...)
+	}
+
+	if spec.Buckets < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("buckets"), spec.Buckets, "must not be negative"))
+	}
+
+	if spec.MirrorDuration != "" {
+		if _, err := time.ParseDuration(spec.MirrorDuration); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("mirrorDuration"), spec.MirrorDuration, err.Error()))
+		}
+	}
+	if spec.Mirror && len(gateway.HTTPRouteNames(&spec.Gateway)) == 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("mirror"), spec.Mirror, "traffic mirroring requires an HTTPRoute"))
+	}
+	if len(spec.Gateway.SectionNames) > 0 && len(gateway.HTTPRouteNames(&spec.Gateway)) == 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("gateway", "sectionNames"), spec.Gateway.SectionNames, "section names require an HTTPRoute"))
+	}
+	for i, section := range spec.Gateway.SectionNames {
+		for _, msg := range validation.IsDNS1123Subdomain(section) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("gateway", "sectionNames").Index(i), section, msg))
+		}
+	}
+	if spec.Gateway.RouteSelector != nil {
+		allErrs = append(allErrs, validateRouteSelector(spec, specPath.Child("gateway", "routeSelector"))...)
+	}
+	if spec.Gateway.ProgrammedTimeout != "" {
+		allErrs = append(allErrs, validateWindow(spec.Gateway.ProgrammedTimeout, specPath.Child("gateway", "programmedTimeout"))...)
+	}
+
+	if spec.Interval != "" {
+		allErrs = append(allErrs, validateWindow(spec.Interval, specPath.Child("interval"))...)
+	}
+	if spec.Schedule != nil {
+		allErrs = append(allErrs, validateSchedule(spec.Schedule, specPath.Child("schedule"))...)
+	}
+	if spec.TimeSlice != nil {
+		allErrs = append(allErrs, validateTimeSlice(spec.TimeSlice, specPath.Child("timeSlice"))...)
+	}
+	if spec.ABTest != nil {
+		allErrs = append(allErrs, validateABTest(spec, specPath.Child("abTest"))...)
+	}
+	if spec.SessionAffinity != nil {
+		allErrs = append(allErrs, validateSessionAffinity(spec, specPath.Child("sessionAffinity"))...)
+	}
+
+	hookNames := map[string]bool{}
+	for i, hook := range spec.Hooks {
+		hookPath := specPath.Child("hooks").Index(i)
+		for _, msg := range validation.IsDNS1123Label(hook.Name) {
+			allErrs = append(allErrs, field.Invalid(hookPath.Child("name"), hook.Name, msg))
+		}
+		if hookNames[hook.Name] {
+			allErrs = append(allErrs, field.Duplicate(hookPath.Child("name"), hook.Name))
+		}
+		hookNames[hook.Name] = true
+		if hook.Type != gatewaycdv1alpha1.HookTypePreRollout && hook.Type != gatewaycdv1alpha1.HookTypeRollback {
+			allErrs = append(allErrs, field.NotSupported(hookPath.Child("type"), hook.Type,
+				[]string{string(gatewaycdv1alpha1.HookTypePreRollout), string(gatewaycdv1alpha1.HookTypeRollback)}))
+		}
+		if len(hook.Template.Spec.Template.Spec.Containers) == 0 {
+			allErrs = append(allErrs, field.Required(hookPath.Child("template", "spec", "template", "spec", "containers"), "a hook Job needs a container"))
+		}
+		if hook.Timeout != "" {
+			allErrs = append(allErrs, validateWindow(hook.Timeout, hookPath.Child("timeout"))...)
+		}
+	}
+	if check := spec.PreRolloutCheck; check != nil {
+		allErrs = append(allErrs, validatePreRolloutCheck(check, specPath.Child("preRolloutCheck"))...)
+	}
+
+	if sa := spec.ServiceAccount; sa != nil {
+		for _, msg := range validation.IsDNS1123Subdomain(sa.Name) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("serviceAccount", "name"), sa.Name, msg))
+		}
+		if spec.TargetRef.Kind != "Deployment" {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("serviceAccount"), sa.Name, "switching the ServiceAccount requires a Deployment target"))
+		}
+	}
+
+	if scale := spec.CanaryScale; scale != nil {
+		allErrs = append(allErrs, validateCanaryScale(spec, scale, specPath.Child("canaryScale"))...)
+	}
+	if baseline := spec.Baseline; baseline != nil {
+		allErrs = append(allErrs, validateBaseline(spec, baseline, specPath.Child("baseline"))...)
+	}
+
+	if spec.RevertOnRollback && spec.TargetRef.Kind != "Deployment" {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("revertOnRollback"), spec.RevertOnRollback, "reverting the workload requires a Deployment target"))
+	}
+
+	if rollback := spec.Rollback; rollback != nil && rollback.Mode == gatewaycdv1alpha1.RollbackModeScaleDownCanary {
+		if spec.TargetRef.Kind != "Deployment" {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("rollback", "mode"), rollback.Mode, "scaling down the canary requires a Deployment target"))
+		}
+		if spec.Service.Managed {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("rollback", "mode"), rollback.Mode,
+				"scaling down the canary cannot be used with managed Services, where the target Deployment also runs the stable pods"))
+		}
+	}
+	if rollback := spec.Rollback; rollback != nil {
+		for i, step := range rollback.Steps {
+			stepPath := specPath.Child("rollback", "steps").Index(i)
+			if step.Weight < 0 || step.Weight >= 100 {
+				allErrs = append(allErrs, field.Invalid(stepPath.Child("weight"), step.Weight, "must be between 0 and 99"))
+			}
+			if i > 0 && step.Weight >= rollback.Steps[i-1].Weight {
+				allErrs = append(allErrs, field.Invalid(stepPath.Child("weight"), step.Weight, "rollback step weights must decrease"))
+			}
+			if step.Duration != "" {
+				allErrs = append(allErrs, validateWindow(step.Duration, stepPath.Child("duration"))...)
+			}
+		}
+	}
+
+	if spec.Service.Managed {
+		if spec.TargetRef.Kind != "Deployment" {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("service", "managed"), spec.Service.Managed, "managed Services require a Deployment target"))
+		}
+		if spec.CanaryScale != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("canaryScale"), spec.CanaryScale.Mode,
+				"canary scaling cannot be used with managed Services, where the target Deployment also runs the stable pods"))
+		}
+	}
+
+	if spec.Approvals != nil && spec.Approvals.Required < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("approvals", "required"), spec.Approvals.Required, "must not be negative"))
+	}
+
+	for i, revision := range spec.ConfigRevisions {
+		revisionPath := specPath.Child("configRevisions").Index(i)
+		if revision.Kind != "ConfigMap" && revision.Kind != "Secret" {
+			allErrs = append(allErrs, field.NotSupported(revisionPath.Child("kind"), revision.Kind, []string{"ConfigMap", "Secret"}))
+		}
+		if revision.Name == "" {
+			allErrs = append(allErrs, field.Required(revisionPath.Child("name"), ""))
+		}
+		if revision.CanaryName == "" {
+			allErrs = append(allErrs, field.Required(revisionPath.Child("canaryName"), ""))
+		} else if revision.CanaryName == revision.Name {
+			allErrs = append(allErrs, field.Invalid(revisionPath.Child("canaryName"), revision.CanaryName, "must differ from name"))
+		}
+	}
+	if len(spec.ConfigRevisions) > 0 && spec.TargetRef.Kind != "Deployment" {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("configRevisions"), len(spec.ConfigRevisions), "config revisions require a Deployment target"))
+	}
+
+	if spec.Notifications != nil {
+		for i, channel := range spec.Notifications.Channels {
+			allErrs = append(allErrs, validateNotificationChannel(channel, specPath.Child("notifications", "channels").Index(i))...)
+		}
+	}
+
+	if ref := spec.AnalysisTemplateRef; ref != nil {
+		switch ref.Kind {
+		case "", gatewaycdv1alpha1.AnalysisTemplateKind, gatewaycdv1alpha1.ClusterAnalysisTemplateKind:
+		default:
+			allErrs = append(allErrs, field.NotSupported(specPath.Child("analysisTemplateRef", "kind"), ref.Kind,
+				[]string{gatewaycdv1alpha1.AnalysisTemplateKind, gatewaycdv1alpha1.ClusterAnalysisTemplateKind}))
+		}
+	}
+
+	analysisPath := specPath.Child("analysis")
+	if spec.Analysis.AnalysisInterval != "" {
+		if _, err := time.ParseDuration(spec.Analysis.AnalysisInterval); err != nil {
+			allErrs = append(allErrs, field.Invalid(analysisPath.Child("analysisInterval"), spec.Analysis.AnalysisInterval, err.Error()))
+		}
+	}
+	if spec.Analysis.SuccessfulIntervals < 0 {
+		allErrs = append(allErrs, field.Invalid(analysisPath.Child("successfulIntervals"), spec.Analysis.SuccessfulIntervals, "must not be negative"))
+	}
+	if spec.Analysis.FailureLimit < 0 {
+		allErrs = append(allErrs, field.Invalid(analysisPath.Child("failureLimit"), spec.Analysis.FailureLimit, "must not be negative"))
+	}
+	if spec.Analysis.ConsecutiveErrors < 0 {
+		allErrs = append(allErrs, field.Invalid(analysisPath.Child("consecutiveErrors"), spec.Analysis.ConsecutiveErrors, "must not be negative"))
+	}
+	if smoothing := spec.Analysis.Smoothing; smoothing != nil {
+		smoothingPath := analysisPath.Child("smoothing")
+		if smoothing.Window < 2 {
+			allErrs = append(allErrs, field.Invalid(smoothingPath.Child("window"), smoothing.Window, "must be at least 2"))
+		}
+		if minPassed := smoothing.MinPassed; minPassed != nil && (*minPassed < 1 || *minPassed > smoothing.Window) {
+			allErrs = append(allErrs, field.Invalid(smoothingPath.Child("minPassed"), *minPassed, "must be between 1 and the window"))
+		}
+	}
+	if spec.Analysis.SuccessRate < 0 || spec.Analysis.SuccessRate > 1 {
+		allErrs = append(allErrs, field.Invalid(analysisPath.Child("successRate"), spec.Analysis.SuccessRate, "must be between 0.0 and 1.0"))
+	}
+	if traces := spec.Analysis.Traces; traces != nil {
+		tracesPath := analysisPath.Child("traces")
+		if traces.MaxErrorRate < 0 || traces.MaxErrorRate > 1 {
+			allErrs = append(allErrs, field.Invalid(tracesPath.Child("maxErrorRate"), traces.MaxErrorRate, "must be between 0.0 and 1.0"))
+		}
+		if traces.MaxErrorRate == 0 && traces.MaxP95DurationMs <= 0 {
+			allErrs = append(allErrs, field.Required(tracesPath, "maxErrorRate or maxP95DurationMs must be set"))
+		}
+		if traces.Lookback != "" {
+			if _, err := time.ParseDuration(traces.Lookback); err != nil {
+				allErrs = append(allErrs, field.Invalid(tracesPath.Child("lookback"), traces.Lookback, err.Error()))
+			}
+		}
+	}
+	if podHealth := spec.Analysis.PodHealth; podHealth != nil {
+		if podHealth.MaxRestarts < 0 {
+			allErrs = append(allErrs, field.Invalid(analysisPath.Child("podHealth", "maxRestarts"), podHealth.MaxRestarts, "must be positive"))
+		}
+		if podHealth.NotReadyTimeout != "" {
+			allErrs = append(allErrs, validateWindow(podHealth.NotReadyTimeout, analysisPath.Child("podHealth", "notReadyTimeout"))...)
+		}
+		if spec.TargetRef.Kind != "Deployment" {
+			allErrs = append(allErrs, field.Invalid(analysisPath.Child("podHealth"), spec.TargetRef.Kind, "pod health checks require a Deployment target"))
+		}
+	}
+	if postPromotion := spec.Analysis.PostPromotion; postPromotion != nil {
+		allErrs = append(allErrs, validateWindow(postPromotion.Duration, analysisPath.Child("postPromotion", "duration"))...)
+		if postPromotion.Interval != "" {
+			allErrs = append(allErrs, validateWindow(postPromotion.Interval, analysisPath.Child("postPromotion", "interval"))...)
+		}
+	}
+	if provider := spec.Analysis.Provider; provider != nil {
+		providerPath := analysisPath.Child("provider")
+		if u, err := url.Parse(provider.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(providerPath.Child("address"), provider.Address, "must be an http or https URL"))
+		}
+		if provider.Type != gatewaycdv1alpha1.ProviderTypePrometheus && provider.Type != gatewaycdv1alpha1.ProviderTypeCloudMonitoring &&
+			len(spec.Analysis.Metrics) > 0 {
+			allErrs = append(allErrs, field.Invalid(providerPath.Child("type"), provider.Type, "custom metrics require a Prometheus or CloudMonitoring provider"))
+		}
+		if provider.Type == gatewaycdv1alpha1.ProviderTypeCloudMonitoring && provider.Project == "" {
+			allErrs = append(allErrs, field.Required(providerPath.Child("project"), "a CloudMonitoring provider requires a project"))
+		}
+		if ref := provider.SecretRef; ref != nil {
+			for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+				allErrs = append(allErrs, field.Invalid(providerPath.Child("secretRef", "name"), ref.Name, msg))
+			}
+		}
+		if compat := provider.Compatibility; compat != nil {
+			allErrs = append(allErrs, validateCompatibility(provider.Type, compat, providerPath.Child("compatibility"))...)
+		}
+	}
+	for i, metric := range spec.Analysis.Metrics {
+		metricPath := analysisPath.Child("metrics").Index(i)
+		if !validOperators[metric.Operator] {
+			allErrs = append(allErrs, field.NotSupported(metricPath.Child("operator"),
+				metric.Operator, []string{">", ">=", "<", "<=", "==", "!="}))
+		}
+		allErrs = append(allErrs, validateRangeMetric(metric, metricPath)...)
+	}
+
+	if len(gateway.HTTPRouteNames(&spec.Gateway)) == 0 && spec.Gateway.GRPCRoute == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("gateway", "httpRoute"), "an HTTPRoute or GRPCRoute must be referenced"))
+	}
+	for i, name := range spec.Gateway.HTTPRoutes {
+		if name == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("gateway", "httpRoutes").Index(i), "an HTTPRoute must be named"))
+		}
+	}
+	allErrs = append(allErrs, validateServicePorts(spec, specPath)...)
+	for i, route := range spec.Gateway.AdditionalRoutes {
+		routePath := specPath.Child("gateway", "additionalRoutes").Index(i)
+		if route.HTTPRoute == "" {
+			allErrs = append(allErrs, field.Required(routePath.Child("httpRoute"), "an HTTPRoute must be referenced"))
+		}
+		allErrs = append(allErrs, validatePortName(spec, route.Port, routePath.Child("port"))...)
+		switch route.WeightPolicy {
+		case "", gatewaycdv1alpha1.RouteWeightPolicyLinked:
+		case gatewaycdv1alpha1.RouteWeightPolicyIndependent:
+			if len(route.Weights) != len(spec.TrafficSplit) {
+				allErrs = append(allErrs, field.Invalid(routePath.Child("weights"), route.Weights,
+					fmt.Sprintf("must list one weight per traffic split step (%d)", len(spec.TrafficSplit))))
+			}
+			for j, weight := range route.Weights {
+				if weight < 0 || weight > 100 {
+					allErrs = append(allErrs, field.Invalid(routePath.Child("weights").Index(j), weight, "must be between 0 and 100"))
+				}
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(routePath.Child("weightPolicy"), route.WeightPolicy,
+				[]string{string(gatewaycdv1alpha1.RouteWeightPolicyLinked), string(gatewaycdv1alpha1.RouteWeightPolicyIndependent)}))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateReferences checks that the referenced Gateway API resources and analysis template exist
+func (v *CanaryDeploymentValidator) ValidateReferences(ctx context.Context, canary *gatewaycdv1alpha1.CanaryDeployment) field.ErrorList {
+	return v.validateReferences(ctx, canary, nil)
+}
+
+// validateReferences checks that the references of canary exist, skipping
+// those old already had so an update isn't rejected for an object deleted
+// since
+func (v *CanaryDeploymentValidator) validateReferences(ctx context.Context, canary, old *gatewaycdv1alpha1.CanaryDeployment) field.ErrorList {
+	var allErrs field.ErrorList
+	if v.Client == nil {
+		return allErrs
+	}
+
+	unchanged := map[string]bool{}
+	if old != nil {
+		for _, ref := range references(old) {
+			unchanged[ref.key()] = true
+		}
+	}
+	for _, ref := range references(canary) {
+		if unchanged[ref.key()] {
+			continue
+		}
+		allErrs = append(allErrs, v.validateExists(ctx, ref.path, ref.obj, ref.name, ref.namespace)...)
+	}
+	return allErrs
+}
+
+// reference is an object a canary refers to by name
+type reference struct {
+	path      *field.Path
+	obj       client.Object
+	name      string
+	namespace string
+}
+
+// key identifies the referenced object regardless of the field referring to it
+func (r reference) key() string {
+	return fmt.Sprintf("%T/%s/%s", r.obj, r.namespace, r.name)
+}
+
+// references lists the Gateway API resources and templates canary refers to
+func references(canary *gatewaycdv1alpha1.CanaryDeployment) []reference {
+	namespace := canary.Spec.Gateway.Namespace
+	if namespace == "" {
+		namespace = canary.Namespace
+	}
+
+	refs := []reference{
+		{field.NewPath("spec", "gateway", "httpRoute"), &gatewayapi.HTTPRoute{}, canary.Spec.Gateway.HTTPRoute, namespace},
+		{field.NewPath("spec", "gateway", "grpcRoute"), &gatewayapiv1alpha2.GRPCRoute{}, canary.Spec.Gateway.GRPCRoute, namespace},
+	}
+	for i, name := range canary.Spec.Gateway.HTTPRoutes {
+		refs = append(refs, reference{field.NewPath("spec", "gateway", "httpRoutes").Index(i), &gatewayapi.HTTPRoute{}, name, namespace})
+	}
+	for i, route := range canary.Spec.Gateway.AdditionalRoutes {
+		routeNamespace := route.Namespace
+		if routeNamespace == "" {
+			routeNamespace = namespace
+		}
+		refs = append(refs, reference{field.NewPath("spec", "gateway", "additionalRoutes").Index(i).Child("httpRoute"),
+			&gatewayapi.HTTPRoute{}, route.HTTPRoute, routeNamespace})
+	}
+
+	if ref := canary.Spec.TemplateRef; ref != nil {
+		refs = append(refs, reference{field.NewPath("spec", "templateRef", "name"), &gatewaycdv1alpha1.CanaryTemplate{}, ref.Name, ""})
+	}
+
+	if ref := canary.Spec.AnalysisTemplateRef; ref != nil {
+		refPath := field.NewPath("spec", "analysisTemplateRef", "name")
+		if ref.Kind == gatewaycdv1alpha1.ClusterAnalysisTemplateKind {
+			refs = append(refs, reference{refPath, &gatewaycdv1alpha1.ClusterAnalysisTemplate{}, ref.Name, ""})
+		} else {
+			refs = append(refs, reference{refPath, &gatewaycdv1alpha1.AnalysisTemplate{}, ref.Name, canary.Namespace})
+		}
+	}
+	return refs
+}
+
+// validateExists reports a field error if the named object cannot be found
+func (v *CanaryDeploymentValidator) validateExists(ctx context.Context, path *field.Path, obj client.Object, name, namespace string) field.ErrorList {
+	var allErrs field.ErrorList
+	if name == "" {
+		return allErrs
+	}
+
+	err := v.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, obj)
+	if apierrors.IsNotFound(err) {
+		allErrs = append(allErrs, field.NotFound(path, types.NamespacedName{Name: name, Namespace: namespace}.String()))
+	} else if err != nil {
+		allErrs = append(allErrs, field.InternalError(path, fmt.Errorf("failed to get %s: %w", name, err)))
+	}
+
+	return allErrs
+}
+
+// validateTimeSlice checks the exposure weight, window durations and cycle count
+func validateTimeSlice(slice *gatewaycdv1alpha1.TimeSliceSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if slice.Weight < 1 || slice.Weight > 100 {
+		allErrs = append(allErrs, field.Invalid(path.Child("weight"), slice.Weight, "must be between 1 and 100"))
+	}
+	allErrs = append(allErrs, validateWindow(slice.OnDuration, path.Child("onDuration"))...)
+	allErrs = append(allErrs, validateWindow(slice.OffDuration, path.Child("offDuration"))...)
+	if slice.Cycles < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("cycles"), slice.Cycles, "must not be negative"))
+	}
+	return allErrs
+}
+
+// validateRouteSelector checks that the selected paths are absolute and the
+// hostnames valid, and that there is an HTTPRoute whose rules they select
+func validateRouteSelector(spec *gatewaycdv1alpha1.CanaryDeploymentSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	selector := spec.Gateway.RouteSelector
+
+	if len(gateway.HTTPRouteNames(&spec.Gateway)) == 0 {
+		allErrs = append(allErrs, field.Invalid(path, "", "a route selector requires an HTTPRoute"))
+	}
+	for i, p := range selector.Paths {
+		if !strings.HasPrefix(p, "/") {
+			allErrs = append(allErrs, field.Invalid(path.Child("paths").Index(i), p, "must start with /"))
+		}
+	}
+	for i, hostname := range selector.Hostnames {
+		msgs := validation.IsDNS1123Subdomain(hostname)
+		if strings.HasPrefix(hostname, "*") {
+			msgs = validation.IsWildcardDNS1123Subdomain(hostname)
+		}
+		for _, msg := range msgs {
+			allErrs = append(allErrs, field.Invalid(path.Child("hostnames").Index(i), hostname, msg))
+		}
+	}
+	return allErrs
+}
+
+// validateSchedule checks the time zone and that every window parses
+func validateSchedule(spec *gatewaycdv1alpha1.ScheduleSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if _, err := schedule.LoadLocation(spec.TimeZone); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("timeZone"), spec.TimeZone, err.Error()))
+	}
+	for i, window := range spec.Allowed {
+		if _, err := schedule.ParseWindow(window); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("allowed").Index(i), window, err.Error()))
+		}
+	}
+	for i, window := range spec.Blocked {
+		if _, err := schedule.ParseWindow(window); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("blocked").Index(i), window, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+// validateABTest checks that the A/B test has an assignment cookie or header
+// and targets an HTTPRoute, whose rules can match on them
+func validateABTest(spec *gatewaycdv1alpha1.CanaryDeploymentSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	abTest := spec.ABTest
+
+	if abTest.Cookie == "" && abTest.Header == "" {
+		allErrs = append(allErrs, field.Required(path, "an A/B test requires a cookie or a header"))
+	}
+	if len(gateway.HTTPRouteNames(&spec.Gateway)) == 0 {
+		allErrs = append(allErrs, field.Invalid(path, "", "an A/B test requires an HTTPRoute"))
+	}
+	if abTest.Cookie != "" {
+		for _, msg := range validation.IsHTTPHeaderName(abTest.Cookie) {
+			allErrs = append(allErrs, field.Invalid(path.Child("cookie"), abTest.Cookie, msg))
+		}
+	}
+	if abTest.Header != "" {
+		for _, msg := range validation.IsHTTPHeaderName(abTest.Header) {
+			allErrs = append(allErrs, field.Invalid(path.Child("header"), abTest.Header, msg))
+		}
+	}
+	if abTest.SetCookie && abTest.Cookie == "" {
+		allErrs = append(allErrs, field.Invalid(path.Child("setCookie"), abTest.SetCookie, "setting the assignment cookie requires a cookie"))
+	}
+	if abTest.CookieMaxAge != "" {
+		allErrs = append(allErrs, validateWindow(abTest.CookieMaxAge, path.Child("cookieMaxAge"))...)
+	}
+	return allErrs
+}
+
+// validateSessionAffinity checks that session affinity targets an HTTPRoute,
+// whose rules can match on the cookie, and doesn't overlap an A/B test
+func validateSessionAffinity(spec *gatewaycdv1alpha1.CanaryDeploymentSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	affinity := spec.SessionAffinity
+
+	if len(gateway.HTTPRouteNames(&spec.Gateway)) == 0 {
+		allErrs = append(allErrs, field.Invalid(path, "", "session affinity requires an HTTPRoute"))
+	}
+	if spec.ABTest != nil {
+		allErrs = append(allErrs, field.Invalid(path, "", "session affinity cannot be combined with an A/B test, whose assignment cookie already pins users"))
+	}
+	if affinity.Cookie != "" {
+		for _, msg := range validation.IsHTTPHeaderName(affinity.Cookie) {
+			allErrs = append(allErrs, field.Invalid(path.Child("cookie"), affinity.Cookie, msg))
+		}
+	}
+	if affinity.MaxAge != "" {
+		allErrs = append(allErrs, validateWindow(affinity.MaxAge, path.Child("maxAge"))...)
+	}
+	return allErrs
+}
+
+// validateCanaryScale checks that the canary replicas of every step can be computed
+func validateCanaryScale(spec *gatewaycdv1alpha1.CanaryDeploymentSpec, scale *gatewaycdv1alpha1.CanaryScaleSpec, scalePath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.TargetRef.Kind != "Deployment" {
+		allErrs = append(allErrs, field.Invalid(scalePath, scale.Mode, "scaling the canary requires a Deployment target"))
+	}
+	switch scale.Mode {
+	case gatewaycdv1alpha1.CanaryScaleModeFixed:
+		if scale.Replicas < 1 {
+			allErrs = append(allErrs, field.Invalid(scalePath.Child("replicas"), scale.Replicas, "must be at least 1"))
+		}
+	case gatewaycdv1alpha1.CanaryScaleModePercentOfStable, gatewaycdv1alpha1.CanaryScaleModeMatchWeight:
+		if scale.Mode == gatewaycdv1alpha1.CanaryScaleModePercentOfStable && (scale.Percent < 1 || scale.Percent > 100) {
+			allErrs = append(allErrs, field.Invalid(scalePath.Child("percent"), scale.Percent, "must be between 1 and 100"))
+		}
+		if scale.StableDeployment == "" {
+			allErrs = append(allErrs, field.Required(scalePath.Child("stableDeployment"), "the stable Deployment to scale from must be set"))
+		} else if scale.StableDeployment == spec.TargetRef.Name {
+			allErrs = append(allErrs, field.Invalid(scalePath.Child("stableDeployment"), scale.StableDeployment, "must differ from the target Deployment"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(scalePath.Child("mode"), scale.Mode,
+			[]string{string(gatewaycdv1alpha1.CanaryScaleModeFixed), string(gatewaycdv1alpha1.CanaryScaleModePercentOfStable),
+				string(gatewaycdv1alpha1.CanaryScaleModeMatchWeight)}))
+	}
+	if scale.MinReplicas < 0 {
+		allErrs = append(allErrs, field.Invalid(scalePath.Child("minReplicas"), scale.MinReplicas, "must not be negative"))
+	}
+	if scale.HPAPolicy != "" && scale.HPAPolicy != gatewaycdv1alpha1.HPAPolicyPause && scale.HPAPolicy != gatewaycdv1alpha1.HPAPolicyAdjust {
+		allErrs = append(allErrs, field.NotSupported(scalePath.Child("hpaPolicy"), scale.HPAPolicy,
+			[]string{string(gatewaycdv1alpha1.HPAPolicyPause), string(gatewaycdv1alpha1.HPAPolicyAdjust)}))
+	}
+	return allErrs
+}
+
+// validateBaseline checks the baseline's stable Deployment and tolerances, and
+// that the last step moves all traffic to the canary, as the baseline is
+// removed on promotion
+func validateBaseline(spec *gatewaycdv1alpha1.CanaryDeploymentSpec, baseline *gatewaycdv1alpha1.BaselineSpec, baselinePath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if baseline.StableDeployment == "" {
+		allErrs = append(allErrs, field.Required(baselinePath.Child("stableDeployment"), "the stable Deployment to copy must be set"))
+	} else if baseline.StableDeployment == spec.TargetRef.Name && spec.TargetRef.Kind == "Deployment" {
+		allErrs = append(allErrs, field.Invalid(baselinePath.Child("stableDeployment"), baseline.StableDeployment, "must differ from the target Deployment"))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(baseline.StableDeployment) {
+			allErrs = append(allErrs, field.Invalid(baselinePath.Child("stableDeployment"), baseline.StableDeployment, msg))
+		}
+	}
+	if baseline.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(baselinePath.Child("replicas"), baseline.Replicas, "must not be negative"))
+	}
+	if baseline.SuccessRateTolerance < 0 || baseline.SuccessRateTolerance > 1 {
+		allErrs = append(allErrs, field.Invalid(baselinePath.Child("successRateTolerance"), baseline.SuccessRateTolerance, "must be between 0 and 1"))
+	}
+	if baseline.Tolerance < 0 {
+		allErrs = append(allErrs, field.Invalid(baselinePath.Child("tolerance"), baseline.Tolerance, "must not be negative"))
+	}
+	if steps := spec.TrafficSplit; len(steps) > 0 && steps[len(steps)-1].Weight != 100 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "trafficSplit").Index(len(steps)-1).Child("weight"),
+			steps[len(steps)-1].Weight, "the last step must route 100% to the canary when running a baseline"))
+	}
+	return allErrs
+}
+
+// validateCompatibility checks that the compatibility settings of a provider
+// apply to its type and backend
+func validateCompatibility(providerType gatewaycdv1alpha1.ProviderType, compat *gatewaycdv1alpha1.PrometheusCompatibility, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if providerType != gatewaycdv1alpha1.ProviderTypePrometheus {
+		return append(allErrs, field.Invalid(path, providerType, "compatibility applies to Prometheus providers only"))
+	}
+	backend := compat.Backend
+	if backend == "" {
+		backend = gatewaycdv1alpha1.PrometheusBackendPrometheus
+	}
+	if compat.TenantID != "" && backend != gatewaycdv1alpha1.PrometheusBackendThanos && backend != gatewaycdv1alpha1.PrometheusBackendMimir {
+		allErrs = append(allErrs, field.Invalid(path.Child("tenantID"), compat.TenantID, "tenants are supported on Thanos and Mimir only"))
+	}
+	if compat.LookbackDelta != "" {
+		if backend == gatewaycdv1alpha1.PrometheusBackendMimir {
+			allErrs = append(allErrs, field.Invalid(path.Child("lookbackDelta"), compat.LookbackDelta, "Mimir doesn't support a per-query lookback delta"))
+		} else {
+			allErrs = append(allErrs, validateWindow(compat.LookbackDelta, path.Child("lookbackDelta"))...)
+		}
+	}
+	if compat.PartialResponse && backend != gatewaycdv1alpha1.PrometheusBackendThanos {
+		allErrs = append(allErrs, field.Invalid(path.Child("partialResponse"), compat.PartialResponse, "partial responses apply to Thanos only"))
+	}
+	for name := range compat.Headers {
+		for _, msg := range validation.IsHTTPHeaderName(name) {
+			allErrs = append(allErrs, field.Invalid(path.Child("headers").Key(name), name, msg))
+		}
+	}
+	return allErrs
+}
+
+// validateWindow checks that a required duration parses and is positive
+func validateWindow(duration string, path *field.Path) field.ErrorList {
+	if duration == "" {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, duration, err.Error())}
+	}
+	if d <= 0 {
+		return field.ErrorList{field.Invalid(path, duration, "must be positive")}
+	}
+	return nil
+}
+
+// validateFractionalWeight checks that a fractional weight step can be
+// expressed with an integer weight and the configured hash buckets
+func validateFractionalWeight(spec *gatewaycdv1alpha1.CanaryDeploymentSpec, step gatewaycdv1alpha1.TrafficSplitStep, stepPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	fractionalPath := stepPath.Child("fractionalWeight")
+
+	if step.Weight != 0 {
+		allErrs = append(allErrs, field.Invalid(stepPath.Child("weight"), step.Weight, "must be 0 when fractionalWeight is set"))
+	}
+	if len(gateway.HTTPRouteNames(&spec.Gateway)) == 0 {
+		allErrs = append(allErrs, field.Invalid(fractionalPath, step.FractionalWeight, "fractional weights require an HTTPRoute"))
+	}
+
+	percent, err := strconv.ParseFloat(step.FractionalWeight, 64)
+	if err != nil {
+		return append(allErrs, field.Invalid(fractionalPath, step.FractionalWeight, "must be a decimal percentage"))
+	}
+	buckets := gateway.DefaultBuckets
+	if spec.Buckets > 0 {
+		buckets = int(spec.Buckets)
+	}
+	if _, _, err := gateway.FractionalSplit(percent, buckets); err != nil {
+		allErrs = append(allErrs, field.Invalid(fractionalPath, step.FractionalWeight, err.Error()))
+	}
+	return allErrs
+}
+
+// validateServicePorts checks that the named service ports are unique and
+// the ports selected by the routes exist
+func validateServicePorts(spec *gatewaycdv1alpha1.CanaryDeploymentSpec, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, port := range spec.Service.Ports {
+		portPath := specPath.Child("service", "ports").Index(i)
+		if port.Name == "" {
+			allErrs = append(allErrs, field.Required(portPath.Child("name"), "a service port must be named"))
+		} else if names[port.Name] {
+			allErrs = append(allErrs, field.Duplicate(portPath.Child("name"), port.Name))
+		}
+		names[port.Name] = true
+		if port.Port < 1 || port.Port > 65535 {
+			allErrs = append(allErrs, field.Invalid(portPath.Child("port"), port.Port, "must be between 1 and 65535"))
+		}
+	}
+	allErrs = append(allErrs, validatePortName(spec, spec.Gateway.Port, specPath.Child("gateway", "port"))...)
+	allErrs = append(allErrs, validatePortName(spec, spec.Gateway.GRPCPort, specPath.Child("gateway", "grpcPort"))...)
+	return allErrs
+}
+
+// validatePortName checks that a route port names one of the service ports
+func validatePortName(spec *gatewaycdv1alpha1.CanaryDeploymentSpec, name string, fldPath *field.Path) field.ErrorList {
+	if _, ok := gateway.ServicePort(&spec.Service, name); ok {
+		return nil
+	}
+	names := make([]string, 0, len(spec.Service.Ports))
+	for _, port := range spec.Service.Ports {
+		names = append(names, port.Name)
+	}
+	return field.ErrorList{field.NotSupported(fldPath, name, names)}
+}
+
+// validateRangeMetric checks the aggregation, range and resolution of a
+// metric evaluated as a range query
+func validateRangeMetric(metric gatewaycdv1alpha1.AnalysisMetric, metricPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch metric.Aggregation {
+	case "":
+		if metric.Range != "" || metric.Resolution != "" {
+			allErrs = append(allErrs, field.Required(metricPath.Child("aggregation"), "a range or resolution requires an aggregation"))
+		}
+		return allErrs
+	case gatewaycdv1alpha1.RangeAggregationAvg, gatewaycdv1alpha1.RangeAggregationMin, gatewaycdv1alpha1.RangeAggregationMax,
+		gatewaycdv1alpha1.RangeAggregationLast, gatewaycdv1alpha1.RangeAggregationP50, gatewaycdv1alpha1.RangeAggregationP90,
+		gatewaycdv1alpha1.RangeAggregationP95, gatewaycdv1alpha1.RangeAggregationP99:
+	default:
+		allErrs = append(allErrs, field.NotSupported(metricPath.Child("aggregation"), metric.Aggregation,
+			[]string{"avg", "min", "max", "last", "p50", "p90", "p95", "p99"}))
+	}
+	if metric.Range != "" {
+		allErrs = append(allErrs, validateWindow(metric.Range, metricPath.Child("range"))...)
+	}
+	if metric.Resolution != "" {
+		allErrs = append(allErrs, validateWindow(metric.Resolution, metricPath.Child("resolution"))...)
+	}
+	return allErrs
+}
+
+// validateStepHooks checks the names, actions, timeouts and failure policies
+// of the hooks of a step stage
+func validateStepHooks(hooks []gatewaycdv1alpha1.StepHook, hooksPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, hook := range hooks {
+		hookPath := hooksPath.Index(i)
+		for _, msg := range validation.IsDNS1123Label(hook.Name) {
+			allErrs = append(allErrs, field.Invalid(hookPath.Child("name"), hook.Name, msg))
+		}
+		if names[hook.Name] {
+			allErrs = append(allErrs, field.Duplicate(hookPath.Child("name"), hook.Name))
+		}
+		names[hook.Name] = true
+
+		actions := 0
+		if hook.Job != nil {
+			actions++
+			if len(hook.Job.Spec.Template.Spec.Containers) == 0 {
+				allErrs = append(allErrs, field.Required(hookPath.Child("job", "spec", "template", "spec", "containers"), "a hook Job needs a container"))
+			}
+		}
+		if webhook := hook.Webhook; webhook != nil {
+			actions++
+			if (webhook.URL == "") == (webhook.URLSecretRef == nil) {
+				allErrs = append(allErrs, field.Invalid(hookPath.Child("webhook", "url"), webhook.URL, "exactly one of url or urlSecretRef must be set"))
+			} else if webhook.URL != "" {
+				if u, err := url.Parse(webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					allErrs = append(allErrs, field.Invalid(hookPath.Child("webhook", "url"), webhook.URL, "must be an http or https URL"))
+				}
+			}
+		}
+		if hook.Expression != "" {
+			actions++
+			if _, err := expression.Parse(hook.Expression); err != nil {
+				allErrs = append(allErrs, field.Invalid(hookPath.Child("expression"), hook.Expression, err.Error()))
+			}
+		}
+		if actions != 1 {
+			allErrs = append(allErrs, field.Invalid(hookPath, hook.Name, "exactly one of job, webhook or expression must be set"))
+		}
+
+		if hook.Timeout != "" {
+			allErrs = append(allErrs, validateWindow(hook.Timeout, hookPath.Child("timeout"))...)
+		}
+		switch hook.FailurePolicy {
+		case "", gatewaycdv1alpha1.StepHookFailurePolicyFail, gatewaycdv1alpha1.StepHookFailurePolicyIgnore, gatewaycdv1alpha1.StepHookFailurePolicyRetry:
+		default:
+			allErrs = append(allErrs, field.NotSupported(hookPath.Child("failurePolicy"), hook.FailurePolicy, []string{
+				string(gatewaycdv1alpha1.StepHookFailurePolicyFail),
+				string(gatewaycdv1alpha1.StepHookFailurePolicyIgnore),
+				string(gatewaycdv1alpha1.StepHookFailurePolicyRetry),
+			}))
+		}
+		if hook.Retries < 0 {
+			allErrs = append(allErrs, field.Invalid(hookPath.Child("retries"), hook.Retries, "must not be negative"))
+		}
+	}
+	return allErrs
+}
+
+// validatePreRolloutCheck checks that the pre-rollout check has either a Job
+// or HTTP checks, and its requests and timeout
+func validatePreRolloutCheck(check *gatewaycdv1alpha1.PreRolloutCheck, checkPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if (check.Job == nil) == (len(check.HTTP) == 0) {
+		allErrs = append(allErrs, field.Invalid(checkPath, "", "exactly one of job or http must be set"))
+	}
+	if check.Job != nil && len(check.Job.Spec.Template.Spec.Containers) == 0 {
+		allErrs = append(allErrs, field.Required(checkPath.Child("job", "spec", "template", "spec", "containers"), "a check Job needs a container"))
+	}
+	for i, httpCheck := range check.HTTP {
+		httpPath := checkPath.Child("http").Index(i)
+		if httpCheck.Path != "" && !strings.HasPrefix(httpCheck.Path, "/") {
+			allErrs = append(allErrs, field.Invalid(httpPath.Child("path"), httpCheck.Path, "must start with /"))
+		}
+		switch httpCheck.Method {
+		case "", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		default:
+			allErrs = append(allErrs, field.NotSupported(httpPath.Child("method"), httpCheck.Method, []string{
+				http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions,
+			}))
+		}
+		if httpCheck.ExpectedStatus != 0 && (httpCheck.ExpectedStatus < 100 || httpCheck.ExpectedStatus > 599) {
+			allErrs = append(allErrs, field.Invalid(httpPath.Child("expectedStatus"), httpCheck.ExpectedStatus, "must be an HTTP status code"))
+		}
+	}
+	if check.Timeout != "" {
+		allErrs = append(allErrs, validateWindow(check.Timeout, checkPath.Child("timeout"))...)
+	}
+	return allErrs
+}
+
+// validateNotificationChannel checks the type, URL source and events of a notification channel
+func validateNotificationChannel(channel gatewaycdv1alpha1.NotificationChannel, channelPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch channel.Type {
+	case gatewaycdv1alpha1.NotificationChannelSlack, gatewaycdv1alpha1.NotificationChannelTeams, gatewaycdv1alpha1.NotificationChannelWebhook:
+	default:
+		allErrs = append(allErrs, field.NotSupported(channelPath.Child("type"), channel.Type, []string{
+			string(gatewaycdv1alpha1.NotificationChannelSlack),
+			string(gatewaycdv1alpha1.NotificationChannelTeams),
+			string(gatewaycdv1alpha1.NotificationChannelWebhook),
+		}))
+	}
+
+	if (channel.URL == "") == (channel.URLSecretRef == nil) {
+		allErrs = append(allErrs, field.Invalid(channelPath.Child("url"), channel.URL, "exactly one of url or urlSecretRef must be set"))
+	}
+
+	for j, event := range channel.Events {
+		switch event {
+		case gatewaycdv1alpha1.NotificationEventRolloutStarted, gatewaycdv1alpha1.NotificationEventPausedForApproval,
+			gatewaycdv1alpha1.NotificationEventAnalysisFailed, gatewaycdv1alpha1.NotificationEventRolledBack,
+			gatewaycdv1alpha1.NotificationEventPromoted, gatewaycdv1alpha1.NotificationEventProgressDeadlineExceeded:
+		default:
+			allErrs = append(allErrs, field.NotSupported(channelPath.Child("events").Index(j), event, []string{
+				string(gatewaycdv1alpha1.NotificationEventRolloutStarted),
+				string(gatewaycdv1alpha1.NotificationEventPausedForApproval),
+				string(gatewaycdv1alpha1.NotificationEventAnalysisFailed),
+				string(gatewaycdv1alpha1.NotificationEventRolledBack),
+				string(gatewaycdv1alpha1.NotificationEventPromoted),
+				string(gatewaycdv1alpha1.NotificationEventProgressDeadlineExceeded),
+			}))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gatewaycdv1alpha1 "gateway-cd/pkg/api/v1alpha1"
+)
+
+// newTestValidator returns a validator looking references up in a fake
+// client holding objs
+func newTestValidator(t *testing.T, objs ...client.Object) *CanaryDeploymentValidator {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme, gatewayapi.AddToScheme, gatewayapiv1alpha2.AddToScheme, gatewaycdv1alpha1.AddToScheme,
+	} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &CanaryDeploymentValidator{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()}
+}
+
+// newValidatorCanary returns a valid canary shifting traffic on route
+func newValidatorCanary(route string) *gatewaycdv1alpha1.CanaryDeployment {
+	return &gatewaycdv1alpha1.CanaryDeployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout"},
+		Spec: gatewaycdv1alpha1.CanaryDeploymentSpec{
+			TargetRef: gatewaycdv1alpha1.WorkloadRef{APIVersion: "apps/v1", Kind: "Deployment", Name: "checkout"},
+			Service:   gatewaycdv1alpha1.ServiceRef{Name: "checkout", Port: 8080},
+			Gateway:   gatewaycdv1alpha1.GatewayRef{HTTPRoute: route},
+			TrafficSplit: []gatewaycdv1alpha1.TrafficSplitStep{
+				{Weight: 10, Duration: "5m"},
+				{Weight: 100},
+			},
+		},
+	}
+}
+
+func TestValidateCreate(t *testing.T) {
+	route := &gatewayapi.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout"}}
+	v := newTestValidator(t, route)
+
+	tests := []struct {
+		name    string
+		canary  *gatewaycdv1alpha1.CanaryDeployment
+		wantErr string
+	}{
+		{"valid", newValidatorCanary("checkout"), ""},
+		{"missing route", newValidatorCanary("gone"), `spec.gateway.httpRoute: Not found: "shop/gone"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.ValidateCreate(context.Background(), tt.canary)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateCreate() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateCreate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	// The canary's route "checkout" was deleted after the canary was created
+	other := &gatewayapi.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout-v2"}}
+	v := newTestValidator(t, other)
+	now := metav1.Now()
+
+	tests := []struct {
+		name    string
+		update  func(canary *gatewaycdv1alpha1.CanaryDeployment)
+		wantErr string
+	}{
+		{
+			name: "control annotation",
+			update: func(canary *gatewaycdv1alpha1.CanaryDeployment) {
+				canary.Annotations = map[string]string{"gateway-cd.io/abort": "true"}
+			},
+		},
+		{
+			name: "finalizer removal of a deleting canary",
+			update: func(canary *gatewaycdv1alpha1.CanaryDeployment) {
+				canary.DeletionTimestamp = &now
+				canary.Finalizers = nil
+			},
+		},
+		{
+			name: "spec change of a deleting canary",
+			update: func(canary *gatewaycdv1alpha1.CanaryDeployment) {
+				canary.DeletionTimestamp = &now
+				canary.Spec.TrafficSplit[0].Weight = 150
+			},
+		},
+		{
+			name: "spec change keeping the deleted route",
+			update: func(canary *gatewaycdv1alpha1.CanaryDeployment) {
+				canary.Spec.TrafficSplit[0].Weight = 20
+			},
+		},
+		{
+			name: "route changed to an existing route",
+			update: func(canary *gatewaycdv1alpha1.CanaryDeployment) {
+				canary.Spec.Gateway.HTTPRoute = "checkout-v2"
+			},
+		},
+		{
+			name: "route changed to a missing route",
+			update: func(canary *gatewaycdv1alpha1.CanaryDeployment) {
+				canary.Spec.Gateway.HTTPRoute = "checkout-v3"
+			},
+			wantErr: `spec.gateway.httpRoute: Not found: "shop/checkout-v3"`,
+		},
+		{
+			name: "route added to a missing route",
+			update: func(canary *gatewaycdv1alpha1.CanaryDeployment) {
+				canary.Spec.Gateway.HTTPRoutes = []string{"internal"}
+			},
+			wantErr: `spec.gateway.httpRoutes[0]: Not found: "shop/internal"`,
+		},
+		{
+			name: "invalid spec",
+			update: func(canary *gatewaycdv1alpha1.CanaryDeployment) {
+				canary.Spec.TrafficSplit[0].Weight = 150
+			},
+			wantErr: "spec.trafficSplit[0].weight: Invalid value: 150: must be between 0 and 100",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := newValidatorCanary("checkout")
+			old.Finalizers = []string{"gateway-cd.io/finalizer"}
+			canary := old.DeepCopy()
+			tt.update(canary)
+
+			_, err := v.ValidateUpdate(context.Background(), old, canary)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateUpdate() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateUpdate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSpecSmoothing(t *testing.T) {
+	minPassed := func(n int32) *int32 { return &n }
+	tests := []struct {
+		name      string
+		smoothing *gatewaycdv1alpha1.AnalysisSmoothing
+		wantErr   string
+	}{
+		{"averaged", &gatewaycdv1alpha1.AnalysisSmoothing{Window: 5}, ""},
+		{"K of M", &gatewaycdv1alpha1.AnalysisSmoothing{Window: 5, MinPassed: minPassed(3)}, ""},
+		{"all of the window", &gatewaycdv1alpha1.AnalysisSmoothing{Window: 5, MinPassed: minPassed(5)}, ""},
+		{"window too small", &gatewaycdv1alpha1.AnalysisSmoothing{Window: 1}, "spec.analysis.smoothing.window"},
+		{"zero passed", &gatewaycdv1alpha1.AnalysisSmoothing{Window: 5, MinPassed: minPassed(0)}, "spec.analysis.smoothing.minPassed: Invalid value: 0"},
+		{"negative passed", &gatewaycdv1alpha1.AnalysisSmoothing{Window: 5, MinPassed: minPassed(-1)}, "spec.analysis.smoothing.minPassed"},
+		{"more than the window", &gatewaycdv1alpha1.AnalysisSmoothing{Window: 5, MinPassed: minPassed(6)}, "spec.analysis.smoothing.minPassed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canary := newValidatorCanary("checkout")
+			canary.Spec.Analysis.Smoothing = tt.smoothing
+
+			err := ValidateSpec(&canary.Spec).ToAggregate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateSpec() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateSpec() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,7 +5,27 @@ const api = axios.create({
   timeout: 30000,
 })
 
+// The API server may require a bearer token (--auth=tokenreview or --auth=oidc)
+const TOKEN_KEY = 'gateway-cd-token'
+
+export const setAuthToken = (token: string | null) => {
+  if (token) {
+    localStorage.setItem(TOKEN_KEY, token)
+  } else {
+    localStorage.removeItem(TOKEN_KEY)
+  }
+}
+
+api.interceptors.request.use((config) => {
+  const token = localStorage.getItem(TOKEN_KEY)
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`
+  }
+  return config
+})
+
 export interface CanaryDeployment {
+  cluster?: string
   metadata: {
     name: string
     namespace: string
@@ -24,6 +44,7 @@ export interface CanaryDeployment {
     }
     gateway: {
       httpRoute: string
+      httpRoutes?: string[]
       gateway?: string
       namespace?: string
     }
@@ -77,6 +98,7 @@ export interface CanaryDeployment {
 }
 
 export interface CanaryStatus {
+  cluster?: string
   phase: string
   message: string
   currentStep: number
@@ -127,52 +149,55 @@ export interface HistoryEntry {
   message: string
 }
 
+// clusterParams selects the cluster of a canary when the API server aggregates several
+const clusterParams = (cluster?: string) => (cluster ? { cluster } : {})
+
 export const canaryApi = {
-  // List all canary deployments
-  list: (namespace?: string) =>
+  // List all canary deployments, across every cluster unless one is given
+  list: (namespace?: string, cluster?: string) =>
     api.get<CanaryDeployment[]>('/canaries', {
-      params: namespace ? { namespace } : {},
+      params: { ...(namespace ? { namespace } : {}), ...clusterParams(cluster) },
     }),
 
   // Get a specific canary deployment
-  get: (namespace: string, name: string) =>
-    api.get<CanaryDeployment>(`/canaries/${namespace}/${name}`),
+  get: (namespace: string, name: string, cluster?: string) =>
+    api.get<CanaryDeployment>(`/canaries/${namespace}/${name}`, { params: clusterParams(cluster) }),
 
   // Create a new canary deployment
-  create: (canary: Partial<CanaryDeployment>) =>
-    api.post<CanaryDeployment>('/canaries', canary),
+  create: (canary: Partial<CanaryDeployment>, cluster?: string) =>
+    api.post<CanaryDeployment>('/canaries', canary, { params: clusterParams(cluster) }),
 
   // Update a canary deployment
-  update: (namespace: string, name: string, canary: Partial<CanaryDeployment>) =>
-    api.put<CanaryDeployment>(`/canaries/${namespace}/${name}`, canary),
+  update: (namespace: string, name: string, canary: Partial<CanaryDeployment>, cluster?: string) =>
+    api.put<CanaryDeployment>(`/canaries/${namespace}/${name}`, canary, { params: clusterParams(cluster) }),
 
   // Delete a canary deployment
-  delete: (namespace: string, name: string) =>
-    api.delete(`/canaries/${namespace}/${name}`),
+  delete: (namespace: string, name: string, cluster?: string) =>
+    api.delete(`/canaries/${namespace}/${name}`, { params: clusterParams(cluster) }),
 
   // Control operations
-  resume: (namespace: string, name: string) =>
-    api.post(`/canaries/${namespace}/${name}/resume`),
+  resume: (namespace: string, name: string, cluster?: string) =>
+    api.post(`/canaries/${namespace}/${name}/resume`, undefined, { params: clusterParams(cluster) }),
 
-  pause: (namespace: string, name: string) =>
-    api.post(`/canaries/${namespace}/${name}/pause`),
+  pause: (namespace: string, name: string, cluster?: string) =>
+    api.post(`/canaries/${namespace}/${name}/pause`, undefined, { params: clusterParams(cluster) }),
 
-  abort: (namespace: string, name: string) =>
-    api.post(`/canaries/${namespace}/${name}/abort`),
+  abort: (namespace: string, name: string, cluster?: string) =>
+    api.post(`/canaries/${namespace}/${name}/abort`, undefined, { params: clusterParams(cluster) }),
 
-  promote: (namespace: string, name: string) =>
-    api.post(`/canaries/${namespace}/${name}/promote`),
+  promote: (namespace: string, name: string, cluster?: string) =>
+    api.post(`/canaries/${namespace}/${name}/promote`, undefined, { params: clusterParams(cluster) }),
 
   // Status and metrics
-  getStatus: (namespace: string, name: string) =>
-    api.get<CanaryStatus>(`/canaries/${namespace}/${name}/status`),
+  getStatus: (namespace: string, name: string, cluster?: string) =>
+    api.get<CanaryStatus>(`/canaries/${namespace}/${name}/status`, { params: clusterParams(cluster) }),
 
-  getMetrics: (namespace: string, name: string) =>
-    api.get<CanaryMetrics>(`/canaries/${namespace}/${name}/metrics`),
+  getMetrics: (namespace: string, name: string, cluster?: string) =>
+    api.get<CanaryMetrics>(`/canaries/${namespace}/${name}/metrics`, { params: clusterParams(cluster) }),
 
-  getHistory: (namespace: string, name: string, limit?: number) =>
+  getHistory: (namespace: string, name: string, limit?: number, cluster?: string) =>
     api.get<HistoryEntry[]>(`/canaries/${namespace}/${name}/history`, {
-      params: limit ? { limit } : {},
+      params: { ...(limit ? { limit } : {}), ...clusterParams(cluster) },
     }),
 
   // Health check